@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"mime/multipart"
+	"path"
 	"strings"
 
 	"github.com/ipfs-cluster/ipfs-cluster/adder/ipfsadd"
@@ -57,6 +58,13 @@ type dagFormatter interface {
 	Add(name string, f files.Node) (api.Cid, error)
 }
 
+// A rootsAdder is a dagFormatter adding several roots, which decides the
+// root of everything added once done.
+type rootsAdder interface {
+	dagFormatter
+	Root() (api.Cid, error)
+}
+
 // Adder is used to add content to IPFS Cluster using an implementation of
 // ClusterDAGService.
 type Adder struct {
@@ -138,6 +146,8 @@ func (a *Adder) FromFiles(ctx context.Context, f files.Directory) (api.Cid, erro
 
 	case "car":
 		dagFmtr, err = newCarAdder(ctx, a.dgs, a.params, a.output)
+	case "dag-json", "dag-cbor":
+		dagFmtr, err = newDagAdder(ctx, a.dgs, a.params, a.output)
 	default:
 		err = errors.New("bad dag formatter option")
 	}
@@ -145,8 +155,10 @@ func (a *Adder) FromFiles(ctx context.Context, f files.Directory) (api.Cid, erro
 		return api.CidUndef, err
 	}
 
-	// setup wrapping
-	if a.params.Wrap {
+	// setup wrapping. CAR and IPLD node roots are wrapped by their
+	// adders.
+	_, wrapsRoots := dagFmtr.(rootsAdder)
+	if a.params.Wrap && !wrapsRoots {
 		f = files.NewSliceDirectory(
 			[]files.DirEntry{files.FileEntry("", f)},
 		)
@@ -167,16 +179,19 @@ func (a *Adder) FromFiles(ctx context.Context, f files.Directory) (api.Cid, erro
 				return api.CidUndef, err
 			}
 		}
-		// TODO (hector): We can only add a single CAR file for the
-		// moment.
-		if a.params.Format == "car" {
-			break
-		}
 	}
 	if it.Err() != nil {
 		return api.CidUndef, it.Err()
 	}
 
+	if ra, ok := dagFmtr.(rootsAdder); ok {
+		adderRoot, err = ra.Root()
+		if err != nil {
+			logger.Error("error adding to cluster: ", err)
+			return api.CidUndef, err
+		}
+	}
+
 	clusterRoot, err := a.dgs.Finalize(a.ctx, adderRoot)
 	if err != nil {
 		logger.Error("error finalizing adder:", err)
@@ -247,16 +262,19 @@ func (ia *ipfsAdder) Add(name string, f files.Node) (api.Cid, error) {
 	return api.NewCid(nd.Cid()), nil
 }
 
-// An adder to add CAR files. It is at the moment very basic, and can
-// add a single CAR file with a single root. Ideally, it should be able to
-// add more complex, or several CARs by wrapping them with a single root.
-// But for that we would need to keep state and track an MFS root similarly to
-// what the ipfsadder does.
+// An adder to add CAR files. The blocks of every CAR file are added using
+// the ClusterDAGService. When there is a single root among all the CAR
+// files, that is the root of the added content. Otherwise, or when wrapping
+// is requested, the roots are linked from a new directory node, named after
+// their CIDs, which becomes the root. Pinning it recursively pins all of the
+// roots.
 type carAdder struct {
 	ctx    context.Context
 	dgs    ClusterDAGService
 	params api.AddParams
 	output chan api.AddedOutput
+
+	roots []cid.Cid
 }
 
 func newCarAdder(ctx context.Context, dgs ClusterDAGService, params api.AddParams, out chan api.AddedOutput) (*carAdder, error) {
@@ -269,12 +287,9 @@ func newCarAdder(ctx context.Context, dgs ClusterDAGService, params api.AddParam
 }
 
 // Add takes a node which should be a CAR file and nothing else and
-// adds its blocks using the ClusterDAGService.
+// adds its blocks using the ClusterDAGService. It returns the last root of
+// the CAR file.
 func (ca *carAdder) Add(name string, fn files.Node) (api.Cid, error) {
-	if ca.params.Wrap {
-		return api.CidUndef, errors.New("cannot wrap a CAR file upload")
-	}
-
 	f, ok := fn.(files.File)
 	if !ok {
 		return api.CidUndef, errors.New("expected CAR file is not of type file")
@@ -284,13 +299,10 @@ func (ca *carAdder) Add(name string, fn files.Node) (api.Cid, error) {
 		return api.CidUndef, err
 	}
 
-	if len(carReader.Header.Roots) != 1 {
-		return api.CidUndef, errors.New("only CAR files with a single root are supported")
-	}
-
-	root := carReader.Header.Roots[0]
-	bytes := uint64(0)
-	size := uint64(0)
+	roots := carReader.Header.Roots
+	bytes := make(map[cid.Cid]uint64, len(roots))
+	sizes := make(map[cid.Cid]uint64, len(roots))
+	total := uint64(0)
 
 	for {
 		block, err := carReader.Next()
@@ -300,19 +312,23 @@ func (ca *carAdder) Add(name string, fn files.Node) (api.Cid, error) {
 			break
 		}
 
-		bytes += uint64(len(block.RawData()))
+		total += uint64(len(block.RawData()))
 
 		nd, err := ipld.Decode(block)
 		if err != nil {
 			return api.CidUndef, err
 		}
 
-		// If the root is in the CAR and the root is a UnixFS
-		// node, then set the size in the output object.
-		if nd.Cid().Equals(root) {
+		// If a root is in the CAR and it is a UnixFS node, then set
+		// the size in the output object.
+		for _, r := range roots {
+			if !nd.Cid().Equals(r) {
+				continue
+			}
+			bytes[r] = uint64(len(block.RawData()))
 			ufs, err := unixfs.ExtractFSNode(nd)
 			if err == nil {
-				size = ufs.FileSize()
+				sizes[r] = ufs.FileSize()
 			}
 		}
 
@@ -322,13 +338,75 @@ func (ca *carAdder) Add(name string, fn files.Node) (api.Cid, error) {
 		}
 	}
 
+	for _, r := range roots {
+		out := api.AddedOutput{
+			Name:        name,
+			Cid:         api.NewCid(r),
+			Bytes:       bytes[r],
+			Size:        sizes[r],
+			Allocations: ca.dgs.Allocations(),
+		}
+		if len(roots) == 1 {
+			out.Bytes = total
+		} else {
+			out.Name = path.Join(name, r.String())
+		}
+		ca.output <- out
+		ca.addRoot(r)
+	}
+
+	return api.NewCid(roots[len(roots)-1]), nil
+}
+
+func (ca *carAdder) addRoot(r cid.Cid) {
+	for _, c := range ca.roots {
+		if c.Equals(r) {
+			return
+		}
+	}
+	ca.roots = append(ca.roots, r)
+}
+
+// Root returns the root of all the CAR files added, wrapping their roots
+// in a directory if there are several of them or if wrapping was requested.
+func (ca *carAdder) Root() (api.Cid, error) {
+	if len(ca.roots) == 0 {
+		return api.CidUndef, errors.New("nothing was added")
+	}
+	if len(ca.roots) == 1 && !ca.params.Wrap {
+		return api.NewCid(ca.roots[0]), nil
+	}
+
+	prefix, err := merkledag.PrefixForCidVersion(ca.params.CidVersion)
+	if err != nil {
+		return api.CidUndef, fmt.Errorf("bad CID Version: %s", err)
+	}
+	hashFunCode, ok := multihash.Names[strings.ToLower(ca.params.HashFun)]
+	if !ok {
+		return api.CidUndef, errors.New("hash function name not known")
+	}
+	prefix.MhType = hashFunCode
+	prefix.MhLength = -1
+
+	dir := unixfs.EmptyDirNode()
+	dir.SetCidBuilder(&prefix)
+	for _, r := range ca.roots {
+		err := dir.AddRawLink(r.String(), &ipld.Link{Cid: r})
+		if err != nil {
+			return api.CidUndef, err
+		}
+	}
+
+	err = ca.dgs.Add(ca.ctx, dir)
+	if err != nil {
+		return api.CidUndef, err
+	}
+
+	root := api.NewCid(dir.Cid())
 	ca.output <- api.AddedOutput{
-		Name:        name,
-		Cid:         api.NewCid(root),
-		Bytes:       bytes,
-		Size:        size,
+		Cid:         root,
+		Bytes:       uint64(len(dir.RawData())),
 		Allocations: ca.dgs.Allocations(),
 	}
-
-	return api.NewCid(root), nil
+	return root, nil
 }
@@ -6,16 +6,22 @@ import (
 	"fmt"
 	"mime/multipart"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/ipfs-cluster/ipfs-cluster/api"
 	"github.com/ipfs-cluster/ipfs-cluster/test"
 	"github.com/ipld/go-car"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
 	peer "github.com/libp2p/go-libp2p/core/peer"
 
 	cid "github.com/ipfs/go-cid"
 	files "github.com/ipfs/go-ipfs-files"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	ipld "github.com/ipfs/go-ipld-format"
+	merkledag "github.com/ipfs/go-merkledag"
+	multihash "github.com/multiformats/go-multihash"
 )
 
 type mockCDAGServ struct {
@@ -203,6 +209,221 @@ func TestAdder_CAR(t *testing.T) {
 
 }
 
+// carMultipart writes a CAR file for every set of roots and returns them in
+// a multipart reader.
+func carMultipart(t *testing.T, dags ipld.NodeGetter, roots ...[]cid.Cid) *multipart.Reader {
+	ctx := context.Background()
+	entries := make(map[string]files.Node)
+	for i, r := range roots {
+		var carBuf bytes.Buffer
+		err := car.WriteCar(ctx, dags, r, &carBuf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[fmt.Sprintf("file%d.car", i)] = files.NewReaderFile(&carBuf)
+	}
+	mf := files.NewMultiFileReader(files.NewMapDirectory(entries), true)
+	return multipart.NewReader(mf, mf.Boundary())
+}
+
+func TestAdder_CARMultipleRoots(t *testing.T) {
+	ctx := context.Background()
+	dags := newReadableMockCDAGServ()
+	nd1 := merkledag.NodeWithData([]byte("first root"))
+	nd2 := merkledag.NodeWithData([]byte("second root"))
+	nd3 := merkledag.NodeWithData([]byte("third root"))
+	for _, nd := range []ipld.Node{nd1, nd2, nd3} {
+		if err := dags.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkRoot := func(t *testing.T, dags *mockCDAGServ, root api.Cid, expected ...ipld.Node) {
+		nd, ok := dags.Nodes[root.Cid]
+		if !ok {
+			t.Fatal("the wrapping directory was not added")
+		}
+		links := nd.Links()
+		if len(links) != len(expected) {
+			t.Fatalf("expected %d links in the root: got %d", len(expected), len(links))
+		}
+		found := make(map[string]bool)
+		for _, l := range links {
+			if l.Name != l.Cid.String() {
+				t.Error("links should be named after the roots:", l.Name)
+			}
+			found[l.Cid.String()] = true
+		}
+		for _, e := range expected {
+			if !found[e.Cid().String()] {
+				t.Error("root not linked:", e.Cid())
+			}
+			if _, ok := dags.Nodes[e.Cid()]; !ok {
+				t.Error("block not extracted from CAR:", e.Cid())
+			}
+		}
+	}
+
+	p := api.DefaultAddParams()
+	p.Format = "car"
+
+	t.Run("single CAR", func(t *testing.T) {
+		r := carMultipart(t, dags, []cid.Cid{nd1.Cid(), nd2.Cid()})
+		added := newMockCDAGServ()
+		defer added.Close()
+		root, err := New(added, p, nil).FromMultipart(ctx, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkRoot(t, added, root, nd1, nd2)
+	})
+
+	t.Run("several CARs", func(t *testing.T) {
+		r := carMultipart(t, dags, []cid.Cid{nd1.Cid()}, []cid.Cid{nd2.Cid(), nd3.Cid()})
+		added := newMockCDAGServ()
+		defer added.Close()
+		root, err := New(added, p, nil).FromMultipart(ctx, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkRoot(t, added, root, nd1, nd2, nd3)
+	})
+
+	t.Run("wrap single root", func(t *testing.T) {
+		r := carMultipart(t, dags, []cid.Cid{nd1.Cid()})
+		added := newMockCDAGServ()
+		defer added.Close()
+		wp := p
+		wp.Wrap = true
+		root, err := New(added, wp, nil).FromMultipart(ctx, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkRoot(t, added, root, nd1)
+	})
+}
+
+// nodesMultipart returns the given IPLD nodes as files of a multipart
+// reader.
+func nodesMultipart(nodes ...[]byte) *multipart.Reader {
+	entries := make(map[string]files.Node)
+	for i, nd := range nodes {
+		entries[fmt.Sprintf("node%d", i)] = files.NewBytesFile(nd)
+	}
+	mf := files.NewMultiFileReader(files.NewMapDirectory(entries), true)
+	return multipart.NewReader(mf, mf.Boundary())
+}
+
+func TestAdder_DagNodes(t *testing.T) {
+	ctx := context.Background()
+
+	add := func(t *testing.T, format string, nodes ...[]byte) (api.Cid, *mockCDAGServ, error) {
+		p := api.DefaultAddParams()
+		p.Format = format
+		dags := newMockCDAGServ()
+		root, err := New(dags, p, nil).FromMultipart(ctx, nodesMultipart(nodes...))
+		return root, dags, err
+	}
+
+	t.Run("dag-json", func(t *testing.T) {
+		root, dags, err := add(t, "dag-json", []byte(`{"hello":"world"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer dags.Close()
+		// as given by "ipfs dag put".
+		if root.String() != "bafyreidykglsfhoixmivffc5uwhcgshx4j465xwqntbmu43nb2dzqwfvae" {
+			t.Error("unexpected root:", root)
+		}
+		if _, ok := dags.Nodes[root.Cid]; !ok {
+			t.Error("the node was not added")
+		}
+	})
+
+	t.Run("dag-cbor", func(t *testing.T) {
+		nd, err := cbor.WrapObject(map[string]interface{}{
+			"link": test.Cid1.Cid,
+		}, multihash.SHA2_256, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root, dags, err := add(t, "dag-cbor", nd.RawData())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer dags.Close()
+		if !root.Cid.Equals(nd.Cid()) {
+			t.Error("unexpected root:", root)
+		}
+		links := dags.Nodes[root.Cid].Links()
+		if len(links) != 1 || !links[0].Cid.Equals(test.Cid1.Cid) {
+			t.Error("expected the link to be kept")
+		}
+
+		// the same node in dag-json
+		root2, _, err := add(t, "dag-json", []byte(`{"link":{"/":"`+test.Cid1.String()+`"}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !root.Equals(root2) {
+			t.Error("expected the same node in both codecs")
+		}
+	})
+
+	t.Run("several nodes", func(t *testing.T) {
+		root, dags, err := add(t, "dag-json", []byte(`{"a":1}`), []byte(`{"b":2}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer dags.Close()
+		if root.Type() != cid.DagProtobuf || len(dags.Nodes[root.Cid].Links()) != 2 {
+			t.Error("expected the nodes to be wrapped in a directory")
+		}
+	})
+
+	t.Run("bad node", func(t *testing.T) {
+		if _, _, err := add(t, "dag-json", []byte(`{"a":`)); err == nil {
+			t.Error("expected an error decoding the node")
+		}
+	})
+}
+
+func TestAdder_ContentDefinedChunkers(t *testing.T) {
+	data := make([]byte, 2*1024*1024)
+	for i := range data {
+		data[i] = byte(i * 7 % 251)
+	}
+
+	add := func(chunker string) (api.Cid, int) {
+		p := api.DefaultAddParams()
+		p.Chunker = chunker
+		dags := newMockCDAGServ()
+		defer dags.Close()
+		dir := files.NewMapDirectory(map[string]files.Node{
+			"file": files.NewBytesFile(data),
+		})
+		root, err := New(dags, p, nil).FromFiles(context.Background(), dir)
+		if err != nil {
+			t.Fatal(chunker, err)
+		}
+		return root, len(dags.Nodes)
+	}
+
+	defaultRoot, _ := add("size-262144")
+	for _, chunker := range []string{"rabin", "rabin-16-4096-65536", "buzhash"} {
+		root, blocks := add(chunker)
+		if root == defaultRoot {
+			t.Errorf("%s: expected a different root than the size chunker", chunker)
+		}
+		if blocks < 2 {
+			t.Errorf("%s: expected the content to be chunked", chunker)
+		}
+		if again, _ := add(chunker); again != root {
+			t.Errorf("%s: expected the same root when adding again", chunker)
+		}
+	}
+}
+
 func TestAdder_LargeFolder(t *testing.T) {
 	items := 10000 // add 10000 items
 
@@ -231,3 +452,78 @@ func TestAdder_LargeFolder(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+type blockStreamRPC struct {
+	calls   int32
+	release chan struct{}
+
+	mu       sync.Mutex
+	received map[int32]int
+}
+
+// BlockStream receives blocks, holding back the first stream until
+// released.
+func (rpcs *blockStreamRPC) BlockStream(ctx context.Context, in <-chan api.NodeWithMeta, out chan<- struct{}) error {
+	defer close(out)
+	call := atomic.AddInt32(&rpcs.calls, 1)
+	for range in {
+		if call == 1 {
+			<-rpcs.release
+		}
+		rpcs.mu.Lock()
+		rpcs.received[call]++
+		rpcs.mu.Unlock()
+	}
+	return nil
+}
+
+func (rpcs *blockStreamRPC) count(call int32) int {
+	rpcs.mu.Lock()
+	defer rpcs.mu.Unlock()
+	return rpcs.received[call]
+}
+
+func TestBlockStreamer_SlowDestination(t *testing.T) {
+	ipfsRPC := &blockStreamRPC{
+		release:  make(chan struct{}),
+		received: make(map[int32]int),
+	}
+	server := rpc.NewServer(nil, "mock")
+	if err := server.RegisterName("IPFSConnector", ipfsRPC); err != nil {
+		t.Fatal(err)
+	}
+	// Without a host all calls are local.
+	client := rpc.NewClientWithServer(nil, "mock", server)
+
+	nBlocks := 10
+	blocks := make(chan api.NodeWithMeta, nBlocks)
+	for i := 0; i < nBlocks; i++ {
+		nd := merkledag.NodeWithData([]byte(fmt.Sprintf("block %d", i)))
+		blocks <- IpldNodeToNodeWithMeta(nd)
+	}
+	close(blocks)
+
+	dests := []peer.ID{test.PeerID1, test.PeerID2}
+	bs := NewBlockStreamer(context.Background(), client, dests, blocks)
+
+	// The second destination gets all the blocks while the first
+	// one is stuck.
+	for i := 0; ipfsRPC.count(2) < nBlocks; i++ {
+		if i > 50 {
+			t.Fatal("blocks were not streamed to the second destination")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if ipfsRPC.count(1) != 0 {
+		t.Fatal("the first destination should be stuck")
+	}
+
+	close(ipfsRPC.release)
+	<-bs.Done()
+	if err := bs.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if ipfsRPC.count(1) != nBlocks {
+		t.Error("expected all blocks in the first destination")
+	}
+}
@@ -19,6 +19,36 @@ import (
 
 var logger = logging.Logger("adder")
 
+// acquireBudget waits until the cluster's concurrency budget allows adding
+// content. The returned function gives back the budget.
+func acquireBudget(ctx context.Context, rpc *rpc.Client) (func(), error) {
+	err := rpc.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"AcquireBudget",
+		struct{}{},
+		&struct{}{},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	release := func() {
+		err := rpc.Call(
+			"",
+			"Cluster",
+			"ReleaseBudget",
+			struct{}{},
+			&struct{}{},
+		)
+		if err != nil {
+			logger.Error(err)
+		}
+	}
+	return release, nil
+}
+
 // AddMultipartHTTPHandler is a helper function to add content
 // uploaded using a multipart request. The outputTransform parameter
 // allows to customize the http response output format to something
@@ -31,6 +61,22 @@ func AddMultipartHTTPHandler(
 	w http.ResponseWriter,
 	outputTransform func(api.AddedOutput) interface{},
 ) (api.Cid, error) {
+	release, err := acquireBudget(ctx, rpc)
+	if err != nil {
+		logger.Error(err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		errorResp := api.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+		if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+			logger.Error(err)
+		}
+		return api.CidUndef, err
+	}
+	defer release()
+
 	var dags adder.ClusterDAGService
 	output := make(chan api.AddedOutput, 200)
 
@@ -0,0 +1,134 @@
+package adderutils
+
+import (
+	"context"
+	"mime/multipart"
+	"sync"
+
+	"github.com/ipfs-cluster/ipfs-cluster/adder"
+	"github.com/ipfs-cluster/ipfs-cluster/adder/single"
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+
+	ipld "github.com/ipfs/go-ipld-format"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+// CARRoot is a root of an imported CAR file along with the error pinning
+// it, if any.
+type CARRoot struct {
+	Cid      api.Cid
+	PinError error
+}
+
+// CARImport is the result of ImportCARs.
+type CARImport struct {
+	Roots  []CARRoot
+	Blocks uint64
+	Bytes  uint64
+}
+
+// ImportCARs adds the blocks in the CAR files of a multipart upload to the
+// cluster peers allocated to them and then pins every root of the CAR files
+// on those peers, unless params.NoPin is set. This is what "ipfs dag import"
+// does, whereas adding CAR files with several roots pins a directory
+// wrapping them.
+func ImportCARs(
+	ctx context.Context,
+	rpc *rpc.Client,
+	params api.AddParams,
+	reader *multipart.Reader,
+) (CARImport, error) {
+	pinRoots := !params.NoPin
+	params.Format = "car"
+	params.Wrap = false
+	params.Shard = false
+	params.Mode = api.PinModeRecursive
+	params.NoPin = true // roots are pinned below
+
+	release, err := acquireBudget(ctx, rpc)
+	if err != nil {
+		return CARImport{}, err
+	}
+	defer release()
+
+	dags := &countingDAGService{
+		ClusterDAGService: single.New(ctx, rpc, params, params.Local),
+	}
+	defer dags.Close()
+
+	output := make(chan api.AddedOutput, 200)
+	var outputs []api.AddedOutput
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for out := range output {
+			outputs = append(outputs, out)
+		}
+	}()
+
+	add := adder.New(dags, params, output)
+	_, err = add.FromMultipart(ctx, reader)
+	wg.Wait()
+	result := CARImport{
+		Blocks: dags.blocks,
+		Bytes:  dags.bytes,
+	}
+	if err != nil {
+		return result, err
+	}
+
+	var roots []api.Cid
+	seen := make(map[api.Cid]struct{})
+	for _, out := range outputs {
+		if _, ok := seen[out.Cid]; ok {
+			continue
+		}
+		seen[out.Cid] = struct{}{}
+		roots = append(roots, out.Cid)
+	}
+	// With several roots, the adder outputs the directory wrapping
+	// them last. It is not part of the CAR files.
+	if len(roots) > 1 {
+		roots = roots[:len(roots)-1]
+		result.Blocks--
+		result.Bytes -= outputs[len(outputs)-1].Bytes
+	}
+
+	for _, root := range roots {
+		carRoot := CARRoot{Cid: root}
+		if pinRoots {
+			rootPin := api.PinWithOpts(root, params.PinOptions)
+			rootPin.Allocations = dags.Allocations()
+			carRoot.PinError = adder.Pin(ctx, rpc, rootPin)
+		}
+		result.Roots = append(result.Roots, carRoot)
+	}
+	return result, nil
+}
+
+// countingDAGService wraps a ClusterDAGService to count the blocks added.
+type countingDAGService struct {
+	adder.ClusterDAGService
+
+	blocks uint64
+	bytes  uint64
+}
+
+func (dgs *countingDAGService) Add(ctx context.Context, node ipld.Node) error {
+	if err := dgs.ClusterDAGService.Add(ctx, node); err != nil {
+		return err
+	}
+	dgs.blocks++
+	dgs.bytes += uint64(len(node.RawData()))
+	return nil
+}
+
+func (dgs *countingDAGService) AddMany(ctx context.Context, nodes []ipld.Node) error {
+	for _, node := range nodes {
+		if err := dgs.Add(ctx, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
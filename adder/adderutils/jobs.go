@@ -0,0 +1,476 @@
+package adderutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/adder"
+	"github.com/ipfs-cluster/ipfs-cluster/adder/s3"
+	"github.com/ipfs-cluster/ipfs-cluster/adder/sharding"
+	"github.com/ipfs-cluster/ipfs-cluster/adder/single"
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+
+	"github.com/google/uuid"
+	files "github.com/ipfs/go-ipfs-files"
+	ipld "github.com/ipfs/go-ipld-format"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+// AddJobsRetention is how long finished add jobs are kept around so that
+// their results can be retrieved.
+var AddJobsRetention = time.Hour
+
+// ErrAddJobNotFound is returned when requesting an unknown add job.
+var ErrAddJobNotFound = errors.New("add job not found")
+
+// AddURLTimeout is how long fetching the content of a URL to add can take.
+var AddURLTimeout = time.Hour
+
+// ErrAddTooLarge is returned when an upload is larger than allowed.
+var ErrAddTooLarge = errors.New("upload too large")
+
+// ErrAddURLForbidden is returned when fetching content from a loopback,
+// private or link-local address, which is not allowed.
+var ErrAddURLForbidden = errors.New("fetching from this address is not allowed")
+
+// AddJobs runs adds in the background and keeps track of their progress.
+type AddJobs struct {
+	mu   sync.RWMutex
+	jobs map[string]*api.AddJob
+	// the parameters of the jobs waiting for their upload
+	waiting map[string]api.AddParams
+}
+
+// NewAddJobs returns a new AddJobs.
+func NewAddJobs() *AddJobs {
+	return &AddJobs{
+		jobs:    make(map[string]*api.AddJob),
+		waiting: make(map[string]api.AddParams),
+	}
+}
+
+// Get returns the current state of the add job with the given ID.
+func (aj *AddJobs) Get(id string) (api.AddJob, error) {
+	aj.mu.RLock()
+	defer aj.mu.RUnlock()
+	job, ok := aj.jobs[id]
+	if !ok {
+		return api.AddJob{}, ErrAddJobNotFound
+	}
+	return *job, nil
+}
+
+// New registers a new add job with the given parameters, which waits for
+// its upload to be given to Start. This way, the job ID can be returned to
+// the client before the upload is sent. Jobs whose upload does not arrive
+// within AddJobsRetention are dropped.
+func (aj *AddJobs) New(params api.AddParams) api.AddJob {
+	aj.purge()
+	job := aj.newJob(api.AddJobUploading, 0)
+	aj.mu.Lock()
+	aj.waiting[job.ID] = params
+	aj.mu.Unlock()
+	return aj.get(job)
+}
+
+// Start reads the multipart upload with the given boundary for the waiting
+// add job with the given ID into a temporary file, up to maxSize bytes, and
+// then adds it in the background, until done or until the context is
+// cancelled. It returns the job once the upload has been received. The job
+// finishes with an error when the upload fails.
+func (aj *AddJobs) Start(
+	ctx context.Context,
+	rpc *rpc.Client,
+	id string,
+	body io.Reader,
+	boundary string,
+	maxSize uint64,
+) (api.AddJob, error) {
+	aj.mu.Lock()
+	params, ok := aj.waiting[id]
+	job := aj.jobs[id]
+	delete(aj.waiting, id)
+	aj.mu.Unlock()
+	if !ok {
+		return api.AddJob{}, ErrAddJobNotFound
+	}
+
+	f, err := os.CreateTemp("", "ipfs-cluster-add-")
+	if err != nil {
+		aj.finish(job, api.CidUndef, err)
+		return aj.get(job), err
+	}
+	upload := &uploadReader{
+		Reader: io.LimitReader(body, int64(maxSize)+1),
+		jobs:   aj,
+		job:    job,
+	}
+	n, err := io.Copy(f, upload)
+	if err == nil && uint64(n) > maxSize {
+		err = ErrAddTooLarge
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		aj.finish(job, api.CidUndef, err)
+		return aj.get(job), err
+	}
+
+	return aj.startFile(ctx, rpc, params, f, boundary, job), nil
+}
+
+// startFile adds the multipart upload with the given boundary in the file
+// in the background for the given job, removing the file when done.
+func (aj *AddJobs) startFile(
+	ctx context.Context,
+	rpc *rpc.Client,
+	params api.AddParams,
+	f *os.File,
+	boundary string,
+	job *api.AddJob,
+) api.AddJob {
+	aj.mu.Lock()
+	job.Status = api.AddJobRunning
+	aj.mu.Unlock()
+	go func() {
+		defer os.Remove(f.Name())
+		defer f.Close()
+		dir, err := files.NewFileFromPartReader(multipart.NewReader(f, boundary), "multipart/form-data")
+		if err != nil {
+			aj.finish(job, api.CidUndef, err)
+			return
+		}
+		defer dir.Close()
+		root, err := aj.run(ctx, rpc, params, dir, job)
+		aj.finish(job, root, err)
+	}()
+	return aj.get(job)
+}
+
+// StartURL fetches the content at the given HTTP(S) URL and adds it in the
+// background, until done or until the context is cancelled. The content is
+// added as a file named after the last element of the URL path. The bytes
+// fetched so far are reported as uploaded. The job fails when the content is
+// larger than maxSize bytes, when fetching takes longer than AddURLTimeout
+// and, unless allowPrivate is set, when the URL or its redirects point to
+// loopback, private or link-local addresses.
+func (aj *AddJobs) StartURL(
+	ctx context.Context,
+	rpc *rpc.Client,
+	params api.AddParams,
+	u *url.URL,
+	maxSize uint64,
+	allowPrivate bool,
+) (api.AddJob, error) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return api.AddJob{}, errors.New("only http and https URLs can be added")
+	}
+	aj.purge()
+
+	job := aj.newJob(api.AddJobRunning, 0)
+	go func() {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			aj.finish(job, api.CidUndef, err)
+			return
+		}
+		res, err := newURLClient(allowPrivate).Do(req)
+		if err != nil {
+			aj.finish(job, api.CidUndef, err)
+			return
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			aj.finish(job, api.CidUndef, fmt.Errorf("error fetching %s: %s", u, res.Status))
+			return
+		}
+		if res.ContentLength > 0 && uint64(res.ContentLength) > maxSize {
+			aj.finish(job, api.CidUndef, ErrAddTooLarge)
+			return
+		}
+
+		name := path.Base(u.Path)
+		if name == "/" || name == "." {
+			name = "index"
+		}
+		body := &uploadReader{
+			Reader: &sizeLimitReader{
+				Reader: io.LimitReader(res.Body, int64(maxSize)+1),
+				max:    maxSize,
+				read:   new(uint64),
+			},
+			jobs: aj,
+			job:  job,
+		}
+		dir := files.NewMapDirectory(map[string]files.Node{
+			name: files.NewReaderFile(body),
+		})
+		root, err := aj.run(ctx, rpc, params, dir, job)
+		aj.finish(job, root, err)
+	}()
+	return aj.get(job), nil
+}
+
+// newURLClient returns the HTTP client used to fetch URLs to add. Unless
+// allowPrivate is set, it refuses to connect to loopback, private and
+// link-local addresses. This is checked when dialing, once names are
+// resolved, so it also applies to redirects.
+func newURLClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || forbiddenURLAddr(ip) {
+				return fmt.Errorf("%w: %s", ErrAddURLForbidden, host)
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout: AddURLTimeout,
+		Transport: &http.Transport{
+			// No proxies, as the address of the proxy would be
+			// checked instead of the one of the URL.
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: time.Minute,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return errors.New("only redirects to http and https URLs are followed")
+			}
+			return nil
+		},
+	}
+}
+
+// forbiddenURLAddr returns true for the addresses which URLs to add cannot
+// point to.
+func forbiddenURLAddr(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified()
+}
+
+// StartS3 adds the objects under the given prefix of an S3 bucket in the
+// background, until done or until the context is cancelled. The objects are
+// fetched as they are added, and the bytes fetched so far are reported as
+// uploaded. Keys are relative to the prefix and split in directories on
+// "/", and the result is always wrapped in a directory, which is pinned.
+// The job fails when the objects add up to more than maxSize bytes.
+func (aj *AddJobs) StartS3(
+	ctx context.Context,
+	rpc *rpc.Client,
+	params api.AddParams,
+	client *s3.Client,
+	bucket, prefix string,
+	maxSize uint64,
+) (api.AddJob, error) {
+	if bucket == "" {
+		return api.AddJob{}, errors.New("a bucket is needed")
+	}
+	aj.purge()
+
+	params.Wrap = true
+	job := aj.newJob(api.AddJobRunning, 0)
+	go func() {
+		// The limit applies to all the objects together.
+		var fetched uint64
+		wrap := func(r io.Reader) io.Reader {
+			return &uploadReader{
+				Reader: &sizeLimitReader{Reader: r, max: maxSize, read: &fetched},
+				jobs:   aj,
+				job:    job,
+			}
+		}
+		dir, err := client.Directory(ctx, bucket, prefix, wrap)
+		if err != nil {
+			aj.finish(job, api.CidUndef, err)
+			return
+		}
+		root, err := aj.run(ctx, rpc, params, dir, job)
+		aj.finish(job, root, err)
+	}()
+	return aj.get(job), nil
+}
+
+func (aj *AddJobs) newJob(status api.AddJobStatus, uploaded uint64) *api.AddJob {
+	job := &api.AddJob{
+		ID:       uuid.NewString(),
+		Status:   status,
+		Uploaded: uploaded,
+		Started:  time.Now(),
+	}
+	aj.mu.Lock()
+	aj.jobs[job.ID] = job
+	aj.mu.Unlock()
+	return job
+}
+
+func (aj *AddJobs) get(job *api.AddJob) api.AddJob {
+	aj.mu.RLock()
+	defer aj.mu.RUnlock()
+	return *job
+}
+
+// finish records the result of a job.
+func (aj *AddJobs) finish(job *api.AddJob, root api.Cid, err error) {
+	aj.mu.Lock()
+	defer aj.mu.Unlock()
+	job.Finished = time.Now()
+	if err != nil {
+		logger.Errorf("add job %s: %s", job.ID, err)
+		job.Status = api.AddJobError
+		job.Error = err.Error()
+		return
+	}
+	job.Status = api.AddJobDone
+	job.Cid = root
+}
+
+func (aj *AddJobs) run(
+	ctx context.Context,
+	rpc *rpc.Client,
+	params api.AddParams,
+	dir files.Directory,
+	job *api.AddJob,
+) (api.Cid, error) {
+	release, err := acquireBudget(ctx, rpc)
+	if err != nil {
+		return api.CidUndef, err
+	}
+	defer release()
+
+	var dags adder.ClusterDAGService
+	// The output is not needed, as progress is tracked by the DAG
+	// service.
+	output := make(chan api.AddedOutput, 200)
+	go func() {
+		for range output {
+		}
+	}()
+
+	if params.Shard {
+		dags = sharding.New(ctx, rpc, params, output)
+	} else {
+		dags = single.New(ctx, rpc, params, params.Local)
+	}
+	dags = &jobDAGService{ClusterDAGService: dags, jobs: aj, job: job}
+	defer dags.Close()
+
+	add := adder.New(dags, params, output)
+	return add.FromFiles(ctx, dir)
+}
+
+// purge removes the jobs which finished longer than AddJobsRetention ago,
+// and those which have been waiting for their upload for as long.
+func (aj *AddJobs) purge() {
+	aj.mu.Lock()
+	defer aj.mu.Unlock()
+	for id, job := range aj.jobs {
+		if !job.Finished.IsZero() && time.Since(job.Finished) > AddJobsRetention {
+			delete(aj.jobs, id)
+		}
+	}
+	for id := range aj.waiting {
+		if time.Since(aj.jobs[id].Started) > AddJobsRetention {
+			delete(aj.waiting, id)
+			delete(aj.jobs, id)
+		}
+	}
+}
+
+// jobDAGService wraps a ClusterDAGService to record the progress of an add
+// job.
+type jobDAGService struct {
+	adder.ClusterDAGService
+
+	jobs *AddJobs
+	job  *api.AddJob
+}
+
+func (dgs *jobDAGService) Add(ctx context.Context, node ipld.Node) error {
+	if err := dgs.ClusterDAGService.Add(ctx, node); err != nil {
+		return err
+	}
+	dgs.jobs.mu.Lock()
+	dgs.job.Bytes += uint64(len(node.RawData()))
+	dgs.job.Blocks++
+	dgs.job.CurrentCid = api.NewCid(node.Cid())
+	dgs.jobs.mu.Unlock()
+	return nil
+}
+
+func (dgs *jobDAGService) AddMany(ctx context.Context, nodes []ipld.Node) error {
+	for _, node := range nodes {
+		if err := dgs.Add(ctx, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadReader records the bytes read as uploaded for an add job.
+type uploadReader struct {
+	io.Reader
+
+	jobs *AddJobs
+	job  *api.AddJob
+}
+
+func (ur *uploadReader) Read(p []byte) (int, error) {
+	n, err := ur.Reader.Read(p)
+	ur.jobs.mu.Lock()
+	ur.job.Uploaded += uint64(n)
+	ur.jobs.mu.Unlock()
+	return n, err
+}
+
+// sizeLimitReader fails with ErrAddTooLarge after reading more than max
+// bytes. The count of bytes read may be shared by several readers, which
+// are then limited together, as long as they are not read concurrently.
+type sizeLimitReader struct {
+	io.Reader
+
+	max  uint64
+	read *uint64
+}
+
+func (lr *sizeLimitReader) Read(p []byte) (int, error) {
+	n, err := lr.Reader.Read(p)
+	*lr.read += uint64(n)
+	if *lr.read > lr.max {
+		return n, ErrAddTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,254 @@
+package adderutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+
+	"github.com/google/uuid"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+// AddUploadsRetention is how long resumable uploads are kept since they
+// were last written to.
+var AddUploadsRetention = 24 * time.Hour
+
+// ErrAddUploadNotFound is returned when requesting an unknown upload.
+var ErrAddUploadNotFound = errors.New("upload not found")
+
+// ErrAddUploadBusy is returned when an upload is being written to or
+// completed by another request.
+var ErrAddUploadBusy = errors.New("upload is busy")
+
+// ErrAddUploadOffset is returned when writing to an upload at an offset
+// other than its current size.
+var ErrAddUploadOffset = errors.New("offset does not match the upload size")
+
+type addUpload struct {
+	id       string
+	params   api.AddParams
+	boundary string
+	path     string
+	offset   uint64
+	expires  time.Time
+	busy     bool
+}
+
+func (u *addUpload) info() api.AddUpload {
+	return api.AddUpload{
+		ID:      u.id,
+		Offset:  u.offset,
+		Expires: u.expires,
+	}
+}
+
+// AddUploads keeps multipart uploads which are received in several parts
+// in temporary files until they are complete, so that interrupted uploads
+// can be resumed from where they were left. Complete uploads are added like
+// any other.
+type AddUploads struct {
+	jobs *AddJobs
+
+	mu      sync.Mutex
+	uploads map[string]*addUpload
+}
+
+// NewAddUploads returns a new AddUploads which starts asynchronous adds
+// with the given AddJobs.
+func NewAddUploads(jobs *AddJobs) *AddUploads {
+	return &AddUploads{
+		jobs:    jobs,
+		uploads: make(map[string]*addUpload),
+	}
+}
+
+// Create starts an empty upload of a multipart body with the given
+// boundary, which is added with the given parameters once complete.
+func (au *AddUploads) Create(params api.AddParams, boundary string) (api.AddUpload, error) {
+	if boundary == "" {
+		return api.AddUpload{}, errors.New("a multipart boundary is needed")
+	}
+	au.purge()
+
+	f, err := os.CreateTemp("", "ipfs-cluster-upload-")
+	if err != nil {
+		return api.AddUpload{}, err
+	}
+	f.Close()
+
+	u := &addUpload{
+		id:       uuid.NewString(),
+		params:   params,
+		boundary: boundary,
+		path:     f.Name(),
+		expires:  time.Now().Add(AddUploadsRetention),
+	}
+	au.mu.Lock()
+	au.uploads[u.id] = u
+	au.mu.Unlock()
+	return u.info(), nil
+}
+
+// Get returns the current state of the upload with the given ID.
+func (au *AddUploads) Get(id string) (api.AddUpload, error) {
+	au.mu.Lock()
+	defer au.mu.Unlock()
+	u, ok := au.uploads[id]
+	if !ok {
+		return api.AddUpload{}, ErrAddUploadNotFound
+	}
+	return u.info(), nil
+}
+
+// Write appends the body to the upload, provided that the offset is the
+// current size of the upload. When the body cannot be read to the end, the
+// part that was read is kept, and the returned upload tells the offset to
+// resume from.
+func (au *AddUploads) Write(id string, offset uint64, body io.Reader) (api.AddUpload, error) {
+	au.mu.Lock()
+	u, ok := au.uploads[id]
+	switch {
+	case !ok:
+		au.mu.Unlock()
+		return api.AddUpload{}, ErrAddUploadNotFound
+	case u.busy:
+		au.mu.Unlock()
+		return u.info(), ErrAddUploadBusy
+	case u.offset != offset:
+		au.mu.Unlock()
+		return u.info(), fmt.Errorf("%w: %d", ErrAddUploadOffset, u.offset)
+	}
+	u.busy = true
+	au.mu.Unlock()
+
+	n, err := appendFile(u.path, body)
+
+	au.mu.Lock()
+	defer au.mu.Unlock()
+	u.busy = false
+	u.offset += uint64(n)
+	u.expires = time.Now().Add(AddUploadsRetention)
+	return u.info(), err
+}
+
+func appendFile(path string, body io.Reader) (int64, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+// Delete aborts an upload.
+func (au *AddUploads) Delete(id string) error {
+	u, err := au.take(id)
+	if err != nil {
+		return err
+	}
+	return os.Remove(u.path)
+}
+
+// Complete adds a complete upload and writes the output to w, like
+// AddMultipartHTTPHandler does. The upload is removed.
+func (au *AddUploads) Complete(
+	ctx context.Context,
+	rpc *rpc.Client,
+	id string,
+	w http.ResponseWriter,
+	outputTransform func(api.AddedOutput) interface{},
+) (api.Cid, error) {
+	u, err := au.take(id)
+	if err != nil {
+		return api.CidUndef, err
+	}
+	defer os.Remove(u.path)
+
+	f, err := os.Open(u.path)
+	if err != nil {
+		return api.CidUndef, err
+	}
+	defer f.Close()
+	return AddMultipartHTTPHandler(
+		ctx,
+		rpc,
+		u.params,
+		multipart.NewReader(f, u.boundary),
+		w,
+		outputTransform,
+	)
+}
+
+// CompleteAsync starts adding a complete upload in the background, until
+// done or until the context is cancelled, and returns the add job. The
+// upload is removed.
+func (au *AddUploads) CompleteAsync(
+	ctx context.Context,
+	rpc *rpc.Client,
+	id string,
+) (api.AddJob, error) {
+	u, err := au.take(id)
+	if err != nil {
+		return api.AddJob{}, err
+	}
+
+	f, err := os.Open(u.path)
+	if err != nil {
+		os.Remove(u.path)
+		return api.AddJob{}, err
+	}
+	au.jobs.purge()
+	job := au.jobs.newJob(api.AddJobRunning, u.offset)
+	return au.jobs.startFile(ctx, rpc, u.params, f, u.boundary, job), nil
+}
+
+// Params returns the add parameters given when creating the upload.
+func (au *AddUploads) Params(id string) (api.AddParams, error) {
+	au.mu.Lock()
+	defer au.mu.Unlock()
+	u, ok := au.uploads[id]
+	if !ok {
+		return api.AddParams{}, ErrAddUploadNotFound
+	}
+	return u.params, nil
+}
+
+// take removes an upload which is not busy, leaving its file to the
+// caller.
+func (au *AddUploads) take(id string) (*addUpload, error) {
+	au.mu.Lock()
+	defer au.mu.Unlock()
+	u, ok := au.uploads[id]
+	if !ok {
+		return nil, ErrAddUploadNotFound
+	}
+	if u.busy {
+		return nil, ErrAddUploadBusy
+	}
+	delete(au.uploads, id)
+	return u, nil
+}
+
+// purge removes the uploads which have expired.
+func (au *AddUploads) purge() {
+	au.mu.Lock()
+	defer au.mu.Unlock()
+	now := time.Now()
+	for id, u := range au.uploads {
+		if !u.busy && now.After(u.expires) {
+			delete(au.uploads, id)
+			os.Remove(u.path)
+		}
+	}
+}
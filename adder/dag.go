@@ -0,0 +1,115 @@
+package adder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	files "github.com/ipfs/go-ipfs-files"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	multihash "github.com/multiformats/go-multihash"
+)
+
+// maxDagNodeSize is the largest encoded IPLD node that can be added. IPFS
+// does not exchange larger blocks.
+const maxDagNodeSize = 1 << 20
+
+// An adder to add IPLD nodes given in dag-json or dag-cbor, like "ipfs dag
+// put" does: every file is a node, which is stored as a dag-cbor block with
+// a CIDv1. The roots are handled like those of CAR files, so several nodes,
+// or wrapping, result in a directory linking to them.
+type dagAdder struct {
+	*carAdder
+}
+
+func newDagAdder(ctx context.Context, dgs ClusterDAGService, params api.AddParams, out chan api.AddedOutput) (*dagAdder, error) {
+	ca, err := newCarAdder(ctx, dgs, params, out)
+	if err != nil {
+		return nil, err
+	}
+	return &dagAdder{carAdder: ca}, nil
+}
+
+// Add decodes the IPLD node in the given file and adds it as a dag-cbor
+// block using the ClusterDAGService. It returns the CID of the node.
+func (da *dagAdder) Add(name string, fn files.Node) (api.Cid, error) {
+	f, ok := fn.(files.File)
+	if !ok {
+		return api.CidUndef, fmt.Errorf("expected %s node is not of type file", da.params.Format)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(f, maxDagNodeSize+1))
+	if err != nil {
+		return api.CidUndef, err
+	}
+	blk, err := encodeDagNode(data, da.params.Format, da.params.HashFun)
+	if err != nil {
+		return api.CidUndef, err
+	}
+	nd, err := cbor.DecodeBlock(blk)
+	if err != nil {
+		return api.CidUndef, err
+	}
+
+	err = da.dgs.Add(da.ctx, nd)
+	if err != nil {
+		return api.CidUndef, err
+	}
+
+	root := api.NewCid(nd.Cid())
+	da.output <- api.AddedOutput{
+		Name:        name,
+		Cid:         root,
+		Bytes:       uint64(len(blk.RawData())),
+		Allocations: da.dgs.Allocations(),
+	}
+	da.addRoot(nd.Cid())
+	return root, nil
+}
+
+// encodeDagNode decodes an IPLD node with the given codec and returns it as
+// a dag-cbor block, hashed with the given function.
+func encodeDagNode(data []byte, codec, hashFun string) (blocks.Block, error) {
+	if len(data) > maxDagNodeSize {
+		return nil, fmt.Errorf("%s node too large: the limit is %d bytes", codec, maxDagNodeSize)
+	}
+
+	nb := basicnode.Prototype.Any.NewBuilder()
+	var err error
+	switch codec {
+	case "dag-json":
+		err = dagjson.Decode(nb, bytes.NewReader(data))
+	case "dag-cbor":
+		err = dagcbor.Decode(nb, bytes.NewReader(data))
+	default:
+		return nil, errors.New("unknown IPLD codec: " + codec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s node: %w", codec, err)
+	}
+
+	var buf bytes.Buffer
+	if err := dagcbor.Encode(nb.Build(), &buf); err != nil {
+		return nil, err
+	}
+
+	hashFunCode, ok := multihash.Names[strings.ToLower(hashFun)]
+	if !ok {
+		return nil, errors.New("hash function name not known")
+	}
+	mh, err := multihash.Sum(buf.Bytes(), hashFunCode, -1)
+	if err != nil {
+		return nil, err
+	}
+	return blocks.NewBlockWithCid(buf.Bytes(), cid.NewCidV1(cid.DagCBOR, mh))
+}
@@ -374,10 +374,15 @@ func (adder *Adder) addSymlink(path string, l *files.Symlink) error {
 
 func (adder *Adder) addFile(path string, file files.File) error {
 	// if the progress flag was specified, wrap the file so that we can send
-	// progress updates to the client (over the output channel)
+	// progress updates to the client (over the output channel). They are
+	// named like the output for the file.
 	var reader io.Reader = file
 	if adder.Progress {
-		rdr := &progressReader{file: reader, path: path, out: adder.Out}
+		rdr := &progressReader{
+			file: reader,
+			path: filepath.Join(adder.OutputPrefix, path),
+			out:  adder.Out,
+		}
 		if fi, ok := file.(files.FileInfo); ok {
 			reader = &progressReader2{rdr, fi}
 		} else {
@@ -461,14 +466,18 @@ type progressReader struct {
 	out          chan api.AddedOutput
 	bytes        int64
 	lastProgress int64
+	done         bool
 }
 
 func (i *progressReader) Read(p []byte) (int, error) {
 	n, err := i.file.Read(p)
 
 	i.bytes += int64(n)
-	if i.bytes-i.lastProgress >= progressReaderIncrement || err == io.EOF {
+	// The file may be read again after EOF: only send the final
+	// update once.
+	if i.bytes-i.lastProgress >= progressReaderIncrement || (err == io.EOF && !i.done) {
 		i.lastProgress = i.bytes
+		i.done = err == io.EOF
 		i.out <- api.AddedOutput{
 			Name:  i.path,
 			Bytes: uint64(i.bytes),
@@ -0,0 +1,139 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	files "github.com/ipfs/go-ipfs-files"
+)
+
+// Directory lists the objects in the bucket under the given prefix and
+// returns them as a files.Directory, where the keys, relative to the
+// prefix, are split in directories on every "/". A prefix naming a single
+// object results in a directory with that object only. Objects are only
+// fetched when read, with their content passed through wrap when set.
+func (c *Client) Directory(ctx context.Context, bucket, prefix string, wrap func(io.Reader) io.Reader) (files.Directory, error) {
+	objects, err := c.List(ctx, bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no objects found in %s/%s", bucket, prefix)
+	}
+
+	root := make(tree)
+	for _, obj := range objects {
+		rel := strings.TrimPrefix(obj.Key, prefix)
+		if rel == "" {
+			rel = path.Base(obj.Key)
+		}
+		if strings.HasSuffix(rel, "/") { // folder placeholders
+			continue
+		}
+		f := &objectFile{
+			ctx:    ctx,
+			client: c,
+			bucket: bucket,
+			obj:    obj,
+			wrap:   wrap,
+		}
+		if err := root.add(splitKey(rel), f); err != nil {
+			return nil, fmt.Errorf("%s: %w", obj.Key, err)
+		}
+	}
+	return root.directory(), nil
+}
+
+func splitKey(key string) []string {
+	var segments []string
+	for _, s := range strings.Split(key, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// tree holds the objects (*objectFile) and subdirectories (tree) of a
+// directory.
+type tree map[string]interface{}
+
+func (t tree) add(segments []string, f *objectFile) error {
+	name := segments[0]
+	existing, ok := t[name]
+	if len(segments) == 1 {
+		if ok {
+			return errors.New("the key is used both as a file and as a directory")
+		}
+		t[name] = f
+		return nil
+	}
+	if !ok {
+		existing = make(tree)
+		t[name] = existing
+	}
+	sub, ok := existing.(tree)
+	if !ok {
+		return errors.New("the key is used both as a file and as a directory")
+	}
+	return sub.add(segments[1:], f)
+}
+
+func (t tree) directory() files.Directory {
+	nodes := make(map[string]files.Node, len(t))
+	for name, n := range t {
+		switch n := n.(type) {
+		case tree:
+			nodes[name] = n.directory()
+		case *objectFile:
+			nodes[name] = n
+		}
+	}
+	return files.NewMapDirectory(nodes)
+}
+
+// objectFile is a files.File which fetches an object when first read.
+type objectFile struct {
+	ctx    context.Context
+	client *Client
+	bucket string
+	obj    Object
+	wrap   func(io.Reader) io.Reader
+
+	body   io.ReadCloser
+	reader io.Reader
+}
+
+func (f *objectFile) Read(p []byte) (int, error) {
+	if f.reader == nil {
+		body, err := f.client.Get(f.ctx, f.bucket, f.obj.Key)
+		if err != nil {
+			return 0, err
+		}
+		f.body = body
+		f.reader = body
+		if f.wrap != nil {
+			f.reader = f.wrap(body)
+		}
+	}
+	return f.reader.Read(p)
+}
+
+func (f *objectFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, errors.New("objects cannot be seeked")
+}
+
+func (f *objectFile) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	return f.body.Close()
+}
+
+func (f *objectFile) Size() (int64, error) {
+	return f.obj.Size, nil
+}
@@ -0,0 +1,244 @@
+// Package s3 implements a minimal client for S3-compatible object storage,
+// providing the listing and retrieval of objects needed to add them to
+// cluster without downloading them first.
+package s3
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultRegion is used to sign requests when no region is configured.
+const DefaultRegion = "us-east-1"
+
+// emptyHash is the SHA-256 of an empty payload.
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Object describes an object in a bucket.
+type Object struct {
+	Key  string
+	Size int64
+}
+
+// Client sends requests signed with AWS Signature Version 4 to an
+// S3-compatible endpoint. Buckets are addressed in the path of the URLs
+// (path-style), which all S3-compatible services support.
+type Client struct {
+	endpoint  *url.URL
+	region    string
+	accessKey string
+	secretKey string
+
+	httpClient *http.Client
+}
+
+// New returns a Client for the S3 API at the given http(s) endpoint. The
+// requests are anonymous when no access key is given.
+func New(endpoint, region, accessKey, secretKey string) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid S3 endpoint: %s", endpoint)
+	}
+	if region == "" {
+		region = DefaultRegion
+	}
+	return &Client{
+		endpoint:   u,
+		region:     region,
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		httpClient: http.DefaultClient,
+	}, nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key  string
+		Size int64
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+type errorResponse struct {
+	Code    string
+	Message string
+}
+
+// List returns the objects in the bucket whose keys start with the given
+// prefix, sorted by key.
+func (c *Client) List(ctx context.Context, bucket, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		q := url.Values{}
+		q.Set("list-type", "2")
+		q.Set("prefix", prefix)
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		res, err := c.do(ctx, bucket, "", q)
+		if err != nil {
+			return nil, err
+		}
+		var list listBucketResult
+		err = xml.NewDecoder(res.Body).Decode(&list)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding the object list: %w", err)
+		}
+		for _, o := range list.Contents {
+			objects = append(objects, Object{Key: o.Key, Size: o.Size})
+		}
+		if !list.IsTruncated || list.NextContinuationToken == "" {
+			break
+		}
+		token = list.NextContinuationToken
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+	return objects, nil
+}
+
+// Get returns the content of an object. It must be closed when done.
+func (c *Client) Get(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	res, err := c.do(ctx, bucket, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// do sends a signed GET request for an object, or for the bucket when the
+// key is empty, and returns successful responses.
+func (c *Client) do(ctx context.Context, bucket, key string, query url.Values) (*http.Response, error) {
+	if bucket == "" {
+		return nil, errors.New("a bucket is needed")
+	}
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = encodePath(u.Path)
+	u.RawQuery = encodeQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.sign(req, time.Now().UTC())
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusOK {
+		return res, nil
+	}
+	defer res.Body.Close()
+	var eres errorResponse
+	if err := xml.NewDecoder(res.Body).Decode(&eres); err == nil && eres.Code != "" {
+		return nil, fmt.Errorf("S3 error (%s): %s: %s", res.Status, eres.Code, eres.Message)
+	}
+	return nil, fmt.Errorf("S3 error: %s", res.Status)
+}
+
+// sign adds the AWS Signature Version 4 headers to a request without
+// body.
+func (c *Client) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyHash)
+	if c.accessKey == "" {
+		return
+	}
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		encodePath(req.URL.Path),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + emptyHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		emptyHash,
+	}, "\n")
+
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// encodeQuery encodes a query as SigV4 expects: sorted by key and with
+// spaces as %20.
+func encodeQuery(q url.Values) string {
+	return strings.ReplaceAll(q.Encode(), "+", "%20")
+}
+
+// encodePath URI-encodes every segment of a path as SigV4 expects.
+func encodePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = encodeSegment(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func encodeSegment(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch >= 'A' && ch <= 'Z' || ch >= 'a' && ch <= 'z' || ch >= '0' && ch <= '9' ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' {
+			b.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", ch)
+	}
+	return b.String()
+}
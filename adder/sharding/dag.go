@@ -74,28 +74,28 @@ func makeDAGSimple(ctx context.Context, dagObj map[string]cid.Cid) (ipld.Node, e
 
 // makeDAG parses a dagObj which stores all of the node-links a shardDAG
 // is responsible for tracking.  In general a single node of links may exceed
-// the capacity of an ipfs block.  In this case an indirect node in the
-// shardDAG is constructed that references "leaf shardNodes" that themselves
-// carry links to the data nodes being tracked. The head of the output slice
-// is always the root of the shardDAG, i.e. the ipld node that should be
-// recursively pinned to track the shard
-func makeDAG(ctx context.Context, dagObj map[string]cid.Cid) ([]ipld.Node, error) {
+// the capacity of an ipfs block, or the given maximum number of links.  In
+// this case indirect nodes in the shardDAG are constructed that reference
+// "leaf shardNodes" that themselves carry links to the data nodes being
+// tracked. The head of the output slice is always the root of the shardDAG,
+// i.e. the ipld node that should be recursively pinned to track the shard
+func makeDAG(ctx context.Context, dagObj map[string]cid.Cid, maxLinks int) ([]ipld.Node, error) {
 	// FIXME: We have a 4MB limit on the block size enforced by bitswap:
 	// https://github.com/libp2p/go-libp2p/core/blob/master/network/network.go#L23
 
 	// No indirect node
-	if len(dagObj) <= MaxLinks {
+	if len(dagObj) <= maxLinks {
 		n, err := makeDAGSimple(ctx, dagObj)
 		return []ipld.Node{n}, err
 	}
 	// Indirect node required
 	leafNodes := make([]ipld.Node, 0)       // shardNodes with links to data
 	indirectObj := make(map[string]cid.Cid) // shardNode with links to shardNodes
-	numFullLeaves := len(dagObj) / MaxLinks
+	numFullLeaves := len(dagObj) / maxLinks
 	for i := 0; i <= numFullLeaves; i++ {
 		leafObj := make(map[string]cid.Cid)
-		for j := 0; j < MaxLinks; j++ {
-			c, ok := dagObj[fmt.Sprintf("%d", i*MaxLinks+j)]
+		for j := 0; j < maxLinks; j++ {
+			c, ok := dagObj[fmt.Sprintf("%d", i*maxLinks+j)]
 			if !ok { // finished with this leaf before filling all the way
 				if i != numFullLeaves {
 					panic("bad state, should never be here")
@@ -111,14 +111,26 @@ func makeDAG(ctx context.Context, dagObj map[string]cid.Cid) ([]ipld.Node, error
 		indirectObj[fmt.Sprintf("%d", i)] = leafNode.Cid()
 		leafNodes = append(leafNodes, leafNode)
 	}
-	indirectNode, err := makeDAGSimple(ctx, indirectObj)
+	// The indirect node may need indirection itself when the maximum
+	// number of links is low.
+	indirectNodes, err := makeDAG(ctx, indirectObj, maxLinks)
 	if err != nil {
 		return nil, err
 	}
-	nodes := append([]ipld.Node{indirectNode}, leafNodes...)
+	nodes := append(indirectNodes, leafNodes...)
 	return nodes, nil
 }
 
+// dagDepth returns the number of levels of the shardDAG built by makeDAG
+// for the given number of links, which is the depth up to which it must
+// be pinned to pin the data nodes.
+func dagDepth(links, maxLinks int) int {
+	if links <= maxLinks {
+		return 1
+	}
+	return 1 + dagDepth(links/maxLinks+1, maxLinks)
+}
+
 // TODO: decide whether this is worth including. Is precision important for
 // most usecases?  Is being a little over the shard size a serious problem?
 // Is precision worth the cost to maintain complex accounting for metadata
@@ -7,6 +7,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 
 	"time"
 
@@ -23,6 +24,13 @@ import (
 
 var logger = logging.Logger("shardingdags")
 
+// Metadata keys set in ClusterDAG pins to record the parameters used to shard
+// the content.
+const (
+	MetadataMaxLinks = "sharding-max-links"
+	MetadataLayout   = "sharding-layout"
+)
+
 // DAGService is an implementation of a ClusterDAGService which
 // shards content while adding among several IPFS Cluster peers,
 // creating a Cluster DAG to track and pin that content selectively
@@ -34,6 +42,7 @@ type DAGService struct {
 	rpcClient *rpc.Client
 
 	addParams api.AddParams
+	maxLinks  int
 	output    chan<- api.AddedOutput
 
 	addedSet *cid.Set
@@ -55,10 +64,15 @@ type DAGService struct {
 func New(ctx context.Context, rpc *rpc.Client, opts api.AddParams, out chan<- api.AddedOutput) *DAGService {
 	// use a default value for this regardless of what is provided.
 	opts.Mode = api.PinModeRecursive
+	maxLinks := opts.ShardMaxLinks
+	if maxLinks == 0 {
+		maxLinks = MaxLinks
+	}
 	return &DAGService{
 		ctx:       ctx,
 		rpcClient: rpc,
 		addParams: opts,
+		maxLinks:  maxLinks,
 		output:    out,
 		addedSet:  cid.NewSet(),
 		shards:    make(map[string]cid.Cid),
@@ -98,7 +112,9 @@ func (dgs *DAGService) Finalize(ctx context.Context, dataRoot api.Cid) (api.Cid,
 		logger.Warnf("the last added CID (%s) is not the IPFS data root (%s). This is only normal when adding a single file without wrapping in directory.", lastCid, dataRoot)
 	}
 
-	clusterDAGNodes, err := makeDAG(ctx, dgs.shards)
+	// The ClusterDAG is read as a single node listing the shards, so it
+	// is not limited by ShardMaxLinks.
+	clusterDAGNodes, err := makeDAG(ctx, dgs.shards, MaxLinks)
 	if err != nil {
 		return dataRoot, err
 	}
@@ -144,6 +160,7 @@ func (dgs *DAGService) Finalize(ctx context.Context, dataRoot api.Cid) (api.Cid,
 	clusterDAGPin.ReplicationFactorMax = -1
 	clusterDAGPin.MaxDepth = 0 // pin direct
 	clusterDAGPin.Name = fmt.Sprintf("%s-clusterDAG", dgs.addParams.Name)
+	clusterDAGPin.Metadata = dgs.clusterDAGMetadata()
 	clusterDAGPin.Type = api.ClusterDAGType
 	clusterDAGPin.Reference = &dataRoot
 	// Update object with response.
@@ -187,6 +204,23 @@ func (dgs *DAGService) Finalize(ctx context.Context, dataRoot api.Cid) (api.Cid,
 	return dataRoot, nil
 }
 
+// clusterDAGMetadata returns the metadata of the pin, along with the
+// sharding parameters, so that the content can be sharded in the same way
+// later. The shard size is kept in the ShardSize of the pin.
+func (dgs *DAGService) clusterDAGMetadata() map[string]string {
+	meta := make(map[string]string, len(dgs.addParams.Metadata)+2)
+	for k, v := range dgs.addParams.Metadata {
+		meta[k] = v
+	}
+	meta[MetadataMaxLinks] = strconv.Itoa(dgs.maxLinks)
+	layout := dgs.addParams.Layout
+	if layout == "" {
+		layout = "balanced"
+	}
+	meta[MetadataLayout] = layout
+	return meta
+}
+
 // Allocations returns the current allocations for the current shard.
 func (dgs *DAGService) Allocations() []peer.ID {
 	// FIXME: this is probably not safe in concurrency?  However, there is
@@ -204,10 +238,13 @@ func (dgs *DAGService) ingestBlock(ctx context.Context, n ipld.Node) error {
 
 	// if we have no currentShard, create one
 	if shard == nil {
+		if dgs.maxLinks < 2 || dgs.maxLinks > MaxLinks {
+			return fmt.Errorf("shard max links must be between 2 and %d", MaxLinks)
+		}
 		logger.Infof("new shard for '%s': #%d", dgs.addParams.Name, len(dgs.shards))
 		var err error
 		// important: shards use the DAGService context.
-		shard, err = newShard(dgs.ctx, ctx, dgs.rpcClient, dgs.addParams.PinOptions)
+		shard, err = newShard(dgs.ctx, ctx, dgs.rpcClient, dgs.addParams.PinOptions, dgs.maxLinks)
 		if err != nil {
 			return err
 		}
@@ -3,6 +3,7 @@ package sharding
 import (
 	"context"
 	"errors"
+	"fmt"
 	"mime/multipart"
 	"sync"
 	"testing"
@@ -11,6 +12,7 @@ import (
 	"github.com/ipfs-cluster/ipfs-cluster/api"
 	"github.com/ipfs-cluster/ipfs-cluster/test"
 
+	cid "github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
 	peer "github.com/libp2p/go-libp2p/core/peer"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
@@ -189,6 +191,89 @@ func TestFromMultipart(t *testing.T) {
 
 }
 
+func TestFromMultipartMaxLinks(t *testing.T) {
+	sth := test.NewShardingTestHelper()
+	defer sth.Clean(t)
+
+	p := api.DefaultAddParams()
+	p.ShardSize = 1024 * 300 // 300kB
+	p.ShardMaxLinks = 3
+	p.Layout = "balanced"
+	p.Name = "testingFile"
+	p.Shard = true
+	p.ReplicationFactorMin = 1
+	p.ReplicationFactorMax = 2
+
+	add, rpcObj := makeAdder(t, p)
+
+	mr, closer := sth.GetTreeMultiReader(t)
+	defer closer.Close()
+	r := multipart.NewReader(mr, mr.Boundary())
+
+	rootCid, err := add.FromMultipart(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rootCid.String() != test.ShardingDirBalancedRootCID {
+		t.Fatal("bad root CID")
+	}
+
+	// Same shards as with the default max links, with indirect shard
+	// nodes.
+	shardBlocks, err := VerifyShards(t, rootCid, rpcObj, rpcObj, 14)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(test.ShardingDirCids) != len(shardBlocks) {
+		t.Fatal("shards have some missing or extra blocks")
+	}
+
+	metaPin, err := rpcObj.PinGet(context.Background(), rootCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusterPin, err := rpcObj.PinGet(context.Background(), *metaPin.Reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clusterPin.Metadata[MetadataMaxLinks] != "3" ||
+		clusterPin.Metadata[MetadataLayout] != "balanced" ||
+		clusterPin.ShardSize != p.ShardSize {
+		t.Error("expected the sharding parameters to be recorded in the ClusterDAG pin")
+	}
+}
+
+func TestDAGDepth(t *testing.T) {
+	for _, tc := range []struct {
+		links, maxLinks, depth int
+	}{
+		{1, MaxLinks, 1},
+		{MaxLinks, MaxLinks, 1},
+		{MaxLinks + 1, MaxLinks, 2},
+		{3, 3, 1},
+		{4, 3, 2},
+		{9, 3, 3},
+		{27, 3, 4},
+	} {
+		obj := make(map[string]cid.Cid)
+		for i := 0; i < tc.links; i++ {
+			obj[fmt.Sprintf("%d", i)] = test.Cid1.Cid
+		}
+		nodes, err := makeDAG(context.Background(), obj, tc.maxLinks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := dagDepth(tc.links, tc.maxLinks); d != tc.depth {
+			t.Errorf("%d links, %d max: expected depth %d, got %d", tc.links, tc.maxLinks, tc.depth, d)
+		}
+		for _, n := range nodes {
+			if len(n.Links()) > tc.maxLinks {
+				t.Errorf("%d links, %d max: node with %d links", tc.links, tc.maxLinks, len(n.Links()))
+			}
+		}
+	}
+}
+
 func TestFromMultipart_Errors(t *testing.T) {
 	type testcase struct {
 		name   string
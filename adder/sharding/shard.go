@@ -30,11 +30,12 @@ type shard struct {
 	// dagNode represents a node with links and will be converted
 	// to Cbor.
 	dagNode     map[string]cid.Cid
+	maxLinks    int
 	currentSize uint64
 	sizeLimit   uint64
 }
 
-func newShard(globalCtx context.Context, ctx context.Context, rpc *rpc.Client, opts api.PinOptions) (*shard, error) {
+func newShard(globalCtx context.Context, ctx context.Context, rpc *rpc.Client, opts api.PinOptions, maxLinks int) (*shard, error) {
 	allocs, err := adder.BlockAllocate(ctx, rpc, opts)
 	if err != nil {
 		return nil, err
@@ -62,6 +63,7 @@ func newShard(globalCtx context.Context, ctx context.Context, rpc *rpc.Client, o
 		bs:          adder.NewBlockStreamer(globalCtx, rpc, allocs, blocks),
 		blocks:      blocks,
 		dagNode:     make(map[string]cid.Cid),
+		maxLinks:    maxLinks,
 		currentSize: 0,
 		sizeLimit:   opts.ShardSize,
 	}, nil
@@ -108,7 +110,7 @@ func (sh *shard) Close() error {
 // shard.
 func (sh *shard) Flush(ctx context.Context, shardN int, prev cid.Cid) (cid.Cid, error) {
 	logger.Debugf("shard %d: flush", shardN)
-	nodes, err := makeDAG(ctx, sh.dagNode)
+	nodes, err := makeDAG(ctx, sh.dagNode, sh.maxLinks)
 	if err != nil {
 		return cid.Undef, err
 	}
@@ -141,11 +143,9 @@ func (sh *shard) Flush(ctx context.Context, shardN int, prev cid.Cid) (cid.Cid,
 	pin.Type = api.ShardType
 	ref := api.NewCid(prev)
 	pin.Reference = &ref
-	pin.MaxDepth = 1
-	pin.ShardSize = sh.Size()           // use current size, not the limit
-	if len(nodes) > len(sh.dagNode)+1 { // using an indirect graph
-		pin.MaxDepth = 2
-	}
+	// deeper than 1 when using an indirect graph
+	pin.MaxDepth = api.PinDepth(dagDepth(len(sh.dagNode), sh.maxLinks))
+	pin.ShardSize = sh.Size() // use current size, not the limit
 
 	logger.Infof("shard #%d (%s) completed. Total size: %s. Links: %d",
 		shardN,
@@ -7,6 +7,8 @@ import (
 	"testing"
 
 	"github.com/ipfs-cluster/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
 )
 
 // MockPinStore is used in VerifyShards
@@ -85,16 +87,12 @@ func VerifyShards(t *testing.T, rootCid api.Cid, pins MockPinStore, ipfs MockBlo
 		}
 		ref = shardPin.Cid
 
-		shardBlock, err := ipfs.BlockGet(ctx, shardPin.Cid)
-		if err != nil {
-			return nil, fmt.Errorf("shard block was not stored: %s", err)
-		}
-		shardNode, err := CborDataToNode(shardBlock, "cbor")
+		links, err := shardLinks(ctx, ipfs, shardPin.Cid, int(shardPin.MaxDepth))
 		if err != nil {
 			return nil, err
 		}
-		for _, l := range shardNode.Links() {
-			ci := l.Cid.String()
+		for _, l := range links {
+			ci := l.String()
 			_, ok := shardBlocks[ci]
 			if ok {
 				return nil, fmt.Errorf("block belongs to two shards: %s", ci)
@@ -104,3 +102,30 @@ func VerifyShards(t *testing.T, rootCid api.Cid, pins MockPinStore, ipfs MockBlo
 	}
 	return shardBlocks, nil
 }
+
+// shardLinks returns the data blocks linked from a shard node, following
+// the indirect nodes of shards deeper than 1.
+func shardLinks(ctx context.Context, ipfs MockBlockStore, c api.Cid, depth int) ([]cid.Cid, error) {
+	shardBlock, err := ipfs.BlockGet(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("shard block was not stored: %s", err)
+	}
+	shardNode, err := CborDataToNode(shardBlock, "cbor")
+	if err != nil {
+		return nil, err
+	}
+
+	var links []cid.Cid
+	for _, l := range shardNode.Links() {
+		if depth <= 1 {
+			links = append(links, l.Cid)
+			continue
+		}
+		sub, err := shardLinks(ctx, ipfs, api.NewCid(l.Cid), depth-1)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, sub...)
+	}
+	return links, nil
+}
@@ -67,7 +67,15 @@ func (dgs *DAGService) Add(ctx context.Context, node ipld.Node) error {
 	// is the adder only allocates and starts streaming when the first
 	// block arrives and not on creation.
 	if dgs.dests == nil {
-		dests, err := adder.BlockAllocate(ctx, dgs.rpcClient, dgs.addParams.PinOptions)
+		opts := dgs.addParams.PinOptions
+		if opts.PinUpdate.Defined() {
+			var err error
+			opts, err = adder.PinUpdateOptions(ctx, dgs.rpcClient, opts)
+			if err != nil {
+				return err
+			}
+		}
+		dests, err := adder.BlockAllocate(ctx, dgs.rpcClient, opts)
 		if err != nil {
 			return err
 		}
@@ -119,7 +127,8 @@ func (dgs *DAGService) Close() error {
 	return nil
 }
 
-// Finalize pins the last Cid added to this DAGService.
+// Finalize pins the last Cid added to this DAGService and, when requested,
+// verifies that the allocations store it.
 func (dgs *DAGService) Finalize(ctx context.Context, root api.Cid) (api.Cid, error) {
 	// Close the blocks channel
 	dgs.Close()
@@ -150,7 +159,13 @@ func (dgs *DAGService) Finalize(ctx context.Context, root api.Cid) (api.Cid, err
 	rootPin := api.PinWithOpts(root, dgs.addParams.PinOptions)
 	rootPin.Allocations = dgs.dests
 
-	return root, adder.Pin(ctx, dgs.rpcClient, rootPin)
+	if err := adder.Pin(ctx, dgs.rpcClient, rootPin); err != nil {
+		return root, err
+	}
+	if dgs.addParams.Verify {
+		return root, adder.Verify(ctx, dgs.rpcClient, root)
+	}
+	return root, nil
 }
 
 // Allocations returns the add destinations decided by the DAGService.
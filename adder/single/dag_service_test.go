@@ -21,6 +21,9 @@ type testIPFSRPC struct {
 
 type testClusterRPC struct {
 	pins sync.Map
+
+	// blocks reported missing when verifying pins
+	missing []api.Cid
 }
 
 func (rpcs *testIPFSRPC) BlockStream(ctx context.Context, in <-chan api.NodeWithMeta, out chan<- struct{}) error {
@@ -37,7 +40,33 @@ func (rpcs *testClusterRPC) Pin(ctx context.Context, in api.Pin, out *api.Pin) e
 	return nil
 }
 
+func (rpcs *testClusterRPC) PinGet(ctx context.Context, in api.Cid, out *api.Pin) error {
+	p, ok := rpcs.pins.Load(in.String())
+	if !ok {
+		return errors.New("not found")
+	}
+	*out = p.(api.Pin)
+	return nil
+}
+
+func (rpcs *testClusterRPC) VerifyPin(ctx context.Context, in api.PinVerifyRequest, out *api.PinVerification) error {
+	if _, ok := rpcs.pins.Load(in.Cid.String()); !ok {
+		return errors.New("not found")
+	}
+	*out = api.PinVerification{
+		Cid: in.Cid,
+		Peers: []api.PeerVerification{
+			{Peer: test.PeerID1, Missing: rpcs.missing},
+		},
+	}
+	return nil
+}
+
 func (rpcs *testClusterRPC) BlockAllocate(ctx context.Context, in api.Pin, out *[]peer.ID) error {
+	if len(in.UserAllocations) > 0 {
+		*out = in.UserAllocations
+		return nil
+	}
 	if in.ReplicationFactorMin > 1 {
 		return errors.New("we can only replicate to 1 peer")
 	}
@@ -136,3 +165,101 @@ func TestAdd(t *testing.T) {
 		}
 	})
 }
+
+func TestAddPinUpdate(t *testing.T) {
+	clusterRPC := &testClusterRPC{}
+	ipfsRPC := &testIPFSRPC{}
+	server := rpc.NewServer(nil, "mock")
+	err := server.RegisterName("Cluster", clusterRPC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.RegisterName("IPFSConnector", ipfsRPC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.NewClientWithServer(nil, "mock", server)
+
+	old := api.PinCid(test.Cid1)
+	old.ReplicationFactorMin = 1
+	old.ReplicationFactorMax = 1
+	old.Allocations = []peer.ID{test.PeerID2}
+	clusterRPC.pins.Store(test.Cid1.String(), old)
+
+	params := api.DefaultAddParams()
+	params.PinUpdate = test.Cid1
+
+	dags := New(context.Background(), client, params, false)
+	add := adder.New(dags, params, nil)
+
+	sth := test.NewShardingTestHelper()
+	defer sth.Clean(t)
+	mr, closer := sth.GetTreeMultiReader(t)
+	defer closer.Close()
+	r := multipart.NewReader(mr, mr.Boundary())
+
+	rootCid, err := add.FromMultipart(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, ok := clusterRPC.pins.Load(rootCid.String())
+	if !ok {
+		t.Fatal("the tree wasn't pinned")
+	}
+	pin := p.(api.Pin)
+	if !pin.PinUpdate.Equals(test.Cid1) {
+		t.Error("expected the tree to be pinned as an update")
+	}
+	if len(pin.Allocations) != 1 || pin.Allocations[0] != test.PeerID2 {
+		t.Error("expected the allocations of the updated pin to be used")
+	}
+
+	params.PinUpdate = test.Cid2
+	dags = New(context.Background(), client, params, false)
+	add = adder.New(dags, params, nil)
+	mr, closer = sth.GetTreeMultiReader(t)
+	defer closer.Close()
+	_, err = add.FromMultipart(context.Background(), multipart.NewReader(mr, mr.Boundary()))
+	if err == nil {
+		t.Error("expected an error when the updated pin does not exist")
+	}
+}
+
+func TestAddVerify(t *testing.T) {
+	clusterRPC := &testClusterRPC{}
+	ipfsRPC := &testIPFSRPC{}
+	server := rpc.NewServer(nil, "mock")
+	err := server.RegisterName("Cluster", clusterRPC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.RegisterName("IPFSConnector", ipfsRPC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.NewClientWithServer(nil, "mock", server)
+
+	params := api.DefaultAddParams()
+	params.Verify = true
+
+	sth := test.NewShardingTestHelper()
+	defer sth.Clean(t)
+
+	add := func() error {
+		dags := New(context.Background(), client, params, false)
+		mr, closer := sth.GetTreeMultiReader(t)
+		defer closer.Close()
+		_, err := adder.New(dags, params, nil).FromMultipart(context.Background(), multipart.NewReader(mr, mr.Boundary()))
+		return err
+	}
+
+	if err := add(); err != nil {
+		t.Fatal(err)
+	}
+
+	clusterRPC.missing = []api.Cid{test.Cid1}
+	if err := add(); err == nil {
+		t.Error("expected an error when blocks are missing after adding")
+	}
+}
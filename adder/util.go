@@ -3,6 +3,7 @@ package adder
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/ipfs-cluster/ipfs-cluster/api"
@@ -18,6 +19,11 @@ import (
 // block fails on all of them.
 var ErrBlockAdder = errors.New("failed to put block on all destinations")
 
+// BlockStreamBuffer is the number of blocks queued for every destination of
+// a BlockStreamer. Destinations are streamed to concurrently, so a slow
+// destination only holds back the rest once it is that many blocks behind.
+var BlockStreamBuffer = 64
+
 // BlockStreamer helps streaming nodes to multiple destinations, as long as
 // one of them is still working.
 type BlockStreamer struct {
@@ -72,22 +78,72 @@ func (bs *BlockStreamer) Err() error {
 func (bs *BlockStreamer) streamBlocks() {
 	defer bs.cancel()
 
-	// Nothing should be sent on out.
-	// We drain though
-	out := make(chan struct{})
-	go func() {
-		for range out {
+	n := len(bs.dests)
+	queues := make([]chan api.NodeWithMeta, n)
+	done := make([]chan struct{}, n)
+	errs := make([]error, n)
+
+	// Stream to every destination concurrently, each with its own
+	// queue.
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := range bs.dests {
+		queues[i] = make(chan api.NodeWithMeta, BlockStreamBuffer)
+		done[i] = make(chan struct{})
+		go func(i int) {
+			defer wg.Done()
+			defer close(done[i])
+
+			// Nothing should be sent on out.
+			// We drain though
+			out := make(chan struct{})
+			go func() {
+				for range out {
+				}
+			}()
+
+			errs[i] = bs.rpcClient.Stream(
+				bs.ctx,
+				bs.dests[i],
+				"IPFSConnector",
+				"BlockStream",
+				queues[i],
+				out,
+			)
+		}(i)
+	}
+
+	// Queue every block for every destination which is still working.
+	// This only blocks when the queue of a destination is full.
+	active := n
+	for blk := range bs.blocks {
+		for i, q := range queues {
+			if q == nil {
+				continue
+			}
+			select {
+			case q <- blk:
+			case <-done[i]:
+				close(q)
+				queues[i] = nil
+				active--
+			}
 		}
-	}()
-
-	errs := bs.rpcClient.MultiStream(
-		bs.ctx,
-		bs.dests,
-		"IPFSConnector",
-		"BlockStream",
-		bs.blocks,
-		out,
-	)
+		if active == 0 {
+			// Let the producer finish.
+			go func() {
+				for range bs.blocks {
+				}
+			}()
+			break
+		}
+	}
+	for _, q := range queues {
+		if q != nil {
+			close(q)
+		}
+	}
+	wg.Wait()
 
 	combinedErrors := multierr.Combine(errs...)
 
@@ -130,6 +186,29 @@ func BlockAllocate(ctx context.Context, rpc *rpc.Client, pinOpts api.PinOptions)
 	return allocsStr, err
 }
 
+// PinUpdateOptions returns the given options with the replication factors
+// and allocations of the pin being updated (opts.PinUpdate), so that the
+// blocks of the new DAG are sent to the peers pinning the previous one,
+// which likely hold most of them already.
+func PinUpdateOptions(ctx context.Context, rpc *rpc.Client, opts api.PinOptions) (api.PinOptions, error) {
+	var existing api.Pin
+	err := rpc.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"PinGet",
+		opts.PinUpdate,
+		&existing,
+	)
+	if err != nil {
+		return opts, fmt.Errorf("error getting the pin to update: %w", err)
+	}
+	opts.ReplicationFactorMin = existing.ReplicationFactorMin
+	opts.ReplicationFactorMax = existing.ReplicationFactorMax
+	opts.UserAllocations = existing.Allocations
+	return opts, nil
+}
+
 // Pin helps sending local RPC pin requests.
 func Pin(ctx context.Context, rpc *rpc.Client, pin api.Pin) error {
 	if pin.ReplicationFactorMin < 0 {
@@ -147,6 +226,33 @@ func Pin(ctx context.Context, rpc *rpc.Client, pin api.Pin) error {
 	)
 }
 
+// Verify checks that the peers which a pin is allocated to store all the
+// blocks of its DAG and returns an error describing the peers where blocks
+// are missing otherwise.
+func Verify(ctx context.Context, rpc *rpc.Client, c api.Cid) error {
+	var res api.PinVerification
+	err := rpc.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"VerifyPin",
+		api.PinVerifyRequest{Cid: c},
+		&res,
+	)
+	if err != nil {
+		return fmt.Errorf("error verifying %s: %w", c, err)
+	}
+	for _, pv := range res.Peers {
+		switch {
+		case pv.Error != "":
+			err = multierr.Append(err, fmt.Errorf("%s could not be verified on %s: %s", c, pv.Peer, pv.Error))
+		case len(pv.Missing) > 0:
+			err = multierr.Append(err, fmt.Errorf("%s is missing %d blocks on %s", c, len(pv.Missing), pv.Peer))
+		}
+	}
+	return err
+}
+
 // ErrDAGNotFound is returned whenever we try to get a block from the DAGService.
 var ErrDAGNotFound = errors.New("dagservice: a Get operation was attempted while cluster-adding (this is likely a bug)")
 
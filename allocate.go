@@ -2,7 +2,6 @@ package ipfscluster
 
 import (
 	"context"
-	"errors"
 	"fmt"
 
 	peer "github.com/libp2p/go-libp2p/core/peer"
@@ -24,7 +23,8 @@ import (
 //   monitor component
 // * Divide the metrics between "current" (peers already pinning the CID)
 //   and "candidates" (peers that could pin the CID), as long as their metrics
-//   are valid.
+//   are valid. Passive peers are left out, and so are peers which have
+//   reached their quota, unless they are already allocated.
 // * Given the candidates:
 //   * Check if we are overpinning an item
 //   * Check if there are not enough candidates for the "needed" replication
@@ -119,7 +119,9 @@ func (c *Cluster) allocate(ctx context.Context, hash api.Cid, currentPin api.Pin
 // - Those corresponding to "candidate" allocations
 // And return also an slice of the peers in those groups.
 //
-// Peers from untrusted peers are left out if configured.
+// Peers from untrusted peers are left out if configured. Passive peers, which
+// announce it in their ping metrics, are always left out. Peers which have
+// reached their quota are only kept when they are current allocations.
 //
 // For a metric/peer to be included in a group, it is necessary that it has
 // metrics for all informers.
@@ -127,6 +129,8 @@ func (c *Cluster) filterMetrics(ctx context.Context, mSet api.MetricsSet, numMet
 	curPeersMap := make(map[peer.ID][]api.Metric)
 	candPeersMap := make(map[peer.ID][]api.Metric)
 	prioPeersMap := make(map[peer.ID][]api.Metric)
+	passive := c.passivePeers(ctx)
+	overQuota := c.overQuotaPeers(ctx)
 
 	// Divide the metric by current/candidate/prio and by peer
 	for _, metrics := range mSet {
@@ -135,12 +139,19 @@ func (c *Cluster) filterMetrics(ctx context.Context, mSet api.MetricsSet, numMet
 			case containsPeer(blacklist, m.Peer):
 				// discard blacklisted peers
 				continue
+			case passive[m.Peer]:
+				// discard peers that never pin content
+				continue
 			case c.config.PinOnlyOnTrustedPeers && !c.consensus.IsTrustedPeer(ctx, m.Peer):
 				// discard peer that are not trusted when
 				// configured.
 				continue
 			case containsPeer(currentAllocs, m.Peer):
 				curPeersMap[m.Peer] = append(curPeersMap[m.Peer], m)
+			case overQuota[m.Peer]:
+				// discard new allocations to peers without
+				// space left.
+				continue
 			case containsPeer(priorityList, m.Peer):
 				prioPeersMap[m.Peer] = append(prioPeersMap[m.Peer], m)
 			default:
@@ -183,6 +194,21 @@ func (c *Cluster) filterMetrics(ctx context.Context, mSet api.MetricsSet, numMet
 	}
 }
 
+// passivePeers returns the peers that are in PassiveMode, according to their
+// latest ping metrics.
+func (c *Cluster) passivePeers(ctx context.Context) map[peer.ID]bool {
+	passive := make(map[peer.ID]bool)
+	if c.config.PassiveMode {
+		passive[c.id] = true
+	}
+	for _, m := range c.monitor.LatestMetrics(ctx, pingMetricName) {
+		if pingValueFromMetric(m).Passive {
+			passive[m.Peer] = true
+		}
+	}
+	return passive
+}
+
 // allocationError logs an allocation error
 func allocationError(hash api.Cid, needed, wanted int, candidatesValid []peer.ID) error {
 	logger.Errorf("Not enough candidates to allocate %s:", hash)
@@ -192,12 +218,11 @@ func allocationError(hash api.Cid, needed, wanted int, candidatesValid []peer.ID
 	for _, c := range candidatesValid {
 		logger.Errorf("    - %s", c.Pretty())
 	}
-	errorMsg := "not enough peers to allocate CID. "
-	errorMsg += fmt.Sprintf("Needed at least: %d. ", needed)
+	errorMsg := fmt.Sprintf("Needed at least: %d. ", needed)
 	errorMsg += fmt.Sprintf("Wanted at most: %d. ", wanted)
 	errorMsg += fmt.Sprintf("Available candidates: %d. ", len(candidatesValid))
 	errorMsg += "See logs for more info."
-	return errors.New(errorMsg)
+	return fmt.Errorf("%w. %s", api.ErrInsufficientAllocations, errorMsg)
 }
 
 func (c *Cluster) obtainAllocations(
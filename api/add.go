@@ -1,12 +1,14 @@
 package api
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"net/url"
 	"strconv"
+	"time"
 
-	cid "github.com/ipfs/go-cid"
+	chunk "github.com/ipfs/go-ipfs-chunker"
 	peer "github.com/libp2p/go-libp2p/core/peer"
 )
 
@@ -45,9 +47,12 @@ type AddParams struct {
 	Hidden         bool
 	Wrap           bool
 	Shard          bool
+	ShardMaxLinks  int // links per shard/ClusterDAG node (0: as many as fit)
 	StreamChannels bool
-	Format         string // selects with adder
+	Format         string // selects with adder: unixfs, car, dag-json or dag-cbor
 	NoPin          bool
+	Async          bool
+	Verify         bool // check the allocations store the DAG once pinned
 
 	IPFSAddParams
 }
@@ -66,6 +71,7 @@ func DefaultAddParams() AddParams {
 
 		Format: "unixfs",
 		NoPin:  false,
+		Async:  false,
 		PinOptions: PinOptions{
 			ReplicationFactorMin: 0,
 			ReplicationFactorMax: 0,
@@ -120,7 +126,6 @@ func AddParamsFromQuery(query url.Values) (AddParams, error) {
 		return params, err
 	}
 	params.PinOptions = *opts
-	params.PinUpdate.Cid = cid.Undef // hardcode as does not make sense for adding
 
 	layout := query.Get("layout")
 	switch layout {
@@ -131,8 +136,13 @@ func AddParamsFromQuery(query url.Values) (AddParams, error) {
 	}
 	params.Layout = layout
 
+	// Same chunkers as "ipfs add": size-<size>, rabin,
+	// rabin-<min>-<avg>-<max> and buzhash.
 	chunker := query.Get("chunker")
 	if chunker != "" {
+		if _, err := chunk.FromString(bytes.NewReader(nil), chunker); err != nil {
+			return params, fmt.Errorf("chunker parameter is invalid: %w", err)
+		}
 		params.Chunker = chunker
 	}
 
@@ -143,7 +153,7 @@ func AddParamsFromQuery(query url.Values) (AddParams, error) {
 
 	format := query.Get("format")
 	switch format {
-	case "car", "unixfs", "":
+	case "car", "unixfs", "dag-json", "dag-cbor", "":
 	default:
 		return params, errors.New("format parameter is invalid")
 	}
@@ -172,6 +182,14 @@ func AddParamsFromQuery(query url.Values) (AddParams, error) {
 		return params, err
 	}
 
+	err = parseIntParam(query, "shard-max-links", &params.ShardMaxLinks)
+	if err != nil {
+		return params, err
+	}
+	if params.ShardMaxLinks < 0 {
+		return params, errors.New("shard-max-links parameter is invalid")
+	}
+
 	err = parseBoolParam(query, "progress", &params.Progress)
 	if err != nil {
 		return params, err
@@ -210,6 +228,25 @@ func AddParamsFromQuery(query url.Values) (AddParams, error) {
 		return params, err
 	}
 
+	err = parseBoolParam(query, "async", &params.Async)
+	if err != nil {
+		return params, err
+	}
+
+	err = parseBoolParam(query, "verify", &params.Verify)
+	if err != nil {
+		return params, err
+	}
+	if params.Verify && (params.Shard || params.NoPin) {
+		return params, errors.New("verify cannot be used with sharding or no-pin")
+	}
+
+	// Sharded DAGs are pinned with a meta pin, which cannot be
+	// updated.
+	if params.Shard && params.PinUpdate.Defined() {
+		return params, errors.New("pin-update cannot be used with sharding")
+	}
+
 	return params, nil
 }
 
@@ -224,6 +261,7 @@ func (p AddParams) ToQueryString() (string, error) {
 		return "", err
 	}
 	query.Set("shard", fmt.Sprintf("%t", p.Shard))
+	query.Set("shard-max-links", fmt.Sprintf("%d", p.ShardMaxLinks))
 	query.Set("local", fmt.Sprintf("%t", p.Local))
 	query.Set("recursive", fmt.Sprintf("%t", p.Recursive))
 	query.Set("layout", p.Layout)
@@ -238,6 +276,8 @@ func (p AddParams) ToQueryString() (string, error) {
 	query.Set("nocopy", fmt.Sprintf("%t", p.NoCopy))
 	query.Set("format", p.Format)
 	query.Set("no-pin", fmt.Sprintf("%t", p.NoPin))
+	query.Set("async", fmt.Sprintf("%t", p.Async))
+	query.Set("verify", fmt.Sprintf("%t", p.Verify))
 	return query.Encode(), nil
 }
 
@@ -247,6 +287,7 @@ func (p AddParams) Equals(p2 AddParams) bool {
 		p.Local == p2.Local &&
 		p.Recursive == p2.Recursive &&
 		p.Shard == p2.Shard &&
+		p.ShardMaxLinks == p2.ShardMaxLinks &&
 		p.Layout == p2.Layout &&
 		p.Chunker == p2.Chunker &&
 		p.RawLeaves == p2.RawLeaves &&
@@ -257,5 +298,42 @@ func (p AddParams) Equals(p2 AddParams) bool {
 		p.StreamChannels == p2.StreamChannels &&
 		p.NoCopy == p2.NoCopy &&
 		p.Format == p2.Format &&
-		p.NoPin == p2.NoPin
+		p.NoPin == p2.NoPin &&
+		p.Async == p2.Async &&
+		p.Verify == p2.Verify
+}
+
+// AddJobStatus is the status of an asynchronous add.
+type AddJobStatus string
+
+// AddJobStatus values.
+const (
+	AddJobUploading AddJobStatus = "uploading"
+	AddJobRunning   AddJobStatus = "running"
+	AddJobDone      AddJobStatus = "done"
+	AddJobError     AddJobStatus = "error"
+)
+
+// AddJob describes the progress of an asynchronous add, which is uploading
+// until the upload has been received and then runs in the background.
+type AddJob struct {
+	ID         string       `json:"id" codec:"i"`
+	Status     AddJobStatus `json:"status" codec:"st"`
+	Uploaded   uint64       `json:"uploaded" codec:"u,omitempty"`
+	Bytes      uint64       `json:"bytes" codec:"b,omitempty"`
+	Blocks     uint64       `json:"blocks" codec:"bl,omitempty"`
+	CurrentCid Cid          `json:"current_cid,omitempty" codec:"cc,omitempty"`
+	Cid        Cid          `json:"cid,omitempty" codec:"c,omitempty"`
+	Error      string       `json:"error,omitempty" codec:"e,omitempty"`
+	Started    time.Time    `json:"started" codec:"s"`
+	Finished   time.Time    `json:"finished,omitempty" codec:"f,omitempty"`
+}
+
+// AddUpload describes a resumable upload, which is received in several
+// parts and added once complete. Offset is the number of bytes received so
+// far, from which the upload continues.
+type AddUpload struct {
+	ID      string    `json:"id" codec:"i"`
+	Offset  uint64    `json:"offset" codec:"o,omitempty"`
+	Expires time.Time `json:"expires" codec:"e"`
 }
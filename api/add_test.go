@@ -6,7 +6,7 @@ import (
 )
 
 func TestAddParams_FromQuery(t *testing.T) {
-	qStr := "layout=balanced&chunker=size-262144&name=test&raw-leaves=true&hidden=true&shard=true&replication-min=2&replication-max=4&shard-size=1"
+	qStr := "layout=balanced&chunker=size-262144&name=test&raw-leaves=true&hidden=true&shard=true&replication-min=2&replication-max=4&shard-size=1&shard-max-links=100"
 
 	q, err := url.ParseQuery(qStr)
 	if err != nil {
@@ -23,7 +23,8 @@ func TestAddParams_FromQuery(t *testing.T) {
 		!p.RawLeaves || !p.Hidden || !p.Shard ||
 		p.ReplicationFactorMin != 2 ||
 		p.ReplicationFactorMax != 4 ||
-		p.ShardSize != 1 {
+		p.ShardSize != 1 ||
+		p.ShardMaxLinks != 100 {
 		t.Fatal("did not parse the query correctly")
 	}
 }
@@ -75,6 +76,89 @@ func TestAddParams_FromQueryRawLeaves(t *testing.T) {
 	}
 }
 
+func TestAddParams_FromQueryChunker(t *testing.T) {
+	for _, chunker := range []string{"size-1024", "rabin", "rabin-262144", "rabin-16-1024-4096", "rabin-min:16-avg:1024-max:4096", "buzhash"} {
+		q := url.Values{}
+		q.Set("chunker", chunker)
+		p, err := AddParamsFromQuery(q)
+		if err != nil {
+			t.Errorf("%s: %s", chunker, err)
+			continue
+		}
+		if p.Chunker != chunker {
+			t.Errorf("expected chunker %s, got %s", chunker, p.Chunker)
+		}
+	}
+
+	for _, chunker := range []string{"size-0", "rabin-1024-16-4096", "rabin-8-16-32", "rabin-16-1024-2097152", "other"} {
+		q := url.Values{}
+		q.Set("chunker", chunker)
+		if _, err := AddParamsFromQuery(q); err == nil {
+			t.Errorf("%s: expected an error", chunker)
+		}
+	}
+}
+
+func TestAddParams_FromQueryFormat(t *testing.T) {
+	for _, format := range []string{"unixfs", "car", "dag-json", "dag-cbor"} {
+		q := url.Values{}
+		q.Set("format", format)
+		p, err := AddParamsFromQuery(q)
+		if err != nil {
+			t.Errorf("%s: %s", format, err)
+			continue
+		}
+		if p.Format != format {
+			t.Errorf("expected format %s, got %s", format, p.Format)
+		}
+	}
+
+	q := url.Values{}
+	q.Set("format", "dag-pb")
+	if _, err := AddParamsFromQuery(q); err == nil {
+		t.Error("expected an error with an unknown format")
+	}
+}
+
+func TestAddParams_FromQueryPinUpdate(t *testing.T) {
+	c := "QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmmq"
+	q, err := url.ParseQuery("pin-update=" + c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := AddParamsFromQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.PinUpdate.String() != c {
+		t.Error("expected pin-update to be parsed")
+	}
+
+	q.Set("shard", "true")
+	if _, err := AddParamsFromQuery(q); err == nil {
+		t.Error("expected an error when using pin-update with sharding")
+	}
+}
+
+func TestAddParams_FromQueryVerify(t *testing.T) {
+	q, err := url.ParseQuery("verify=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := AddParamsFromQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Verify {
+		t.Error("expected verify to be parsed")
+	}
+
+	q.Set("no-pin", "true")
+	if _, err := AddParamsFromQuery(q); err == nil {
+		t.Error("expected an error when using verify with no-pin")
+	}
+}
+
 func TestAddParams_ToQueryString(t *testing.T) {
 	p := DefaultAddParams()
 	p.ReplicationFactorMin = 3
@@ -82,6 +166,9 @@ func TestAddParams_ToQueryString(t *testing.T) {
 	p.Name = "something"
 	p.RawLeaves = true
 	p.ShardSize = 1020
+	p.ShardMaxLinks = 100
+	p.Async = true
+	p.Verify = true
 	qstr, err := p.ToQueryString()
 	if err != nil {
 		t.Fatal(err)
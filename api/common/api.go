@@ -92,17 +92,62 @@ type API struct {
 	httpListeners  []net.Listener
 	libp2pListener net.Listener
 
+	// jwt verifies bearer tokens from identity providers when
+	// configured.
+	jwt *jwtVerifier
+
+	// idempotency remembers the responses to requests with idempotency
+	// keys when enabled.
+	idempotency *idempotencyCache
+
 	shutdownLock sync.Mutex
 	shutdown     bool
 	wg           sync.WaitGroup
 }
 
-// Route defines a REST endpoint supported by this API.
+// API versions. Routes are served under /v<version> for every version that
+// they support. Routes supporting LegacyAPIVersion are additionally served
+// at their unversioned paths, as they were before versions were introduced.
+const (
+	APIVersion1      = 1
+	LegacyAPIVersion = APIVersion1
+)
+
+// DefaultRouteVersions are the versions supported by routes which do not
+// set them.
+var DefaultRouteVersions = []int{APIVersion1}
+
+// Route defines a REST endpoint supported by this API. Versions lists the
+// API versions serving the route, DefaultRouteVersions when empty. Routes
+// which change in a breaking way in a new version should be declared twice,
+// with the old and the new versions.
 type Route struct {
 	Name        string
 	Method      string
 	Pattern     string
 	HandlerFunc http.HandlerFunc
+	Versions    []int
+	// Scopes required to use the route when authentication is
+	// enabled. Defaults to ScopeRead for GET and HEAD routes and to
+	// ScopeAdmin for the rest.
+	Scopes []Scope
+}
+
+type apiVersionKey struct{}
+
+// RequestAPIVersion returns the API version of the route serving the given
+// request. Unversioned paths return LegacyAPIVersion.
+func RequestAPIVersion(r *http.Request) int {
+	if v, ok := r.Context().Value(apiVersionKey{}).(int); ok {
+		return v
+	}
+	return LegacyAPIVersion
+}
+
+// VersionedPattern returns the path under which the route pattern is
+// served in the given API version.
+func VersionedPattern(version int, pattern string) string {
+	return fmt.Sprintf("/v%d%s", version, pattern)
 }
 
 type jwtToken struct {
@@ -141,24 +186,37 @@ func NewAPIWithHost(ctx context.Context, cfg *Config, h host.Host, routes func(*
 		routes:   routes,
 		rpcReady: make(chan struct{}, 2),
 	}
+	if cfg.JWTSecret != "" || cfg.JWTPublicKey != nil || cfg.JWKSURL != "" {
+		api.jwt = newJWTVerifier(cfg)
+	}
+	if cfg.IdempotencyWindow > 0 {
+		api.idempotency = newIdempotencyCache(cfg.IdempotencyWindow)
+	}
 
 	// Our handler is a gorilla router wrapped with:
 	// - a custom strictSlashHandler that uses 307 redirects (#1415)
 	// - the cors handler,
-	// - the basic auth handler.
+	// - the rate limit handler,
+	// - the basic auth handler,
+	// - the compression handler.
 	//
 	// Requests will need to have valid credentials first, except
-	// cors-preflight requests (OPTIONS). Then requests are handled by
+	// cors-preflight requests (OPTIONS). Then they are subject to the
+	// rate limits of their credentials, if any. Then requests are handled by
 	// CORS and potentially need to comply with it. Then they may be
 	// redirected if the path ends with a "/". Finally they hit one of our
 	// routes and handlers.
 	router := mux.NewRouter()
-	handler := api.authHandler(
-		cors.New(*cfg.CorsOptions()).
-			Handler(
-				strictSlashHandler(router),
+	handler := api.compressionHandler(
+		api.authHandler(
+			api.rateLimitHandler(
+				cors.New(*cfg.CorsOptions()).
+					Handler(
+						strictSlashHandler(router),
+					),
 			),
-		cfg.Logger,
+			cfg.Logger,
+		),
 	)
 	if cfg.Tracing {
 		handler = &ochttp.Handler{
@@ -272,30 +330,74 @@ func (api *API) setupLibp2p() error {
 
 func (api *API) addRoutes() {
 	for _, route := range api.routes(api.rpcClient) {
-		api.router.
-			Methods(route.Method).
-			Path(route.Pattern).
-			Name(route.Name).
-			Handler(
-				ochttp.WithRouteTag(
-					http.HandlerFunc(route.HandlerFunc),
-					"/"+route.Name,
-				),
-			)
+		versions := route.Versions
+		if len(versions) == 0 {
+			versions = DefaultRouteVersions
+		}
+		for _, v := range versions {
+			name := fmt.Sprintf("v%d/%s", v, route.Name)
+			api.addRoute(route, VersionedPattern(v, route.Pattern), name, v)
+			if v == LegacyAPIVersion {
+				api.addRoute(route, route.Pattern, route.Name, v)
+			}
+		}
 	}
 	api.router.NotFoundHandler = ochttp.WithRouteTag(
-		http.HandlerFunc(api.notFoundHandler),
+		InstrumentHandler(
+			api.config.ConfigKey,
+			"notfound",
+			http.HandlerFunc(api.notFoundHandler),
+		),
 		"/notfound",
 	)
 }
 
-// authHandler takes care of authentication either using basicAuth or JWT bearer tokens.
+// addRoute registers the route handler for the given path, setting the API
+// version in the request context.
+func (api *API) addRoute(route Route, path, name string, version int) {
+	scopes := routeScopes(route)
+	routeHandler := route.HandlerFunc
+	switch route.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		if api.idempotency != nil {
+			routeHandler = api.idempotentHandler(routeHandler)
+		}
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if err := CheckScopes(r, scopes...); err != nil {
+			api.SendResponse(w, http.StatusForbidden, err, nil)
+			return
+		}
+		ctx := context.WithValue(r.Context(), apiVersionKey{}, version)
+		routeHandler(w, r.WithContext(ctx))
+	}
+
+	api.router.
+		Methods(route.Method).
+		Path(path).
+		Name(name).
+		Handler(
+			ochttp.WithRouteTag(
+				InstrumentHandler(
+					api.config.ConfigKey,
+					name,
+					http.HandlerFunc(handler),
+				),
+				"/"+name,
+			),
+		)
+}
+
+// authHandler takes care of authentication either using client
+// certificates, basicAuth or JWT bearer tokens, issued by the cluster or
+// by identity providers.
 func (api *API) authHandler(h http.Handler, lggr *logging.ZapEventLogger) http.Handler {
 
 	credentials := api.config.BasicAuthCredentials
+	clientCerts := api.config.TLS != nil && api.config.TLS.ClientCAs != nil
 
 	// If no credentials are set, we do nothing.
-	if credentials == nil {
+	if credentials == nil && !clientCerts && api.jwt == nil {
 		return h
 	}
 
@@ -307,9 +409,18 @@ func (api *API) authHandler(h http.Handler, lggr *logging.ZapEventLogger) http.H
 			return
 		}
 
+		// The TLS handshake already verified client certificates.
+		if cn, ok := clientCertName(r); ok {
+			r = withIdentity(r, "cert:"+cn)
+			h.ServeHTTP(w, withScopes(r, api.config.certScopes(cn)))
+			return
+		}
+
 		username, password, okBasic := r.BasicAuth()
 		tokenString, okToken := parseBearerToken(r.Header.Get("Authorization"))
 
+		var identity string
+		var scopes []Scope
 		switch {
 		case okBasic:
 			ok := verifyBasicAuth(credentials, username, password)
@@ -318,8 +429,11 @@ func (api *API) authHandler(h http.Handler, lggr *logging.ZapEventLogger) http.H
 				api.SendResponse(w, http.StatusUnauthorized, errors.New("unauthorized: access denied"), nil)
 				return
 			}
+			identity = "user:" + username
+			scopes = api.config.userScopes(username)
 		case okToken:
-			_, err := verifyToken(credentials, tokenString)
+			var err error
+			identity, scopes, err = api.verifyBearerToken(r.Context(), credentials, tokenString)
 			if err != nil {
 				lggr.Debug(err)
 
@@ -336,7 +450,7 @@ func (api *API) authHandler(h http.Handler, lggr *logging.ZapEventLogger) http.H
 		}
 
 		// If we are here, authentication worked.
-		h.ServeHTTP(w, r)
+		h.ServeHTTP(w, withScopes(withIdentity(r, identity), scopes))
 	}
 	return http.HandlerFunc(wrap)
 }
@@ -361,6 +475,15 @@ func wwwAuthenticate(auth, realm, error, description string) string {
 	return str
 }
 
+// clientCertName returns the Common Name of the verified certificate
+// presented by the client.
+func clientCertName(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName, true
+}
+
 func verifyBasicAuth(credentials map[string]string, username, password string) bool {
 	if username == "" || password == "" {
 		return false
@@ -373,6 +496,25 @@ func verifyBasicAuth(credentials map[string]string, username, password string) b
 	return false
 }
 
+// verifyBearerToken verifies tokens issued by the cluster, which carry
+// the scopes of their issuer, and then tokens from identity providers, and
+// returns the identity of the client and the scopes that they grant.
+func (api *API) verifyBearerToken(ctx context.Context, credentials map[string]string, tokenString string) (string, []Scope, error) {
+	var err error
+	if credentials != nil {
+		var token *jwt.Token
+		token, err = verifyToken(credentials, tokenString)
+		if err == nil {
+			issuer := token.Claims.(*jwt.RegisteredClaims).Issuer
+			return "user:" + issuer, api.config.userScopes(issuer), nil
+		}
+	}
+	if api.jwt != nil {
+		return api.jwt.verify(ctx, tokenString)
+	}
+	return "", nil, err
+}
+
 // verify that a Bearer JWT token is valid.
 func verifyToken(credentials map[string]string, tokenString string) (*jwt.Token, error) {
 	// The token should be signed with the basic auth credential password
@@ -533,6 +675,11 @@ func (api *API) SetClient(c *rpc.Client) {
 	// One notification for http server and one for libp2p server.
 	api.rpcReady <- struct{}{}
 	api.rpcReady <- struct{}{}
+
+	if len(api.config.Webhooks) > 0 {
+		api.wg.Add(1)
+		go api.runWebhooks(api.ctx)
+	}
 }
 
 func (api *API) notFoundHandler(w http.ResponseWriter, r *http.Request) {
@@ -560,7 +707,9 @@ func (api *API) ParsePinPathOrFail(w http.ResponseWriter, r *http.Request) types
 	err = pinPath.PinOptions.FromQuery(r.URL.Query())
 	if err != nil {
 		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return types.PinPath{}
 	}
+	pinPath.Owner, _ = RequestIdentity(r)
 	return pinPath
 }
 
@@ -579,12 +728,41 @@ func (api *API) ParseCidOrFail(w http.ResponseWriter, r *http.Request) types.Pin
 	err = opts.FromQuery(r.URL.Query())
 	if err != nil {
 		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return types.Pin{}
 	}
+	opts.Owner, _ = RequestIdentity(r)
 	pin := types.PinWithOpts(c, opts)
 	pin.MaxDepth = -1 // For now, all pins are recursive
 	return pin
 }
 
+// OwnershipIdentity returns the identity which must own the pins that the
+// request unpins or modifies. ok is false when ownership is not enforced,
+// when the request is not authenticated, or when an admin overrides it
+// with the "override" query parameter.
+func (api *API) OwnershipIdentity(r *http.Request) (identity string, ok bool) {
+	if !api.config.EnforcePinOwnership {
+		return "", false
+	}
+	identity, ok = RequestIdentity(r)
+	if !ok {
+		return "", false
+	}
+	if r.URL.Query().Get("override") == "true" && CheckScopes(r, ScopeAdmin) == nil {
+		return "", false
+	}
+	return identity, true
+}
+
+// PinOwnershipError returns an error when the given identity cannot unpin
+// or modify the given pin.
+func PinOwnershipError(identity string, pin types.Pin) error {
+	if pin.ModifiableBy(identity) {
+		return nil
+	}
+	return fmt.Errorf("forbidden: %s is owned by %s", pin.Cid, pin.Owner)
+}
+
 // ParsePidOrFail parses a PID and returns it or makes the request fail.
 func (api *API) ParsePidOrFail(w http.ResponseWriter, r *http.Request) peer.ID {
 	vars := mux.Vars(r)
@@ -717,9 +895,29 @@ func (api *API) SendResponse(
 // StreamResponse.
 type StreamIterator func() (interface{}, bool, error)
 
+// NDJSONMediaType is the media type accepted by clients that want streamed
+// responses to be sent as such, rather than as application/json.
+const NDJSONMediaType = "application/x-ndjson"
+
+// WantsNDJSON returns true when the request accepts NDJSONMediaType.
+func WantsNDJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), NDJSONMediaType)
+}
+
 // StreamResponse reads from an iterator and sends the response.
 func (api *API) StreamResponse(w http.ResponseWriter, next StreamIterator, errCh chan error) {
-	api.SetHeaders(w)
+	api.streamResponse(w, "application/json", next, errCh)
+}
+
+// StreamNDJSONResponse works like StreamResponse, but the response is
+// sent with the NDJSONMediaType content type. Items are sent one per line
+// either way.
+func (api *API) StreamNDJSONResponse(w http.ResponseWriter, next StreamIterator, errCh chan error) {
+	api.streamResponse(w, NDJSONMediaType, next, errCh)
+}
+
+func (api *API) streamResponse(w http.ResponseWriter, contentType string, next StreamIterator, errCh chan error) {
+	api.SetHeadersWithContentType(w, contentType)
 	enc := json.NewEncoder(w)
 	flusher, flush := w.(http.Flusher)
 	w.Header().Set("Trailer", "X-Stream-Error")
@@ -807,13 +1005,19 @@ func (api *API) StreamResponse(w http.ResponseWriter, next StreamIterator, errCh
 // SetHeaders sets all the headers that are common to all responses
 // from this API. Called automatically from SendResponse().
 func (api *API) SetHeaders(w http.ResponseWriter) {
+	api.SetHeadersWithContentType(w, "application/json")
+}
+
+// SetHeadersWithContentType works like SetHeaders but sets the given
+// Content-Type.
+func (api *API) SetHeadersWithContentType(w http.ResponseWriter, contentType string) {
 	for header, values := range api.config.Headers {
 		for _, val := range values {
 			w.Header().Add(header, val)
 		}
 	}
 
-	w.Header().Add("Content-Type", "application/json")
+	w.Header().Add("Content-Type", contentType)
 }
 
 // These functions below are mostly used in tests.
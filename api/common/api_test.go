@@ -1,24 +1,46 @@
 package common
 
 import (
+	"bytes"
+	"compress/zlib"
+	"compress/gzip"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
+	"math/big"
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
 	"net/http/httputil"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/ipfs-cluster/ipfs-cluster/api"
 	"github.com/ipfs-cluster/ipfs-cluster/api/common/test"
+	"github.com/ipfs-cluster/ipfs-cluster/observations"
 	rpctest "github.com/ipfs-cluster/ipfs-cluster/test"
 
+	jwt "github.com/golang-jwt/jwt/v4"
 	libp2p "github.com/libp2p/go-libp2p"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
+	peer "github.com/libp2p/go-libp2p/core/peer"
 	ma "github.com/multiformats/go-multiaddr"
+	"go.opencensus.io/stats/view"
 )
 
 const (
@@ -37,17 +59,89 @@ var (
 	invalidToken, _ = generateSignedTokenString(invalidUserName, invalidUserPassword)
 )
 
+// largeTestBody is the response of the TestLarge route.
+var largeTestBody = []byte(`{ "thisis": "` + strings.Repeat("large", 2000) + `" }`)
+
+// testCounter is incremented by the TestCounter route.
+var testCounter int64
+
 func routes(c *rpc.Client) []Route {
 	return []Route{
 		{
-			"Test",
-			"GET",
-			"/test",
-			func(w http.ResponseWriter, r *http.Request) {
+			Name:    "Test",
+			Method:  "GET",
+			Pattern: "/test",
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
 				w.Header().Add("Content-Type", "application/json")
 				w.Write([]byte(`{ "thisis": "atest" }`))
 			},
 		},
+		{
+			Name:    "TestVersion",
+			Method:  "GET",
+			Pattern: "/test/version",
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Content-Type", "application/json")
+				fmt.Fprintf(w, `{ "version": %d }`, RequestAPIVersion(r))
+			},
+			Versions: []int{APIVersion1, 2},
+		},
+		{
+			Name:    "TestV2",
+			Method:  "GET",
+			Pattern: "/test/v2only",
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Content-Type", "application/json")
+				w.Write([]byte(`{ "thisis": "v2" }`))
+			},
+			Versions: []int{2},
+		},
+		{
+			Name:    "TestPin",
+			Method:  "POST",
+			Pattern: "/test/pin",
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			Scopes: []Scope{ScopePin},
+		},
+		{
+			Name:    "TestLarge",
+			Method:  "GET",
+			Pattern: "/test/large",
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Content-Type", "application/json")
+				w.Write(largeTestBody)
+			},
+		},
+		{
+			Name:    "TestLargePost",
+			Method:  "POST",
+			Pattern: "/test/large",
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Content-Type", "application/json")
+				w.Write(largeTestBody)
+			},
+			Scopes: []Scope{ScopePin},
+		},
+		{
+			Name:    "TestCounter",
+			Method:  "POST",
+			Pattern: "/test/counter",
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Content-Type", "application/json")
+				fmt.Fprintf(w, `{ "count": %d }`, atomic.AddInt64(&testCounter, 1))
+			},
+			Scopes: []Scope{ScopePin},
+		},
+		{
+			Name:    "TestAdmin",
+			Method:  "DELETE",
+			Pattern: "/test/admin",
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		},
 	}
 
 }
@@ -119,6 +213,47 @@ func TestAPIShutdown(t *testing.T) {
 
 }
 
+func TestAPIVersionedRoutes(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		r := make(map[string]string)
+		test.MakeGet(t, rest, url(rest)+"/v1/test", &r)
+		if r["thisis"] != "atest" {
+			t.Error("routes should be served under /v1")
+		}
+
+		for path, expected := range map[string]float64{
+			"/test/version":    LegacyAPIVersion,
+			"/v1/test/version": 1,
+			"/v2/test/version": 2,
+		} {
+			v := make(map[string]float64)
+			test.MakeGet(t, rest, url(rest)+path, &v)
+			if v["version"] != expected {
+				t.Errorf("%s: expected version %.0f, got %.0f", path, expected, v["version"])
+			}
+		}
+
+		r = make(map[string]string)
+		test.MakeGet(t, rest, url(rest)+"/v2/test/v2only", &r)
+		if r["thisis"] != "v2" {
+			t.Error("v2 routes should be served under /v2")
+		}
+		for _, path := range []string{"/test/v2only", "/v1/test/v2only", "/v2/test"} {
+			errResp := api.Error{}
+			test.MakeGet(t, rest, url(rest)+path, &errResp)
+			if errResp.Code != http.StatusNotFound {
+				t.Errorf("%s should not be found", path)
+			}
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
 func TestHTTPSTestEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -642,3 +777,930 @@ func TestLimitMaxHeaderSize(t *testing.T) {
 		test.BothEndpoints(t, tc.getTestFunction(rest))
 	}
 }
+
+func TestRateLimiter(t *testing.T) {
+	rl := newRateLimiter(2, 3)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := rl.allow("a", now); !ok {
+			t.Fatalf("request %d should be allowed by the burst", i)
+		}
+	}
+	ok, wait := rl.allow("a", now)
+	if ok {
+		t.Fatal("request over the burst should not be allowed")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("unexpected wait: %s", wait)
+	}
+
+	if ok, _ := rl.allow("b", now); !ok {
+		t.Error("other keys should have their own bucket")
+	}
+
+	if ok, _ := rl.allow("a", now.Add(wait)); !ok {
+		t.Error("request should be allowed after waiting")
+	}
+
+	rl.sweep(now.Add(time.Hour))
+	if len(rl.buckets) != 0 {
+		t.Error("full buckets should be swept")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	ctx := context.Background()
+	cfg := newDefaultTestConfig(t)
+	cfg.BasicAuthCredentials = map[string]string{
+		validUserName: validUserPassword,
+		adminUserName: adminUserPassword,
+	}
+	cfg.RateLimit = 0.01
+	cfg.RateLimitBurst = 2
+	rest := testAPIwithConfig(t, cfg, "rate limit")
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, prefixMaker test.URLFunc) {
+		h := test.MakeHost(t, rest)
+		defer h.Close()
+		url := prefixMaker(rest) + "/test"
+		c := test.HTTPClient(t, h, test.IsHTTPS(url))
+		get := func(user, pass string) *http.Response {
+			req, _ := http.NewRequest(http.MethodGet, url, nil)
+			req.SetBasicAuth(user, pass)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			return resp
+		}
+
+		// The buckets are shared by both endpoints, so the admin
+		// user may be limited already.
+		for {
+			if resp := get(adminUserName, adminUserPassword); resp.StatusCode == http.StatusTooManyRequests {
+				break
+			}
+		}
+
+		resp := get(adminUserName, adminUserPassword)
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("expected 429, got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("Retry-After") != "100" {
+			t.Errorf("unexpected Retry-After: %s", resp.Header.Get("Retry-After"))
+		}
+
+		resp = get(validUserName, validUserPassword)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			t.Error("other users should not be limited")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestRateLimitKey(t *testing.T) {
+	req := func(token string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/test", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		return r
+	}
+
+	// Different tokens of the same identity share a bucket.
+	r1 := withIdentity(req("token1"), "user:a")
+	r2 := withIdentity(req("token2"), "user:a")
+	if rateLimitKey(r1) != rateLimitKey(r2) {
+		t.Error("tokens of the same identity should share the key")
+	}
+
+	// Unauthenticated requests are keyed by address, whatever
+	// credentials they carry.
+	r3 := req("token3")
+	r3.RemoteAddr = "1.2.3.4:1234"
+	if rateLimitKey(r3) != "addr:1.2.3.4" {
+		t.Errorf("unexpected key: %s", rateLimitKey(r3))
+	}
+}
+
+func TestRateLimitScopes(t *testing.T) {
+	ctx := context.Background()
+	cfg := newDefaultTestConfig(t)
+	cfg.BasicAuthCredentials = map[string]string{
+		validUserName: validUserPassword,
+		adminUserName: adminUserPassword,
+	}
+	cfg.BasicAuthScopes = map[string][]Scope{
+		validUserName: {ScopeRead},
+	}
+	cfg.RateLimit = 0.01
+	cfg.RateLimitBurst = 2
+	cfg.RateLimitScopes = map[Scope]float64{ScopeAdmin: 0}
+	rest := testAPIwithConfig(t, cfg, "rate limit scopes")
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, prefixMaker test.URLFunc) {
+		h := test.MakeHost(t, rest)
+		defer h.Close()
+		url := prefixMaker(rest) + "/test"
+		c := test.HTTPClient(t, h, test.IsHTTPS(url))
+		get := func(user, pass string) *http.Response {
+			req, _ := http.NewRequest(http.MethodGet, url, nil)
+			req.SetBasicAuth(user, pass)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			return resp
+		}
+
+		for i := 0; i < 5; i++ {
+			if resp := get(adminUserName, adminUserPassword); resp.StatusCode == http.StatusTooManyRequests {
+				t.Fatal("the admin scope should not be limited")
+			}
+		}
+
+		limited := false
+		for i := 0; i < 5; i++ {
+			if resp := get(validUserName, validUserPassword); resp.StatusCode == http.StatusTooManyRequests {
+				limited = true
+				break
+			}
+		}
+		if !limited {
+			t.Error("the read scope should be limited")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestScopes(t *testing.T) {
+	ctx := context.Background()
+	cfg := newDefaultTestConfig(t)
+	cfg.BasicAuthCredentials = map[string]string{
+		validUserName: validUserPassword,
+		adminUserName: adminUserPassword,
+	}
+	cfg.BasicAuthScopes = map[string][]Scope{
+		validUserName: {ScopeRead, ScopePin},
+	}
+	rest := testAPIwithConfig(t, cfg, "scopes")
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, prefixMaker test.URLFunc) {
+		h := test.MakeHost(t, rest)
+		defer h.Close()
+		c := test.HTTPClient(t, h, test.IsHTTPS(prefixMaker(rest)))
+		do := func(method, path string, shaper requestShaper) int {
+			req, _ := http.NewRequest(method, prefixMaker(rest)+path, nil)
+			shaper(req)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		limited := makeBasicAuthRequestShaper(validUserName, validUserPassword)
+		token := makeTokenAuthRequestShaper(validToken)
+		admin := makeBasicAuthRequestShaper(adminUserName, adminUserPassword)
+
+		for _, shaper := range []requestShaper{limited, token} {
+			if st := do("GET", "/test", shaper); st != http.StatusOK {
+				t.Errorf("expected 200 reading: got %d", st)
+			}
+			if st := do("POST", "/test/pin", shaper); st != http.StatusNoContent {
+				t.Errorf("expected 204 pinning: got %d", st)
+			}
+			if st := do("DELETE", "/test/admin", shaper); st != http.StatusForbidden {
+				t.Errorf("expected 403 without admin scope: got %d", st)
+			}
+			if st := do("DELETE", "/v1/test/admin", shaper); st != http.StatusForbidden {
+				t.Errorf("expected 403 without admin scope: got %d", st)
+			}
+		}
+
+		if st := do("DELETE", "/test/admin", admin); st != http.StatusNoContent {
+			t.Errorf("expected 204 for users without configured scopes: got %d", st)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+// makeClientCerts writes a new CA certificate to caFile and returns client
+// certificates signed by it with the given Common Names.
+func makeClientCerts(t *testing.T, caFile string, names ...string) []tls.Certificate {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(crand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certs := make([]tls.Certificate, len(names))
+	for i, name := range names {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 2)),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		der, err := x509.CreateCertificate(crand.Reader, tmpl, caTmpl, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs[i] = tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	}
+	return certs
+}
+
+func TestClientCertAuth(t *testing.T) {
+	ctx := context.Background()
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	certs := makeClientCerts(t, caFile, "reader", "operator")
+
+	cfg := newDefaultTestConfig(t)
+	cfg.PathSSLCertFile = SSLCertFile
+	cfg.PathSSLKeyFile = SSLKeyFile
+	var err error
+	cfg.TLS, err = newTLSConfig(cfg.PathSSLCertFile, cfg.PathSSLKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := setClientCAs(cfg.TLS, caFile, true); err != nil {
+		t.Fatal(err)
+	}
+	cfg.PathClientCAFile = caFile
+	cfg.ClientCertRequired = true
+	cfg.ClientCertScopes = map[string][]Scope{
+		"reader": {ScopeRead},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	rest := testAPIwithConfig(t, cfg, "client certificates")
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		client := func(certs ...tls.Certificate) *http.Client {
+			c := test.HTTPClient(t, nil, true)
+			c.Transport.(*http.Transport).TLSClientConfig.Certificates = certs
+			return c
+		}
+		do := func(c *http.Client, method, path string) (int, error) {
+			req, _ := http.NewRequest(method, url(rest)+path, nil)
+			resp, err := c.Do(req)
+			if err != nil {
+				return 0, err
+			}
+			resp.Body.Close()
+			return resp.StatusCode, nil
+		}
+
+		if _, err := do(client(), "GET", "/test"); err == nil {
+			t.Error("expected an error without a client certificate")
+		}
+
+		reader := client(certs[0])
+		if st, err := do(reader, "GET", "/test"); err != nil || st != http.StatusOK {
+			t.Errorf("expected 200 reading: got %d (%v)", st, err)
+		}
+		if st, err := do(reader, "DELETE", "/test/admin"); err != nil || st != http.StatusForbidden {
+			t.Errorf("expected 403 without admin scope: got %d (%v)", st, err)
+		}
+
+		operator := client(certs[1])
+		if st, err := do(operator, "DELETE", "/test/admin"); err != nil || st != http.StatusNoContent {
+			t.Errorf("expected 204 for certificates without configured scopes: got %d (%v)", st, err)
+		}
+	}
+
+	test.HTTPSEndPoint(t, tf)
+}
+
+func TestJWTAuth(t *testing.T) {
+	ctx := context.Background()
+
+	jwksMinRefreshInterval = 0
+	defer func() { jwksMinRefreshInterval = time.Minute }()
+	rsaKeys := make(map[string]*rsa.PrivateKey)
+	for _, kid := range []string{"k1", "k2"} {
+		key, err := rsa.GenerateKey(crand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsaKeys[kid] = key
+	}
+	var published sync.Map
+	published.Store("k1", true)
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := []map[string]string{}
+		for kid, key := range rsaKeys {
+			if _, ok := published.Load(kid); !ok {
+				continue
+			}
+			keys = append(keys, map[string]string{
+				"kid": kid,
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	defer jwks.Close()
+
+	cfg := newDefaultTestConfig(t)
+	cfg.JWTSecret = "s3cret"
+	cfg.JWKSURL = jwks.URL
+	cfg.JWTIssuer = "idp"
+	cfg.JWTAudience = "cluster"
+	rest := testAPIwithConfig(t, cfg, "JWT")
+	defer rest.Shutdown(ctx)
+
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		str, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return str
+	}
+	claims := func(aud, scope string) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss": "idp",
+			"aud": aud,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		if scope != "" {
+			c["scope"] = scope
+		}
+		return c
+	}
+
+	hsReader := sign(jwt.SigningMethodHS256, "", []byte("s3cret"), claims("cluster", "read pin"))
+	hsAdmin := sign(jwt.SigningMethodHS256, "", []byte("s3cret"), claims("cluster", "admin"))
+	hsNoScopes := sign(jwt.SigningMethodHS256, "", []byte("s3cret"), claims("cluster", ""))
+	hsBadSecret := sign(jwt.SigningMethodHS256, "", []byte("wrong"), claims("cluster", ""))
+	hsBadAudience := sign(jwt.SigningMethodHS256, "", []byte("s3cret"), claims("other", ""))
+	rsK1 := sign(jwt.SigningMethodRS256, "k1", rsaKeys["k1"], claims("cluster", "read"))
+	rsK2 := sign(jwt.SigningMethodRS256, "k2", rsaKeys["k2"], claims("cluster", "read"))
+
+	tf := func(t *testing.T, prefixMaker test.URLFunc) {
+		h := test.MakeHost(t, rest)
+		defer h.Close()
+		c := test.HTTPClient(t, h, test.IsHTTPS(prefixMaker(rest)))
+		do := func(method, path, token string) int {
+			req, _ := http.NewRequest(method, prefixMaker(rest)+path, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		if st := do("GET", "/test", hsReader); st != http.StatusOK {
+			t.Errorf("expected 200 with an HS256 token: got %d", st)
+		}
+		if st := do("DELETE", "/test/admin", hsReader); st != http.StatusForbidden {
+			t.Errorf("expected 403 without admin scope: got %d", st)
+		}
+		if st := do("DELETE", "/test/admin", hsAdmin); st != http.StatusNoContent {
+			t.Errorf("expected 204 with admin scope: got %d", st)
+		}
+		if st := do("GET", "/test", hsNoScopes); st != http.StatusForbidden {
+			t.Errorf("expected 403 for tokens without scopes: got %d", st)
+		}
+		if st := do("GET", "/test", hsBadSecret); st != http.StatusUnauthorized {
+			t.Errorf("expected 401 with a bad signature: got %d", st)
+		}
+		if st := do("GET", "/test", hsBadAudience); st != http.StatusUnauthorized {
+			t.Errorf("expected 401 with a bad audience: got %d", st)
+		}
+		if st := do("GET", "/test", rsK1); st != http.StatusOK {
+			t.Errorf("expected 200 with an RS256 token: got %d", st)
+		}
+	}
+	test.BothEndpoints(t, tf)
+
+	// Rotate the keys.
+	published.Store("k2", true)
+	published.Delete("k1")
+	tf = func(t *testing.T, prefixMaker test.URLFunc) {
+		h := test.MakeHost(t, rest)
+		defer h.Close()
+		c := test.HTTPClient(t, h, test.IsHTTPS(prefixMaker(rest)))
+		req, _ := http.NewRequest("GET", prefixMaker(rest)+"/test", nil)
+		req.Header.Set("Authorization", "Bearer "+rsK2)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected 200 with a token signed by a new key: got %d", resp.StatusCode)
+		}
+	}
+	test.BothEndpoints(t, tf)
+}
+
+func TestIdempotencyCache(t *testing.T) {
+	ic := newIdempotencyCache(time.Minute)
+	now := time.Now()
+
+	if resp, err := ic.start("a", now); resp != nil || err != nil {
+		t.Fatal("expected to reserve a new key")
+	}
+	if _, err := ic.start("a", now); err == nil {
+		t.Error("expected an error while the request is in progress")
+	}
+	ic.finish("a", &idempotentResponse{status: http.StatusAccepted}, now)
+	if resp, err := ic.start("a", now.Add(time.Second)); err != nil || resp == nil || resp.status != http.StatusAccepted {
+		t.Error("expected the remembered response")
+	}
+	if resp, err := ic.start("a", now.Add(2*time.Minute)); resp != nil || err != nil {
+		t.Error("expected the response to expire")
+	}
+
+	// Responses which should not be replayed are forgotten.
+	ic.finish("a", nil, now)
+	if resp, err := ic.start("a", now); resp != nil || err != nil {
+		t.Error("expected the key to be forgotten")
+	}
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	cfg := newDefaultTestConfig(t)
+	cfg.IdempotencyWindow = time.Minute
+	rest := testAPIwithConfig(t, cfg, "idempotency")
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, prefixMaker test.URLFunc) {
+		h := test.MakeHost(t, rest)
+		defer h.Close()
+		c := test.HTTPClient(t, h, test.IsHTTPS(prefixMaker(rest)))
+		post := func(key string) (int64, bool) {
+			req, _ := http.NewRequest(http.MethodPost, prefixMaker(rest)+"/test/counter", nil)
+			if key != "" {
+				req.Header.Set(IdempotencyKeyHeader, key)
+			}
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var body struct {
+				Count int64 `json:"count"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			return body.Count, resp.Header.Get(IdempotentReplayedHeader) == "true"
+		}
+
+		key := fmt.Sprintf("key-%d", rand.Int())
+		first, replayed := post(key)
+		if replayed {
+			t.Error("the first response should not be replayed")
+		}
+		again, replayed := post(key)
+		if again != first || !replayed {
+			t.Errorf("expected the response to be replayed: got %d, want %d", again, first)
+		}
+		if other, _ := post(key + "-other"); other == first {
+			t.Error("requests with a different key should be executed")
+		}
+		if none, replayed := post(""); none == first || replayed {
+			t.Error("requests without a key should be executed")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestCompression(t *testing.T) {
+	ctx := context.Background()
+	cfg := newDefaultTestConfig(t)
+	cfg.Compression = true
+	cfg.CompressionMinSize = 1024
+	rest := testAPIwithConfig(t, cfg, "compression")
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, prefixMaker test.URLFunc) {
+		h := test.MakeHost(t, rest)
+		defer h.Close()
+		c := test.HTTPClient(t, h, test.IsHTTPS(prefixMaker(rest)))
+		// Do not let the client decompress responses.
+		c.Transport.(*http.Transport).DisableCompression = true
+
+		get := func(path, acceptEncoding string) (string, []byte) {
+			req, _ := http.NewRequest(http.MethodGet, prefixMaker(rest)+path, nil)
+			if acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", acceptEncoding)
+			}
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var body io.Reader = resp.Body
+			encoding := resp.Header.Get("Content-Encoding")
+			switch encoding {
+			case "gzip":
+				body, err = gzip.NewReader(resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+			case "deflate":
+				body, err = zlib.NewReader(resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			b, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return encoding, b
+		}
+
+		encoding, body := get("/test/large", "gzip, deflate")
+		if encoding != "gzip" || !bytes.Equal(body, largeTestBody) {
+			t.Errorf("expected a gzipped response: got %q encoding", encoding)
+		}
+		encoding, body = get("/test/large", "deflate, gzip;q=0")
+		if encoding != "deflate" || !bytes.Equal(body, largeTestBody) {
+			t.Errorf("expected a deflated response: got %q encoding", encoding)
+		}
+		encoding, body = get("/test/large", "")
+		if encoding != "" || !bytes.Equal(body, largeTestBody) {
+			t.Errorf("expected an uncompressed response: got %q encoding", encoding)
+		}
+		encoding, _ = get("/test", "gzip")
+		if encoding != "" {
+			t.Error("small responses should not be compressed")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestIdempotencyKeysCompression(t *testing.T) {
+	ctx := context.Background()
+	cfg := newDefaultTestConfig(t)
+	cfg.IdempotencyWindow = time.Minute
+	cfg.Compression = true
+	cfg.CompressionMinSize = 1024
+	rest := testAPIwithConfig(t, cfg, "idempotency and compression")
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, prefixMaker test.URLFunc) {
+		h := test.MakeHost(t, rest)
+		defer h.Close()
+		c := test.HTTPClient(t, h, test.IsHTTPS(prefixMaker(rest)))
+		// Do not let the client decompress responses.
+		c.Transport.(*http.Transport).DisableCompression = true
+
+		key := fmt.Sprintf("key-%d", rand.Int())
+		post := func(acceptEncoding string) (string, []byte) {
+			req, _ := http.NewRequest(http.MethodPost, prefixMaker(rest)+"/test/large", nil)
+			req.Header.Set(IdempotencyKeyHeader, key)
+			if acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", acceptEncoding)
+			}
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var body io.Reader = resp.Body
+			encoding := resp.Header.Get("Content-Encoding")
+			if encoding == "gzip" {
+				body, err = gzip.NewReader(resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			b, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return encoding, b
+		}
+
+		encoding, body := post("gzip")
+		if encoding != "gzip" || !bytes.Equal(body, largeTestBody) {
+			t.Errorf("expected a gzipped response: got %q encoding", encoding)
+		}
+		encoding, body = post("")
+		if encoding != "" || !bytes.Equal(body, largeTestBody) {
+			t.Errorf("expected an uncompressed replayed response: got %q encoding", encoding)
+		}
+		encoding, body = post("gzip")
+		if encoding != "gzip" || !bytes.Equal(body, largeTestBody) {
+			t.Errorf("expected a gzipped replayed response: got %q encoding", encoding)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestWebhookTransitions(t *testing.T) {
+	now := time.Now()
+	prev := map[api.Cid]WebhookEvent{
+		rpctest.Cid1: {Cid: rpctest.Cid1, Status: WebhookStatusPinning},
+		rpctest.Cid2: {Cid: rpctest.Cid2, Status: WebhookStatusPinned},
+		rpctest.Cid3: {Cid: rpctest.Cid3, Name: "c3", Status: WebhookStatusPinned},
+	}
+	cur := map[api.Cid]WebhookEvent{
+		rpctest.Cid1: {Cid: rpctest.Cid1, Status: WebhookStatusPinned},
+		rpctest.Cid2: {Cid: rpctest.Cid2, Status: WebhookStatusPinned},
+		rpctest.Cid4: {Cid: rpctest.Cid4, Status: WebhookStatusFailed},
+	}
+
+	events := make(map[api.Cid]WebhookEvent)
+	for _, ev := range webhookTransitions(prev, cur, now) {
+		events[ev.Cid] = ev
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events: %+v", events)
+	}
+	if ev := events[rpctest.Cid1]; ev.Status != WebhookStatusPinned || ev.PreviousStatus != WebhookStatusPinning || !ev.Timestamp.Equal(now) {
+		t.Errorf("unexpected event for Cid1: %+v", ev)
+	}
+	if ev := events[rpctest.Cid3]; ev.Status != WebhookStatusRemoved || ev.PreviousStatus != WebhookStatusPinned || ev.Name != "c3" {
+		t.Errorf("unexpected event for Cid3: %+v", ev)
+	}
+	if ev := events[rpctest.Cid4]; ev.Status != WebhookStatusFailed || ev.PreviousStatus != "" {
+		t.Errorf("unexpected event for Cid4: %+v", ev)
+	}
+}
+
+// webhookCluster is a Cluster RPC service whose StatusAll and Status return
+// the given statuses. Every change is announced as a pin status event.
+type webhookCluster struct {
+	mu         sync.Mutex
+	statuses   map[api.Cid]api.TrackerStatus
+	events     chan api.Event
+	subscribed chan struct{}
+	listed     chan struct{}
+	onceSub    sync.Once
+	onceList   sync.Once
+}
+
+func (wc *webhookCluster) set(c api.Cid, st api.TrackerStatus) {
+	wc.mu.Lock()
+	if st == api.TrackerStatusUndefined {
+		delete(wc.statuses, c)
+	} else {
+		wc.statuses[c] = st
+	}
+	wc.mu.Unlock()
+	wc.events <- api.Event{
+		Type:    api.EventPinStatus,
+		PinInfo: &api.PinInfo{Cid: c},
+	}
+}
+
+func (wc *webhookCluster) gpi(c api.Cid, st api.TrackerStatus) api.GlobalPinInfo {
+	return api.GlobalPinInfo{
+		Cid: c,
+		PeerMap: map[string]api.PinInfoShort{
+			rpctest.PeerID1.String(): {Status: st},
+		},
+	}
+}
+
+func (wc *webhookCluster) StatusAll(ctx context.Context, in <-chan api.TrackerStatus, out chan<- api.GlobalPinInfo) error {
+	defer close(out)
+	<-in
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	for c, st := range wc.statuses {
+		out <- wc.gpi(c, st)
+	}
+	wc.onceList.Do(func() { close(wc.listed) })
+	return nil
+}
+
+func (wc *webhookCluster) Status(ctx context.Context, in api.Cid, out *api.GlobalPinInfo) error {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	st, ok := wc.statuses[in]
+	if !ok {
+		st = api.TrackerStatusUnpinned
+	}
+	*out = wc.gpi(in, st)
+	return nil
+}
+
+func (wc *webhookCluster) ID(ctx context.Context, in struct{}, out *api.ID) error {
+	*out = api.ID{ID: rpctest.PeerID1}
+	return nil
+}
+
+func (wc *webhookCluster) Events(ctx context.Context, in <-chan struct{}, out chan<- api.Event) error {
+	defer close(out)
+	wc.onceSub.Do(func() { close(wc.subscribed) })
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev := <-wc.events:
+			out <- ev
+		}
+	}
+}
+
+type webhookConsensus struct{}
+
+func (wc webhookConsensus) Peers(ctx context.Context, in struct{}, out *[]peer.ID) error {
+	*out = []peer.ID{rpctest.PeerID1}
+	return nil
+}
+
+func TestWebhooks(t *testing.T) {
+	ctx := context.Background()
+	secret := "secret"
+
+	events := make(chan WebhookEvent, 10)
+	var failOnce sync.Once
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(WebhookSignatureHeader) != "sha256="+webhookSignature(secret, body) {
+			t.Error("bad webhook signature")
+		}
+		failed := false
+		failOnce.Do(func() { failed = true })
+		if failed {
+			// the first notification should be retried
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var ev WebhookEvent
+		if err := json.Unmarshal(body, &ev); err != nil {
+			t.Error(err)
+		}
+		events <- ev
+	}))
+	defer srv.Close()
+
+	wc := &webhookCluster{
+		statuses: map[api.Cid]api.TrackerStatus{
+			rpctest.Cid1: api.TrackerStatusPinning,
+		},
+		events:     make(chan api.Event),
+		subscribed: make(chan struct{}),
+		listed:     make(chan struct{}),
+	}
+	s := rpc.NewServer(nil, "mock")
+	if err := s.RegisterName("Cluster", wc); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RegisterName("Consensus", webhookConsensus{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := newDefaultTestConfig(t)
+	cfg.HTTPListenAddr = []ma.Multiaddr{ma.StringCast("/ip4/127.0.0.1/tcp/0")}
+	cfg.Webhooks = []string{srv.URL}
+	cfg.WebhookSecret = secret
+	cfg.WebhookMaxRetries = 1
+	wapi, err := NewAPI(ctx, cfg, routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wapi.Shutdown(ctx)
+	wapi.SetClient(rpc.NewClientWithServer(nil, "mock", s))
+
+	next := func(t *testing.T) WebhookEvent {
+		t.Helper()
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for webhook")
+			return WebhookEvent{}
+		}
+	}
+
+	// Let the initial statuses be recorded.
+	for _, ch := range []chan struct{}{wc.subscribed, wc.listed} {
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the webhooks to start")
+		}
+	}
+	wc.set(rpctest.Cid1, api.TrackerStatusPinned)
+	ev := next(t)
+	if !ev.Cid.Equals(rpctest.Cid1) || ev.Status != WebhookStatusPinned || ev.PreviousStatus != WebhookStatusPinning {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+
+	wc.set(rpctest.Cid1, api.TrackerStatusUndefined)
+	ev = next(t)
+	if !ev.Cid.Equals(rpctest.Cid1) || ev.Status != WebhookStatusRemoved {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
+
+func TestInstrumentHandler(t *testing.T) {
+	ctx := context.Background()
+	views := []*view.View{
+		observations.APIRequestsView,
+		observations.APIRequestLatencyView,
+		observations.APIRequestsInFlightView,
+	}
+	if err := view.Register(views...); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(views...)
+
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var resp map[string]string
+		test.MakeGet(t, rest, url(rest)+"/test", &resp)
+		var errResp api.Error
+		test.MakeGet(t, rest, url(rest)+"/notexisting", &errResp)
+	}
+	test.BothEndpoints(t, tf)
+
+	count := func(route, status string) int64 {
+		rows, err := view.RetrieveData(observations.APIRequestsView.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range rows {
+			tags := make(map[string]string)
+			for _, tg := range row.Tags {
+				tags[tg.Key.Name()] = tg.Value
+			}
+			if tags["api"] == "testapi" && tags["route"] == route && tags["status_code"] == status {
+				return row.Data.(*view.CountData).Value
+			}
+		}
+		return 0
+	}
+
+	// Metrics are recorded once the handlers return, which may happen
+	// after clients got their responses.
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && (count("Test", "200") < 2 || count("notfound", "404") < 2) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := count("Test", "200"); n != 2 {
+		t.Errorf("expected 2 requests to Test with 200, got %d", n)
+	}
+	if n := count("notfound", "404"); n != 2 {
+		t.Errorf("expected 2 requests to notfound with 404, got %d", n)
+	}
+
+	rows, err := view.RetrieveData(observations.APIRequestsInFlightView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if v := row.Data.(*view.LastValueData).Value; v != 0 {
+			t.Errorf("expected no requests in flight, got %f", v)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package common
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(io.Discard) },
+}
+
+// acceptedEncoding returns the compression to use for a response according
+// to the Accept-Encoding header of the request: "gzip", "deflate" or "" for
+// none.
+func acceptedEncoding(r *http.Request) string {
+	gzipOK, deflateOK := false, false
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			q, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			if err == nil && q == 0 {
+				continue
+			}
+		}
+		switch coding {
+		case "gzip", "*":
+			gzipOK = true
+		case "deflate":
+			deflateOK = true
+		}
+	}
+	switch {
+	case gzipOK:
+		return "gzip"
+	case deflateOK:
+		return "deflate"
+	default:
+		return ""
+	}
+}
+
+// compressWriter compresses the response once its body reaches minSize
+// bytes. Smaller responses are sent as they are. Flushing a response, as
+// streaming handlers do, starts compressing it right away.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	buf     []byte
+	decided bool
+	cw      io.WriteCloser
+	flusher interface{ Flush() error }
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.decided || cw.status != 0 {
+		return
+	}
+	cw.status = status
+	// These responses have no body.
+	if status == http.StatusNoContent || status == http.StatusNotModified || status < 200 {
+		cw.start(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, b...)
+		if len(cw.buf) < cw.minSize {
+			return len(b), nil
+		}
+		if err := cw.start(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if cw.cw != nil {
+		return cw.cw.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, as streaming responses need it.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.start(true)
+	}
+	if cw.flusher != nil {
+		cw.flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// start sends the headers, setting up the compression when asked to and
+// the handler did not encode the response itself, and then the body
+// buffered so far.
+func (cw *compressWriter) start(compress bool) error {
+	cw.decided = true
+	h := cw.Header()
+	if compress && h.Get("Content-Encoding") == "" {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		switch cw.encoding {
+		case "gzip":
+			gz := gzipWriters.Get().(*gzip.Writer)
+			gz.Reset(cw.ResponseWriter)
+			cw.cw, cw.flusher = gz, gz
+		case "deflate":
+			// The deflate content coding is the zlib format, not
+			// raw DEFLATE (RFC 9110, section 8.4.1.2).
+			zw := zlib.NewWriter(cw.ResponseWriter)
+			cw.cw, cw.flusher = zw, zw
+		}
+	}
+
+	status := cw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	cw.ResponseWriter.WriteHeader(status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := cw.Write(buf)
+	return err
+}
+
+// close sends what is left of the response.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		cw.start(false)
+	}
+	if cw.cw == nil {
+		return
+	}
+	cw.cw.Close()
+	if gz, ok := cw.cw.(*gzip.Writer); ok {
+		gzipWriters.Put(gz)
+	}
+}
+
+// compressionHandler compresses the responses larger than the configured
+// size with gzip or deflate, when the clients accept it.
+func (api *API) compressionHandler(h http.Handler) http.Handler {
+	if !api.config.Compression {
+		return h
+	}
+
+	wrap := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := acceptedEncoding(r)
+		if encoding == "" || r.Method == http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: w,
+			encoding:       encoding,
+			minSize:        api.config.CompressionMinSize,
+		}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	}
+	return http.HandlerFunc(wrap)
+}
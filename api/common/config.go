@@ -1,16 +1,21 @@
 package common
 
 import (
+	"crypto/rsa"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
 	"time"
 
+	jwt "github.com/golang-jwt/jwt/v4"
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
 	logging "github.com/ipfs/go-log/v2"
 	crypto "github.com/libp2p/go-libp2p/core/crypto"
 	peer "github.com/libp2p/go-libp2p/core/peer"
@@ -42,6 +47,9 @@ type Config struct {
 	Logger        *logging.ZapEventLogger
 	RequestLogger *logging.ZapEventLogger
 	APIErrorFunc  func(err error, status int) error
+	// WebhookStatusFunc returns the status of a pin reported to
+	// webhooks. WebhookStatus is used when not set.
+	WebhookStatusFunc func(types.GlobalPinInfo) string
 
 	// Listen address for the HTTP REST API endpoint.
 	HTTPListenAddr []ma.Multiaddr
@@ -57,6 +65,15 @@ type Config struct {
 	// SSLKeyFile. We track it so we can write it in the JSON.
 	PathSSLKeyFile string
 
+	// PathClientCAFile is a path to a bundle of CA certificates used to
+	// verify the certificates of HTTPS clients. Clients presenting a
+	// valid certificate are authenticated without further credentials.
+	PathClientCAFile string
+
+	// ClientCertRequired makes the HTTPS endpoint reject clients which do
+	// not present a certificate signed by one of the client CAs.
+	ClientCertRequired bool
+
 	// Maximum duration before timing out reading a full request
 	ReadTimeout time.Duration
 
@@ -86,12 +103,99 @@ type Config struct {
 	// which are authorized to use Basic Authentication
 	BasicAuthCredentials map[string]string
 
+	// BasicAuthScopes restricts what basic auth users (and the tokens
+	// they issue) are allowed to do, by granting them the listed scopes
+	// only. Users without an entry are granted all scopes.
+	BasicAuthScopes map[string][]Scope
+
+	// ClientCertScopes grants the listed scopes to the clients
+	// authenticated with a certificate with the given Common Name. Other
+	// certificates are granted all scopes.
+	ClientCertScopes map[string][]Scope
+
+	// EnforcePinOwnership only lets the identity which created a pin
+	// unpin or modify it. Clients with the admin scope can override it
+	// in every request. In the Pinning Service API, requesting a pin
+	// owned by somebody else adds a request without modifying it, and
+	// clients can always remove their own requests. The IPFS proxy
+	// does not authenticate its clients and has an option of its own.
+	EnforcePinOwnership bool
+
+	// JWTSecret enables the verification of bearer tokens issued by an
+	// identity provider and signed with HS256 using this secret. Tokens
+	// from identity providers must grant scopes explicitly in their
+	// "scope" or "scopes" claims, or they are not allowed anything.
+	JWTSecret string
+
+	// PathJWTPublicKeyFile is a path to a PEM-encoded RSA public key used
+	// to verify bearer tokens signed with RS256. JWTPublicKey is the
+	// loaded key.
+	PathJWTPublicKeyFile string
+	JWTPublicKey         *rsa.PublicKey
+
+	// JWKSURL is the location of a JSON Web Key Set with the RSA keys used
+	// to verify bearer tokens signed with RS256. The keys are reloaded
+	// every JWKSRefreshInterval and when a token is signed with an unknown
+	// key.
+	JWKSURL             string
+	JWKSRefreshInterval time.Duration
+
+	// JWTIssuer and JWTAudience, when set, must match the "iss" and
+	// "aud" claims of the bearer tokens verified with the options above.
+	JWTIssuer   string
+	JWTAudience string
+
 	// HTTPLogFile is path of the file that would save HTTP API logs. If this
 	// path is empty, HTTP logs would be sent to standard output. This path
 	// should either be absolute or relative to cluster base directory. Its
 	// default value is empty.
 	HTTPLogFile string
 
+	// RateLimit is the sustained number of requests per second allowed
+	// for each authenticated identity (basic auth user, token issuer,
+	// client certificate or identity provider subject), or for each
+	// client address when authentication is disabled. Requests over the
+	// limit are answered with 429 (Too Many Requests). 0 disables rate
+	// limiting.
+	RateLimit float64
+
+	// RateLimitScopes sets the rate limit of the clients granted the
+	// given scopes, instead of RateLimit. Clients granted several of
+	// them get the highest limit. A limit of 0 lifts the limit for the
+	// scope.
+	RateLimitScopes map[Scope]float64
+
+	// RateLimitBurst is the number of requests that a client can make at
+	// once before being limited to RateLimit. Values below 1 are treated as
+	// 1.
+	RateLimitBurst int
+
+	// Webhooks is a list of URLs which are notified with a POST request
+	// when the status of a pin changes to pinning, pinned, failed or
+	// removed. The status of a pin is checked when the pin tracker of
+	// any peer reports a change.
+	Webhooks []string
+
+	// WebhookSecret, when set, is used to sign the body of webhook
+	// notifications with HMAC-SHA256. The signature is sent in the
+	// X-Cluster-Signature header.
+	WebhookSecret string
+
+	// WebhookMaxRetries is the number of times that a failed webhook
+	// notification is retried.
+	WebhookMaxRetries int
+
+	// IdempotencyWindow is how long the responses to mutating requests
+	// with an Idempotency-Key header are remembered, so that retried
+	// requests are not executed twice. 0 disables idempotency keys.
+	IdempotencyWindow time.Duration
+
+	// Compression enables gzip and deflate compression of the responses
+	// of at least CompressionMinSize bytes, for clients accepting them.
+	// Configurations without the option keep the default of the API.
+	Compression        bool
+	CompressionMinSize int
+
 	// Headers provides customization for the headers returned
 	// by the API on existing routes.
 	Headers map[string][]string
@@ -112,6 +216,8 @@ type jsonConfig struct {
 	HTTPListenMultiaddress config.Strings `json:"http_listen_multiaddress"`
 	SSLCertFile            string         `json:"ssl_cert_file,omitempty"`
 	SSLKeyFile             string         `json:"ssl_key_file,omitempty"`
+	ClientCAFile           string         `json:"client_ca_file,omitempty"`
+	ClientCertRequired     bool           `json:"client_cert_required,omitempty"`
 	ReadTimeout            string         `json:"read_timeout"`
 	ReadHeaderTimeout      string         `json:"read_header_timeout"`
 	WriteTimeout           string         `json:"write_timeout"`
@@ -123,8 +229,26 @@ type jsonConfig struct {
 	PrivateKey               string         `json:"private_key,omitempty" hidden:"true"`
 
 	BasicAuthCredentials map[string]string   `json:"basic_auth_credentials"  hidden:"true"`
+	BasicAuthScopes      map[string][]Scope  `json:"basic_auth_scopes,omitempty"`
+	ClientCertScopes     map[string][]Scope  `json:"client_cert_scopes,omitempty"`
+	EnforcePinOwnership  bool                `json:"enforce_pin_ownership,omitempty"`
+	JWTSecret            string              `json:"jwt_secret,omitempty" hidden:"true"`
+	JWTPublicKeyFile     string              `json:"jwt_public_key_file,omitempty"`
+	JWKSURL              string              `json:"jwt_jwks_url,omitempty"`
+	JWKSRefreshInterval  string              `json:"jwt_jwks_refresh_interval,omitempty"`
+	JWTIssuer            string              `json:"jwt_issuer,omitempty"`
+	JWTAudience          string              `json:"jwt_audience,omitempty"`
 	HTTPLogFile          string              `json:"http_log_file"`
 	Headers              map[string][]string `json:"headers"`
+	RateLimit            float64             `json:"rate_limit,omitempty"`
+	RateLimitBurst       int                 `json:"rate_limit_burst,omitempty"`
+	RateLimitScopes      map[Scope]float64   `json:"rate_limit_scopes,omitempty"`
+	Webhooks             []string            `json:"webhooks,omitempty"`
+	WebhookSecret        string              `json:"webhook_secret,omitempty" hidden:"true"`
+	WebhookMaxRetries    int                 `json:"webhook_max_retries,omitempty"`
+	IdempotencyWindow    string              `json:"idempotency_window"`
+	Compression          *bool               `json:"compression,omitempty"`
+	CompressionMinSize   int                 `json:"compression_min_size"`
 
 	CORSAllowedOrigins   []string `json:"cors_allowed_origins"`
 	CORSAllowedMethods   []string `json:"cors_allowed_methods"`
@@ -184,8 +308,70 @@ func (cfg *Config) Validate() error {
 		return errors.New(cfg.ConfigKey + ".basic_auth_creds should be null or have at least one entry")
 	case (cfg.PathSSLCertFile != "" || cfg.PathSSLKeyFile != "") && cfg.TLS == nil:
 		return errors.New(cfg.ConfigKey + ": missing TLS configuration")
+	case (cfg.PathClientCAFile != "" || cfg.ClientCertRequired) && (cfg.TLS == nil || cfg.TLS.ClientCAs == nil):
+		return errors.New(cfg.ConfigKey + ".client_ca_file needs ssl_cert_file and ssl_key_file to be set")
+	case cfg.PathClientCAFile != "" && !cfg.ClientCertRequired && cfg.BasicAuthCredentials == nil:
+		// Otherwise clients without certificates would not need any
+		// credentials.
+		return errors.New(cfg.ConfigKey + ".client_cert_required must be set when basic_auth_credentials are not")
 	case (cfg.CORSMaxAge < 0):
 		return errors.New(cfg.ConfigKey + ".cors_max_age is invalid")
+	case cfg.RateLimit < 0:
+		return errors.New(cfg.ConfigKey + ".rate_limit is invalid")
+	case cfg.RateLimitBurst < 0:
+		return errors.New(cfg.ConfigKey + ".rate_limit_burst is invalid")
+	case cfg.PathJWTPublicKeyFile != "" && cfg.JWTPublicKey == nil:
+		return errors.New(cfg.ConfigKey + ": missing JWT public key")
+	case cfg.JWKSURL != "" && cfg.JWKSRefreshInterval <= 0:
+		return errors.New(cfg.ConfigKey + ".jwt_jwks_refresh_interval is invalid")
+	case cfg.WebhookMaxRetries < 0:
+		return errors.New(cfg.ConfigKey + ".webhook_max_retries is invalid")
+	case cfg.IdempotencyWindow < 0:
+		return errors.New(cfg.ConfigKey + ".idempotency_window is invalid")
+	case cfg.CompressionMinSize < 0:
+		return errors.New(cfg.ConfigKey + ".compression_min_size is invalid")
+	}
+
+	for user, scopes := range cfg.BasicAuthScopes {
+		if _, ok := cfg.BasicAuthCredentials[user]; !ok {
+			return fmt.Errorf("%s.basic_auth_scopes: unknown user %q", cfg.ConfigKey, user)
+		}
+		for _, s := range scopes {
+			if !s.Valid() {
+				return fmt.Errorf("%s.basic_auth_scopes: invalid scope %q for user %q", cfg.ConfigKey, s, user)
+			}
+		}
+	}
+
+	if cfg.JWKSURL != "" {
+		parsed, err := url.Parse(cfg.JWKSURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return fmt.Errorf("%s.jwt_jwks_url: invalid URL %q", cfg.ConfigKey, cfg.JWKSURL)
+		}
+	}
+
+	for s, rate := range cfg.RateLimitScopes {
+		if !s.Valid() {
+			return fmt.Errorf("%s.rate_limit_scopes: invalid scope %q", cfg.ConfigKey, s)
+		}
+		if rate < 0 {
+			return fmt.Errorf("%s.rate_limit_scopes: invalid rate limit for %q", cfg.ConfigKey, s)
+		}
+	}
+
+	for cn, scopes := range cfg.ClientCertScopes {
+		for _, s := range scopes {
+			if !s.Valid() {
+				return fmt.Errorf("%s.client_cert_scopes: invalid scope %q for %q", cfg.ConfigKey, s, cn)
+			}
+		}
+	}
+
+	for _, u := range cfg.Webhooks {
+		parsed, err := url.Parse(u)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return fmt.Errorf("%s.webhooks: invalid URL %q", cfg.ConfigKey, u)
+		}
 	}
 
 	return cfg.validateLibp2p()
@@ -236,8 +422,37 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 
 	// Other options
 	cfg.BasicAuthCredentials = jcfg.BasicAuthCredentials
+	cfg.BasicAuthScopes = jcfg.BasicAuthScopes
+	cfg.ClientCertScopes = jcfg.ClientCertScopes
+	cfg.EnforcePinOwnership = jcfg.EnforcePinOwnership
+	cfg.JWTSecret = jcfg.JWTSecret
+	cfg.JWKSURL = jcfg.JWKSURL
+	cfg.JWTIssuer = jcfg.JWTIssuer
+	cfg.JWTAudience = jcfg.JWTAudience
+	err = cfg.jwtPublicKeyOptions(jcfg)
+	if err != nil {
+		return err
+	}
 	cfg.HTTPLogFile = jcfg.HTTPLogFile
 	cfg.Headers = jcfg.Headers
+	cfg.RateLimit = jcfg.RateLimit
+	cfg.RateLimitBurst = jcfg.RateLimitBurst
+	cfg.RateLimitScopes = jcfg.RateLimitScopes
+	cfg.Webhooks = jcfg.Webhooks
+	cfg.WebhookSecret = jcfg.WebhookSecret
+	config.SetIfNotDefault(jcfg.WebhookMaxRetries, &cfg.WebhookMaxRetries)
+	if jcfg.Compression != nil {
+		cfg.Compression = *jcfg.Compression
+	}
+	config.SetIfNotDefault(jcfg.CompressionMinSize, &cfg.CompressionMinSize)
+	err = config.ParseDurations(
+		cfg.ConfigKey,
+		&config.DurationOpt{Duration: jcfg.JWKSRefreshInterval, Dst: &cfg.JWKSRefreshInterval, Name: "jwt_jwks_refresh_interval"},
+		&config.DurationOpt{Duration: jcfg.IdempotencyWindow, Dst: &cfg.IdempotencyWindow, Name: "idempotency_window"},
+	)
+	if err != nil {
+		return err
+	}
 
 	return cfg.Validate()
 }
@@ -289,6 +504,8 @@ func (cfg *Config) loadHTTPOptions(jcfg *jsonConfig) error {
 func (cfg *Config) tlsOptions(jcfg *jsonConfig) error {
 	cert := jcfg.SSLCertFile
 	key := jcfg.SSLKeyFile
+	cfg.PathClientCAFile = jcfg.ClientCAFile
+	cfg.ClientCertRequired = jcfg.ClientCertRequired
 
 	if cert+key == "" {
 		return nil
@@ -313,10 +530,47 @@ func (cfg *Config) tlsOptions(jcfg *jsonConfig) error {
 	if err != nil {
 		return err
 	}
+
+	if ca := jcfg.ClientCAFile; ca != "" {
+		if !filepath.IsAbs(ca) {
+			ca = filepath.Join(cfg.BaseDir, ca)
+		}
+		cfg.Logger.Debug("client CA path: ", ca)
+		err = setClientCAs(tlsCfg, ca, jcfg.ClientCertRequired)
+		if err != nil {
+			return err
+		}
+	}
+
 	cfg.TLS = tlsCfg
 	return nil
 }
 
+func (cfg *Config) jwtPublicKeyOptions(jcfg *jsonConfig) error {
+	path := jcfg.JWTPublicKeyFile
+	cfg.PathJWTPublicKeyFile = path
+	cfg.JWTPublicKey = nil
+	if path == "" {
+		return nil
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.BaseDir, path)
+	}
+	cfg.Logger.Debug("JWT public key path: ", path)
+
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return errors.New("Error loading JWT public key: " + err.Error())
+	}
+	key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+	if err != nil {
+		return errors.New("Error loading JWT public key: " + err.Error())
+	}
+	cfg.JWTPublicKey = key
+	return nil
+}
+
 func (cfg *Config) loadLibp2pOptions(jcfg *jsonConfig) error {
 	if addresses := jcfg.Libp2pListenMultiaddress; len(addresses) > 0 {
 		cfg.Libp2pListenAddr = make([]ma.Multiaddr, 0, len(addresses))
@@ -382,18 +636,39 @@ func (cfg *Config) toJSONConfig() (jcfg *jsonConfig, err error) {
 		libp2pAddresses = append(libp2pAddresses, addr.String())
 	}
 
+	compression := cfg.Compression
+
 	jcfg = &jsonConfig{
 		HTTPListenMultiaddress: httpAddresses,
 		SSLCertFile:            cfg.PathSSLCertFile,
 		SSLKeyFile:             cfg.PathSSLKeyFile,
+		ClientCAFile:           cfg.PathClientCAFile,
+		ClientCertRequired:     cfg.ClientCertRequired,
 		ReadTimeout:            cfg.ReadTimeout.String(),
 		ReadHeaderTimeout:      cfg.ReadHeaderTimeout.String(),
 		WriteTimeout:           cfg.WriteTimeout.String(),
 		IdleTimeout:            cfg.IdleTimeout.String(),
 		MaxHeaderBytes:         cfg.MaxHeaderBytes,
 		BasicAuthCredentials:   cfg.BasicAuthCredentials,
+		BasicAuthScopes:        cfg.BasicAuthScopes,
+		ClientCertScopes:       cfg.ClientCertScopes,
+		EnforcePinOwnership:    cfg.EnforcePinOwnership,
+		JWTSecret:              cfg.JWTSecret,
+		JWTPublicKeyFile:       cfg.PathJWTPublicKeyFile,
+		JWKSURL:                cfg.JWKSURL,
+		JWTIssuer:              cfg.JWTIssuer,
+		JWTAudience:            cfg.JWTAudience,
 		HTTPLogFile:            cfg.HTTPLogFile,
 		Headers:                cfg.Headers,
+		RateLimit:              cfg.RateLimit,
+		RateLimitBurst:         cfg.RateLimitBurst,
+		RateLimitScopes:        cfg.RateLimitScopes,
+		Webhooks:               cfg.Webhooks,
+		WebhookSecret:          cfg.WebhookSecret,
+		WebhookMaxRetries:      cfg.WebhookMaxRetries,
+		IdempotencyWindow:      cfg.IdempotencyWindow.String(),
+		Compression:            &compression,
+		CompressionMinSize:     cfg.CompressionMinSize,
 		CORSAllowedOrigins:     cfg.CORSAllowedOrigins,
 		CORSAllowedMethods:     cfg.CORSAllowedMethods,
 		CORSAllowedHeaders:     cfg.CORSAllowedHeaders,
@@ -402,6 +677,10 @@ func (cfg *Config) toJSONConfig() (jcfg *jsonConfig, err error) {
 		CORSMaxAge:             cfg.CORSMaxAge.String(),
 	}
 
+	if cfg.JWKSURL != "" {
+		jcfg.JWKSRefreshInterval = cfg.JWKSRefreshInterval.String()
+	}
+
 	if cfg.ID != "" {
 		jcfg.ID = cfg.ID.String()
 	}
@@ -444,6 +723,33 @@ func (cfg *Config) ToDisplayJSON() ([]byte, error) {
 	return config.DisplayJSON(jcfg)
 }
 
+// MergeJSON adds the fields of the given component-specific configuration
+// object, encoded with the given function, to the JSON object produced by
+// the common configuration. It allows components to store their own options
+// in the same configuration section.
+func MergeJSON(commonRaw []byte, own interface{}, marshal func(interface{}) ([]byte, error)) ([]byte, error) {
+	fields := make(map[string]json.RawMessage)
+	err := json.Unmarshal(commonRaw, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	ownRaw, err := marshal(own)
+	if err != nil {
+		return nil, err
+	}
+	ownFields := make(map[string]json.RawMessage)
+	err = json.Unmarshal(ownRaw, &ownFields)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range ownFields {
+		fields[k] = v
+	}
+	return config.DefaultJSONMarshal(fields)
+}
+
 // LogWriter returns a writer to write logs to. If a log path is configured,
 // it creates a file.  Otherwise, uses the given logger.
 func (cfg *Config) LogWriter() (io.Writer, error) {
@@ -459,6 +765,25 @@ func (cfg *Config) LogWriter() (io.Writer, error) {
 	}, nil
 }
 
+// setClientCAs makes the TLS configuration verify client certificates with
+// the CAs in the given file, and require them if told so.
+func setClientCAs(tlsCfg *tls.Config, caFile string, required bool) error {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return errors.New("Error loading client CA certificates: " + err.Error())
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return errors.New("Error loading client CA certificates: no certificates found in " + caFile)
+	}
+	tlsCfg.ClientCAs = pool
+	tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	if required {
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return nil
+}
+
 func newTLSConfig(certFile, keyFile string) (*tls.Config, error) {
 	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
 	if err != nil {
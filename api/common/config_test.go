@@ -35,6 +35,7 @@ var (
 	}
 	DefaultCORSAllowCredentials = true
 	DefaultCORSMaxAge           time.Duration // 0. Means always.
+	DefaultWebhookMaxRetries    = 3
 )
 
 func defaultFunc(cfg *Config) error {
@@ -50,6 +51,8 @@ func defaultFunc(cfg *Config) error {
 	cfg.HTTPListenAddr = addrs
 	cfg.PathSSLCertFile = ""
 	cfg.PathSSLKeyFile = ""
+	cfg.PathClientCAFile = ""
+	cfg.ClientCertRequired = false
 	cfg.ReadTimeout = DefaultReadTimeout
 	cfg.ReadHeaderTimeout = DefaultReadHeaderTimeout
 	cfg.WriteTimeout = DefaultWriteTimeout
@@ -63,6 +66,15 @@ func defaultFunc(cfg *Config) error {
 
 	// Auth
 	cfg.BasicAuthCredentials = nil
+	cfg.BasicAuthScopes = nil
+	cfg.ClientCertScopes = nil
+	cfg.JWTSecret = ""
+	cfg.PathJWTPublicKeyFile = ""
+	cfg.JWTPublicKey = nil
+	cfg.JWKSURL = ""
+	cfg.JWKSRefreshInterval = DefaultJWKSRefreshInterval
+	cfg.JWTIssuer = ""
+	cfg.JWTAudience = ""
 
 	// Logs
 	cfg.HTTPLogFile = ""
@@ -70,6 +82,15 @@ func defaultFunc(cfg *Config) error {
 	// Headers
 	cfg.Headers = DefaultHeaders
 
+	// Rate limiting (disabled)
+	cfg.RateLimit = 0
+	cfg.RateLimitBurst = 0
+
+	// Webhooks
+	cfg.Webhooks = nil
+	cfg.WebhookSecret = ""
+	cfg.WebhookMaxRetries = DefaultWebhookMaxRetries
+
 	cfg.CORSAllowedOrigins = DefaultCORSAllowedOrigins
 	cfg.CORSAllowedMethods = DefaultCORSAllowedMethods
 	cfg.CORSAllowedHeaders = DefaultCORSAllowedHeaders
@@ -173,6 +194,52 @@ func TestLoadJSON(t *testing.T) {
 		t.Error("expected error with empty basic auth map")
 	}
 
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.BasicAuthScopes = map[string][]Scope{"nobody": {ScopeRead}}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with scopes for an unknown user")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.BasicAuthCredentials = map[string]string{"user": "pass"}
+	j.BasicAuthScopes = map[string][]Scope{"user": {"superpowers"}}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with an invalid scope")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.ClientCAFile = "ca.crt"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with a client CA and no TLS configuration")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.JWKSURL = "ftp://keys"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with a bad JWKS URL")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.JWTPublicKeyFile = "notthere.pem"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with a missing JWT public key")
+	}
+
 	j = &jsonConfig{}
 	json.Unmarshal(cfgJSON, j)
 	j.SSLCertFile = "abc"
@@ -217,6 +284,49 @@ func TestLoadJSON(t *testing.T) {
 	if err == nil {
 		t.Error("expected error with MaxHeaderBytes")
 	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.RateLimit = -1
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with rate_limit")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.RateLimit = 5
+	j.RateLimitBurst = 10
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RateLimit != 5 || cfg.RateLimitBurst != 10 {
+		t.Error("error parsing rate limits")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.RateLimitScopes = map[Scope]float64{ScopeAdmin: 0, ScopeRead: 2}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RateLimitScopes[ScopeRead] != 2 {
+		t.Error("error parsing rate_limit_scopes")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.RateLimitScopes = map[Scope]float64{"nope": 1}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with an invalid scope in rate_limit_scopes")
+	}
 }
 
 func TestApplyEnvVars(t *testing.T) {
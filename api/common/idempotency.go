@@ -0,0 +1,204 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header used by clients to make
+// mutating requests idempotent. Requests repeating the key of a previous
+// one within the configured window are not executed again and receive the
+// original response instead.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on responses which are replayed from an
+// earlier request with the same idempotency key.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength is the maximum accepted length of idempotency
+// keys.
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotentResponseSize is the maximum size of the response bodies that
+// are remembered. Larger responses, like those streamed when adding, are
+// not, so the requests are executed again when retried.
+const maxIdempotentResponseSize = 1 << 20 // 1 MiB
+
+// idempotencyCache remembers the responses to requests with an idempotency
+// key during the configured window.
+type idempotencyCache struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]*idempotentResponse
+	lastSweep time.Time
+}
+
+// idempotentResponse is a remembered response. done is false while the
+// first request with the key is being served.
+type idempotentResponse struct {
+	done    bool
+	expires time.Time
+	status  int
+	header  http.Header
+	body    []byte
+}
+
+func newIdempotencyCache(window time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		window:    window,
+		entries:   make(map[string]*idempotentResponse),
+		lastSweep: time.Now(),
+	}
+}
+
+// start returns the response remembered for the key, if any. Otherwise, it
+// reserves the key for a request which is about to be served and returns
+// nil. An error is returned when another request with the same key is
+// being served.
+func (ic *idempotencyCache) start(key string, now time.Time) (*idempotentResponse, error) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	if now.Sub(ic.lastSweep) > ic.window {
+		ic.sweep(now)
+	}
+
+	resp, ok := ic.entries[key]
+	switch {
+	case ok && !resp.done:
+		return nil, errors.New("a request with the same idempotency key is in progress")
+	case ok && now.Before(resp.expires):
+		return resp, nil
+	}
+	ic.entries[key] = &idempotentResponse{}
+	return nil, nil
+}
+
+// finish remembers the response to the request which reserved the key, or
+// forgets the key when the response should not be replayed.
+func (ic *idempotencyCache) finish(key string, resp *idempotentResponse, now time.Time) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	if resp == nil {
+		delete(ic.entries, key)
+		return
+	}
+	resp.done = true
+	resp.expires = now.Add(ic.window)
+	ic.entries[key] = resp
+}
+
+// sweep removes the expired responses.
+func (ic *idempotencyCache) sweep(now time.Time) {
+	for k, resp := range ic.entries {
+		if resp.done && !now.Before(resp.expires) {
+			delete(ic.entries, k)
+		}
+	}
+	ic.lastSweep = now
+}
+
+// responseRecorder keeps a copy of the response written to the client.
+type responseRecorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	if !rr.overflow {
+		if rr.body.Len()+len(b) > maxIdempotentResponseSize {
+			rr.overflow = true
+			rr.body = bytes.Buffer{}
+		} else {
+			rr.body.Write(b)
+		}
+	}
+	return rr.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, as streaming responses need it.
+func (rr *responseRecorder) Flush() {
+	if flusher, ok := rr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// response returns the recorded response, or nil when it should not be
+// replayed: when it was too large, or when it failed for reasons which may
+// not happen again (server errors and rate limiting).
+func (rr *responseRecorder) response() *idempotentResponse {
+	status := rr.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if rr.overflow || status >= 500 || status == http.StatusTooManyRequests {
+		return nil
+	}
+	// The body is recorded before being compressed, which is done again
+	// according to the request when replaying it.
+	header := rr.Header().Clone()
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+	header.Del("Vary")
+	return &idempotentResponse{
+		status: status,
+		header: header,
+		body:   rr.body.Bytes(),
+	}
+}
+
+// idempotentHandler wraps the handler of a mutating route so that requests
+// with an idempotency key are only executed once per client during the
+// idempotency window.
+func (api *API) idempotentHandler(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idemKey := r.Header.Get(IdempotencyKeyHeader)
+		if idemKey == "" {
+			h(w, r)
+			return
+		}
+		if len(idemKey) > maxIdempotencyKeyLength {
+			api.SendResponse(w, http.StatusBadRequest, errors.New("idempotency key is too long"), nil)
+			return
+		}
+
+		// Keys are scoped to the client and the request.
+		key := rateLimitKey(r) + " " + r.Method + " " + r.URL.RequestURI() + " " + idemKey
+		resp, err := api.idempotency.start(key, time.Now())
+		if err != nil {
+			api.SendResponse(w, http.StatusConflict, err, nil)
+			return
+		}
+		if resp != nil {
+			for k, v := range resp.header {
+				w.Header()[k] = v
+			}
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(resp.status)
+			w.Write(resp.body)
+			return
+		}
+
+		rr := &responseRecorder{ResponseWriter: w}
+		defer func() {
+			api.idempotency.finish(key, rr.response(), time.Now())
+		}()
+		h(rr, r)
+	}
+}
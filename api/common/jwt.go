@@ -0,0 +1,223 @@
+package common
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+// DefaultJWKSRefreshInterval is how often the keys published at the JWKS
+// URL are reloaded when not configured.
+var DefaultJWKSRefreshInterval = time.Hour
+
+// jwksMinRefreshInterval limits how often the JWKS URL is requested when a
+// token is signed with an unknown key.
+var jwksMinRefreshInterval = time.Minute
+
+// jwtClaims are the claims understood by the jwtVerifier. Scopes can be
+// given as a space-separated "scope" claim (as in OAuth 2.0) or as a list in
+// "scopes". They must be granted explicitly: tokens without them are not
+// allowed to do anything.
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	Scope  string   `json:"scope,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// jwtVerifier validates bearer tokens issued by an external identity
+// provider, signed with HS256 using the configured secret, or with RS256
+// using the configured public key or the keys published at the JWKS URL.
+type jwtVerifier struct {
+	cfg    *Config
+	client *http.Client
+
+	mu        sync.RWMutex
+	jwks      map[string]*rsa.PublicKey
+	lastFetch time.Time
+}
+
+func newJWTVerifier(cfg *Config) *jwtVerifier {
+	return &jwtVerifier{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// verify validates the token and returns the identity of its subject and
+// the scopes that it grants.
+func (jv *jwtVerifier) verify(ctx context.Context, tokenString string) (string, []Scope, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods(jv.methods()))
+	claims := &jwtClaims{}
+	token, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return jv.key(ctx, token)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if !token.Valid {
+		return "", nil, errors.New("invalid token")
+	}
+
+	if iss := jv.cfg.JWTIssuer; iss != "" && !claims.VerifyIssuer(iss, true) {
+		return "", nil, errors.New("unexpected token issuer")
+	}
+	if aud := jv.cfg.JWTAudience; aud != "" && !claims.VerifyAudience(aud, true) {
+		return "", nil, errors.New("unexpected token audience")
+	}
+	return "token:" + claims.Subject, claims.scopes(), nil
+}
+
+func (jv *jwtVerifier) methods() []string {
+	var methods []string
+	if jv.cfg.JWTSecret != "" {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if jv.cfg.JWTPublicKey != nil || jv.cfg.JWKSURL != "" {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	return methods
+}
+
+// key returns the key to verify the given token with.
+func (jv *jwtVerifier) key(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return []byte(jv.cfg.JWTSecret), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" && jv.cfg.JWTPublicKey != nil {
+		return jv.cfg.JWTPublicKey, nil
+	}
+	if jv.cfg.JWKSURL == "" {
+		return nil, errors.New("no key to verify the token")
+	}
+
+	key, ok := jv.jwksKey(kid)
+	// Keys are rotated by publishing new ones, so reload them when the
+	// token is signed with an unknown one.
+	if !ok || jv.jwksExpired() {
+		if err := jv.refresh(ctx); err != nil && !ok {
+			return nil, err
+		}
+		key, ok = jv.jwksKey(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// jwksKey returns the JWKS key with the given ID. Tokens without a key ID
+// can only be verified when there is a single key.
+func (jv *jwtVerifier) jwksKey(kid string) (*rsa.PublicKey, bool) {
+	jv.mu.RLock()
+	defer jv.mu.RUnlock()
+	if kid == "" && len(jv.jwks) == 1 {
+		for _, key := range jv.jwks {
+			return key, true
+		}
+	}
+	key, ok := jv.jwks[kid]
+	return key, ok
+}
+
+func (jv *jwtVerifier) jwksExpired() bool {
+	jv.mu.RLock()
+	defer jv.mu.RUnlock()
+	return time.Since(jv.lastFetch) > jv.cfg.JWKSRefreshInterval
+}
+
+// refresh reloads the keys from the JWKS URL, unless it was done very
+// recently. The keys are fetched without holding the lock, so that tokens
+// can be verified with the current keys in the meantime.
+func (jv *jwtVerifier) refresh(ctx context.Context) error {
+	jv.mu.Lock()
+	if time.Since(jv.lastFetch) < jwksMinRefreshInterval {
+		jv.mu.Unlock()
+		return nil
+	}
+	jv.lastFetch = time.Now()
+	jv.mu.Unlock()
+
+	keys, err := fetchJWKS(ctx, jv.client, jv.cfg.JWKSURL)
+	if err != nil {
+		jv.cfg.Logger.Errorf("error fetching JWKS from %s: %s", jv.cfg.JWKSURL, err)
+		return err
+	}
+	jv.mu.Lock()
+	jv.jwks = keys
+	jv.mu.Unlock()
+	return nil
+}
+
+// fetchJWKS returns the RSA keys in the JSON Web Key Set at the given URL,
+// by key ID.
+func fetchJWKS(ctx context.Context, client *http.Client, url string) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: bad modulus: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: bad exponent: %w", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+// scopes returns the known scopes listed in the claims, which may be none.
+func (c *jwtClaims) scopes() []Scope {
+	names := append(strings.Fields(c.Scope), c.Scopes...)
+	scopes := []Scope{}
+	for _, name := range names {
+		if s := Scope(name); s.Valid() {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
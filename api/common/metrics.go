@@ -0,0 +1,106 @@
+package common
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/observations"
+
+	mux "github.com/gorilla/mux"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// inFlight tracks the number of requests being served by every route so
+// that it can be recorded as a gauge.
+var inFlight = struct {
+	mu sync.Mutex
+	n  map[string]int64
+}{n: make(map[string]int64)}
+
+// statusWriter remembers the status code sent in a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, as streaming responses need it.
+func (sw *statusWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// InstrumentHandler wraps the given handler so that it records the number
+// of requests, their latency and the number of requests in flight for the
+// given API and route.
+func InstrumentHandler(apiName, route string, h http.Handler) http.Handler {
+	mutators := []tag.Mutator{
+		tag.Upsert(observations.APIKey, apiName),
+		tag.Upsert(observations.RouteKey, route),
+	}
+	key := apiName + "/" + route
+
+	recordInFlight := func(r *http.Request, delta int64) {
+		inFlight.mu.Lock()
+		defer inFlight.mu.Unlock()
+		inFlight.n[key] += delta
+		stats.RecordWithTags(r.Context(), mutators, observations.APIRequestsInFlight.M(inFlight.n[key]))
+	}
+
+	wrap := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recordInFlight(r, 1)
+
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			recordInFlight(r, -1)
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			latency := float64(time.Since(start)) / float64(time.Millisecond)
+			stats.RecordWithTags(
+				r.Context(),
+				[]tag.Mutator{
+					mutators[0],
+					mutators[1],
+					tag.Upsert(observations.StatusCodeKey, strconv.Itoa(status)),
+				},
+				observations.APIRequestLatency.M(latency),
+			)
+		}()
+		h.ServeHTTP(sw, r)
+	}
+	return http.HandlerFunc(wrap)
+}
+
+// MetricsMiddleware returns a router middleware which instruments the
+// matched routes with InstrumentHandler, using the route names.
+func MetricsMiddleware(apiName string) mux.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := "unknown"
+			if cur := mux.CurrentRoute(r); cur != nil && cur.GetName() != "" {
+				route = cur.GetName()
+			}
+			InstrumentHandler(apiName, route, h).ServeHTTP(w, r)
+		})
+	}
+}
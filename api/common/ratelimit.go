@@ -0,0 +1,182 @@
+package common
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimitSweepInterval controls how often buckets which have filled up
+// again are forgotten.
+const rateLimitSweepInterval = time.Minute
+
+// rateLimiter implements a token bucket for every client. Buckets hold up to
+// burst tokens and are refilled at rate tokens per second. Every request
+// takes one token.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// refill updates the tokens in the bucket up to the given time.
+func (rl *rateLimiter) refill(b *bucket, now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(rl.burst, b.tokens+elapsed*rl.rate)
+		b.last = now
+	}
+}
+
+// allow takes a token from the bucket for the given key. When none is left,
+// it returns false and the time until the next one is available.
+func (rl *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if now.Sub(rl.lastSweep) > rateLimitSweepInterval {
+		rl.sweep(now)
+	}
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: rl.burst, last: now}
+		rl.buckets[key] = b
+	}
+	rl.refill(b, now)
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep removes the buckets which are full, as they are equivalent to new
+// ones.
+func (rl *rateLimiter) sweep(now time.Time) {
+	for k, b := range rl.buckets {
+		rl.refill(b, now)
+		if b.tokens >= rl.burst {
+			delete(rl.buckets, k)
+		}
+	}
+	rl.lastSweep = now
+}
+
+// rateLimitKey identifies the client making the request by the identity it
+// authenticated with, so that every token issued to the same user shares a
+// bucket, or by its address when it is not authenticated.
+func rateLimitKey(r *http.Request) string {
+	if identity, ok := RequestIdentity(r); ok && identity != "" {
+		return "identity:" + identity
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// libp2p requests use the peer ID as remote address.
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+// rateFor returns the rate limit for the client making the request: the
+// highest among the limits of the scopes that it was granted, when any of
+// them have one, or the default one otherwise. 0 means no limit.
+func (cfg *Config) rateFor(r *http.Request) float64 {
+	scopes, _ := RequestScopes(r)
+	rate, found := 0.0, false
+	for _, s := range scopes {
+		scopeRate, ok := cfg.RateLimitScopes[s]
+		if !ok {
+			continue
+		}
+		if scopeRate == 0 {
+			return 0
+		}
+		if !found || scopeRate > rate {
+			rate, found = scopeRate, true
+		}
+	}
+	if found {
+		return rate
+	}
+	return cfg.RateLimit
+}
+
+// rateLimitHandler rejects the requests of clients that go over the
+// configured rate limit with 429 (Too Many Requests). Requests only reach
+// this handler when authenticated, so that clients cannot take over the
+// buckets of others.
+func (api *API) rateLimitHandler(h http.Handler) http.Handler {
+	if api.config.RateLimit <= 0 && len(api.config.RateLimitScopes) == 0 {
+		return h
+	}
+
+	// There is a limiter for every configured rate.
+	limiters := make(map[float64]*rateLimiter)
+	for _, rate := range append([]float64{api.config.RateLimit}, scopeRates(api.config.RateLimitScopes)...) {
+		if rate > 0 {
+			limiters[rate] = newRateLimiter(rate, api.config.RateLimitBurst)
+		}
+	}
+
+	wrap := func(w http.ResponseWriter, r *http.Request) {
+		// CORS preflight requests are not limited.
+		if r.Method == http.MethodOptions {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		rl, limited := limiters[api.config.rateFor(r)]
+		if !limited {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		ok, wait := rl.allow(rateLimitKey(r), time.Now())
+		if !ok {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			api.SendResponse(w, http.StatusTooManyRequests, errors.New("too many requests"), nil)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(wrap)
+}
+
+func scopeRates(rates map[Scope]float64) []float64 {
+	var list []float64
+	for _, rate := range rates {
+		list = append(list, rate)
+	}
+	return list
+}
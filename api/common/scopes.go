@@ -0,0 +1,130 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Scope is a capability which can be granted to API credentials. Routes
+// require scopes and credentials without them are refused with 403
+// (Forbidden).
+type Scope string
+
+// Scopes that can be granted to credentials. ScopeAdmin grants all of
+// them.
+const (
+	// ScopeRead allows reading the state of the cluster.
+	ScopeRead Scope = "read"
+	// ScopePin allows pinning and adding content.
+	ScopePin Scope = "pin"
+	// ScopeUnpin allows unpinning content.
+	ScopeUnpin Scope = "unpin"
+	// ScopeAdmin allows everything, including peer management.
+	ScopeAdmin Scope = "admin"
+)
+
+// AllScopes lists the known scopes.
+var AllScopes = []Scope{ScopeRead, ScopePin, ScopeUnpin, ScopeAdmin}
+
+// Valid returns true for known scopes.
+func (s Scope) Valid() bool {
+	for _, known := range AllScopes {
+		if s == known {
+			return true
+		}
+	}
+	return false
+}
+
+// routeScopes returns the scopes required by a route: its Scopes, or
+// ScopeRead for GET and HEAD requests and ScopeAdmin for anything else when
+// they are not set.
+func routeScopes(route Route) []Scope {
+	if len(route.Scopes) > 0 {
+		return route.Scopes
+	}
+	switch route.Method {
+	case http.MethodGet, http.MethodHead:
+		return []Scope{ScopeRead}
+	default:
+		return []Scope{ScopeAdmin}
+	}
+}
+
+type authScopesKey struct{}
+
+// withScopes sets the scopes granted to the client making a request.
+func withScopes(r *http.Request, scopes []Scope) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), authScopesKey{}, scopes))
+}
+
+// RequestScopes returns the scopes granted to the client making the
+// request. ok is false when the request has not been authenticated, which
+// happens when authentication is disabled.
+func RequestScopes(r *http.Request) (scopes []Scope, ok bool) {
+	scopes, ok = r.Context().Value(authScopesKey{}).([]Scope)
+	return
+}
+
+type authIdentityKey struct{}
+
+// withIdentity sets the identity of the client making a request.
+func withIdentity(r *http.Request, identity string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), authIdentityKey{}, identity))
+}
+
+// RequestIdentity returns the identity of the client making the request:
+// "user:<name>" for basic auth users and the tokens issued by the cluster
+// for them, "token:<subject>" for tokens from identity providers and
+// "cert:<common name>" for client certificates. ok is false when the
+// request has not been authenticated.
+func RequestIdentity(r *http.Request) (identity string, ok bool) {
+	identity, ok = r.Context().Value(authIdentityKey{}).(string)
+	return
+}
+
+// CheckScopes returns an error when the request was authenticated with
+// credentials lacking any of the given scopes. Routes check their Scopes
+// before calling their handlers, so this is only needed by handlers
+// requiring further scopes depending on the request.
+func CheckScopes(r *http.Request, required ...Scope) error {
+	granted, ok := RequestScopes(r)
+	if !ok {
+		return nil
+	}
+
+	has := func(s Scope) bool {
+		for _, g := range granted {
+			if g == s || g == ScopeAdmin {
+				return true
+			}
+		}
+		return false
+	}
+	for _, s := range required {
+		if !has(s) {
+			return fmt.Errorf("forbidden: missing %q scope", s)
+		}
+	}
+	return nil
+}
+
+// userScopes returns the scopes granted to a basic auth user. Users without
+// configured scopes are granted all of them.
+func (cfg *Config) userScopes(username string) []Scope {
+	if scopes, ok := cfg.BasicAuthScopes[username]; ok {
+		return scopes
+	}
+	return []Scope{ScopeAdmin}
+}
+
+// certScopes returns the scopes granted to clients authenticated with a
+// certificate with the given Common Name. Certificates without configured
+// scopes are granted all of them.
+func (cfg *Config) certScopes(cn string) []Scope {
+	if scopes, ok := cfg.ClientCertScopes[cn]; ok {
+		return scopes
+	}
+	return []Scope{ScopeAdmin}
+}
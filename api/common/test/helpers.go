@@ -219,6 +219,20 @@ func MakePostWithContentType(t *testing.T, api API, url string, body []byte, con
 	CheckHeaders(t, api.Headers(), url, httpResp.Header)
 }
 
+// MakePatch performs a PATCH request against the API with the given JSON
+// body.
+func MakePatch(t *testing.T, api API, url string, body []byte, resp interface{}) {
+	h := MakeHost(t, api)
+	defer h.Close()
+	c := HTTPClient(t, h, IsHTTPS(url))
+	req, _ := http.NewRequest(http.MethodPatch, url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", ClientOrigin)
+	httpResp, err := c.Do(req)
+	ProcessResp(t, httpResp, err, resp)
+	CheckHeaders(t, api.Headers(), url, httpResp.Header)
+}
+
 // MakeDelete performs a DELETE request against the given API.
 func MakeDelete(t *testing.T, api API, url string, resp interface{}) {
 	h := MakeHost(t, api)
@@ -256,6 +270,19 @@ func MakeStreamingPost(t *testing.T, api API, url string, body io.Reader, conten
 	CheckHeaders(t, api.Headers(), url, httpResp.Header)
 }
 
+// MakeStreamingPut performs a PUT request and uses ProcessStreamingResp
+func MakeStreamingPut(t *testing.T, api API, url string, body io.Reader, contentType string, resp interface{}) {
+	h := MakeHost(t, api)
+	defer h.Close()
+	c := HTTPClient(t, h, IsHTTPS(url))
+	req, _ := http.NewRequest(http.MethodPut, url, body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Origin", ClientOrigin)
+	httpResp, err := c.Do(req)
+	ProcessStreamingResp(t, httpResp, err, resp, false)
+	CheckHeaders(t, api.Headers(), url, httpResp.Header)
+}
+
 // MakeStreamingGet performs a GET request and uses ProcessStreamingResp
 func MakeStreamingGet(t *testing.T, api API, url string, resp interface{}, trailerError bool) {
 	h := MakeHost(t, api)
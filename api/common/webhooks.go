@@ -0,0 +1,439 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Statuses reported in webhook notifications.
+const (
+	WebhookStatusPinning = "pinning"
+	WebhookStatusPinned  = "pinned"
+	WebhookStatusFailed  = "failed"
+	WebhookStatusRemoved = "removed"
+)
+
+// WebhookSignatureHeader is the header carrying the HMAC-SHA256 signature
+// of the body of webhook notifications, as "sha256=<hex>", when a
+// WebhookSecret is configured.
+const WebhookSignatureHeader = "X-Cluster-Signature"
+
+const (
+	webhookQueueSize      = 1024
+	webhookRequestTimeout = 10 * time.Second
+	webhookRetryBackoff   = time.Second
+
+	// webhookResubscribeInterval is how long to wait before subscribing
+	// again to the events of a peer when the subscription ends.
+	webhookResubscribeInterval = 5 * time.Second
+)
+
+// WebhookEvent is the body of webhook notifications. It is sent every time
+// that the status of a pin changes.
+type WebhookEvent struct {
+	Cid            types.Cid `json:"cid"`
+	Name           string    `json:"name,omitempty"`
+	Status         string    `json:"status"`
+	PreviousStatus string    `json:"previous_status,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// WebhookStatus summarizes the status of a pin across peers as one of the
+// statuses reported in webhook notifications. It returns an empty string
+// for pins which should not be reported, e.g. when they are being unpinned.
+// This is the default WebhookStatusFunc.
+func WebhookStatus(gpi types.GlobalPinInfo) string {
+	status := ""
+	for _, pi := range gpi.PeerMap {
+		switch {
+		case pi.Status&types.TrackerStatusError != 0 || pi.Status == types.TrackerStatusUnexpectedlyUnpinned:
+			return WebhookStatusFailed
+		case pi.Status == types.TrackerStatusPinning || pi.Status == types.TrackerStatusPinQueued:
+			status = WebhookStatusPinning
+		case pi.Status == types.TrackerStatusPinned:
+			if status == "" {
+				status = WebhookStatusPinned
+			}
+		case pi.Status == types.TrackerStatusRemote || pi.Status == types.TrackerStatusSharded:
+		default:
+			// unpinning, unpinned etc.
+			return ""
+		}
+	}
+	return status
+}
+
+// webhookTransitions returns the events for the pins whose status differs
+// between prev and cur. Pins missing from cur are reported as removed.
+func webhookTransitions(prev, cur map[types.Cid]WebhookEvent, now time.Time) []WebhookEvent {
+	var events []WebhookEvent
+	for c, ev := range cur {
+		old, ok := prev[c]
+		if ok && old.Status == ev.Status {
+			continue
+		}
+		ev.PreviousStatus = old.Status
+		ev.Timestamp = now
+		events = append(events, ev)
+	}
+	for c, old := range prev {
+		if _, ok := cur[c]; ok {
+			continue
+		}
+		events = append(events, WebhookEvent{
+			Cid:            c,
+			Name:           old.Name,
+			Status:         WebhookStatusRemoved,
+			PreviousStatus: old.Status,
+			Timestamp:      now,
+		})
+	}
+	return events
+}
+
+// runWebhooks notifies the configured webhooks of the changes in the status
+// of pins. The current statuses are recorded first. From then on, every pin
+// status event emitted by the pin tracker of a cluster peer triggers a check of the
+// status of the pin across the cluster, which is notified when it changes.
+func (api *API) runWebhooks(ctx context.Context) {
+	defer api.wg.Done()
+
+	queue := make(chan WebhookEvent, webhookQueueSize)
+	api.wg.Add(1)
+	go api.sendWebhooks(ctx, queue)
+
+	statusFunc := api.config.WebhookStatusFunc
+	if statusFunc == nil {
+		statusFunc = WebhookStatus
+	}
+
+	// Subscribe before recording the current statuses, so that no
+	// changes are missed in between.
+	changed := newWebhookChanges()
+	api.wg.Add(1)
+	go api.watchPinEvents(ctx, changed)
+
+	var prev map[types.Cid]WebhookEvent
+	for {
+		cur, err := api.webhookStatuses(ctx, statusFunc)
+		if err == nil {
+			prev = cur
+			break
+		}
+		api.config.Logger.Errorf("error checking pin statuses for webhooks: %s", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(webhookResubscribeInterval):
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed.ready:
+		}
+
+		for _, c := range changed.take() {
+			gpi, err := api.webhookPinStatus(ctx, c)
+			if err != nil {
+				api.config.Logger.Errorf("error checking the status of %s for webhooks: %s", c, err)
+				continue
+			}
+			cur := map[types.Cid]WebhookEvent{}
+			if st := statusFunc(gpi); st != "" {
+				cur[c] = WebhookEvent{Cid: c, Name: gpi.Name, Status: st}
+			}
+			old := map[types.Cid]WebhookEvent{}
+			if ev, ok := prev[c]; ok {
+				old[c] = ev
+			}
+
+			for _, ev := range webhookTransitions(old, cur, time.Now()) {
+				select {
+				case queue <- ev:
+				default:
+					api.config.Logger.Warnf("webhook queue full: dropping notification for %s", ev.Cid)
+				}
+			}
+			if ev, ok := cur[c]; ok {
+				prev[c] = ev
+			} else {
+				delete(prev, c)
+			}
+		}
+	}
+}
+
+// webhookChanges is the set of pins whose status has to be checked. Events
+// for the same pin are coalesced while it waits to be checked.
+type webhookChanges struct {
+	mu    sync.Mutex
+	cids  map[types.Cid]struct{}
+	ready chan struct{}
+}
+
+func newWebhookChanges() *webhookChanges {
+	return &webhookChanges{
+		cids:  make(map[types.Cid]struct{}),
+		ready: make(chan struct{}, 1),
+	}
+}
+
+func (wc *webhookChanges) add(c types.Cid) {
+	wc.mu.Lock()
+	wc.cids[c] = struct{}{}
+	wc.mu.Unlock()
+	select {
+	case wc.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (wc *webhookChanges) take() []types.Cid {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	cids := make([]types.Cid, 0, len(wc.cids))
+	for c := range wc.cids {
+		cids = append(cids, c)
+	}
+	wc.cids = make(map[types.Cid]struct{})
+	return cids
+}
+
+// watchPinEvents subscribes to the events of this peer and of every other
+// peer in the peerset, including the ones that join later, and adds the
+// pins of pin status events to changed.
+func (api *API) watchPinEvents(ctx context.Context, changed *webhookChanges) {
+	defer api.wg.Done()
+
+	var self types.ID
+	err := api.rpcClient.CallContext(ctx, "", "Cluster", "ID", struct{}{}, &self)
+	if err != nil {
+		api.config.Logger.Errorf("error obtaining the peer ID for webhooks: %s", err)
+	}
+
+	var mu sync.Mutex
+	watched := make(map[peer.ID]struct{})
+	var watch func(pid peer.ID)
+	watch = func(pid peer.ID) {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := watched[pid]; ok {
+			return
+		}
+		watched[pid] = struct{}{}
+
+		api.wg.Add(1)
+		go func() {
+			defer api.wg.Done()
+			api.watchPeerEvents(ctx, pid, self.ID, changed, watch)
+			mu.Lock()
+			delete(watched, pid)
+			mu.Unlock()
+		}()
+	}
+
+	// The local peer is the empty peer ID.
+	watch("")
+
+	var peers []peer.ID
+	err = api.rpcClient.CallContext(ctx, "", "Consensus", "Peers", struct{}{}, &peers)
+	if err != nil {
+		api.config.Logger.Errorf("error obtaining the peerset for webhooks: %s", err)
+	}
+	for _, pid := range peers {
+		if pid != self.ID {
+			watch(pid)
+		}
+	}
+}
+
+// watchPeerEvents streams the events of the given peer until the context
+// is canceled, resubscribing every webhookResubscribeInterval when the
+// stream ends. Remote peers are only watched while they are part of the
+// peerset. The events of the local peer also tell when peers join the
+// peerset, and watch is called for them.
+func (api *API) watchPeerEvents(ctx context.Context, pid, self peer.ID, changed *webhookChanges, watch func(peer.ID)) {
+	for {
+		in := make(chan struct{})
+		close(in)
+		out := make(chan types.Event, StreamChannelSize)
+		errCh := make(chan error, 1)
+		go func() {
+			defer close(errCh)
+			errCh <- api.rpcClient.Stream(ctx, pid, "Cluster", "Events", in, out)
+		}()
+
+		for ev := range out {
+			switch {
+			case ev.Type == types.EventPinStatus && ev.PinInfo != nil:
+				changed.add(ev.PinInfo.Cid)
+			case ev.Type == types.EventPeerJoined && pid == "" && ev.Peer != self:
+				watch(ev.Peer)
+			}
+		}
+		if err := <-errCh; err != nil && ctx.Err() == nil {
+			api.config.Logger.Debugf("error streaming events from %s for webhooks: %s", pid, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(webhookResubscribeInterval):
+		}
+
+		if pid != "" && !api.isPeer(ctx, pid) {
+			return
+		}
+	}
+}
+
+// isPeer returns true if the given peer is part of the peerset.
+func (api *API) isPeer(ctx context.Context, pid peer.ID) bool {
+	var peers []peer.ID
+	err := api.rpcClient.CallContext(ctx, "", "Consensus", "Peers", struct{}{}, &peers)
+	if err != nil {
+		// Keep trying.
+		return true
+	}
+	for _, p := range peers {
+		if p == pid {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookPinStatus returns the status of the given pin across the cluster.
+func (api *API) webhookPinStatus(ctx context.Context, c types.Cid) (types.GlobalPinInfo, error) {
+	var gpi types.GlobalPinInfo
+	err := api.rpcClient.CallContext(ctx, "", "Cluster", "Status", c, &gpi)
+	return gpi, err
+}
+
+// webhookStatuses returns the current status of every pin in the cluster.
+func (api *API) webhookStatuses(ctx context.Context, statusFunc func(types.GlobalPinInfo) string) (map[types.Cid]WebhookEvent, error) {
+	in := make(chan types.TrackerStatus, 1)
+	in <- types.TrackerStatusUndefined
+	close(in)
+	out := make(chan types.GlobalPinInfo, StreamChannelSize)
+
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		errCh <- api.rpcClient.Stream(
+			ctx,
+			"",
+			"Cluster",
+			"StatusAll",
+			in,
+			out,
+		)
+	}()
+
+	statuses := make(map[types.Cid]WebhookEvent)
+	for gpi := range out {
+		if st := statusFunc(gpi); st != "" {
+			statuses[gpi.Cid] = WebhookEvent{
+				Cid:    gpi.Cid,
+				Name:   gpi.Name,
+				Status: st,
+			}
+		}
+	}
+	if err := <-errCh; err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// sendWebhooks delivers the queued events to all the configured webhooks.
+func (api *API) sendWebhooks(ctx context.Context, queue <-chan WebhookEvent) {
+	defer api.wg.Done()
+
+	client := &http.Client{Timeout: webhookRequestTimeout}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-queue:
+			body, err := json.Marshal(ev)
+			if err != nil {
+				api.config.Logger.Error(err)
+				continue
+			}
+			for _, u := range api.config.Webhooks {
+				err := api.sendWebhook(ctx, client, u, body)
+				if err != nil {
+					api.config.Logger.Errorf("error notifying webhook %s: %s", u, err)
+				}
+			}
+		}
+	}
+}
+
+// sendWebhook posts the body to the given URL, retrying up to
+// WebhookMaxRetries times with exponential backoff when it fails.
+func (api *API) sendWebhook(ctx context.Context, client *http.Client, url string, body []byte) error {
+	backoff := webhookRetryBackoff
+	var err error
+	for attempt := 0; attempt <= api.config.WebhookMaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		err = api.postWebhook(ctx, client, url, body)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (api *API) postWebhook(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret := api.config.WebhookSecret; secret != "" {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+webhookSignature(secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// webhookSignature returns the hex-encoded HMAC-SHA256 of the body using
+// the given secret.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
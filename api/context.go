@@ -0,0 +1,34 @@
+package api
+
+import "context"
+
+type contextKey int
+
+const (
+	requestIDContextKey contextKey = iota
+	identityContextKey
+)
+
+// ContextWithRequestID returns a copy of ctx carrying the ID of the API
+// request which originated it. RPC handlers called locally receive it.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, id)
+}
+
+// RequestIDFromContext returns the API request ID carried by ctx, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDContextKey).(string)
+	return id, ok
+}
+
+// ContextWithIdentity returns a copy of ctx carrying an identifier of the
+// credentials used in the API request which originated it.
+func ContextWithIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, identityContextKey, identity)
+}
+
+// IdentityFromContext returns the identity carried by ctx, if any.
+func IdentityFromContext(ctx context.Context) (string, bool) {
+	identity, ok := ctx.Value(identityContextKey).(string)
+	return identity, ok
+}
@@ -0,0 +1,518 @@
+// Package graphqlapi implements an IPFS Cluster API component which provides
+// a GraphQL endpoint to query the cluster state: pins and their status,
+// allocations, peers and metrics.
+//
+// The implemented API is based on the common.API component (refer to module
+// description there). Queries are sent to /graphql, either as the "query"
+// parameter of GET requests or in the body of POST requests, and are
+// executed against the schema in schema.go, which only has a Query type.
+// Collections are returned as connections with "nodes" and "page_info" and
+// can be paginated with the "first" and "after" arguments.
+package graphqlapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/api/common"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	logging "github.com/ipfs/go-log/v2"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	"github.com/libp2p/go-libp2p/core/host"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+var (
+	logger    = logging.Logger("graphqlapi")
+	apiLogger = logging.Logger("graphqlapilog")
+)
+
+// Page sizes for paginated fields.
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// GraphQLMediaType is the content type of POST requests sending the query
+// as body.
+const GraphQLMediaType = "application/graphql"
+
+// API implements the GraphQL API Component.
+// It embeds a common.API.
+type API struct {
+	*common.API
+
+	rpcClient *rpc.Client
+	config    *Config
+	schema    *graphql.Schema
+}
+
+// NewAPI creates a new GraphQL API component.
+func NewAPI(ctx context.Context, cfg *Config) (*API, error) {
+	return NewAPIWithHost(ctx, cfg, nil)
+}
+
+// NewAPIWithHost creates a new GraphQL API component using the given libp2p
+// Host.
+func NewAPIWithHost(ctx context.Context, cfg *Config, h host.Host) (*API, error) {
+	api := API{
+		config: cfg,
+	}
+	s, err := graphql.ParseSchema(schema, &queryResolver{api: &api})
+	if err != nil {
+		return nil, err
+	}
+	api.schema = s
+	capi, err := common.NewAPIWithHost(ctx, &cfg.Config, h, api.routes)
+	api.API = capi
+	return &api, err
+}
+
+// Routes returns endpoints supported by this API.
+func (api *API) routes(c *rpc.Client) []common.Route {
+	api.rpcClient = c
+	return []common.Route{
+		{
+			Name:        "Query",
+			Method:      "GET",
+			Pattern:     "/graphql",
+			HandlerFunc: api.queryHandler,
+		},
+		{
+			Name:        "QueryPost",
+			Method:      "POST",
+			Pattern:     "/graphql",
+			HandlerFunc: api.queryHandler,
+			Scopes:      []common.Scope{common.ScopeRead},
+		},
+	}
+}
+
+func (api *API) queryHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := parseRequest(r)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	result := api.schema.Exec(r.Context(), req.Query, req.OperationName, req.Variables)
+	resp := &Response{
+		Data: result.Data,
+	}
+	for _, qerr := range result.Errors {
+		resp.Errors = append(resp.Errors, &Error{
+			Message: qerr.Message,
+			Path:    qerr.Path,
+		})
+	}
+
+	// Data is only unset when the request could not be executed.
+	if resp.Data == nil {
+		api.SendResponse(w, http.StatusBadRequest, nil, resp)
+		return
+	}
+	api.SendResponse(w, http.StatusOK, nil, resp)
+}
+
+// parseRequest reads the GraphQL request from the query parameters of GET
+// requests or from the body of POST requests.
+func parseRequest(r *http.Request) (Request, error) {
+	var req Request
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				return req, fmt.Errorf("error decoding variables: %w", err)
+			}
+		}
+	} else {
+		defer r.Body.Close()
+		if strings.HasPrefix(r.Header.Get("Content-Type"), GraphQLMediaType) {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				return req, err
+			}
+			req.Query = string(body)
+		} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return req, errors.New("error decoding request body")
+		}
+	}
+
+	if req.Query == "" {
+		return req, errors.New("a query is required")
+	}
+	return req, nil
+}
+
+// Request is a GraphQL request, as sent in the body of POST requests.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Error is an error included in a GraphQL response. Path is set for errors
+// resolving a field.
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Response is a GraphQL response. Data is unset for requests which could not
+// be executed, i.e. because the query is invalid.
+type Response struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []*Error        `json:"errors,omitempty"`
+}
+
+// Error implements the error interface, so that Responses can be used as
+// error responses by the common API.
+func (r *Response) Error() string {
+	if len(r.Errors) == 0 {
+		return ""
+	}
+	return r.Errors[0].Message
+}
+
+// pageArgs are the arguments of paginated fields.
+type pageArgs struct {
+	First *int32
+	After *string
+}
+
+// bounds returns the number of items requested with "first" and the cursor
+// given in "after".
+func (args pageArgs) bounds() (int, string, error) {
+	first := defaultPageSize
+	if args.First != nil {
+		first = int(*args.First)
+	}
+	if first < 0 || first > maxPageSize {
+		return 0, "", fmt.Errorf("first should be between 0 and %d", maxPageSize)
+	}
+	after := ""
+	if args.After != nil {
+		after = *args.After
+	}
+	return first, after, nil
+}
+
+// paginate returns the bounds of the page of a collection of n items
+// sorted by their cursors, along with its page info.
+func paginate(n int, cursor func(i int) string, first int, after string) (int, int, *pageInfoResolver) {
+	start := sort.Search(n, func(i int) bool {
+		return after == "" || cursor(i) > after
+	})
+	end := n
+	pi := &pageInfoResolver{}
+	if end-start > first {
+		pi.hasNextPage = true
+		end = start + first
+	}
+	if end > start {
+		pi.endCursor = cursor(end - 1)
+	}
+	return start, end, pi
+}
+
+// queryResolver resolves the fields of the Query type.
+type queryResolver struct {
+	api *API
+}
+
+func (q *queryResolver) ID(ctx context.Context) (*peerResolver, error) {
+	var id types.ID
+	err := q.api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"ID",
+		struct{}{},
+		&id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &peerResolver{id: id}, nil
+}
+
+func (q *queryResolver) Version(ctx context.Context) (*versionResolver, error) {
+	var v types.Version
+	err := q.api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"Version",
+		struct{}{},
+		&v,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &versionResolver{v: v}, nil
+}
+
+func (q *queryResolver) Peers(ctx context.Context, args pageArgs) (*peerConnectionResolver, error) {
+	first, after, err := args.bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	in := make(chan struct{})
+	close(in)
+	out := make(chan types.ID, common.StreamChannelSize)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		errCh <- q.api.rpcClient.Stream(
+			ctx,
+			"",
+			"Cluster",
+			"Peers",
+			in,
+			out,
+		)
+	}()
+
+	peers := make([]types.ID, 0)
+	for id := range out {
+		peers = append(peers, id)
+	}
+	if err := <-errCh; err != nil {
+		return nil, err
+	}
+
+	cursor := func(i int) string { return peers[i].ID.String() }
+	sort.Slice(peers, func(i, j int) bool {
+		return cursor(i) < cursor(j)
+	})
+	start, end, pi := paginate(len(peers), cursor, first, after)
+	nodes := make([]*peerResolver, 0, end-start)
+	for _, id := range peers[start:end] {
+		nodes = append(nodes, &peerResolver{id: id})
+	}
+	return &peerConnectionResolver{nodes: nodes, pageInfo: pi}, nil
+}
+
+// pinsArgs are the arguments of the pins field.
+type pinsArgs struct {
+	First      *int32
+	After      *string
+	Status     *string
+	Name       *string
+	CidPrefix  *string
+	Allocation *string
+}
+
+// Pins returns the status of the pins. Pagination happens in the cluster
+// peer: one more item than requested is fetched to know if there is a next
+// page.
+func (q *queryResolver) Pins(ctx context.Context, args pinsArgs) (*pinStatusConnectionResolver, error) {
+	first, after, err := pageArgs{First: args.First, After: args.After}.bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	filter := types.StatusAllFilter{
+		Limit: first + 1,
+	}
+	if after != "" {
+		if filter.After, err = types.DecodeCid(after); err != nil {
+			return nil, fmt.Errorf("error decoding after: %w", err)
+		}
+	}
+	if args.Status != nil && *args.Status != "" {
+		filter.Status = types.TrackerStatusFromString(*args.Status)
+		if filter.Status == types.TrackerStatusUndefined {
+			return nil, errors.New("invalid status value")
+		}
+	}
+	if args.Name != nil {
+		filter.Name = *args.Name
+	}
+	if args.CidPrefix != nil {
+		filter.CidPrefix = *args.CidPrefix
+	}
+	if args.Allocation != nil && *args.Allocation != "" {
+		if filter.Allocation, err = peer.Decode(*args.Allocation); err != nil {
+			return nil, fmt.Errorf("error decoding allocation: %w", err)
+		}
+	}
+
+	in := make(chan types.StatusAllFilter, 1)
+	in <- filter
+	close(in)
+	out := make(chan types.GlobalPinInfo, common.StreamChannelSize)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		errCh <- q.api.rpcClient.Stream(
+			ctx,
+			"",
+			"Cluster",
+			"StatusAllFiltered",
+			in,
+			out,
+		)
+	}()
+
+	pins := make([]types.GlobalPinInfo, 0)
+	for gpi := range out {
+		pins = append(pins, gpi)
+	}
+	if err := <-errCh; err != nil {
+		return nil, err
+	}
+
+	// The results are in order and start after the cursor already.
+	cursor := func(i int) string { return pins[i].Cid.String() }
+	start, end, pi := paginate(len(pins), cursor, first, "")
+	nodes := make([]*pinStatusResolver, 0, end-start)
+	for _, gpi := range pins[start:end] {
+		nodes = append(nodes, &pinStatusResolver{gpi: gpi})
+	}
+	return &pinStatusConnectionResolver{nodes: nodes, pageInfo: pi}, nil
+}
+
+// cidArgs are the arguments of fields selecting a single cid.
+type cidArgs struct {
+	Cid string
+}
+
+func (args cidArgs) cid() (types.Cid, error) {
+	c, err := types.DecodeCid(args.Cid)
+	if err != nil {
+		return types.CidUndef, fmt.Errorf("error decoding cid: %w", err)
+	}
+	return c, nil
+}
+
+func (q *queryResolver) Pin(ctx context.Context, args cidArgs) (*pinStatusResolver, error) {
+	c, err := args.cid()
+	if err != nil {
+		return nil, err
+	}
+
+	var gpi types.GlobalPinInfo
+	err = q.api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"Status",
+		c,
+		&gpi,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &pinStatusResolver{gpi: gpi}, nil
+}
+
+func (q *queryResolver) Allocations(ctx context.Context, args pageArgs) (*allocationConnectionResolver, error) {
+	first, after, err := args.bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	in := make(chan struct{})
+	close(in)
+	out := make(chan types.Pin, common.StreamChannelSize)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		errCh <- q.api.rpcClient.Stream(
+			ctx,
+			"",
+			"Cluster",
+			"Pins",
+			in,
+			out,
+		)
+	}()
+
+	pins := make([]types.Pin, 0)
+	for pin := range out {
+		pins = append(pins, pin)
+	}
+	if err := <-errCh; err != nil {
+		return nil, err
+	}
+
+	cursor := func(i int) string { return pins[i].Cid.String() }
+	sort.Slice(pins, func(i, j int) bool {
+		return cursor(i) < cursor(j)
+	})
+	start, end, pi := paginate(len(pins), cursor, first, after)
+	nodes := make([]*allocationResolver, 0, end-start)
+	for _, pin := range pins[start:end] {
+		nodes = append(nodes, &allocationResolver{pin: pin})
+	}
+	return &allocationConnectionResolver{nodes: nodes, pageInfo: pi}, nil
+}
+
+func (q *queryResolver) Allocation(ctx context.Context, args cidArgs) (*allocationResolver, error) {
+	c, err := args.cid()
+	if err != nil {
+		return nil, err
+	}
+
+	var pin types.Pin
+	err = q.api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"PinGet",
+		c,
+		&pin,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &allocationResolver{pin: pin}, nil
+}
+
+func (q *queryResolver) Metrics(ctx context.Context, args struct{ Name string }) (*[]*metricResolver, error) {
+	var metrics []types.Metric
+	err := q.api.rpcClient.CallContext(
+		ctx,
+		"",
+		"PeerMonitor",
+		"LatestMetrics",
+		args.Name,
+		&metrics,
+	)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*metricResolver, len(metrics))
+	for i, m := range metrics {
+		list[i] = &metricResolver{m: m}
+	}
+	return &list, nil
+}
+
+func (q *queryResolver) MetricNames(ctx context.Context) (*[]string, error) {
+	var metricNames []string
+	err := q.api.rpcClient.CallContext(
+		ctx,
+		"",
+		"PeerMonitor",
+		"MetricNames",
+		struct{}{},
+		&metricNames,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &metricNames, nil
+}
@@ -0,0 +1,208 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	test "github.com/ipfs-cluster/ipfs-cluster/api/common/test"
+	clustertest "github.com/ipfs-cluster/ipfs-cluster/test"
+
+	libp2p "github.com/libp2p/go-libp2p"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func testAPI(t *testing.T) *API {
+	ctx := context.Background()
+	apiMAddr, _ := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/0")
+	h, err := libp2p.New(libp2p.ListenAddrs(apiMAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.HTTPListenAddr = []ma.Multiaddr{apiMAddr}
+
+	gqlapi, err := NewAPIWithHost(ctx, cfg, h)
+	if err != nil {
+		t.Fatal("should be able to create a new GraphQL API: ", err)
+	}
+
+	// No keep alive for tests
+	gqlapi.SetKeepAlivesEnabled(false)
+	gqlapi.SetClient(clustertest.NewMockRPCClient(t))
+
+	return gqlapi
+}
+
+func makeQuery(t *testing.T, gqlapi *API, url string, req Request) (int, map[string]interface{}) {
+	body, _ := json.Marshal(req)
+	c := test.HTTPClient(t, test.MakeHost(t, gqlapi), test.IsHTTPS(url))
+	httpResp, err := c.Post(url+"/graphql", "application/json", strings.NewReader(string(body)))
+	var resp map[string]interface{}
+	test.ProcessResp(t, httpResp, err, &resp)
+	return httpResp.StatusCode, resp
+}
+
+func TestAPIQueryPins(t *testing.T) {
+	ctx := context.Background()
+	gqlapi := testAPI(t)
+	defer gqlapi.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		status, resp := makeQuery(t, gqlapi, url(gqlapi), Request{
+			Query: `query ($first: Int) {
+				pins(first: $first) {
+					nodes { name c: cid peer_map { peer status } }
+					page_info { end_cursor has_next_page }
+				}
+			}`,
+			Variables: map[string]interface{}{"first": 2},
+		})
+		if status != http.StatusOK || resp["errors"] != nil {
+			t.Fatal("unexpected response:", status, resp)
+		}
+
+		pins := resp["data"].(map[string]interface{})["pins"].(map[string]interface{})
+		nodes := pins["nodes"].([]interface{})
+		if len(nodes) != 2 {
+			t.Fatal("expected 2 pins:", nodes)
+		}
+		first := nodes[0].(map[string]interface{})
+		if len(first) != 3 || first["c"] != clustertest.Cid1.String() || first["name"] != "aaa" {
+			t.Error("only the selected fields should be returned:", first)
+		}
+		peers := first["peer_map"].([]interface{})
+		if len(peers) == 0 || peers[0].(map[string]interface{})["peer"] == nil {
+			t.Error("peer_map should be a list of objects with peer:", peers)
+		}
+
+		pageInfo := pins["page_info"].(map[string]interface{})
+		if pageInfo["has_next_page"] != true || pageInfo["end_cursor"] != clustertest.Cid2.String() {
+			t.Error("unexpected page_info:", pageInfo)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIQueryAllocationsPages(t *testing.T) {
+	ctx := context.Background()
+	gqlapi := testAPI(t)
+	defer gqlapi.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var cids []string
+		after := ""
+		for i := 0; i < 5; i++ {
+			_, resp := makeQuery(t, gqlapi, url(gqlapi), Request{
+				Query: `query ($after: String) {
+					allocations(first: 1, after: $after) {
+						nodes { cid }
+						page_info { end_cursor has_next_page }
+					}
+				}`,
+				Variables: map[string]interface{}{"after": after},
+			})
+			allocs := resp["data"].(map[string]interface{})["allocations"].(map[string]interface{})
+			for _, n := range allocs["nodes"].([]interface{}) {
+				cids = append(cids, n.(map[string]interface{})["cid"].(string))
+			}
+			pageInfo := allocs["page_info"].(map[string]interface{})
+			if pageInfo["has_next_page"] != true {
+				break
+			}
+			after = pageInfo["end_cursor"].(string)
+		}
+
+		// the mock returns 3 pins
+		if len(cids) != 3 {
+			t.Fatal("expected 3 allocations:", cids)
+		}
+		for i := 1; i < len(cids); i++ {
+			if cids[i-1] >= cids[i] {
+				t.Error("allocations should be sorted by cid:", cids)
+			}
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIQueryGet(t *testing.T) {
+	ctx := context.Background()
+	gqlapi := testAPI(t)
+	defer gqlapi.Shutdown(ctx)
+
+	tf := func(t *testing.T, u test.URLFunc) {
+		q := url.Values{}
+		q.Set("query", `{ __typename metric_names metrics(name: "test") { name peer } peers { nodes { id } } }`)
+		var resp map[string]interface{}
+		test.MakeGet(t, gqlapi, u(gqlapi)+"/graphql?"+q.Encode(), &resp)
+
+		data := resp["data"].(map[string]interface{})
+		if data["__typename"] != "Query" {
+			t.Error("__typename should be Query")
+		}
+		if len(data["metric_names"].([]interface{})) != 2 {
+			t.Error("expected 2 metric names")
+		}
+		metrics := data["metrics"].([]interface{})
+		if len(metrics) != 1 || metrics[0].(map[string]interface{})["peer"] != clustertest.PeerID1.String() {
+			t.Error("unexpected metrics:", metrics)
+		}
+		peers := data["peers"].(map[string]interface{})["nodes"].([]interface{})
+		if len(peers) != 1 {
+			t.Error("expected 1 peer:", peers)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIQueryErrors(t *testing.T) {
+	ctx := context.Background()
+	gqlapi := testAPI(t)
+	defer gqlapi.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		invalid := []Request{
+			{Query: `{ pins { nodes { cid } }`},
+			{Query: `{ unknown }`},
+			{Query: `{ pins(wrong: 1) { nodes { cid } } }`},
+			{Query: `query ($cid: String!) { pin(cid: $cid) { cid } }`},
+			{Query: `{ pin(cid: $cid) { cid } }`},
+		}
+		for _, req := range invalid {
+			status, resp := makeQuery(t, gqlapi, url(gqlapi), req)
+			if status != http.StatusBadRequest || resp["data"] != nil || len(resp["errors"].([]interface{})) != 1 {
+				t.Error("expected a bad request for", req.Query, resp)
+			}
+		}
+
+		// field errors are returned along with the other fields.
+		status, resp := makeQuery(t, gqlapi, url(gqlapi), Request{
+			Query: `{
+				bad: pin(cid: "` + clustertest.ErrorCid.String() + `") { cid }
+				good: pin(cid: "` + clustertest.Cid1.String() + `") { cid }
+			}`,
+		})
+		if status != http.StatusOK {
+			t.Fatal("expected 200, got", status)
+		}
+		data := resp["data"].(map[string]interface{})
+		if data["bad"] != nil || data["good"] == nil {
+			t.Error("unexpected data:", data)
+		}
+		errs := resp["errors"].([]interface{})
+		if len(errs) != 1 || errs[0].(map[string]interface{})["path"].([]interface{})[0] != "bad" {
+			t.Error("expected an error for the bad field:", errs)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
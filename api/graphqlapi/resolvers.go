@@ -0,0 +1,429 @@
+package graphqlapi
+
+import (
+	"sort"
+	"time"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// This file contains the resolvers of the objects of the schema. They wrap
+// the API types and are matched to the schema fields by name, ignoring case
+// and underscores.
+
+type pageInfoResolver struct {
+	endCursor   string
+	hasNextPage bool
+}
+
+func (r *pageInfoResolver) EndCursor() string {
+	return r.endCursor
+}
+
+func (r *pageInfoResolver) HasNextPage() bool {
+	return r.hasNextPage
+}
+
+type peerResolver struct {
+	id types.ID
+}
+
+func (r *peerResolver) ID() string {
+	return r.id.ID.String()
+}
+
+func (r *peerResolver) Addresses() []string {
+	return multiaddrStrings(r.id.Addresses)
+}
+
+func (r *peerResolver) ClusterPeers() []string {
+	return peerStrings(r.id.ClusterPeers)
+}
+
+func (r *peerResolver) ClusterPeersAddresses() []string {
+	return multiaddrStrings(r.id.ClusterPeersAddresses)
+}
+
+func (r *peerResolver) Version() string {
+	return r.id.Version
+}
+
+func (r *peerResolver) Commit() string {
+	return r.id.Commit
+}
+
+func (r *peerResolver) RPCProtocolVersion() string {
+	return string(r.id.RPCProtocolVersion)
+}
+
+func (r *peerResolver) Error() string {
+	return r.id.Error
+}
+
+func (r *peerResolver) IPFS() *ipfsPeerResolver {
+	return &ipfsPeerResolver{id: r.id.IPFS}
+}
+
+func (r *peerResolver) Peername() string {
+	return r.id.Peername
+}
+
+type ipfsPeerResolver struct {
+	id types.IPFSID
+}
+
+func (r *ipfsPeerResolver) ID() string {
+	return r.id.ID.String()
+}
+
+func (r *ipfsPeerResolver) Addresses() []string {
+	return multiaddrStrings(r.id.Addresses)
+}
+
+func (r *ipfsPeerResolver) Error() string {
+	return r.id.Error
+}
+
+type peerConnectionResolver struct {
+	nodes    []*peerResolver
+	pageInfo *pageInfoResolver
+}
+
+func (r *peerConnectionResolver) Nodes() []*peerResolver {
+	return r.nodes
+}
+
+func (r *peerConnectionResolver) PageInfo() *pageInfoResolver {
+	return r.pageInfo
+}
+
+type versionResolver struct {
+	v types.Version
+}
+
+func (r *versionResolver) Version() string {
+	return r.v.Version
+}
+
+type metadataEntryResolver struct {
+	key   string
+	value string
+}
+
+func (r *metadataEntryResolver) Key() string {
+	return r.key
+}
+
+func (r *metadataEntryResolver) Value() string {
+	return r.value
+}
+
+type pinStatusResolver struct {
+	gpi types.GlobalPinInfo
+}
+
+func (r *pinStatusResolver) Cid() string {
+	return r.gpi.Cid.String()
+}
+
+func (r *pinStatusResolver) Name() string {
+	return r.gpi.Name
+}
+
+func (r *pinStatusResolver) Allocations() []string {
+	return peerStrings(r.gpi.Allocations)
+}
+
+func (r *pinStatusResolver) Origins() []string {
+	return multiaddrStrings(r.gpi.Origins)
+}
+
+func (r *pinStatusResolver) Created() string {
+	return timeString(r.gpi.Created)
+}
+
+func (r *pinStatusResolver) Metadata() []*metadataEntryResolver {
+	return metadataEntries(r.gpi.Metadata)
+}
+
+func (r *pinStatusResolver) Group() string {
+	return r.gpi.Group
+}
+
+// PeerMap returns the status in every peer, sorted by peer.
+func (r *pinStatusResolver) PeerMap() []*peerPinStatusResolver {
+	peers := make([]string, 0, len(r.gpi.PeerMap))
+	for p := range r.gpi.PeerMap {
+		peers = append(peers, p)
+	}
+	sort.Strings(peers)
+	list := make([]*peerPinStatusResolver, 0, len(peers))
+	for _, p := range peers {
+		list = append(list, &peerPinStatusResolver{
+			peer: p,
+			pis:  r.gpi.PeerMap[p],
+		})
+	}
+	return list
+}
+
+type peerPinStatusResolver struct {
+	peer string
+	pis  types.PinInfoShort
+}
+
+func (r *peerPinStatusResolver) Peer() string {
+	return r.peer
+}
+
+func (r *peerPinStatusResolver) Peername() string {
+	return r.pis.PeerName
+}
+
+func (r *peerPinStatusResolver) IPFSPeerID() string {
+	return r.pis.IPFS.String()
+}
+
+func (r *peerPinStatusResolver) IPFSPeerAddresses() []string {
+	return multiaddrStrings(r.pis.IPFSAddresses)
+}
+
+func (r *peerPinStatusResolver) Status() string {
+	return r.pis.Status.String()
+}
+
+func (r *peerPinStatusResolver) Timestamp() string {
+	return timeString(r.pis.TS)
+}
+
+func (r *peerPinStatusResolver) Error() string {
+	return r.pis.Error
+}
+
+func (r *peerPinStatusResolver) AttemptCount() int32 {
+	return int32(r.pis.AttemptCount)
+}
+
+func (r *peerPinStatusResolver) PriorityPin() bool {
+	return r.pis.PriorityPin
+}
+
+func (r *peerPinStatusResolver) NextRetry() string {
+	return timeString(r.pis.NextRetry)
+}
+
+func (r *peerPinStatusResolver) Progress() *pinProgressResolver {
+	if r.pis.Progress == nil {
+		return nil
+	}
+	return &pinProgressResolver{p: *r.pis.Progress}
+}
+
+type pinProgressResolver struct {
+	p types.PinProgress
+}
+
+func (r *pinProgressResolver) Blocks() int32 {
+	return int32(r.p.Blocks)
+}
+
+func (r *pinProgressResolver) Bytes() float64 {
+	return float64(r.p.Bytes)
+}
+
+func (r *pinProgressResolver) Size() float64 {
+	return float64(r.p.Size)
+}
+
+type pinStatusConnectionResolver struct {
+	nodes    []*pinStatusResolver
+	pageInfo *pageInfoResolver
+}
+
+func (r *pinStatusConnectionResolver) Nodes() []*pinStatusResolver {
+	return r.nodes
+}
+
+func (r *pinStatusConnectionResolver) PageInfo() *pageInfoResolver {
+	return r.pageInfo
+}
+
+type allocationResolver struct {
+	pin types.Pin
+}
+
+func (r *allocationResolver) Cid() string {
+	return r.pin.Cid.String()
+}
+
+func (r *allocationResolver) Type() string {
+	return r.pin.Type.String()
+}
+
+func (r *allocationResolver) Allocations() []string {
+	return peerStrings(r.pin.Allocations)
+}
+
+func (r *allocationResolver) MaxDepth() int32 {
+	return int32(r.pin.MaxDepth)
+}
+
+func (r *allocationResolver) Reference() *string {
+	if r.pin.Reference == nil {
+		return nil
+	}
+	ref := r.pin.Reference.String()
+	return &ref
+}
+
+func (r *allocationResolver) Timestamp() string {
+	return timeString(r.pin.Timestamp)
+}
+
+func (r *allocationResolver) ReplicationFactorMin() int32 {
+	return int32(r.pin.ReplicationFactorMin)
+}
+
+func (r *allocationResolver) ReplicationFactorMax() int32 {
+	return int32(r.pin.ReplicationFactorMax)
+}
+
+func (r *allocationResolver) Name() string {
+	return r.pin.Name
+}
+
+func (r *allocationResolver) Mode() string {
+	return r.pin.Mode.String()
+}
+
+func (r *allocationResolver) ShardSize() float64 {
+	return float64(r.pin.ShardSize)
+}
+
+func (r *allocationResolver) UserAllocations() []string {
+	return peerStrings(r.pin.UserAllocations)
+}
+
+func (r *allocationResolver) ExpireAt() string {
+	return timeString(r.pin.ExpireAt)
+}
+
+func (r *allocationResolver) Metadata() []*metadataEntryResolver {
+	return metadataEntries(r.pin.Metadata)
+}
+
+func (r *allocationResolver) PinUpdate() *string {
+	if !r.pin.PinUpdate.Defined() {
+		return nil
+	}
+	update := r.pin.PinUpdate.String()
+	return &update
+}
+
+func (r *allocationResolver) Origins() []string {
+	return multiaddrStrings(r.pin.Origins)
+}
+
+func (r *allocationResolver) Priority() string {
+	return r.pin.Priority.String()
+}
+
+func (r *allocationResolver) Group() string {
+	return r.pin.Group
+}
+
+func (r *allocationResolver) Follow() string {
+	return r.pin.Follow
+}
+
+func (r *allocationResolver) Owner() string {
+	return r.pin.Owner
+}
+
+type allocationConnectionResolver struct {
+	nodes    []*allocationResolver
+	pageInfo *pageInfoResolver
+}
+
+func (r *allocationConnectionResolver) Nodes() []*allocationResolver {
+	return r.nodes
+}
+
+func (r *allocationConnectionResolver) PageInfo() *pageInfoResolver {
+	return r.pageInfo
+}
+
+type metricResolver struct {
+	m types.Metric
+}
+
+func (r *metricResolver) Name() string {
+	return r.m.Name
+}
+
+func (r *metricResolver) Peer() string {
+	return r.m.Peer.String()
+}
+
+func (r *metricResolver) Value() string {
+	return r.m.Value
+}
+
+func (r *metricResolver) Expire() float64 {
+	return float64(r.m.Expire)
+}
+
+func (r *metricResolver) Valid() bool {
+	return r.m.Valid
+}
+
+func (r *metricResolver) Weight() float64 {
+	return float64(r.m.Weight)
+}
+
+func (r *metricResolver) Partitionable() bool {
+	return r.m.Partitionable
+}
+
+func (r *metricResolver) ReceivedAt() float64 {
+	return float64(r.m.ReceivedAt)
+}
+
+func peerStrings(peers []peer.ID) []string {
+	strs := make([]string, len(peers))
+	for i, p := range peers {
+		strs[i] = p.String()
+	}
+	return strs
+}
+
+func multiaddrStrings(addrs []types.Multiaddr) []string {
+	strs := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		if a.Multiaddr != nil {
+			strs = append(strs, a.String())
+		}
+	}
+	return strs
+}
+
+// timeString formats times as in their JSON representation.
+func timeString(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+// metadataEntries returns the metadata entries sorted by key.
+func metadataEntries(meta map[string]string) []*metadataEntryResolver {
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	entries := make([]*metadataEntryResolver, len(keys))
+	for i, k := range keys {
+		entries[i] = &metadataEntryResolver{key: k, value: meta[k]}
+	}
+	return entries
+}
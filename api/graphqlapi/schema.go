@@ -0,0 +1,144 @@
+package graphqlapi
+
+// schema is the GraphQL schema of this API. Object fields follow the JSON
+// representation of the corresponding API types, except for maps: peer maps
+// are lists of objects including the "peer" field and metadata is a list of
+// key/value entries. Integers which may not fit in 32 bits are given as
+// Float.
+const schema = `
+schema {
+	query: Query
+}
+
+type Query {
+	id: Peer
+	version: Version
+	peers(first: Int, after: String): PeerConnection
+	pins(
+		first: Int
+		after: String
+		status: String
+		name: String
+		cid_prefix: String
+		allocation: String
+	): PinStatusConnection
+	pin(cid: String!): PinStatus
+	allocations(first: Int, after: String): AllocationConnection
+	allocation(cid: String!): Allocation
+	metrics(name: String!): [Metric!]
+	metric_names: [String!]
+}
+
+type PageInfo {
+	end_cursor: String!
+	has_next_page: Boolean!
+}
+
+type Peer {
+	id: String!
+	addresses: [String!]!
+	cluster_peers: [String!]!
+	cluster_peers_addresses: [String!]!
+	version: String!
+	commit: String!
+	rpc_protocol_version: String!
+	error: String!
+	ipfs: IPFSPeer!
+	peername: String!
+}
+
+type IPFSPeer {
+	id: String!
+	addresses: [String!]!
+	error: String!
+}
+
+type PeerConnection {
+	nodes: [Peer!]!
+	page_info: PageInfo!
+}
+
+type Version {
+	version: String!
+}
+
+type MetadataEntry {
+	key: String!
+	value: String!
+}
+
+type PinStatus {
+	cid: String!
+	name: String!
+	allocations: [String!]!
+	origins: [String!]!
+	created: String!
+	metadata: [MetadataEntry!]!
+	group: String!
+	peer_map: [PeerPinStatus!]!
+}
+
+type PeerPinStatus {
+	peer: String!
+	peername: String!
+	ipfs_peer_id: String!
+	ipfs_peer_addresses: [String!]!
+	status: String!
+	timestamp: String!
+	error: String!
+	attempt_count: Int!
+	priority_pin: Boolean!
+	next_retry: String!
+	progress: PinProgress
+}
+
+type PinProgress {
+	blocks: Int!
+	bytes: Float!
+	size: Float!
+}
+
+type PinStatusConnection {
+	nodes: [PinStatus!]!
+	page_info: PageInfo!
+}
+
+type Allocation {
+	cid: String!
+	type: String!
+	allocations: [String!]!
+	max_depth: Int!
+	reference: String
+	timestamp: String!
+	replication_factor_min: Int!
+	replication_factor_max: Int!
+	name: String!
+	mode: String!
+	shard_size: Float!
+	user_allocations: [String!]!
+	expire_at: String!
+	metadata: [MetadataEntry!]!
+	pin_update: String
+	origins: [String!]!
+	priority: String!
+	group: String!
+	follow: String!
+	owner: String!
+}
+
+type AllocationConnection {
+	nodes: [Allocation!]!
+	page_info: PageInfo!
+}
+
+type Metric {
+	name: String!
+	peer: String!
+	value: String!
+	expire: Float!
+	valid: Boolean!
+	weight: Float!
+	partitionable: Boolean!
+	received_at: Float!
+}
+`
@@ -0,0 +1,161 @@
+package ipfsproxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// nodeHTTPAddress returns the http(s)://host:port address of the IPFS
+// daemon API listening on the given multiaddress.
+func nodeHTTPAddress(nodeMAddr ma.Multiaddr, https bool) (string, error) {
+	// dns multiaddresses need to be resolved first
+	if madns.Matches(nodeMAddr) {
+		ctx, cancel := context.WithTimeout(context.Background(), DNSTimeout)
+		defer cancel()
+		resolvedAddrs, err := madns.Resolve(ctx, nodeMAddr)
+		if err != nil {
+			return "", err
+		}
+		nodeMAddr = resolvedAddrs[0]
+	}
+
+	_, nodeAddr, err := manet.DialArgs(nodeMAddr)
+	if err != nil {
+		return "", err
+	}
+
+	nodeScheme := "http"
+	if https {
+		nodeScheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", nodeScheme, nodeAddr), nil
+}
+
+// backend is an IPFS daemon to which requests can be forwarded.
+type backend struct {
+	url       *url.URL
+	unhealthy int32 // atomic
+}
+
+func (b *backend) healthy() bool {
+	return atomic.LoadInt32(&b.unhealthy) == 0
+}
+
+func (b *backend) setHealthy(healthy bool) {
+	if healthy {
+		atomic.StoreInt32(&b.unhealthy, 0)
+	} else {
+		atomic.StoreInt32(&b.unhealthy, 1)
+	}
+}
+
+// backendPool is an http.RoundTripper which balances requests between
+// several IPFS daemons in a round-robin fashion. Daemons failing the health
+// checks, or failing to respond, are skipped until they pass a health check
+// again. Requests without a body are retried on the next daemon when a
+// daemon fails to respond.
+type backendPool struct {
+	transport http.RoundTripper
+	backends  []*backend
+	next      uint32 // atomic
+}
+
+func newBackendPool(transport http.RoundTripper, addrs []string) (*backendPool, error) {
+	bp := &backendPool{transport: transport}
+	for _, addr := range addrs {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, err
+		}
+		bp.backends = append(bp.backends, &backend{url: u})
+	}
+	return bp, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (bp *backendPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	retryable := req.Body == nil || req.Body == http.NoBody
+	n := len(bp.backends)
+	start := int(atomic.AddUint32(&bp.next, 1))
+
+	var lastErr error
+	for i := 0; i < n; i++ {
+		b := bp.backends[(start+i)%n]
+		if !b.healthy() {
+			continue
+		}
+		res, err := bp.roundTrip(b, req)
+		if err == nil {
+			return res, nil
+		}
+		logger.Errorf("IPFS daemon at %s failed: %s", b.url.Host, err)
+		b.setHealthy(false)
+		lastErr = err
+		if !retryable {
+			return nil, err
+		}
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	// All daemons are unhealthy: try anyway.
+	return bp.roundTrip(bp.backends[start%n], req)
+}
+
+func (bp *backendPool) roundTrip(b *backend, req *http.Request) (*http.Response, error) {
+	outreq := req.Clone(req.Context())
+	outreq.URL.Scheme = b.url.Scheme
+	outreq.URL.Host = b.url.Host
+	return bp.transport.RoundTrip(outreq)
+}
+
+// healthCheck marks the daemons answering to /api/v0/version as healthy
+// and the rest as unhealthy.
+func (bp *backendPool) healthCheck(ctx context.Context, timeout time.Duration) {
+	for _, b := range bp.backends {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.url.String()+"/api/v0/version", nil)
+		if err != nil {
+			cancel()
+			logger.Error(err)
+			continue
+		}
+		res, err := bp.transport.RoundTrip(req)
+		cancel()
+		if err != nil {
+			if b.healthy() {
+				logger.Errorf("IPFS daemon at %s is unhealthy: %s", b.url.Host, err)
+			}
+			b.setHealthy(false)
+			continue
+		}
+		res.Body.Close()
+		if !b.healthy() {
+			logger.Infof("IPFS daemon at %s is healthy again", b.url.Host)
+		}
+		b.setHealthy(true)
+	}
+}
+
+// run checks the health of the daemons at the given interval until the
+// context is cancelled.
+func (bp *backendPool) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			bp.healthCheck(ctx, interval)
+		}
+	}
+}
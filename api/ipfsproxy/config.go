@@ -26,15 +26,20 @@ var DefaultListenAddrs = []string{
 
 // Default values for Config.
 const (
-	DefaultNodeAddr           = "/ip4/127.0.0.1/tcp/5001"
-	DefaultNodeHTTPS          = false
-	DefaultReadTimeout        = 0
-	DefaultReadHeaderTimeout  = 5 * time.Second
-	DefaultWriteTimeout       = 0
-	DefaultIdleTimeout        = 60 * time.Second
-	DefaultExtractHeadersPath = "/api/v0/version"
-	DefaultExtractHeadersTTL  = 5 * time.Minute
-	DefaultMaxHeaderBytes     = minMaxHeaderBytes
+	DefaultNodeAddr                = "/ip4/127.0.0.1/tcp/5001"
+	DefaultNodeHTTPS               = false
+	DefaultReadTimeout             = 0
+	DefaultReadHeaderTimeout       = 5 * time.Second
+	DefaultWriteTimeout            = 0
+	DefaultIdleTimeout             = 60 * time.Second
+	DefaultExtractHeadersPath      = "/api/v0/version"
+	DefaultExtractHeadersTTL       = 5 * time.Minute
+	DefaultMaxHeaderBytes          = minMaxHeaderBytes
+	DefaultBlockPutSessionTTL      = time.Hour
+	DefaultMFSPinning              = false
+	DefaultMFSPinName              = "mfs-root"
+	DefaultNodeHealthCheckInterval = 10 * time.Second
+	DefaultSlowRequestThreshold    = 0
 )
 
 // Config allows to customize behavior of IPFSProxy.
@@ -48,6 +53,15 @@ type Config struct {
 	// Host/Port for the IPFS daemon.
 	NodeAddr ma.Multiaddr
 
+	// Host/Port for additional IPFS daemons. When set, the requests
+	// which are not hijacked are balanced between them and NodeAddr.
+	// Hijacked requests always go to NodeAddr.
+	ExtraNodeAddrs []ma.Multiaddr
+
+	// How often the IPFS daemons are checked when there are several of
+	// them. Requests are not sent to those which fail the check.
+	NodeHealthCheckInterval time.Duration
+
 	// Should we talk to the IPFS API over HTTPS? (experimental, untested)
 	NodeHTTPS bool
 
@@ -92,6 +106,36 @@ type Config struct {
 	// refresh them with a new request. 0 means always.
 	ExtractHeadersTTL time.Duration
 
+	// How long the blocks put under a session (block/put?session=<name>)
+	// are tracked since the last block was put. A pin of one of them
+	// using the same session is allocated to the peers holding them.
+	BlockPutSessionTTL time.Duration
+
+	// When enabled, the MFS root of the IPFS daemon is cluster-pinned
+	// every time it is changed through files/write, files/cp or
+	// files/flush, updating the pin of the previous root. The pin
+	// is given MFSPinName as name.
+	MFSPinning bool
+	MFSPinName string
+
+	// IPFS API endpoints (i.e. "config" or "key/rm", which also covers
+	// "config/show" or "key/rm/xyz") which are refused by the proxy.
+	// When AllowedEndpoints is set, only the endpoints in it are
+	// accepted, unless denied. This applies to hijacked endpoints too.
+	AllowedEndpoints []string
+	DeniedEndpoints  []string
+
+	// Requests taking longer than this are logged with their method,
+	// path, duration and size. 0 disables it.
+	SlowRequestThreshold time.Duration
+
+	// EnforcePinOwnership makes pin/add, pin/rm and pin/update fail
+	// for the pins created by authenticated clients of the cluster APIs.
+	// The proxy does not authenticate its clients, so this should be
+	// set along with the option of the same name in the REST and
+	// pinning service APIs.
+	EnforcePinOwnership bool
+
 	// Tracing flag used to skip tracing specific paths when not enabled.
 	Tracing bool
 }
@@ -101,6 +145,9 @@ type jsonConfig struct {
 	NodeMultiaddress   string         `json:"node_multiaddress"`
 	NodeHTTPS          bool           `json:"node_https,omitempty"`
 
+	ExtraNodeMultiaddresses config.Strings `json:"extra_node_multiaddresses,omitempty"`
+	NodeHealthCheckInterval string         `json:"node_health_check_interval,omitempty"`
+
 	LogFile string `json:"log_file"`
 
 	ReadTimeout       string `json:"read_timeout"`
@@ -112,6 +159,18 @@ type jsonConfig struct {
 	ExtractHeadersExtra []string `json:"extract_headers_extra,omitempty"`
 	ExtractHeadersPath  string   `json:"extract_headers_path,omitempty"`
 	ExtractHeadersTTL   string   `json:"extract_headers_ttl,omitempty"`
+
+	BlockPutSessionTTL string `json:"block_put_session_ttl,omitempty"`
+
+	AllowedEndpoints []string `json:"allowed_endpoints,omitempty"`
+	DeniedEndpoints  []string `json:"denied_endpoints,omitempty"`
+
+	SlowRequestThreshold string `json:"slow_request_threshold,omitempty"`
+
+	EnforcePinOwnership bool `json:"enforce_pin_ownership,omitempty"`
+
+	MFSPinning bool   `json:"mfs_pinning,omitempty"`
+	MFSPinName string `json:"mfs_pin_name,omitempty"`
 }
 
 // getLogPath gets full path of the file where proxy logs should be
@@ -149,6 +208,8 @@ func (cfg *Config) Default() error {
 	}
 	cfg.ListenAddr = proxy
 	cfg.NodeAddr = node
+	cfg.ExtraNodeAddrs = nil
+	cfg.NodeHealthCheckInterval = DefaultNodeHealthCheckInterval
 	cfg.LogFile = ""
 	cfg.ReadTimeout = DefaultReadTimeout
 	cfg.ReadHeaderTimeout = DefaultReadHeaderTimeout
@@ -158,6 +219,13 @@ func (cfg *Config) Default() error {
 	cfg.ExtractHeadersPath = DefaultExtractHeadersPath
 	cfg.ExtractHeadersTTL = DefaultExtractHeadersTTL
 	cfg.MaxHeaderBytes = DefaultMaxHeaderBytes
+	cfg.BlockPutSessionTTL = DefaultBlockPutSessionTTL
+	cfg.AllowedEndpoints = nil
+	cfg.DeniedEndpoints = nil
+	cfg.SlowRequestThreshold = DefaultSlowRequestThreshold
+	cfg.EnforcePinOwnership = false
+	cfg.MFSPinning = DefaultMFSPinning
+	cfg.MFSPinName = DefaultMFSPinName
 
 	return nil
 }
@@ -189,6 +257,10 @@ func (cfg *Config) Validate() error {
 		err = errors.New("ipfsproxy.node_multiaddress not set")
 	}
 
+	if cfg.NodeHealthCheckInterval <= 0 {
+		err = errors.New("ipfsproxy.node_health_check_interval is invalid")
+	}
+
 	if cfg.ReadTimeout < 0 {
 		err = errors.New("ipfsproxy.read_timeout is invalid")
 	}
@@ -213,6 +285,30 @@ func (cfg *Config) Validate() error {
 		err = errors.New("ipfsproxy.extract_headers_ttl is invalid")
 	}
 
+	if cfg.BlockPutSessionTTL <= 0 {
+		err = errors.New("ipfsproxy.block_put_session_ttl is invalid")
+	}
+
+	for _, e := range cfg.AllowedEndpoints {
+		if normalizeEndpoint(e) == "" {
+			err = errors.New("ipfsproxy.allowed_endpoints should not have empty entries")
+		}
+	}
+
+	for _, e := range cfg.DeniedEndpoints {
+		if normalizeEndpoint(e) == "" {
+			err = errors.New("ipfsproxy.denied_endpoints should not have empty entries")
+		}
+	}
+
+	if cfg.SlowRequestThreshold < 0 {
+		err = errors.New("ipfsproxy.slow_request_threshold is invalid")
+	}
+
+	if cfg.MFSPinning && cfg.MFSPinName == "" {
+		err = errors.New("ipfsproxy.mfs_pin_name should not be empty")
+	}
+
 	if cfg.MaxHeaderBytes < minMaxHeaderBytes {
 		err = fmt.Errorf("ipfsproxy.max_header_size must be greater or equal to %d", minMaxHeaderBytes)
 	}
@@ -255,17 +351,30 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 		}
 		cfg.NodeAddr = nodeAddr
 	}
+	if addresses := jcfg.ExtraNodeMultiaddresses; len(addresses) > 0 {
+		cfg.ExtraNodeAddrs = make([]ma.Multiaddr, 0, len(addresses))
+		for _, a := range addresses {
+			nodeAddr, err := ma.NewMultiaddr(a)
+			if err != nil {
+				return fmt.Errorf("error parsing ipfs extra_node_multiaddresses: %s", err)
+			}
+			cfg.ExtraNodeAddrs = append(cfg.ExtraNodeAddrs, nodeAddr)
+		}
+	}
 	config.SetIfNotDefault(jcfg.NodeHTTPS, &cfg.NodeHTTPS)
 
 	config.SetIfNotDefault(jcfg.LogFile, &cfg.LogFile)
 
 	err := config.ParseDurations(
 		"ipfsproxy",
+		&config.DurationOpt{Duration: jcfg.NodeHealthCheckInterval, Dst: &cfg.NodeHealthCheckInterval, Name: "node_health_check_interval"},
 		&config.DurationOpt{Duration: jcfg.ReadTimeout, Dst: &cfg.ReadTimeout, Name: "read_timeout"},
 		&config.DurationOpt{Duration: jcfg.ReadHeaderTimeout, Dst: &cfg.ReadHeaderTimeout, Name: "read_header_timeout"},
 		&config.DurationOpt{Duration: jcfg.WriteTimeout, Dst: &cfg.WriteTimeout, Name: "write_timeout"},
 		&config.DurationOpt{Duration: jcfg.IdleTimeout, Dst: &cfg.IdleTimeout, Name: "idle_timeout"},
 		&config.DurationOpt{Duration: jcfg.ExtractHeadersTTL, Dst: &cfg.ExtractHeadersTTL, Name: "extract_header_ttl"},
+		&config.DurationOpt{Duration: jcfg.SlowRequestThreshold, Dst: &cfg.SlowRequestThreshold, Name: "slow_request_threshold"},
+		&config.DurationOpt{Duration: jcfg.BlockPutSessionTTL, Dst: &cfg.BlockPutSessionTTL, Name: "block_put_session_ttl"},
 	)
 	if err != nil {
 		return err
@@ -282,6 +391,17 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 	}
 	config.SetIfNotDefault(jcfg.ExtractHeadersPath, &cfg.ExtractHeadersPath)
 
+	if len(jcfg.AllowedEndpoints) > 0 {
+		cfg.AllowedEndpoints = jcfg.AllowedEndpoints
+	}
+	if len(jcfg.DeniedEndpoints) > 0 {
+		cfg.DeniedEndpoints = jcfg.DeniedEndpoints
+	}
+
+	config.SetIfNotDefault(jcfg.EnforcePinOwnership, &cfg.EnforcePinOwnership)
+	config.SetIfNotDefault(jcfg.MFSPinning, &cfg.MFSPinning)
+	config.SetIfNotDefault(jcfg.MFSPinName, &cfg.MFSPinName)
+
 	return cfg.Validate()
 }
 
@@ -314,6 +434,12 @@ func (cfg *Config) toJSONConfig() (jcfg *jsonConfig, err error) {
 	// Set all configuration fields
 	jcfg.ListenMultiaddress = addresses
 	jcfg.NodeMultiaddress = cfg.NodeAddr.String()
+	for _, a := range cfg.ExtraNodeAddrs {
+		jcfg.ExtraNodeMultiaddresses = append(jcfg.ExtraNodeMultiaddresses, a.String())
+	}
+	if interval := cfg.NodeHealthCheckInterval; interval != DefaultNodeHealthCheckInterval {
+		jcfg.NodeHealthCheckInterval = interval.String()
+	}
 	jcfg.ReadTimeout = cfg.ReadTimeout.String()
 	jcfg.ReadHeaderTimeout = cfg.ReadHeaderTimeout.String()
 	jcfg.WriteTimeout = cfg.WriteTimeout.String()
@@ -329,6 +455,19 @@ func (cfg *Config) toJSONConfig() (jcfg *jsonConfig, err error) {
 	if ttl := cfg.ExtractHeadersTTL; ttl != DefaultExtractHeadersTTL {
 		jcfg.ExtractHeadersTTL = ttl.String()
 	}
+	if ttl := cfg.BlockPutSessionTTL; ttl != DefaultBlockPutSessionTTL {
+		jcfg.BlockPutSessionTTL = ttl.String()
+	}
+	jcfg.AllowedEndpoints = cfg.AllowedEndpoints
+	jcfg.DeniedEndpoints = cfg.DeniedEndpoints
+	if cfg.SlowRequestThreshold != DefaultSlowRequestThreshold {
+		jcfg.SlowRequestThreshold = cfg.SlowRequestThreshold.String()
+	}
+	jcfg.EnforcePinOwnership = cfg.EnforcePinOwnership
+	jcfg.MFSPinning = cfg.MFSPinning
+	if cfg.MFSPinName != DefaultMFSPinName {
+		jcfg.MFSPinName = cfg.MFSPinName
+	}
 
 	return
 }
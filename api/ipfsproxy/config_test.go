@@ -56,6 +56,24 @@ func TestLoadJSON(t *testing.T) {
 		t.Error("expected error in node_multiaddress")
 	}
 
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.ExtraNodeMultiaddresses = []string{"abc"}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in extra_node_multiaddresses")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.NodeHealthCheckInterval = "-1s"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in node_health_check_interval")
+	}
+
 	j = &jsonConfig{}
 	json.Unmarshal(cfgJSON, j)
 	j.ReadTimeout = "-aber"
@@ -73,6 +91,24 @@ func TestLoadJSON(t *testing.T) {
 	if err == nil {
 		t.Error("expected error in extract_headers_ttl")
 	}
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.SlowRequestThreshold = "-1s"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in slow_request_threshold")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.BlockPutSessionTTL = "-1h"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in block_put_session_ttl")
+	}
+
 	j = &jsonConfig{}
 	json.Unmarshal(cfgJSON, j)
 	j.MaxHeaderBytes = minMaxHeaderBytes - 1
@@ -115,6 +151,19 @@ func TestDefault(t *testing.T) {
 		t.Fatal("expected error validating")
 	}
 
+	cfg.Default()
+	cfg.MFSPinning = true
+	cfg.MFSPinName = ""
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.DeniedEndpoints = []string{"/api/v0/"}
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
 	cfg.Default()
 	cfg.ReadTimeout = -1
 	if cfg.Validate() == nil {
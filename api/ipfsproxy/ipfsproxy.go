@@ -24,6 +24,7 @@ import (
 	"github.com/ipfs-cluster/ipfs-cluster/adder/adderutils"
 	"github.com/ipfs-cluster/ipfs-cluster/api"
 	"github.com/ipfs-cluster/ipfs-cluster/rpcutil"
+	"github.com/ipfs-cluster/ipfs-cluster/state"
 
 	handlers "github.com/gorilla/handlers"
 	mux "github.com/gorilla/mux"
@@ -33,7 +34,6 @@ import (
 	path "github.com/ipfs/go-path"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
 	peer "github.com/libp2p/go-libp2p/core/peer"
-	madns "github.com/multiformats/go-multiaddr-dns"
 	manet "github.com/multiformats/go-multiaddr/net"
 
 	"go.opencensus.io/plugin/ochttp"
@@ -68,9 +68,15 @@ type Server struct {
 	listeners    []net.Listener         // proxy listener
 	server       *http.Server           // proxy server
 	reverseProxy *httputil.ReverseProxy // allows to talk to IPFS
+	backends     *backendPool           // balances non-hijacked requests
 
 	ipfsHeadersStore sync.Map
 
+	blockSessions *blockSessions
+
+	mfsMux  sync.Mutex
+	mfsRoot api.Cid
+
 	shutdownLock sync.Mutex
 	shutdown     bool
 	wg           sync.WaitGroup
@@ -111,22 +117,29 @@ func New(cfg *Config) (*Server, error) {
 		return nil, err
 	}
 
-	nodeMAddr := cfg.NodeAddr
-	// dns multiaddresses need to be resolved first
-	if madns.Matches(nodeMAddr) {
-		ctx, cancel := context.WithTimeout(context.Background(), DNSTimeout)
-		defer cancel()
-		resolvedAddrs, err := madns.Resolve(ctx, cfg.NodeAddr)
+	nodeHTTPAddr, err := nodeHTTPAddress(cfg.NodeAddr, cfg.NodeHTTPS)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	// Requests which are not hijacked can be balanced between several
+	// IPFS daemons.
+	var backends *backendPool
+	if len(cfg.ExtraNodeAddrs) > 0 {
+		addrs := []string{nodeHTTPAddr}
+		for _, maddr := range cfg.ExtraNodeAddrs {
+			addr, err := nodeHTTPAddress(maddr, cfg.NodeHTTPS)
+			if err != nil {
+				logger.Error(err)
+				return nil, err
+			}
+			addrs = append(addrs, addr)
+		}
+		backends, err = newBackendPool(http.DefaultTransport, addrs)
 		if err != nil {
-			logger.Error(err)
 			return nil, err
 		}
-		nodeMAddr = resolvedAddrs[0]
-	}
-
-	_, nodeAddr, err := manet.DialArgs(nodeMAddr)
-	if err != nil {
-		return nil, err
 	}
 
 	var listeners []net.Listener
@@ -143,11 +156,6 @@ func New(cfg *Config) (*Server, error) {
 		listeners = append(listeners, l)
 	}
 
-	nodeScheme := "http"
-	if cfg.NodeHTTPS {
-		nodeScheme = "https"
-	}
-	nodeHTTPAddr := fmt.Sprintf("%s://%s", nodeScheme, nodeAddr)
 	proxyURL, err := url.Parse(nodeHTTPAddr)
 	if err != nil {
 		return nil, err
@@ -202,13 +210,22 @@ func New(cfg *Config) (*Server, error) {
 		config:       cfg,
 		cancel:       cancel,
 		nodeAddr:     nodeHTTPAddr,
-		nodeScheme:   nodeScheme,
+		nodeScheme:   proxyURL.Scheme,
 		rpcReady:     make(chan struct{}, 1),
 		listeners:    listeners,
 		server:       s,
 		reverseProxy: reverseProxy,
+
+		backends:      backends,
+		blockSessions: newBlockSessions(cfg.BlockPutSessionTTL),
 	}
 
+	// Record metrics for every request, labeled by route name, and log
+	// slow requests.
+	router.Use(proxy.metricsMiddleware)
+	// Refuse the endpoints which are not allowed.
+	router.Use(proxy.policyMiddleware)
+
 	// Ideally, we should only intercept POST requests, but
 	// people may be calling the API with GET or worse, PUT
 	// because IPFS has been allowing this traditionally.
@@ -268,9 +285,32 @@ func New(cfg *Config) (*Server, error) {
 		Path("/dag/put").
 		HandlerFunc(proxy.dagPutHandler).
 		Name("DagPut")
-
-	// Everything else goes to the IPFS daemon.
-	router.PathPrefix("/").Handler(reverseProxy)
+	hijackSubrouter.
+		Path("/dag/import").
+		HandlerFunc(proxy.dagImportHandler).
+		Name("DagImport")
+	hijackSubrouter.
+		Path("/files/write").
+		HandlerFunc(proxy.mfsHandler).
+		Name("FilesWrite")
+	hijackSubrouter.
+		Path("/files/cp").
+		HandlerFunc(proxy.mfsHandler).
+		Name("FilesCp")
+	hijackSubrouter.
+		Path("/files/flush").
+		HandlerFunc(proxy.mfsHandler).
+		Name("FilesFlush")
+
+	// Everything else goes to the IPFS daemon, or to any of them when
+	// there are several.
+	if backends != nil {
+		balancedProxy := httputil.NewSingleHostReverseProxy(proxyURL)
+		balancedProxy.Transport = backends
+		router.PathPrefix("/").Handler(balancedProxy).Name("ReverseProxy")
+	} else {
+		router.PathPrefix("/").Handler(reverseProxy).Name("ReverseProxy")
+	}
 
 	go proxy.run()
 	return proxy, nil
@@ -317,6 +357,14 @@ func (proxy *Server) run() {
 	proxy.shutdownLock.Lock()
 	defer proxy.shutdownLock.Unlock()
 
+	if proxy.backends != nil {
+		proxy.wg.Add(1)
+		go func() {
+			defer proxy.wg.Done()
+			proxy.backends.run(proxy.ctx, proxy.config.NodeHealthCheckInterval)
+		}()
+	}
+
 	// This launches the proxy
 	proxy.wg.Add(len(proxy.listeners))
 	for _, l := range proxy.listeners {
@@ -365,9 +413,26 @@ func (proxy *Server) pinOpHandler(op string, w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if err := proxy.checkPathOwnership(r.Context(), p.String()); err != nil {
+		ipfsErrorResponder(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
 	pinPath := api.PinPath{Path: p.String()}
 	pinPath.Mode = api.PinModeFromString(q.Get("type"))
 
+	// Pins of blocks put under a session are allocated to the peers
+	// holding them.
+	session := q.Get("session")
+	if session != "" && op == "PinPath" && p.IsJustAKey() {
+		if c, _, err := path.SplitAbsPath(p); err == nil {
+			holders, ok := proxy.blockSessions.holders(session, api.NewCid(c))
+			if ok {
+				pinPath.UserAllocations = holders
+			}
+		}
+	}
+
 	var pin api.Pin
 	err = proxy.rpcClient.Call(
 		"",
@@ -380,6 +445,9 @@ func (proxy *Server) pinOpHandler(op string, w http.ResponseWriter, r *http.Requ
 		ipfsErrorResponder(w, err.Error(), -1)
 		return
 	}
+	if session != "" && op == "PinPath" {
+		proxy.blockSessions.remove(session)
+	}
 
 	res := ipfsPinOpResp{
 		Pins: []string{pin.Cid.String()},
@@ -504,6 +572,59 @@ func (proxy *Server) pinLsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// checkPinOwnership returns an error when EnforcePinOwnership is set and
+// the pin for the given cid, if any, is owned by a client of the cluster
+// APIs. The proxy does not authenticate its clients, so they can only
+// modify the pins created without an owner, or by cluster peers.
+func (proxy *Server) checkPinOwnership(ctx context.Context, c api.Cid) error {
+	if !proxy.config.EnforcePinOwnership {
+		return nil
+	}
+	var pin api.Pin
+	err := proxy.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"PinGet",
+		c,
+		&pin,
+	)
+	if err != nil && err.Error() == state.ErrNotFound.Error() {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !pin.ModifiableBy("") {
+		return fmt.Errorf("forbidden: %s is owned by %s", pin.Cid, pin.Owner)
+	}
+	return nil
+}
+
+// checkPathOwnership works like checkPinOwnership with the cid that the
+// given path resolves to.
+func (proxy *Server) checkPathOwnership(ctx context.Context, p string) error {
+	if !proxy.config.EnforcePinOwnership {
+		return nil
+	}
+	var c api.Cid
+	err := proxy.rpcClient.CallContext(
+		ctx,
+		"",
+		"IPFSConnector",
+		"Resolve",
+		p,
+		&c,
+	)
+	if err != nil {
+		return err
+	}
+	return proxy.checkPinOwnership(ctx, c)
+}
+
+// pinUpdateHandler pins the "to" path as an update of the "from" pin, which
+// keeps its allocations and options, and then unpins "from" unless
+// unpin=false, like Kubo does.
 func (proxy *Server) pinUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, span := trace.StartSpan(r.Context(), "ipfsproxy/pinUpdateHandler")
 	defer span.End()
@@ -554,6 +675,17 @@ func (proxy *Server) pinUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if unpin {
+		err = proxy.checkPinOwnership(ctx, fromCid)
+	}
+	if err == nil {
+		err = proxy.checkPathOwnership(ctx, pTo.String())
+	}
+	if err != nil {
+		ipfsErrorResponder(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
 	// Do a PinPath setting PinUpdate
 	pinPath := api.PinPath{Path: pTo.String()}
 	pinPath.PinUpdate = fromCid
@@ -572,9 +704,10 @@ func (proxy *Server) pinUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// If unpin != "false", unpin the FROM argument
-	// (it was already resolved).
+	// (it was already resolved). Updating a pin to itself does nothing,
+	// as in Kubo.
 	var pinObj api.Pin
-	if unpin {
+	if unpin && !pin.Cid.Equals(fromCid) {
 		err = proxy.rpcClient.CallContext(
 			ctx,
 			"",
@@ -773,8 +906,14 @@ type ipfsBlockPutResp struct {
 	Size int
 }
 
+// blockPutHandler cluster-pins the blocks put with pin=true. Blocks put with
+// session=<name> are tracked under that session, so that pinning one of them
+// with the same session allocates the pin to the peers holding them.
 func (proxy *Server) blockPutHandler(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Query().Get("pin") != "true" {
+	q := r.URL.Query()
+	pin := q.Get("pin") == "true"
+	session := q.Get("session")
+	if !pin && session == "" {
 		proxy.reverseProxy.ServeHTTP(w, r)
 		return
 	}
@@ -790,6 +929,8 @@ func (proxy *Server) blockPutHandler(w http.ResponseWriter, r *http.Request) {
 	r.URL.Host = u2.Host
 	r.URL.Scheme = u2.Scheme
 	r.Host = u2.Host
+	q.Del("session")
+	r.URL.RawQuery = q.Encode()
 	r.RequestURI = ""
 
 	res, err := proxy.reverseProxy.Transport.RoundTrip(r)
@@ -826,18 +967,24 @@ func (proxy *Server) blockPutHandler(w http.ResponseWriter, r *http.Request) {
 			w.Header().Add("X-Stream-Error", err.Error())
 			return
 		}
-		p := api.PinCid(blockInfo.Key)
-		var pinObj api.Pin
-		if err := proxy.rpcClient.Call(
-			"",
-			"Cluster",
-			"Pin",
-			p,
-			&pinObj,
-		); err != nil {
-			logger.Error(err)
-			w.Header().Add("X-Stream-Error", err.Error())
-			// keep going though blocks
+		if session != "" {
+			// The blocks are put on the IPFS daemon of this peer.
+			proxy.blockSessions.add(session, blockInfo.Key, proxy.rpcClient.ID())
+		}
+		if pin {
+			p := api.PinCid(blockInfo.Key)
+			var pinObj api.Pin
+			if err := proxy.rpcClient.Call(
+				"",
+				"Cluster",
+				"Pin",
+				p,
+				&pinObj,
+			); err != nil {
+				logger.Error(err)
+				w.Header().Add("X-Stream-Error", err.Error())
+				// keep going though blocks
+			}
 		}
 		if err := enc.Encode(blockInfo); err != nil {
 			logger.Error(err)
@@ -929,6 +1076,72 @@ func (proxy *Server) dagPutHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+type ipfsDagImportRoot struct {
+	Cid         cid.Cid
+	PinErrorMsg string
+}
+
+type ipfsDagImportStats struct {
+	BlockCount      uint64
+	BlockBytesCount uint64
+}
+
+// From https://github.com/ipfs/kubo/blob/master/core/commands/dag/dag.go
+type ipfsDagImportResp struct {
+	Root  *ipfsDagImportRoot  `json:",omitempty"`
+	Stats *ipfsDagImportStats `json:",omitempty"`
+}
+
+// dagImportHandler adds the blocks in the imported CAR files to the peers
+// allocated to them and pins their roots in cluster, instead of on the
+// local IPFS daemon.
+func (proxy *Server) dagImportHandler(w http.ResponseWriter, r *http.Request) {
+	proxy.setHeaders(w.Header(), r)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		ipfsErrorResponder(w, "error reading request: "+err.Error(), -1)
+		return
+	}
+
+	q := r.URL.Query()
+	params, err := api.AddParamsFromQuery(q)
+	if err != nil {
+		ipfsErrorResponder(w, "error parsing options:"+err.Error(), -1)
+		return
+	}
+	params.NoPin = q.Get("pin-roots") == "false"
+
+	imported, err := adderutils.ImportCARs(proxy.ctx, proxy.rpcClient, params, reader)
+	if err != nil {
+		logger.Error(err)
+		ipfsErrorResponder(w, err.Error(), -1)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	for _, root := range imported.Roots {
+		resp := ipfsDagImportResp{
+			Root: &ipfsDagImportRoot{Cid: root.Cid.Cid},
+		}
+		if root.PinError != nil {
+			logger.Error(root.PinError)
+			resp.Root.PinErrorMsg = root.PinError.Error()
+		}
+		enc.Encode(resp)
+	}
+	if q.Get("stats") == "true" {
+		enc.Encode(ipfsDagImportResp{
+			Stats: &ipfsDagImportStats{
+				BlockCount:      imported.Blocks,
+				BlockBytesCount: imported.Bytes,
+			},
+		})
+	}
+}
+
 // slashHandler returns a handler which converts a /a/b/c/<argument> request
 // into an /a/b/c/<argument>?arg=<argument> one. And uses the given origHandler
 // for it. Our handlers expect that arguments are passed in the ?arg query
@@ -20,7 +20,10 @@ import (
 
 	cid "github.com/ipfs/go-cid"
 	cmd "github.com/ipfs/go-ipfs-cmds"
+	ipld "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log/v2"
+	merkledag "github.com/ipfs/go-merkledag"
+	car "github.com/ipld/go-car"
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/multiformats/go-multicodec"
 	"github.com/multiformats/go-multihash"
@@ -320,6 +323,38 @@ func TestIPFSProxyUnpin(t *testing.T) {
 	}
 }
 
+func TestIPFSProxyEnforcePinOwnership(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
+	cfg.EnforcePinOwnership = true
+	proxy, mock := testIPFSProxyWithConfig(t, cfg)
+	defer mock.Close()
+	defer proxy.Shutdown(ctx)
+
+	// Cid3 is owned by a client of the cluster APIs in the mock.
+	for _, tc := range []struct {
+		urlPath    string
+		statusCode int
+	}{
+		{"/pin/rm?arg=" + test.Cid3.String(), http.StatusForbidden},
+		{"/pin/add?arg=" + test.Cid3.String(), http.StatusForbidden},
+		{"/pin/update?arg=" + test.Cid3.String() + "&arg=" + test.Cid1.String(), http.StatusForbidden},
+		{"/pin/update?arg=" + test.Cid1.String() + "&arg=" + test.Cid3.String(), http.StatusForbidden},
+		{"/pin/update?unpin=false&arg=" + test.Cid3.String() + "&arg=" + test.Cid1.String(), http.StatusOK},
+		{"/pin/rm?arg=" + test.Cid1.String(), http.StatusOK},
+	} {
+		res, err := http.Post(proxyURL(proxy)+tc.urlPath, "", nil)
+		if err != nil {
+			t.Fatal("request should complete: ", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tc.statusCode {
+			t.Errorf("%s: expected %d, got %d", tc.urlPath, tc.statusCode, res.StatusCode)
+		}
+	}
+}
+
 func TestIPFSProxyPinUpdate(t *testing.T) {
 	ctx := context.Background()
 	proxy, mock := testIPFSProxy(t)
@@ -687,6 +722,65 @@ func TestProxyAdd(t *testing.T) {
 	}
 }
 
+func TestProxyAddProgress(t *testing.T) {
+	ctx := context.Background()
+	proxy, mock := testIPFSProxy(t)
+	defer mock.Close()
+	defer proxy.Shutdown(ctx)
+
+	sth := test.NewShardingTestHelper()
+	defer sth.Clean(t)
+	mr, closer := sth.GetRandFileMultiReader(t, 1024)
+	defer closer.Close()
+
+	url := fmt.Sprintf("%s/add?progress=true", proxyURL(proxy))
+	req, _ := http.NewRequest("POST", url, mr)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+mr.Boundary())
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("should have succeeded: ", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Bad response status: got = %d, want = %d", res.StatusCode, http.StatusOK)
+	}
+
+	var resps []ipfsAddResp
+	dec := json.NewDecoder(res.Body)
+	for dec.More() {
+		var resp ipfsAddResp
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		resps = append(resps, resp)
+	}
+
+	// 4 updates, one every 256KiB, and the final output.
+	if len(resps) != 5 {
+		t.Fatalf("expected 5 outputs: got %d: %+v", len(resps), resps)
+	}
+	final := resps[len(resps)-1]
+	if final.Hash == "" {
+		t.Fatal("the last output should be the added file")
+	}
+	var last int64
+	for _, resp := range resps[:len(resps)-1] {
+		if resp.Hash != "" {
+			t.Error("progress updates should have no hash")
+		}
+		if resp.Name != final.Name {
+			t.Errorf("progress updates should be named %q: got %q", final.Name, resp.Name)
+		}
+		if resp.Bytes <= last {
+			t.Error("progress updates should report increasing bytes")
+		}
+		last = resp.Bytes
+	}
+	if last != 1024*1024 {
+		t.Errorf("the last update should report the full size: got %d", last)
+	}
+}
+
 func TestProxyAddError(t *testing.T) {
 	ctx := context.Background()
 	proxy, mock := testIPFSProxy(t)
@@ -841,6 +935,72 @@ func TestProxyBlockPut(t *testing.T) {
 	}
 }
 
+func TestProxyBlockPutSession(t *testing.T) {
+	ctx := context.Background()
+	proxy, mock := testIPFSProxy(t)
+	defer mock.Close()
+	defer proxy.Shutdown(ctx)
+
+	var body bytes.Buffer
+	mpw := multipart.NewWriter(&body)
+	for _, b := range []string{"block1", "block2"} {
+		w, err := mpw.CreateFormFile("file", b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(b))
+	}
+	mpw.Close()
+
+	url := fmt.Sprintf("%s/block/put?session=s1", proxyURL(proxy))
+	res, err := http.Post(url, mpw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal("should have succeeded: ", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Bad response status: got = %d, want = %d", res.StatusCode, http.StatusOK)
+	}
+
+	var blockCids []api.Cid
+	dec := json.NewDecoder(res.Body)
+	for {
+		var resp ipfsBlockPutResp
+		err := dec.Decode(&resp)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		blockCids = append(blockCids, resp.Key)
+	}
+	if len(blockCids) != 2 {
+		t.Fatal("expected 2 block cids in response", len(blockCids))
+	}
+
+	for _, c := range blockCids {
+		if _, ok := proxy.blockSessions.holders("s1", c); !ok {
+			t.Error("block should be tracked in the session:", c)
+		}
+	}
+	if _, ok := proxy.blockSessions.holders("s2", blockCids[0]); ok {
+		t.Error("block should not be tracked in another session")
+	}
+
+	res2, err := http.Post(fmt.Sprintf("%s/pin/add?arg=%s&session=s1", proxyURL(proxy), blockCids[1]), "", nil)
+	if err != nil {
+		t.Fatal("should have succeeded: ", err)
+	}
+	res2.Body.Close()
+	if res2.StatusCode != http.StatusOK {
+		t.Fatalf("Bad response status: got = %d, want = %d", res2.StatusCode, http.StatusOK)
+	}
+	if _, ok := proxy.blockSessions.holders("s1", blockCids[0]); ok {
+		t.Error("the session should be removed after pinning")
+	}
+}
+
 func TestProxyDagPut(t *testing.T) {
 	ctx := context.Background()
 	proxy, mock := testIPFSProxy(t)
@@ -964,6 +1124,236 @@ func TestProxyDagPut(t *testing.T) {
 	}
 }
 
+func TestProxyDagImport(t *testing.T) {
+	ctx := context.Background()
+	proxy, mock := testIPFSProxy(t)
+	defer mock.Close()
+	defer proxy.Shutdown(ctx)
+
+	dags := test.NewMockDAGService(false)
+	nd1 := merkledag.NodeWithData([]byte("first root"))
+	nd2 := merkledag.NodeWithData([]byte("second root"))
+	for _, nd := range []ipld.Node{nd1, nd2} {
+		if err := dags.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dagImport := func(t *testing.T, query string) []ipfsDagImportResp {
+		var carBuf bytes.Buffer
+		err := car.WriteCar(ctx, dags, []cid.Cid{nd1.Cid(), nd2.Cid()}, &carBuf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body bytes.Buffer
+		mpw := multipart.NewWriter(&body)
+		w, err := mpw.CreateFormFile("file", "dag.car")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(carBuf.Bytes())
+		mpw.Close()
+
+		url := fmt.Sprintf("%s/dag/import?"+query, proxyURL(proxy))
+		res, err := http.Post(url, mpw.FormDataContentType(), &body)
+		if err != nil {
+			t.Fatal("should have succeeded: ", err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("Bad response status: got = %d, want = %d", res.StatusCode, http.StatusOK)
+		}
+
+		var resps []ipfsDagImportResp
+		dec := json.NewDecoder(res.Body)
+		for {
+			var resp ipfsDagImportResp
+			err := dec.Decode(&resp)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resps = append(resps, resp)
+		}
+		return resps
+	}
+
+	t.Run("pin roots", func(t *testing.T) {
+		resps := dagImport(t, "stats=true")
+		if len(resps) != 3 {
+			t.Fatal("expected 2 roots and stats in response", len(resps))
+		}
+		for i, nd := range []ipld.Node{nd1, nd2} {
+			root := resps[i].Root
+			if root == nil || !root.Cid.Equals(nd.Cid()) {
+				t.Fatalf("expected root %s in response %d", nd.Cid(), i)
+			}
+			if root.PinErrorMsg != "" {
+				t.Error("root should have been pinned:", root.PinErrorMsg)
+			}
+		}
+		stats := resps[2].Stats
+		if stats == nil || stats.BlockCount != 2 {
+			t.Fatalf("expected stats for 2 blocks: %+v", stats)
+		}
+		size := uint64(len(nd1.RawData()) + len(nd2.RawData()))
+		if stats.BlockBytesCount != size {
+			t.Errorf("expected %d bytes: got %d", size, stats.BlockBytesCount)
+		}
+	})
+
+	t.Run("no pin roots", func(t *testing.T) {
+		resps := dagImport(t, "pin-roots=false")
+		if len(resps) != 2 {
+			t.Fatal("expected 2 roots in response", len(resps))
+		}
+	})
+}
+
+func TestProxyMFSPinning(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
+	cfg.MFSPinning = true
+	proxy, mock := testIPFSProxyWithConfig(t, cfg)
+	defer mock.Close()
+	defer proxy.Shutdown(ctx)
+
+	mfsRequest := func(t *testing.T, query string) {
+		url := fmt.Sprintf("%s/files/write?"+query, proxyURL(proxy))
+		res, err := http.Post(url, "", nil)
+		if err != nil {
+			t.Fatal("should have succeeded: ", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("Bad response status: got = %d, want = %d", res.StatusCode, http.StatusOK)
+		}
+	}
+
+	mfsRequest(t, "arg=/a&create=true")
+	root1 := proxy.mfsRoot
+	if !root1.Defined() {
+		t.Fatal("the MFS root should have been pinned")
+	}
+
+	mfsRequest(t, "arg=/b&create=true")
+	root2 := proxy.mfsRoot
+	if root2.Equals(root1) {
+		t.Error("the new MFS root should have been pinned")
+	}
+	stat, err := proxy.mfsRootCid(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stat.Equals(root2) {
+		t.Error("the pinned root should be the current MFS root")
+	}
+
+	mfsRequest(t, "arg=/c&create=true&flush=false")
+	if !proxy.mfsRoot.Equals(root2) {
+		t.Error("changes not flushed should not be pinned")
+	}
+}
+
+func TestProxyExtraNodes(t *testing.T) {
+	ctx := context.Background()
+	mock2 := test.NewIpfsMock(t)
+	node2, _ := ma.NewMultiaddr(fmt.Sprintf("/ip4/%s/tcp/%d", mock2.Addr, mock2.Port))
+
+	cfg := &Config{}
+	cfg.Default()
+	cfg.ExtraNodeAddrs = []ma.Multiaddr{node2}
+	proxy, mock := testIPFSProxyWithConfig(t, cfg)
+	defer mock.Close()
+	defer proxy.Shutdown(ctx)
+
+	version := func(t *testing.T) {
+		res, err := http.Post(fmt.Sprintf("%s/version", proxyURL(proxy)), "", nil)
+		if err != nil {
+			t.Fatal("should forward requests to IPFS host: ", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("Bad response status: got = %d, want = %d", res.StatusCode, http.StatusOK)
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		version(t)
+	}
+	// Wait for the mocks to count the requests.
+	time.Sleep(100 * time.Millisecond)
+	if n := mock2.GetCount("version"); n != 2 {
+		t.Errorf("expected 2 requests to the second daemon: got %d", n)
+	}
+
+	// Requests fail over to the first daemon.
+	mock2.Close()
+	for i := 0; i < 4; i++ {
+		version(t)
+	}
+	if proxy.backends.backends[1].healthy() {
+		t.Error("the second daemon should be unhealthy")
+	}
+}
+
+func TestProxyEndpointPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	status := func(t *testing.T, proxy *Server, endpoint string) int {
+		res, err := http.Post(fmt.Sprintf("%s/%s", proxyURL(proxy), endpoint), "", nil)
+		if err != nil {
+			t.Fatal("should have succeeded: ", err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	t.Run("denied", func(t *testing.T) {
+		cfg := &Config{}
+		cfg.Default()
+		cfg.DeniedEndpoints = []string{"config", "/api/v0/key/rm"}
+		proxy, mock := testIPFSProxyWithConfig(t, cfg)
+		defer mock.Close()
+		defer proxy.Shutdown(ctx)
+
+		for _, e := range []string{"config/show", "key/rm?arg=k"} {
+			if s := status(t, proxy, e); s != http.StatusForbidden {
+				t.Errorf("%s should be refused: got %d", e, s)
+			}
+		}
+		for _, e := range []string{"version", "pin/add?arg=" + test.Cid1.String()} {
+			if s := status(t, proxy, e); s != http.StatusOK {
+				t.Errorf("%s should be allowed: got %d", e, s)
+			}
+		}
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		cfg := &Config{}
+		cfg.Default()
+		cfg.AllowedEndpoints = []string{"pin", "version"}
+		cfg.DeniedEndpoints = []string{"pin/rm"}
+		proxy, mock := testIPFSProxyWithConfig(t, cfg)
+		defer mock.Close()
+		defer proxy.Shutdown(ctx)
+
+		for _, e := range []string{"config/show", "pin/rm?arg=" + test.Cid1.String()} {
+			if s := status(t, proxy, e); s != http.StatusForbidden {
+				t.Errorf("%s should be refused: got %d", e, s)
+			}
+		}
+		for _, e := range []string{"version", "pin/add?arg=" + test.Cid1.String()} {
+			if s := status(t, proxy, e); s != http.StatusOK {
+				t.Errorf("%s should be allowed: got %d", e, s)
+			}
+		}
+	})
+}
+
 func proxyURL(c *Server) string {
 	addr := c.listeners[0].Addr()
 	return fmt.Sprintf("http://%s/api/v0", addr.String())
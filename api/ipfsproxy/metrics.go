@@ -0,0 +1,102 @@
+package ipfsproxy
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api/common"
+
+	mux "github.com/gorilla/mux"
+)
+
+// commandSegment matches the parts of the path of IPFS API requests which
+// name the command, rather than being an argument (like a CID).
+var commandSegment = regexp.MustCompile(`^[a-z0-9-]{1,16}$`)
+
+// maxCommandSegments is the maximum number of parts in the names of IPFS
+// API commands ("pin/remote/service/add").
+const maxCommandSegments = 4
+
+// proxyEndpoint returns the IPFS API command requested in the given path,
+// (i.e. "files/ls" for "/api/v0/files/ls"), so that proxied requests can be
+// told apart in the metrics without recording arguments given in the path.
+func proxyEndpoint(p string) string {
+	p = strings.TrimPrefix(p, "/api/v0/")
+	var segments []string
+	for _, s := range strings.Split(p, "/") {
+		if len(segments) == maxCommandSegments || !commandSegment.MatchString(s) {
+			break
+		}
+		segments = append(segments, s)
+	}
+	if len(segments) == 0 {
+		return "unknown"
+	}
+	return strings.Join(segments, "/")
+}
+
+// sizeWriter remembers the status and size of a response.
+type sizeWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (sw *sizeWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *sizeWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(b)
+	sw.size += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher, as streaming responses need it.
+func (sw *sizeWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// metricsMiddleware instruments the requests with the route names, naming
+// the requests forwarded to IPFS after the API command, and logs the
+// requests taking longer than the SlowRequestThreshold.
+func (proxy *Server) metricsMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if cur := mux.CurrentRoute(r); cur != nil && cur.GetName() != "" {
+			route = cur.GetName()
+		}
+		if route == "ReverseProxy" {
+			route += "/" + proxyEndpoint(r.URL.Path)
+		}
+		instrumented := common.InstrumentHandler(proxy.config.ConfigKey(), route, h)
+
+		threshold := proxy.config.SlowRequestThreshold
+		if threshold <= 0 {
+			instrumented.ServeHTTP(w, r)
+			return
+		}
+
+		// The request is modified when forwarded to IPFS.
+		method, path := r.Method, r.URL.Path
+		start := time.Now()
+		sw := &sizeWriter{ResponseWriter: w}
+		instrumented.ServeHTTP(sw, r)
+		if d := time.Since(start); d > threshold {
+			logger.Warnf(
+				"slow request: %s %s (%s): %s, status %d, request %d bytes, response %d bytes",
+				method, path, route, d, sw.status, r.ContentLength, sw.size,
+			)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package ipfsproxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/observations"
+	"github.com/ipfs-cluster/ipfs-cluster/test"
+
+	"go.opencensus.io/stats/view"
+)
+
+func TestProxyEndpoint(t *testing.T) {
+	testcases := map[string]string{
+		"/api/v0/version":                         "version",
+		"/api/v0/files/ls":                        "files/ls",
+		"/api/v0/pin/remote/service/add":          "pin/remote/service/add",
+		"/api/v0/cat/" + test.Cid1.String():       "cat",
+		"/api/v0/block/get/" + test.Cid4.String(): "block/get",
+		"/api/v0/": "unknown",
+		"/webui":   "unknown",
+	}
+	for p, expected := range testcases {
+		if e := proxyEndpoint(p); e != expected {
+			t.Errorf("%s: expected %q, got %q", p, expected, e)
+		}
+	}
+}
+
+func TestProxyMetrics(t *testing.T) {
+	ctx := context.Background()
+	views := []*view.View{observations.APIRequestsView}
+	if err := view.Register(views...); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(views...)
+
+	cfg := &Config{}
+	cfg.Default()
+	cfg.SlowRequestThreshold = time.Nanosecond
+	proxy, mock := testIPFSProxyWithConfig(t, cfg)
+	defer mock.Close()
+	defer proxy.Shutdown(ctx)
+
+	res, err := http.Post(fmt.Sprintf("%s/version", proxyURL(proxy)), "", nil)
+	if err != nil {
+		t.Fatal("should forward requests to IPFS host: ", err)
+	}
+	res.Body.Close()
+
+	count := func() int64 {
+		rows, err := view.RetrieveData(observations.APIRequestsView.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range rows {
+			tags := make(map[string]string)
+			for _, tg := range row.Tags {
+				tags[tg.Key.Name()] = tg.Value
+			}
+			if tags["api"] == "ipfsproxy" && tags["route"] == "ReverseProxy/version" && tags["status_code"] == "200" {
+				return row.Data.(*view.CountData).Value
+			}
+		}
+		return 0
+	}
+
+	// Metrics are recorded once the handlers return, which may happen
+	// after clients got their responses.
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && count() < 1 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("expected 1 request to ReverseProxy/version, got %d", n)
+	}
+}
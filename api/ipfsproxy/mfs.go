@@ -0,0 +1,139 @@
+package ipfsproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+)
+
+type ipfsFilesStatResp struct {
+	Hash string
+}
+
+// mfsHandler forwards files/write, files/cp and files/flush requests to the
+// IPFS daemon and, when MFSPinning is enabled, cluster-pins the resulting
+// MFS root once they succeed.
+func (proxy *Server) mfsHandler(w http.ResponseWriter, r *http.Request) {
+	if !proxy.config.MFSPinning {
+		proxy.reverseProxy.ServeHTTP(w, r)
+		return
+	}
+
+	proxy.setHeaders(w.Header(), r)
+	u2, err := url.Parse(proxy.nodeAddr)
+	if err != nil {
+		logger.Error(err)
+		ipfsErrorResponder(w, err.Error(), -1)
+		return
+	}
+
+	r.URL.Host = u2.Host
+	r.URL.Scheme = u2.Scheme
+	r.Host = u2.Host
+	r.RequestURI = ""
+
+	res, err := proxy.reverseProxy.Transport.RoundTrip(r)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error(), -1)
+		return
+	}
+	defer res.Body.Close()
+
+	// Changes which are not flushed do not modify the MFS root.
+	flush := r.URL.Query().Get("flush") != "false"
+	if res.StatusCode == http.StatusOK && flush {
+		if err := proxy.pinMFSRoot(r.Context()); err != nil {
+			logger.Error(err)
+			ipfsErrorResponder(w, "error pinning the MFS root in cluster: "+err.Error(), -1)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", res.Header.Get("Content-Type"))
+	w.WriteHeader(res.StatusCode)
+	_, err = io.Copy(w, res.Body)
+	if err != nil {
+		logger.Error(err)
+	}
+}
+
+// pinMFSRoot cluster-pins the current MFS root of the IPFS daemon as an
+// update of the previously pinned one, which is then unpinned. The first
+// root pinned after starting is pinned on its own, as the previous one is
+// not known.
+func (proxy *Server) pinMFSRoot(ctx context.Context) error {
+	proxy.mfsMux.Lock()
+	defer proxy.mfsMux.Unlock()
+
+	root, err := proxy.mfsRootCid(ctx)
+	if err != nil {
+		return err
+	}
+	prev := proxy.mfsRoot
+	if root.Equals(prev) {
+		return nil
+	}
+
+	opts := api.PinOptions{
+		Mode: api.PinModeRecursive,
+		Name: proxy.config.MFSPinName,
+	}
+	if prev.Defined() {
+		opts.PinUpdate = prev
+	}
+
+	var pin api.Pin
+	err = proxy.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"Pin",
+		api.PinWithOpts(root, opts),
+		&pin,
+	)
+	if err != nil {
+		return err
+	}
+	proxy.mfsRoot = root
+
+	if !prev.Defined() {
+		return nil
+	}
+	var pinObj api.Pin
+	return proxy.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"Unpin",
+		api.PinCid(prev),
+		&pinObj,
+	)
+}
+
+// mfsRootCid asks the IPFS daemon for the CID of its MFS root.
+func (proxy *Server) mfsRootCid(ctx context.Context) (api.Cid, error) {
+	u := fmt.Sprintf("%s/api/v0/files/stat?arg=/&hash=true", proxy.nodeAddr)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, nil)
+	if err != nil {
+		return api.CidUndef, err
+	}
+	res, err := proxy.reverseProxy.Transport.RoundTrip(req)
+	if err != nil {
+		return api.CidUndef, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return api.CidUndef, fmt.Errorf("files/stat: unexpected status: %s", res.Status)
+	}
+
+	var stat ipfsFilesStatResp
+	if err := json.NewDecoder(res.Body).Decode(&stat); err != nil {
+		return api.CidUndef, err
+	}
+	return api.DecodeCid(stat.Hash)
+}
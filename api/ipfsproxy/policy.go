@@ -0,0 +1,51 @@
+package ipfsproxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// normalizeEndpoint returns an endpoint of the allow and deny lists as an
+// IPFS API command name: "key/rm" for "/api/v0/key/rm".
+func normalizeEndpoint(e string) string {
+	e = strings.TrimPrefix(strings.TrimSpace(e), "/")
+	e = strings.TrimPrefix(e, "api/v0/")
+	return strings.Trim(e, "/")
+}
+
+// matchEndpoint returns true when the command is the endpoint or one of its
+// subcommands (i.e. "key/rm" matches "key").
+func matchEndpoint(command string, endpoints []string) bool {
+	for _, e := range endpoints {
+		e = normalizeEndpoint(e)
+		if command == e || strings.HasPrefix(command, e+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointAllowed returns false for the commands in DeniedEndpoints, and
+// for those not in AllowedEndpoints when it is set.
+func (proxy *Server) endpointAllowed(command string) bool {
+	if matchEndpoint(command, proxy.config.DeniedEndpoints) {
+		return false
+	}
+	if len(proxy.config.AllowedEndpoints) > 0 {
+		return matchEndpoint(command, proxy.config.AllowedEndpoints)
+	}
+	return true
+}
+
+// policyMiddleware refuses the requests to the IPFS API commands which are
+// not allowed by the configuration, whether they are hijacked or not.
+func (proxy *Server) policyMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if command := proxyEndpoint(r.URL.Path); !proxy.endpointAllowed(command) {
+			logger.Warnf("refusing %s %s: endpoint not allowed", r.Method, r.URL.Path)
+			ipfsErrorResponder(w, "this endpoint is not allowed by the IPFS Cluster proxy: "+command, http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,94 @@
+package ipfsproxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// blockSession tracks the blocks put through the proxy under the same
+// session name and the peers which hold them.
+type blockSession struct {
+	blocks  map[api.Cid]struct{}
+	holders []peer.ID
+	updated time.Time
+}
+
+// blockSessions keeps the block/put sessions until they are used to pin
+// their root or until they have not been updated for the given TTL.
+type blockSessions struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*blockSession
+}
+
+func newBlockSessions(ttl time.Duration) *blockSessions {
+	return &blockSessions{
+		ttl:      ttl,
+		sessions: make(map[string]*blockSession),
+	}
+}
+
+// add records that the given peer holds a block put under the session.
+func (bs *blockSessions) add(name string, c api.Cid, holder peer.ID) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.purge()
+
+	s, ok := bs.sessions[name]
+	if !ok {
+		s = &blockSession{
+			blocks: make(map[api.Cid]struct{}),
+		}
+		bs.sessions[name] = s
+	}
+	s.blocks[c] = struct{}{}
+	s.updated = time.Now()
+	if holder == "" {
+		return
+	}
+	for _, h := range s.holders {
+		if h == holder {
+			return
+		}
+	}
+	s.holders = append(s.holders, holder)
+}
+
+// holders returns the peers holding the blocks of the session, provided
+// the given block was put under it.
+func (bs *blockSessions) holders(name string, c api.Cid) ([]peer.ID, bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.purge()
+
+	s, ok := bs.sessions[name]
+	if !ok {
+		return nil, false
+	}
+	if _, ok := s.blocks[c]; !ok {
+		return nil, false
+	}
+	return s.holders, true
+}
+
+// remove forgets a session.
+func (bs *blockSessions) remove(name string) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	delete(bs.sessions, name)
+}
+
+// purge removes the expired sessions. It must be called with the lock
+// held.
+func (bs *blockSessions) purge() {
+	for name, s := range bs.sessions {
+		if time.Since(s.updated) > bs.ttl {
+			delete(bs.sessions, name)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package ipfsproxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/test"
+)
+
+func TestBlockSessions(t *testing.T) {
+	bs := newBlockSessions(time.Second)
+	bs.add("s1", test.Cid1, test.PeerID1)
+	bs.add("s1", test.Cid2, test.PeerID1)
+	bs.add("s1", test.Cid3, "")
+
+	holders, ok := bs.holders("s1", test.Cid2)
+	if !ok {
+		t.Fatal("block should be in the session")
+	}
+	if len(holders) != 1 || holders[0] != test.PeerID1 {
+		t.Error("unexpected holders:", holders)
+	}
+	if _, ok := bs.holders("s1", test.Cid4); ok {
+		t.Error("block should not be in the session")
+	}
+
+	bs.remove("s1")
+	if _, ok := bs.holders("s1", test.Cid1); ok {
+		t.Error("session should have been removed")
+	}
+
+	bs.add("s2", test.Cid1, test.PeerID1)
+	time.Sleep(1500 * time.Millisecond)
+	if _, ok := bs.holders("s2", test.Cid1); ok {
+		t.Error("session should have expired")
+	}
+}
@@ -0,0 +1,66 @@
+package pinsvcapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/api/common"
+	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi/pinsvc"
+)
+
+// pinErrorStatus returns the status for a pin in a bulk request that could
+// not be pinned.
+func (api *API) pinErrorStatus(p pinsvc.Pin, err error) pinsvc.PinStatus {
+	return pinsvc.PinStatus{
+		RequestID: p.Cid.String(),
+		Status:    pinsvc.StatusFailed,
+		Created:   time.Now(),
+		Pin:       p,
+		Delegates: []types.Multiaddr{},
+		Info:      api.infoWith("error", err.Error()),
+	}
+}
+
+// addPins pins every item in the array sent in the request body and
+// responds with their statuses, in the same order. Items which cannot be
+// pinned have the failed status and the error in their info.
+func (api *API) addPins(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var pins []pinsvc.Pin
+	err := dec.Decode(&pins)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, fmt.Errorf("error decoding request body: %w", err), nil)
+		return
+	}
+
+	if len(pins) > api.config.MaxBulkPins {
+		err := fmt.Errorf("too many pins in request: the limit is %d", api.config.MaxBulkPins)
+		api.SendResponse(w, http.StatusRequestEntityTooLarge, err, nil)
+		return
+	}
+
+	if !api.consensusAvailableOrFail(w, r) {
+		return
+	}
+
+	api.config.Logger.Debugf("addPins: %d pins", len(pins))
+	results := make([]pinsvc.PinStatus, 0, len(pins))
+	for _, pin := range pins {
+		if !pin.Defined() {
+			results = append(results, api.pinErrorStatus(pin, errors.New("cid is undefined")))
+			continue
+		}
+		status, _, err := api.pin(r, pin, types.CidUndef, "")
+		if err != nil {
+			status = api.pinErrorStatus(pin, err)
+		}
+		results = append(results, status)
+	}
+	api.SendResponse(w, common.SetStatusAutomatically, nil, results)
+}
@@ -1,13 +1,18 @@
 package pinsvcapi
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/kelseyhightower/envconfig"
 	ma "github.com/multiformats/go-multiaddr"
 
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
 	"github.com/ipfs-cluster/ipfs-cluster/api/common"
-	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi/pinsvc"
+	"github.com/ipfs-cluster/ipfs-cluster/config"
 )
 
 const configKey = "pinsvcapi"
@@ -17,11 +22,65 @@ const minMaxHeaderBytes = 4096
 
 // Default values for Config.
 const (
-	DefaultReadTimeout       = 0
-	DefaultReadHeaderTimeout = 5 * time.Second
-	DefaultWriteTimeout      = 0
-	DefaultIdleTimeout       = 120 * time.Second
-	DefaultMaxHeaderBytes    = minMaxHeaderBytes
+	DefaultReadTimeout        = 0
+	DefaultReadHeaderTimeout  = 5 * time.Second
+	DefaultWriteTimeout       = 0
+	DefaultIdleTimeout        = 120 * time.Second
+	DefaultMaxHeaderBytes     = minMaxHeaderBytes
+	DefaultWebhookMaxRetries  = 3
+	DefaultIdempotencyWindow  = 10 * time.Minute
+	DefaultCompressionMinSize = 1024
+
+	DefaultSuppressRepeatedDelegates = false
+	DefaultDelegatesSessionTTL       = 5 * time.Minute
+	DefaultAdvertisePeerID           = false
+	DefaultStuckQueuedThreshold      = 0
+	DefaultStuckQueuedCheckInterval  = time.Minute
+	DefaultStuckQueuedAutoRecover    = false
+	DefaultMetaLogFormat             = MetaLogKeys
+	DefaultMaxInFlightPinsPerToken   = 0
+	DefaultAnchorCreated             = false
+	DefaultRequestIDMode             = RequestIDModeCid
+	DefaultUniquePinsPerOwner        = false
+	DefaultAsyncDelegates            = false
+	DefaultCheckConsensusLeader      = false
+	DefaultConsensusRetryAfter       = 30 * time.Second
+	DefaultMaxListPinsResponseSize   = 0
+	DefaultMaxBulkPins               = 1000
+	DefaultTenantScoping             = false
+	DefaultMergeStaticDelegates      = false
+	DefaultMaxPinWaitTimeout         = 5 * time.Minute
+)
+
+// Values for RequestIDMode.
+const (
+	// RequestIDModeCid uses the cid of the pin as requestID.
+	RequestIDModeCid = "cid"
+	// RequestIDModeDeterministic uses a hash of the cid, the name and
+	// the credentials used to create the pin as requestID.
+	RequestIDModeDeterministic = "deterministic"
+	// RequestIDModeUUID assigns a random UUID as requestID to every
+	// pin request.
+	RequestIDModeUUID = "uuid"
+)
+
+// Values for RPCContextValues.
+const (
+	// ContextValueRequestID propagates the request ID, taken from the
+	// X-Request-Id header or generated.
+	ContextValueRequestID = "request_id"
+	// ContextValueIdentity propagates an identifier of the credentials
+	// used in the request.
+	ContextValueIdentity = "identity"
+)
+
+// Values for MetaLogFormat.
+const (
+	// MetaLogKeys logs only the keys of the pin metadata.
+	MetaLogKeys = "keys"
+	// MetaLogRedacted logs the keys of the pin metadata with their
+	// values replaced.
+	MetaLogRedacted = "redacted"
 )
 
 // Default values for Config.
@@ -51,9 +110,163 @@ var (
 )
 
 // Config fully implements the config.ComponentConfig interface. Use
-// NewConfig() to instantiate. Config embeds a common.Config object.
+// NewConfig() to instantiate. Config embeds a common.Config object and adds
+// options specific to the Pinning Services API, which are stored in the same
+// configuration section.
 type Config struct {
 	common.Config
+
+	// SuppressRepeatedDelegates enables omitting the delegates of a
+	// cluster peer from listPins results when they have already been
+	// sent in a previous page of the same paginated listing.
+	SuppressRepeatedDelegates bool
+
+	// DelegatesSessionTTL controls for how long the delegates sent
+	// during a paginated listing are remembered while waiting for the
+	// request of the next page.
+	DelegatesSessionTTL time.Duration
+
+	// AdvertisePeerID makes the API set an X-Cluster-Peer header with
+	// the ID of the cluster peer handling the request on every
+	// response. Useful when several peers sit behind a load balancer.
+	AdvertisePeerID bool
+
+	// StuckQueuedThreshold is the time after which a pin that is still
+	// queued is considered stuck. Stuck pins are reported in the
+	// /admin/stuck-queued endpoint and the pins/stuck_queued metric.
+	// 0 disables detection.
+	StuckQueuedThreshold time.Duration
+
+	// StuckQueuedCheckInterval specifies how often to look for stuck
+	// pins.
+	StuckQueuedCheckInterval time.Duration
+
+	// StuckQueuedAutoRecover triggers a recover operation for every
+	// stuck pin that is detected.
+	StuckQueuedAutoRecover bool
+
+	// MetaLogFormat controls how pin metadata is shown in log messages.
+	// Metadata values are never logged, as they may contain sensitive
+	// information. Either "keys" or "redacted".
+	MetaLogFormat string
+
+	// MaxInFlightPinsPerToken limits how many pins requested with the
+	// same credentials can be queued or pinning at the same time.
+	// Requests without credentials share the same limit. 0 means no
+	// limit.
+	MaxInFlightPinsPerToken int
+
+	// StatusMapping overrides the pin status reported for pins in the
+	// given tracker statuses, i.e. {"remote": "pinned"}. Tracker
+	// statuses not included use the default mapping.
+	StatusMapping map[string]string
+
+	// AnchorCreated stores the time at which a pin is first created in
+	// its metadata, so that the reported creation time does not change
+	// when the same cid is pinned again.
+	AnchorCreated bool
+
+	// RequestIDMode controls how requestIDs are assigned to pins. With
+	// "cid", the requestID is the cid of the pin. With "deterministic",
+	// it is derived from the cid, the name, the origins and the
+	// credentials used, so that the same logical pin always has the same
+	// requestID. With "uuid", every request gets a new random requestID.
+	// In all modes there is a single pin per cid. In "deterministic" and
+	// "uuid" modes, every request for a cid that is already pinned adds
+	// its requestID to the existing pin, and removing a requestID only
+	// unpins the cid when it is the last one. Unknown requestIDs are not
+	// found.
+	RequestIDMode string
+
+	// UniquePinsPerOwner makes adding a pin with the same cid and name
+	// as an existing one, using the same credentials, return the
+	// existing pin rather than pinning again.
+	UniquePinsPerOwner bool
+
+	// AsyncDelegates makes addPin respond without waiting to resolve
+	// the IPFS addresses of the allocated peers. The allocated cluster
+	// peers are returned as provisional delegates until their addresses
+	// have been resolved in the background.
+	AsyncDelegates bool
+
+	// CheckConsensusLeader makes addPin and replacePin verify that the
+	// consensus component has a leader before pinning. When it does
+	// not, the request is rejected with 503 Service Unavailable rather
+	// than failing with a generic error. Consensus components without
+	// leaders (crdt) are always considered available.
+	CheckConsensusLeader bool
+
+	// ConsensusRetryAfter is the value of the Retry-After header sent
+	// when a pin is rejected because consensus is unavailable.
+	ConsensusRetryAfter time.Duration
+
+	// MaxListPinsResponseSize is the maximum size in bytes of a listPins
+	// response. Larger responses are truncated and carry the
+	// X-Truncated header, along with an X-Next-Before header holding
+	// the "before" value to request the rest. 0 means no limit.
+	MaxListPinsResponseSize int
+
+	// RPCContextValues selects the values to add to the context of the
+	// RPC calls made when handling a request, so that they can be read
+	// by the RPC handlers. Supported values are "request_id" and
+	// "identity".
+	RPCContextValues []string
+
+	// MaxBulkPins is the maximum number of pins that can be sent in a
+	// single request to /pins/bulk.
+	MaxBulkPins int
+
+	// TenantScoping makes every token used to access the API own a
+	// separate namespace. Pin requests are stored with the identity of
+	// the client which made them, or an identifier of the token used
+	// without authentication, and only that client can see, replace or
+	// remove them. Several tokens may pin the same cid: each of them
+	// sees its own request, and the cid stays pinned until all of them
+	// remove it. Pins without an owner, i.e. created before enabling
+	// this option or through other APIs, are not visible. Owners are
+	// never taken from the request: meta keys starting with "pinsvc_"
+	// are reserved and rejected. The admin endpoints are not scoped.
+	TenantScoping bool
+
+	// StaticDelegates is a list of multiaddresses returned as delegates
+	// for every pin instead of the addresses of the IPFS daemons in the
+	// cluster, which may not be reachable by clients.
+	StaticDelegates []types.Multiaddr
+
+	// MergeStaticDelegates makes the API return StaticDelegates in
+	// addition to the discovered IPFS addresses, rather than instead
+	// of them.
+	MergeStaticDelegates bool
+
+	// MaxPinWaitTimeout is the longest time that a POST /pins request
+	// with "wait=pinned" blocks waiting for the pin to complete. It is
+	// also the timeout used when the request does not set one.
+	MaxPinWaitTimeout time.Duration
+}
+
+type jsonConfig struct {
+	SuppressRepeatedDelegates bool              `json:"suppress_repeated_delegates,omitempty"`
+	DelegatesSessionTTL       string            `json:"delegates_session_ttl,omitempty"`
+	AdvertisePeerID           bool              `json:"advertise_peer_id,omitempty"`
+	StuckQueuedThreshold      string            `json:"stuck_queued_threshold,omitempty"`
+	StuckQueuedCheckInterval  string            `json:"stuck_queued_check_interval,omitempty"`
+	StuckQueuedAutoRecover    bool              `json:"stuck_queued_auto_recover,omitempty"`
+	MetaLogFormat             string            `json:"meta_log_format,omitempty"`
+	MaxInFlightPinsPerToken   int               `json:"max_in_flight_pins_per_token,omitempty"`
+	StatusMapping             map[string]string `json:"status_mapping,omitempty"`
+	AnchorCreated             bool              `json:"anchor_created,omitempty"`
+	RequestIDMode             string            `json:"request_id_mode,omitempty"`
+	UniquePinsPerOwner        bool              `json:"unique_pins_per_owner,omitempty"`
+	AsyncDelegates            bool              `json:"async_delegates,omitempty"`
+	CheckConsensusLeader      bool              `json:"check_consensus_leader,omitempty"`
+	ConsensusRetryAfter       string            `json:"consensus_retry_after,omitempty"`
+	MaxListPinsResponseSize   int               `json:"max_list_pins_response_size,omitempty"`
+	RPCContextValues          []string          `json:"rpc_context_values,omitempty"`
+	MaxBulkPins               int               `json:"max_bulk_pins,omitempty"`
+	TenantScoping             bool              `json:"tenant_scoping,omitempty"`
+	StaticDelegates           []string          `json:"static_delegates,omitempty"`
+	MergeStaticDelegates      bool              `json:"merge_static_delegates,omitempty"`
+	MaxPinWaitTimeout         string            `json:"max_pin_wait_timeout,omitempty"`
 }
 
 // NewConfig creates a Config object setting the necessary meta-fields in the
@@ -65,13 +278,7 @@ func NewConfig() *Config {
 	cfg.Logger = logger
 	cfg.RequestLogger = apiLogger
 	cfg.DefaultFunc = defaultFunc
-	cfg.APIErrorFunc = func(err error, status int) error {
-		return pinsvc.APIError{
-			Details: pinsvc.APIErrorDetails{
-				Reason: err.Error(),
-			},
-		}
-	}
+	cfg.APIErrorFunc = apiError
 	return &cfg
 }
 
@@ -83,9 +290,229 @@ func (cfg *Config) ConfigKey() string {
 
 // Default initializes this Config with working values.
 func (cfg *Config) Default() error {
+	cfg.setDefaults()
 	return defaultFunc(&cfg.Config)
 }
 
+// Sets defaults for the pinsvcapi-specific options.
+func (cfg *Config) setDefaults() {
+	cfg.SuppressRepeatedDelegates = DefaultSuppressRepeatedDelegates
+	cfg.DelegatesSessionTTL = DefaultDelegatesSessionTTL
+	cfg.AdvertisePeerID = DefaultAdvertisePeerID
+	cfg.StuckQueuedThreshold = DefaultStuckQueuedThreshold
+	cfg.StuckQueuedCheckInterval = DefaultStuckQueuedCheckInterval
+	cfg.StuckQueuedAutoRecover = DefaultStuckQueuedAutoRecover
+	cfg.MetaLogFormat = DefaultMetaLogFormat
+	cfg.MaxInFlightPinsPerToken = DefaultMaxInFlightPinsPerToken
+	cfg.StatusMapping = nil
+	cfg.AnchorCreated = DefaultAnchorCreated
+	cfg.RequestIDMode = DefaultRequestIDMode
+	cfg.UniquePinsPerOwner = DefaultUniquePinsPerOwner
+	cfg.AsyncDelegates = DefaultAsyncDelegates
+	cfg.CheckConsensusLeader = DefaultCheckConsensusLeader
+	cfg.ConsensusRetryAfter = DefaultConsensusRetryAfter
+	cfg.MaxListPinsResponseSize = DefaultMaxListPinsResponseSize
+	cfg.RPCContextValues = nil
+	cfg.MaxBulkPins = DefaultMaxBulkPins
+	cfg.TenantScoping = DefaultTenantScoping
+	cfg.StaticDelegates = nil
+	cfg.MergeStaticDelegates = DefaultMergeStaticDelegates
+	cfg.MaxPinWaitTimeout = DefaultMaxPinWaitTimeout
+}
+
+// ApplyEnvVars fills in any Config fields found as environment variables.
+func (cfg *Config) ApplyEnvVars() error {
+	err := cfg.Config.ApplyEnvVars()
+	if err != nil {
+		return err
+	}
+
+	jcfg := cfg.toJSONConfig()
+	err = envconfig.Process(envConfigKey, jcfg)
+	if err != nil {
+		return err
+	}
+	return cfg.applyJSONConfig(jcfg)
+}
+
+// Validate makes sure that all fields in this Config have working values,
+// at least in appearance.
+func (cfg *Config) Validate() error {
+	err := cfg.Config.Validate()
+	if err != nil {
+		return err
+	}
+
+	if cfg.DelegatesSessionTTL < 0 {
+		return errors.New(configKey + ".delegates_session_ttl is invalid")
+	}
+
+	if cfg.StuckQueuedThreshold < 0 {
+		return errors.New(configKey + ".stuck_queued_threshold is invalid")
+	}
+
+	if cfg.StuckQueuedThreshold > 0 && cfg.StuckQueuedCheckInterval <= 0 {
+		return errors.New(configKey + ".stuck_queued_check_interval is invalid")
+	}
+
+	if cfg.MaxInFlightPinsPerToken < 0 {
+		return errors.New(configKey + ".max_in_flight_pins_per_token is invalid")
+	}
+
+	if cfg.CheckConsensusLeader && cfg.ConsensusRetryAfter <= 0 {
+		return errors.New(configKey + ".consensus_retry_after is invalid")
+	}
+
+	if cfg.MaxListPinsResponseSize < 0 {
+		return errors.New(configKey + ".max_list_pins_response_size is invalid")
+	}
+
+	if cfg.MaxBulkPins <= 0 {
+		return errors.New(configKey + ".max_bulk_pins is invalid")
+	}
+
+	if cfg.MaxPinWaitTimeout <= 0 {
+		return errors.New(configKey + ".max_pin_wait_timeout is invalid")
+	}
+
+	for _, v := range cfg.RPCContextValues {
+		switch v {
+		case ContextValueRequestID, ContextValueIdentity:
+		default:
+			return fmt.Errorf("%s.rpc_context_values: unknown value %q", configKey, v)
+		}
+	}
+
+	if _, err := parseStatusMapping(cfg.StatusMapping); err != nil {
+		return fmt.Errorf("%s.status_mapping is invalid: %w", configKey, err)
+	}
+
+	switch cfg.RequestIDMode {
+	case RequestIDModeCid, RequestIDModeDeterministic, RequestIDModeUUID:
+	default:
+		return errors.New(configKey + ".request_id_mode should be \"cid\", \"deterministic\" or \"uuid\"")
+	}
+
+	switch cfg.MetaLogFormat {
+	case MetaLogKeys, MetaLogRedacted:
+	default:
+		return errors.New(configKey + ".meta_log_format should be \"keys\" or \"redacted\"")
+	}
+	return nil
+}
+
+// LoadJSON parses a raw JSON byte slice created by ToJSON() and sets the
+// configuration fields accordingly.
+func (cfg *Config) LoadJSON(raw []byte) error {
+	jcfg := &jsonConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		cfg.Logger.Error(configKey + ": error unmarshaling config")
+		return err
+	}
+
+	err = cfg.Config.LoadJSON(raw)
+	if err != nil {
+		return err
+	}
+
+	cfg.setDefaults()
+	return cfg.applyJSONConfig(jcfg)
+}
+
+func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
+	cfg.SuppressRepeatedDelegates = jcfg.SuppressRepeatedDelegates
+	cfg.AdvertisePeerID = jcfg.AdvertisePeerID
+	cfg.StuckQueuedAutoRecover = jcfg.StuckQueuedAutoRecover
+	config.SetIfNotDefault(jcfg.MetaLogFormat, &cfg.MetaLogFormat)
+	cfg.MaxInFlightPinsPerToken = jcfg.MaxInFlightPinsPerToken
+	cfg.StatusMapping = jcfg.StatusMapping
+	cfg.AnchorCreated = jcfg.AnchorCreated
+	config.SetIfNotDefault(jcfg.RequestIDMode, &cfg.RequestIDMode)
+	cfg.UniquePinsPerOwner = jcfg.UniquePinsPerOwner
+	cfg.AsyncDelegates = jcfg.AsyncDelegates
+	cfg.CheckConsensusLeader = jcfg.CheckConsensusLeader
+	cfg.MaxListPinsResponseSize = jcfg.MaxListPinsResponseSize
+	cfg.RPCContextValues = jcfg.RPCContextValues
+	config.SetIfNotDefault(jcfg.MaxBulkPins, &cfg.MaxBulkPins)
+	cfg.TenantScoping = jcfg.TenantScoping
+	cfg.MergeStaticDelegates = jcfg.MergeStaticDelegates
+
+	cfg.StaticDelegates = nil
+	for _, addr := range jcfg.StaticDelegates {
+		delegate, err := types.NewMultiaddr(addr)
+		if err != nil {
+			return fmt.Errorf("%s.static_delegates: error parsing %s: %w", configKey, addr, err)
+		}
+		cfg.StaticDelegates = append(cfg.StaticDelegates, delegate)
+	}
+
+	err := config.ParseDurations(
+		configKey,
+		&config.DurationOpt{Duration: jcfg.DelegatesSessionTTL, Dst: &cfg.DelegatesSessionTTL, Name: "delegates_session_ttl"},
+		&config.DurationOpt{Duration: jcfg.StuckQueuedThreshold, Dst: &cfg.StuckQueuedThreshold, Name: "stuck_queued_threshold"},
+		&config.DurationOpt{Duration: jcfg.StuckQueuedCheckInterval, Dst: &cfg.StuckQueuedCheckInterval, Name: "stuck_queued_check_interval"},
+		&config.DurationOpt{Duration: jcfg.ConsensusRetryAfter, Dst: &cfg.ConsensusRetryAfter, Name: "consensus_retry_after"},
+		&config.DurationOpt{Duration: jcfg.MaxPinWaitTimeout, Dst: &cfg.MaxPinWaitTimeout, Name: "max_pin_wait_timeout"},
+	)
+	if err != nil {
+		return err
+	}
+
+	return cfg.Validate()
+}
+
+// ToJSON produce a human-friendly JSON representation of the Config
+// object.
+func (cfg *Config) ToJSON() ([]byte, error) {
+	raw, err := cfg.Config.ToJSON()
+	if err != nil {
+		return nil, err
+	}
+	return common.MergeJSON(raw, cfg.toJSONConfig(), config.DefaultJSONMarshal)
+}
+
+// ToDisplayJSON returns JSON config as a string.
+func (cfg *Config) ToDisplayJSON() ([]byte, error) {
+	raw, err := cfg.Config.ToDisplayJSON()
+	if err != nil {
+		return nil, err
+	}
+	return common.MergeJSON(raw, cfg.toJSONConfig(), config.DisplayJSON)
+}
+
+func (cfg *Config) toJSONConfig() *jsonConfig {
+	var staticDelegates []string
+	for _, addr := range cfg.StaticDelegates {
+		staticDelegates = append(staticDelegates, addr.String())
+	}
+
+	return &jsonConfig{
+		SuppressRepeatedDelegates: cfg.SuppressRepeatedDelegates,
+		DelegatesSessionTTL:       cfg.DelegatesSessionTTL.String(),
+		AdvertisePeerID:           cfg.AdvertisePeerID,
+		StuckQueuedThreshold:      cfg.StuckQueuedThreshold.String(),
+		StuckQueuedCheckInterval:  cfg.StuckQueuedCheckInterval.String(),
+		StuckQueuedAutoRecover:    cfg.StuckQueuedAutoRecover,
+		MetaLogFormat:             cfg.MetaLogFormat,
+		MaxInFlightPinsPerToken:   cfg.MaxInFlightPinsPerToken,
+		StatusMapping:             cfg.StatusMapping,
+		AnchorCreated:             cfg.AnchorCreated,
+		RequestIDMode:             cfg.RequestIDMode,
+		UniquePinsPerOwner:        cfg.UniquePinsPerOwner,
+		AsyncDelegates:            cfg.AsyncDelegates,
+		CheckConsensusLeader:      cfg.CheckConsensusLeader,
+		ConsensusRetryAfter:       cfg.ConsensusRetryAfter.String(),
+		MaxListPinsResponseSize:   cfg.MaxListPinsResponseSize,
+		RPCContextValues:          cfg.RPCContextValues,
+		MaxBulkPins:               cfg.MaxBulkPins,
+		TenantScoping:             cfg.TenantScoping,
+		StaticDelegates:           staticDelegates,
+		MergeStaticDelegates:      cfg.MergeStaticDelegates,
+		MaxPinWaitTimeout:         cfg.MaxPinWaitTimeout.String(),
+	}
+}
+
 // Sets all defaults for this config.
 func defaultFunc(cfg *common.Config) error {
 	// http
@@ -100,6 +527,8 @@ func defaultFunc(cfg *common.Config) error {
 	cfg.HTTPListenAddr = addrs
 	cfg.PathSSLCertFile = ""
 	cfg.PathSSLKeyFile = ""
+	cfg.PathClientCAFile = ""
+	cfg.ClientCertRequired = false
 	cfg.ReadTimeout = DefaultReadTimeout
 	cfg.ReadHeaderTimeout = DefaultReadHeaderTimeout
 	cfg.WriteTimeout = DefaultWriteTimeout
@@ -113,6 +542,16 @@ func defaultFunc(cfg *common.Config) error {
 
 	// Auth
 	cfg.BasicAuthCredentials = nil
+	cfg.BasicAuthScopes = nil
+	cfg.ClientCertScopes = nil
+	cfg.EnforcePinOwnership = false
+	cfg.JWTSecret = ""
+	cfg.PathJWTPublicKeyFile = ""
+	cfg.JWTPublicKey = nil
+	cfg.JWKSURL = ""
+	cfg.JWKSRefreshInterval = common.DefaultJWKSRefreshInterval
+	cfg.JWTIssuer = ""
+	cfg.JWTAudience = ""
 
 	// Logs
 	cfg.HTTPLogFile = ""
@@ -120,6 +559,23 @@ func defaultFunc(cfg *common.Config) error {
 	// Headers
 	cfg.Headers = DefaultHeaders
 
+	// Rate limiting (disabled)
+	cfg.RateLimit = 0
+	cfg.RateLimitBurst = 0
+	cfg.RateLimitScopes = nil
+
+	// Webhooks
+	cfg.Webhooks = nil
+	cfg.WebhookSecret = ""
+	cfg.WebhookMaxRetries = DefaultWebhookMaxRetries
+
+	// Idempotency keys
+	cfg.IdempotencyWindow = DefaultIdempotencyWindow
+
+	// Compression
+	cfg.Compression = true
+	cfg.CompressionMinSize = DefaultCompressionMinSize
+
 	cfg.CORSAllowedOrigins = DefaultCORSAllowedOrigins
 	cfg.CORSAllowedMethods = DefaultCORSAllowedMethods
 	cfg.CORSAllowedHeaders = DefaultCORSAllowedHeaders
@@ -0,0 +1,150 @@
+package pinsvcapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+var cfgJSON = []byte(`
+{
+      "http_listen_multiaddress": "/ip4/127.0.0.1/tcp/12122",
+      "read_timeout": "30s",
+      "read_header_timeout": "5s",
+      "write_timeout": "1m0s",
+      "idle_timeout": "2m0s",
+      "max_header_bytes": 16384,
+      "basic_auth_credentials": null,
+      "http_log_file": "",
+      "cors_allowed_origins": ["myorigin"],
+      "cors_allowed_methods": ["GET"],
+      "cors_allowed_headers": ["X-Custom"],
+      "cors_exposed_headers": ["X-Chunked-Output"],
+      "cors_allow_credentials": false,
+      "cors_max_age": "1s",
+      "suppress_repeated_delegates": true,
+      "delegates_session_ttl": "10m",
+      "advertise_peer_id": true,
+      "stuck_queued_threshold": "30m",
+      "stuck_queued_auto_recover": true,
+      "meta_log_format": "redacted",
+      "status_mapping": {"remote": "pinned"},
+      "static_delegates": ["/dns4/gateway.example.com/tcp/4001/p2p/12D3KooWKewdAMAU3WjYHm8qkAJc5eW6KHbHWNigWraXXtE1UCng"]
+}
+`)
+
+func TestLoadJSON(t *testing.T) {
+	cfg := NewConfig()
+	err := cfg.LoadJSON(cfgJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.ReadTimeout != 30*time.Second {
+		t.Error("common options should be parsed")
+	}
+
+	if !cfg.SuppressRepeatedDelegates ||
+		cfg.DelegatesSessionTTL != 10*time.Minute ||
+		!cfg.AdvertisePeerID {
+		t.Error("pinsvcapi options should be parsed")
+	}
+
+	if cfg.StuckQueuedThreshold != 30*time.Minute ||
+		cfg.StuckQueuedCheckInterval != DefaultStuckQueuedCheckInterval ||
+		!cfg.StuckQueuedAutoRecover {
+		t.Error("stuck queued options should be parsed")
+	}
+
+	if cfg.MetaLogFormat != MetaLogRedacted {
+		t.Error("meta_log_format should be parsed")
+	}
+
+	if cfg.StatusMapping["remote"] != "pinned" {
+		t.Error("status_mapping should be parsed")
+	}
+
+	if len(cfg.StaticDelegates) != 1 || cfg.MergeStaticDelegates {
+		t.Error("static_delegates should be parsed")
+	}
+
+	j := make(map[string]interface{})
+	json.Unmarshal(cfgJSON, &j)
+	j["delegates_session_ttl"] = "-1s"
+	tst, _ := json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in delegates_session_ttl")
+	}
+
+	j = make(map[string]interface{})
+	json.Unmarshal(cfgJSON, &j)
+	j["stuck_queued_threshold"] = "-1s"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in stuck_queued_threshold")
+	}
+
+	j = make(map[string]interface{})
+	json.Unmarshal(cfgJSON, &j)
+	j["meta_log_format"] = "full"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in meta_log_format")
+	}
+
+	j = make(map[string]interface{})
+	json.Unmarshal(cfgJSON, &j)
+	j["status_mapping"] = map[string]string{"remote": "gone"}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in status_mapping")
+	}
+
+	j = make(map[string]interface{})
+	json.Unmarshal(cfgJSON, &j)
+	j["static_delegates"] = []string{"abc"}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in static_delegates")
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	cfg := NewConfig()
+	cfg.LoadJSON(cfgJSON)
+	newjson, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = NewConfig()
+	err = cfg.LoadJSON(newjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.ReadTimeout != 30*time.Second ||
+		!cfg.SuppressRepeatedDelegates ||
+		cfg.DelegatesSessionTTL != 10*time.Minute ||
+		!cfg.AdvertisePeerID ||
+		len(cfg.StaticDelegates) != 1 {
+		t.Error("options should survive a ToJSON/LoadJSON roundtrip")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Default()
+	if cfg.Validate() != nil {
+		t.Fatal("error validating")
+	}
+
+	cfg.DelegatesSessionTTL = -1
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+}
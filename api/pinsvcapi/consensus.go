@@ -0,0 +1,35 @@
+package pinsvcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// consensusAvailable returns an error when the consensus component
+// reports that there is no leader. Consensus components which never have
+// a leader are considered available.
+func (api *API) consensusAvailable(ctx context.Context) error {
+	var leader peer.ID
+	err := api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Consensus",
+		"Leader",
+		struct{}{},
+		&leader,
+	)
+	switch {
+	case types.IsErrLeaderless(err):
+		return nil
+	case err != nil:
+		return fmt.Errorf("consensus unavailable: %w", err)
+	case leader == "":
+		return errors.New("consensus unavailable: no leader")
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package pinsvcapi
+
+import (
+	"context"
+	"time"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi/pinsvc"
+)
+
+// createdMetaKey is the pin metadata key storing the time at which a pin
+// was first created, when AnchorCreated is enabled.
+const createdMetaKey = "pinsvc_created"
+
+// anchorCreated sets the Created time of the status to the one stored in
+// the pin metadata, when present, and removes it from the metadata shown to
+// the user.
+func anchorCreated(status *pinsvc.PinStatus) {
+	v, ok := takeMeta(status, createdMetaKey)
+	if !ok {
+		return
+	}
+
+	var created time.Time
+	if err := created.UnmarshalText([]byte(v)); err == nil {
+		status.Created = created
+	}
+}
+
+// takeMeta removes the given key from the pin metadata of the status and
+// returns its value. The metadata map is copied, as it may be shared.
+func takeMeta(status *pinsvc.PinStatus, key string) (string, bool) {
+	v, ok := status.Pin.Meta[key]
+	if !ok {
+		return "", false
+	}
+
+	meta := make(map[string]string, len(status.Pin.Meta)-1)
+	for k, v := range status.Pin.Meta {
+		if k != key {
+			meta[k] = v
+		}
+	}
+	status.Pin.Meta = meta
+	return v, true
+}
+
+// withCreatedAnchor returns a copy of the metadata of the given pin which
+// includes its original creation time. This is the time stored in the
+// existing pin for the same cid, if any, or now.
+func (api *API) withCreatedAnchor(ctx context.Context, pin types.Pin) map[string]string {
+	created := time.Now()
+
+	var existing types.Pin
+	err := api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"PinGet",
+		pin.Cid,
+		&existing,
+	)
+	if err == nil && existing.Defined() {
+		st := pinsvc.PinStatus{
+			Created: existing.Timestamp,
+			Pin:     pinsvc.Pin{Meta: existing.Metadata},
+		}
+		anchorCreated(&st)
+		if !st.Created.IsZero() {
+			created = st.Created
+		}
+	}
+
+	meta := make(map[string]string, len(pin.Metadata)+1)
+	for k, v := range pin.Metadata {
+		meta[k] = v
+	}
+	ts, _ := created.UTC().MarshalText()
+	meta[createdMetaKey] = string(ts)
+	return meta
+}
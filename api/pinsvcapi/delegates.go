@@ -0,0 +1,163 @@
+package pinsvcapi
+
+import (
+	"sync"
+	"time"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// delegatesSession remembers which cluster peers have had their delegates
+// sent during a paginated listing.
+type delegatesSession struct {
+	seen   map[string]struct{}
+	expire time.Time
+}
+
+// filter returns the delegates in the given peer map, omitting those of
+// peers that were already seen in this session, and marks all peers as
+// seen.
+func (ds *delegatesSession) filter(peerMap map[string]types.PinInfoShort) []types.Multiaddr {
+	delegates := []types.Multiaddr{}
+	for pid, pi := range peerMap {
+		if _, ok := ds.seen[pid]; ok {
+			continue
+		}
+		ds.seen[pid] = struct{}{}
+		delegates = append(delegates, pi.IPFSAddresses...)
+	}
+	return delegates
+}
+
+// delegatesSessions tracks delegatesSession objects. Sessions are keyed by
+// the cursor that the client will send to request the next page, that is,
+// the "created" timestamp of the last result in the current page, which
+// is used as "before" value.
+type delegatesSessions struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[int64]*delegatesSession
+}
+
+func newDelegatesSessions(ttl time.Duration) *delegatesSessions {
+	return &delegatesSessions{
+		ttl:      ttl,
+		sessions: make(map[int64]*delegatesSession),
+	}
+}
+
+// take removes and returns the session for the given cursor. A new session
+// is returned when there is none (or it expired), or the cursor is zero.
+func (dss *delegatesSessions) take(cursor time.Time) *delegatesSession {
+	dss.mu.Lock()
+	defer dss.mu.Unlock()
+
+	now := time.Now()
+	for k, ds := range dss.sessions {
+		if now.After(ds.expire) {
+			delete(dss.sessions, k)
+		}
+	}
+
+	if !cursor.IsZero() {
+		ds, ok := dss.sessions[cursor.UnixNano()]
+		if ok {
+			delete(dss.sessions, cursor.UnixNano())
+			return ds
+		}
+	}
+	return &delegatesSession{
+		seen: make(map[string]struct{}),
+	}
+}
+
+// put stores the session so that it can be retrieved with the given cursor.
+func (dss *delegatesSessions) put(cursor time.Time, ds *delegatesSession) {
+	if cursor.IsZero() {
+		return
+	}
+
+	dss.mu.Lock()
+	defer dss.mu.Unlock()
+	ds.expire = time.Now().Add(dss.ttl)
+	dss.sessions[cursor.UnixNano()] = ds
+}
+
+// ipfsAddrsTTL is how long the IPFS addresses of a peer are cached when
+// resolving delegates asynchronously.
+const ipfsAddrsTTL = time.Minute
+
+type ipfsAddrs struct {
+	addrs  []types.Multiaddr
+	expire time.Time
+}
+
+// ipfsAddrsCache keeps the IPFS addresses of cluster peers so that
+// delegates can be provided without waiting for IPFSID lookups.
+type ipfsAddrsCache struct {
+	mu        sync.Mutex
+	peers     map[peer.ID]ipfsAddrs
+	resolving map[peer.ID]struct{}
+}
+
+func newIPFSAddrsCache() *ipfsAddrsCache {
+	return &ipfsAddrsCache{
+		peers:     make(map[peer.ID]ipfsAddrs),
+		resolving: make(map[peer.ID]struct{}),
+	}
+}
+
+// get returns the cached addresses for a peer. When they are not cached or
+// have expired, it returns false and, unless the peer is already being
+// resolved, true as second value, to signal that the caller should resolve
+// them.
+func (c *ipfsAddrsCache) get(p peer.ID) ([]types.Multiaddr, bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.peers[p]
+	if ok && time.Now().Before(entry.expire) {
+		return entry.addrs, true, false
+	}
+	if _, ok := c.resolving[p]; ok {
+		return nil, false, false
+	}
+	c.resolving[p] = struct{}{}
+	return nil, false, true
+}
+
+// set caches the addresses of a peer which has been resolved.
+func (c *ipfsAddrsCache) set(p peer.ID, addrs []types.Multiaddr) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.resolving, p)
+	c.peers[p] = ipfsAddrs{
+		addrs:  addrs,
+		expire: time.Now().Add(ipfsAddrsTTL),
+	}
+}
+
+// failed signals that resolving the addresses of the peer did not work.
+func (c *ipfsAddrsCache) failed(p peer.ID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.resolving, p)
+}
+
+// svcDelegates returns the delegates to send to clients given the ones
+// discovered from the IPFS daemons in the cluster, according to the
+// StaticDelegates configuration.
+func (api *API) svcDelegates(discovered []types.Multiaddr) []types.Multiaddr {
+	if len(api.config.StaticDelegates) == 0 {
+		return discovered
+	}
+	delegates := make([]types.Multiaddr, 0, len(api.config.StaticDelegates)+len(discovered))
+	delegates = append(delegates, api.config.StaticDelegates...)
+	if api.config.MergeStaticDelegates {
+		delegates = append(delegates, discovered...)
+	}
+	return delegates
+}
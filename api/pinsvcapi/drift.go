@@ -0,0 +1,156 @@
+package pinsvcapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/api/common"
+)
+
+// Drift directions.
+const (
+	driftUnder = "under"
+	driftOver  = "over"
+)
+
+// driftedPin describes a pin which is not pinned in the number of peers
+// given by its replication factors.
+type driftedPin struct {
+	Cid                  types.Cid `json:"cid"`
+	Name                 string    `json:"name"`
+	ReplicationFactorMin int       `json:"replication_factor_min"`
+	ReplicationFactorMax int       `json:"replication_factor_max"`
+	Pinned               int       `json:"pinned"`
+	Drift                string    `json:"drift"`
+}
+
+// driftList is the response of the replication drift endpoint.
+type driftList struct {
+	Count   uint64       `json:"count"`
+	Results []driftedPin `json:"results"`
+}
+
+// pinDrift returns the number of peers where the pin is pinned, according
+// to the given status, and whether that is under or over its replication
+// factors. The direction is empty when the pin is correctly replicated or
+// when its replication factors are unknown. Pins replicated everywhere are
+// expected to be pinned in every peer tracking them.
+func pinDrift(pin types.Pin, gpi types.GlobalPinInfo) (int, string) {
+	pinned := 0
+	for _, pi := range gpi.PeerMap {
+		if pi.Status == types.TrackerStatusPinned {
+			pinned++
+		}
+	}
+
+	rplMin := pin.ReplicationFactorMin
+	rplMax := pin.ReplicationFactorMax
+	if pin.IsPinEverywhere() {
+		rplMin = len(gpi.PeerMap)
+		rplMax = len(gpi.PeerMap)
+	}
+
+	switch {
+	case rplMin <= 0 || rplMax <= 0:
+		return pinned, ""
+	case pinned < rplMin:
+		return pinned, driftUnder
+	case pinned > rplMax:
+		return pinned, driftOver
+	}
+	return pinned, ""
+}
+
+// replicationDrift lists the pins which are pinned in less peers than their
+// replication factor minimum or in more peers than their maximum. The
+// "drift" query parameter limits results to one of the directions.
+func (api *API) replicationDrift(w http.ResponseWriter, r *http.Request) {
+	direction := r.URL.Query().Get("drift")
+	switch direction {
+	case "", driftUnder, driftOver:
+	default:
+		api.SendResponse(w, http.StatusBadRequest, errors.New("drift should be \"under\" or \"over\""), nil)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	pins, err := api.allPins(ctx)
+	if err != nil {
+		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+		return
+	}
+
+	in := make(chan types.TrackerStatus, 1)
+	in <- types.TrackerStatusUndefined
+	close(in)
+	out := make(chan types.GlobalPinInfo, common.StreamChannelSize)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		errCh <- api.rpcClient.Stream(
+			ctx,
+			"",
+			"Cluster",
+			"StatusAll",
+			in,
+			out,
+		)
+	}()
+
+	drifted := driftList{
+		Results: []driftedPin{},
+	}
+	for gpi := range out {
+		pin, ok := pins[gpi.Cid]
+		if !ok {
+			continue
+		}
+		pinned, drift := pinDrift(pin, gpi)
+		if drift == "" || (direction != "" && drift != direction) {
+			continue
+		}
+		drifted.Results = append(drifted.Results, driftedPin{
+			Cid:                  gpi.Cid,
+			Name:                 gpi.Name,
+			ReplicationFactorMin: pin.ReplicationFactorMin,
+			ReplicationFactorMax: pin.ReplicationFactorMax,
+			Pinned:               pinned,
+			Drift:                drift,
+		})
+	}
+	if err := <-errCh; err != nil {
+		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+		return
+	}
+	drifted.Count = uint64(len(drifted.Results))
+	api.SendResponse(w, common.SetStatusAutomatically, nil, drifted)
+}
+
+// allPins returns all the pins in the shared state, indexed by cid.
+func (api *API) allPins(ctx context.Context) (map[types.Cid]types.Pin, error) {
+	in := make(chan struct{})
+	close(in)
+	out := make(chan types.Pin, common.StreamChannelSize)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		errCh <- api.rpcClient.Stream(
+			ctx,
+			"",
+			"Cluster",
+			"Pins",
+			in,
+			out,
+		)
+	}()
+
+	pins := make(map[types.Cid]types.Pin)
+	for pin := range out {
+		pins[pin.Cid] = pin
+	}
+	return pins, <-errCh
+}
@@ -0,0 +1,20 @@
+package pinsvcapi
+
+import (
+	"errors"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi/pinsvc"
+)
+
+// apiError converts the errors sent by the API into the failure objects
+// defined in the spec. The reason is given by the HTTP status, unless the
+// error is already one of those objects, and the error message is used as
+// details.
+func apiError(err error, status int) error {
+	var apiErr pinsvc.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	return pinsvc.NewAPIError(pinsvc.ReasonForStatus(status), err.Error())
+}
@@ -0,0 +1,86 @@
+package pinsvcapi
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+)
+
+// inFlightPins keeps track of the pins each token has requested and that
+// have not reached a terminal state yet.
+type inFlightPins struct {
+	mu   sync.Mutex
+	pins map[string]map[types.Cid]struct{}
+}
+
+func newInFlightPins() *inFlightPins {
+	return &inFlightPins{
+		pins: make(map[string]map[types.Cid]struct{}),
+	}
+}
+
+// list returns the cids currently in flight for the given token.
+func (ifp *inFlightPins) list(token string) []types.Cid {
+	ifp.mu.Lock()
+	defer ifp.mu.Unlock()
+
+	cids := make([]types.Cid, 0, len(ifp.pins[token]))
+	for c := range ifp.pins[token] {
+		cids = append(cids, c)
+	}
+	return cids
+}
+
+// acquire adds the cid to the in-flight pins of the token, unless they are
+// already at the limit, in which case it returns false. Re-acquiring a cid
+// already in flight always succeeds.
+func (ifp *inFlightPins) acquire(token string, c types.Cid, limit int) bool {
+	ifp.mu.Lock()
+	defer ifp.mu.Unlock()
+
+	cids, ok := ifp.pins[token]
+	if !ok {
+		cids = make(map[types.Cid]struct{})
+		ifp.pins[token] = cids
+	}
+	if _, ok := cids[c]; ok {
+		return true
+	}
+	if len(cids) >= limit {
+		return false
+	}
+	cids[c] = struct{}{}
+	return true
+}
+
+// release removes the cid from the in-flight pins of the token.
+func (ifp *inFlightPins) release(token string, c types.Cid) {
+	ifp.mu.Lock()
+	defer ifp.mu.Unlock()
+
+	cids, ok := ifp.pins[token]
+	if !ok {
+		return
+	}
+	delete(cids, c)
+	if len(cids) == 0 {
+		delete(ifp.pins, token)
+	}
+}
+
+// requestToken identifies the credentials used in a request: the bearer
+// token when provided, or otherwise the basic auth username. Requests
+// without credentials are all identified by the empty string.
+func requestToken(r *http.Request) string {
+	const prefix = "Bearer "
+	authHeader := r.Header.Get("Authorization")
+	if len(authHeader) > len(prefix) && strings.EqualFold(authHeader[:len(prefix)], prefix) {
+		return authHeader[len(prefix):]
+	}
+	if username, _, ok := r.BasicAuth(); ok {
+		return "basic:" + username
+	}
+	return ""
+}
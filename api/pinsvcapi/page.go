@@ -0,0 +1,72 @@
+package pinsvcapi
+
+import (
+	"container/heap"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi/pinsvc"
+)
+
+type pageEntry struct {
+	st  pinsvc.PinStatus
+	gpi types.GlobalPinInfo
+}
+
+// page keeps the first limit pins, in listing order, among those added to
+// it, so that a page of results can be obtained while streaming the
+// status of all pins without holding them in memory. It is a heap with
+// the last pin in listing order at the top.
+type page struct {
+	limit   int
+	entries []pageEntry
+}
+
+func newPage(limit uint64) *page {
+	l := int(limit)
+	if uint64(l) != limit || l < 0 {
+		l = int(^uint(0) >> 1)
+	}
+	return &page{limit: l}
+}
+
+func (p *page) Len() int { return len(p.entries) }
+
+func (p *page) Less(i, j int) bool {
+	return pinsvc.CursorFor(p.entries[j].st).Precedes(p.entries[i].st)
+}
+
+func (p *page) Swap(i, j int) { p.entries[i], p.entries[j] = p.entries[j], p.entries[i] }
+
+func (p *page) Push(x interface{}) { p.entries = append(p.entries, x.(pageEntry)) }
+
+func (p *page) Pop() interface{} {
+	n := len(p.entries)
+	e := p.entries[n-1]
+	p.entries = p.entries[:n-1]
+	return e
+}
+
+// add offers a pin to the page. It is kept when it comes before any of the
+// pins in the page, or when the page is not full.
+func (p *page) add(st pinsvc.PinStatus, gpi types.GlobalPinInfo) {
+	if p.limit == 0 {
+		return
+	}
+	if len(p.entries) < p.limit {
+		heap.Push(p, pageEntry{st: st, gpi: gpi})
+		return
+	}
+	if pinsvc.CursorFor(st).Precedes(p.entries[0].st) {
+		p.entries[0] = pageEntry{st: st, gpi: gpi}
+		heap.Fix(p, 0)
+	}
+}
+
+// results returns the pins in the page in listing order.
+func (p *page) results() []pageEntry {
+	sorted := make([]pageEntry, len(p.entries))
+	for i := len(sorted) - 1; i >= 0; i-- {
+		sorted[i] = heap.Pop(p).(pageEntry)
+	}
+	return sorted
+}
@@ -2,9 +2,11 @@
 package pinsvc
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -19,8 +21,23 @@ func init() {
 	for k, v := range statusString {
 		stringStatus[v] = k
 	}
+
+	// initialize stringMatchingStrategy
+	stringMatchingStrategy = make(map[string]MatchingStrategy)
+	for k, v := range matchingStrategyString {
+		stringMatchingStrategy[v] = k
+	}
 }
 
+// Reasons for failures as defined in the spec. Errors with other statuses
+// use their status text in upper snake case as reason.
+const (
+	ReasonBadRequest          = "BAD_REQUEST"
+	ReasonUnauthorized        = "UNAUTHORIZED"
+	ReasonNotFound            = "NOT_FOUND"
+	ReasonInternalServerError = "INTERNAL_SERVER_ERROR"
+)
+
 // APIError is returned by the API as a body when an error
 // occurs. It implements the error interface.
 type APIError struct {
@@ -29,12 +46,46 @@ type APIError struct {
 
 // APIErrorDetails contains details about the APIError.
 type APIErrorDetails struct {
-	Reason  string `json:"reason"`
+	// Reason is a machine-readable identifier of the type of error.
+	Reason string `json:"reason"`
+	// Details is an optional human-readable description of the error.
 	Details string `json:"details,omitempty"`
 }
 
+// NewAPIError returns an APIError with the given reason and details.
+func NewAPIError(reason, details string) APIError {
+	return APIError{
+		Details: APIErrorDetails{
+			Reason:  reason,
+			Details: details,
+		},
+	}
+}
+
+// ReasonForStatus returns the reason used for failures with the given HTTP
+// status code.
+func ReasonForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ReasonBadRequest
+	case http.StatusUnauthorized:
+		return ReasonUnauthorized
+	case http.StatusNotFound:
+		return ReasonNotFound
+	}
+	text := http.StatusText(status)
+	if text == "" {
+		return ReasonInternalServerError
+	}
+	text = strings.ReplaceAll(text, "-", "_")
+	return strings.ToUpper(strings.ReplaceAll(text, " ", "_"))
+}
+
 func (apiErr APIError) Error() string {
-	return apiErr.Details.Reason
+	if apiErr.Details.Details == "" {
+		return apiErr.Details.Reason
+	}
+	return apiErr.Details.Reason + ": " + apiErr.Details.Details
 }
 
 // PinName is a string limited to 255 chars when serializing JSON.
@@ -75,37 +126,32 @@ func (p Pin) MatchesName(nameOpt string, strategy MatchingStrategy) bool {
 	if nameOpt == "" {
 		return true
 	}
-	name := string(p.Name)
-
-	switch strategy {
-	case MatchingStrategyUndefined:
-		return true
-
-	case MatchingStrategyExact:
-		return nameOpt == name
-	case MatchingStrategyIexact:
-		return strings.EqualFold(name, nameOpt)
-	case MatchingStrategyPartial:
-		return strings.Contains(name, nameOpt)
-	case MatchingStrategyIpartial:
-		return strings.Contains(strings.ToLower(name), strings.ToLower(nameOpt))
-	default:
-		return true
-	}
+	return strategy.Match(string(p.Name), nameOpt)
 }
 
 // MatchesMeta returns true if the pin status metadata matches the given.  The
 // metadata should have all the keys in the given metaOpts and the values
-// should, be the same (metadata map includes metaOpts).
-func (p Pin) MatchesMeta(metaOpts map[string]string) bool {
+// should match the given ones using the given strategy. Keys are always
+// matched exactly.
+func (p Pin) MatchesMeta(metaOpts map[string]string, strategy MatchingStrategy) bool {
 	for k, v := range metaOpts {
-		if p.Meta[k] != v {
+		if !strategy.Match(p.Meta[k], v) {
 			return false
 		}
 	}
 	return true
 }
 
+// MatchesOrigins returns true if the pin has origins when hasOrigins is
+// true, or if it has none when it is false. A nil hasOrigins matches any
+// pin.
+func (p Pin) MatchesOrigins(hasOrigins *bool) bool {
+	if hasOrigins == nil {
+		return true
+	}
+	return (len(p.Origins) > 0) == *hasOrigins
+}
+
 // Status represents a pin status, which defines the current state of the pin
 // in the system.
 type Status int
@@ -203,20 +249,55 @@ const (
 	MatchingStrategyIpartial
 )
 
+var matchingStrategyString = map[MatchingStrategy]string{
+	MatchingStrategyExact:    "exact",
+	MatchingStrategyIexact:   "iexact",
+	MatchingStrategyPartial:  "partial",
+	MatchingStrategyIpartial: "ipartial",
+}
+
+// values autofilled in init()
+var stringMatchingStrategy map[string]MatchingStrategy
+
+// String returns the name of the MatchingStrategy as used in the "match"
+// query parameter, or an empty string when undefined.
+func (ms MatchingStrategy) String() string {
+	return matchingStrategyString[ms]
+}
+
+// Match returns true if the value matches the given option using this
+// strategy. The undefined strategy matches everything.
+func (ms MatchingStrategy) Match(value, opt string) bool {
+	switch ms {
+	case MatchingStrategyExact:
+		return opt == value
+	case MatchingStrategyIexact:
+		return strings.EqualFold(value, opt)
+	case MatchingStrategyPartial:
+		return strings.Contains(value, opt)
+	case MatchingStrategyIpartial:
+		return strings.Contains(strings.ToLower(value), strings.ToLower(opt))
+	default:
+		return true
+	}
+}
+
 // MatchingStrategyFromString converts a string to its MatchingStrategy value.
 func MatchingStrategyFromString(str string) MatchingStrategy {
-	switch str {
-	case "exact":
-		return MatchingStrategyExact
-	case "iexact":
-		return MatchingStrategyIexact
-	case "partial":
-		return MatchingStrategyPartial
-	case "ipartial":
-		return MatchingStrategyIpartial
-	default:
-		return MatchingStrategyUndefined
+	return stringMatchingStrategy[str]
+}
+
+// MatchingStrategies returns the list of all supported (defined)
+// MatchingStrategy values, ordered by value.
+func MatchingStrategies() []MatchingStrategy {
+	strategies := make([]MatchingStrategy, 0, len(matchingStrategyString))
+	for ms := MatchingStrategyUndefined + 1; ; ms++ {
+		if _, ok := matchingStrategyString[ms]; !ok {
+			break
+		}
+		strategies = append(strategies, ms)
 	}
+	return strategies
 }
 
 // PinStatus provides information about a Pin stored by the Pinning API.
@@ -233,6 +314,81 @@ type PinStatus struct {
 type PinList struct {
 	Count   uint64      `json:"count"`
 	Results []PinStatus `json:"results"`
+	// Next is a continuation token to obtain the following page of
+	// results. It is empty when there are no more results.
+	Next string `json:"next,omitempty"`
+}
+
+// Cursor marks a position in a listing of pins, which are sorted from
+// newest to oldest, and by cid for pins created at the same time.
+type Cursor struct {
+	Created time.Time
+	Cid     types.Cid
+}
+
+// CursorFor returns a cursor pointing to the given pin status.
+func CursorFor(st PinStatus) Cursor {
+	return Cursor{
+		Created: st.Created,
+		Cid:     st.Pin.Cid,
+	}
+}
+
+// Defined returns true if the cursor points to a pin.
+func (c Cursor) Defined() bool {
+	return c.Cid.Defined()
+}
+
+// String returns the cursor encoded as a continuation token.
+func (c Cursor) String() string {
+	token := strconv.FormatInt(c.Created.UnixNano(), 10) + ":" + c.Cid.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(token))
+}
+
+// Precedes returns true when the position of the cursor comes before the
+// given pin status in a listing.
+func (c Cursor) Precedes(st PinStatus) bool {
+	return c.before(CursorFor(st))
+}
+
+// before returns true when c comes before c2 in a listing.
+func (c Cursor) before(c2 Cursor) bool {
+	if !c.Created.Equal(c2.Created) {
+		return c.Created.After(c2.Created)
+	}
+	return c.Cid.String() < c2.Cid.String()
+}
+
+// ParseCursor decodes a continuation token.
+func ParseCursor(token string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, err
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return Cursor{}, errors.New("malformed cursor")
+	}
+	ns, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return Cursor{}, err
+	}
+	c, err := types.DecodeCid(parts[1])
+	if err != nil {
+		return Cursor{}, err
+	}
+	return Cursor{Created: time.Unix(0, ns), Cid: c}, nil
+}
+
+// Capabilities describes optional features supported by this
+// implementation of the Pinning Services API so that clients can discover
+// them.
+type Capabilities struct {
+	// Match lists the values accepted by the "match" query parameter.
+	Match []string `json:"match"`
+	// MetaMatch lists the values accepted by the "meta_match" query
+	// parameter.
+	MetaMatch []string `json:"meta_match"`
 }
 
 // ListOptions represents possible options given to the List endpoint.
@@ -240,11 +396,16 @@ type ListOptions struct {
 	Cids             []types.Cid
 	Name             string
 	MatchingStrategy MatchingStrategy
-	Status           Status
-	Before           time.Time
-	After            time.Time
-	Limit            uint64
-	Meta             map[string]string
+	// MetaMatchingStrategy is used to match the values of Meta. It is
+	// set with the "meta_match" query parameter and defaults to exact.
+	MetaMatchingStrategy MatchingStrategy
+	Status               Status
+	Before               time.Time
+	After                time.Time
+	Limit                uint64
+	Meta                 map[string]string
+	HasOrigins           *bool
+	Cursor               Cursor
 }
 
 // FromQuery parses ListOptions from url.Values.
@@ -309,5 +470,44 @@ func (lo *ListOptions) FromQuery(q url.Values) error {
 		}
 	}
 
+	lo.MetaMatchingStrategy = MatchingStrategyFromString(q.Get("meta_match"))
+	if lo.MetaMatchingStrategy == MatchingStrategyUndefined {
+		lo.MetaMatchingStrategy = MatchingStrategyExact // default
+	}
+
+	if v := q.Get("has_origins"); v != "" {
+		hasOrigins, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("error parsing 'has_origins' query param: %s: %w", v, err)
+		}
+		lo.HasOrigins = &hasOrigins
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		cursor, err := ParseCursor(v)
+		if err != nil {
+			return fmt.Errorf("error decoding 'cursor' query param: %s: %w", v, err)
+		}
+		lo.Cursor = cursor
+	}
+
 	return nil
 }
+
+// Matches returns true when the pin status passes all the filters in the
+// list options, except for the cid and status filters, which are applied
+// when obtaining the status of the pins.
+func (lo *ListOptions) Matches(st PinStatus) bool {
+	if !lo.After.IsZero() && st.Created.Before(lo.After) {
+		return false
+	}
+	if !lo.Before.IsZero() && st.Created.After(lo.Before) {
+		return false
+	}
+	if lo.Cursor.Defined() && !lo.Cursor.Precedes(st) {
+		return false
+	}
+	return st.Pin.MatchesName(lo.Name, lo.MatchingStrategy) &&
+		st.Pin.MatchesMeta(lo.Meta, lo.MetaMatchingStrategy) &&
+		st.Pin.MatchesOrigins(lo.HasOrigins)
+}
@@ -12,13 +12,20 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	types "github.com/ipfs-cluster/ipfs-cluster/api"
 	"github.com/ipfs-cluster/ipfs-cluster/api/common"
 	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi/pinsvc"
+	"github.com/ipfs-cluster/ipfs-cluster/observations"
 	"github.com/ipfs-cluster/ipfs-cluster/state"
+	"go.opencensus.io/stats"
 	"go.uber.org/multierr"
 
 	logging "github.com/ipfs/go-log/v2"
@@ -33,11 +40,60 @@ var (
 )
 
 var apiInfo map[string]string = map[string]string{
+	"source":   "IPFS cluster API",
+	"warning2": "experimental",
+}
+
+// cidRequestIDInfo is sent instead of apiInfo when the cid is used as
+// requestID.
+var cidRequestIDInfo map[string]string = map[string]string{
 	"source":   "IPFS cluster API",
 	"warning1": "CID used for requestID. Conflicts possible",
 	"warning2": "experimental",
 }
 
+// info returns the Info sent with pin statuses.
+func (api *API) info() map[string]string {
+	if api.config.RequestIDMode == RequestIDModeCid {
+		return cidRequestIDInfo
+	}
+	return apiInfo
+}
+
+// infoWith returns a copy of the Info sent with pin statuses with the given
+// entry added to it.
+func (api *API) infoWith(key, value string) map[string]string {
+	base := api.info()
+	info := make(map[string]string, len(base)+1)
+	for k, v := range base {
+		info[k] = v
+	}
+	info[key] = value
+	return info
+}
+
+// allocationErrorStatus returns the status for a pin that was rejected
+// because it could not be allocated.
+func (api *API) allocationErrorStatus(rID string, p pinsvc.Pin, err error) pinsvc.PinStatus {
+	return pinsvc.PinStatus{
+		RequestID: rID,
+		Status:    pinsvc.StatusFailed,
+		Created:   time.Now(),
+		Pin:       p,
+		Delegates: []types.Multiaddr{},
+		Info:      api.infoWith("allocation_error", err.Error()),
+	}
+}
+
+// fromInternalMeta updates the status with the information that this API
+// stores in the pin metadata, which is not shown to the user. When owner is
+// not empty, only the requests of that owner are shown.
+func fromInternalMeta(status *pinsvc.PinStatus, owner string) {
+	anchorCreated(status)
+	requestIDFromMeta(status, owner)
+	takeMeta(status, ownerMetaKey)
+}
+
 func trackerStatusToSvcStatus(st types.TrackerStatus) pinsvc.Status {
 	switch {
 	case st.Match(types.TrackerStatusError):
@@ -71,31 +127,35 @@ func svcStatusToTrackerStatus(st pinsvc.Status) types.TrackerStatus {
 	return tst
 }
 
+// priorityMetaKey is the pin metadata key with which users set the
+// priority of their pins: low, normal or high. It is kept in the metadata.
+const priorityMetaKey = "priority"
+
 func svcPinToClusterPin(p pinsvc.Pin) (types.Pin, error) {
+	priority, err := types.PinPriorityFromString(p.Meta[priorityMetaKey])
+	if err != nil {
+		return types.Pin{}, fmt.Errorf("meta.%s: %w", priorityMetaKey, err)
+	}
 	opts := types.PinOptions{
 		Name:     string(p.Name),
 		Origins:  p.Origins,
 		Metadata: p.Meta,
 		Mode:     types.PinModeRecursive,
+		Priority: priority,
 	}
 	return types.PinWithOpts(p.Cid, opts), nil
 }
 
-func globalPinInfoToSvcPinStatus(
-	rID string,
-	gpi types.GlobalPinInfo,
-) pinsvc.PinStatus {
-
+// globalPinInfoToSvcPinStatus returns the status of the pin request with the
+// given requestID. The latest request for the pin is used when the
+// requestID is not one of those recorded for it. When owner is not empty,
+// only the requests of that owner are considered.
+func (api *API) globalPinInfoToSvcPinStatus(rID, owner string, gpi types.GlobalPinInfo) pinsvc.PinStatus {
 	status := pinsvc.PinStatus{
 		RequestID: rID,
 	}
 
-	var statusMask types.TrackerStatus
-	for _, pinfo := range gpi.PeerMap {
-		statusMask |= pinfo.Status
-	}
-
-	status.Status = trackerStatusToSvcStatus(statusMask)
+	status.Status = api.statusMapping.svcStatus(gpi.PeerMap)
 	status.Created = gpi.Created
 	status.Pin = pinsvc.Pin{
 		Cid:     gpi.Cid,
@@ -104,13 +164,14 @@ func globalPinInfoToSvcPinStatus(
 		Meta:    gpi.Metadata,
 	}
 
-	status.Info = apiInfo
+	status.Info = api.info()
 
 	status.Delegates = []types.Multiaddr{}
 	for _, pi := range gpi.PeerMap {
 		status.Delegates = append(status.Delegates, pi.IPFSAddresses...)
 	}
 
+	fromInternalMeta(&status, owner)
 	return status
 }
 
@@ -121,6 +182,23 @@ type API struct {
 
 	rpcClient *rpc.Client
 	config    *Config
+
+	delegatesSessions *delegatesSessions
+
+	peerIDMux sync.RWMutex
+	peerID    peer.ID
+
+	stuckQueued stuckQueued
+
+	inFlight *inFlightPins
+
+	statusMapping statusMapping
+
+	cidLocks *cidLocks
+
+	ipfsAddrs *ipfsAddrsCache
+
+	wg sync.WaitGroup
 }
 
 // NewAPI creates a new REST API component.
@@ -130,18 +208,47 @@ func NewAPI(ctx context.Context, cfg *Config) (*API, error) {
 
 // NewAPIWithHost creates a new REST API component using the given libp2p Host.
 func NewAPIWithHost(ctx context.Context, cfg *Config, h host.Host) (*API, error) {
+	sm, err := parseStatusMapping(cfg.StatusMapping)
+	if err != nil {
+		return nil, err
+	}
+
 	api := API{
-		config: cfg,
+		config:            cfg,
+		delegatesSessions: newDelegatesSessions(cfg.DelegatesSessionTTL),
+		inFlight:          newInFlightPins(),
+		statusMapping:     sm,
+		cidLocks:          newCidLocks(),
+		ipfsAddrs:         newIPFSAddrsCache(),
 	}
+	cfg.WebhookStatusFunc = api.webhookStatus
 	capi, err := common.NewAPIWithHost(ctx, &cfg.Config, h, api.routes)
 	api.API = capi
 	return &api, err
 }
 
+// SetClient makes the component ready to perform RPC requests and starts
+// any background tasks that need them.
+func (api *API) SetClient(c *rpc.Client) {
+	api.API.SetClient(c)
+
+	if api.config.StuckQueuedThreshold > 0 {
+		api.wg.Add(1)
+		go api.runStuckQueuedDetector(api.Context())
+	}
+}
+
+// Shutdown stops the API and waits for background tasks to finish.
+func (api *API) Shutdown(ctx context.Context) error {
+	err := api.API.Shutdown(ctx)
+	api.wg.Wait()
+	return err
+}
+
 // Routes returns endpoints supported by this API.
 func (api *API) routes(c *rpc.Client) []common.Route {
 	api.rpcClient = c
-	return []common.Route{
+	routes := []common.Route{
 		{
 			Name:        "ListPins",
 			Method:      "GET",
@@ -153,6 +260,14 @@ func (api *API) routes(c *rpc.Client) []common.Route {
 			Method:      "POST",
 			Pattern:     "/pins",
 			HandlerFunc: api.addPin,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "AddPins",
+			Method:      "POST",
+			Pattern:     "/pins/bulk",
+			HandlerFunc: api.addPins,
+			Scopes:      []common.Scope{common.ScopePin},
 		},
 		{
 			Name:        "GetPin",
@@ -164,21 +279,123 @@ func (api *API) routes(c *rpc.Client) []common.Route {
 			Name:        "ReplacePin",
 			Method:      "POST",
 			Pattern:     "/pins/{requestID}",
-			HandlerFunc: api.addPin,
+			HandlerFunc: api.replacePin,
+			Scopes:      []common.Scope{common.ScopePin, common.ScopeUnpin},
 		},
 		{
 			Name:        "RemovePin",
 			Method:      "DELETE",
 			Pattern:     "/pins/{requestID}",
 			HandlerFunc: api.removePin,
+			Scopes:      []common.Scope{common.ScopeUnpin},
 		},
 		{
 			Name:        "GetToken",
 			Method:      "POST",
 			Pattern:     "/token",
 			HandlerFunc: api.GenerateTokenHandler,
+			Scopes:      []common.Scope{common.ScopeRead},
+		},
+		{
+			Name:        "Capabilities",
+			Method:      "GET",
+			Pattern:     "/capabilities",
+			HandlerFunc: api.capabilities,
+		},
+		{
+			Name:        "StuckQueued",
+			Method:      "GET",
+			Pattern:     "/admin/stuck-queued",
+			HandlerFunc: api.stuckQueuedPins,
+			Scopes:      []common.Scope{common.ScopeAdmin},
+		},
+		{
+			Name:        "ReplicationDrift",
+			Method:      "GET",
+			Pattern:     "/admin/replication-drift",
+			HandlerFunc: api.replicationDrift,
+			Scopes:      []common.Scope{common.ScopeAdmin},
 		},
+		{
+			Name:        "Config",
+			Method:      "GET",
+			Pattern:     "/admin/config",
+			HandlerFunc: api.effectiveConfig,
+			Scopes:      []common.Scope{common.ScopeAdmin},
+		},
+	}
+
+	if api.config.AdvertisePeerID {
+		for i := range routes {
+			routes[i].HandlerFunc = api.peerHeaderHandler(routes[i].HandlerFunc)
+		}
+	}
+
+	if len(api.config.RPCContextValues) > 0 {
+		for i := range routes {
+			routes[i].HandlerFunc = api.contextHandler(routes[i].HandlerFunc)
+		}
+	}
+	return routes
+}
+
+// peerHeaderHandler wraps a handler so that responses carry the
+// X-Cluster-Peer header with the ID of the local cluster peer.
+func (api *API) peerHeaderHandler(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if pid := api.localPeerID(r.Context()); pid != "" {
+			w.Header().Set("X-Cluster-Peer", pid.String())
+		}
+		h(w, r)
+	}
+}
+
+// localPeerID returns the ID of the cluster peer, which is obtained via RPC
+// on the first call and cached afterwards. An empty ID is returned on error.
+func (api *API) localPeerID(ctx context.Context) peer.ID {
+	api.peerIDMux.RLock()
+	pid := api.peerID
+	api.peerIDMux.RUnlock()
+	if pid != "" {
+		return pid
+	}
+
+	var id types.ID
+	err := api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"ID",
+		struct{}{},
+		&id,
+	)
+	if err != nil {
+		api.config.Logger.Errorf("error obtaining local peer ID: %s", err)
+		return ""
+	}
+
+	api.peerIDMux.Lock()
+	api.peerID = id.ID
+	api.peerIDMux.Unlock()
+	return id.ID
+}
+
+// loggableMeta returns a representation of the given pin metadata that is
+// safe to log, according to the MetaLogFormat option. Values are never
+// included.
+func (api *API) loggableMeta(meta map[string]string) string {
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if api.config.MetaLogFormat == MetaLogRedacted {
+		for i, k := range keys {
+			keys[i] = k + ":<redacted>"
+		}
 	}
+	return "[" + strings.Join(keys, " ") + "]"
 }
 
 func (api *API) parseBodyOrFail(w http.ResponseWriter, r *http.Request) pinsvc.Pin {
@@ -200,6 +417,36 @@ func (api *API) parseRequestIDOrFail(w http.ResponseWriter, r *http.Request) (ty
 	if !ok {
 		return types.CidUndef, true
 	}
+
+	if api.config.RequestIDMode != RequestIDModeCid {
+		c, err := api.findRequestID(r.Context(), cStr)
+		if err == nil {
+			return c, true
+		}
+		if err.Error() != state.ErrNotFound.Error() {
+			api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+			return c, false
+		}
+		// Otherwise, it may be a pin added before, identified by
+		// its cid, as long as it has no requestIDs of its own which
+		// other clients hold. Anything else is an unknown requestID.
+		c, err = types.DecodeCid(cStr)
+		if err != nil {
+			api.SendResponse(w, http.StatusNotFound, errors.New("requestID not found"), nil)
+			return c, false
+		}
+		existing, err := api.existingPin(r.Context(), c)
+		if err != nil {
+			api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+			return c, false
+		}
+		if hasRequestIDs(existing.Metadata) {
+			api.SendResponse(w, http.StatusNotFound, errors.New("requestID not found"), nil)
+			return c, false
+		}
+		return c, true
+	}
+
 	c, err := types.DecodeCid(cStr)
 	if err != nil {
 		api.SendResponse(w, http.StatusBadRequest, errors.New("error decoding requestID: "+err.Error()), nil)
@@ -209,56 +456,240 @@ func (api *API) parseRequestIDOrFail(w http.ResponseWriter, r *http.Request) (ty
 }
 
 func (api *API) addPin(w http.ResponseWriter, r *http.Request) {
+	wait, ok := api.parseWaitOrFail(w, r)
+	if !ok {
+		return
+	}
 	if pin := api.parseBodyOrFail(w, r); pin.Defined() {
-		api.config.Logger.Debugf("addPin: %s", pin.Cid)
-		clusterPin, err := svcPinToClusterPin(pin)
+		api.config.Logger.Debugf("addPin: %s (meta: %s)", pin.Cid, api.loggableMeta(pin.Meta))
+		api.pinWithUpdate(w, r, pin, types.CidUndef, wait)
+	}
+}
+
+// replacePin is only used for /pins/{requestID}. It should never be reached
+// without a requestID (i.e. "POST /pins/" is redirected to "POST /pins"), but
+// we guard against it so that replace semantics are never applied to things
+// which are not a replacement.
+func (api *API) replacePin(w http.ResponseWriter, r *http.Request) {
+	updateCid, ok := api.parseRequestIDOrFail(w, r)
+	if !ok {
+		return
+	}
+	if !updateCid.Defined() {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("error decoding requestID: empty requestID"), nil)
+		return
+	}
+	if !api.ownedOrFail(w, r, updateCid) || !api.removableOrFail(w, r, updateCid, mux.Vars(r)["requestID"]) {
+		return
+	}
+
+	if pin := api.parseBodyOrFail(w, r); pin.Defined() {
+		api.config.Logger.Debugf("replacePin: %s -> %s (meta: %s)", updateCid, pin.Cid, api.loggableMeta(pin.Meta))
+		api.pinWithUpdate(w, r, pin, updateCid, 0)
+	}
+}
+
+// pinWithUpdate pins the given pin and, when updateCid is defined, sets it as
+// PinUpdate and unpins it afterwards. When wait is not 0, it waits up to that
+// long for the pin to be pinned before responding.
+func (api *API) pinWithUpdate(w http.ResponseWriter, r *http.Request, pin pinsvc.Pin, updateCid types.Cid, wait time.Duration) {
+	if !api.consensusAvailableOrFail(w, r) {
+		return
+	}
+
+	status, code, err := api.pin(r, pin, updateCid, mux.Vars(r)["requestID"])
+	if err != nil {
+		api.SendResponse(w, code, err, nil)
+		return
+	}
+	if wait > 0 {
+		status = api.waitPinned(r.Context(), pin.Cid, status, wait)
+	}
+	api.SendResponse(w, code, nil, status)
+}
+
+// consensusAvailableOrFail checks that consensus is available, when
+// CheckConsensusLeader is enabled, and sends a 503 response otherwise.
+func (api *API) consensusAvailableOrFail(w http.ResponseWriter, r *http.Request) bool {
+	if !api.config.CheckConsensusLeader {
+		return true
+	}
+	if err := api.consensusAvailable(r.Context()); err != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(int(api.config.ConsensusRetryAfter.Seconds())))
+		api.SendResponse(w, http.StatusServiceUnavailable, err, nil)
+		return false
+	}
+	return true
+}
+
+// pin pins the given item on behalf of the client making the request,
+// replacing the request with the given requestID for updateCid when
+// defined. It returns the status of the pin and the HTTP status code with
+// which it should be sent, or the error to send.
+func (api *API) pin(r *http.Request, pin pinsvc.Pin, updateCid types.Cid, updateRID string) (pinsvc.PinStatus, int, error) {
+	ctx := r.Context()
+	token := requestToken(r)
+	if err := checkUserMeta(pin.Meta); err != nil {
+		return pinsvc.PinStatus{}, http.StatusBadRequest, err
+	}
+	clusterPin, err := svcPinToClusterPin(pin)
+	if err != nil {
+		return pinsvc.PinStatus{}, http.StatusBadRequest, err
+	}
+	clusterPin.PinUpdate = updateCid
+	clusterPin.Owner, _ = common.RequestIdentity(r)
+	if api.config.AnchorCreated {
+		clusterPin.Metadata = api.withCreatedAnchor(ctx, clusterPin)
+	}
+
+	unique := api.config.UniquePinsPerOwner && !updateCid.Defined()
+	scoped := unique || api.config.TenantScoping || api.config.EnforcePinOwnership
+	// The cid is unlocked before removing the replaced request, which
+	// locks its own cid.
+	unlock := func() {}
+	defer func() { unlock() }()
+	var existing types.Pin
+	if scoped || api.config.RequestIDMode != RequestIDModeCid {
+		unlock = api.cidLocks.lock(pin.Cid)
+		existing, err = api.existingPin(ctx, pin.Cid)
 		if err != nil {
-			api.SendResponse(w, common.SetStatusAutomatically, err, nil)
-			return
+			return pinsvc.PinStatus{}, common.SetStatusAutomatically, err
 		}
+	}
 
-		if updateCid, ok := api.parseRequestIDOrFail(w, r); updateCid.Defined() && ok {
-			clusterPin.PinUpdate = updateCid
+	// Every owner of a pin keeps its own request for it, so that it can
+	// be shared by several tenants and stays pinned until all of them
+	// remove it.
+	var owner string
+	if scoped {
+		owner = requestOwner(r)
+	}
+	if unique {
+		if status, ok := api.existingPinStatus(ctx, existing, pin, owner); ok {
+			return status, http.StatusOK, nil
 		}
+	}
 
-		// Pin item
-		var pinObj types.Pin
-		err = api.rpcClient.CallContext(
-			r.Context(),
-			"",
-			"Cluster",
-			"Pin",
-			clusterPin,
-			&pinObj,
-		)
+	// Pins which the client cannot modify keep their options and
+	// metadata, and only get the record of the new request.
+	if identity, ok := api.OwnershipIdentity(r); ok && existing.Defined() && !existing.ModifiableBy(identity) {
+		clusterPin = existing
+	}
+
+	rec := newRequestRecord(pin, owner)
+	rID := pin.Cid.String()
+	switch api.config.RequestIDMode {
+	case RequestIDModeDeterministic:
+		rID = deterministicRequestID(pin.Cid, string(pin.Name), pin.Origins, token)
+		clusterPin.Metadata = withRequestID(clusterPin.Metadata, existing, rID, rec)
+	case RequestIDModeUUID:
+		rID = uuid.NewString()
+		clusterPin.Metadata = withRequestID(clusterPin.Metadata, existing, rID, rec)
+	default:
+		// The requestID is the cid, so every owner has a single
+		// request for it, recorded with its owner as key.
+		if owner != "" {
+			clusterPin.Metadata = withRecord(clusterPin.Metadata, existing, owner, rec)
+		}
+	}
+
+	if !api.acquireInFlight(ctx, token, pin.Cid) {
+		err := fmt.Errorf("too many pins in flight: the limit is %d", api.config.MaxInFlightPinsPerToken)
+		return pinsvc.PinStatus{}, http.StatusTooManyRequests, err
+	}
+
+	// Pin item
+	var pinObj types.Pin
+	err = api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"Pin",
+		clusterPin,
+		&pinObj,
+	)
+	if err != nil {
+		api.inFlight.release(token, pin.Cid)
+	}
+	if types.IsErrInsufficientAllocations(err) {
+		return api.allocationErrorStatus(rID, pin, err), http.StatusConflict, nil
+	}
+	if err != nil {
+		return pinsvc.PinStatus{}, common.SetStatusAutomatically, err
+	}
+
+	// Remove the old request, unless it is the one just made.
+	unlock()
+	unlock = func() {}
+	if updateCid.Defined() && updateRID != rID {
+		err = api.removeRequest(r, updateCid, updateRID)
 		if err != nil {
-			api.SendResponse(w, common.SetStatusAutomatically, err, nil)
-			return
+			return pinsvc.PinStatus{}, common.SetStatusAutomatically, err
 		}
+	}
 
-		// Unpin old item
-		if clusterPin.PinUpdate.Defined() {
-			var oldPin types.Pin
-			err = api.rpcClient.CallContext(
-				r.Context(),
-				"",
-				"Cluster",
-				"Unpin",
-				types.PinCid(clusterPin.PinUpdate),
-				&oldPin,
-			)
-			if err != nil {
-				api.SendResponse(w, common.SetStatusAutomatically, err, nil)
-				return
+	status := api.pinToSvcPinStatus(ctx, pin.Cid.String(), owner, pinObj)
+	return status, common.SetStatusAutomatically, nil
+}
+
+// removeRequest removes the request with the given requestID for the given
+// cid made by the client making the request. A pin requested several times,
+// with different requestIDs or by different owners, stays pinned until the
+// last of its requests is removed.
+func (api *API) removeRequest(r *http.Request, c types.Cid, rID string) error {
+	ctx := r.Context()
+	api.inFlight.release(requestToken(r), c)
+
+	byRequestID := api.config.RequestIDMode != RequestIDModeCid
+	if byRequestID || api.config.TenantScoping {
+		unlock := api.cidLocks.lock(c)
+		defer unlock()
+		owner := requestOwner(r)
+		dropped, err := api.dropRequests(ctx, c, func(key string, rec requestRecord) bool {
+			if byRequestID {
+				return key == rID
 			}
+			return rec.Owner == owner
+		})
+		if err != nil || dropped {
+			return err
 		}
+	}
 
-		status := api.pinToSvcPinStatus(r.Context(), pin.Cid.String(), pinObj)
-		api.SendResponse(w, common.SetStatusAutomatically, nil, status)
+	var pinObj types.Pin
+	return api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"Unpin",
+		types.PinCid(c),
+		&pinObj,
+	)
+}
+
+// acquireInFlight takes an in-flight slot for the given cid on behalf of the
+// given token. When the token is at the limit, the slots of pins that have
+// reached a terminal state since they were requested are freed before
+// trying again.
+func (api *API) acquireInFlight(ctx context.Context, token string, c types.Cid) bool {
+	limit := api.config.MaxInFlightPinsPerToken
+	if limit <= 0 {
+		return true
 	}
+	if api.inFlight.acquire(token, c, limit) {
+		return true
+	}
+
+	for _, ifc := range api.inFlight.list(token) {
+		st, err := api.getPinSvcStatus(ctx, ifc)
+		if err != nil || (st.Status != pinsvc.StatusQueued && st.Status != pinsvc.StatusPinning) {
+			api.inFlight.release(token, ifc)
+		}
+	}
+	return api.inFlight.acquire(token, c, limit)
 }
 
-func (api *API) getPinSvcStatus(ctx context.Context, c types.Cid) (pinsvc.PinStatus, error) {
+func (api *API) getGlobalPinInfo(ctx context.Context, c types.Cid) (types.GlobalPinInfo, error) {
 	var pinInfo types.GlobalPinInfo
 
 	err := api.rpcClient.CallContext(
@@ -269,10 +700,43 @@ func (api *API) getPinSvcStatus(ctx context.Context, c types.Cid) (pinsvc.PinSta
 		c,
 		&pinInfo,
 	)
+	return pinInfo, err
+}
+
+// getLocalPinInfo returns the status of the given cid in this peer only,
+// without contacting the rest of the cluster.
+func (api *API) getLocalPinInfo(ctx context.Context, c types.Cid) (types.GlobalPinInfo, error) {
+	var pinInfo types.PinInfo
+
+	err := api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"StatusLocal",
+		c,
+		&pinInfo,
+	)
+	if err != nil {
+		return types.GlobalPinInfo{}, err
+	}
+	return pinInfo.ToGlobal(), nil
+}
+
+func (api *API) getPinSvcStatus(ctx context.Context, c types.Cid) (pinsvc.PinStatus, error) {
+	return api.getPinSvcStatusFrom(ctx, c, c.String(), "", api.getGlobalPinInfo)
+}
+
+// getPinSvcStatusFrom returns the pinsvc status of the request with the
+// given requestID for the given cid, among those of the given owner when
+// not empty, using the given function to obtain its GlobalPinInfo.
+func (api *API) getPinSvcStatusFrom(ctx context.Context, c types.Cid, rID, owner string, getInfo func(context.Context, types.Cid) (types.GlobalPinInfo, error)) (pinsvc.PinStatus, error) {
+	pinInfo, err := getInfo(ctx, c)
 	if err != nil {
 		return pinsvc.PinStatus{}, err
 	}
-	return globalPinInfoToSvcPinStatus(c.String(), pinInfo), nil
+	status := api.globalPinInfoToSvcPinStatus(rID, owner, pinInfo)
+	status.Delegates = api.svcDelegates(status.Delegates)
+	return status, nil
 
 }
 
@@ -282,11 +746,31 @@ func (api *API) getPin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	api.config.Logger.Debugf("getPin: %s", c)
-	status, err := api.getPinSvcStatus(r.Context(), c)
+
+	// peers=local returns the status in this peer only, which avoids
+	// asking every peer in the cluster.
+	getInfo := api.getGlobalPinInfo
+	switch r.URL.Query().Get("peers") {
+	case "", "all":
+	case "local":
+		getInfo = api.getLocalPinInfo
+	default:
+		api.SendResponse(w, http.StatusBadRequest, errors.New("peers should be \"local\" or \"all\""), nil)
+		return
+	}
+
+	if !api.ownedOrFail(w, r, c) {
+		return
+	}
+	// The pin may have several requestIDs: this is the one that was
+	// asked for.
+	rID := mux.Vars(r)["requestID"]
+	status, err := api.getPinSvcStatusFrom(r.Context(), c, rID, api.scopedOwner(r), getInfo)
 	if status.Status == pinsvc.StatusUndefined {
 		api.SendResponse(w, http.StatusNotFound, errors.New("pin not found"), nil)
 		return
 	}
+	status.RequestID = rID
 	api.SendResponse(w, common.SetStatusAutomatically, err, status)
 }
 
@@ -296,15 +780,12 @@ func (api *API) removePin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	api.config.Logger.Debugf("removePin: %s", c)
-	var pinObj types.Pin
-	err := api.rpcClient.CallContext(
-		r.Context(),
-		"",
-		"Cluster",
-		"Unpin",
-		types.PinCid(c),
-		&pinObj,
-	)
+	rID := mux.Vars(r)["requestID"]
+	if !api.ownedOrFail(w, r, c) || !api.removableOrFail(w, r, c, rID) {
+		return
+	}
+
+	err := api.removeRequest(r, c, rID)
 	if err != nil && err.Error() == state.ErrNotFound.Error() {
 		api.SendResponse(w, http.StatusNotFound, err, nil)
 		return
@@ -319,16 +800,31 @@ func (api *API) listPins(w http.ResponseWriter, r *http.Request) {
 		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
 		return
 	}
-	tst := svcStatusToTrackerStatus(opts.Status)
+	tst := api.statusMapping.trackerStatusFilter(opts.Status)
+	if wantsStream(r) {
+		api.streamPins(w, r, opts, tst)
+		return
+	}
+	owner := api.scopedOwner(r)
 
 	var pinList pinsvc.PinList
 	pinList.Results = []pinsvc.PinStatus{}
 	count := uint64(0)
 
+	var session *delegatesSession
+	if api.config.SuppressRepeatedDelegates {
+		key := opts.Before
+		if opts.Cursor.Defined() {
+			key = opts.Cursor.Created
+		}
+		session = api.delegatesSessions.take(key)
+	}
+
 	if len(opts.Cids) > 0 {
 		// copy approach from restapi
 		type statusResult struct {
 			st  pinsvc.PinStatus
+			gpi types.GlobalPinInfo
 			err error
 		}
 		stCh := make(chan statusResult, len(opts.Cids))
@@ -343,8 +839,14 @@ func (api *API) listPins(w http.ResponseWriter, r *http.Request) {
 		for _, ci := range opts.Cids {
 			go func(c types.Cid) {
 				defer wg.Done()
-				st, err := api.getPinSvcStatus(r.Context(), c)
-				stCh <- statusResult{st: st, err: err}
+				gpi, err := api.getGlobalPinInfo(r.Context(), c)
+				var st pinsvc.PinStatus
+				// Pins from other owners are skipped like
+				// those with undefined status.
+				if err == nil && api.ownedBy(gpi.Metadata, owner) {
+					st = api.globalPinInfoToSvcPinStatus(c.String(), owner, gpi)
+				}
+				stCh <- statusResult{st: st, gpi: gpi, err: err}
 			}(ci)
 		}
 
@@ -357,6 +859,10 @@ func (api *API) listPins(w http.ResponseWriter, r *http.Request) {
 			}
 
 			if count < opts.Limit {
+				if session != nil {
+					stResult.st.Delegates = session.filter(stResult.gpi.PeerMap)
+				}
+				stResult.st.Delegates = api.svcDelegates(stResult.st.Delegates)
 				pinList.Results = append(pinList.Results, stResult.st)
 				err = multierr.Append(err, stResult.err)
 			}
@@ -368,48 +874,29 @@ func (api *API) listPins(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	} else {
-		in := make(chan types.TrackerStatus, 1)
-		in <- tst
-		close(in)
 		out := make(chan types.GlobalPinInfo, common.StreamChannelSize)
 		errCh := make(chan error, 1)
 
 		go func() {
 			defer close(errCh)
 
-			errCh <- api.rpcClient.Stream(
-				r.Context(),
-				"",
-				"Cluster",
-				"StatusAll",
-				in,
-				out,
-			)
+			errCh <- api.streamStatusAll(r.Context(), opts, tst, out)
 		}()
 
+		pg := newPage(opts.Limit)
 		for gpi := range out {
-			st := globalPinInfoToSvcPinStatus(gpi.Cid.String(), gpi)
-			if st.Status == pinsvc.StatusUndefined {
-				// i.e things unpinning
-				continue
-			}
-			if !opts.After.IsZero() && st.Created.Before(opts.After) {
+			if !api.ownedBy(gpi.Metadata, owner) {
 				continue
 			}
-
-			if !opts.Before.IsZero() && st.Created.After(opts.Before) {
-				continue
-			}
-
-			if !st.Pin.MatchesName(opts.Name, opts.MatchingStrategy) {
+			st := api.globalPinInfoToSvcPinStatus(gpi.Cid.String(), owner, gpi)
+			if st.Status == pinsvc.StatusUndefined {
+				// i.e things unpinning
 				continue
 			}
-			if !st.Pin.MatchesMeta(opts.Meta) {
+			if !opts.Matches(st) {
 				continue
 			}
-			if count < opts.Limit {
-				pinList.Results = append(pinList.Results, st)
-			}
+			pg.add(st, gpi)
 			count++
 		}
 
@@ -418,13 +905,60 @@ func (api *API) listPins(w http.ResponseWriter, r *http.Request) {
 			api.SendResponse(w, common.SetStatusAutomatically, err, nil)
 			return
 		}
+
+		for _, e := range pg.results() {
+			if session != nil {
+				e.st.Delegates = session.filter(e.gpi.PeerMap)
+			}
+			e.st.Delegates = api.svcDelegates(e.st.Delegates)
+			pinList.Results = append(pinList.Results, e.st)
+		}
 	}
 
 	pinList.Count = count
-	api.SendResponse(w, common.SetStatusAutomatically, err, pinList)
+	if maxSize := api.config.MaxListPinsResponseSize; maxSize > 0 && truncateToSize(&pinList, maxSize) {
+		last := pinList.Results[len(pinList.Results)-1].Created
+		w.Header().Set("X-Truncated", "true")
+		w.Header().Set("X-Next-Before", last.Format(time.RFC3339Nano))
+	}
+
+	// Set the continuation token and remember the delegates sent so far
+	// if there are more pages.
+	if n := len(pinList.Results); n > 0 && count > uint64(n) {
+		pinList.Next = pinsvc.CursorFor(pinList.Results[n-1]).String()
+		if session != nil {
+			api.delegatesSessions.put(pinList.Results[n-1].Created, session)
+		}
+	}
+
+	cw := &countingWriter{ResponseWriter: w}
+	api.SendResponse(cw, common.SetStatusAutomatically, err, pinList)
+	stats.Record(r.Context(), observations.ListPinsSize.M(cw.n))
 }
 
-func (api *API) pinToSvcPinStatus(ctx context.Context, rID string, pin types.Pin) pinsvc.PinStatus {
+func (api *API) capabilities(w http.ResponseWriter, r *http.Request) {
+	caps := pinsvc.Capabilities{
+		Match:     []string{},
+		MetaMatch: []string{},
+	}
+	for _, ms := range pinsvc.MatchingStrategies() {
+		caps.Match = append(caps.Match, ms.String())
+		caps.MetaMatch = append(caps.MetaMatch, ms.String())
+	}
+	api.SendResponse(w, common.SetStatusAutomatically, nil, caps)
+}
+
+// effectiveConfig returns the configuration in use, with secrets hidden.
+func (api *API) effectiveConfig(w http.ResponseWriter, r *http.Request) {
+	raw, err := api.config.ToDisplayJSON()
+	if err != nil {
+		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+		return
+	}
+	api.SendResponse(w, common.SetStatusAutomatically, nil, json.RawMessage(raw))
+}
+
+func (api *API) pinToSvcPinStatus(ctx context.Context, rID, owner string, pin types.Pin) pinsvc.PinStatus {
 	status := pinsvc.PinStatus{
 		RequestID: rID,
 		Status:    pinsvc.StatusQueued,
@@ -435,8 +969,9 @@ func (api *API) pinToSvcPinStatus(ctx context.Context, rID string, pin types.Pin
 			Origins: pin.Origins,
 			Meta:    pin.Metadata,
 		},
-		Info: apiInfo,
+		Info: api.info(),
 	}
+	fromInternalMeta(&status, owner)
 
 	var peers []peer.ID
 
@@ -458,20 +993,61 @@ func (api *API) pinToSvcPinStatus(ctx context.Context, rID string, pin types.Pin
 
 	status.Delegates = []types.Multiaddr{}
 	for _, peer := range peers {
-		var ipfsid types.IPFSID
-		err := api.rpcClient.CallContext(
-			ctx,
-			"", // call the local peer
-			"Cluster",
-			"IPFSID",
-			peer, // retrieve ipfs info for this peer
-			&ipfsid,
-		)
-		if err != nil {
-			logger.Error(err)
+		if api.config.AsyncDelegates {
+			status.Delegates = append(status.Delegates, api.cachedDelegates(peer)...)
+			continue
 		}
-		status.Delegates = append(status.Delegates, ipfsid.Addresses...)
+		status.Delegates = append(status.Delegates, api.resolveDelegates(ctx, peer)...)
 	}
+	status.Delegates = api.svcDelegates(status.Delegates)
 
 	return status
 }
+
+// resolveDelegates returns the addresses of the IPFS daemon attached to the
+// given cluster peer.
+func (api *API) resolveDelegates(ctx context.Context, p peer.ID) []types.Multiaddr {
+	var ipfsid types.IPFSID
+	err := api.rpcClient.CallContext(
+		ctx,
+		"", // call the local peer
+		"Cluster",
+		"IPFSID",
+		p, // retrieve ipfs info for this peer
+		&ipfsid,
+	)
+	if err != nil {
+		logger.Error(err)
+	}
+	return ipfsid.Addresses
+}
+
+// cachedDelegates returns the cached IPFS addresses for the given cluster
+// peer. When they are not known yet, the peer itself is returned as
+// provisional delegate and the addresses are resolved in the background.
+func (api *API) cachedDelegates(p peer.ID) []types.Multiaddr {
+	addrs, ok, resolve := api.ipfsAddrs.get(p)
+	if ok {
+		return addrs
+	}
+
+	if resolve {
+		api.wg.Add(1)
+		go func() {
+			defer api.wg.Done()
+			ctx := api.Context()
+			addrs := api.resolveDelegates(ctx, p)
+			if ctx.Err() != nil || len(addrs) == 0 {
+				api.ipfsAddrs.failed(p)
+				return
+			}
+			api.ipfsAddrs.set(p, addrs)
+		}()
+	}
+
+	provisional, err := types.NewMultiaddr("/p2p/" + p.String())
+	if err != nil {
+		return nil
+	}
+	return []types.Multiaddr{provisional}
+}
@@ -1,22 +1,38 @@
 package pinsvcapi
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/api/common"
 	"github.com/ipfs-cluster/ipfs-cluster/api/common/test"
 	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi/pinsvc"
+	"github.com/ipfs-cluster/ipfs-cluster/state"
 	clustertest "github.com/ipfs-cluster/ipfs-cluster/test"
 
+	logging "github.com/ipfs/go-log/v2"
 	libp2p "github.com/libp2p/go-libp2p"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	peer "github.com/libp2p/go-libp2p/core/peer"
 	ma "github.com/multiformats/go-multiaddr"
 )
 
 func testAPIwithConfig(t *testing.T, cfg *Config, name string) *API {
+	return testAPIwithClient(t, cfg, name, clustertest.NewMockRPCClient(t))
+}
+
+func testAPIwithClient(t *testing.T, cfg *Config, name string, c *rpc.Client) *API {
 	ctx := context.Background()
 	apiMAddr, _ := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/0")
 	h, err := libp2p.New(libp2p.ListenAddrs(apiMAddr))
@@ -33,7 +49,7 @@ func testAPIwithConfig(t *testing.T, cfg *Config, name string) *API {
 
 	// No keep alive for tests
 	svcapi.SetKeepAlivesEnabled(false)
-	svcapi.SetClient(clustertest.NewMockRPCClient(t))
+	svcapi.SetClient(c)
 
 	return svcapi
 }
@@ -141,6 +157,23 @@ func TestAPIListEndpoint(t *testing.T) {
 			t.Errorf("unexpected statusAll+meta resp:\n %+v", resp11)
 		}
 
+		// Test with meta-match strategies
+		var resp12 pinsvc.PinList
+		test.MakeGet(t, svcapi, url(svcapi)+`/pins?meta={"ccc":"3"}&meta_match=partial`, &resp12)
+		if resp12.Count != 1 || len(resp12.Results) != 1 {
+			t.Errorf("unexpected statusAll+meta partial resp:\n %+v", resp12)
+		}
+		var resp13 pinsvc.PinList
+		test.MakeGet(t, svcapi, url(svcapi)+`/pins?meta={"ccc":"3C"}&meta_match=iexact&limit=1`, &resp13)
+		if resp13.Count != 1 || len(resp13.Results) != 1 {
+			t.Errorf("unexpected statusAll+meta iexact resp:\n %+v", resp13)
+		}
+		var resp14 pinsvc.PinList
+		test.MakeGet(t, svcapi, url(svcapi)+`/pins?meta={"ccc":"3C"}`, &resp14)
+		if resp14.Count != 0 {
+			t.Errorf("unexpected statusAll+meta exact resp:\n %+v", resp14)
+		}
+
 		var errorResp pinsvc.APIError
 		test.MakeGet(t, svcapi, url(svcapi)+"/pins?status=invalid", &errorResp)
 		if errorResp.Details.Reason == "" {
@@ -200,14 +233,49 @@ func TestAPIPinEndpoint(t *testing.T) {
 			t.Fatal(err)
 		}
 		test.MakePost(t, svcapi, url(svcapi)+"/pins", pinJSON, &errName)
-		if !strings.Contains(errName.Details.Reason, "255") {
-			t.Error("expected name error")
+		if errName.Details.Reason != pinsvc.ReasonBadRequest || !strings.Contains(errName.Details.Details, "255") {
+			t.Errorf("expected name error: %+v", errName)
+		}
+
+		var errPriority pinsvc.APIError
+		pin3 := pinsvc.Pin{
+			Cid:  clustertest.Cid1,
+			Meta: map[string]string{"priority": "urgent"},
+		}
+		pinJSON, err = json.Marshal(pin3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.MakePost(t, svcapi, url(svcapi)+"/pins", pinJSON, &errPriority)
+		if errPriority.Details.Reason != pinsvc.ReasonBadRequest || !strings.Contains(errPriority.Details.Details, "priority") {
+			t.Errorf("expected priority error: %+v", errPriority)
 		}
 	}
 
 	test.BothEndpoints(t, tf)
 }
 
+func TestSvcPinToClusterPinPriority(t *testing.T) {
+	pin, err := svcPinToClusterPin(pinsvc.Pin{
+		Cid:  clustertest.Cid1,
+		Meta: map[string]string{"priority": "high"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.Priority != api.PinPriorityHigh || pin.Metadata["priority"] != "high" {
+		t.Errorf("expected a high priority pin: %+v", pin)
+	}
+
+	pin, err = svcPinToClusterPin(pinsvc.Pin{Cid: clustertest.Cid1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.Priority != api.PinPriorityNormal {
+		t.Error("expected normal priority by default")
+	}
+}
+
 func TestAPIGetPinEndpoint(t *testing.T) {
 	ctx := context.Background()
 	svcapi := testAPI(t)
@@ -239,6 +307,42 @@ func TestAPIGetPinEndpoint(t *testing.T) {
 	test.BothEndpoints(t, tf)
 }
 
+func TestAPIGetPinPeers(t *testing.T) {
+	ctx := context.Background()
+	svcapi := testAPI(t)
+	defer svcapi.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		// The mock only sets IPFS addresses in the cluster-wide
+		// status, so delegates tell which one was used.
+		var status pinsvc.PinStatus
+		test.MakeGet(t, svcapi, url(svcapi)+"/pins/"+clustertest.Cid1.String()+"?peers=all", &status)
+		if len(status.Delegates) != 1 {
+			t.Errorf("expected cluster-wide status: %+v", status)
+		}
+
+		var local pinsvc.PinStatus
+		test.MakeGet(t, svcapi, url(svcapi)+"/pins/"+clustertest.Cid1.String()+"?peers=local", &local)
+		if !local.Pin.Cid.Equals(clustertest.Cid1) {
+			t.Error("Cid should be set")
+		}
+		if local.Status != pinsvc.StatusPinned {
+			t.Errorf("expected pinned: %s", local.Status)
+		}
+		if len(local.Delegates) != 0 {
+			t.Errorf("expected local status: %+v", local)
+		}
+
+		var errResp pinsvc.APIError
+		test.MakeGet(t, svcapi, url(svcapi)+"/pins/"+clustertest.Cid1.String()+"?peers=some", &errResp)
+		if errResp.Details.Reason != pinsvc.ReasonBadRequest {
+			t.Errorf("expected a bad request error: %+v", errResp)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
 func TestAPIRemovePinEndpoint(t *testing.T) {
 	ctx := context.Background()
 	svcapi := testAPI(t)
@@ -251,3 +355,2189 @@ func TestAPIRemovePinEndpoint(t *testing.T) {
 
 	test.BothEndpoints(t, tf)
 }
+
+func TestAPICapabilitiesEndpoint(t *testing.T) {
+	ctx := context.Background()
+	svcapi := testAPI(t)
+	defer svcapi.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var caps pinsvc.Capabilities
+		test.MakeGet(t, svcapi, url(svcapi)+"/capabilities", &caps)
+
+		if len(caps.Match) != len(pinsvc.MatchingStrategies()) {
+			t.Fatalf("unexpected advertised strategies: %v", caps.Match)
+		}
+
+		for _, m := range caps.Match {
+			var opts pinsvc.ListOptions
+			err := opts.FromQuery(map[string][]string{"match": {m}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opts.MatchingStrategy.String() != m {
+				t.Errorf("advertised strategy %s not accepted by FromQuery", m)
+			}
+		}
+
+		for _, m := range caps.MetaMatch {
+			var opts pinsvc.ListOptions
+			err := opts.FromQuery(map[string][]string{"meta_match": {m}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opts.MetaMatchingStrategy.String() != m {
+				t.Errorf("advertised meta strategy %s not accepted by FromQuery", m)
+			}
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIListSuppressRepeatedDelegates(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.SuppressRepeatedDelegates = true
+	svcapi := testAPIwithConfig(t, cfg, "suppress delegates")
+	defer svcapi.Shutdown(ctx)
+
+	url := test.HTTPURL(svcapi)
+
+	var page1 pinsvc.PinList
+	test.MakeGet(t, svcapi, url+"/pins?limit=1", &page1)
+	if len(page1.Results) != 1 {
+		t.Fatalf("expected 1 result: %+v", page1)
+	}
+	if len(page1.Results[0].Delegates) == 0 {
+		t.Fatal("first page should include delegates")
+	}
+
+	before, err := page1.Results[0].Created.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var page2 pinsvc.PinList
+	test.MakeGet(t, svcapi, url+"/pins?limit=1&before="+string(before), &page2)
+	if len(page2.Results) != 1 {
+		t.Fatalf("expected 1 result: %+v", page2)
+	}
+	if len(page2.Results[0].Delegates) != 0 {
+		t.Errorf("delegates of the same peer should be suppressed: %+v", page2.Results[0])
+	}
+
+	// A new listing starts a new session.
+	var page3 pinsvc.PinList
+	test.MakeGet(t, svcapi, url+"/pins?limit=1", &page3)
+	if len(page3.Results) != 1 || len(page3.Results[0].Delegates) == 0 {
+		t.Errorf("new listing should include delegates: %+v", page3)
+	}
+}
+
+func TestAPIPinEndpointAllocationError(t *testing.T) {
+	ctx := context.Background()
+	svcapi := testAPI(t)
+	defer svcapi.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		pin := pinsvc.Pin{
+			Cid: clustertest.UnallocatableCid,
+		}
+		pinJSON, err := json.Marshal(pin)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h := test.MakeHost(t, svcapi)
+		defer h.Close()
+		c := test.HTTPClient(t, h, false)
+		httpResp, err := c.Post(url(svcapi)+"/pins", "application/json", bytes.NewReader(pinJSON))
+		var status pinsvc.PinStatus
+		test.ProcessResp(t, httpResp, err, &status)
+
+		if httpResp.StatusCode != http.StatusConflict {
+			t.Errorf("expected 409: got %d", httpResp.StatusCode)
+		}
+		if status.Status != pinsvc.StatusFailed {
+			t.Errorf("status should be failed: %s", status.Status)
+		}
+		if !strings.Contains(status.Info["allocation_error"], "not enough peers") {
+			t.Errorf("expected allocation_error in info: %+v", status.Info)
+		}
+		if !status.Pin.Cid.Equals(clustertest.UnallocatableCid) {
+			t.Error("cids should match")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+
+	// The requestID of the failed request is reported.
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.CORSAllowedOrigins = []string{"myorigin"}
+	cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+	cfg.RequestIDMode = RequestIDModeDeterministic
+	detapi := testAPIwithConfig(t, cfg, "allocation error requestID")
+	defer detapi.Shutdown(ctx)
+
+	pinJSON, err := json.Marshal(pinsvc.Pin{Cid: clustertest.UnallocatableCid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status pinsvc.PinStatus
+	test.MakePost(t, detapi, test.HTTPURL(detapi)+"/pins", pinJSON, &status)
+	if rID := deterministicRequestID(clustertest.UnallocatableCid, "", nil, ""); status.RequestID != rID {
+		t.Errorf("expected requestID %s: got %s", rID, status.RequestID)
+	}
+}
+
+func TestAPIReplacePinEndpoint(t *testing.T) {
+	ctx := context.Background()
+	svcapi := testAPI(t)
+	defer svcapi.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		pin := pinsvc.Pin{
+			Cid: clustertest.Cid1,
+		}
+		pinJSON, err := json.Marshal(pin)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h := test.MakeHost(t, svcapi)
+		defer h.Close()
+		c := test.HTTPClient(t, h, false)
+
+		t.Run("trailing slash adds", func(t *testing.T) {
+			httpResp, err := c.Post(url(svcapi)+"/pins/", "application/json", bytes.NewReader(pinJSON))
+			var status pinsvc.PinStatus
+			test.ProcessResp(t, httpResp, err, &status)
+			if httpResp.StatusCode != http.StatusOK {
+				t.Errorf("expected 200: got %d", httpResp.StatusCode)
+			}
+			if !status.Pin.Cid.Equals(clustertest.Cid1) {
+				t.Error("cids should match")
+			}
+		})
+
+		t.Run("bad requestID", func(t *testing.T) {
+			httpResp, err := c.Post(url(svcapi)+"/pins/abcd", "application/json", bytes.NewReader(pinJSON))
+			var errResp pinsvc.APIError
+			test.ProcessResp(t, httpResp, err, &errResp)
+			if httpResp.StatusCode != http.StatusBadRequest {
+				t.Errorf("expected 400: got %d", httpResp.StatusCode)
+			}
+		})
+
+		t.Run("replace unpins requestID", func(t *testing.T) {
+			// The mock fails to unpin ErrorCid, so a failure
+			// here means the replacement was attempted.
+			httpResp, err := c.Post(url(svcapi)+"/pins/"+clustertest.ErrorCid.String(), "application/json", bytes.NewReader(pinJSON))
+			var errResp pinsvc.APIError
+			test.ProcessResp(t, httpResp, err, &errResp)
+			if httpResp.StatusCode == http.StatusOK {
+				t.Error("expected an error replacing ErrorCid")
+			}
+		})
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIAdvertisePeerID(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.AdvertisePeerID = true
+	svcapi := testAPIwithConfig(t, cfg, "advertise peer")
+	defer svcapi.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		h := test.MakeHost(t, svcapi)
+		defer h.Close()
+		c := test.HTTPClient(t, h, false)
+		httpResp, err := c.Get(url(svcapi) + "/pins")
+		var resp pinsvc.PinList
+		test.ProcessResp(t, httpResp, err, &resp)
+
+		if got := httpResp.Header.Get("X-Cluster-Peer"); got != clustertest.PeerID1.String() {
+			t.Errorf("expected X-Cluster-Peer to be %s: got %q", clustertest.PeerID1, got)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+
+	// Disabled by default
+	svcapi2 := testAPI(t)
+	defer svcapi2.Shutdown(ctx)
+	httpResp, err := test.HTTPClient(t, nil, false).Get(test.HTTPURL(svcapi2) + "/pins")
+	var resp pinsvc.PinList
+	test.ProcessResp(t, httpResp, err, &resp)
+	if got := httpResp.Header.Get("X-Cluster-Peer"); got != "" {
+		t.Errorf("X-Cluster-Peer should not be set: %q", got)
+	}
+}
+
+func TestIsStuckQueued(t *testing.T) {
+	now := time.Now()
+	threshold := time.Minute
+	gpi := func(st api.TrackerStatus, ts time.Time) api.GlobalPinInfo {
+		return api.GlobalPinInfo{
+			Cid: clustertest.Cid1,
+			PeerMap: map[string]api.PinInfoShort{
+				clustertest.PeerID1.String(): {
+					Status: st,
+					TS:     ts,
+				},
+			},
+		}
+	}
+
+	if !isStuckQueued(gpi(api.TrackerStatusPinQueued, now.Add(-2*threshold)), threshold, now) {
+		t.Error("pin queued beyond the threshold should be stuck")
+	}
+	if isStuckQueued(gpi(api.TrackerStatusPinQueued, now.Add(-threshold/2)), threshold, now) {
+		t.Error("recently queued pin should not be stuck")
+	}
+	if isStuckQueued(gpi(api.TrackerStatusPinning, now.Add(-2*threshold)), threshold, now) {
+		t.Error("pinning pin should not be stuck")
+	}
+}
+
+func TestAPIStuckQueuedEndpoint(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.StuckQueuedThreshold = time.Minute
+	cfg.StuckQueuedCheckInterval = 10 * time.Millisecond
+	svcapi := testAPIwithConfig(t, cfg, "stuck queued")
+	defer svcapi.Shutdown(ctx)
+
+	// Let the detector run against the mock, which has no queued items.
+	time.Sleep(50 * time.Millisecond)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var resp pinsvc.PinList
+		test.MakeGet(t, svcapi, url(svcapi)+"/admin/stuck-queued", &resp)
+		if resp.Count != 0 || len(resp.Results) != 0 {
+			t.Errorf("expected no stuck pins: %+v", resp)
+		}
+	}
+	test.BothEndpoints(t, tf)
+
+	// A check against a pin queued beyond the threshold.
+	stuck := api.GlobalPinInfo{
+		Cid: clustertest.Cid1,
+		PeerMap: map[string]api.PinInfoShort{
+			clustertest.PeerID1.String(): {
+				Status: api.TrackerStatusPinQueued,
+				TS:     time.Now().Add(-time.Hour),
+			},
+		},
+	}
+	if !isStuckQueued(stuck, cfg.StuckQueuedThreshold, time.Now()) {
+		t.Fatal("pin should be stuck")
+	}
+	svcapi.Shutdown(ctx) // stop the detector so it does not overwrite.
+	svcapi.stuckQueued.set([]api.GlobalPinInfo{stuck})
+
+	w := httptest.NewRecorder()
+	svcapi.stuckQueuedPins(w, httptest.NewRequest("GET", "/admin/stuck-queued", nil))
+	var resp pinsvc.PinList
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Count != 1 || !resp.Results[0].Pin.Cid.Equals(clustertest.Cid1) {
+		t.Errorf("expected Cid1 to be reported as stuck: %+v", resp)
+	}
+	if resp.Results[0].Status != pinsvc.StatusQueued {
+		t.Errorf("stuck pin should be queued: %s", resp.Results[0].Status)
+	}
+}
+
+func TestAPIListHasOrigins(t *testing.T) {
+	ctx := context.Background()
+	svcapi := testAPI(t)
+	defer svcapi.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		// Only Cid2 has origins in the mock.
+		var resp pinsvc.PinList
+		test.MakeGet(t, svcapi, url(svcapi)+"/pins?has_origins=true", &resp)
+		if resp.Count != 1 || !resp.Results[0].Pin.Cid.Equals(clustertest.Cid2) {
+			t.Errorf("expected only Cid2: %+v", resp)
+		}
+
+		var resp2 pinsvc.PinList
+		test.MakeGet(t, svcapi, url(svcapi)+"/pins?has_origins=false", &resp2)
+		if resp2.Count != 2 {
+			t.Errorf("expected 2 pins without origins: %+v", resp2)
+		}
+		for _, st := range resp2.Results {
+			if len(st.Pin.Origins) > 0 {
+				t.Errorf("pin should have no origins: %+v", st)
+			}
+		}
+
+		var errResp pinsvc.APIError
+		test.MakeGet(t, svcapi, url(svcapi)+"/pins?has_origins=maybe", &errResp)
+		if errResp.Details.Reason == "" {
+			t.Error("expected an error with invalid has_origins")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIPinEndpointRedactsMeta(t *testing.T) {
+	ctx := context.Background()
+
+	logging.SetLogLevel("pinsvcapi", "debug")
+	defer logging.SetLogLevel("pinsvcapi", "info")
+
+	for _, format := range []string{MetaLogKeys, MetaLogRedacted} {
+		t.Run(format, func(t *testing.T) {
+			cfg := NewConfig()
+			cfg.Default()
+			cfg.CORSAllowedOrigins = []string{"myorigin"}
+			cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+			cfg.MetaLogFormat = format
+			svcapi := testAPIwithConfig(t, cfg, "meta log "+format)
+			defer svcapi.Shutdown(ctx)
+
+			pr := logging.NewPipeReader(logging.PipeFormat(logging.PlaintextOutput))
+			var logs bytes.Buffer
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				logs.ReadFrom(pr)
+			}()
+
+			pin := pinsvc.Pin{
+				Cid: clustertest.Cid1,
+				Meta: map[string]string{
+					"secret": "hunter2",
+				},
+			}
+			pinJSON, err := json.Marshal(pin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var status pinsvc.PinStatus
+			test.MakePost(t, svcapi, test.HTTPURL(svcapi)+"/pins", pinJSON, &status)
+
+			pr.Close()
+			<-done
+
+			out := logs.String()
+			if !strings.Contains(out, "addPin: "+clustertest.Cid1.String()) {
+				t.Fatalf("addPin debug message not found in logs: %s", out)
+			}
+			if strings.Contains(out, "hunter2") {
+				t.Errorf("meta values should not be logged: %s", out)
+			}
+			if !strings.Contains(out, "secret") {
+				t.Errorf("meta keys should be logged: %s", out)
+			}
+			if format == MetaLogRedacted && !strings.Contains(out, "secret:<redacted>") {
+				t.Errorf("meta values should be redacted: %s", out)
+			}
+		})
+	}
+}
+
+func TestAPIPinEndpointInFlightLimit(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.CORSAllowedOrigins = []string{"myorigin"}
+	cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+	cfg.MaxInFlightPinsPerToken = 1
+	svcapi := testAPIwithConfig(t, cfg, "in flight limit")
+	defer svcapi.Shutdown(ctx)
+
+	c := test.HTTPClient(t, nil, false)
+	url := test.HTTPURL(svcapi)
+
+	post := func(t *testing.T, cid api.Cid, user string) int {
+		t.Helper()
+		pinJSON, err := json.Marshal(pinsvc.Pin{Cid: cid})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest(http.MethodPost, url+"/pins", bytes.NewReader(pinJSON))
+		req.Header.Set("Content-Type", "application/json")
+		if user != "" {
+			req.SetBasicAuth(user, "pass")
+		}
+		httpResp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		httpResp.Body.Close()
+		return httpResp.StatusCode
+	}
+
+	// The mock reports SlowCid1 as pinning, so it stays in flight.
+	if st := post(t, clustertest.SlowCid1, ""); st != http.StatusOK {
+		t.Fatalf("first pin should succeed: %d", st)
+	}
+	if st := post(t, clustertest.Cid2, ""); st != http.StatusTooManyRequests {
+		t.Errorf("expected 429 when saturated: %d", st)
+	}
+	if st := post(t, clustertest.Cid2, "other"); st != http.StatusOK {
+		t.Errorf("other credentials have their own limit: %d", st)
+	}
+
+	// Removing the pin frees the slot.
+	test.MakeDelete(t, svcapi, url+"/pins/"+clustertest.SlowCid1.String(), &struct{}{})
+	if st := post(t, clustertest.Cid2, ""); st != http.StatusOK {
+		t.Errorf("pin should succeed after removing the in-flight one: %d", st)
+	}
+
+	// Cid2 is reported pinned, which frees the slot.
+	if st := post(t, clustertest.Cid3, ""); st != http.StatusOK {
+		t.Errorf("pin should succeed once the in-flight one is pinned: %d", st)
+	}
+}
+
+func TestAPIConfigEndpoint(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.BasicAuthCredentials = map[string]string{
+		"admin": "secretpass",
+	}
+	cfg.MaxInFlightPinsPerToken = 5
+	svcapi := testAPIwithConfig(t, cfg, "config")
+	defer svcapi.Shutdown(ctx)
+
+	req, _ := http.NewRequest(http.MethodGet, test.HTTPURL(svcapi)+"/admin/config", nil)
+	req.SetBasicAuth("admin", "secretpass")
+	httpResp, err := test.HTTPClient(t, nil, false).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200: got %d", httpResp.StatusCode)
+	}
+
+	var body bytes.Buffer
+	body.ReadFrom(httpResp.Body)
+	if strings.Contains(body.String(), "secretpass") {
+		t.Errorf("credentials should be redacted: %s", body.String())
+	}
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp["basic_auth_credentials"] != "XXX_hidden_XXX" {
+		t.Errorf("basic_auth_credentials should be hidden: %v", resp["basic_auth_credentials"])
+	}
+	if resp["max_in_flight_pins_per_token"] != float64(5) {
+		t.Errorf("max_in_flight_pins_per_token should be present: %v", resp["max_in_flight_pins_per_token"])
+	}
+}
+
+func TestStatusMapping(t *testing.T) {
+	sm, err := parseStatusMapping(map[string]string{"remote": "pinned"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peerMap := func(sts ...api.TrackerStatus) map[string]api.PinInfoShort {
+		pm := make(map[string]api.PinInfoShort)
+		for i, st := range sts {
+			pm[fmt.Sprint(i)] = api.PinInfoShort{Status: st}
+		}
+		return pm
+	}
+
+	if st := sm.svcStatus(peerMap(api.TrackerStatusRemote)); st != pinsvc.StatusPinned {
+		t.Errorf("remote should be reported as pinned: %s", st)
+	}
+	if st := sm.svcStatus(peerMap(api.TrackerStatusRemote, api.TrackerStatusPinning)); st != pinsvc.StatusPinning {
+		t.Errorf("non-overridden statuses should keep the default mapping: %s", st)
+	}
+	if st := statusMapping(nil).svcStatus(peerMap(api.TrackerStatusRemote)); st != pinsvc.StatusUndefined {
+		t.Errorf("remote should be undefined by default: %s", st)
+	}
+	if f := sm.trackerStatusFilter(pinsvc.StatusPinned); !f.Match(api.TrackerStatusRemote) {
+		t.Error("filtering by pinned should include remote")
+	}
+
+	for _, bad := range []map[string]string{
+		{"remote": "nonsense"},
+		{"nonsense": "pinned"},
+		{"error": "pinned"},
+		{"remote": "pinned,failed"},
+	} {
+		if _, err := parseStatusMapping(bad); err == nil {
+			t.Errorf("expected an error parsing %v", bad)
+		}
+	}
+}
+
+func TestAPIListStatusMapping(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.StatusMapping = map[string]string{"pinning": "queued"}
+	svcapi := testAPIwithConfig(t, cfg, "status mapping")
+	defer svcapi.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		// Cid2 is pinning in the mock.
+		var resp pinsvc.PinList
+		test.MakeGet(t, svcapi, url(svcapi)+"/pins?status=queued", &resp)
+		if resp.Count != 1 ||
+			!resp.Results[0].Pin.Cid.Equals(clustertest.Cid2) ||
+			resp.Results[0].Status != pinsvc.StatusQueued {
+			t.Errorf("pinning should be reported as queued: %+v", resp)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAnchorCreated(t *testing.T) {
+	original := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	ts, _ := original.MarshalText()
+
+	gpi := func(created time.Time) api.GlobalPinInfo {
+		return api.GlobalPinInfo{
+			Cid:     clustertest.Cid1,
+			Created: created,
+			Metadata: map[string]string{
+				"a":            "b",
+				createdMetaKey: string(ts),
+			},
+			PeerMap: map[string]api.PinInfoShort{
+				clustertest.PeerID1.String(): {
+					Status: api.TrackerStatusPinned,
+					TS:     created,
+				},
+			},
+		}
+	}
+
+	cfg := NewConfig()
+	cfg.Default()
+	svcapi := &API{config: cfg}
+
+	// Timestamps change after re-pinning, but Created does not.
+	for _, created := range []time.Time{time.Now(), time.Now().Add(time.Minute)} {
+		st := svcapi.globalPinInfoToSvcPinStatus(clustertest.Cid1.String(), "", gpi(created))
+		if !st.Created.Equal(original) {
+			t.Errorf("created should be anchored to %s: got %s", original, st.Created)
+		}
+		if _, ok := st.Pin.Meta[createdMetaKey]; ok || st.Pin.Meta["a"] != "b" {
+			t.Errorf("only the anchor should be removed from meta: %+v", st.Pin.Meta)
+		}
+	}
+}
+
+func TestAPIPinEndpointAnchorCreated(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.CORSAllowedOrigins = []string{"myorigin"}
+	cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+	cfg.AnchorCreated = true
+	svcapi := testAPIwithConfig(t, cfg, "anchor created")
+	defer svcapi.Shutdown(ctx)
+
+	pinJSON, err := json.Marshal(pinsvc.Pin{
+		Cid:  clustertest.Cid1,
+		Meta: map[string]string{"a": "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var status pinsvc.PinStatus
+	test.MakePost(t, svcapi, test.HTTPURL(svcapi)+"/pins", pinJSON, &status)
+	if status.Created.IsZero() {
+		t.Error("created should be set")
+	}
+	if _, ok := status.Pin.Meta[createdMetaKey]; ok || status.Pin.Meta["a"] != "b" {
+		t.Errorf("the anchor should not be shown in meta: %+v", status.Pin.Meta)
+	}
+}
+
+func TestDeterministicRequestID(t *testing.T) {
+	origin1, _ := api.NewMultiaddr("/ip4/1.2.3.4/tcp/4001")
+	origin2, _ := api.NewMultiaddr("/ip4/5.6.7.8/tcp/4001")
+	origins := []api.Multiaddr{origin1}
+
+	rID := deterministicRequestID(clustertest.Cid1, "name", origins, "owner")
+	if rID != deterministicRequestID(clustertest.Cid1, "name", origins, "owner") {
+		t.Error("requestIDs should be deterministic")
+	}
+
+	others := []string{
+		deterministicRequestID(clustertest.Cid2, "name", origins, "owner"),
+		deterministicRequestID(clustertest.Cid1, "other", origins, "owner"),
+		deterministicRequestID(clustertest.Cid1, "name", origins, "other"),
+		deterministicRequestID(clustertest.Cid1, "name", nil, "owner"),
+		deterministicRequestID(clustertest.Cid1, "name", []api.Multiaddr{origin2}, "owner"),
+		deterministicRequestID(clustertest.Cid1, "name", []api.Multiaddr{origin1, origin2}, "owner"),
+		// field boundaries are not ambiguous
+		deterministicRequestID(clustertest.Cid1, "nam", origins, "eowner"),
+		deterministicRequestID(clustertest.Cid1, "", origins, "nameowner"),
+	}
+	seen := map[string]struct{}{rID: {}}
+	for _, o := range others {
+		if _, ok := seen[o]; ok {
+			t.Errorf("requestIDs for different pins should be distinct: %s", o)
+		}
+		seen[o] = struct{}{}
+	}
+}
+
+func TestAPIPinEndpointDeterministicRequestID(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.CORSAllowedOrigins = []string{"myorigin"}
+	cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+	cfg.RequestIDMode = RequestIDModeDeterministic
+	svcapi := testAPIwithConfig(t, cfg, "deterministic requestID")
+	defer svcapi.Shutdown(ctx)
+
+	pinJSON, err := json.Marshal(pinsvc.Pin{
+		Cid:  clustertest.Cid1,
+		Name: "name",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := deterministicRequestID(clustertest.Cid1, "name", nil, "")
+	for i := 0; i < 2; i++ {
+		var status pinsvc.PinStatus
+		test.MakePost(t, svcapi, test.HTTPURL(svcapi)+"/pins", pinJSON, &status)
+		if status.RequestID != expected {
+			t.Errorf("expected requestID %s: got %s", expected, status.RequestID)
+		}
+		if _, ok := status.Pin.Meta[requestIDMetaKey]; ok {
+			t.Errorf("the requestID should not be shown in meta: %+v", status.Pin.Meta)
+		}
+	}
+}
+
+func TestAPIPinEndpointUUIDRequestID(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.CORSAllowedOrigins = []string{"myorigin"}
+	cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+	cfg.RequestIDMode = RequestIDModeUUID
+	svcapi := testAPIwithConfig(t, cfg, "uuid requestID")
+	defer svcapi.Shutdown(ctx)
+
+	pinJSON, err := json.Marshal(pinsvc.Pin{Cid: clustertest.Cid1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		var status pinsvc.PinStatus
+		test.MakePost(t, svcapi, test.HTTPURL(svcapi)+"/pins", pinJSON, &status)
+		if _, err := uuid.Parse(status.RequestID); err != nil {
+			t.Errorf("requestID should be a UUID: %s", status.RequestID)
+		}
+		if seen[status.RequestID] {
+			t.Errorf("requestIDs should be unique: %s", status.RequestID)
+		}
+		seen[status.RequestID] = true
+		if _, ok := status.Pin.Meta[requestIDMetaKey]; ok {
+			t.Errorf("the requestID should not be shown in meta: %+v", status.Pin.Meta)
+		}
+		if _, ok := status.Info["warning1"]; ok {
+			t.Error("the cid is not used as requestID and should not be warned about")
+		}
+	}
+
+	// Pins without a stored requestID are still found by cid.
+	var status pinsvc.PinStatus
+	test.MakeGet(t, svcapi, test.HTTPURL(svcapi)+"/pins/"+clustertest.Cid1.String(), &status)
+	if status.RequestID != clustertest.Cid1.String() {
+		t.Errorf("expected the cid as requestID: %s", status.RequestID)
+	}
+
+	// Unknown requestIDs are not found.
+	c := &http.Client{}
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		req, err := http.NewRequest(method, test.HTTPURL(svcapi)+"/pins/"+uuid.NewString(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		httpResp, err := c.Do(req)
+		var errResp pinsvc.APIError
+		test.ProcessResp(t, httpResp, err, &errResp)
+		if httpResp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expected 404 for an unknown requestID: got %d", method, httpResp.StatusCode)
+		}
+	}
+}
+
+func TestRequestIDMeta(t *testing.T) {
+	p1 := pinsvc.Pin{Cid: clustertest.Cid1, Name: "n1", Meta: map[string]string{"a": "b"}}
+	p2 := pinsvc.Pin{Cid: clustertest.Cid1, Name: "n2", Meta: map[string]string{"c": "d"}}
+	meta := withRequestID(p1.Meta, api.Pin{}, "r1", newRequestRecord(p1, ""))
+	existing := api.PinWithOpts(clustertest.Cid1, api.PinOptions{Metadata: meta})
+	meta = withRequestID(withCreatedMeta(p2.Meta), existing, "r2", newRequestRecord(p2, ""))
+	if rIDs := requestIDs(meta); len(rIDs) != 2 || rIDs[0] != "r1" || rIDs[1] != "r2" {
+		t.Fatalf("both requestIDs should be kept: %v", rIDs)
+	}
+	if meta[requestIDMetaKey] != "r2" {
+		t.Errorf("r2 should be the latest requestID: %s", meta[requestIDMetaKey])
+	}
+
+	status := pinsvc.PinStatus{Pin: pinsvc.Pin{Name: "n2", Meta: meta}}
+	requestIDFromMeta(&status, "")
+	if status.RequestID != "r2" {
+		t.Errorf("expected r2 as requestID: %s", status.RequestID)
+	}
+	anchorCreated(&status)
+	if len(status.Pin.Meta) != 1 || status.Pin.Meta["c"] != "d" {
+		t.Errorf("the requestIDs should not be shown in meta: %+v", status.Pin.Meta)
+	}
+
+	// Each requestID shows the name and meta of its request.
+	status = pinsvc.PinStatus{RequestID: "r1", Pin: pinsvc.Pin{Name: "n2", Meta: meta}}
+	requestIDFromMeta(&status, "")
+	anchorCreated(&status)
+	if status.RequestID != "r1" || status.Pin.Name != "n1" {
+		t.Errorf("expected the name of r1: %s %s", status.RequestID, status.Pin.Name)
+	}
+	if len(status.Pin.Meta) != 1 || status.Pin.Meta["a"] != "b" {
+		t.Errorf("expected the meta of r1: %+v", status.Pin.Meta)
+	}
+
+	pin := api.PinWithOpts(clustertest.Cid1, api.PinOptions{Name: "n2", Metadata: meta})
+	dropKey := func(rID string) func(string, requestRecord) bool {
+		return func(key string, rec requestRecord) bool { return key == rID }
+	}
+	pin, left := withoutRequests(pin, dropKey("r2"))
+	if left != 1 || pin.Metadata[requestIDMetaKey] != "r1" {
+		t.Errorf("r1 should be left as the latest requestID: %d %+v", left, pin.Metadata)
+	}
+	if pin.Name != "n1" || pin.Metadata["a"] != "b" || pin.Metadata["c"] != "" {
+		t.Errorf("the pin should take the name and meta of r1: %s %+v", pin.Name, pin.Metadata)
+	}
+	if _, ok := pin.Metadata[createdMetaKey]; !ok {
+		t.Error("internal metadata should be kept")
+	}
+	if _, left = withoutRequests(pin, dropKey("r1")); left != 0 {
+		t.Errorf("no requestIDs should be left: %d", left)
+	}
+}
+
+// withCreatedMeta returns a copy of the given metadata with a creation
+// anchor, as an example of internal metadata.
+func withCreatedMeta(metadata map[string]string) map[string]string {
+	meta := map[string]string{createdMetaKey: "2022-01-01T00:00:00Z"}
+	for k, v := range metadata {
+		meta[k] = v
+	}
+	return meta
+}
+
+func TestRequestOwners(t *testing.T) {
+	alice, bob := ownerID("alice"), ownerID("bob")
+	pb := pinsvc.Pin{Cid: clustertest.Cid1, Name: "b", Meta: map[string]string{"b": "2"}}
+
+	// A pin created with a single owner for the whole pin.
+	legacy := api.PinWithOpts(clustertest.Cid1, api.PinOptions{
+		Name:     "a",
+		Metadata: map[string]string{"a": "1", ownerMetaKey: alice},
+	})
+	meta := withRecord(pb.Meta, legacy, bob, newRequestRecord(pb, bob))
+	if _, ok := meta[ownerMetaKey]; ok {
+		t.Error("the owner of the whole pin should be moved to a record")
+	}
+	if !ownsRequest(meta, alice) || !ownsRequest(meta, bob) || ownsRequest(meta, ownerID("carol")) {
+		t.Fatalf("both owners should have a request: %+v", meta)
+	}
+
+	for _, tc := range []struct {
+		owner string
+		name  string
+		meta  string
+	}{
+		{alice, "a", "a"},
+		{bob, "b", "b"},
+	} {
+		status := pinsvc.PinStatus{
+			RequestID: clustertest.Cid1.String(),
+			Pin:       pinsvc.Pin{Name: "b", Meta: meta},
+		}
+		requestIDFromMeta(&status, tc.owner)
+		if status.RequestID != clustertest.Cid1.String() {
+			t.Errorf("the requestID should stay the cid: %s", status.RequestID)
+		}
+		if string(status.Pin.Name) != tc.name || len(status.Pin.Meta) != 1 || status.Pin.Meta[tc.meta] == "" {
+			t.Errorf("each owner should see its own request: %s %+v", status.Pin.Name, status.Pin.Meta)
+		}
+	}
+
+	pin := api.PinWithOpts(clustertest.Cid1, api.PinOptions{Name: "b", Metadata: meta})
+	byOwner := func(owner string) func(string, requestRecord) bool {
+		return func(key string, rec requestRecord) bool { return rec.Owner == owner }
+	}
+	pin, left := withoutRequests(pin, byOwner(bob))
+	if left != 1 || ownsRequest(pin.Metadata, bob) || !ownsRequest(pin.Metadata, alice) {
+		t.Errorf("only the request of alice should be left: %d %+v", left, pin.Metadata)
+	}
+	if pin.Name != "a" || pin.Metadata["a"] != "1" || pin.Metadata["b"] != "" {
+		t.Errorf("the pin should take the name and meta of alice: %s %+v", pin.Name, pin.Metadata)
+	}
+	if _, left = withoutRequests(pin, byOwner(alice)); left != 0 {
+		t.Errorf("no requests should be left: %d", left)
+	}
+}
+
+func TestIsDuplicate(t *testing.T) {
+	existing := api.PinWithOpts(clustertest.Cid1, api.PinOptions{
+		Name:     "other",
+		Metadata: withRecord(nil, api.Pin{}, "r1", newRequestRecord(pinsvc.Pin{Name: "name"}, "user:alice")),
+	})
+
+	pin := pinsvc.Pin{Cid: clustertest.Cid1, Name: "name"}
+	if !isDuplicate(existing, pin, "user:alice") {
+		t.Error("same cid, name and owner should be a duplicate")
+	}
+
+	if isDuplicate(existing, pinsvc.Pin{Cid: clustertest.Cid1, Name: "other"}, "user:alice") {
+		t.Error("a different name should not be a duplicate")
+	}
+	if isDuplicate(existing, pinsvc.Pin{Cid: clustertest.Cid2, Name: "name"}, "user:alice") {
+		t.Error("a different cid should not be a duplicate")
+	}
+	if isDuplicate(existing, pin, "user:bob") {
+		t.Error("a different owner should not be a duplicate")
+	}
+	if isDuplicate(api.PinWithOpts(clustertest.Cid1, api.PinOptions{Name: "name"}), pin, "user:alice") {
+		t.Error("a pin without owner should not be a duplicate")
+	}
+
+	legacy := api.PinWithOpts(clustertest.Cid1, api.PinOptions{
+		Name:     "name",
+		Metadata: map[string]string{ownerMetaKey: ownerID("token")},
+	})
+	if !isDuplicate(legacy, pin, ownerID("token")) {
+		t.Error("a pin with the owner of the whole pin should be a duplicate")
+	}
+}
+
+func TestCidLocks(t *testing.T) {
+	cl := newCidLocks()
+	unlock := cl.lock(clustertest.Cid1)
+
+	// other cids are not blocked
+	cl.lock(clustertest.Cid2)()
+
+	locked := make(chan struct{})
+	go func() {
+		cl.lock(clustertest.Cid1)()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("the same cid should be locked")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+
+	if len(cl.locks) != 0 {
+		t.Error("locks should be removed when released")
+	}
+}
+
+func TestAPIPinEndpointUniquePinsPerOwner(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.CORSAllowedOrigins = []string{"myorigin"}
+	cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+	cfg.UniquePinsPerOwner = true
+	svcapi := testAPIwithConfig(t, cfg, "unique pins")
+	defer svcapi.Shutdown(ctx)
+
+	// The existing Cid1 in the mock has no owner, so it is pinned.
+	pinJSON, err := json.Marshal(pinsvc.Pin{Cid: clustertest.Cid1, Name: "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status pinsvc.PinStatus
+	test.MakePost(t, svcapi, test.HTTPURL(svcapi)+"/pins", pinJSON, &status)
+	if status.Status != pinsvc.StatusQueued {
+		t.Errorf("pin should have been added: %+v", status)
+	}
+	if _, ok := status.Pin.Meta[ownerMetaKey]; ok {
+		t.Errorf("the owner should not be shown in meta: %+v", status.Pin.Meta)
+	}
+}
+
+func TestAPIPinEndpointAsyncDelegates(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.CORSAllowedOrigins = []string{"myorigin"}
+	cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+	cfg.AsyncDelegates = true
+	svcapi := testAPIwithConfig(t, cfg, "async delegates")
+	defer svcapi.Shutdown(ctx)
+
+	pinJSON, err := json.Marshal(pinsvc.Pin{Cid: clustertest.Cid1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The pin is allocated everywhere, so the mock consensus peers are
+	// used as delegates.
+	var status pinsvc.PinStatus
+	test.MakePost(t, svcapi, test.HTTPURL(svcapi)+"/pins", pinJSON, &status)
+	if len(status.Delegates) != 3 {
+		t.Fatalf("expected 3 provisional delegates: %+v", status.Delegates)
+	}
+	for _, d := range status.Delegates {
+		if !strings.HasPrefix(d.String(), "/p2p/") {
+			t.Errorf("delegates should be provisional: %s", d)
+		}
+	}
+
+	// The mock resolves all peers to the same IPFS address.
+	for i := 0; ; i++ {
+		var status pinsvc.PinStatus
+		test.MakePost(t, svcapi, test.HTTPURL(svcapi)+"/pins", pinJSON, &status)
+		if len(status.Delegates) == 3 && strings.HasPrefix(status.Delegates[0].String(), "/ip4/127.0.0.1/tcp/4001") {
+			break
+		}
+		if i == 20 {
+			t.Fatalf("delegates should have been resolved: %+v", status.Delegates)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// leaderConsensus is a Consensus RPC service which only implements Leader.
+type leaderConsensus struct {
+	err error
+}
+
+func (lc *leaderConsensus) Leader(ctx context.Context, in struct{}, out *peer.ID) error {
+	return lc.err
+}
+
+func leaderRPCClient(t *testing.T, err error) *rpc.Client {
+	s := rpc.NewServer(nil, "mock")
+	if err := s.RegisterName("Consensus", &leaderConsensus{err: err}); err != nil {
+		t.Fatal(err)
+	}
+	return rpc.NewClientWithServer(nil, "mock", s)
+}
+
+func TestConsensusAvailable(t *testing.T) {
+	ctx := context.Background()
+	svcapi := &API{}
+
+	svcapi.rpcClient = clustertest.NewMockRPCClient(t)
+	if err := svcapi.consensusAvailable(ctx); err != nil {
+		t.Error("consensus with a leader should be available:", err)
+	}
+
+	// Components without leaders, like crdt, are always available.
+	svcapi.rpcClient = leaderRPCClient(t, fmt.Errorf("crdt %w", api.ErrLeaderless))
+	if err := svcapi.consensusAvailable(ctx); err != nil {
+		t.Error("leaderless consensus should be available:", err)
+	}
+
+	svcapi.rpcClient = leaderRPCClient(t, errors.New("no leader"))
+	if err := svcapi.consensusAvailable(ctx); err == nil {
+		t.Error("consensus without a leader should not be available")
+	}
+}
+
+func TestAPIPinEndpointConsensusUnavailable(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.CORSAllowedOrigins = []string{"myorigin"}
+	cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+	cfg.CheckConsensusLeader = true
+	cfg.ConsensusRetryAfter = 10 * time.Second
+	svcapi := testAPIwithClient(t, cfg, "consensus unavailable", leaderRPCClient(t, errors.New("no leader")))
+	defer svcapi.Shutdown(ctx)
+
+	pinJSON, err := json.Marshal(pinsvc.Pin{Cid: clustertest.Cid1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := test.HTTPClient(t, nil, false)
+	req, _ := http.NewRequest(http.MethodPost, test.HTTPURL(svcapi)+"/pins", bytes.NewReader(pinJSON))
+	req.Header.Set("Content-Type", "application/json")
+	httpResp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503: %d", httpResp.StatusCode)
+	}
+	if ra := httpResp.Header.Get("Retry-After"); ra != "10" {
+		t.Errorf("expected Retry-After: 10. Got %q", ra)
+	}
+	var apiErr pinsvc.APIError
+	if err := json.NewDecoder(httpResp.Body).Decode(&apiErr); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(apiErr.Details.Details, "consensus unavailable") {
+		t.Errorf("unexpected error: %s", apiErr)
+	}
+
+	// The regular mock has a leader.
+	svcapi2 := testAPIwithConfig(t, cfg, "consensus available")
+	defer svcapi2.Shutdown(ctx)
+	var status pinsvc.PinStatus
+	test.MakePost(t, svcapi2, test.HTTPURL(svcapi2)+"/pins", pinJSON, &status)
+	if !status.Pin.Cid.Equals(clustertest.Cid1) {
+		t.Errorf("expected pin to succeed: %+v", status)
+	}
+}
+
+// driftCluster is a Cluster RPC service with pins which are under and over
+// replicated.
+type driftCluster struct{}
+
+func (dc *driftCluster) Pins(ctx context.Context, in <-chan struct{}, out chan<- api.Pin) error {
+	defer close(out)
+	for _, c := range []api.Cid{clustertest.Cid1, clustertest.Cid2, clustertest.Cid3} {
+		out <- api.PinWithOpts(c, api.PinOptions{
+			ReplicationFactorMin: 2,
+			ReplicationFactorMax: 2,
+		})
+	}
+	return nil
+}
+
+func (dc *driftCluster) StatusAll(ctx context.Context, in <-chan api.TrackerStatus, out chan<- api.GlobalPinInfo) error {
+	defer close(out)
+	<-in
+	peerMap := func(n int) map[string]api.PinInfoShort {
+		pm := make(map[string]api.PinInfoShort)
+		for i, p := range []peer.ID{clustertest.PeerID1, clustertest.PeerID2, clustertest.PeerID3} {
+			st := api.TrackerStatusRemote
+			if i < n {
+				st = api.TrackerStatusPinned
+			}
+			pm[p.String()] = api.PinInfoShort{Status: st}
+		}
+		return pm
+	}
+	out <- api.GlobalPinInfo{Cid: clustertest.Cid1, PeerMap: peerMap(1)}
+	out <- api.GlobalPinInfo{Cid: clustertest.Cid2, PeerMap: peerMap(2)}
+	out <- api.GlobalPinInfo{Cid: clustertest.Cid3, PeerMap: peerMap(3)}
+	return nil
+}
+
+func TestPinDrift(t *testing.T) {
+	pinned := api.PinInfoShort{Status: api.TrackerStatusPinned}
+	failed := api.PinInfoShort{Status: api.TrackerStatusPinError}
+	gpi := api.GlobalPinInfo{
+		PeerMap: map[string]api.PinInfoShort{
+			clustertest.PeerID1.String(): pinned,
+			clustertest.PeerID2.String(): pinned,
+			clustertest.PeerID3.String(): failed,
+		},
+	}
+
+	testcases := []struct {
+		min, max int
+		drift    string
+	}{
+		{1, 1, driftOver},
+		{1, 2, ""},
+		{2, 3, ""},
+		{3, 3, driftUnder},
+		{-1, -1, driftUnder},
+		{0, 0, ""},
+	}
+	for _, tc := range testcases {
+		pin := api.PinWithOpts(clustertest.Cid1, api.PinOptions{
+			ReplicationFactorMin: tc.min,
+			ReplicationFactorMax: tc.max,
+		})
+		n, drift := pinDrift(pin, gpi)
+		if n != 2 {
+			t.Errorf("expected 2 pinned peers: %d", n)
+		}
+		if drift != tc.drift {
+			t.Errorf("%d/%d: expected drift %q, got %q", tc.min, tc.max, tc.drift, drift)
+		}
+	}
+}
+
+func TestAPIReplicationDriftEndpoint(t *testing.T) {
+	ctx := context.Background()
+	s := rpc.NewServer(nil, "mock")
+	if err := s.RegisterName("Cluster", &driftCluster{}); err != nil {
+		t.Fatal(err)
+	}
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.CORSAllowedOrigins = []string{"myorigin"}
+	cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+	svcapi := testAPIwithClient(t, cfg, "replication drift", rpc.NewClientWithServer(nil, "mock", s))
+	defer svcapi.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var resp driftList
+		test.MakeGet(t, svcapi, url(svcapi)+"/admin/replication-drift", &resp)
+		if resp.Count != 2 || len(resp.Results) != 2 {
+			t.Fatalf("expected 2 drifted pins: %+v", resp)
+		}
+
+		test.MakeGet(t, svcapi, url(svcapi)+"/admin/replication-drift?drift=under", &resp)
+		if resp.Count != 1 || !resp.Results[0].Cid.Equals(clustertest.Cid1) {
+			t.Fatalf("expected Cid1 to be under-replicated: %+v", resp)
+		}
+		if resp.Results[0].Pinned != 1 || resp.Results[0].Drift != driftUnder {
+			t.Errorf("unexpected result: %+v", resp.Results[0])
+		}
+
+		test.MakeGet(t, svcapi, url(svcapi)+"/admin/replication-drift?drift=over", &resp)
+		if resp.Count != 1 || !resp.Results[0].Cid.Equals(clustertest.Cid3) {
+			t.Fatalf("expected Cid3 to be over-replicated: %+v", resp)
+		}
+		if resp.Results[0].Pinned != 3 || resp.Results[0].Drift != driftOver {
+			t.Errorf("unexpected result: %+v", resp.Results[0])
+		}
+
+		var errResp pinsvc.APIError
+		test.MakeGet(t, svcapi, url(svcapi)+"/admin/replication-drift?drift=sideways", &errResp)
+		if errResp.Details.Reason == "" {
+			t.Error("expected an error for an invalid drift direction")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIListPinsMaxResponseSize(t *testing.T) {
+	ctx := context.Background()
+	get := func(t *testing.T, svcapi *API) (*http.Response, []byte) {
+		t.Helper()
+		c := test.HTTPClient(t, nil, false)
+		httpResp, err := c.Get(test.HTTPURL(svcapi) + "/pins?limit=10")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer httpResp.Body.Close()
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(httpResp.Body); err != nil {
+			t.Fatal(err)
+		}
+		return httpResp, buf.Bytes()
+	}
+
+	svcapi := testAPI(t)
+	defer svcapi.Shutdown(ctx)
+	httpResp, full := get(t, svcapi)
+	if httpResp.Header.Get("X-Truncated") != "" {
+		t.Error("response should not be truncated without a limit")
+	}
+
+	// The limit on number of results allows all of them, but they do not
+	// fit in the size limit. Some slack is left as the creation times,
+	// and so their encoded length, change between requests.
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.MaxListPinsResponseSize = len(full) - 20
+	svcapi2 := testAPIwithConfig(t, cfg, "max response size")
+	defer svcapi2.Shutdown(ctx)
+	httpResp, body := get(t, svcapi2)
+	if len(body) > cfg.MaxListPinsResponseSize {
+		t.Errorf("response is larger than the limit: %d", len(body))
+	}
+	if httpResp.Header.Get("X-Truncated") != "true" {
+		t.Error("response should be truncated")
+	}
+
+	var resp pinsvc.PinList
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Count != 3 || len(resp.Results) != 2 {
+		t.Fatalf("expected 2 of 3 results: %+v", resp)
+	}
+	var next time.Time
+	if err := next.UnmarshalText([]byte(httpResp.Header.Get("X-Next-Before"))); err != nil {
+		t.Fatal(err)
+	}
+	if !next.Equal(resp.Results[1].Created) {
+		t.Errorf("X-Next-Before should be the creation time of the last result: %s", next)
+	}
+
+	// A single result is always returned.
+	pinList := pinsvc.PinList{Results: resp.Results}
+	if !truncateToSize(&pinList, 1) || len(pinList.Results) != 1 {
+		t.Errorf("expected a single result: %+v", pinList)
+	}
+}
+
+// contextCluster is a Cluster RPC service which records the values in the
+// context of the Status calls it receives.
+type contextCluster struct {
+	mu        sync.Mutex
+	requestID string
+	identity  string
+}
+
+func (cc *contextCluster) Status(ctx context.Context, in api.Cid, out *api.GlobalPinInfo) error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.requestID, _ = api.RequestIDFromContext(ctx)
+	cc.identity, _ = api.IdentityFromContext(ctx)
+	*out = api.GlobalPinInfo{
+		Cid: in,
+		PeerMap: map[string]api.PinInfoShort{
+			clustertest.PeerID1.String(): {Status: api.TrackerStatusPinned},
+		},
+	}
+	return nil
+}
+
+func (cc *contextCluster) values() (string, string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.requestID, cc.identity
+}
+
+func TestAPIRPCContextValues(t *testing.T) {
+	ctx := context.Background()
+	cc := &contextCluster{}
+	s := rpc.NewServer(nil, "mock")
+	if err := s.RegisterName("Cluster", cc); err != nil {
+		t.Fatal(err)
+	}
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.RPCContextValues = []string{ContextValueRequestID, ContextValueIdentity}
+	svcapi := testAPIwithClient(t, cfg, "rpc context", rpc.NewClientWithServer(nil, "mock", s))
+	defer svcapi.Shutdown(ctx)
+
+	c := test.HTTPClient(t, nil, false)
+	get := func(t *testing.T, reqID, user string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, test.HTTPURL(svcapi)+"/pins/"+clustertest.Cid1.String(), nil)
+		if reqID != "" {
+			req.Header.Set("X-Request-Id", reqID)
+		}
+		if user != "" {
+			req.SetBasicAuth(user, "pass")
+		}
+		httpResp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		httpResp.Body.Close()
+		if httpResp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status: %d", httpResp.StatusCode)
+		}
+	}
+
+	get(t, "abc", "user")
+	reqID, identity := cc.values()
+	if reqID != "abc" {
+		t.Errorf("expected request ID abc: %q", reqID)
+	}
+	if identity != ownerID("basic:user") {
+		t.Errorf("unexpected identity: %q", identity)
+	}
+
+	// A request ID is generated when not provided. There is no identity
+	// without credentials.
+	get(t, "", "")
+	reqID, identity = cc.values()
+	if reqID == "" {
+		t.Error("a request ID should have been generated")
+	}
+	if identity != "" {
+		t.Errorf("expected no identity: %q", identity)
+	}
+
+	cfg2 := NewConfig()
+	cfg2.Default()
+	cfg2.RPCContextValues = []string{"something"}
+	if err := cfg2.Validate(); err == nil {
+		t.Error("expected an error for an unknown context value")
+	}
+}
+
+func TestPage(t *testing.T) {
+	now := time.Now()
+	st := func(c api.Cid, age time.Duration) pinsvc.PinStatus {
+		return pinsvc.PinStatus{
+			Created: now.Add(-age),
+			Pin:     pinsvc.Pin{Cid: c},
+		}
+	}
+
+	pg := newPage(2)
+	pg.add(st(clustertest.Cid3, 3*time.Minute), api.GlobalPinInfo{})
+	pg.add(st(clustertest.Cid1, time.Minute), api.GlobalPinInfo{})
+	pg.add(st(clustertest.Cid4, 4*time.Minute), api.GlobalPinInfo{})
+	pg.add(st(clustertest.Cid2, 2*time.Minute), api.GlobalPinInfo{})
+
+	results := pg.results()
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results: %+v", results)
+	}
+	if !results[0].st.Pin.Cid.Equals(clustertest.Cid1) || !results[1].st.Pin.Cid.Equals(clustertest.Cid2) {
+		t.Errorf("expected the newest pins in order: %+v", results)
+	}
+
+	cursor, err := pinsvc.ParseCursor(pinsvc.CursorFor(results[1].st).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cursor.Created.Equal(results[1].st.Created) || !cursor.Cid.Equals(clustertest.Cid2) {
+		t.Errorf("cursor did not round trip: %+v", cursor)
+	}
+	if !cursor.Precedes(st(clustertest.Cid3, 3*time.Minute)) {
+		t.Error("older pins come after the cursor")
+	}
+	if cursor.Precedes(st(clustertest.Cid1, time.Minute)) || cursor.Precedes(results[1].st) {
+		t.Error("newer pins and the cursor pin itself do not come after the cursor")
+	}
+
+	if _, err := pinsvc.ParseCursor("not a cursor"); err == nil {
+		t.Error("expected an error parsing an invalid cursor")
+	}
+}
+
+func TestAPIListPinsCursor(t *testing.T) {
+	ctx := context.Background()
+	svcapi := testAPI(t)
+	defer svcapi.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		expected := []api.Cid{clustertest.Cid1, clustertest.Cid2, clustertest.Cid3}
+		next := ""
+		for i, c := range expected {
+			var resp pinsvc.PinList
+			u := url(svcapi) + "/pins?limit=1"
+			if next != "" {
+				u += "&cursor=" + next
+			}
+			test.MakeGet(t, svcapi, u, &resp)
+			if len(resp.Results) != 1 || !resp.Results[0].Pin.Cid.Equals(c) {
+				t.Fatalf("page %d: expected %s: %+v", i, c, resp)
+			}
+			if i < len(expected)-1 && resp.Next == "" {
+				t.Fatalf("page %d: expected a continuation token", i)
+			}
+			next = resp.Next
+		}
+		if next != "" {
+			t.Errorf("the last page should not have a continuation token: %s", next)
+		}
+
+		var errResp pinsvc.APIError
+		test.MakeGet(t, svcapi, url(svcapi)+"/pins?cursor=abc", &errResp)
+		if errResp.Details.Reason == "" {
+			t.Error("expected an error for an invalid cursor")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIAddPinsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.CORSAllowedOrigins = []string{"myorigin"}
+	cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+	cfg.MaxBulkPins = 3
+	svcapi := testAPIwithConfig(t, cfg, "bulk")
+	defer svcapi.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		pins := []pinsvc.Pin{
+			{Cid: clustertest.Cid1, Name: "a"},
+			{Cid: clustertest.ErrorCid},
+			{Cid: clustertest.Cid2, Name: "b"},
+		}
+		pinsJSON, err := json.Marshal(pins)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var results []pinsvc.PinStatus
+		test.MakePost(t, svcapi, url(svcapi)+"/pins/bulk", pinsJSON, &results)
+		if len(results) != 3 {
+			t.Fatalf("expected 3 results: %+v", results)
+		}
+		for i, st := range results {
+			if !st.Pin.Cid.Equals(pins[i].Cid) {
+				t.Errorf("result %d: expected %s: %s", i, pins[i].Cid, st.Pin.Cid)
+			}
+		}
+		if results[0].Status == pinsvc.StatusFailed || results[0].Pin.Name != "a" {
+			t.Errorf("first pin should succeed: %+v", results[0])
+		}
+		if results[1].Status != pinsvc.StatusFailed || results[1].Info["error"] == "" {
+			t.Errorf("second pin should fail with an error: %+v", results[1])
+		}
+		if results[2].Status == pinsvc.StatusFailed {
+			t.Errorf("third pin should succeed: %+v", results[2])
+		}
+
+		pinsJSON, err = json.Marshal(append(pins, pinsvc.Pin{Cid: clustertest.Cid3}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var errResp pinsvc.APIError
+		test.MakePost(t, svcapi, url(svcapi)+"/pins/bulk", pinsJSON, &errResp)
+		if !strings.Contains(errResp.Details.Details, "the limit is 3") {
+			t.Errorf("expected an error for too many pins: %+v", errResp)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+// tenantCluster is a Cluster RPC service keeping the pins it receives.
+type tenantCluster struct {
+	mu   sync.Mutex
+	pins map[api.Cid]api.Pin
+}
+
+func (tc *tenantCluster) Pin(ctx context.Context, in api.Pin, out *api.Pin) error {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.pins[in.Cid] = in
+	*out = in
+	return nil
+}
+
+func (tc *tenantCluster) Unpin(ctx context.Context, in api.Pin, out *api.Pin) error {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	pin, ok := tc.pins[in.Cid]
+	if !ok {
+		return state.ErrNotFound
+	}
+	delete(tc.pins, in.Cid)
+	*out = pin
+	return nil
+}
+
+func (tc *tenantCluster) PinGet(ctx context.Context, in api.Cid, out *api.Pin) error {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	pin, ok := tc.pins[in]
+	if !ok {
+		return state.ErrNotFound
+	}
+	*out = pin
+	return nil
+}
+
+func (tc *tenantCluster) gpi(pin api.Pin) api.GlobalPinInfo {
+	return api.GlobalPinInfo{
+		Cid:      pin.Cid,
+		Metadata: pin.Metadata,
+		PeerMap: map[string]api.PinInfoShort{
+			clustertest.PeerID1.String(): {Status: api.TrackerStatusPinned},
+		},
+	}
+}
+
+func (tc *tenantCluster) Status(ctx context.Context, in api.Cid, out *api.GlobalPinInfo) error {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	pin, ok := tc.pins[in]
+	if !ok {
+		return state.ErrNotFound
+	}
+	*out = tc.gpi(pin)
+	return nil
+}
+
+func (tc *tenantCluster) StatusAll(ctx context.Context, in <-chan api.TrackerStatus, out chan<- api.GlobalPinInfo) error {
+	defer close(out)
+	<-in
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	for _, pin := range tc.pins {
+		out <- tc.gpi(pin)
+	}
+	return nil
+}
+
+func (tc *tenantCluster) SearchPins(ctx context.Context, in <-chan api.PinSearch, out chan<- api.Pin) error {
+	defer close(out)
+	search := <-in
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	for _, pin := range tc.pins {
+		if search.Match(pin) {
+			out <- pin
+		}
+	}
+	return nil
+}
+
+func TestAPITenantScoping(t *testing.T) {
+	ctx := context.Background()
+	tc := &tenantCluster{pins: make(map[api.Cid]api.Pin)}
+	// A pin without owner.
+	tc.pins[clustertest.Cid3] = api.PinCid(clustertest.Cid3)
+	s := rpc.NewServer(nil, "mock")
+	if err := s.RegisterName("Cluster", tc); err != nil {
+		t.Fatal(err)
+	}
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.TenantScoping = true
+	svcapi := testAPIwithClient(t, cfg, "tenant scoping", rpc.NewClientWithServer(nil, "mock", s))
+	defer svcapi.Shutdown(ctx)
+
+	c := test.HTTPClient(t, nil, false)
+	do := func(t *testing.T, method, path, user string, body []byte, resp interface{}) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, test.HTTPURL(svcapi)+path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth(user, "pass")
+		httpResp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer httpResp.Body.Close()
+		if resp != nil {
+			json.NewDecoder(httpResp.Body).Decode(resp)
+		}
+		return httpResp.StatusCode
+	}
+	pinJSON := func(c api.Cid) []byte {
+		raw, err := json.Marshal(pinsvc.Pin{Cid: c})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	if st := do(t, "POST", "/pins", "alice", pinJSON(clustertest.Cid1), nil); st != http.StatusOK {
+		t.Fatalf("alice should be able to pin: %d", st)
+	}
+	if st := do(t, "POST", "/pins", "bob", pinJSON(clustertest.Cid2), nil); st != http.StatusOK {
+		t.Fatalf("bob should be able to pin: %d", st)
+	}
+	if st := do(t, "POST", "/pins", "bob", pinJSON(clustertest.Cid1), nil); st != http.StatusOK {
+		t.Errorf("bob should be able to pin alice's cid too: %d", st)
+	}
+
+	var list pinsvc.PinList
+	do(t, "GET", "/pins", "alice", nil, &list)
+	if list.Count != 1 || !list.Results[0].Pin.Cid.Equals(clustertest.Cid1) {
+		t.Errorf("alice should only see their own pin: %+v", list)
+	}
+	if _, ok := list.Results[0].Pin.Meta[ownerMetaKey]; ok {
+		t.Error("the owner should not be shown in meta")
+	}
+	do(t, "GET", "/pins?cid="+clustertest.Cid1.String()+","+clustertest.Cid2.String(), "alice", nil, &list)
+	if list.Count != 1 || !list.Results[0].Pin.Cid.Equals(clustertest.Cid1) {
+		t.Errorf("alice should only see their own pin when listing by cid: %+v", list)
+	}
+
+	// Records and anchors cannot be forged through meta to make a pin
+	// show up for another tenant.
+	alice := ownerID("basic:alice")
+	forgeries := []map[string]string{
+		{requestIDKey(alice): requestRecord{Owner: alice}.String()},
+		{ownerMetaKey: alice},
+		{createdMetaKey: "2000-01-01T00:00:00Z"},
+	}
+	for _, meta := range forgeries {
+		raw, err := json.Marshal(pinsvc.Pin{Cid: clustertest.Cid4, Meta: meta})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st := do(t, "POST", "/pins", "bob", raw, nil); st != http.StatusBadRequest {
+			t.Errorf("bob should not forge internal meta %v: %d", meta, st)
+		}
+	}
+	do(t, "GET", "/pins", "alice", nil, &list)
+	if list.Count != 1 || !list.Results[0].Pin.Cid.Equals(clustertest.Cid1) {
+		t.Errorf("forged records should not show up for alice: %+v", list)
+	}
+
+	if st := do(t, "GET", "/pins/"+clustertest.Cid2.String(), "alice", nil, nil); st != http.StatusNotFound {
+		t.Errorf("alice should not see bob's pin: %d", st)
+	}
+	if st := do(t, "GET", "/pins/"+clustertest.Cid3.String(), "alice", nil, nil); st != http.StatusNotFound {
+		t.Errorf("alice should not see pins without owner: %d", st)
+	}
+	if st := do(t, "POST", "/pins/"+clustertest.Cid2.String(), "alice", pinJSON(clustertest.Cid4), nil); st != http.StatusNotFound {
+		t.Errorf("alice should not replace bob's pin: %d", st)
+	}
+	if st := do(t, "DELETE", "/pins/"+clustertest.Cid2.String(), "alice", nil, nil); st != http.StatusNotFound {
+		t.Errorf("alice should not remove bob's pin: %d", st)
+	}
+	if st := do(t, "DELETE", "/pins/"+clustertest.Cid2.String(), "bob", nil, nil); st != http.StatusAccepted {
+		t.Errorf("bob should remove their own pin: %d", st)
+	}
+
+	// Removing a shared cid removes only the request of the owner.
+	if st := do(t, "DELETE", "/pins/"+clustertest.Cid1.String(), "bob", nil, nil); st != http.StatusAccepted {
+		t.Errorf("bob should remove their request for the shared cid: %d", st)
+	}
+	if st := do(t, "GET", "/pins/"+clustertest.Cid1.String(), "bob", nil, nil); st != http.StatusNotFound {
+		t.Errorf("bob should not see the shared cid after removing it: %d", st)
+	}
+	if st := do(t, "GET", "/pins/"+clustertest.Cid1.String(), "alice", nil, nil); st != http.StatusOK {
+		t.Errorf("the shared cid should stay pinned for alice: %d", st)
+	}
+	if st := do(t, "DELETE", "/pins/"+clustertest.Cid1.String(), "alice", nil, nil); st != http.StatusAccepted {
+		t.Errorf("alice should remove their own pin: %d", st)
+	}
+	tc.mu.Lock()
+	_, pinned := tc.pins[clustertest.Cid1]
+	tc.mu.Unlock()
+	if pinned {
+		t.Error("the shared cid should be unpinned with its last owner")
+	}
+}
+
+func TestAPIEnforcePinOwnership(t *testing.T) {
+	ctx := context.Background()
+	tc := &tenantCluster{pins: make(map[api.Cid]api.Pin)}
+	s := rpc.NewServer(nil, "mock")
+	if err := s.RegisterName("Cluster", tc); err != nil {
+		t.Fatal(err)
+	}
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.BasicAuthCredentials = map[string]string{
+		"alice": "pass",
+		"bob":   "pass",
+		"admin": "pass",
+	}
+	allButAdmin := []common.Scope{common.ScopeRead, common.ScopePin, common.ScopeUnpin}
+	cfg.BasicAuthScopes = map[string][]common.Scope{
+		"alice": allButAdmin,
+		"bob":   allButAdmin,
+	}
+	cfg.EnforcePinOwnership = true
+	svcapi := testAPIwithClient(t, cfg, "enforce pin ownership", rpc.NewClientWithServer(nil, "mock", s))
+	defer svcapi.Shutdown(ctx)
+
+	c := test.HTTPClient(t, nil, false)
+	do := func(t *testing.T, method, path, user string, body []byte) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, test.HTTPURL(svcapi)+path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth(user, "pass")
+		httpResp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		httpResp.Body.Close()
+		return httpResp.StatusCode
+	}
+	pinJSON := func(c api.Cid, name string) []byte {
+		raw, err := json.Marshal(pinsvc.Pin{Cid: c, Name: pinsvc.PinName(name)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	getPin := func(c api.Cid) api.Pin {
+		tc.mu.Lock()
+		defer tc.mu.Unlock()
+		return tc.pins[c]
+	}
+
+	if st := do(t, "POST", "/pins", "alice", pinJSON(clustertest.Cid1, "alice")); st != http.StatusOK {
+		t.Fatalf("alice should be able to pin: %d", st)
+	}
+	if owner := getPin(clustertest.Cid1).Owner; owner != "user:alice" {
+		t.Errorf("the pin should be owned by alice: %s", owner)
+	}
+
+	// Pinning a cid owned by somebody else keeps its options.
+	if st := do(t, "POST", "/pins", "bob", pinJSON(clustertest.Cid1, "bob")); st != http.StatusOK {
+		t.Errorf("bob should be able to request alice's pin: %d", st)
+	}
+	if pin := getPin(clustertest.Cid1); pin.Name != "alice" || pin.Owner != "user:alice" {
+		t.Errorf("bob should not modify alice's pin: %s %s", pin.Name, pin.Owner)
+	}
+
+	if st := do(t, "DELETE", "/pins/"+clustertest.Cid1.String(), "bob", nil); st != http.StatusForbidden {
+		t.Errorf("bob should not remove alice's pin: %d", st)
+	}
+	if st := do(t, "POST", "/pins/"+clustertest.Cid1.String(), "bob", pinJSON(clustertest.Cid2, "bob")); st != http.StatusForbidden {
+		t.Errorf("bob should not replace alice's pin: %d", st)
+	}
+	if st := do(t, "DELETE", "/pins/"+clustertest.Cid1.String()+"?override=true", "bob", nil); st != http.StatusForbidden {
+		t.Errorf("only admins should override the ownership: %d", st)
+	}
+	if !getPin(clustertest.Cid1).Defined() {
+		t.Fatal("alice's pin should still be pinned")
+	}
+
+	if st := do(t, "DELETE", "/pins/"+clustertest.Cid1.String()+"?override=true", "admin", nil); st != http.StatusAccepted {
+		t.Errorf("admins should override the ownership: %d", st)
+	}
+	if getPin(clustertest.Cid1).Defined() {
+		t.Error("alice's pin should have been removed by the admin")
+	}
+}
+
+func TestAPIPinEndpointReservedMeta(t *testing.T) {
+	ctx := context.Background()
+	tc := &tenantCluster{pins: make(map[api.Cid]api.Pin)}
+	s := rpc.NewServer(nil, "mock")
+	if err := s.RegisterName("Cluster", tc); err != nil {
+		t.Fatal(err)
+	}
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.RequestIDMode = RequestIDModeUUID
+	svcapi := testAPIwithClient(t, cfg, "reserved meta", rpc.NewClientWithServer(nil, "mock", s))
+	defer svcapi.Shutdown(ctx)
+
+	c := test.HTTPClient(t, nil, false)
+	do := func(t *testing.T, method, path string, body []byte, resp interface{}) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, test.HTTPURL(svcapi)+path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		httpResp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer httpResp.Body.Close()
+		if resp != nil {
+			json.NewDecoder(httpResp.Body).Decode(resp)
+		}
+		return httpResp.StatusCode
+	}
+	pinJSON := func(c api.Cid, meta map[string]string) []byte {
+		raw, err := json.Marshal(pinsvc.Pin{Cid: c, Meta: meta})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	var status pinsvc.PinStatus
+	if st := do(t, "POST", "/pins", pinJSON(clustertest.Cid1, nil), &status); st != http.StatusOK {
+		t.Fatalf("should be able to pin: %d", st)
+	}
+	victim := status.RequestID
+
+	// A pin forging the record of the victim's requestID is rejected.
+	forged := map[string]string{requestIDKey(victim): "{}"}
+	if st := do(t, "POST", "/pins", pinJSON(clustertest.Cid2, forged), nil); st != http.StatusBadRequest {
+		t.Errorf("reserved meta keys should be rejected: %d", st)
+	}
+	tc.mu.Lock()
+	_, pinned := tc.pins[clustertest.Cid2]
+	tc.mu.Unlock()
+	if pinned {
+		t.Error("the pin with a forged record should not be pinned")
+	}
+
+	status = pinsvc.PinStatus{}
+	if st := do(t, "GET", "/pins/"+victim, nil, &status); st != http.StatusOK || !status.Pin.Cid.Equals(clustertest.Cid1) {
+		t.Errorf("the requestID should still find the victim's pin: %d %s", st, status.Pin.Cid)
+	}
+}
+
+func TestAPIRemovePinRequestIDFallback(t *testing.T) {
+	ctx := context.Background()
+	tc := &tenantCluster{pins: make(map[api.Cid]api.Pin)}
+	// A pin added before requestIDs were recorded.
+	tc.pins[clustertest.Cid2] = api.PinCid(clustertest.Cid2)
+	s := rpc.NewServer(nil, "mock")
+	if err := s.RegisterName("Cluster", tc); err != nil {
+		t.Fatal(err)
+	}
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.RequestIDMode = RequestIDModeUUID
+	svcapi := testAPIwithClient(t, cfg, "requestID fallback", rpc.NewClientWithServer(nil, "mock", s))
+	defer svcapi.Shutdown(ctx)
+
+	c := test.HTTPClient(t, nil, false)
+	do := func(t *testing.T, method, path string, body []byte) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, test.HTTPURL(svcapi)+path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		httpResp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		httpResp.Body.Close()
+		return httpResp.StatusCode
+	}
+	pinned := func(c api.Cid) bool {
+		tc.mu.Lock()
+		defer tc.mu.Unlock()
+		_, ok := tc.pins[c]
+		return ok
+	}
+
+	pinJSON, err := json.Marshal(pinsvc.Pin{Cid: clustertest.Cid1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if st := do(t, "POST", "/pins", pinJSON); st != http.StatusOK {
+			t.Fatalf("should be able to pin: %d", st)
+		}
+	}
+
+	// The cid does not refer to pins held under their own requestIDs.
+	for _, method := range []string{"GET", "DELETE"} {
+		if st := do(t, method, "/pins/"+clustertest.Cid1.String(), nil); st != http.StatusNotFound {
+			t.Errorf("%s: the cid should not be a requestID of the pin: %d", method, st)
+		}
+	}
+	if !pinned(clustertest.Cid1) {
+		t.Error("the pin should be kept for its requests")
+	}
+	_, err = svcapi.dropRequests(ctx, clustertest.Cid1, func(string, requestRecord) bool { return false })
+	if err != state.ErrNotFound {
+		t.Errorf("dropping no request should not remove the pin: %v", err)
+	}
+	if !pinned(clustertest.Cid1) {
+		t.Error("the pin should be kept when no request is dropped")
+	}
+
+	if st := do(t, "DELETE", "/pins/"+clustertest.Cid2.String(), nil); st != http.StatusAccepted {
+		t.Errorf("pins without requestIDs should be removed by cid: %d", st)
+	}
+	if pinned(clustertest.Cid2) {
+		t.Error("the pin without requestIDs should have been removed")
+	}
+}
+
+func TestAPIStaticDelegates(t *testing.T) {
+	ctx := context.Background()
+	static, _ := api.NewMultiaddr("/dns4/gateway.example.com/tcp/4001/p2p/" + clustertest.PeerID1.String())
+
+	for _, merge := range []bool{false, true} {
+		cfg := NewConfig()
+		cfg.Default()
+		cfg.CORSAllowedOrigins = []string{"myorigin"}
+		cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+		cfg.StaticDelegates = []api.Multiaddr{static}
+		cfg.MergeStaticDelegates = merge
+		svcapi := testAPIwithConfig(t, cfg, "static delegates")
+
+		// The mock returns one IPFS address for Cid1.
+		expected := 1
+		if merge {
+			expected = 2
+		}
+
+		var status pinsvc.PinStatus
+		test.MakeGet(t, svcapi, test.HTTPURL(svcapi)+"/pins/"+clustertest.Cid1.String(), &status)
+		if len(status.Delegates) != expected || status.Delegates[0].String() != static.String() {
+			t.Errorf("merge=%t: unexpected delegates: %+v", merge, status.Delegates)
+		}
+
+		var list pinsvc.PinList
+		test.MakeGet(t, svcapi, test.HTTPURL(svcapi)+"/pins?limit=1", &list)
+		if len(list.Results) != 1 || len(list.Results[0].Delegates) != expected || list.Results[0].Delegates[0].String() != static.String() {
+			t.Errorf("merge=%t: unexpected delegates in list: %+v", merge, list.Results)
+		}
+
+		svcapi.Shutdown(ctx)
+	}
+}
+
+func TestAPIListPinsStream(t *testing.T) {
+	ctx := context.Background()
+	svcapi := testAPI(t)
+	defer svcapi.Shutdown(ctx)
+
+	c := test.HTTPClient(t, nil, false)
+	stream := func(t *testing.T, query string) []pinsvc.PinStatus {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, test.HTTPURL(svcapi)+"/pins"+query, nil)
+		req.Header.Set("Accept", "application/x-ndjson")
+		httpResp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer httpResp.Body.Close()
+
+		var results []pinsvc.PinStatus
+		dec := json.NewDecoder(httpResp.Body)
+		for dec.More() {
+			var st pinsvc.PinStatus
+			if err := dec.Decode(&st); err != nil {
+				t.Fatal(err)
+			}
+			results = append(results, st)
+		}
+		if e := httpResp.Trailer.Get("X-Stream-Error"); e != "" {
+			t.Errorf("unexpected stream error: %s", e)
+		}
+		return results
+	}
+
+	// The limit does not apply.
+	if results := stream(t, "?limit=1"); len(results) != 3 {
+		t.Errorf("expected 3 streamed results: %+v", results)
+	}
+	results := stream(t, "?status=pinned")
+	if len(results) != 1 || !results[0].Pin.Cid.Equals(clustertest.Cid1) {
+		t.Errorf("expected Cid1 as only pinned result: %+v", results)
+	}
+	results = stream(t, "?cid="+clustertest.Cid2.String())
+	if len(results) != 1 || !results[0].Pin.Cid.Equals(clustertest.Cid2) {
+		t.Errorf("expected Cid2 as only result: %+v", results)
+	}
+}
+
+// windowCluster is a Cluster RPC service which records the filter of the
+// StatusAllFiltered calls it receives.
+type windowCluster struct {
+	mu         sync.Mutex
+	filter     api.StatusAllFilter
+	statusAlls int
+}
+
+func (wc *windowCluster) StatusAll(ctx context.Context, in <-chan api.TrackerStatus, out chan<- api.GlobalPinInfo) error {
+	defer close(out)
+	<-in
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	wc.statusAlls++
+	return nil
+}
+
+func (wc *windowCluster) StatusAllFiltered(ctx context.Context, in <-chan api.StatusAllFilter, out chan<- api.GlobalPinInfo) error {
+	defer close(out)
+	filter := <-in
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	wc.filter = filter
+	return nil
+}
+
+func TestAPIListPinsCreatedWindow(t *testing.T) {
+	ctx := context.Background()
+	wc := &windowCluster{}
+	s := rpc.NewServer(nil, "mock")
+	if err := s.RegisterName("Cluster", wc); err != nil {
+		t.Fatal(err)
+	}
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.CORSAllowedOrigins = []string{"myorigin"}
+	cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+	svcapi := testAPIwithClient(t, cfg, "created window", rpc.NewClientWithServer(nil, "mock", s))
+	defer svcapi.Shutdown(ctx)
+
+	after := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	before := after.Add(30 * time.Minute)
+	afterStr, _ := after.MarshalText()
+	beforeStr, _ := before.MarshalText()
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var resp pinsvc.PinList
+		test.MakeGet(t, svcapi, url(svcapi)+"/pins?status=pinned&after="+string(afterStr)+"&before="+string(beforeStr), &resp)
+		wc.mu.Lock()
+		filter := wc.filter
+		statusAlls := wc.statusAlls
+		wc.mu.Unlock()
+		if !filter.CreatedAfter.Equal(after) || !filter.CreatedBefore.Equal(before) {
+			t.Errorf("unexpected created bounds: %+v", filter)
+		}
+		if filter.Status != api.TrackerStatusPinned {
+			t.Errorf("unexpected status filter: %s", filter.Status)
+		}
+		if statusAlls != 0 {
+			t.Error("StatusAll should not be used with created bounds")
+		}
+
+		test.MakeGet(t, svcapi, url(svcapi)+"/pins", &resp)
+		wc.mu.Lock()
+		statusAlls = wc.statusAlls
+		wc.statusAlls = 0
+		wc.mu.Unlock()
+		if statusAlls != 1 {
+			t.Error("StatusAll should be used without created bounds")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestStatusAllFilter(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Default()
+	svcapi := &API{config: cfg}
+
+	now := time.Now()
+	opts := &pinsvc.ListOptions{
+		After:  now.Add(-time.Hour),
+		Before: now,
+		Cursor: pinsvc.Cursor{Created: now.Add(-time.Minute), Cid: clustertest.Cid1},
+	}
+	filter := svcapi.statusAllFilter(opts, api.TrackerStatusPinned)
+	if !filter.CreatedAfter.Equal(opts.After) {
+		t.Error("expected after as lower bound")
+	}
+	if !filter.CreatedBefore.Equal(opts.Cursor.Created) {
+		t.Error("expected the cursor as upper bound")
+	}
+
+	if filter.MatchCreated(now) || filter.MatchCreated(now.Add(-2*time.Hour)) {
+		t.Error("times out of bounds should not match")
+	}
+	if !filter.MatchCreated(opts.Cursor.Created) || !filter.MatchCreated(opts.After) {
+		t.Error("bounds should be inclusive")
+	}
+
+	cfg.AnchorCreated = true
+	filter = svcapi.statusAllFilter(opts, api.TrackerStatusPinned)
+	if !filter.CreatedBefore.IsZero() {
+		t.Error("no upper bound should be set with AnchorCreated")
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	testcases := []struct {
+		err     error
+		status  int
+		reason  string
+		details string
+	}{
+		{errors.New("bad"), http.StatusBadRequest, pinsvc.ReasonBadRequest, "bad"},
+		{errors.New("who"), http.StatusUnauthorized, pinsvc.ReasonUnauthorized, "who"},
+		{errors.New("where"), http.StatusNotFound, pinsvc.ReasonNotFound, "where"},
+		{errors.New("oops"), http.StatusInternalServerError, pinsvc.ReasonInternalServerError, "oops"},
+		{errors.New("slow down"), http.StatusTooManyRequests, "TOO_MANY_REQUESTS", "slow down"},
+		{pinsvc.NewAPIError("CUSTOM", "custom"), http.StatusBadRequest, "CUSTOM", "custom"},
+	}
+
+	for _, tc := range testcases {
+		var apiErr pinsvc.APIError
+		if !errors.As(apiError(tc.err, tc.status), &apiErr) {
+			t.Fatal("expected an APIError")
+		}
+		if apiErr.Details.Reason != tc.reason || apiErr.Details.Details != tc.details {
+			t.Errorf("unexpected error for %q (%d): %+v", tc.err, tc.status, apiErr)
+		}
+	}
+}
+
+func TestAPIErrorResponses(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.CORSAllowedOrigins = []string{"myorigin"}
+	cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+	cfg.BasicAuthCredentials = map[string]string{"user": "pass"}
+	svcapi := testAPIwithConfig(t, cfg, "error responses")
+	defer svcapi.Shutdown(ctx)
+
+	c := test.HTTPClient(t, nil, false)
+	do := func(t *testing.T, method, path string, auth bool, body []byte) (int, pinsvc.APIError) {
+		t.Helper()
+		req, _ := http.NewRequest(method, test.HTTPURL(svcapi)+path, bytes.NewReader(body))
+		if auth {
+			req.SetBasicAuth("user", "pass")
+		}
+		httpResp, err := c.Do(req)
+		var errResp pinsvc.APIError
+		test.ProcessResp(t, httpResp, err, &errResp)
+		return httpResp.StatusCode, errResp
+	}
+
+	status, errResp := do(t, http.MethodGet, "/pins", false, nil)
+	if status != http.StatusUnauthorized || errResp.Details.Reason != pinsvc.ReasonUnauthorized {
+		t.Errorf("expected an unauthorized error: %d %+v", status, errResp)
+	}
+
+	status, errResp = do(t, http.MethodPost, "/pins", true, []byte(`{"cid": "abc"}`))
+	if status != http.StatusBadRequest || errResp.Details.Reason != pinsvc.ReasonBadRequest {
+		t.Errorf("expected a bad request error for an invalid cid: %d %+v", status, errResp)
+	}
+	if errResp.Details.Details == "" {
+		t.Error("expected error details")
+	}
+
+	status, errResp = do(t, http.MethodGet, "/pins/abcd", true, nil)
+	if status != http.StatusBadRequest || errResp.Details.Reason != pinsvc.ReasonBadRequest {
+		t.Errorf("expected a bad request error for an invalid requestID: %d %+v", status, errResp)
+	}
+}
+
+func TestAPIPinEndpointWait(t *testing.T) {
+	ctx := context.Background()
+	svcapi := testAPI(t)
+	defer svcapi.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		pinJSON := func(c api.Cid) []byte {
+			b, err := json.Marshal(pinsvc.Pin{Cid: c})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return b
+		}
+
+		var status pinsvc.PinStatus
+		test.MakePost(t, svcapi, url(svcapi)+"/pins?wait=pinned&timeout=5s", pinJSON(clustertest.Cid1), &status)
+		if status.Status != pinsvc.StatusPinned {
+			t.Errorf("expected pinned after waiting: %s", status.Status)
+		}
+		if status.RequestID != clustertest.Cid1.String() {
+			t.Errorf("unexpected requestID: %s", status.RequestID)
+		}
+
+		start := time.Now()
+		var slowStatus pinsvc.PinStatus
+		test.MakePost(t, svcapi, url(svcapi)+"/pins?wait=pinned&timeout=300ms", pinJSON(clustertest.SlowCid1), &slowStatus)
+		if slowStatus.Status == pinsvc.StatusPinned {
+			t.Error("SlowCid1 should not be pinned")
+		}
+		if elapsed := time.Since(start); elapsed < 300*time.Millisecond || elapsed > 3*time.Second {
+			t.Errorf("unexpected wait: %s", elapsed)
+		}
+
+		var errResp pinsvc.APIError
+		test.MakePost(t, svcapi, url(svcapi)+"/pins?wait=queued", pinJSON(clustertest.Cid1), &errResp)
+		if errResp.Details.Reason != pinsvc.ReasonBadRequest {
+			t.Errorf("expected an error for an invalid wait: %+v", errResp)
+		}
+		errResp = pinsvc.APIError{}
+		test.MakePost(t, svcapi, url(svcapi)+"/pins?wait=pinned&timeout=abc", pinJSON(clustertest.Cid1), &errResp)
+		if errResp.Details.Reason != pinsvc.ReasonBadRequest {
+			t.Errorf("expected an error for an invalid timeout: %+v", errResp)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
@@ -0,0 +1,399 @@
+package pinsvcapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/api/common"
+	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi/pinsvc"
+	"github.com/ipfs-cluster/ipfs-cluster/state"
+)
+
+// requestIDMetaKey is the pin metadata key storing the latest requestID of
+// a pin when using deterministic or UUID requestIDs. It is the requestID
+// shown when listing pins.
+const requestIDMetaKey = "pinsvc_requestid"
+
+// requestIDMetaPrefix prefixes the pin metadata keys which record every
+// requestID of a pin. Several requests for the same cid keep their own
+// requestIDs this way, and each of them is found through the metadata index
+// of the shared state. The value of each key is a requestRecord.
+const requestIDMetaPrefix = requestIDMetaKey + "/"
+
+// internalMetaPrefix prefixes the pin metadata keys used by this API, which
+// are not shown to the user.
+const internalMetaPrefix = "pinsvc_"
+
+// checkUserMeta returns an error when the given meta of a pin request uses
+// any of the keys reserved for the internal metadata of this API, which
+// clients must not be able to forge.
+func checkUserMeta(meta map[string]string) error {
+	for k := range meta {
+		if strings.HasPrefix(k, internalMetaPrefix) {
+			return fmt.Errorf("meta.%s: keys starting with %q are reserved", k, internalMetaPrefix)
+		}
+	}
+	return nil
+}
+
+func requestIDKey(rID string) string {
+	return requestIDMetaPrefix + rID
+}
+
+// requestRecord keeps the name and meta given in a pin request, as the pin
+// for a cid is shared by all the requests for it and only shows those of
+// the latest one. The owner identifies the token which made the request,
+// when UniquePinsPerOwner or TenantScoping are enabled.
+type requestRecord struct {
+	Name  string            `json:"name,omitempty"`
+	Meta  map[string]string `json:"meta,omitempty"`
+	Owner string            `json:"owner,omitempty"`
+}
+
+// newRequestRecord returns the record for a request for the given pin made
+// by the given owner.
+func newRequestRecord(pin pinsvc.Pin, owner string) requestRecord {
+	return requestRecord{
+		Name:  string(pin.Name),
+		Meta:  pin.Meta,
+		Owner: owner,
+	}
+}
+
+func (rec requestRecord) String() string {
+	raw, _ := json.Marshal(rec)
+	return string(raw)
+}
+
+// recordFromMeta returns the record of the given requestID stored in the pin
+// metadata. Pins created before records were kept store the requestID
+// instead, and have no record. Records without owner belong to the owner of
+// the whole pin, if it has one.
+func recordFromMeta(metadata map[string]string, rID string) (requestRecord, bool) {
+	var rec requestRecord
+	v, ok := metadata[requestIDKey(rID)]
+	if !ok || json.Unmarshal([]byte(v), &rec) != nil {
+		return rec, false
+	}
+	if rec.Owner == "" {
+		rec.Owner = metadata[ownerMetaKey]
+	}
+	return rec, true
+}
+
+// recordFor returns the key and the record of the request shown for the
+// given requestID to the given owner: the record of that requestID, or else
+// that of the latest request, or else any of the requests of the owner. The
+// requests of every owner are considered when owner is empty.
+func recordFor(metadata map[string]string, rID, owner string) (string, requestRecord, bool) {
+	owned := func(key string) (requestRecord, bool) {
+		rec, ok := recordFromMeta(metadata, key)
+		return rec, ok && (owner == "" || rec.Owner == owner)
+	}
+	if rec, ok := owned(rID); ok {
+		return rID, rec, true
+	}
+	if latest, ok := metadata[requestIDMetaKey]; ok {
+		if rec, ok := owned(latest); ok {
+			return latest, rec, true
+		}
+	}
+	if owner != "" {
+		for _, key := range requestIDs(metadata) {
+			if rec, ok := owned(key); ok {
+				return key, rec, true
+			}
+		}
+	}
+	return "", requestRecord{}, false
+}
+
+// ownsRequest returns true when the given owner made any of the requests
+// recorded in the given pin metadata.
+func ownsRequest(metadata map[string]string, owner string) bool {
+	for _, key := range requestIDs(metadata) {
+		if rec, ok := recordFromMeta(metadata, key); ok && rec.Owner == owner {
+			return true
+		}
+	}
+	return metadata[ownerMetaKey] == owner
+}
+
+// deterministicRequestID returns a requestID which is the same for every
+// request for the same cid, with the same name and origins, by the same
+// owner. Each field is length-prefixed, and so is the list of origins, so
+// that different tuples never result in the same hashed input.
+func deterministicRequestID(c types.Cid, name string, origins []types.Multiaddr, owner string) string {
+	h := sha256.New()
+	write := func(field []byte) {
+		var l [8]byte
+		binary.BigEndian.PutUint64(l[:], uint64(len(field)))
+		h.Write(l[:])
+		h.Write(field)
+	}
+
+	write(c.Bytes())
+	write([]byte(name))
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(origins)))
+	h.Write(n[:])
+	for _, o := range origins {
+		write(o.Bytes())
+	}
+	write([]byte(owner))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// requestIDFromMeta sets the RequestID of the status to the latest one stored
+// in the pin metadata, unless it is already one of the requestIDs recorded
+// there, and removes the requestIDs from the metadata shown to the user. The
+// name and meta shown are those given in the request for that requestID.
+// When owner is not empty, only the requests of that owner are shown.
+func requestIDFromMeta(status *pinsvc.PinStatus, owner string) {
+	key, rec, hasRecord := recordFor(status.Pin.Meta, status.RequestID, owner)
+	_, hasLatest := status.Pin.Meta[requestIDMetaKey]
+	_, recorded := status.Pin.Meta[requestIDKey(status.RequestID)]
+	switch {
+	case hasRecord && hasLatest:
+		status.RequestID = key
+	case !recorded && hasLatest:
+		status.RequestID = status.Pin.Meta[requestIDMetaKey]
+	}
+
+	takeMeta(status, requestIDMetaKey)
+	for k := range status.Pin.Meta {
+		if strings.HasPrefix(k, requestIDMetaPrefix) {
+			takeMeta(status, k)
+		}
+	}
+	if hasRecord {
+		status.Pin.Name = pinsvc.PinName(rec.Name)
+		status.Pin.Meta = withRecordMeta(status.Pin.Meta, rec)
+	}
+}
+
+// withRecordMeta returns a copy of the given metadata where the user
+// metadata is replaced by the meta of the given record. Internal keys are
+// kept.
+func withRecordMeta(metadata map[string]string, rec requestRecord) map[string]string {
+	meta := make(map[string]string, len(rec.Meta))
+	for k, v := range metadata {
+		if strings.HasPrefix(k, internalMetaPrefix) {
+			meta[k] = v
+		}
+	}
+	for k, v := range rec.Meta {
+		meta[k] = v
+	}
+	return meta
+}
+
+// requestIDs returns the requestIDs recorded in the given pin metadata.
+func requestIDs(metadata map[string]string) []string {
+	var rIDs []string
+	for k := range metadata {
+		if strings.HasPrefix(k, requestIDMetaPrefix) {
+			rIDs = append(rIDs, strings.TrimPrefix(k, requestIDMetaPrefix))
+		}
+	}
+	sort.Strings(rIDs)
+	return rIDs
+}
+
+// hasRequestIDs returns true when the given pin metadata records the
+// requestIDs of the requests for the pin, which are then the only way to
+// refer to them.
+func hasRequestIDs(metadata map[string]string) bool {
+	_, hasLatest := metadata[requestIDMetaKey]
+	return hasLatest || len(requestIDs(metadata)) > 0
+}
+
+// userMeta returns the metadata of a pin without the internal keys.
+func userMeta(metadata map[string]string) map[string]string {
+	meta := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		if !strings.HasPrefix(k, internalMetaPrefix) {
+			meta[k] = v
+		}
+	}
+	return meta
+}
+
+// withRecord returns a copy of the given metadata including the given record
+// under the given key. The records in the metadata of the existing pin for
+// the same cid are kept, so that their requests keep working. When the
+// existing pin has a single owner instead of records with owners, the owner
+// is moved to its records, or to a record of its own if it has none.
+func withRecord(metadata map[string]string, existing types.Pin, key string, rec requestRecord) map[string]string {
+	meta := make(map[string]string, len(metadata)+2)
+	for k, v := range metadata {
+		meta[k] = v
+	}
+	delete(meta, ownerMetaKey)
+	others := requestIDs(existing.Metadata)
+	for _, other := range others {
+		meta[requestIDKey(other)] = existing.Metadata[requestIDKey(other)]
+		if otherRec, ok := recordFromMeta(existing.Metadata, other); ok {
+			meta[requestIDKey(other)] = otherRec.String()
+		}
+	}
+	if owner := existing.Metadata[ownerMetaKey]; owner != "" && len(others) == 0 {
+		legacy := requestRecord{
+			Name:  existing.Name,
+			Meta:  userMeta(existing.Metadata),
+			Owner: owner,
+		}
+		meta[requestIDKey(owner)] = legacy.String()
+	}
+	meta[requestIDKey(key)] = rec.String()
+	return meta
+}
+
+// withRequestID returns a copy of the given metadata including the given
+// requestID, which becomes the latest, with the given record of its request.
+// The records of the existing pin are kept as in withRecord.
+func withRequestID(metadata map[string]string, existing types.Pin, rID string, rec requestRecord) map[string]string {
+	meta := withRecord(metadata, existing, rID, rec)
+	meta[requestIDMetaKey] = rID
+	return meta
+}
+
+// withoutRequests returns a copy of the given pin without the requests for
+// which drop returns true, along with the number of requests left. When the
+// request whose name and meta the pin carries is removed, one of the others
+// takes its place, along with the name and meta of its request.
+func withoutRequests(pin types.Pin, drop func(key string, rec requestRecord) bool) (types.Pin, int) {
+	meta := make(map[string]string, len(pin.Metadata))
+	for k, v := range pin.Metadata {
+		meta[k] = v
+	}
+	for _, key := range requestIDs(pin.Metadata) {
+		rec, _ := recordFromMeta(pin.Metadata, key)
+		if drop(key, rec) {
+			delete(meta, requestIDKey(key))
+		}
+	}
+	left := requestIDs(meta)
+
+	// Without a latest requestID, as with cid requestIDs, the pin may
+	// carry the name and meta of any of the dropped requests.
+	latest, hasLatest := meta[requestIDMetaKey]
+	_, kept := meta[requestIDKey(latest)]
+	if !hasLatest || !kept {
+		delete(meta, requestIDMetaKey)
+		if len(left) > 0 {
+			if hasLatest {
+				meta[requestIDMetaKey] = left[0]
+			}
+			if rec, ok := recordFromMeta(meta, left[0]); ok {
+				pin.Name = rec.Name
+				meta = withRecordMeta(meta, rec)
+			}
+		}
+	}
+	pin.Metadata = meta
+	return pin, len(left)
+}
+
+// findRequestID returns the cid of the pin with the given requestID, or
+// state.ErrNotFound. The lookup uses the metadata index of the shared state,
+// first by the key recording the requestID and then, for pins which only
+// have the latest requestID, by its value.
+func (api *API) findRequestID(ctx context.Context, rID string) (types.Cid, error) {
+	searches := []types.PinSearch{
+		{Metadata: map[string]string{requestIDKey(rID): ""}},
+		{Metadata: map[string]string{requestIDMetaKey: rID}},
+	}
+	for _, search := range searches {
+		c, err := api.searchFirst(ctx, search)
+		if err != state.ErrNotFound {
+			return c, err
+		}
+	}
+	return types.CidUndef, state.ErrNotFound
+}
+
+// searchFirst returns the cid of the first pin matching the given search,
+// or state.ErrNotFound.
+func (api *API) searchFirst(ctx context.Context, search types.PinSearch) (types.Cid, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	in := make(chan types.PinSearch, 1)
+	in <- search
+	close(in)
+	out := make(chan types.Pin, common.StreamChannelSize)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		errCh <- api.rpcClient.Stream(
+			ctx,
+			"",
+			"Cluster",
+			"SearchPins",
+			in,
+			out,
+		)
+	}()
+
+	found := types.CidUndef
+	for pin := range out {
+		if !found.Defined() {
+			found = pin.Cid
+			cancel()
+		}
+	}
+	err := <-errCh
+	if found.Defined() {
+		return found, nil
+	}
+	if err != nil {
+		return found, err
+	}
+	return found, state.ErrNotFound
+}
+
+// dropRequests removes the requests for which drop returns true from the
+// pin of the given cid when the pin has other requests, which keep it
+// pinned. It returns false when none is left, or the pin records no
+// requests, and the pin should be removed. When the pin records requests
+// but none of them matches, it returns state.ErrNotFound.
+func (api *API) dropRequests(ctx context.Context, c types.Cid, drop func(key string, rec requestRecord) bool) (bool, error) {
+	var pin types.Pin
+	err := api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"PinGet",
+		c,
+		&pin,
+	)
+	if err != nil {
+		return false, err
+	}
+	before := len(requestIDs(pin.Metadata))
+	pin, left := withoutRequests(pin, drop)
+	switch {
+	case before == 0 || left == 0:
+		return false, nil
+	case left == before:
+		return false, state.ErrNotFound
+	}
+	var pinObj types.Pin
+	err = api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"Pin",
+		pin,
+		&pinObj,
+	)
+	return err == nil, err
+}
@@ -0,0 +1,58 @@
+package pinsvcapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi/pinsvc"
+)
+
+// truncateToSize removes results from the end of the pin list until its
+// JSON encoding fits in maxSize bytes, including the continuation token
+// that is set when not all the pins are included. At least one result is
+// always kept, so that clients can continue from it. It returns true when
+// the list was truncated.
+func truncateToSize(pinList *pinsvc.PinList, maxSize int) bool {
+	results := pinList.Results
+	pinList.Results = []pinsvc.PinStatus{}
+	base, err := json.Marshal(pinList)
+	pinList.Results = results
+	if err != nil {
+		return false
+	}
+
+	// Encoder.Encode adds a newline.
+	size := len(base) + 1
+	for i, st := range results {
+		raw, err := json.Marshal(st)
+		if err != nil {
+			return false
+		}
+		size += len(raw)
+		if i > 0 {
+			size++ // comma
+		}
+
+		total := size
+		if uint64(i+1) < pinList.Count {
+			total += len(`,"next":""`) + len(pinsvc.CursorFor(st).String())
+		}
+		if total > maxSize && i > 0 {
+			pinList.Results = results[:i]
+			return true
+		}
+	}
+	return false
+}
+
+// countingWriter counts the bytes written to a ResponseWriter.
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.ResponseWriter.Write(b)
+	cw.n += int64(n)
+	return n, err
+}
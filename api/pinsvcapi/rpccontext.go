@@ -0,0 +1,52 @@
+package pinsvcapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+)
+
+// requestIDHeader is the header from which the request ID propagated to
+// RPC calls is taken. A random one is used when not present.
+const requestIDHeader = "X-Request-Id"
+
+// contextHandler wraps a handler so that the context of the request, which
+// is used for RPC calls, carries the values selected in RPCContextValues.
+func (api *API) contextHandler(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h(w, r.WithContext(api.enrichContext(r)))
+	}
+}
+
+// enrichContext returns the context of the request with the values
+// selected in RPCContextValues.
+func (api *API) enrichContext(r *http.Request) context.Context {
+	ctx := r.Context()
+	for _, v := range api.config.RPCContextValues {
+		switch v {
+		case ContextValueRequestID:
+			ctx = types.ContextWithRequestID(ctx, requestID(r))
+		case ContextValueIdentity:
+			if token := requestToken(r); token != "" {
+				ctx = types.ContextWithIdentity(ctx, ownerID(token))
+			}
+		}
+	}
+	return ctx
+}
+
+// requestID returns the ID set by the client for the request, or a random
+// one.
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeader); id != "" {
+		return id
+	}
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf[:])
+}
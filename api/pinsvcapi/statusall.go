@@ -0,0 +1,62 @@
+package pinsvcapi
+
+import (
+	"context"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi/pinsvc"
+)
+
+// statusAllFilter returns the filter for Cluster.StatusAllFiltered
+// corresponding to the given list options. The upper bound is given by
+// before or by the cursor, whichever is earlier. It is not set when
+// AnchorCreated is enabled, as the creation times shown to the user may then
+// be earlier than those of the pins in the state, and only later ones are
+// safe to discard.
+func (api *API) statusAllFilter(opts *pinsvc.ListOptions, tst types.TrackerStatus) types.StatusAllFilter {
+	filter := types.StatusAllFilter{
+		Status:       tst,
+		CreatedAfter: opts.After,
+	}
+	if api.config.AnchorCreated {
+		return filter
+	}
+	filter.CreatedBefore = opts.Before
+	if opts.Cursor.Defined() && (filter.CreatedBefore.IsZero() || opts.Cursor.Created.Before(filter.CreatedBefore)) {
+		filter.CreatedBefore = opts.Cursor.Created
+	}
+	return filter
+}
+
+// streamStatusAll sends the GlobalPinInfo of all the pins matching the given
+// list options to the out channel, which is closed when done. Creation time
+// bounds are applied by Cluster before the items reach the API. Other
+// options must still be checked by the caller.
+func (api *API) streamStatusAll(ctx context.Context, opts *pinsvc.ListOptions, tst types.TrackerStatus, out chan<- types.GlobalPinInfo) error {
+	filter := api.statusAllFilter(opts, tst)
+	if !filter.HasCriteria() {
+		in := make(chan types.TrackerStatus, 1)
+		in <- tst
+		close(in)
+		return api.rpcClient.Stream(
+			ctx,
+			"",
+			"Cluster",
+			"StatusAll",
+			in,
+			out,
+		)
+	}
+
+	in := make(chan types.StatusAllFilter, 1)
+	in <- filter
+	close(in)
+	return api.rpcClient.Stream(
+		ctx,
+		"",
+		"Cluster",
+		"StatusAllFiltered",
+		in,
+		out,
+	)
+}
@@ -0,0 +1,81 @@
+package pinsvcapi
+
+import (
+	"fmt"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi/pinsvc"
+)
+
+// statusMapping holds overrides for the pinning service status that
+// corresponds to a tracker status. Tracker statuses not in the map use the
+// mapping in trackerStatusToSvcStatus.
+type statusMapping map[types.TrackerStatus]pinsvc.Status
+
+// parseStatusMapping parses a mapping from tracker status names to pinning
+// service status names, as given in the configuration.
+func parseStatusMapping(m map[string]string) (statusMapping, error) {
+	sm := make(statusMapping, len(m))
+	for k, v := range m {
+		tst := types.TrackerStatusFromString(k)
+		if tst == types.TrackerStatusUndefined || tst&(tst-1) != 0 || tst.String() != k {
+			return nil, fmt.Errorf("%s is not a single tracker status", k)
+		}
+		st := pinsvc.StatusFromString(v)
+		if st == pinsvc.StatusUndefined || st&(st-1) != 0 || st.String() != v {
+			return nil, fmt.Errorf("%s is not a single pin status", v)
+		}
+		sm[tst] = st
+	}
+	return sm, nil
+}
+
+// svcStatus returns the status reported for a pin given the status in each
+// of the peers.
+func (sm statusMapping) svcStatus(peerMap map[string]types.PinInfoShort) pinsvc.Status {
+	var statusMask types.TrackerStatus
+	var overridden pinsvc.Status
+	for _, pinfo := range peerMap {
+		if st, ok := sm[pinfo.Status]; ok {
+			overridden |= st
+			continue
+		}
+		statusMask |= pinfo.Status
+	}
+
+	switch {
+	case overridden == pinsvc.StatusUndefined:
+		return trackerStatusToSvcStatus(statusMask)
+	case statusMask != types.TrackerStatusUndefined:
+		overridden |= trackerStatusToSvcStatus(statusMask)
+	}
+
+	// Same precedence as trackerStatusToSvcStatus.
+	for _, st := range []pinsvc.Status{
+		pinsvc.StatusFailed,
+		pinsvc.StatusQueued,
+		pinsvc.StatusPinning,
+		pinsvc.StatusPinned,
+	} {
+		if overridden&st != 0 {
+			return st
+		}
+	}
+	return pinsvc.StatusUndefined
+}
+
+// trackerStatusFilter returns the tracker status filter that selects pins
+// in the given pinning service status, including the tracker statuses
+// overridden to it.
+func (sm statusMapping) trackerStatusFilter(st pinsvc.Status) types.TrackerStatus {
+	tst := svcStatusToTrackerStatus(st)
+	if tst == types.TrackerStatusUndefined {
+		return tst
+	}
+	for k, v := range sm {
+		if v&st != 0 {
+			tst |= k
+		}
+	}
+	return tst
+}
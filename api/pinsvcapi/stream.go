@@ -0,0 +1,78 @@
+package pinsvcapi
+
+import (
+	"net/http"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/api/common"
+	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi/pinsvc"
+	"go.uber.org/multierr"
+)
+
+// wantsStream returns true when the client asked for a streamed response.
+func wantsStream(r *http.Request) bool {
+	return common.WantsNDJSON(r)
+}
+
+// streamPins sends the status of every pin matching the list options as
+// newline-delimited JSON, as soon as it is received from the cluster,
+// instead of building a PinList. Results are not sorted, and neither the
+// limit nor the total count apply. Errors are sent in the X-Stream-Error
+// trailer, like in other streaming endpoints.
+func (api *API) streamPins(w http.ResponseWriter, r *http.Request, opts *pinsvc.ListOptions, tst types.TrackerStatus) {
+	owner := api.scopedOwner(r)
+	out := make(chan types.GlobalPinInfo, common.StreamChannelSize)
+	errCh := make(chan error, 1)
+
+	if len(opts.Cids) > 0 {
+		go func() {
+			defer close(errCh)
+			defer close(out)
+
+			ctx := r.Context()
+			var err error
+			for _, c := range opts.Cids {
+				gpi, statusErr := api.getGlobalPinInfo(ctx, c)
+				if statusErr != nil {
+					err = multierr.Append(err, statusErr)
+					continue
+				}
+				select {
+				case out <- gpi:
+				case <-ctx.Done():
+					errCh <- multierr.Append(err, ctx.Err())
+					return
+				}
+			}
+			errCh <- err
+		}()
+	} else {
+		go func() {
+			defer close(errCh)
+
+			errCh <- api.streamStatusAll(r.Context(), opts, tst, out)
+		}()
+	}
+
+	iter := func() (interface{}, bool, error) {
+		for gpi := range out {
+			if !api.ownedBy(gpi.Metadata, owner) {
+				continue
+			}
+			st := api.globalPinInfoToSvcPinStatus(gpi.Cid.String(), owner, gpi)
+			if st.Status == pinsvc.StatusUndefined {
+				// i.e things unpinning
+				continue
+			}
+			// As in listPins, the other filters do not apply
+			// when asking for specific cids.
+			if len(opts.Cids) == 0 && !opts.Matches(st) {
+				continue
+			}
+			st.Delegates = api.svcDelegates(st.Delegates)
+			return st, true, nil
+		}
+		return nil, false, nil
+	}
+	api.StreamNDJSONResponse(w, iter, errCh)
+}
@@ -0,0 +1,133 @@
+package pinsvcapi
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/api/common"
+	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi/pinsvc"
+	"github.com/ipfs-cluster/ipfs-cluster/observations"
+
+	"go.opencensus.io/stats"
+)
+
+// stuckQueued holds the results of the last check for pins that have been
+// queued for too long.
+type stuckQueued struct {
+	mu   sync.RWMutex
+	pins []types.GlobalPinInfo
+}
+
+func (sq *stuckQueued) set(pins []types.GlobalPinInfo) {
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+	sq.pins = pins
+}
+
+func (sq *stuckQueued) get() []types.GlobalPinInfo {
+	sq.mu.RLock()
+	defer sq.mu.RUnlock()
+	return sq.pins
+}
+
+// isStuckQueued returns true when any of the peers in the given
+// GlobalPinInfo has been in pin_queued status for longer than threshold.
+func isStuckQueued(gpi types.GlobalPinInfo, threshold time.Duration, now time.Time) bool {
+	for _, pi := range gpi.PeerMap {
+		if pi.Status == types.TrackerStatusPinQueued && now.Sub(pi.TS) > threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// runStuckQueuedDetector checks for stuck pins on every
+// StuckQueuedCheckInterval until the context is cancelled.
+func (api *API) runStuckQueuedDetector(ctx context.Context) {
+	defer api.wg.Done()
+
+	ticker := time.NewTicker(api.config.StuckQueuedCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			api.checkStuckQueued(ctx)
+		}
+	}
+}
+
+// checkStuckQueued lists all queued pins in the cluster, remembers which of
+// them are stuck and triggers a recover for them when configured.
+func (api *API) checkStuckQueued(ctx context.Context) {
+	in := make(chan types.TrackerStatus, 1)
+	in <- types.TrackerStatusPinQueued
+	close(in)
+	out := make(chan types.GlobalPinInfo, common.StreamChannelSize)
+
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		errCh <- api.rpcClient.Stream(
+			ctx,
+			"",
+			"Cluster",
+			"StatusAll",
+			in,
+			out,
+		)
+	}()
+
+	now := time.Now()
+	stuck := []types.GlobalPinInfo{}
+	for gpi := range out {
+		if isStuckQueued(gpi, api.config.StuckQueuedThreshold, now) {
+			stuck = append(stuck, gpi)
+		}
+	}
+	if err := <-errCh; err != nil {
+		api.config.Logger.Errorf("error checking for stuck queued pins: %s", err)
+		return
+	}
+
+	api.stuckQueued.set(stuck)
+	stats.Record(ctx, observations.PinsStuckQueued.M(int64(len(stuck))))
+
+	for _, gpi := range stuck {
+		api.config.Logger.Warnf("%s has been queued for longer than %s", gpi.Cid, api.config.StuckQueuedThreshold)
+		if !api.config.StuckQueuedAutoRecover {
+			continue
+		}
+		var recovered types.GlobalPinInfo
+		err := api.rpcClient.CallContext(
+			ctx,
+			"",
+			"Cluster",
+			"Recover",
+			gpi.Cid,
+			&recovered,
+		)
+		if err != nil {
+			api.config.Logger.Errorf("error recovering stuck queued pin %s: %s", gpi.Cid, err)
+		}
+	}
+}
+
+// stuckQueuedPins returns the pins found stuck in queued status during the
+// last check.
+func (api *API) stuckQueuedPins(w http.ResponseWriter, r *http.Request) {
+	stuck := api.stuckQueued.get()
+	pinList := pinsvc.PinList{
+		Results: []pinsvc.PinStatus{},
+	}
+	for _, gpi := range stuck {
+		pinList.Results = append(pinList.Results, api.globalPinInfoToSvcPinStatus(gpi.Cid.String(), "", gpi))
+	}
+	pinList.Count = uint64(len(pinList.Results))
+	api.SendResponse(w, common.SetStatusAutomatically, nil, pinList)
+}
@@ -0,0 +1,222 @@
+package pinsvcapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/api/common"
+	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi/pinsvc"
+	"github.com/ipfs-cluster/ipfs-cluster/state"
+)
+
+// ownerMetaKey is the pin metadata key which stored an identifier of the
+// credentials used to create a pin, when UniquePinsPerOwner or
+// TenantScoping are enabled. The owner of every request is kept in its
+// record now, as a pin may be requested by several owners, and this key is
+// only read for pins created before.
+const ownerMetaKey = "pinsvc_owner"
+
+// ownerID returns the identifier stored for the given token. Tokens are
+// hashed so that they are not exposed in the pin metadata.
+func ownerID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// requestOwner returns the owner of the requests made by the client making
+// the given request. That is the identity of the client when it is
+// authenticated, which the cluster also records as the Owner of the pins it
+// creates, or an identifier of the credentials used otherwise.
+func requestOwner(r *http.Request) string {
+	if identity, ok := common.RequestIdentity(r); ok {
+		return identity
+	}
+	return ownerID(requestToken(r))
+}
+
+// scopedOwner returns the owner whose requests are visible to the client
+// making the given request, or an empty string when every request is
+// visible.
+func (api *API) scopedOwner(r *http.Request) string {
+	if !api.config.TenantScoping {
+		return ""
+	}
+	return requestOwner(r)
+}
+
+// isDuplicate returns true when the existing pin has the same cid as the
+// requested one and was requested with the same name by the same owner.
+func isDuplicate(existing types.Pin, pin pinsvc.Pin, owner string) bool {
+	if !existing.Defined() || !existing.Cid.Equals(pin.Cid) {
+		return false
+	}
+	for _, key := range requestIDs(existing.Metadata) {
+		rec, ok := recordFromMeta(existing.Metadata, key)
+		if ok && rec.Owner == owner && rec.Name == string(pin.Name) {
+			return true
+		}
+	}
+	return existing.Name == string(pin.Name) &&
+		existing.Metadata[ownerMetaKey] == owner
+}
+
+// existingPinStatus returns the status of the existing pin when it is for
+// the same cid, name and owner as the given one.
+func (api *API) existingPinStatus(ctx context.Context, existing types.Pin, pin pinsvc.Pin, owner string) (pinsvc.PinStatus, bool) {
+	if !isDuplicate(existing, pin, owner) {
+		return pinsvc.PinStatus{}, false
+	}
+
+	st, err := api.getPinSvcStatusFrom(ctx, pin.Cid, pin.Cid.String(), owner, api.getGlobalPinInfo)
+	if err != nil {
+		return pinsvc.PinStatus{}, false
+	}
+	return st, true
+}
+
+// ownedBy returns true when the given owner made any of the requests for
+// the pin with the given metadata, or when TenantScoping is disabled.
+func (api *API) ownedBy(metadata map[string]string, owner string) bool {
+	return !api.config.TenantScoping || ownsRequest(metadata, owner)
+}
+
+// errNotOwned is returned with TenantScoping when the given owner made
+// none of the requests for a pin, or not the one with the given requestID.
+var errNotOwned = errors.New("pin is not owned by the client")
+
+// checkOwner returns errNotOwned if the cid is pinned and the given owner
+// made none of the requests for it, or the request with the given
+// requestID was made by a different owner.
+func (api *API) checkOwner(ctx context.Context, c types.Cid, rID string, owner string) error {
+	existing, err := api.existingPin(ctx, c)
+	if err != nil || !existing.Defined() {
+		return err
+	}
+	if !api.ownedBy(existing.Metadata, owner) {
+		return errNotOwned
+	}
+	if rec, ok := recordFromMeta(existing.Metadata, rID); ok && rec.Owner != owner {
+		return errNotOwned
+	}
+	return nil
+}
+
+// existingPin returns the pin for the given cid, or an undefined pin if
+// there is none.
+func (api *API) existingPin(ctx context.Context, c types.Cid) (types.Pin, error) {
+	var existing types.Pin
+	err := api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"PinGet",
+		c,
+		&existing,
+	)
+	if err != nil && err.Error() == state.ErrNotFound.Error() {
+		return types.Pin{}, nil
+	}
+	return existing, err
+}
+
+// ownedOrFail sends a not found response when TenantScoping is enabled and
+// the client making the request made none of the requests for the pin with
+// the given cid, or not the one with the requestID of the request. Pins
+// which do not exist are left for the handler to deal with.
+func (api *API) ownedOrFail(w http.ResponseWriter, r *http.Request, c types.Cid) bool {
+	if !api.config.TenantScoping {
+		return true
+	}
+	err := api.checkOwner(r.Context(), c, mux.Vars(r)["requestID"], requestOwner(r))
+	if err == errNotOwned {
+		api.SendResponse(w, http.StatusNotFound, errors.New("pin not found"), nil)
+		return false
+	}
+	if err != nil {
+		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+		return false
+	}
+	return true
+}
+
+// removableOrFail sends a forbidden response when EnforcePinOwnership is
+// enabled and the client making the request can neither modify the pin
+// with the given cid nor made the request with the given requestID for it,
+// which is the one to remove. Pins which do not exist are left for the
+// handler to deal with.
+func (api *API) removableOrFail(w http.ResponseWriter, r *http.Request, c types.Cid, rID string) bool {
+	identity, ok := api.OwnershipIdentity(r)
+	if !ok {
+		return true
+	}
+	existing, err := api.existingPin(r.Context(), c)
+	if err != nil {
+		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+		return false
+	}
+	if !existing.Defined() || api.removableBy(existing, rID, identity) {
+		return true
+	}
+	api.SendResponse(w, http.StatusForbidden, common.PinOwnershipError(identity, existing), nil)
+	return false
+}
+
+// removableBy returns true when the given identity can remove the request
+// with the given requestID for the given pin: when it can modify the pin,
+// or when it made that request, as only its own requests are removed then.
+func (api *API) removableBy(pin types.Pin, rID, identity string) bool {
+	if pin.ModifiableBy(identity) {
+		return true
+	}
+	if api.config.RequestIDMode != RequestIDModeCid && rID != pin.Cid.String() {
+		rec, ok := recordFromMeta(pin.Metadata, rID)
+		return ok && rec.Owner == identity
+	}
+	return api.config.TenantScoping && ownsRequest(pin.Metadata, identity)
+}
+
+// cidLocks provides a lock for every cid.
+type cidLocks struct {
+	mu    sync.Mutex
+	locks map[types.Cid]*cidLock
+}
+
+type cidLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newCidLocks() *cidLocks {
+	return &cidLocks{
+		locks: make(map[types.Cid]*cidLock),
+	}
+}
+
+// lock locks the given cid and returns a function to unlock it.
+func (cl *cidLocks) lock(c types.Cid) func() {
+	cl.mu.Lock()
+	l, ok := cl.locks[c]
+	if !ok {
+		l = &cidLock{}
+		cl.locks[c] = l
+	}
+	l.refs++
+	cl.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		cl.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(cl.locks, c)
+		}
+		cl.mu.Unlock()
+	}
+}
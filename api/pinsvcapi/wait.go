@@ -0,0 +1,75 @@
+package pinsvcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi/pinsvc"
+)
+
+// Bounds for the interval between the status checks made while waiting for
+// a pin. It starts small, so that fast pins return quickly, and doubles
+// after every check.
+const (
+	minPinWaitInterval = 100 * time.Millisecond
+	maxPinWaitInterval = 5 * time.Second
+)
+
+// parseWaitOrFail parses the "wait" and "timeout" query parameters of
+// POST /pins. It returns how long to wait for the pin to be pinned, which
+// is 0 when not waiting, and sends a 400 response when they are invalid.
+func (api *API) parseWaitOrFail(w http.ResponseWriter, r *http.Request) (time.Duration, bool) {
+	q := r.URL.Query()
+	switch q.Get("wait") {
+	case "":
+		return 0, true
+	case "pinned":
+	default:
+		api.SendResponse(w, http.StatusBadRequest, errors.New("wait should be \"pinned\""), nil)
+		return 0, false
+	}
+
+	timeout := api.config.MaxPinWaitTimeout
+	if v := q.Get("timeout"); v != "" {
+		t, err := time.ParseDuration(v)
+		if err != nil || t <= 0 {
+			api.SendResponse(w, http.StatusBadRequest, fmt.Errorf("error parsing 'timeout' query param: %s", v), nil)
+			return 0, false
+		}
+		if t < timeout {
+			timeout = t
+		}
+	}
+	return timeout, true
+}
+
+// waitPinned checks the status of the given cid until it is pinned or
+// failed, or until the timeout expires, and returns the last status seen.
+// The given status is returned if no other could be obtained.
+func (api *API) waitPinned(ctx context.Context, c types.Cid, status pinsvc.PinStatus, timeout time.Duration) pinsvc.PinStatus {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	interval := minPinWaitInterval
+	for status.Status != pinsvc.StatusPinned && status.Status != pinsvc.StatusFailed {
+		select {
+		case <-ctx.Done():
+			return status
+		case <-time.After(interval):
+		}
+
+		st, err := api.getPinSvcStatus(ctx, c)
+		if err == nil && st.Status != pinsvc.StatusUndefined {
+			status = st
+		}
+		interval *= 2
+		if interval > maxPinWaitInterval {
+			interval = maxPinWaitInterval
+		}
+	}
+	return status
+}
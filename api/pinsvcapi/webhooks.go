@@ -0,0 +1,24 @@
+package pinsvcapi
+
+import (
+	types "github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/api/common"
+	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi/pinsvc"
+)
+
+// webhookStatus returns the status of a pin reported to webhooks, as seen
+// by users of this API with the configured status mapping. Queued pins are
+// reported as pinning.
+func (api *API) webhookStatus(gpi types.GlobalPinInfo) string {
+	st := api.globalPinInfoToSvcPinStatus(gpi.Cid.String(), "", gpi)
+	switch st.Status {
+	case pinsvc.StatusQueued, pinsvc.StatusPinning:
+		return common.WebhookStatusPinning
+	case pinsvc.StatusPinned:
+		return common.WebhookStatusPinned
+	case pinsvc.StatusFailed:
+		return common.WebhookStatusFailed
+	default:
+		return ""
+	}
+}
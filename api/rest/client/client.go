@@ -56,16 +56,39 @@ type Client interface {
 	// PeerRm removes a current peer from the cluster
 	PeerRm(ctx context.Context, pid peer.ID) error
 
+	// TrustedPeers returns the peers trusted by the cluster peer.
+	TrustedPeers(ctx context.Context) ([]peer.ID, error)
+	// TrustPeer makes all cluster peers trust the given peer.
+	TrustPeer(ctx context.Context, pid peer.ID) error
+	// DistrustPeer makes all cluster peers stop trusting the given peer.
+	DistrustPeer(ctx context.Context, pid peer.ID) error
+
+	// OperationLog returns a page of the consensus operation log, from
+	// the most recent.
+	OperationLog(ctx context.Context, opts api.OpLogOptions) ([]api.ConsensusOp, error)
+
 	// Add imports files to the cluster from the given paths.
 	Add(ctx context.Context, paths []string, params api.AddParams, out chan<- api.AddedOutput) error
 	// AddMultiFile imports new files from a MultiFileReader.
 	AddMultiFile(ctx context.Context, multiFileR *files.MultiFileReader, params api.AddParams, out chan<- api.AddedOutput) error
+	// AddURL has the cluster peer fetch the content at the given HTTP(S)
+	// URL and add it in the background.
+	AddURL(ctx context.Context, u string, params api.AddParams) (api.AddJob, error)
+	// AddS3 has the cluster peer fetch the objects under a prefix of an
+	// S3 bucket and add them in the background.
+	AddS3(ctx context.Context, bucket, prefix string, params api.AddParams) (api.AddJob, error)
+	// AddJob returns the progress of an add running in the background
+	// in the cluster peer.
+	AddJob(ctx context.Context, id string) (api.AddJob, error)
 
 	// Pin tracks a Cid with the given replication factor and a name for
 	// human-friendliness.
 	Pin(ctx context.Context, ci api.Cid, opts api.PinOptions) (api.Pin, error)
 	// Unpin untracks a Cid from cluster.
 	Unpin(ctx context.Context, ci api.Cid) (api.Pin, error)
+	// UndoUnpin pins again a Cid unpinned during the unpin grace
+	// period of the cluster peer.
+	UndoUnpin(ctx context.Context, ci api.Cid) (api.Pin, error)
 
 	// PinPath resolves given path into a cid and performs the pin operation.
 	PinPath(ctx context.Context, path string, opts api.PinOptions) (api.Pin, error)
@@ -76,9 +99,25 @@ type Client interface {
 	// Allocations returns the consensus state listing all tracked items
 	// and the peers that should be pinning them.
 	Allocations(ctx context.Context, filter api.PinType, out chan<- api.Pin) error
+	// SearchPins returns the pins matching the given name and metadata.
+	SearchPins(ctx context.Context, search api.PinSearch, out chan<- api.Pin) error
 	// Allocation returns the current allocations for a given Cid.
 	Allocation(ctx context.Context, ci api.Cid) (api.Pin, error)
 
+	// Groups returns the pin groups in the cluster and the number of pins
+	// in each.
+	Groups(ctx context.Context) ([]api.PinGroup, error)
+	// GroupPins returns the pins in a group.
+	GroupPins(ctx context.Context, group string, out chan<- api.Pin) error
+	// UnpinGroup unpins all the pins in a group.
+	UnpinGroup(ctx context.Context, group string) ([]api.BatchResult, error)
+	// SetGroupReplication changes the replication factors of all the
+	// pins in a group.
+	SetGroupReplication(ctx context.Context, group string, rplMin, rplMax int) ([]api.BatchResult, error)
+	// Reallocate changes the replication factors of existing pins,
+	// given by their CIDs or by a search on their names and metadata.
+	Reallocate(ctx context.Context, re api.Reallocation) ([]api.BatchResult, error)
+
 	// Status returns the current ipfs state for a given Cid. If local is true,
 	// the information affects only the current peer, otherwise the information
 	// is fetched from all cluster peers.
@@ -92,10 +131,18 @@ type Client interface {
 	// state.  If local is true, the operation is limited to the current
 	// peer, otherwise it happens on every cluster peer.
 	Recover(ctx context.Context, ci api.Cid, local bool) (api.GlobalPinInfo, error)
+	// VerifyPin checks that the peers a pin is allocated to store the
+	// blocks of its DAG. When sample is not 0, only the given number of
+	// randomly chosen blocks is checked.
+	VerifyPin(ctx context.Context, ci api.Cid, sample int) (api.PinVerification, error)
 	// RecoverAll triggers Recover() operations on all tracked items. If
 	// local is true, the operation is limited to the current peer.
 	// Otherwise, it happens everywhere.
 	RecoverAll(ctx context.Context, local bool, out chan<- api.GlobalPinInfo) error
+	// RecoverPermanent retries the pins which failed too many times to
+	// be retried automatically (pin_error_permanent). If local is true,
+	// the operation is limited to the current peer.
+	RecoverPermanent(ctx context.Context, local bool, out chan<- api.GlobalPinInfo) error
 
 	// Alerts returns information health events in the cluster (expired
 	// metrics etc.).
@@ -122,6 +169,26 @@ type Client interface {
 	// returns collected CIDs. If local is true, it would garbage collect
 	// only on contacted peer, otherwise on all peers' IPFS daemons.
 	RepoGC(ctx context.Context, local bool) (api.GlobalRepoGC, error)
+	// GarbageCollect runs garbage collection on the IPFS daemons of all
+	// cluster peers, on at most the given number of peers at once.
+	GarbageCollect(ctx context.Context, concurrency int) (api.GlobalRepoGC, error)
+
+	// PinTrackerConcurrency returns the number of pin and unpin
+	// operations that the peers run in parallel. If local is true, only
+	// the contacted peer is queried.
+	PinTrackerConcurrency(ctx context.Context, local bool) ([]api.PinTrackerConcurrency, error)
+	// SetPinTrackerConcurrency changes the number of pin and unpin
+	// operations that the peers run in parallel, until they restart.
+	// Zero values are left unchanged. If local is true, only the
+	// contacted peer is changed.
+	SetPinTrackerConcurrency(ctx context.Context, conc api.PinTrackerConcurrency, local bool) ([]api.PinTrackerConcurrency, error)
+	// PinTrackerPause stops the peers from starting new pin and unpin
+	// operations until they are resumed or restart. If local is true,
+	// only the contacted peer is paused.
+	PinTrackerPause(ctx context.Context, local bool) ([]api.PinTrackerConcurrency, error)
+	// PinTrackerResume lets paused peers start operations again. If
+	// local is true, only the contacted peer is resumed.
+	PinTrackerResume(ctx context.Context, local bool) ([]api.PinTrackerConcurrency, error)
 }
 
 // Config allows to configure the parameters to connect
@@ -168,6 +235,10 @@ type Config struct {
 
 	// LogLevel defines the verbosity of the logging facility
 	LogLevel string
+
+	// OverrideOwnership asks the API to let admins unpin and modify
+	// pins owned by other identities, when ownership is enforced.
+	OverrideOwnership bool
 }
 
 // AsTemplateFor creates client configs from resolved multiaddresses
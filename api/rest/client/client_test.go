@@ -23,6 +23,8 @@ func testAPI(t *testing.T) *rest.API {
 	cfg := rest.NewConfig()
 	cfg.Default()
 	cfg.HTTPListenAddr = []ma.Multiaddr{apiMAddr}
+	// Content to add from URLs is served locally.
+	cfg.AddURLAllowPrivate = true
 	secret := make(pnet.PSK, 32)
 
 	h, err := libp2p.New(
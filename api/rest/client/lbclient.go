@@ -171,6 +171,51 @@ func (lc *loadBalancingClient) PeerRm(ctx context.Context, id peer.ID) error {
 	return lc.retry(0, call)
 }
 
+// TrustedPeers returns the peers trusted by the cluster peer.
+func (lc *loadBalancingClient) TrustedPeers(ctx context.Context) ([]peer.ID, error) {
+	var peers []peer.ID
+	call := func(c Client) error {
+		var err error
+		peers, err = c.TrustedPeers(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return peers, err
+}
+
+// TrustPeer makes all cluster peers trust the given peer.
+func (lc *loadBalancingClient) TrustPeer(ctx context.Context, pid peer.ID) error {
+	call := func(c Client) error {
+		return c.TrustPeer(ctx, pid)
+	}
+
+	return lc.retry(0, call)
+}
+
+// DistrustPeer makes all cluster peers stop trusting the given peer.
+func (lc *loadBalancingClient) DistrustPeer(ctx context.Context, pid peer.ID) error {
+	call := func(c Client) error {
+		return c.DistrustPeer(ctx, pid)
+	}
+
+	return lc.retry(0, call)
+}
+
+// OperationLog returns a page of the consensus operation log, from the most
+// recent.
+func (lc *loadBalancingClient) OperationLog(ctx context.Context, opts api.OpLogOptions) ([]api.ConsensusOp, error) {
+	var ops []api.ConsensusOp
+	call := func(c Client) error {
+		var err error
+		ops, err = c.OperationLog(ctx, opts)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return ops, err
+}
+
 // Pin tracks a Cid with the given replication factor and a name for
 // human-friendliness.
 func (lc *loadBalancingClient) Pin(ctx context.Context, ci api.Cid, opts api.PinOptions) (api.Pin, error) {
@@ -198,6 +243,20 @@ func (lc *loadBalancingClient) Unpin(ctx context.Context, ci api.Cid) (api.Pin,
 	return pin, err
 }
 
+// UndoUnpin pins again a Cid unpinned during the unpin grace period of the
+// cluster peer.
+func (lc *loadBalancingClient) UndoUnpin(ctx context.Context, ci api.Cid) (api.Pin, error) {
+	var pin api.Pin
+	call := func(c Client) error {
+		var err error
+		pin, err = c.UndoUnpin(ctx, ci)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pin, err
+}
+
 // PinPath allows to pin an element by the given IPFS path.
 func (lc *loadBalancingClient) PinPath(ctx context.Context, path string, opts api.PinOptions) (api.Pin, error) {
 	var pin api.Pin
@@ -266,6 +325,114 @@ func (lc *loadBalancingClient) Allocation(ctx context.Context, ci api.Cid) (api.
 	return pin, err
 }
 
+// Groups returns the pin groups in the cluster and the number of pins in
+// each.
+func (lc *loadBalancingClient) Groups(ctx context.Context) ([]api.PinGroup, error) {
+	var groups []api.PinGroup
+	call := func(c Client) error {
+		var err error
+		groups, err = c.Groups(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return groups, err
+}
+
+// SearchPins returns the pins matching the given name and metadata.
+func (lc *loadBalancingClient) SearchPins(ctx context.Context, search api.PinSearch, out chan<- api.Pin) error {
+	call := func(c Client) error {
+		done := make(chan struct{})
+		cout := make(chan api.Pin, cap(out))
+		go func() {
+			for o := range cout {
+				out <- o
+			}
+			done <- struct{}{}
+		}()
+
+		// this blocks until done
+		err := c.SearchPins(ctx, search, cout)
+		// wait for cout to be closed
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		return err
+	}
+
+	err := lc.retry(0, call)
+	close(out)
+	return err
+}
+
+// GroupPins returns the pins in a group.
+func (lc *loadBalancingClient) GroupPins(ctx context.Context, group string, out chan<- api.Pin) error {
+	call := func(c Client) error {
+		done := make(chan struct{})
+		cout := make(chan api.Pin, cap(out))
+		go func() {
+			for o := range cout {
+				out <- o
+			}
+			done <- struct{}{}
+		}()
+
+		// this blocks until done
+		err := c.GroupPins(ctx, group, cout)
+		// wait for cout to be closed
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		return err
+	}
+
+	err := lc.retry(0, call)
+	close(out)
+	return err
+}
+
+// UnpinGroup unpins all the pins in a group.
+func (lc *loadBalancingClient) UnpinGroup(ctx context.Context, group string) ([]api.BatchResult, error) {
+	var results []api.BatchResult
+	call := func(c Client) error {
+		var err error
+		results, err = c.UnpinGroup(ctx, group)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return results, err
+}
+
+// SetGroupReplication changes the replication factors of all the pins in a
+// group.
+func (lc *loadBalancingClient) SetGroupReplication(ctx context.Context, group string, rplMin, rplMax int) ([]api.BatchResult, error) {
+	var results []api.BatchResult
+	call := func(c Client) error {
+		var err error
+		results, err = c.SetGroupReplication(ctx, group, rplMin, rplMax)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return results, err
+}
+
+// Reallocate changes the replication factors of existing pins.
+func (lc *loadBalancingClient) Reallocate(ctx context.Context, re api.Reallocation) ([]api.BatchResult, error) {
+	var results []api.BatchResult
+	call := func(c Client) error {
+		var err error
+		results, err = c.Reallocate(ctx, re)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return results, err
+}
+
 // Status returns the current ipfs state for a given Cid. If local is true,
 // the information affects only the current peer, otherwise the information
 // is fetched from all cluster peers.
@@ -356,6 +523,21 @@ func (lc *loadBalancingClient) Recover(ctx context.Context, ci api.Cid, local bo
 	return pinInfo, err
 }
 
+// VerifyPin checks that the peers a pin is allocated to store the blocks of
+// its DAG. When sample is not 0, only the given number of randomly chosen
+// blocks is checked.
+func (lc *loadBalancingClient) VerifyPin(ctx context.Context, ci api.Cid, sample int) (api.PinVerification, error) {
+	var res api.PinVerification
+	call := func(c Client) error {
+		var err error
+		res, err = c.VerifyPin(ctx, ci, sample)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return res, err
+}
+
 // RecoverAll triggers Recover() operations on all tracked items. If local is
 // true, the operation is limited to the current peer. Otherwise, it happens
 // everywhere.
@@ -385,6 +567,35 @@ func (lc *loadBalancingClient) RecoverAll(ctx context.Context, local bool, out c
 	return err
 }
 
+// RecoverPermanent retries the pins which failed too many times to be
+// retried automatically. If local is true, the operation is limited to the
+// current peer. Otherwise, it happens everywhere.
+func (lc *loadBalancingClient) RecoverPermanent(ctx context.Context, local bool, out chan<- api.GlobalPinInfo) error {
+	call := func(c Client) error {
+		done := make(chan struct{})
+		cout := make(chan api.GlobalPinInfo, cap(out))
+		go func() {
+			for o := range cout {
+				out <- o
+			}
+			done <- struct{}{}
+		}()
+
+		// this blocks until done
+		err := c.RecoverPermanent(ctx, local, cout)
+		// wait for cout to be closed
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		return err
+	}
+
+	err := lc.retry(0, call)
+	close(out)
+	return err
+}
+
 // Alerts returns things that are wrong with cluster.
 func (lc *loadBalancingClient) Alerts(ctx context.Context) ([]api.Alert, error) {
 	var alerts []api.Alert
@@ -468,6 +679,84 @@ func (lc *loadBalancingClient) RepoGC(ctx context.Context, local bool) (api.Glob
 	return repoGC, err
 }
 
+// GarbageCollect runs garbage collection on the IPFS daemons of all cluster
+// peers, never on more than the given number of peers at once.
+func (lc *loadBalancingClient) GarbageCollect(ctx context.Context, concurrency int) (api.GlobalRepoGC, error) {
+	var repoGC api.GlobalRepoGC
+
+	call := func(c Client) error {
+		var err error
+		repoGC, err = c.GarbageCollect(ctx, concurrency)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return repoGC, err
+}
+
+// PinTrackerConcurrency returns the number of pin and unpin operations
+// that the peers run in parallel. If local is true, only the contacted peer
+// is queried.
+func (lc *loadBalancingClient) PinTrackerConcurrency(ctx context.Context, local bool) ([]api.PinTrackerConcurrency, error) {
+	var concs []api.PinTrackerConcurrency
+
+	call := func(c Client) error {
+		var err error
+		concs, err = c.PinTrackerConcurrency(ctx, local)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return concs, err
+}
+
+// SetPinTrackerConcurrency changes the number of pin and unpin operations
+// that the peers run in parallel, until they restart. Zero values are left
+// unchanged. If local is true, only the contacted peer is changed.
+func (lc *loadBalancingClient) SetPinTrackerConcurrency(ctx context.Context, conc api.PinTrackerConcurrency, local bool) ([]api.PinTrackerConcurrency, error) {
+	var concs []api.PinTrackerConcurrency
+
+	call := func(c Client) error {
+		var err error
+		concs, err = c.SetPinTrackerConcurrency(ctx, conc, local)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return concs, err
+}
+
+// PinTrackerPause stops the peers from starting new pin and unpin
+// operations until they are resumed or restart. If local is true, only the
+// contacted peer is paused.
+func (lc *loadBalancingClient) PinTrackerPause(ctx context.Context, local bool) ([]api.PinTrackerConcurrency, error) {
+	var concs []api.PinTrackerConcurrency
+
+	call := func(c Client) error {
+		var err error
+		concs, err = c.PinTrackerPause(ctx, local)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return concs, err
+}
+
+// PinTrackerResume lets paused peers start operations again. If local is
+// true, only the contacted peer is resumed.
+func (lc *loadBalancingClient) PinTrackerResume(ctx context.Context, local bool) ([]api.PinTrackerConcurrency, error) {
+	var concs []api.PinTrackerConcurrency
+
+	call := func(c Client) error {
+		var err error
+		concs, err = c.PinTrackerResume(ctx, local)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return concs, err
+}
+
 // Add imports files to the cluster from the given paths. A path can
 // either be a local filesystem location or an web url (http:// or https://).
 // In the latter case, the destination will be downloaded with a GET request.
@@ -538,6 +827,49 @@ func (lc *loadBalancingClient) AddMultiFile(
 	return err
 }
 
+// AddURL has the cluster peer fetch the content at the given HTTP(S) URL
+// and add it in the background.
+func (lc *loadBalancingClient) AddURL(ctx context.Context, u string, params api.AddParams) (api.AddJob, error) {
+	var job api.AddJob
+	call := func(c Client) error {
+		var err error
+		job, err = c.AddURL(ctx, u, params)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return job, err
+}
+
+// AddS3 has the cluster peer fetch the objects under a prefix of an S3
+// bucket and add them in the background.
+func (lc *loadBalancingClient) AddS3(ctx context.Context, bucket, prefix string, params api.AddParams) (api.AddJob, error) {
+	var job api.AddJob
+	call := func(c Client) error {
+		var err error
+		job, err = c.AddS3(ctx, bucket, prefix, params)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return job, err
+}
+
+// AddJob returns the progress of an add running in the background. Add
+// jobs are only known to the peer running them, so this only works when
+// requests keep going to the same peer.
+func (lc *loadBalancingClient) AddJob(ctx context.Context, id string) (api.AddJob, error) {
+	var job api.AddJob
+	call := func(c Client) error {
+		var err error
+		job, err = c.AddJob(ctx, id)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return job, err
+}
+
 // IPFS returns an instance of go-ipfs-api's Shell, pointing to the
 // configured ProxyAddr (or to the default Cluster's IPFS proxy port).
 // It re-uses this Client's HTTP client, thus will be constrained by
@@ -81,6 +81,43 @@ func (c *defaultClient) PeerRm(ctx context.Context, id peer.ID) error {
 	return c.do(ctx, "DELETE", fmt.Sprintf("/peers/%s", id.Pretty()), nil, nil, nil)
 }
 
+// TrustedPeers returns the peers trusted by the cluster peer.
+func (c *defaultClient) TrustedPeers(ctx context.Context) ([]peer.ID, error) {
+	ctx, span := trace.StartSpan(ctx, "client/TrustedPeers")
+	defer span.End()
+
+	var peers []peer.ID
+	err := c.do(ctx, "GET", "/trust", nil, nil, &peers)
+	return peers, err
+}
+
+// TrustPeer makes all cluster peers trust the given peer.
+func (c *defaultClient) TrustPeer(ctx context.Context, pid peer.ID) error {
+	ctx, span := trace.StartSpan(ctx, "client/TrustPeer")
+	defer span.End()
+
+	return c.do(ctx, "POST", fmt.Sprintf("/trust/%s", pid.Pretty()), nil, nil, nil)
+}
+
+// DistrustPeer makes all cluster peers stop trusting the given peer.
+func (c *defaultClient) DistrustPeer(ctx context.Context, pid peer.ID) error {
+	ctx, span := trace.StartSpan(ctx, "client/DistrustPeer")
+	defer span.End()
+
+	return c.do(ctx, "DELETE", fmt.Sprintf("/trust/%s", pid.Pretty()), nil, nil, nil)
+}
+
+// OperationLog returns a page of the consensus operation log, from the most
+// recent.
+func (c *defaultClient) OperationLog(ctx context.Context, opts api.OpLogOptions) ([]api.ConsensusOp, error) {
+	ctx, span := trace.StartSpan(ctx, "client/OperationLog")
+	defer span.End()
+
+	var ops []api.ConsensusOp
+	err := c.do(ctx, "GET", "/state/log?"+opts.ToQuery(), nil, nil, &ops)
+	return ops, err
+}
+
 // Pin tracks a Cid with the given replication factor and a name for
 // human-friendliness.
 func (c *defaultClient) Pin(ctx context.Context, ci api.Cid, opts api.PinOptions) (api.Pin, error) {
@@ -116,6 +153,16 @@ func (c *defaultClient) Unpin(ctx context.Context, ci api.Cid) (api.Pin, error)
 	return pin, err
 }
 
+// UndoUnpin pins again a Cid unpinned during the unpin grace period of the
+// cluster peer. Unpins can only be undone by the peer which handled them.
+func (c *defaultClient) UndoUnpin(ctx context.Context, ci api.Cid) (api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/UndoUnpin")
+	defer span.End()
+	var pin api.Pin
+	err := c.do(ctx, "POST", fmt.Sprintf("/pins/%s/undo", ci.String()), nil, nil, &pin)
+	return pin, err
+}
+
 // PinPath allows to pin an element by the given IPFS path.
 func (c *defaultClient) PinPath(ctx context.Context, path string, opts api.PinOptions) (api.Pin, error) {
 	ctx, span := trace.StartSpan(ctx, "client/PinPath")
@@ -219,6 +266,120 @@ func (c *defaultClient) Allocation(ctx context.Context, ci api.Cid) (api.Pin, er
 	return pin, err
 }
 
+// Groups returns the pin groups in the cluster and the number of pins in
+// each.
+func (c *defaultClient) Groups(ctx context.Context) ([]api.PinGroup, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Groups")
+	defer span.End()
+
+	var groups []api.PinGroup
+	err := c.do(ctx, "GET", "/groups", nil, nil, &groups)
+	return groups, err
+}
+
+// SearchPins returns the pins matching the given name and metadata.
+func (c *defaultClient) SearchPins(ctx context.Context, search api.PinSearch, out chan<- api.Pin) error {
+	defer close(out)
+
+	ctx, span := trace.StartSpan(ctx, "client/SearchPins")
+	defer span.End()
+
+	handler := func(dec *json.Decoder) error {
+		var obj api.Pin
+		err := dec.Decode(&obj)
+		if err != nil {
+			return err
+		}
+		out <- obj
+		return nil
+	}
+
+	return c.doStream(
+		ctx,
+		"GET",
+		"/pins/search?"+search.ToQuery(),
+		nil,
+		nil,
+		handler)
+}
+
+// GroupPins returns the pins in a group.
+func (c *defaultClient) GroupPins(ctx context.Context, group string, out chan<- api.Pin) error {
+	defer close(out)
+
+	ctx, span := trace.StartSpan(ctx, "client/GroupPins")
+	defer span.End()
+
+	handler := func(dec *json.Decoder) error {
+		var obj api.Pin
+		err := dec.Decode(&obj)
+		if err != nil {
+			return err
+		}
+		out <- obj
+		return nil
+	}
+
+	return c.doStream(
+		ctx,
+		"GET",
+		fmt.Sprintf("/groups/%s/pins", url.PathEscape(group)),
+		nil,
+		nil,
+		handler)
+}
+
+// UnpinGroup unpins all the pins in a group.
+func (c *defaultClient) UnpinGroup(ctx context.Context, group string) ([]api.BatchResult, error) {
+	ctx, span := trace.StartSpan(ctx, "client/UnpinGroup")
+	defer span.End()
+
+	var results []api.BatchResult
+	err := c.do(ctx, "DELETE", fmt.Sprintf("/groups/%s", url.PathEscape(group)), nil, nil, &results)
+	return results, err
+}
+
+// SetGroupReplication changes the replication factors of all the pins in a
+// group. Factors set to 0 take the cluster defaults.
+func (c *defaultClient) SetGroupReplication(ctx context.Context, group string, rplMin, rplMax int) ([]api.BatchResult, error) {
+	ctx, span := trace.StartSpan(ctx, "client/SetGroupReplication")
+	defer span.End()
+
+	var results []api.BatchResult
+	err := c.do(
+		ctx,
+		"POST",
+		fmt.Sprintf(
+			"/groups/%s/replication?replication-min=%d&replication-max=%d",
+			url.PathEscape(group),
+			rplMin,
+			rplMax,
+		),
+		nil,
+		nil,
+		&results,
+	)
+	return results, err
+}
+
+// Reallocate changes the replication factors of existing pins, given by
+// their CIDs or by a search on their names and metadata. Factors set to 0
+// take the cluster defaults.
+func (c *defaultClient) Reallocate(ctx context.Context, re api.Reallocation) ([]api.BatchResult, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Reallocate")
+	defer span.End()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if err := enc.Encode(re); err != nil {
+		return nil, err
+	}
+
+	var results []api.BatchResult
+	err := c.do(ctx, "POST", "/pins/reallocate", nil, &buf, &results)
+	return results, err
+}
+
 // Status returns the current ipfs state for a given Cid. If local is true,
 // the information affects only the current peer, otherwise the information
 // is fetched from all cluster peers.
@@ -305,6 +466,18 @@ func (c *defaultClient) Recover(ctx context.Context, ci api.Cid, local bool) (ap
 	return gpi, err
 }
 
+// VerifyPin checks that the peers a pin is allocated to store the blocks of
+// its DAG. When sample is not 0, only the given number of randomly chosen
+// blocks is checked.
+func (c *defaultClient) VerifyPin(ctx context.Context, ci api.Cid, sample int) (api.PinVerification, error) {
+	ctx, span := trace.StartSpan(ctx, "client/VerifyPin")
+	defer span.End()
+
+	var res api.PinVerification
+	err := c.do(ctx, "GET", fmt.Sprintf("/pins/%s/verify?sample=%d", ci.String(), sample), nil, nil, &res)
+	return res, err
+}
+
 // RecoverAll triggers Recover() operations on all tracked items. If local is
 // true, the operation is limited to the current peer. Otherwise, it happens
 // everywhere.
@@ -333,6 +506,34 @@ func (c *defaultClient) RecoverAll(ctx context.Context, local bool, out chan<- a
 		handler)
 }
 
+// RecoverPermanent retries the pins which failed too many times to be
+// retried automatically. If local is true, the operation is limited to the
+// current peer. Otherwise, it happens everywhere.
+func (c *defaultClient) RecoverPermanent(ctx context.Context, local bool, out chan<- api.GlobalPinInfo) error {
+	defer close(out)
+
+	ctx, span := trace.StartSpan(ctx, "client/RecoverPermanent")
+	defer span.End()
+
+	handler := func(dec *json.Decoder) error {
+		var obj api.GlobalPinInfo
+		err := dec.Decode(&obj)
+		if err != nil {
+			return err
+		}
+		out <- obj
+		return nil
+	}
+
+	return c.doStream(
+		ctx,
+		"POST",
+		fmt.Sprintf("/pins/recover/permanent?local=%t", local),
+		nil,
+		nil,
+		handler)
+}
+
 // Alerts returns information health events in the cluster (expired metrics
 // etc.).
 func (c *defaultClient) Alerts(ctx context.Context) ([]api.Alert, error) {
@@ -409,6 +610,107 @@ func (c *defaultClient) RepoGC(ctx context.Context, local bool) (api.GlobalRepoG
 	return repoGC, err
 }
 
+// GarbageCollect runs garbage collection on the IPFS daemons of all cluster
+// peers, never on more than the given number of peers at once. The space
+// reclaimed by every peer is reported.
+func (c *defaultClient) GarbageCollect(ctx context.Context, concurrency int) (api.GlobalRepoGC, error) {
+	ctx, span := trace.StartSpan(ctx, "client/GarbageCollect")
+	defer span.End()
+
+	var repoGC api.GlobalRepoGC
+	err := c.do(
+		ctx,
+		"POST",
+		fmt.Sprintf("/ipfs/gc?concurrency=%d", concurrency),
+		nil,
+		nil,
+		&repoGC,
+	)
+
+	return repoGC, err
+}
+
+// PinTrackerConcurrency returns the number of pin and unpin operations
+// that the peers run in parallel. If local is true, only the contacted peer
+// is queried.
+func (c *defaultClient) PinTrackerConcurrency(ctx context.Context, local bool) ([]api.PinTrackerConcurrency, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinTrackerConcurrency")
+	defer span.End()
+
+	var concs []api.PinTrackerConcurrency
+	err := c.do(
+		ctx,
+		"GET",
+		fmt.Sprintf("/pintracker/concurrency?local=%t", local),
+		nil,
+		nil,
+		&concs,
+	)
+	return concs, err
+}
+
+// SetPinTrackerConcurrency changes the number of pin and unpin operations
+// that the peers run in parallel, until they restart. Zero values are left
+// unchanged. If local is true, only the contacted peer is changed.
+func (c *defaultClient) SetPinTrackerConcurrency(ctx context.Context, conc api.PinTrackerConcurrency, local bool) ([]api.PinTrackerConcurrency, error) {
+	ctx, span := trace.StartSpan(ctx, "client/SetPinTrackerConcurrency")
+	defer span.End()
+
+	var concs []api.PinTrackerConcurrency
+	err := c.do(
+		ctx,
+		"POST",
+		fmt.Sprintf(
+			"/pintracker/concurrency?pins=%d&unpins=%d&auto=%t&local=%t",
+			conc.Pins,
+			conc.Unpins,
+			conc.Auto,
+			local,
+		),
+		nil,
+		nil,
+		&concs,
+	)
+	return concs, err
+}
+
+// PinTrackerPause stops the peers from starting new pin and unpin
+// operations until they are resumed or restart. If local is true, only the
+// contacted peer is paused.
+func (c *defaultClient) PinTrackerPause(ctx context.Context, local bool) ([]api.PinTrackerConcurrency, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinTrackerPause")
+	defer span.End()
+
+	var concs []api.PinTrackerConcurrency
+	err := c.do(
+		ctx,
+		"POST",
+		fmt.Sprintf("/pintracker/pause?local=%t", local),
+		nil,
+		nil,
+		&concs,
+	)
+	return concs, err
+}
+
+// PinTrackerResume lets paused peers start operations again. If local is
+// true, only the contacted peer is resumed.
+func (c *defaultClient) PinTrackerResume(ctx context.Context, local bool) ([]api.PinTrackerConcurrency, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinTrackerResume")
+	defer span.End()
+
+	var concs []api.PinTrackerConcurrency
+	err := c.do(
+		ctx,
+		"POST",
+		fmt.Sprintf("/pintracker/resume?local=%t", local),
+		nil,
+		nil,
+		&concs,
+	)
+	return concs, err
+}
+
 // WaitFor is a utility function that allows for a caller to wait until a CID
 // status target is reached (as given in StatusFilterParams).
 // It returns the final status for that CID and an error, if there was one.
@@ -537,6 +839,7 @@ func statusReached(target api.TrackerStatus, gblPinInfo api.GlobalPinInfo, limit
 		case api.TrackerStatusUndefined,
 			api.TrackerStatusClusterError,
 			api.TrackerStatusPinError,
+			api.TrackerStatusPinErrorPermanent,
 			api.TrackerStatusUnpinError:
 			return false, fmt.Errorf("error has occurred while attempting to reach status: %s", target.String())
 		}
@@ -697,3 +1000,47 @@ func (c *defaultClient) AddMultiFile(
 	)
 	return err
 }
+
+// AddURL has the cluster peer fetch the content at the given HTTP(S) URL
+// and add it in the background, so that it does not go through the client.
+// It returns the add job, whose progress can be followed with AddJob.
+func (c *defaultClient) AddURL(ctx context.Context, u string, params api.AddParams) (api.AddJob, error) {
+	ctx, span := trace.StartSpan(ctx, "client/AddURL")
+	defer span.End()
+
+	var job api.AddJob
+	queryStr, err := params.ToQueryString()
+	if err != nil {
+		return job, err
+	}
+	err = c.do(ctx, "POST", "/add/url?"+queryStr+"&url="+url.QueryEscape(u), nil, nil, &job)
+	return job, err
+}
+
+// AddS3 has the cluster peer fetch the objects whose keys start with prefix
+// from a bucket of the S3-compatible storage configured in its REST API, and
+// add them in the background, wrapped in a directory. The returned AddJob
+// can be followed with AddJob().
+func (c *defaultClient) AddS3(ctx context.Context, bucket, prefix string, params api.AddParams) (api.AddJob, error) {
+	ctx, span := trace.StartSpan(ctx, "client/AddS3")
+	defer span.End()
+
+	var job api.AddJob
+	queryStr, err := params.ToQueryString()
+	if err != nil {
+		return job, err
+	}
+	err = c.do(ctx, "POST", "/add/s3?"+queryStr+"&bucket="+url.QueryEscape(bucket)+"&prefix="+url.QueryEscape(prefix), nil, nil, &job)
+	return job, err
+}
+
+// AddJob returns the progress of an add running in the background in the
+// cluster peer. Add jobs are only known to the peer running them.
+func (c *defaultClient) AddJob(ctx context.Context, id string) (api.AddJob, error) {
+	ctx, span := trace.StartSpan(ctx, "client/AddJob")
+	defer span.End()
+
+	var job api.AddJob
+	err := c.do(ctx, "GET", "/add/jobs/"+id, nil, nil, &job)
+	return job, err
+}
@@ -3,6 +3,8 @@ package client
 import (
 	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"sync"
 	"testing"
 	"time"
@@ -138,6 +140,51 @@ func TestPeerRm(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestTrust(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		peers, err := c.TrustedPeers(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(peers) != 2 || peers[1] != test.PeerID2 {
+			t.Errorf("unexpected trusted peers: %s", peers)
+		}
+
+		err = c.TrustPeer(ctx, test.PeerID3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.DistrustPeer(ctx, test.PeerID3)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestOperationLog(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		ops, err := c.OperationLog(ctx, types.OpLogOptions{Offset: 1, Limit: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ops) != 2 || ops[0].ID != "2" || !ops[0].Unpins[0].Equals(test.Cid1) {
+			t.Errorf("unexpected operation log: %+v", ops)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 func TestPin(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
@@ -173,6 +220,24 @@ func TestUnpin(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestUndoUnpin(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pin, err := c.UndoUnpin(ctx, test.Cid1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pin.Cid.Equals(test.Cid1) {
+			t.Error("expected the restored pin:", pin)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 type pathCase struct {
 	path        string
 	wantErr     bool
@@ -323,6 +388,115 @@ func TestAllocation(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestReallocate(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		re := types.Reallocation{
+			Cids:                 []types.Cid{test.Cid1},
+			ReplicationFactorMin: 1,
+			ReplicationFactorMax: 1,
+		}
+		results, err := c.Reallocate(ctx, re)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || !results[0].Cid.Equals(test.Cid1) || results[0].Pin.ReplicationFactorMax != 1 {
+			t.Errorf("unexpected results: %+v", results)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestSearchPins(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pins := make(chan types.Pin)
+		var got []types.Pin
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range pins {
+				got = append(got, p)
+			}
+		}()
+		search := types.PinSearch{
+			Name:     "foo",
+			Metadata: map[string]string{"hello": "world"},
+		}
+		err := c.SearchPins(ctx, search, pins)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		if len(got) != 1 || !got[0].Cid.Equals(test.Cid1) {
+			t.Errorf("unexpected search results: %+v", got)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestGroups(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		groups, err := c.Groups(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(groups) != 1 || groups[0].Name != test.Group1 || groups[0].Pins != 2 {
+			t.Errorf("unexpected groups: %+v", groups)
+		}
+
+		pins := make(chan types.Pin)
+		var got []types.Pin
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range pins {
+				got = append(got, p)
+			}
+		}()
+		err = c.GroupPins(ctx, test.Group1, pins)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		if len(got) != 2 || got[0].Group != test.Group1 {
+			t.Errorf("unexpected group pins: %+v", got)
+		}
+
+		results, err := c.SetGroupReplication(ctx, test.Group1, 1, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[0].Pin.ReplicationFactorMax != 2 {
+			t.Errorf("unexpected results: %+v", results)
+		}
+
+		results, err = c.UnpinGroup(ctx, test.Group1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[0].Action != types.BatchActionUnpin {
+			t.Errorf("unexpected results: %+v", results)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 func TestStatus(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
@@ -475,6 +649,27 @@ func TestRecover(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestVerifyPin(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		res, err := c.VerifyPin(ctx, test.Cid2, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Cid.Equals(test.Cid2) || res.Checked != 1 {
+			t.Errorf("unexpected verification: %+v", res)
+		}
+		if res.OK() {
+			t.Error("expected blocks to be missing")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 func TestRecoverAll(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
@@ -497,6 +692,25 @@ func TestRecoverAll(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestRecoverPermanent(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		out := make(chan types.GlobalPinInfo, 10)
+		err := c.RecoverPermanent(ctx, false, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 1 {
+			t.Fatal("expected one retried pin")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 func TestAlerts(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
@@ -869,6 +1083,66 @@ func TestAddMultiFile(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestAddURL(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("remote content"))
+	}))
+	defer srv.Close()
+
+	testF := func(t *testing.T, c Client) {
+		p := types.DefaultAddParams()
+		p.ReplicationFactorMin = -1
+		p.ReplicationFactorMax = -1
+		job, err := c.AddURL(ctx, srv.URL+"/file?a=b&c=d", p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; job.Status == types.AddJobRunning; i++ {
+			if i > 50 {
+				t.Fatal("add job did not finish")
+			}
+			time.Sleep(100 * time.Millisecond)
+			job, err = c.AddJob(ctx, job.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if job.Status != types.AddJobDone || !job.Cid.Defined() {
+			t.Fatal("add job failed:", job.Error)
+		}
+
+		_, err = c.AddJob(ctx, "abc")
+		if err == nil {
+			t.Error("expected an error for unknown jobs")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestAddS3(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		p := types.DefaultAddParams()
+		_, err := c.AddS3(ctx, "bucket", "some prefix/", p)
+		if err == nil {
+			t.Fatal("expected an error when S3 is not configured")
+		}
+		if apiErr, ok := err.(types.Error); !ok || apiErr.Code != http.StatusNotImplemented {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 func TestRepoGC(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
@@ -880,6 +1154,14 @@ func TestRepoGC(t *testing.T) {
 			t.Fatal(err)
 		}
 
+		rollingGC, err := c.GarbageCollect(ctx, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rollingGC.PeerMap) != len(globalGC.PeerMap) {
+			t.Error("expected the same peers in both garbage collections")
+		}
+
 		if globalGC.PeerMap == nil {
 			t.Fatal("expected a non-nil peer map")
 		}
@@ -903,3 +1185,55 @@ func TestRepoGC(t *testing.T) {
 
 	testClients(t, api, testF)
 }
+
+func TestPinTrackerConcurrency(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		concs, err := c.PinTrackerConcurrency(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(concs) != 1 || concs[0].Peer != test.PeerID1 || concs[0].Pins != 10 {
+			t.Errorf("unexpected concurrency: %+v", concs)
+		}
+
+		concs, err = c.SetPinTrackerConcurrency(ctx, types.PinTrackerConcurrency{Unpins: 2}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(concs) != 1 || concs[0].Pins != 10 || concs[0].Unpins != 2 || concs[0].Auto {
+			t.Errorf("unexpected concurrency after setting it: %+v", concs)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPinTrackerPause(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		concs, err := c.PinTrackerPause(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(concs) != 1 || concs[0].Peer != test.PeerID1 || !concs[0].Paused {
+			t.Errorf("expected a paused pintracker: %+v", concs)
+		}
+
+		concs, err = c.PinTrackerResume(ctx, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(concs) != 1 || concs[0].Paused {
+			t.Errorf("expected a resumed pintracker: %+v", concs)
+		}
+	}
+
+	testClients(t, api, testF)
+}
@@ -60,6 +60,13 @@ func (c *defaultClient) doRequest(
 	defer span.End()
 
 	urlpath := c.net + "://" + c.hostname + "/" + strings.TrimPrefix(path, "/")
+	if c.config.OverrideOwnership {
+		sep := "?"
+		if strings.Contains(urlpath, "?") {
+			sep = "&"
+		}
+		urlpath += sep + "override=true"
+	}
 	logger.Debugf("%s: %s", method, urlpath)
 
 	r, err := http.NewRequestWithContext(ctx, method, urlpath, body)
@@ -96,7 +103,7 @@ func (c *defaultClient) handleResponse(resp *http.Response, obj interface{}) err
 	logger.Debugf("Response body: %s", body)
 
 	switch {
-	case resp.StatusCode == http.StatusAccepted:
+	case resp.StatusCode == http.StatusAccepted && len(body) == 0:
 		logger.Debug("Request accepted")
 	case resp.StatusCode == http.StatusNoContent:
 		logger.Debug("Request succeeded. Response has no content")
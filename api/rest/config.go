@@ -1,13 +1,19 @@
 package rest
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 
+	"github.com/kelseyhightower/envconfig"
 	ma "github.com/multiformats/go-multiaddr"
 
 	"github.com/ipfs-cluster/ipfs-cluster/api"
 	"github.com/ipfs-cluster/ipfs-cluster/api/common"
+	"github.com/ipfs-cluster/ipfs-cluster/config"
 )
 
 const configKey = "restapi"
@@ -17,11 +23,15 @@ const minMaxHeaderBytes = 4096
 
 // Default values for Config.
 const (
-	DefaultReadTimeout       = 0
-	DefaultReadHeaderTimeout = 5 * time.Second
-	DefaultWriteTimeout      = 0
-	DefaultIdleTimeout       = 120 * time.Second
-	DefaultMaxHeaderBytes    = minMaxHeaderBytes
+	DefaultReadTimeout        = 0
+	DefaultReadHeaderTimeout  = 5 * time.Second
+	DefaultWriteTimeout       = 0
+	DefaultIdleTimeout        = 120 * time.Second
+	DefaultMaxHeaderBytes     = minMaxHeaderBytes
+	DefaultWebhookMaxRetries  = 3
+	DefaultIdempotencyWindow  = 10 * time.Minute
+	DefaultCompressionMinSize = 1024
+	DefaultMaxAsyncAddSize    = 10 << 30 // 10GiB
 )
 
 // Default values for Config.
@@ -51,9 +61,41 @@ var (
 )
 
 // Config fully implements the config.ComponentConfig interface. Use
-// NewConfig() to instantiate. Config embeds a common.Config object.
+// NewConfig() to instantiate. Config embeds a common.Config object and adds
+// options specific to the REST API, which are stored in the same
+// configuration section.
 type Config struct {
 	common.Config
+
+	// MaxAsyncAddSize is the largest upload, in bytes, accepted by
+	// asynchronous adds, which are stored on disk until received. It
+	// also limits the size of the content fetched by adds from URLs.
+	MaxAsyncAddSize uint64
+
+	// AddURLAllowPrivate lets adds from URLs fetch content from
+	// loopback, private and link-local addresses. Otherwise, API clients
+	// could use the peer to read services which are only reachable by
+	// it.
+	AddURLAllowPrivate bool
+
+	// S3Endpoint is the http(s) URL of the S3-compatible object storage
+	// from which content can be added, authenticating with the
+	// S3AccessKeyID and S3SecretAccessKey when set. S3Region is used to
+	// sign the requests. The content fetched is limited by
+	// MaxAsyncAddSize too.
+	S3Endpoint        string
+	S3Region          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+}
+
+type jsonConfig struct {
+	MaxAsyncAddSize    uint64 `json:"max_async_add_size,omitempty"`
+	AddURLAllowPrivate bool   `json:"add_url_allow_private,omitempty"`
+	S3Endpoint         string `json:"s3_endpoint,omitempty"`
+	S3Region           string `json:"s3_region,omitempty"`
+	S3AccessKeyID      string `json:"s3_access_key_id,omitempty"`
+	S3SecretAccessKey  string `json:"s3_secret_access_key,omitempty" hidden:"true"`
 }
 
 // NewConfig creates a Config object setting the necessary meta-fields in the
@@ -82,9 +124,118 @@ func (cfg *Config) ConfigKey() string {
 
 // Default initializes this Config with working values.
 func (cfg *Config) Default() error {
+	cfg.setDefaults()
 	return defaultFunc(&cfg.Config)
 }
 
+// Sets defaults for the restapi-specific options.
+func (cfg *Config) setDefaults() {
+	cfg.MaxAsyncAddSize = DefaultMaxAsyncAddSize
+	cfg.AddURLAllowPrivate = false
+	cfg.S3Endpoint = ""
+	cfg.S3Region = ""
+	cfg.S3AccessKeyID = ""
+	cfg.S3SecretAccessKey = ""
+}
+
+// ApplyEnvVars fills in any Config fields found as environment variables.
+func (cfg *Config) ApplyEnvVars() error {
+	err := cfg.Config.ApplyEnvVars()
+	if err != nil {
+		return err
+	}
+
+	jcfg := cfg.toJSONConfig()
+	err = envconfig.Process(envConfigKey, jcfg)
+	if err != nil {
+		return err
+	}
+	return cfg.applyJSONConfig(jcfg)
+}
+
+// Validate makes sure that all fields in this Config have working values,
+// at least in appearance.
+func (cfg *Config) Validate() error {
+	err := cfg.Config.Validate()
+	if err != nil {
+		return err
+	}
+
+	if cfg.MaxAsyncAddSize == 0 {
+		return errors.New(configKey + ".max_async_add_size is invalid")
+	}
+
+	if cfg.S3Endpoint != "" {
+		parsed, err := url.Parse(cfg.S3Endpoint)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return fmt.Errorf("%s.s3_endpoint: invalid URL %q", configKey, cfg.S3Endpoint)
+		}
+	}
+	if (cfg.S3AccessKeyID == "") != (cfg.S3SecretAccessKey == "") {
+		return fmt.Errorf("%s: s3_access_key_id and s3_secret_access_key must be set together", configKey)
+	}
+	return nil
+}
+
+// LoadJSON parses a raw JSON byte slice created by ToJSON() and sets the
+// configuration fields accordingly.
+func (cfg *Config) LoadJSON(raw []byte) error {
+	jcfg := &jsonConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		cfg.Logger.Error(configKey + ": error unmarshaling config")
+		return err
+	}
+
+	err = cfg.Config.LoadJSON(raw)
+	if err != nil {
+		return err
+	}
+
+	cfg.setDefaults()
+	return cfg.applyJSONConfig(jcfg)
+}
+
+func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
+	config.SetIfNotDefault(jcfg.MaxAsyncAddSize, &cfg.MaxAsyncAddSize)
+	cfg.AddURLAllowPrivate = jcfg.AddURLAllowPrivate
+	cfg.S3Endpoint = jcfg.S3Endpoint
+	cfg.S3Region = jcfg.S3Region
+	cfg.S3AccessKeyID = jcfg.S3AccessKeyID
+	cfg.S3SecretAccessKey = jcfg.S3SecretAccessKey
+	return cfg.Validate()
+}
+
+// ToJSON produce a human-friendly JSON representation of the Config
+// object.
+func (cfg *Config) ToJSON() ([]byte, error) {
+	raw, err := cfg.Config.ToJSON()
+	if err != nil {
+		return nil, err
+	}
+	return common.MergeJSON(raw, cfg.toJSONConfig(), config.DefaultJSONMarshal)
+}
+
+// ToDisplayJSON returns JSON config as a string.
+func (cfg *Config) ToDisplayJSON() ([]byte, error) {
+	raw, err := cfg.Config.ToDisplayJSON()
+	if err != nil {
+		return nil, err
+	}
+	return common.MergeJSON(raw, cfg.toJSONConfig(), config.DisplayJSON)
+}
+
+func (cfg *Config) toJSONConfig() *jsonConfig {
+	return &jsonConfig{
+		MaxAsyncAddSize:    cfg.MaxAsyncAddSize,
+		AddURLAllowPrivate: cfg.AddURLAllowPrivate,
+		S3Endpoint:         cfg.S3Endpoint,
+		S3Region:           cfg.S3Region,
+		S3AccessKeyID:      cfg.S3AccessKeyID,
+		S3SecretAccessKey:  cfg.S3SecretAccessKey,
+	}
+}
+
 // Sets all defaults for this config.
 func defaultFunc(cfg *common.Config) error {
 	// http
@@ -99,6 +250,8 @@ func defaultFunc(cfg *common.Config) error {
 	cfg.HTTPListenAddr = addrs
 	cfg.PathSSLCertFile = ""
 	cfg.PathSSLKeyFile = ""
+	cfg.PathClientCAFile = ""
+	cfg.ClientCertRequired = false
 	cfg.ReadTimeout = DefaultReadTimeout
 	cfg.ReadHeaderTimeout = DefaultReadHeaderTimeout
 	cfg.WriteTimeout = DefaultWriteTimeout
@@ -112,6 +265,16 @@ func defaultFunc(cfg *common.Config) error {
 
 	// Auth
 	cfg.BasicAuthCredentials = nil
+	cfg.BasicAuthScopes = nil
+	cfg.ClientCertScopes = nil
+	cfg.EnforcePinOwnership = false
+	cfg.JWTSecret = ""
+	cfg.PathJWTPublicKeyFile = ""
+	cfg.JWTPublicKey = nil
+	cfg.JWKSURL = ""
+	cfg.JWKSRefreshInterval = common.DefaultJWKSRefreshInterval
+	cfg.JWTIssuer = ""
+	cfg.JWTAudience = ""
 
 	// Logs
 	cfg.HTTPLogFile = ""
@@ -119,6 +282,23 @@ func defaultFunc(cfg *common.Config) error {
 	// Headers
 	cfg.Headers = DefaultHeaders
 
+	// Rate limiting (disabled)
+	cfg.RateLimit = 0
+	cfg.RateLimitBurst = 0
+	cfg.RateLimitScopes = nil
+
+	// Webhooks
+	cfg.Webhooks = nil
+	cfg.WebhookSecret = ""
+	cfg.WebhookMaxRetries = DefaultWebhookMaxRetries
+
+	// Idempotency keys
+	cfg.IdempotencyWindow = DefaultIdempotencyWindow
+
+	// Compression
+	cfg.Compression = true
+	cfg.CompressionMinSize = DefaultCompressionMinSize
+
 	cfg.CORSAllowedOrigins = DefaultCORSAllowedOrigins
 	cfg.CORSAllowedMethods = DefaultCORSAllowedMethods
 	cfg.CORSAllowedHeaders = DefaultCORSAllowedHeaders
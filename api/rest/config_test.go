@@ -0,0 +1,83 @@
+package rest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api/common"
+)
+
+func TestLoadJSONS3(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Default()
+	raw, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j := &jsonConfig{}
+	json.Unmarshal(raw, j)
+	j.S3Endpoint = "https://s3.example.org"
+	j.S3Region = "eu-west-1"
+	j.S3AccessKeyID = "key"
+	j.S3SecretAccessKey = "secret"
+	tst, err := common.MergeJSON(raw, j, json.Marshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = NewConfig()
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.S3Endpoint != "https://s3.example.org" ||
+		cfg.S3Region != "eu-west-1" ||
+		cfg.S3AccessKeyID != "key" ||
+		cfg.S3SecretAccessKey != "secret" {
+		t.Error("s3 options should be parsed")
+	}
+
+	j.S3Endpoint = "s3.example.org"
+	tst, _ = common.MergeJSON(raw, j, json.Marshal)
+	if err := NewConfig().LoadJSON(tst); err == nil {
+		t.Error("expected error with a bad S3 endpoint")
+	}
+
+	j.S3Endpoint = "https://s3.example.org"
+	j.S3SecretAccessKey = ""
+	tst, _ = common.MergeJSON(raw, j, json.Marshal)
+	if err := NewConfig().LoadJSON(tst); err == nil {
+		t.Error("expected error with an S3 access key without secret")
+	}
+}
+
+func TestLoadJSONCompression(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Default()
+	raw, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := make(map[string]json.RawMessage)
+	json.Unmarshal(raw, &fields)
+	delete(fields, "compression")
+	tst, _ := json.Marshal(fields)
+	cfg = NewConfig()
+	if err := cfg.LoadJSON(tst); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Compression {
+		t.Error("compression should keep its default when not set")
+	}
+
+	fields["compression"] = json.RawMessage("false")
+	tst, _ = json.Marshal(fields)
+	cfg = NewConfig()
+	if err := cfg.LoadJSON(tst); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Compression {
+		t.Error("compression should be disabled")
+	}
+}
@@ -11,15 +11,21 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/ipfs-cluster/ipfs-cluster/adder/adderutils"
+	"github.com/ipfs-cluster/ipfs-cluster/adder/s3"
 	types "github.com/ipfs-cluster/ipfs-cluster/api"
 	"github.com/ipfs-cluster/ipfs-cluster/api/common"
+	"github.com/ipfs-cluster/ipfs-cluster/state"
 
 	logging "github.com/ipfs/go-log/v2"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
@@ -38,6 +44,10 @@ var (
 	apiLogger = logging.Logger("restapilog")
 )
 
+// eventsKeepAliveInterval controls how often a comment is sent on idle
+// event streams.
+const eventsKeepAliveInterval = 30 * time.Second
+
 type peerAddBody struct {
 	PeerID string `json:"peer_id"`
 }
@@ -49,6 +59,9 @@ type API struct {
 
 	rpcClient *rpc.Client
 	config    *Config
+
+	addJobs    *adderutils.AddJobs
+	addUploads *adderutils.AddUploads
 }
 
 // NewAPI creates a new REST API component.
@@ -58,8 +71,11 @@ func NewAPI(ctx context.Context, cfg *Config) (*API, error) {
 
 // NewAPIWithHost creates a new REST API component using the given libp2p Host.
 func NewAPIWithHost(ctx context.Context, cfg *Config, h host.Host) (*API, error) {
+	addJobs := adderutils.NewAddJobs()
 	api := API{
-		config: cfg,
+		config:     cfg,
+		addJobs:    addJobs,
+		addUploads: adderutils.NewAddUploads(addJobs),
 	}
 	capi, err := common.NewAPIWithHost(ctx, &cfg.Config, h, api.routes)
 	api.API = capi
@@ -102,11 +118,105 @@ func (api *API) routes(c *rpc.Client) []common.Route {
 			Pattern:     "/peers/{peer}",
 			HandlerFunc: api.peerRemoveHandler,
 		},
+		{
+			Name:        "TrustedPeers",
+			Method:      "GET",
+			Pattern:     "/trust",
+			HandlerFunc: api.trustedPeersHandler,
+		},
+		{
+			Name:        "TrustPeer",
+			Method:      "POST",
+			Pattern:     "/trust/{peer}",
+			HandlerFunc: api.trustPeerHandler,
+		},
+		{
+			Name:        "DistrustPeer",
+			Method:      "DELETE",
+			Pattern:     "/trust/{peer}",
+			HandlerFunc: api.distrustPeerHandler,
+		},
 		{
 			Name:        "Add",
 			Method:      "POST",
 			Pattern:     "/add",
 			HandlerFunc: api.addHandler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "DagPut",
+			Method:      "POST",
+			Pattern:     "/dag/put",
+			HandlerFunc: api.dagPutHandler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "AddJobCreate",
+			Method:      "POST",
+			Pattern:     "/add/jobs",
+			HandlerFunc: api.addJobCreateHandler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "AddJob",
+			Method:      "GET",
+			Pattern:     "/add/jobs/{id}",
+			HandlerFunc: api.addJobHandler,
+		},
+		{
+			Name:        "AddJobUpload",
+			Method:      "PUT",
+			Pattern:     "/add/jobs/{id}",
+			HandlerFunc: api.addJobUploadHandler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "AddURL",
+			Method:      "POST",
+			Pattern:     "/add/url",
+			HandlerFunc: api.addURLHandler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "AddS3",
+			Method:      "POST",
+			Pattern:     "/add/s3",
+			HandlerFunc: api.addS3Handler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "AddUploadCreate",
+			Method:      "POST",
+			Pattern:     "/add/uploads",
+			HandlerFunc: api.addUploadCreateHandler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "AddUpload",
+			Method:      "GET",
+			Pattern:     "/add/uploads/{id}",
+			HandlerFunc: api.addUploadHandler,
+		},
+		{
+			Name:        "AddUploadWrite",
+			Method:      "PATCH",
+			Pattern:     "/add/uploads/{id}",
+			HandlerFunc: api.addUploadWriteHandler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "AddUploadComplete",
+			Method:      "POST",
+			Pattern:     "/add/uploads/{id}/complete",
+			HandlerFunc: api.addUploadCompleteHandler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "AddUploadDelete",
+			Method:      "DELETE",
+			Pattern:     "/add/uploads/{id}",
+			HandlerFunc: api.addUploadDeleteHandler,
+			Scopes:      []common.Scope{common.ScopePin},
 		},
 		{
 			Name:        "Allocations",
@@ -131,12 +241,54 @@ func (api *API) routes(c *rpc.Client) []common.Route {
 			Method:      "POST",
 			Pattern:     "/pins/{hash}/recover",
 			HandlerFunc: api.recoverHandler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "UndoUnpin",
+			Method:      "POST",
+			Pattern:     "/pins/{hash}/undo",
+			HandlerFunc: api.undoUnpinHandler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "VerifyPin",
+			Method:      "GET",
+			Pattern:     "/pins/{hash}/verify",
+			HandlerFunc: api.verifyPinHandler,
 		},
 		{
 			Name:        "RecoverAll",
 			Method:      "POST",
 			Pattern:     "/pins/recover",
 			HandlerFunc: api.recoverAllHandler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "RecoverPermanent",
+			Method:      "POST",
+			Pattern:     "/pins/recover/permanent",
+			HandlerFunc: api.recoverPermanentHandler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "PinBatch",
+			Method:      "POST",
+			Pattern:     "/pins/batch",
+			HandlerFunc: api.pinBatchHandler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "Reallocate",
+			Method:      "POST",
+			Pattern:     "/pins/reallocate",
+			HandlerFunc: api.reallocateHandler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "SearchPins",
+			Method:      "GET",
+			Pattern:     "/pins/search",
+			HandlerFunc: api.searchPinsHandler,
 		},
 		{
 			Name:        "Status",
@@ -149,24 +301,61 @@ func (api *API) routes(c *rpc.Client) []common.Route {
 			Method:      "POST",
 			Pattern:     "/pins/{hash}",
 			HandlerFunc: api.pinHandler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "PinEdit",
+			Method:      "PATCH",
+			Pattern:     "/pins/{hash}",
+			HandlerFunc: api.pinEditHandler,
+			Scopes:      []common.Scope{common.ScopePin},
 		},
 		{
 			Name:        "PinPath",
 			Method:      "POST",
 			Pattern:     "/pins/{keyType:ipfs|ipns|ipld}/{path:.*}",
 			HandlerFunc: api.pinPathHandler,
+			Scopes:      []common.Scope{common.ScopePin},
 		},
 		{
 			Name:        "Unpin",
 			Method:      "DELETE",
 			Pattern:     "/pins/{hash}",
 			HandlerFunc: api.unpinHandler,
+			Scopes:      []common.Scope{common.ScopeUnpin},
 		},
 		{
 			Name:        "UnpinPath",
 			Method:      "DELETE",
 			Pattern:     "/pins/{keyType:ipfs|ipns|ipld}/{path:.*}",
 			HandlerFunc: api.unpinPathHandler,
+			Scopes:      []common.Scope{common.ScopeUnpin},
+		},
+		{
+			Name:        "Groups",
+			Method:      "GET",
+			Pattern:     "/groups",
+			HandlerFunc: api.groupsHandler,
+		},
+		{
+			Name:        "GroupPins",
+			Method:      "GET",
+			Pattern:     "/groups/{group}/pins",
+			HandlerFunc: api.groupPinsHandler,
+		},
+		{
+			Name:        "GroupReplication",
+			Method:      "POST",
+			Pattern:     "/groups/{group}/replication",
+			HandlerFunc: api.groupReplicationHandler,
+			Scopes:      []common.Scope{common.ScopePin},
+		},
+		{
+			Name:        "UnpinGroup",
+			Method:      "DELETE",
+			Pattern:     "/groups/{group}",
+			HandlerFunc: api.unpinGroupHandler,
+			Scopes:      []common.Scope{common.ScopeUnpin},
 		},
 		{
 			Name:        "RepoGC",
@@ -174,6 +363,30 @@ func (api *API) routes(c *rpc.Client) []common.Route {
 			Pattern:     "/ipfs/gc",
 			HandlerFunc: api.repoGCHandler,
 		},
+		{
+			Name:        "PinTrackerConcurrency",
+			Method:      "GET",
+			Pattern:     "/pintracker/concurrency",
+			HandlerFunc: api.pinTrackerConcurrencyHandler,
+		},
+		{
+			Name:        "SetPinTrackerConcurrency",
+			Method:      "POST",
+			Pattern:     "/pintracker/concurrency",
+			HandlerFunc: api.setPinTrackerConcurrencyHandler,
+		},
+		{
+			Name:        "PinTrackerPause",
+			Method:      "POST",
+			Pattern:     "/pintracker/pause",
+			HandlerFunc: api.pinTrackerPauseHandler,
+		},
+		{
+			Name:        "PinTrackerResume",
+			Method:      "POST",
+			Pattern:     "/pintracker/resume",
+			HandlerFunc: api.pinTrackerResumeHandler,
+		},
 		{
 			Name:        "ConnectionGraph",
 			Method:      "GET",
@@ -186,6 +399,24 @@ func (api *API) routes(c *rpc.Client) []common.Route {
 			Pattern:     "/health/alerts",
 			HandlerFunc: api.alertsHandler,
 		},
+		{
+			Name:        "DeepHealth",
+			Method:      "GET",
+			Pattern:     "/health/deep",
+			HandlerFunc: api.deepHealthHandler,
+		},
+		{
+			Name:        "Events",
+			Method:      "GET",
+			Pattern:     "/events",
+			HandlerFunc: api.eventsHandler,
+		},
+		{
+			Name:        "OperationLog",
+			Method:      "GET",
+			Pattern:     "/state/log",
+			HandlerFunc: api.operationLogHandler,
+		},
 		{
 			Name:        "Metrics",
 			Method:      "GET",
@@ -203,6 +434,7 @@ func (api *API) routes(c *rpc.Client) []common.Route {
 			Method:      "POST",
 			Pattern:     "/token",
 			HandlerFunc: api.GenerateTokenHandler,
+			Scopes:      []common.Scope{common.ScopeRead},
 		},
 	}
 }
@@ -277,6 +509,25 @@ func (api *API) metricNamesHandler(w http.ResponseWriter, r *http.Request) {
 	api.SendResponse(w, common.SetStatusAutomatically, err, metricNames)
 }
 
+// deepHealthHandler checks the components of the peer and responds with
+// 503 Service Unavailable when any of them is not healthy.
+func (api *API) deepHealthHandler(w http.ResponseWriter, r *http.Request) {
+	var report types.HealthReport
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"HealthCheck",
+		struct{}{},
+		&report,
+	)
+	status := http.StatusOK
+	if !report.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+	api.SendResponse(w, status, err, report)
+}
+
 func (api *API) alertsHandler(w http.ResponseWriter, r *http.Request) {
 	var alerts []types.Alert
 	err := api.rpcClient.CallContext(
@@ -290,6 +541,17 @@ func (api *API) alertsHandler(w http.ResponseWriter, r *http.Request) {
 	api.SendResponse(w, common.SetStatusAutomatically, err, alerts)
 }
 
+// addParamsFromRequest parses the add parameters in the query of the
+// request. The content is owned by the client making the request.
+func addParamsFromRequest(r *http.Request) (types.AddParams, error) {
+	params, err := types.AddParamsFromQuery(r.URL.Query())
+	if err != nil {
+		return params, err
+	}
+	params.Owner, _ = common.RequestIdentity(r)
+	return params, nil
+}
+
 func (api *API) addHandler(w http.ResponseWriter, r *http.Request) {
 	reader, err := r.MultipartReader()
 	if err != nil {
@@ -297,12 +559,23 @@ func (api *API) addHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	params, err := types.AddParamsFromQuery(r.URL.Query())
+	params, err := addParamsFromRequest(r)
 	if err != nil {
 		api.SendResponse(w, http.StatusBadRequest, err, nil)
 		return
 	}
 
+	if params.Async {
+		_, mpParams, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			api.SendResponse(w, http.StatusBadRequest, err, nil)
+			return
+		}
+		job := api.addJobs.New(params)
+		api.startAddJob(w, r, job.ID, mpParams["boundary"])
+		return
+	}
+
 	api.SetHeaders(w)
 
 	// any errors sent as trailer
@@ -316,6 +589,258 @@ func (api *API) addHandler(w http.ResponseWriter, r *http.Request) {
 	)
 }
 
+// dagPutHandler adds IPLD nodes, like "ipfs dag put" does. Every file in
+// the multipart body is a node in the input-codec (dag-json by default),
+// which is stored as dag-cbor on the allocated peers and pinned. Otherwise,
+// it works like the add endpoint.
+func (api *API) dagPutHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if f := query.Get("format"); f != "" {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("use input-codec to give the format of the nodes"), nil)
+		return
+	}
+	codec := query.Get("input-codec")
+	switch codec {
+	case "":
+		codec = "dag-json"
+	case "dag-json", "dag-cbor":
+	default:
+		api.SendResponse(w, http.StatusBadRequest, errors.New("input-codec must be dag-json or dag-cbor"), nil)
+		return
+	}
+	query.Set("format", codec)
+	r.URL.RawQuery = query.Encode()
+	api.addHandler(w, r)
+}
+
+// addJobCreateHandler creates an add job with the parameters in the query,
+// which waits for its upload. Clients learn the ID of the job before
+// sending the upload to addJobUploadHandler.
+func (api *API) addJobCreateHandler(w http.ResponseWriter, r *http.Request) {
+	params, err := addParamsFromRequest(r)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	api.SendResponse(w, http.StatusCreated, nil, api.addJobs.New(params))
+}
+
+// addJobUploadHandler receives the multipart upload of an add job created
+// with addJobCreateHandler, which then runs in the background.
+func (api *API) addJobUploadHandler(w http.ResponseWriter, r *http.Request) {
+	_, mpParams, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	api.startAddJob(w, r, mux.Vars(r)["id"], mpParams["boundary"])
+}
+
+// startAddJob reads the upload of the add job with the given ID from the
+// request body and returns the job, which runs in the background.
+func (api *API) startAddJob(w http.ResponseWriter, r *http.Request, id, boundary string) {
+	// The add runs after the request is done, so it cannot use its
+	// context.
+	job, err := api.addJobs.Start(
+		api.Context(),
+		api.rpcClient,
+		id,
+		r.Body,
+		boundary,
+		api.config.MaxAsyncAddSize,
+	)
+	switch {
+	case errors.Is(err, adderutils.ErrAddJobNotFound):
+		api.SendResponse(w, http.StatusNotFound, err, nil)
+	case errors.Is(err, adderutils.ErrAddTooLarge):
+		api.SendResponse(w, http.StatusRequestEntityTooLarge, err, nil)
+	default:
+		api.SendResponse(w, http.StatusAccepted, err, job)
+	}
+}
+
+func (api *API) addJobHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	job, err := api.addJobs.Get(vars["id"])
+	if err == adderutils.ErrAddJobNotFound {
+		api.SendResponse(w, http.StatusNotFound, err, nil)
+		return
+	}
+	api.SendResponse(w, common.SetStatusAutomatically, err, job)
+}
+
+// addURLHandler fetches the content at the URL given in the "url" query
+// parameter and adds it in the background, returning the add job.
+func (api *API) addURLHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params, err := addParamsFromRequest(r)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	u, err := url.Parse(query.Get("url"))
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("error parsing url: "+err.Error()), nil)
+		return
+	}
+
+	// The add runs after the request is done, so it cannot use its
+	// context.
+	job, err := api.addJobs.StartURL(
+		api.Context(),
+		api.rpcClient,
+		params,
+		u,
+		api.config.MaxAsyncAddSize,
+		api.config.AddURLAllowPrivate,
+	)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	api.SendResponse(w, http.StatusAccepted, nil, job)
+}
+
+// addS3Handler adds the objects under a prefix of a bucket in the
+// configured S3 storage, in the background.
+func (api *API) addS3Handler(w http.ResponseWriter, r *http.Request) {
+	if api.config.S3Endpoint == "" {
+		api.SendResponse(w, http.StatusNotImplemented, errors.New("no S3 endpoint is configured"), nil)
+		return
+	}
+
+	query := r.URL.Query()
+	params, err := addParamsFromRequest(r)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	client, err := s3.New(
+		api.config.S3Endpoint,
+		api.config.S3Region,
+		api.config.S3AccessKeyID,
+		api.config.S3SecretAccessKey,
+	)
+	if err != nil {
+		api.SendResponse(w, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	// The add runs after the request is done, so it cannot use its
+	// context.
+	job, err := api.addJobs.StartS3(
+		api.Context(),
+		api.rpcClient,
+		params,
+		client,
+		query.Get("bucket"),
+		query.Get("prefix"),
+		api.config.MaxAsyncAddSize,
+	)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	api.SendResponse(w, http.StatusAccepted, nil, job)
+}
+
+// addUploadStatus returns the HTTP status for the errors of resumable
+// uploads.
+func addUploadStatus(err error) int {
+	switch {
+	case errors.Is(err, adderutils.ErrAddUploadNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, adderutils.ErrAddUploadBusy),
+		errors.Is(err, adderutils.ErrAddUploadOffset):
+		return http.StatusConflict
+	default:
+		return common.SetStatusAutomatically
+	}
+}
+
+// addUploadCreateHandler creates a resumable upload of the multipart body
+// whose boundary is given in the Content-Type, to be added with the
+// parameters in the query. The request body, if any, is the first part of
+// the upload.
+func (api *API) addUploadCreateHandler(w http.ResponseWriter, r *http.Request) {
+	params, err := addParamsFromRequest(r)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	_, mpParams, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	upload, err := api.addUploads.Create(params, mpParams["boundary"])
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	upload, err = api.addUploads.Write(upload.ID, 0, r.Body)
+	api.SendResponse(w, http.StatusCreated, err, upload)
+}
+
+func (api *API) addUploadHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	upload, err := api.addUploads.Get(vars["id"])
+	api.SendResponse(w, addUploadStatus(err), err, upload)
+}
+
+// addUploadWriteHandler appends the request body to an upload. The offset
+// query parameter must be the current size of the upload.
+func (api *API) addUploadWriteHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	offset, err := strconv.ParseUint(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("error parsing offset: "+err.Error()), nil)
+		return
+	}
+	upload, err := api.addUploads.Write(vars["id"], offset, r.Body)
+	api.SendResponse(w, addUploadStatus(err), err, upload)
+}
+
+// addUploadCompleteHandler adds a complete upload, either streaming the
+// output as /add does, or in the background when the upload was created
+// with async=true.
+func (api *API) addUploadCompleteHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	params, err := api.addUploads.Params(vars["id"])
+	if err != nil {
+		api.SendResponse(w, addUploadStatus(err), err, nil)
+		return
+	}
+
+	if params.Async {
+		job, err := api.addUploads.CompleteAsync(api.Context(), api.rpcClient, vars["id"])
+		if err != nil {
+			api.SendResponse(w, addUploadStatus(err), err, nil)
+			return
+		}
+		api.SendResponse(w, http.StatusAccepted, nil, job)
+		return
+	}
+
+	api.SetHeaders(w)
+
+	// any errors sent as trailer
+	_, err = api.addUploads.Complete(r.Context(), api.rpcClient, vars["id"], w, nil)
+	if errors.Is(err, adderutils.ErrAddUploadNotFound) || errors.Is(err, adderutils.ErrAddUploadBusy) {
+		api.SendResponse(w, addUploadStatus(err), err, nil)
+	}
+}
+
+func (api *API) addUploadDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	err := api.addUploads.Delete(vars["id"])
+	api.SendResponse(w, addUploadStatus(err), err, nil)
+}
+
 func (api *API) peerListHandler(w http.ResponseWriter, r *http.Request) {
 	in := make(chan struct{})
 	close(in)
@@ -358,100 +883,700 @@ func (api *API) peerAddHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var id types.ID
-	err = api.rpcClient.CallContext(
+	var id types.ID
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PeerAdd",
+		pid,
+		&id,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, &id)
+}
+
+func (api *API) peerRemoveHandler(w http.ResponseWriter, r *http.Request) {
+	if p := api.ParsePidOrFail(w, r); p != "" {
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"PeerRemove",
+			p,
+			&struct{}{},
+		)
+		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+	}
+}
+
+func (api *API) trustedPeersHandler(w http.ResponseWriter, r *http.Request) {
+	var peers []peer.ID
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"TrustedPeers",
+		struct{}{},
+		&peers,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, peers)
+}
+
+func (api *API) trustPeerHandler(w http.ResponseWriter, r *http.Request) {
+	if p := api.ParsePidOrFail(w, r); p != "" {
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"TrustPeer",
+			p,
+			&struct{}{},
+		)
+		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+	}
+}
+
+func (api *API) distrustPeerHandler(w http.ResponseWriter, r *http.Request) {
+	if p := api.ParsePidOrFail(w, r); p != "" {
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"DistrustPeer",
+			p,
+			&struct{}{},
+		)
+		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+	}
+}
+
+// operationLogHandler returns a page of the consensus operation log, from
+// the most recent, given by the "offset" and "limit" query parameters.
+func (api *API) operationLogHandler(w http.ResponseWriter, r *http.Request) {
+	var opts types.OpLogOptions
+	if err := opts.FromQuery(r.URL.Query()); err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	var ops []types.ConsensusOp
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"OperationLog",
+		opts,
+		&ops,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, ops)
+}
+
+func (api *API) pinHandler(w http.ResponseWriter, r *http.Request) {
+	if pin := api.ParseCidOrFail(w, r); pin.Defined() {
+		if !api.checkPinOwnershipOrFail(w, r, pin.Cid, pin.PinUpdate) {
+			return
+		}
+		api.config.Logger.Debugf("rest api pinHandler: %s", pin.Cid)
+		// span.AddAttributes(trace.StringAttribute("cid", pin.Cid))
+		var pinObj types.Pin
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"Pin",
+			pin,
+			&pinObj,
+		)
+		api.SendResponse(w, common.SetStatusAutomatically, err, pinObj)
+		api.config.Logger.Debug("rest api pinHandler done")
+	}
+}
+
+// pinEditHandler changes the name, group and metadata of a pin, given as a
+// JSON object with optional "name", "group" and "metadata" keys. Metadata
+// keys with empty values are removed.
+func (api *API) pinEditHandler(w http.ResponseWriter, r *http.Request) {
+	pin := api.ParseCidOrFail(w, r)
+	if !pin.Defined() {
+		return
+	}
+
+	edit := types.PinEdit{}
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&edit); err != nil {
+		api.SendResponse(w, http.StatusBadRequest, fmt.Errorf("error decoding pin edit: %w", err), nil)
+		return
+	}
+	edit.Cid = pin.Cid
+	if !api.checkPinOwnershipOrFail(w, r, edit.Cid) {
+		return
+	}
+
+	api.config.Logger.Debugf("rest api pinEditHandler: %s", edit.Cid)
+	var pinObj types.Pin
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinEdit",
+		edit,
+		&pinObj,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, pinObj)
+	api.config.Logger.Debug("rest api pinEditHandler done")
+}
+
+func (api *API) pinBatchHandler(w http.ResponseWriter, r *http.Request) {
+	var items []types.BatchItem
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&items); err != nil {
+		api.SendResponse(w, http.StatusBadRequest, fmt.Errorf("error decoding batch: %w", err), nil)
+		return
+	}
+	if len(items) == 0 {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("empty batch"), nil)
+		return
+	}
+	for _, item := range items {
+		if item.Action != types.BatchActionUnpin {
+			continue
+		}
+		if err := common.CheckScopes(r, common.ScopeUnpin); err != nil {
+			api.SendResponse(w, http.StatusForbidden, err, nil)
+			return
+		}
+		break
+	}
+
+	owner, _ := common.RequestIdentity(r)
+	cids := make([]types.Cid, 0, len(items))
+	for i := range items {
+		items[i].Options.Owner = owner
+		cids = append(cids, items[i].Cid, items[i].Options.PinUpdate)
+	}
+	if !api.checkPinOwnershipOrFail(w, r, cids...) {
+		return
+	}
+
+	api.config.Logger.Debugf("rest api pinBatchHandler: %d items", len(items))
+	var results []types.BatchResult
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinBatch",
+		items,
+		&results,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, results)
+	api.config.Logger.Debug("rest api pinBatchHandler done")
+}
+
+// reallocateHandler changes the replication factors of the pins given in
+// the JSON-encoded Reallocation in the request body.
+func (api *API) reallocateHandler(w http.ResponseWriter, r *http.Request) {
+	var re types.Reallocation
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&re); err != nil {
+		api.SendResponse(w, http.StatusBadRequest, fmt.Errorf("error decoding reallocation: %w", err), nil)
+		return
+	}
+	if len(re.Cids) == 0 && re.Search.IsEmpty() {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("cids or a search are needed to reallocate pins"), nil)
+		return
+	}
+	if !api.checkPinOwnershipOrFail(w, r, re.Cids...) {
+		return
+	}
+	if !re.Search.IsEmpty() && !api.checkSearchOwnershipOrFail(w, r, re.Search) {
+		return
+	}
+
+	api.config.Logger.Debugf("rest api reallocateHandler: %d cids", len(re.Cids))
+	var results []types.BatchResult
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Reallocate",
+		re,
+		&results,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, results)
+	api.config.Logger.Debug("rest api reallocateHandler done")
+}
+
+func (api *API) unpinHandler(w http.ResponseWriter, r *http.Request) {
+	if pin := api.ParseCidOrFail(w, r); pin.Defined() {
+		if !api.checkPinOwnershipOrFail(w, r, pin.Cid) {
+			return
+		}
+		api.config.Logger.Debugf("rest api unpinHandler: %s", pin.Cid)
+		// span.AddAttributes(trace.StringAttribute("cid", pin.Cid))
+		var pinObj types.Pin
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"Unpin",
+			pin,
+			&pinObj,
+		)
+		api.SendResponse(w, common.SetStatusAutomatically, err, pinObj)
+		api.config.Logger.Debug("rest api unpinHandler done")
+	}
+}
+
+// undoUnpinHandler pins again an item unpinned during the unpin grace
+// period of the cluster peer. With EnforcePinOwnership, only the owner of
+// the unpinned item can restore it.
+func (api *API) undoUnpinHandler(w http.ResponseWriter, r *http.Request) {
+	if pin := api.ParseCidOrFail(w, r); pin.Defined() {
+		api.config.Logger.Debugf("rest api undoUnpinHandler: %s", pin.Cid)
+		if !api.checkTombstoneOwnershipOrFail(w, r, pin.Cid) {
+			return
+		}
+		var pinObj types.Pin
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"UndoUnpin",
+			pin.Cid,
+			&pinObj,
+		)
+		api.SendResponse(w, common.SetStatusAutomatically, err, pinObj)
+		api.config.Logger.Debug("rest api undoUnpinHandler done")
+	}
+}
+
+func (api *API) pinPathHandler(w http.ResponseWriter, r *http.Request) {
+	var pin types.Pin
+	if pinpath := api.ParsePinPathOrFail(w, r); pinpath.Defined() {
+		if !api.checkPinPathOwnershipOrFail(w, r, pinpath) {
+			return
+		}
+		api.config.Logger.Debugf("rest api pinPathHandler: %s", pinpath.Path)
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"PinPath",
+			pinpath,
+			&pin,
+		)
+
+		api.SendResponse(w, common.SetStatusAutomatically, err, pin)
+		api.config.Logger.Debug("rest api pinPathHandler done")
+	}
+}
+
+func (api *API) unpinPathHandler(w http.ResponseWriter, r *http.Request) {
+	var pin types.Pin
+	if pinpath := api.ParsePinPathOrFail(w, r); pinpath.Defined() {
+		if !api.checkPinPathOwnershipOrFail(w, r, pinpath) {
+			return
+		}
+		api.config.Logger.Debugf("rest api unpinPathHandler: %s", pinpath.Path)
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"UnpinPath",
+			pinpath,
+			&pin,
+		)
+		api.SendResponse(w, common.SetStatusAutomatically, err, pin)
+		api.config.Logger.Debug("rest api unpinPathHandler done")
+	}
+}
+
+func (api *API) groupsHandler(w http.ResponseWriter, r *http.Request) {
+	var groups []types.PinGroup
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Groups",
+		struct{}{},
+		&groups,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, groups)
+}
+
+// groupPinsHandler streams the pins in a group, so that a dataset can be
+// exported and re-imported with the batch endpoint.
+func (api *API) groupPinsHandler(w http.ResponseWriter, r *http.Request) {
+	group := mux.Vars(r)["group"]
+
+	in := make(chan string, 1)
+	in <- group
+	close(in)
+
+	out := make(chan types.Pin, common.StreamChannelSize)
+	errCh := make(chan error, 1)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	go func() {
+		defer close(errCh)
+
+		errCh <- api.rpcClient.Stream(
+			r.Context(),
+			"",
+			"Cluster",
+			"GroupPins",
+			in,
+			out,
+		)
+	}()
+
+	iter := func() (interface{}, bool, error) {
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case p, ok := <-out:
+			return p, ok, nil
+		}
+	}
+
+	api.streamList(w, r, iter, errCh)
+}
+
+// searchPinsHandler streams the pins with the name given in the "name" query
+// parameter and the metadata given in "meta[key]=value" parameters.
+func (api *API) searchPinsHandler(w http.ResponseWriter, r *http.Request) {
+	var search types.PinSearch
+	search.FromQuery(r.URL.Query())
+	if search.IsEmpty() {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("a name or metadata is needed to search pins"), nil)
+		return
+	}
+
+	in := make(chan types.PinSearch, 1)
+	in <- search
+	close(in)
+
+	out := make(chan types.Pin, common.StreamChannelSize)
+	errCh := make(chan error, 1)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	go func() {
+		defer close(errCh)
+
+		errCh <- api.rpcClient.Stream(
+			r.Context(),
+			"",
+			"Cluster",
+			"SearchPins",
+			in,
+			out,
+		)
+	}()
+
+	iter := func() (interface{}, bool, error) {
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case p, ok := <-out:
+			return p, ok, nil
+		}
+	}
+
+	api.streamList(w, r, iter, errCh)
+}
+
+// groupReplicationHandler sets the replication factors of all the pins in a
+// group to those given in the replication-min and replication-max query
+// parameters.
+func (api *API) groupReplicationHandler(w http.ResponseWriter, r *http.Request) {
+	var opts types.PinOptions
+	if err := opts.FromQuery(r.URL.Query()); err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	opts.Group = mux.Vars(r)["group"]
+	if !api.checkGroupOwnershipOrFail(w, r, opts.Group) {
+		return
+	}
+
+	api.config.Logger.Debugf("rest api groupReplicationHandler: %s", opts.Group)
+	var results []types.BatchResult
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"SetGroupReplication",
+		opts,
+		&results,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, results)
+	api.config.Logger.Debug("rest api groupReplicationHandler done")
+}
+
+func (api *API) unpinGroupHandler(w http.ResponseWriter, r *http.Request) {
+	group := mux.Vars(r)["group"]
+	if !api.checkGroupOwnershipOrFail(w, r, group) {
+		return
+	}
+
+	api.config.Logger.Debugf("rest api unpinGroupHandler: %s", group)
+	var results []types.BatchResult
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"UnpinGroup",
+		group,
+		&results,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, results)
+	api.config.Logger.Debug("rest api unpinGroupHandler done")
+}
+
+// checkPinOwnershipOrFail checks that the client making the request can
+// unpin or modify the pins with the given CIDs, when they exist. Otherwise
+// it makes the request fail and returns false. Undefined CIDs are ignored.
+func (api *API) checkPinOwnershipOrFail(w http.ResponseWriter, r *http.Request, cids ...types.Cid) bool {
+	identity, ok := api.OwnershipIdentity(r)
+	if !ok {
+		return true
+	}
+
+	for _, ci := range cids {
+		if !ci.Defined() {
+			continue
+		}
+		var pin types.Pin
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"PinGet",
+			ci,
+			&pin,
+		)
+		if err != nil && err.Error() == state.ErrNotFound.Error() {
+			continue
+		}
+		if err != nil {
+			api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+			return false
+		}
+		if err := common.PinOwnershipError(identity, pin); err != nil {
+			api.SendResponse(w, http.StatusForbidden, err, nil)
+			return false
+		}
+	}
+	return true
+}
+
+// checkTombstoneOwnershipOrFail checks that the client making the request
+// owned the item with the given CID unpinned by the cluster peer, so that it
+// can undo the unpin. Otherwise it makes the request fail and returns false.
+func (api *API) checkTombstoneOwnershipOrFail(w http.ResponseWriter, r *http.Request, ci types.Cid) bool {
+	identity, ok := api.OwnershipIdentity(r)
+	if !ok {
+		return true
+	}
+
+	var pin types.Pin
+	err := api.rpcClient.CallContext(
 		r.Context(),
 		"",
 		"Cluster",
-		"PeerAdd",
-		pid,
-		&id,
+		"Tombstone",
+		ci,
+		&pin,
 	)
-	api.SendResponse(w, common.SetStatusAutomatically, err, &id)
+	if err != nil {
+		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+		return false
+	}
+	if err := common.PinOwnershipError(identity, pin); err != nil {
+		api.SendResponse(w, http.StatusForbidden, err, nil)
+		return false
+	}
+	return true
 }
 
-func (api *API) peerRemoveHandler(w http.ResponseWriter, r *http.Request) {
-	if p := api.ParsePidOrFail(w, r); p != "" {
-		err := api.rpcClient.CallContext(
-			r.Context(),
-			"",
-			"Cluster",
-			"PeerRemove",
-			p,
-			&struct{}{},
-		)
+// checkPinPathOwnershipOrFail works like checkPinOwnershipOrFail with the
+// CID that the path resolves to, and the one being updated, if any.
+func (api *API) checkPinPathOwnershipOrFail(w http.ResponseWriter, r *http.Request, pinpath types.PinPath) bool {
+	if _, ok := api.OwnershipIdentity(r); !ok {
+		return true
+	}
+
+	var ci types.Cid
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"IPFSConnector",
+		"Resolve",
+		pinpath.Path,
+		&ci,
+	)
+	if err != nil {
 		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+		return false
 	}
+	return api.checkPinOwnershipOrFail(w, r, ci, pinpath.PinUpdate)
 }
 
-func (api *API) pinHandler(w http.ResponseWriter, r *http.Request) {
-	if pin := api.ParseCidOrFail(w, r); pin.Defined() {
-		api.config.Logger.Debugf("rest api pinHandler: %s", pin.Cid)
-		// span.AddAttributes(trace.StringAttribute("cid", pin.Cid))
-		var pinObj types.Pin
-		err := api.rpcClient.CallContext(
-			r.Context(),
-			"",
-			"Cluster",
-			"Pin",
-			pin,
-			&pinObj,
-		)
-		api.SendResponse(w, common.SetStatusAutomatically, err, pinObj)
-		api.config.Logger.Debug("rest api pinHandler done")
+// checkGroupOwnershipOrFail checks that the client making the request can
+// modify all the pins in the group. Otherwise, it makes the request fail
+// and returns false.
+func (api *API) checkGroupOwnershipOrFail(w http.ResponseWriter, r *http.Request, group string) bool {
+	in := make(chan string, 1)
+	in <- group
+	close(in)
+	return api.checkStreamOwnershipOrFail(w, r, "GroupPins", in)
+}
+
+// checkSearchOwnershipOrFail checks that the client making the request can
+// modify all the pins matching the search. Otherwise, it makes the request
+// fail and returns false.
+func (api *API) checkSearchOwnershipOrFail(w http.ResponseWriter, r *http.Request, search types.PinSearch) bool {
+	in := make(chan types.PinSearch, 1)
+	in <- search
+	close(in)
+	return api.checkStreamOwnershipOrFail(w, r, "SearchPins", in)
+}
+
+// checkStreamOwnershipOrFail checks the ownership of the pins sent by the
+// given streaming Cluster RPC method.
+func (api *API) checkStreamOwnershipOrFail(w http.ResponseWriter, r *http.Request, method string, in interface{}) bool {
+	identity, ok := api.OwnershipIdentity(r)
+	if !ok {
+		return true
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	out := make(chan types.Pin, common.StreamChannelSize)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- api.rpcClient.Stream(ctx, "", "Cluster", method, in, out)
+	}()
+
+	var ownershipErr error
+	for p := range out {
+		if ownershipErr == nil {
+			ownershipErr = common.PinOwnershipError(identity, p)
+			if ownershipErr != nil {
+				cancel()
+			}
+		}
 	}
+	err := <-errCh
+	if ownershipErr != nil {
+		api.SendResponse(w, http.StatusForbidden, ownershipErr, nil)
+		return false
+	}
+	if err != nil {
+		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+		return false
+	}
+	return true
 }
 
-func (api *API) unpinHandler(w http.ResponseWriter, r *http.Request) {
-	if pin := api.ParseCidOrFail(w, r); pin.Defined() {
-		api.config.Logger.Debugf("rest api unpinHandler: %s", pin.Cid)
-		// span.AddAttributes(trace.StringAttribute("cid", pin.Cid))
-		var pinObj types.Pin
-		err := api.rpcClient.CallContext(
-			r.Context(),
-			"",
-			"Cluster",
-			"Unpin",
-			pin,
-			&pinObj,
-		)
-		api.SendResponse(w, common.SetStatusAutomatically, err, pinObj)
-		api.config.Logger.Debug("rest api unpinHandler done")
+// streamList sends the items of the /allocations and /pins listings as they
+// are produced. They are sent as application/x-ndjson to the clients that
+// accept it.
+func (api *API) streamList(w http.ResponseWriter, r *http.Request, next common.StreamIterator, errCh chan error) {
+	if common.WantsNDJSON(r) {
+		api.StreamNDJSONResponse(w, next, errCh)
+		return
 	}
+	api.StreamResponse(w, next, errCh)
 }
 
-func (api *API) pinPathHandler(w http.ResponseWriter, r *http.Request) {
-	var pin types.Pin
-	if pinpath := api.ParsePinPathOrFail(w, r); pinpath.Defined() {
-		api.config.Logger.Debugf("rest api pinPathHandler: %s", pinpath.Path)
-		err := api.rpcClient.CallContext(
-			r.Context(),
-			"",
-			"Cluster",
-			"PinPath",
-			pinpath,
-			&pin,
-		)
+// eventsHandler sends the events of this peer as Server-Sent Events until
+// the client disconnects. The "types" query parameter selects which event
+// types are sent. A comment is sent every eventsKeepAliveInterval so that
+// idle connections are not closed.
+func (api *API) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	wanted := map[string]bool{}
+	if typesStr := r.URL.Query().Get("types"); typesStr != "" {
+		for _, t := range strings.Split(typesStr, ",") {
+			switch t {
+			case types.EventPinStatus, types.EventPeerJoined, types.EventPeerLeft, types.EventAlert, types.EventPinExpired:
+				wanted[t] = true
+			default:
+				api.SendResponse(w, http.StatusBadRequest, fmt.Errorf("invalid event type: %s", t), nil)
+				return
+			}
+		}
+	}
 
-		api.SendResponse(w, common.SetStatusAutomatically, err, pin)
-		api.config.Logger.Debug("rest api pinPathHandler done")
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		api.SendResponse(w, http.StatusInternalServerError, errors.New("streaming is not supported"), nil)
+		return
 	}
-}
 
-func (api *API) unpinPathHandler(w http.ResponseWriter, r *http.Request) {
-	var pin types.Pin
-	if pinpath := api.ParsePinPathOrFail(w, r); pinpath.Defined() {
-		api.config.Logger.Debugf("rest api unpinPathHandler: %s", pinpath.Path)
-		err := api.rpcClient.CallContext(
-			r.Context(),
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	in := make(chan struct{})
+	close(in)
+	out := make(chan types.Event, common.StreamChannelSize)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+
+		errCh <- api.rpcClient.Stream(
+			ctx,
 			"",
 			"Cluster",
-			"UnpinPath",
-			pinpath,
-			&pin,
+			"Events",
+			in,
+			out,
 		)
-		api.SendResponse(w, common.SetStatusAutomatically, err, pin)
-		api.config.Logger.Debug("rest api unpinPathHandler done")
+	}()
+
+	api.SetHeadersWithContentType(w, "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case ev, ok := <-out:
+			if !ok {
+				if err := <-errCh; err != nil {
+					api.config.Logger.Errorf("error streaming events: %s", err)
+					data, _ := json.Marshal(types.Error{Code: http.StatusInternalServerError, Message: err.Error()})
+					fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+				}
+				return
+			}
+			if len(wanted) > 0 && !wanted[ev.Type] {
+				continue
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				api.config.Logger.Error(err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
 	}
 }
 
@@ -513,7 +1638,7 @@ func (api *API) allocationsHandler(w http.ResponseWriter, r *http.Request) {
 		return p, ok, ctx.Err()
 	}
 
-	api.StreamResponse(w, iter, errCh)
+	api.streamList(w, r, iter, errCh)
 }
 
 func (api *API) allocationHandler(w http.ResponseWriter, r *http.Request) {
@@ -552,6 +1677,17 @@ func (api *API) statusAllHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	saFilter, err := statusAllFilterFromQuery(queryValues, filter)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	filtered := saFilter.HasCriteria()
+	if filtered && local == "true" {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("pin filters and pagination are not supported with local=true"), nil)
+		return
+	}
+
 	var iter common.StreamIterator
 	in := make(chan types.TrackerStatus, 1)
 	in <- filter
@@ -595,18 +1731,84 @@ func (api *API) statusAllHandler(w http.ResponseWriter, r *http.Request) {
 		go func() {
 			defer close(errCh)
 
+			if !filtered {
+				errCh <- api.rpcClient.Stream(
+					r.Context(),
+					"",
+					"Cluster",
+					"StatusAll",
+					in,
+					out,
+				)
+				return
+			}
+
+			filterIn := make(chan types.StatusAllFilter, 1)
+			filterIn <- saFilter
+			close(filterIn)
 			errCh <- api.rpcClient.Stream(
 				r.Context(),
 				"",
 				"Cluster",
-				"StatusAll",
-				in,
+				"StatusAllFiltered",
+				filterIn,
 				out,
 			)
 		}()
 	}
 
-	api.StreamResponse(w, iter, errCh)
+	api.streamList(w, r, iter, errCh)
+}
+
+// statusAllFilterFromQuery parses the filtering and pagination parameters
+// of GET /pins: cid_prefix, name, group, meta-<key>, allocation, offset,
+// limit and after.
+func statusAllFilterFromQuery(q url.Values, status types.TrackerStatus) (types.StatusAllFilter, error) {
+	filter := types.StatusAllFilter{
+		Status:    status,
+		CidPrefix: q.Get("cid_prefix"),
+		Name:      q.Get("name"),
+		Group:     q.Get("group"),
+	}
+
+	for k := range q {
+		if !strings.HasPrefix(k, "meta-") {
+			continue
+		}
+		if filter.Metadata == nil {
+			filter.Metadata = make(map[string]string)
+		}
+		filter.Metadata[strings.TrimPrefix(k, "meta-")] = q.Get(k)
+	}
+
+	if v := q.Get("allocation"); v != "" {
+		pid, err := peer.Decode(v)
+		if err != nil {
+			return filter, fmt.Errorf("error decoding allocation: %w", err)
+		}
+		filter.Allocation = pid
+	}
+
+	for param, dst := range map[string]*int{"offset": &filter.Offset, "limit": &filter.Limit} {
+		v := q.Get(param)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return filter, fmt.Errorf("invalid %s value: %s", param, v)
+		}
+		*dst = n
+	}
+
+	if v := q.Get("after"); v != "" {
+		c, err := types.DecodeCid(v)
+		if err != nil {
+			return filter, fmt.Errorf("error decoding after: %w", err)
+		}
+		filter.After = c
+	}
+	return filter, nil
 }
 
 // request statuses for multiple CIDs in parallel.
@@ -688,7 +1890,7 @@ func (api *API) statusCidsHandler(w http.ResponseWriter, r *http.Request) {
 		return gpi, ok, nil
 	}
 
-	api.StreamResponse(w, iter, errCh)
+	api.streamList(w, r, iter, errCh)
 }
 
 func (api *API) statusHandler(w http.ResponseWriter, r *http.Request) {
@@ -723,6 +1925,16 @@ func (api *API) statusHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (api *API) recoverAllHandler(w http.ResponseWriter, r *http.Request) {
+	api.recoverStreamHandler(w, r, "RecoverAllLocal", "RecoverAll")
+}
+
+func (api *API) recoverPermanentHandler(w http.ResponseWriter, r *http.Request) {
+	api.recoverStreamHandler(w, r, "RecoverPermanentLocal", "RecoverPermanent")
+}
+
+// recoverStreamHandler streams the results of the given Cluster recover
+// method, or of its local version when the local parameter is set.
+func (api *API) recoverStreamHandler(w http.ResponseWriter, r *http.Request, localMethod, globalMethod string) {
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
@@ -752,7 +1964,7 @@ func (api *API) recoverAllHandler(w http.ResponseWriter, r *http.Request) {
 				r.Context(),
 				"",
 				"Cluster",
-				"RecoverAllLocal",
+				localMethod,
 				in,
 				out,
 			)
@@ -775,7 +1987,7 @@ func (api *API) recoverAllHandler(w http.ResponseWriter, r *http.Request) {
 				r.Context(),
 				"",
 				"Cluster",
-				"RecoverAll",
+				globalMethod,
 				in,
 				out,
 			)
@@ -816,6 +2028,32 @@ func (api *API) recoverHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (api *API) verifyPinHandler(w http.ResponseWriter, r *http.Request) {
+	req := types.PinVerifyRequest{}
+	if sample := r.URL.Query().Get("sample"); sample != "" {
+		n, err := strconv.Atoi(sample)
+		if err != nil || n < 0 {
+			api.SendResponse(w, http.StatusBadRequest, errors.New("sample must be a positive number of blocks"), nil)
+			return
+		}
+		req.Sample = n
+	}
+
+	if pin := api.ParseCidOrFail(w, r); pin.Defined() {
+		req.Cid = pin.Cid
+		var res types.PinVerification
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"VerifyPin",
+			req,
+			&res,
+		)
+		api.SendResponse(w, common.SetStatusAutomatically, err, res)
+	}
+}
+
 func (api *API) repoGCHandler(w http.ResponseWriter, r *http.Request) {
 	queryValues := r.URL.Query()
 	local := queryValues.Get("local")
@@ -835,18 +2073,150 @@ func (api *API) repoGCHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The number of peers running gc at once.
+	var concurrency int
+	if v := queryValues.Get("concurrency"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			api.SendResponse(w, http.StatusBadRequest, fmt.Errorf("invalid concurrency value: %s", v), nil)
+			return
+		}
+		concurrency = n
+	}
+
 	var repoGC types.GlobalRepoGC
 	err := api.rpcClient.CallContext(
 		r.Context(),
 		"",
 		"Cluster",
-		"RepoGC",
-		struct{}{},
+		"GarbageCollect",
+		concurrency,
 		&repoGC,
 	)
 	api.SendResponse(w, common.SetStatusAutomatically, err, repoGC)
 }
 
+func (api *API) pinTrackerConcurrencyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("local") == "true" {
+		var conc types.PinTrackerConcurrency
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"PinTracker",
+			"Concurrency",
+			struct{}{},
+			&conc,
+		)
+		api.SendResponse(w, common.SetStatusAutomatically, err, []types.PinTrackerConcurrency{conc})
+		return
+	}
+
+	var concs []types.PinTrackerConcurrency
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinTrackerConcurrency",
+		struct{}{},
+		&concs,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, concs)
+}
+
+func (api *API) setPinTrackerConcurrencyHandler(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+
+	var conc types.PinTrackerConcurrency
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{
+		{"pins", &conc.Pins},
+		{"unpins", &conc.Unpins},
+	} {
+		v := queryValues.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			api.SendResponse(w, http.StatusBadRequest, fmt.Errorf("invalid %s value: %s", p.name, v), nil)
+			return
+		}
+		*p.dst = n
+	}
+	if v := queryValues.Get("auto"); v != "" {
+		auto, err := strconv.ParseBool(v)
+		if err != nil {
+			api.SendResponse(w, http.StatusBadRequest, fmt.Errorf("invalid auto value: %s", v), nil)
+			return
+		}
+		conc.Auto = auto
+	}
+
+	if queryValues.Get("local") == "true" {
+		var res types.PinTrackerConcurrency
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"PinTracker",
+			"SetConcurrency",
+			conc,
+			&res,
+		)
+		api.SendResponse(w, common.SetStatusAutomatically, err, []types.PinTrackerConcurrency{res})
+		return
+	}
+
+	var concs []types.PinTrackerConcurrency
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"SetPinTrackerConcurrency",
+		conc,
+		&concs,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, concs)
+}
+
+func (api *API) pinTrackerPauseHandler(w http.ResponseWriter, r *http.Request) {
+	api.pinTrackerPausedHandler(w, r, "Pause", "PinTrackerPause")
+}
+
+func (api *API) pinTrackerResumeHandler(w http.ResponseWriter, r *http.Request) {
+	api.pinTrackerPausedHandler(w, r, "Resume", "PinTrackerResume")
+}
+
+// pinTrackerPausedHandler calls the given PinTracker method on the local
+// peer, or the Cluster one which broadcasts it to all peers.
+func (api *API) pinTrackerPausedHandler(w http.ResponseWriter, r *http.Request, trackerMethod, clusterMethod string) {
+	if r.URL.Query().Get("local") == "true" {
+		var conc types.PinTrackerConcurrency
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"PinTracker",
+			trackerMethod,
+			struct{}{},
+			&conc,
+		)
+		api.SendResponse(w, common.SetStatusAutomatically, err, []types.PinTrackerConcurrency{conc})
+		return
+	}
+
+	var concs []types.PinTrackerConcurrency
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		clusterMethod,
+		struct{}{},
+		&concs,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, concs)
+}
+
 func repoGCToGlobal(r types.RepoGC) types.GlobalRepoGC {
 	return types.GlobalRepoGC{
 		PeerMap: map[string]types.RepoGC{
@@ -1,18 +1,28 @@
 package rest
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/ipfs-cluster/ipfs-cluster/adder/adderutils"
 	"github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/api/common"
 	test "github.com/ipfs-cluster/ipfs-cluster/api/common/test"
 	clustertest "github.com/ipfs-cluster/ipfs-cluster/test"
 
+	cid "github.com/ipfs/go-cid"
+	files "github.com/ipfs/go-ipfs-files"
+	ipld "github.com/ipfs/go-ipld-format"
+	merkledag "github.com/ipfs/go-merkledag"
+	car "github.com/ipld/go-car"
 	libp2p "github.com/libp2p/go-libp2p"
 	peer "github.com/libp2p/go-libp2p/core/peer"
 	ma "github.com/multiformats/go-multiaddr"
@@ -52,15 +62,18 @@ func testAPIwithConfig(t *testing.T, cfg *Config, name string) *API {
 	return rest
 }
 
-func testAPI(t *testing.T) *API {
+func testConfig() *Config {
 	cfg := NewConfig()
 	cfg.Default()
 	cfg.CORSAllowedOrigins = []string{clientOrigin}
-	cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+	cfg.CORSAllowedMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
 	//cfg.CORSAllowedHeaders = []string{"Content-Type"}
 	cfg.CORSMaxAge = 10 * time.Minute
+	return cfg
+}
 
-	return testAPIwithConfig(t, cfg, "basic")
+func testAPI(t *testing.T) *API {
+	return testAPIwithConfig(t, testConfig(), "basic")
 }
 
 func TestRestAPIIDEndpoint(t *testing.T) {
@@ -74,6 +87,12 @@ func TestRestAPIIDEndpoint(t *testing.T) {
 		if id.ID.Pretty() != clustertest.PeerID1.Pretty() {
 			t.Error("expected correct id")
 		}
+
+		id = api.ID{}
+		test.MakeGet(t, rest, url(rest)+"/v1/id", &id)
+		if id.ID.Pretty() != clustertest.PeerID1.Pretty() {
+			t.Error("expected correct id in /v1/id")
+		}
 	}
 
 	test.BothEndpoints(t, tf)
@@ -266,6 +285,582 @@ func TestAPIAddFileEndpoint_StreamChannelsFalse(t *testing.T) {
 	test.BothEndpoints(t, tf)
 }
 
+func TestAPIAddFileEndpointAsync(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	sth := clustertest.NewShardingTestHelper()
+	defer sth.Clean(t)
+
+	// This generates the testing files and
+	// writes them to disk.
+	// This is necessary here because we run tests
+	// in parallel, and otherwise a write-race might happen.
+	_, closer := sth.GetTreeMultiReader(t)
+	closer.Close()
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		body, closer := sth.GetTreeMultiReader(t)
+		defer closer.Close()
+		mpContentType := "multipart/form-data; boundary=" + body.Boundary()
+		job := api.AddJob{}
+		fmtStr1 := "/add?shard=false&repl_min=-1&repl_max=-1&async=true"
+		test.MakeStreamingPost(t, rest, url(rest)+fmtStr1, body, mpContentType, &job)
+		if job.ID == "" {
+			t.Fatal("expected a job ID")
+		}
+		if job.Uploaded == 0 {
+			t.Error("expected the upload size to be set")
+		}
+
+		for i := 0; job.Status == api.AddJobRunning; i++ {
+			if i > 50 {
+				t.Fatal("add job did not finish")
+			}
+			time.Sleep(100 * time.Millisecond)
+			test.MakeGet(t, rest, url(rest)+"/add/jobs/"+job.ID, &job)
+		}
+		if job.Status != api.AddJobDone {
+			t.Fatal("add job failed:", job.Error)
+		}
+		if job.Cid.String() != clustertest.ShardingDirBalancedRootCID {
+			t.Error("Bad Cid after adding: ", job.Cid)
+		}
+		if job.Blocks == 0 || job.Bytes == 0 || !job.CurrentCid.Defined() {
+			t.Error("expected the job progress to be set")
+		}
+
+		errResp := api.Error{}
+		test.MakeGet(t, rest, url(rest)+"/add/jobs/abc", &errResp)
+		if errResp.Code != http.StatusNotFound {
+			t.Error("expected a not found error for unknown jobs")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIAddJobUpload(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	sth := clustertest.NewShardingTestHelper()
+	defer sth.Clean(t)
+	_, closer := sth.GetTreeMultiReader(t)
+	closer.Close()
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		job := api.AddJob{}
+		test.MakePost(t, rest, url(rest)+"/add/jobs?shard=false&repl_min=-1&repl_max=-1", []byte{}, &job)
+		if job.ID == "" || job.Status != api.AddJobUploading {
+			t.Fatal("expected a job waiting for its upload:", job)
+		}
+
+		body, closer := sth.GetTreeMultiReader(t)
+		defer closer.Close()
+		mpContentType := "multipart/form-data; boundary=" + body.Boundary()
+		test.MakeStreamingPut(t, rest, url(rest)+"/add/jobs/"+job.ID, body, mpContentType, &job)
+		for i := 0; job.Status == api.AddJobRunning; i++ {
+			if i > 50 {
+				t.Fatal("add job did not finish")
+			}
+			time.Sleep(100 * time.Millisecond)
+			test.MakeGet(t, rest, url(rest)+"/add/jobs/"+job.ID, &job)
+		}
+		if job.Status != api.AddJobDone || job.Cid.String() != clustertest.ShardingDirBalancedRootCID {
+			t.Fatal("add job failed:", job.Status, job.Error)
+		}
+
+		errResp := api.Error{}
+		test.MakeStreamingPut(t, rest, url(rest)+"/add/jobs/"+job.ID, strings.NewReader(""), mpContentType, &errResp)
+		if errResp.Code != http.StatusNotFound {
+			t.Error("expected a not found error when uploading twice")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIAddJobUploadTooLarge(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig()
+	cfg.MaxAsyncAddSize = 100
+	rest := testAPIwithConfig(t, cfg, "max_async_add_size")
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		job := api.AddJob{}
+		test.MakePost(t, rest, url(rest)+"/add/jobs", []byte{}, &job)
+
+		mpContentType := "multipart/form-data; boundary=abc"
+		body := strings.NewReader(strings.Repeat("a", 200))
+		errResp := api.Error{}
+		test.MakeStreamingPut(t, rest, url(rest)+"/add/jobs/"+job.ID, body, mpContentType, &errResp)
+		if errResp.Code != http.StatusRequestEntityTooLarge {
+			t.Error("expected a 413 error:", errResp)
+		}
+
+		test.MakeGet(t, rest, url(rest)+"/add/jobs/"+job.ID, &job)
+		if job.Status != api.AddJobError {
+			t.Error("the job should have failed:", job.Status)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIAddURL(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig()
+	cfg.AddURLAllowPrivate = true
+	rest := testAPIwithConfig(t, cfg, "add_url_allow_private")
+	defer rest.Shutdown(ctx)
+
+	data := bytes.Repeat([]byte("remote content "), 100000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	waitJob := func(t *testing.T, url test.URLFunc, job *api.AddJob) {
+		for i := 0; job.Status == api.AddJobRunning; i++ {
+			if i > 50 {
+				t.Fatal("add job did not finish")
+			}
+			time.Sleep(100 * time.Millisecond)
+			test.MakeGet(t, rest, url(rest)+"/add/jobs/"+job.ID, job)
+		}
+	}
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		job := api.AddJob{}
+		fmtStr1 := "/add/url?shard=false&repl_min=-1&repl_max=-1&url="
+		test.MakePost(t, rest, url(rest)+fmtStr1+srv.URL+"/file.txt", nil, &job)
+		if job.ID == "" {
+			t.Fatal("expected a job ID")
+		}
+		waitJob(t, url, &job)
+		if job.Status != api.AddJobDone {
+			t.Fatal("add job failed:", job.Error)
+		}
+		if !job.Cid.Defined() || job.Blocks == 0 {
+			t.Error("expected the content to be added")
+		}
+		if job.Uploaded != uint64(len(data)) {
+			t.Errorf("expected %d bytes fetched, got %d", len(data), job.Uploaded)
+		}
+
+		job = api.AddJob{}
+		test.MakePost(t, rest, url(rest)+fmtStr1+srv.URL+"/missing", nil, &job)
+		waitJob(t, url, &job)
+		if job.Status != api.AddJobError || !strings.Contains(job.Error, "404") {
+			t.Error("expected the job to fail with the fetch error")
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+fmtStr1+"ftp://example.com/file.txt", nil, &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected only http(s) URLs to be accepted")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIAddURLForbidden(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer srv.Close()
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		job := api.AddJob{}
+		test.MakePost(t, rest, url(rest)+"/add/url?url="+srv.URL+"/secret", nil, &job)
+		for i := 0; job.Status == api.AddJobRunning; i++ {
+			if i > 50 {
+				t.Fatal("add job did not finish")
+			}
+			time.Sleep(100 * time.Millisecond)
+			test.MakeGet(t, rest, url(rest)+"/add/jobs/"+job.ID, &job)
+		}
+		if job.Status != api.AddJobError || !strings.Contains(job.Error, adderutils.ErrAddURLForbidden.Error()) {
+			t.Error("expected fetching from a loopback address to fail:", job.Status, job.Error)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIAddURLTooLarge(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig()
+	cfg.AddURLAllowPrivate = true
+	cfg.MaxAsyncAddSize = 100
+	rest := testAPIwithConfig(t, cfg, "max_async_add_size")
+	defer rest.Shutdown(ctx)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stream it, so that the size is not known in advance.
+		w.Header().Set("Content-Type", "text/plain")
+		for i := 0; i < 10; i++ {
+			w.Write(bytes.Repeat([]byte("a"), 50))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		job := api.AddJob{}
+		test.MakePost(t, rest, url(rest)+"/add/url?shard=false&repl_min=-1&repl_max=-1&url="+srv.URL+"/large", nil, &job)
+		for i := 0; job.Status == api.AddJobRunning; i++ {
+			if i > 50 {
+				t.Fatal("add job did not finish")
+			}
+			time.Sleep(100 * time.Millisecond)
+			test.MakeGet(t, rest, url(rest)+"/add/jobs/"+job.ID, &job)
+		}
+		if job.Status != api.AddJobError || !strings.Contains(job.Error, adderutils.ErrAddTooLarge.Error()) {
+			t.Error("expected the job to fail with a too large error:", job.Status, job.Error)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIAddS3(t *testing.T) {
+	ctx := context.Background()
+
+	objects := map[string][]byte{
+		"data/a.txt":     bytes.Repeat([]byte("a"), 300000),
+		"data/sub/b.txt": []byte("b"),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bucket", "/bucket/":
+			fmt.Fprint(w, "<ListBucketResult>")
+			for _, k := range []string{"data/a.txt", "data/sub/b.txt"} {
+				fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size></Contents>", k, len(objects[k]))
+			}
+			fmt.Fprint(w, "</ListBucketResult>")
+		default:
+			data, ok := objects[strings.TrimPrefix(r.URL.Path, "/bucket/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.CORSAllowedOrigins = []string{clientOrigin}
+	cfg.CORSAllowedMethods = []string{"GET", "POST"}
+	cfg.S3Endpoint = srv.URL
+	rest := testAPIwithConfig(t, cfg, "s3")
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		job := api.AddJob{}
+		test.MakePost(t, rest, url(rest)+"/add/s3?bucket=bucket&prefix=data/", nil, &job)
+		if job.ID == "" {
+			t.Fatal("expected a job ID")
+		}
+		for i := 0; job.Status == api.AddJobRunning; i++ {
+			if i > 50 {
+				t.Fatal("add job did not finish")
+			}
+			time.Sleep(100 * time.Millisecond)
+			test.MakeGet(t, rest, url(rest)+"/add/jobs/"+job.ID, &job)
+		}
+		if job.Status != api.AddJobDone {
+			t.Fatal("add job failed:", job.Error)
+		}
+		if !job.Cid.Defined() || job.Blocks == 0 {
+			t.Error("expected the objects to be added")
+		}
+		if job.Uploaded != 300001 {
+			t.Errorf("expected 300001 bytes fetched, got %d", job.Uploaded)
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/add/s3?prefix=data/", nil, &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request without bucket")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+
+	noS3 := testAPI(t)
+	defer noS3.Shutdown(ctx)
+	errResp := api.Error{}
+	test.MakePost(t, noS3, test.HTTPURL(noS3)+"/add/s3?bucket=bucket", nil, &errResp)
+	if errResp.Code != http.StatusNotImplemented {
+		t.Error("expected an error when S3 is not configured")
+	}
+
+	// The objects together are larger than the limit.
+	limitCfg := testConfig()
+	limitCfg.S3Endpoint = srv.URL
+	limitCfg.MaxAsyncAddSize = 1000
+	limited := testAPIwithConfig(t, limitCfg, "s3 max_async_add_size")
+	defer limited.Shutdown(ctx)
+	job := api.AddJob{}
+	test.MakePost(t, limited, test.HTTPURL(limited)+"/add/s3?bucket=bucket&prefix=data/", nil, &job)
+	for i := 0; job.Status == api.AddJobRunning; i++ {
+		if i > 50 {
+			t.Fatal("add job did not finish")
+		}
+		time.Sleep(100 * time.Millisecond)
+		test.MakeGet(t, limited, test.HTTPURL(limited)+"/add/jobs/"+job.ID, &job)
+	}
+	if job.Status != api.AddJobError || !strings.Contains(job.Error, adderutils.ErrAddTooLarge.Error()) {
+		t.Error("expected the job to fail with a too large error:", job.Status, job.Error)
+	}
+}
+
+func TestAPIAddUploads(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	sth := clustertest.NewShardingTestHelper()
+	defer sth.Clean(t)
+
+	// This generates the testing files and
+	// writes them to disk.
+	// This is necessary here because we run tests
+	// in parallel, and otherwise a write-race might happen.
+	_, closer := sth.GetTreeMultiReader(t)
+	closer.Close()
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		body, closer := sth.GetTreeMultiReader(t)
+		defer closer.Close()
+		fullBody, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mpContentType := "multipart/form-data; boundary=" + body.Boundary()
+		third := len(fullBody) / 3
+
+		upload := api.AddUpload{}
+		fmtStr1 := "/add/uploads?shard=false&repl_min=-1&repl_max=-1&stream-channels=false"
+		test.MakePostWithContentType(t, rest, url(rest)+fmtStr1, fullBody[:third], mpContentType, &upload)
+		if upload.ID == "" {
+			t.Fatal("expected an upload ID")
+		}
+		if upload.Offset != uint64(third) {
+			t.Fatalf("expected offset %d, got %d", third, upload.Offset)
+		}
+		uploadURL := url(rest) + "/add/uploads/" + upload.ID
+
+		errResp := api.Error{}
+		test.MakePatch(t, rest, uploadURL+"?offset=0", fullBody[third:], &errResp)
+		if errResp.Code != http.StatusConflict {
+			t.Error("expected a conflict when writing at the wrong offset")
+		}
+
+		test.MakePatch(t, rest, fmt.Sprintf("%s?offset=%d", uploadURL, third), fullBody[third:2*third], &upload)
+		test.MakeGet(t, rest, uploadURL, &upload)
+		if upload.Offset != uint64(2*third) {
+			t.Fatalf("expected offset %d, got %d", 2*third, upload.Offset)
+		}
+		test.MakePatch(t, rest, fmt.Sprintf("%s?offset=%d", uploadURL, 2*third), fullBody[2*third:], &upload)
+		if upload.Offset != uint64(len(fullBody)) {
+			t.Fatalf("expected offset %d, got %d", len(fullBody), upload.Offset)
+		}
+
+		resp := []api.AddedOutput{}
+		test.MakePostWithContentType(t, rest, uploadURL+"/complete", nil, "", &resp)
+		if len(resp) == 0 {
+			t.Fatal("expected the add output")
+		}
+		lastHash := resp[len(resp)-1]
+		if lastHash.Cid.String() != clustertest.ShardingDirBalancedRootCID {
+			t.Error("Bad Cid after adding: ", lastHash.Cid)
+		}
+
+		errResp = api.Error{}
+		test.MakeGet(t, rest, uploadURL, &errResp)
+		if errResp.Code != http.StatusNotFound {
+			t.Error("expected completed uploads to be removed")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIAddUploadsAsync(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	sth := clustertest.NewShardingTestHelper()
+	defer sth.Clean(t)
+
+	// This generates the testing files and
+	// writes them to disk.
+	// This is necessary here because we run tests
+	// in parallel, and otherwise a write-race might happen.
+	_, closer := sth.GetTreeMultiReader(t)
+	closer.Close()
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		body, closer := sth.GetTreeMultiReader(t)
+		defer closer.Close()
+		fullBody, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mpContentType := "multipart/form-data; boundary=" + body.Boundary()
+
+		upload := api.AddUpload{}
+		fmtStr1 := "/add/uploads?shard=false&repl_min=-1&repl_max=-1&async=true"
+		test.MakePostWithContentType(t, rest, url(rest)+fmtStr1, nil, mpContentType, &upload)
+		if upload.Offset != 0 {
+			t.Fatal("expected an empty upload")
+		}
+		uploadURL := url(rest) + "/add/uploads/" + upload.ID
+		test.MakePatch(t, rest, uploadURL+"?offset=0", fullBody, &upload)
+
+		job := api.AddJob{}
+		test.MakePost(t, rest, uploadURL+"/complete", nil, &job)
+		if job.Uploaded != uint64(len(fullBody)) {
+			t.Error("expected the upload size to be set")
+		}
+		for i := 0; job.Status == api.AddJobRunning; i++ {
+			if i > 50 {
+				t.Fatal("add job did not finish")
+			}
+			time.Sleep(100 * time.Millisecond)
+			test.MakeGet(t, rest, url(rest)+"/add/jobs/"+job.ID, &job)
+		}
+		if job.Status != api.AddJobDone {
+			t.Fatal("add job failed:", job.Error)
+		}
+		if job.Cid.String() != clustertest.ShardingDirBalancedRootCID {
+			t.Error("Bad Cid after adding: ", job.Cid)
+		}
+
+		// Aborted uploads are removed.
+		test.MakePostWithContentType(t, rest, url(rest)+fmtStr1, nil, mpContentType, &upload)
+		uploadURL = url(rest) + "/add/uploads/" + upload.ID
+		test.MakeDelete(t, rest, uploadURL, &struct{}{})
+		errResp := api.Error{}
+		test.MakeGet(t, rest, uploadURL, &errResp)
+		if errResp.Code != http.StatusNotFound {
+			t.Error("expected deleted uploads to be removed")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIAddFileEndpointCAR(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	dags := clustertest.NewMockDAGService(false)
+	nd1 := merkledag.NodeWithData([]byte("first root"))
+	nd2 := merkledag.NodeWithData([]byte("second root"))
+	for _, nd := range []ipld.Node{nd1, nd2} {
+		if err := dags.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var carBuf bytes.Buffer
+		err := car.WriteCar(ctx, dags, []cid.Cid{nd1.Cid(), nd2.Cid()}, &carBuf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := files.NewMultiFileReader(
+			files.NewMapDirectory(map[string]files.Node{
+				"dag.car": files.NewReaderFile(&carBuf),
+			}),
+			true,
+		)
+		fullBody, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mpContentType := "multipart/form-data; boundary=" + body.Boundary()
+		resp := []api.AddedOutput{}
+		fmtStr1 := "/add?format=car&repl_min=-1&repl_max=-1&stream-channels=false"
+		test.MakePostWithContentType(t, rest, url(rest)+fmtStr1, fullBody, mpContentType, &resp)
+
+		// One output per root, plus the directory wrapping them.
+		if len(resp) != 3 {
+			t.Fatalf("expected 3 outputs: got %d", len(resp))
+		}
+		if !resp[0].Cid.Cid.Equals(nd1.Cid()) || !resp[1].Cid.Cid.Equals(nd2.Cid()) {
+			t.Error("expected an output for every CAR root")
+		}
+		if resp[2].Cid.Cid.Equals(nd1.Cid()) || resp[2].Cid.Cid.Equals(nd2.Cid()) {
+			t.Error("expected the roots to be wrapped in a directory")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIDagPutEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		body := files.NewMultiFileReader(
+			files.NewMapDirectory(map[string]files.Node{
+				"node.json": files.NewBytesFile([]byte(`{"hello":"world"}`)),
+			}),
+			true,
+		)
+		fullBody, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mpContentType := "multipart/form-data; boundary=" + body.Boundary()
+		resp := []api.AddedOutput{}
+		fmtStr1 := "/dag/put?repl_min=-1&repl_max=-1&stream-channels=false"
+		test.MakePostWithContentType(t, rest, url(rest)+fmtStr1, fullBody, mpContentType, &resp)
+		if len(resp) != 1 {
+			t.Fatalf("expected 1 output: got %d", len(resp))
+		}
+		if resp[0].Cid.String() != "bafyreidykglsfhoixmivffc5uwhcgshx4j465xwqntbmu43nb2dzqwfvae" {
+			t.Error("unexpected CID for the node:", resp[0].Cid)
+		}
+
+		errResp := api.Error{}
+		test.MakePostWithContentType(t, rest, url(rest)+"/dag/put?input-codec=dag-pb", fullBody, mpContentType, &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected an error with a bad input-codec")
+		}
+		errResp = api.Error{}
+		test.MakePostWithContentType(t, rest, url(rest)+"/dag/put?format=car", fullBody, mpContentType, &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected an error when giving a format")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
 func TestAPIPeerRemoveEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -278,6 +873,47 @@ func TestAPIPeerRemoveEndpoint(t *testing.T) {
 	test.BothEndpoints(t, tf)
 }
 
+func TestAPITrustEndpoints(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var peers []peer.ID
+		test.MakeGet(t, rest, url(rest)+"/trust", &peers)
+		if len(peers) != 2 || peers[0] != clustertest.PeerID1 {
+			t.Errorf("unexpected trusted peers: %s", peers)
+		}
+
+		test.MakePost(t, rest, url(rest)+"/trust/"+clustertest.PeerID3.String(), []byte{}, &struct{}{})
+		test.MakeDelete(t, rest, url(rest)+"/trust/"+clustertest.PeerID3.String(), &struct{}{})
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIOperationLogEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var ops []api.ConsensusOp
+		test.MakeGet(t, rest, url(rest)+"/state/log?offset=0&limit=10", &ops)
+		if len(ops) != 2 || ops[0].Height != 2 || len(ops[0].Unpins) != 1 || len(ops[1].Pins) != 1 {
+			t.Errorf("unexpected operation log: %+v", ops)
+		}
+
+		errResp := api.Error{}
+		test.MakeGet(t, rest, url(rest)+"/state/log?limit=abc", &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected a bad request for an invalid limit")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
 func TestConnectGraphEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -298,38 +934,312 @@ func TestConnectGraphEndpoint(t *testing.T) {
 		if len(cg.ClustertoIPFS) != 3 {
 			t.Error("unexpected number of cluster to ipfs links")
 		}
-		// test a few link values
-		pid1 := clustertest.PeerID1
-		pid4 := clustertest.PeerID4
-		if _, ok := cg.ClustertoIPFS[pid1.String()]; !ok {
-			t.Fatal("missing cluster peer 1 from cluster to peer links map")
+		// test a few link values
+		pid1 := clustertest.PeerID1
+		pid4 := clustertest.PeerID4
+		if _, ok := cg.ClustertoIPFS[pid1.String()]; !ok {
+			t.Fatal("missing cluster peer 1 from cluster to peer links map")
+		}
+		if cg.ClustertoIPFS[pid1.String()] != pid4 {
+			t.Error("unexpected ipfs peer mapped to cluster peer 1 in graph")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIPinEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		// test regular post
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String(), []byte{}, &struct{}{})
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.ErrorCid.String(), []byte{}, &errResp)
+		if errResp.Message != clustertest.ErrBadCid.Error() {
+			t.Error("expected different error: ", errResp.Message)
+		}
+
+		test.MakePost(t, rest, url(rest)+"/pins/abcd", []byte{}, &errResp)
+		if errResp.Code != 400 {
+			t.Error("should fail with bad Cid")
+		}
+
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"?priority=high", []byte{}, &struct{}{})
+
+		errResp = api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"?priority=urgent", []byte{}, &errResp)
+		if errResp.Code != 400 {
+			t.Error("should fail with bad priority")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIDeepHealthEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var report api.HealthReport
+		test.MakeGet(t, rest, url(rest)+"/health/deep", &report)
+		if !report.Healthy || report.Peer != clustertest.PeerID1 {
+			t.Errorf("unexpected report: %+v", report)
+		}
+		if len(report.Components) != 4 || report.Components[0].Component != api.HealthComponentIPFS {
+			t.Errorf("unexpected components: %+v", report.Components)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIPinEditEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var pin api.Pin
+		body := []byte(`{"name": "renamed", "metadata": {"a": "b", "c": ""}}`)
+		test.MakePatch(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String(), body, &pin)
+		if !pin.Cid.Equals(clustertest.Cid1) || pin.Name != "renamed" {
+			t.Errorf("unexpected pin: %+v", pin)
+		}
+		if len(pin.Metadata) != 1 || pin.Metadata["a"] != "b" {
+			t.Errorf("unexpected metadata: %+v", pin.Metadata)
+		}
+
+		errResp := api.Error{}
+		test.MakePatch(t, rest, url(rest)+"/pins/"+clustertest.NotFoundCid.String(), body, &errResp)
+		if errResp.Code != http.StatusNotFound {
+			t.Error("editing an unknown pin should 404")
+		}
+
+		errResp = api.Error{}
+		test.MakePatch(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String(), []byte("{"), &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("a bad body should 400")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIPinBatchEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		items := []api.BatchItem{
+			{Action: api.BatchActionPin, Cid: clustertest.Cid1, Options: api.PinOptions{Name: "one"}},
+			{Action: api.BatchActionUnpin, Cid: clustertest.Cid2},
+			{Action: api.BatchActionPin, Cid: clustertest.ErrorCid},
+		}
+		body, err := json.Marshal(items)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var results []api.BatchResult
+		test.MakePost(t, rest, url(rest)+"/pins/batch", body, &results)
+		if len(results) != 3 {
+			t.Fatalf("expected 3 results: %+v", results)
+		}
+		if results[0].Error != "" || results[0].Pin.Name != "one" {
+			t.Errorf("unexpected pin result: %+v", results[0])
+		}
+		if results[1].Error != "" || results[1].Action != api.BatchActionUnpin || !results[1].Cid.Equals(clustertest.Cid2) {
+			t.Errorf("unexpected unpin result: %+v", results[1])
+		}
+		if results[2].Error != clustertest.ErrBadCid.Error() {
+			t.Errorf("expected an error: %+v", results[2])
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/batch", []byte("[]"), &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("an empty batch should 400")
+		}
+
+		errResp = api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/batch", []byte("{"), &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("a bad body should 400")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIReallocateEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		re := api.Reallocation{
+			Cids:                 []api.Cid{clustertest.Cid1, clustertest.Cid2},
+			ReplicationFactorMin: 1,
+			ReplicationFactorMax: 2,
+		}
+		body, err := json.Marshal(re)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var results []api.BatchResult
+		test.MakePost(t, rest, url(rest)+"/pins/reallocate", body, &results)
+		if len(results) != 2 {
+			t.Fatalf("expected 2 results: %+v", results)
+		}
+		for _, r := range results {
+			if r.Error != "" || r.Pin.ReplicationFactorMin != 1 || r.Pin.ReplicationFactorMax != 2 {
+				t.Errorf("unexpected result: %+v", r)
+			}
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/reallocate", []byte("{}"), &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("a reallocation without cids nor search should 400")
+		}
+
+		errResp = api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/reallocate", []byte("{"), &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("a bad body should 400")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIPinBatchEndpointScopes(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.BasicAuthCredentials = map[string]string{
+		validUserName: validUserPassword,
+	}
+	cfg.BasicAuthScopes = map[string][]common.Scope{
+		validUserName: {common.ScopePin},
+	}
+	rest := testAPIwithConfig(t, cfg, "scopes")
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		h := test.MakeHost(t, rest)
+		defer h.Close()
+		c := test.HTTPClient(t, h, test.IsHTTPS(url(rest)))
+		post := func(items []api.BatchItem) int {
+			body, err := json.Marshal(items)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, _ := http.NewRequest(http.MethodPost, url(rest)+"/pins/batch", bytes.NewReader(body))
+			req.SetBasicAuth(validUserName, validUserPassword)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		pin := api.BatchItem{Action: api.BatchActionPin, Cid: clustertest.Cid1}
+		unpin := api.BatchItem{Action: api.BatchActionUnpin, Cid: clustertest.Cid2}
+		if st := post([]api.BatchItem{pin}); st != http.StatusOK {
+			t.Errorf("expected 200 pinning: got %d", st)
 		}
-		if cg.ClustertoIPFS[pid1.String()] != pid4 {
-			t.Error("unexpected ipfs peer mapped to cluster peer 1 in graph")
+		if st := post([]api.BatchItem{pin, unpin}); st != http.StatusForbidden {
+			t.Errorf("expected 403 unpinning without the unpin scope: got %d", st)
 		}
 	}
 
 	test.BothEndpoints(t, tf)
 }
 
-func TestAPIPinEndpoint(t *testing.T) {
+func TestAPIPinOwnership(t *testing.T) {
 	ctx := context.Background()
-	rest := testAPI(t)
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.BasicAuthCredentials = map[string]string{
+		validUserName:   validUserPassword,
+		adminUserName:   adminUserPassword,
+		"TestOwner3":    "owner3",
+		invalidUserName: invalidUserPassword,
+	}
+	cfg.BasicAuthScopes = map[string][]common.Scope{
+		validUserName: {common.ScopeRead, common.ScopePin, common.ScopeUnpin},
+	}
+	cfg.EnforcePinOwnership = true
+	rest := testAPIwithConfig(t, cfg, "ownership")
 	defer rest.Shutdown(ctx)
 
 	tf := func(t *testing.T, url test.URLFunc) {
-		// test regular post
-		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String(), []byte{}, &struct{}{})
+		h := test.MakeHost(t, rest)
+		defer h.Close()
+		c := test.HTTPClient(t, h, test.IsHTTPS(url(rest)))
+		do := func(method, path, user, password string, resp interface{}) int {
+			t.Helper()
+			req, _ := http.NewRequest(method, url(rest)+path, nil)
+			req.SetBasicAuth(user, password)
+			httpResp, err := c.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer httpResp.Body.Close()
+			if resp != nil {
+				json.NewDecoder(httpResp.Body).Decode(resp)
+			}
+			return httpResp.StatusCode
+		}
 
-		errResp := api.Error{}
-		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.ErrorCid.String(), []byte{}, &errResp)
-		if errResp.Message != clustertest.ErrBadCid.Error() {
-			t.Error("expected different error: ", errResp.Message)
+		cid3 := "/pins/" + clustertest.Cid3.String()
+		if st := do("DELETE", cid3, validUserName, validUserPassword, nil); st != http.StatusForbidden {
+			t.Errorf("expected 403 unpinning a pin owned by others: got %d", st)
+		}
+		if st := do("DELETE", cid3+"?override=true", validUserName, validUserPassword, nil); st != http.StatusForbidden {
+			t.Errorf("expected 403 overriding ownership without the admin scope: got %d", st)
+		}
+		if st := do("DELETE", cid3, adminUserName, adminUserPassword, nil); st != http.StatusForbidden {
+			t.Errorf("expected 403 unpinning as admin without override: got %d", st)
+		}
+		if st := do("DELETE", cid3+"?override=true", adminUserName, adminUserPassword, nil); st != http.StatusOK {
+			t.Errorf("expected 200 overriding ownership as admin: got %d", st)
+		}
+		if st := do("DELETE", cid3, "TestOwner3", "owner3", nil); st != http.StatusOK {
+			t.Errorf("expected 200 unpinning as owner: got %d", st)
+		}
+		if st := do("DELETE", "/pins/"+clustertest.Cid1.String(), validUserName, validUserPassword, nil); st != http.StatusOK {
+			t.Errorf("expected 200 unpinning a pin without owner: got %d", st)
+		}
+		if st := do("POST", cid3+"/undo", validUserName, validUserPassword, nil); st != http.StatusForbidden {
+			t.Errorf("expected 403 undoing the unpin of a pin owned by others: got %d", st)
+		}
+		if st := do("POST", cid3+"/undo", "TestOwner3", "owner3", nil); st != http.StatusOK {
+			t.Errorf("expected 200 undoing the unpin as owner: got %d", st)
+		}
+		if st := do("POST", "/pins/"+clustertest.Cid1.String()+"/undo", validUserName, validUserPassword, nil); st != http.StatusOK {
+			t.Errorf("expected 200 undoing the unpin of a pin without owner: got %d", st)
+		}
+		if st := do("DELETE", "/groups/"+clustertest.Group1, invalidUserName, invalidUserPassword, nil); st != http.StatusForbidden {
+			t.Errorf("expected 403 unpinning a group with pins owned by others: got %d", st)
 		}
 
-		test.MakePost(t, rest, url(rest)+"/pins/abcd", []byte{}, &errResp)
-		if errResp.Code != 400 {
-			t.Error("should fail with bad Cid")
+		var pin api.Pin
+		if st := do("POST", "/pins/"+clustertest.Cid4.String(), validUserName, validUserPassword, &pin); st != http.StatusOK {
+			t.Fatalf("expected 200 pinning: got %d", st)
+		}
+		if pin.Owner != "user:"+validUserName {
+			t.Errorf("expected the pin to be owned by the user: %q", pin.Owner)
 		}
 	}
 
@@ -463,6 +1373,28 @@ func TestAPIUnpinEndpoint(t *testing.T) {
 	test.BothEndpoints(t, tf)
 }
 
+func TestAPIUndoUnpinEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var pin api.Pin
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"/undo", []byte{}, &pin)
+		if !pin.Cid.Equals(clustertest.Cid1) {
+			t.Error("expected the restored pin: ", pin)
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.ErrorCid.String()+"/undo", []byte{}, &errResp)
+		if errResp.Message != clustertest.ErrBadCid.Error() {
+			t.Error("expected different error: ", errResp.Message)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
 func TestAPIUnpinEndpointWithPath(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -552,6 +1484,80 @@ func TestAPIAllocationEndpoint(t *testing.T) {
 	test.BothEndpoints(t, tf)
 }
 
+func TestAPIGroupsEndpoints(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var groups []api.PinGroup
+		test.MakeGet(t, rest, url(rest)+"/groups", &groups)
+		if len(groups) != 1 || groups[0].Name != clustertest.Group1 || groups[0].Pins != 2 {
+			t.Errorf("unexpected groups: %+v", groups)
+		}
+
+		var pins []api.Pin
+		test.MakeStreamingGet(t, rest, url(rest)+"/groups/"+clustertest.Group1+"/pins", &pins, false)
+		if len(pins) != 2 || !pins[0].Cid.Equals(clustertest.Cid1) || pins[1].Group != clustertest.Group1 {
+			t.Errorf("unexpected group pins: %+v", pins)
+		}
+
+		var results []api.BatchResult
+		test.MakePost(t, rest, url(rest)+"/groups/"+clustertest.Group1+"/replication?replication-min=1&replication-max=2", []byte{}, &results)
+		if len(results) != 2 || results[0].Pin.ReplicationFactorMin != 1 || results[1].Pin.ReplicationFactorMax != 2 {
+			t.Errorf("unexpected replication results: %+v", results)
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/groups/"+clustertest.Group1+"/replication?replication-min=abc", []byte{}, &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("an invalid replication factor should 400")
+		}
+
+		results = nil
+		test.MakeDelete(t, rest, url(rest)+"/groups/"+clustertest.Group1, &results)
+		if len(results) != 2 || results[0].Action != api.BatchActionUnpin {
+			t.Errorf("unexpected unpin results: %+v", results)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPISearchPinsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var pins []api.Pin
+		test.MakeStreamingGet(t, rest, url(rest)+"/pins/search?name=foo&meta[hello]=world", &pins, false)
+		if len(pins) != 1 || !pins[0].Cid.Equals(clustertest.Cid1) {
+			t.Errorf("unexpected search results: %+v", pins)
+		}
+
+		pins = nil
+		test.MakeStreamingGet(t, rest, url(rest)+"/pins/search?meta[hello]=", &pins, false)
+		if len(pins) != 1 {
+			t.Errorf("expected a pin with the hello key: %+v", pins)
+		}
+
+		pins = nil
+		test.MakeStreamingGet(t, rest, url(rest)+"/pins/search?meta[hello]=nobody", &pins, false)
+		if len(pins) != 0 {
+			t.Errorf("expected no results: %+v", pins)
+		}
+
+		errResp := api.Error{}
+		test.MakeGet(t, rest, url(rest)+"/pins/search", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("a search without criteria should 400")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
 func TestAPIMetricsEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -608,6 +1614,166 @@ func TestAPIAlertsEndpoint(t *testing.T) {
 	test.BothEndpoints(t, tf)
 }
 
+func TestAPIEventsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	c := test.HTTPClient(t, nil, false)
+	events := func(t *testing.T, query string) []api.Event {
+		t.Helper()
+		httpResp, err := c.Get(test.HTTPURL(rest) + "/events" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer httpResp.Body.Close()
+		if ctype := httpResp.Header.Get("Content-Type"); ctype != "text/event-stream" {
+			t.Errorf("unexpected content type: %s", ctype)
+		}
+
+		// The mock sends a few events and finishes.
+		body, err := io.ReadAll(httpResp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var evs []api.Event
+		for _, msg := range strings.Split(strings.TrimSpace(string(body)), "\n\n") {
+			lines := strings.Split(msg, "\n")
+			if len(lines) != 2 || !strings.HasPrefix(lines[0], "event: ") || !strings.HasPrefix(lines[1], "data: ") {
+				t.Fatalf("unexpected message: %q", msg)
+			}
+			var ev api.Event
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &ev); err != nil {
+				t.Fatal(err)
+			}
+			if ev.Type != strings.TrimPrefix(lines[0], "event: ") {
+				t.Errorf("event name does not match type: %q", msg)
+			}
+			evs = append(evs, ev)
+		}
+		return evs
+	}
+
+	evs := events(t, "")
+	if len(evs) != 2 {
+		t.Fatalf("expected 2 events: %+v", evs)
+	}
+	if evs[0].Type != api.EventPinStatus || evs[0].PinInfo == nil || !evs[0].PinInfo.Cid.Equals(clustertest.Cid1) {
+		t.Errorf("unexpected pin status event: %+v", evs[0])
+	}
+	if evs[1].Type != api.EventPeerJoined || evs[1].Peer != clustertest.PeerID2 {
+		t.Errorf("unexpected peer event: %+v", evs[1])
+	}
+
+	evs = events(t, "?types=peer_joined,alert")
+	if len(evs) != 1 || evs[0].Type != api.EventPeerJoined {
+		t.Errorf("expected only the peer event: %+v", evs)
+	}
+
+	errResp := api.Error{}
+	test.MakeGet(t, rest, test.HTTPURL(rest)+"/events?types=something", &errResp)
+	if errResp.Code != http.StatusBadRequest {
+		t.Error("an invalid type should 400")
+	}
+}
+
+func TestAPIListsNDJSON(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	c := test.HTTPClient(t, nil, false)
+	get := func(t *testing.T, path string, accept string) (string, int) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, test.HTTPURL(rest)+path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		httpResp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer httpResp.Body.Close()
+
+		n := 0
+		dec := json.NewDecoder(httpResp.Body)
+		for dec.More() {
+			var item map[string]interface{}
+			if err := dec.Decode(&item); err != nil {
+				t.Fatal(err)
+			}
+			n++
+		}
+		if e := httpResp.Trailer.Get("X-Stream-Error"); e != "" {
+			t.Errorf("unexpected stream error: %s", e)
+		}
+		return httpResp.Header.Get("Content-Type"), n
+	}
+
+	for _, path := range []string{"/allocations", "/pins", "/pins?cids=" + clustertest.Cid1.String()} {
+		ctype, n := get(t, path, "application/x-ndjson")
+		if ctype != "application/x-ndjson" {
+			t.Errorf("%s: unexpected content type: %s", path, ctype)
+		}
+		if n == 0 {
+			t.Errorf("%s: expected some items", path)
+		}
+
+		ctype, _ = get(t, path, "")
+		if ctype != "application/json" {
+			t.Errorf("%s: unexpected content type: %s", path, ctype)
+		}
+	}
+}
+
+func TestAPIStatusAllFiltered(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var resp []api.GlobalPinInfo
+		test.MakeStreamingGet(t, rest, url(rest)+"/pins?name=bbb", &resp, false)
+		if len(resp) != 1 || !resp[0].Cid.Equals(clustertest.Cid2) {
+			t.Errorf("expected only Cid2: %+v", resp)
+		}
+
+		resp = nil
+		test.MakeStreamingGet(t, rest, url(rest)+"/pins?meta-ccc=3c", &resp, false)
+		if len(resp) != 1 || !resp[0].Cid.Equals(clustertest.Cid3) {
+			t.Errorf("expected only Cid3: %+v", resp)
+		}
+
+		resp = nil
+		test.MakeStreamingGet(t, rest, url(rest)+"/pins?cid_prefix="+clustertest.Cid1.String(), &resp, false)
+		if len(resp) != 1 || !resp[0].Cid.Equals(clustertest.Cid1) {
+			t.Errorf("expected only Cid1: %+v", resp)
+		}
+
+		resp = nil
+		test.MakeStreamingGet(t, rest, url(rest)+"/pins?group="+clustertest.Group1, &resp, false)
+		if len(resp) != 2 || !resp[0].Cid.Equals(clustertest.Cid1) || !resp[1].Cid.Equals(clustertest.Cid3) {
+			t.Errorf("expected Cid1 and Cid3: %+v", resp)
+		}
+
+		resp = nil
+		test.MakeStreamingGet(t, rest, url(rest)+"/pins?offset=1&limit=1", &resp, false)
+		if len(resp) != 1 || !resp[0].Cid.Equals(clustertest.Cid2) {
+			t.Errorf("expected the second item only: %+v", resp)
+		}
+
+		for _, query := range []string{"limit=-1", "offset=a", "allocation=abc", "after=abc", "local=true&name=aaa"} {
+			errResp := api.Error{}
+			test.MakeStreamingGet(t, rest, url(rest)+"/pins?"+query, &errResp, false)
+			if errResp.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected 400, got %+v", query, errResp)
+			}
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
 func TestAPIStatusAllEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -776,6 +1942,48 @@ func TestAPIRecoverEndpoint(t *testing.T) {
 	test.BothEndpoints(t, tf)
 }
 
+func TestAPIVerifyPinEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var resp api.PinVerification
+		test.MakeGet(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"/verify", &resp)
+		if !resp.Cid.Equals(clustertest.Cid1) || !resp.OK() {
+			t.Errorf("expected a successful verification of Cid1: %+v", resp)
+		}
+		if resp.Checked != resp.Blocks {
+			t.Error("expected all blocks to be checked")
+		}
+
+		resp = api.PinVerification{}
+		test.MakeGet(t, rest, url(rest)+"/pins/"+clustertest.Cid2.String()+"/verify?sample=1", &resp)
+		if resp.OK() || len(resp.Peers) != 2 {
+			t.Fatalf("expected a failed verification of Cid2: %+v", resp)
+		}
+		if resp.Checked != 1 {
+			t.Error("expected a single block to be checked")
+		}
+		if missing := resp.Peers[1].Missing; len(missing) != 1 || !missing[0].Equals(clustertest.Cid4) {
+			t.Error("expected Cid4 to be missing on the second peer")
+		}
+
+		errResp := api.Error{}
+		test.MakeGet(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"/verify?sample=-1", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request for negative samples")
+		}
+		errResp = api.Error{}
+		test.MakeGet(t, rest, url(rest)+"/pins/"+clustertest.Cid4.String()+"/verify", &errResp)
+		if errResp.Code != http.StatusNotFound {
+			t.Error("expected a not found error for unknown pins")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
 func TestAPIRecoverAllEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -798,6 +2006,28 @@ func TestAPIRecoverAllEndpoint(t *testing.T) {
 	test.BothEndpoints(t, tf)
 }
 
+func TestAPIRecoverPermanentEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var resp []api.GlobalPinInfo
+		test.MakeStreamingPost(t, rest, url(rest)+"/pins/recover/permanent?local=true", nil, "", &resp)
+		if len(resp) != 0 {
+			t.Fatal("bad response length")
+		}
+
+		var resp1 []api.GlobalPinInfo
+		test.MakeStreamingPost(t, rest, url(rest)+"/pins/recover/permanent", nil, "", &resp1)
+		if len(resp1) != 1 || !resp1[0].Cid.Equals(clustertest.Cid3) {
+			t.Fatalf("unexpected response: %+v", resp1)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
 func TestAPIIPFSGCEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -840,6 +2070,81 @@ func TestAPIIPFSGCEndpoint(t *testing.T) {
 		var resp1 api.GlobalRepoGC
 		test.MakePost(t, rest, url(rest)+"/ipfs/gc", []byte{}, &resp1)
 		testGlobalRepoGC(t, resp1)
+
+		var resp2 api.GlobalRepoGC
+		test.MakePost(t, rest, url(rest)+"/ipfs/gc?concurrency=2", []byte{}, &resp2)
+		testGlobalRepoGC(t, resp2)
+		for _, repoGC := range resp2.PeerMap {
+			if repoGC.Reclaimed != 1024 {
+				t.Errorf("expected the reclaimed space: %d", repoGC.Reclaimed)
+			}
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/ipfs/gc?concurrency=0", []byte{}, &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("an invalid concurrency should 400")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIPinTrackerConcurrencyEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		for _, q := range []string{"", "?local=true"} {
+			var concs []api.PinTrackerConcurrency
+			test.MakeGet(t, rest, url(rest)+"/pintracker/concurrency"+q, &concs)
+			if len(concs) != 1 || concs[0].Peer != clustertest.PeerID1 || concs[0].Pins != 10 {
+				t.Errorf("unexpected concurrency: %+v", concs)
+			}
+
+			concs = nil
+			test.MakePost(t, rest, url(rest)+"/pintracker/concurrency?pins=3&auto=true"+strings.Replace(q, "?", "&", 1), []byte{}, &concs)
+			if len(concs) != 1 || concs[0].Pins != 3 || concs[0].MaxPins != 3 || concs[0].Unpins != 1 || !concs[0].Auto {
+				t.Errorf("unexpected concurrency after setting it: %+v", concs)
+			}
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pintracker/concurrency?pins=-1", []byte{}, &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("a negative concurrency should 400")
+		}
+
+		errResp = api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pintracker/concurrency?auto=maybe", []byte{}, &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("an invalid auto value should 400")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIPinTrackerPauseEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		for _, q := range []string{"", "?local=true"} {
+			var concs []api.PinTrackerConcurrency
+			test.MakePost(t, rest, url(rest)+"/pintracker/pause"+q, []byte{}, &concs)
+			if len(concs) != 1 || concs[0].Peer != clustertest.PeerID1 || !concs[0].Paused {
+				t.Errorf("expected a paused pintracker: %+v", concs)
+			}
+
+			concs = nil
+			test.MakePost(t, rest, url(rest)+"/pintracker/resume"+q, []byte{}, &concs)
+			if len(concs) != 1 || concs[0].Paused {
+				t.Errorf("expected a resumed pintracker: %+v", concs)
+			}
+		}
 	}
 
 	test.BothEndpoints(t, tf)
@@ -36,6 +36,29 @@ import (
 
 var logger = logging.Logger("apitypes")
 
+// ErrInsufficientAllocations is returned when a pin cannot be allocated
+// because there are not enough peers to satisfy its minimum replication
+// factor.
+var ErrInsufficientAllocations = errors.New("not enough peers to allocate CID")
+
+// IsErrInsufficientAllocations returns true if the given error was caused by
+// ErrInsufficientAllocations. Since errors are transmitted as strings over
+// RPC, it looks at the error message.
+func IsErrInsufficientAllocations(err error) bool {
+	return err != nil && strings.Contains(err.Error(), ErrInsufficientAllocations.Error())
+}
+
+// ErrLeaderless is returned by consensus components which do not have a
+// leader.
+var ErrLeaderless = errors.New("consensus component does not provide a leader")
+
+// IsErrLeaderless returns true if the given error was caused by
+// ErrLeaderless. It looks at the error message, like
+// IsErrInsufficientAllocations.
+func IsErrLeaderless(err error) bool {
+	return err != nil && strings.Contains(err.Error(), ErrLeaderless.Error())
+}
+
 var unixZero = time.Unix(0, 0)
 
 func init() {
@@ -77,11 +100,14 @@ const (
 	// The item is in the state and should be pinned, but
 	// it is however not pinned and not queued/pinning.
 	TrackerStatusUnexpectedlyUnpinned
+	// Pinning the item failed too many times and it is not retried
+	// automatically anymore.
+	TrackerStatusPinErrorPermanent
 )
 
 // Composite TrackerStatus.
 const (
-	TrackerStatusError  = TrackerStatusClusterError | TrackerStatusPinError | TrackerStatusUnpinError
+	TrackerStatusError  = TrackerStatusClusterError | TrackerStatusPinError | TrackerStatusUnpinError | TrackerStatusPinErrorPermanent
 	TrackerStatusQueued = TrackerStatusPinQueued | TrackerStatusUnpinQueued
 )
 
@@ -104,6 +130,7 @@ var trackerStatusString = map[TrackerStatus]string{
 	TrackerStatusQueued:               "queued",
 	TrackerStatusSharded:              "sharded",
 	TrackerStatusUnexpectedlyUnpinned: "unexpectedly_unpinned",
+	TrackerStatusPinErrorPermanent:    "pin_error_permanent",
 }
 
 // values autofilled in init()
@@ -337,6 +364,330 @@ func (c Cid) Equals(c2 Cid) bool {
 	return c.Cid.Equals(c2.Cid)
 }
 
+// StatusAllFilter selects the items returned by StatusAll by their tracker
+// status, by the time at which they were pinned and by other properties
+// of the pins. Bounds are inclusive and zero values do not filter.
+//
+// The results can be paginated with Offset and Limit, or by giving the last
+// Cid of the previous page in After. Paginated results follow the order
+// of the shared state.
+type StatusAllFilter struct {
+	Status        TrackerStatus     `json:"status" codec:"s,omitempty"`
+	CreatedAfter  time.Time         `json:"created_after" codec:"a,omitempty"`
+	CreatedBefore time.Time         `json:"created_before" codec:"b,omitempty"`
+	CidPrefix     string            `json:"cid_prefix,omitempty" codec:"p,omitempty"`
+	Name          string            `json:"name,omitempty" codec:"n,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty" codec:"m,omitempty"`
+	Allocation    peer.ID           `json:"allocation,omitempty" codec:"l,omitempty"`
+	Offset        int               `json:"offset,omitempty" codec:"o,omitempty"`
+	Limit         int               `json:"limit,omitempty" codec:"i,omitempty"`
+	After         Cid               `json:"after,omitempty" codec:"r,omitempty"`
+	Group         string            `json:"group,omitempty" codec:"g,omitempty"`
+}
+
+// MatchCreated returns true if the given creation time is within the bounds
+// of the filter.
+func (f StatusAllFilter) MatchCreated(created time.Time) bool {
+	if !f.CreatedAfter.IsZero() && created.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && created.After(f.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// Match returns true if the given PinInfo matches all the criteria of the
+// filter, except the tracker status, which is checked by the trackers. Pins
+// allocated everywhere match any Allocation.
+func (f StatusAllFilter) Match(pi PinInfo) bool {
+	if !f.MatchCreated(pi.Created) {
+		return false
+	}
+	if f.CidPrefix != "" && !strings.HasPrefix(pi.Cid.String(), f.CidPrefix) {
+		return false
+	}
+	if f.Name != "" && pi.Name != f.Name {
+		return false
+	}
+	if f.Group != "" && pi.Group != f.Group {
+		return false
+	}
+	for k, v := range f.Metadata {
+		if pi.Metadata[k] != v {
+			return false
+		}
+	}
+	if f.Allocation == "" || len(pi.Allocations) == 0 {
+		return true
+	}
+	for _, p := range pi.Allocations {
+		if p == f.Allocation {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPin returns true if the given pin matches all the criteria of the
+// filter which do not depend on its status.
+func (f StatusAllFilter) MatchPin(p Pin) bool {
+	return f.Match(PinInfo{
+		Cid:         p.Cid,
+		Name:        p.Name,
+		Allocations: p.Allocations,
+		Created:     p.Timestamp,
+		Metadata:    p.Metadata,
+		Group:       p.Group,
+	})
+}
+
+// HasCriteria returns true when the filter selects items by anything other
+// than their tracker status, or asks for a page.
+func (f StatusAllFilter) HasCriteria() bool {
+	return !f.CreatedAfter.IsZero() ||
+		!f.CreatedBefore.IsZero() ||
+		f.CidPrefix != "" ||
+		f.Name != "" ||
+		f.Group != "" ||
+		len(f.Metadata) > 0 ||
+		f.Allocation != "" ||
+		f.Paginated()
+}
+
+// Paginated returns true when the filter asks for a page of the results.
+func (f StatusAllFilter) Paginated() bool {
+	return f.Offset > 0 || f.Limit > 0 || f.After.Defined()
+}
+
+// PinSearch selects pins by their name and metadata. Pins match when they
+// have the given Name, if set, and every given Metadata key with the given
+// value. An empty value matches any value of the key.
+type PinSearch struct {
+	Name     string            `json:"name,omitempty" codec:"n,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty" codec:"m,omitempty"`
+}
+
+// IsEmpty returns true when the search has no criteria.
+func (s PinSearch) IsEmpty() bool {
+	return s.Name == "" && len(s.Metadata) == 0
+}
+
+// Match returns true if the given pin matches the search.
+func (s PinSearch) Match(p Pin) bool {
+	if s.Name != "" && p.Name != s.Name {
+		return false
+	}
+	for k, v := range s.Metadata {
+		pv, ok := p.Metadata[k]
+		if !ok || (v != "" && pv != v) {
+			return false
+		}
+	}
+	return true
+}
+
+// ToQuery returns the search as query arguments: "name" and one
+// "meta[key]" argument per metadata key.
+func (s PinSearch) ToQuery() string {
+	q := url.Values{}
+	if s.Name != "" {
+		q.Set("name", s.Name)
+	}
+	for k, v := range s.Metadata {
+		if k == "" {
+			continue
+		}
+		q.Set("meta["+k+"]", v)
+	}
+	return q.Encode()
+}
+
+// FromQuery is the inverse of ToQuery().
+func (s *PinSearch) FromQuery(q url.Values) {
+	s.Name = q.Get("name")
+	for k := range q {
+		if !strings.HasPrefix(k, "meta[") || !strings.HasSuffix(k, "]") {
+			continue
+		}
+		metaKey := k[len("meta[") : len(k)-1]
+		if metaKey == "" {
+			continue
+		}
+		if s.Metadata == nil {
+			s.Metadata = make(map[string]string)
+		}
+		s.Metadata[metaKey] = q.Get(k)
+	}
+}
+
+// Actions supported in BatchItems.
+const (
+	BatchActionPin   = "pin"
+	BatchActionUnpin = "unpin"
+)
+
+// BatchItem is one of the operations of a pin batch.
+type BatchItem struct {
+	Action  string     `json:"action" codec:"a"`
+	Cid     Cid        `json:"cid" codec:"c"`
+	Options PinOptions `json:"options" codec:"o,omitempty"`
+}
+
+// BatchResult is the outcome of a BatchItem. Pin is the pin that was
+// submitted, or removed when unpinning. Error is set when the item failed.
+type BatchResult struct {
+	Action string `json:"action" codec:"a"`
+	Cid    Cid    `json:"cid" codec:"c"`
+	Pin    Pin    `json:"pin" codec:"p,omitempty"`
+	Error  string `json:"error,omitempty" codec:"e,omitempty"`
+}
+
+// OpLogOptions selects a page of the consensus operation log, which is
+// sorted from the most recent operation.
+type OpLogOptions struct {
+	Offset int `json:"offset,omitempty" codec:"o,omitempty"`
+	Limit  int `json:"limit,omitempty" codec:"l,omitempty"`
+}
+
+// ToQuery returns the options as query arguments: "offset" and "limit".
+func (o OpLogOptions) ToQuery() string {
+	q := url.Values{}
+	if o.Offset > 0 {
+		q.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return q.Encode()
+}
+
+// FromQuery is the inverse of ToQuery().
+func (o *OpLogOptions) FromQuery(q url.Values) error {
+	for param, dst := range map[string]*int{"offset": &o.Offset, "limit": &o.Limit} {
+		v := q.Get(param)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid %s value: %s", param, v)
+		}
+		*dst = n
+	}
+	return nil
+}
+
+// ConsensusOp is an update to the shared state, as recorded by the
+// consensus layer. Pins and unpins submitted in the same batch are part of
+// the same ConsensusOp. The ID and the Height are the CID and the priority
+// of the delta in CRDT, and the log index in Raft, where the Timestamp is
+// when the update was appended to the log. Pins carry their own owner and
+// timestamp.
+type ConsensusOp struct {
+	ID        string    `json:"id" codec:"i"`
+	Height    uint64    `json:"height" codec:"h,omitempty"`
+	Timestamp time.Time `json:"timestamp,omitempty" codec:"t,omitempty"`
+	Pins      []Pin     `json:"pins,omitempty" codec:"p,omitempty"`
+	Unpins    []Cid     `json:"unpins,omitempty" codec:"u,omitempty"`
+}
+
+// PinEdit describes changes to the name, group and metadata of an existing
+// pin. The name and group are only changed when set, and an empty group
+// removes the pin from its group. Metadata keys are added or replaced, or
+// removed when their value is empty.
+type PinEdit struct {
+	Cid      Cid               `json:"cid" codec:"c"`
+	Name     *string           `json:"name,omitempty" codec:"n,omitempty"`
+	Group    *string           `json:"group,omitempty" codec:"g,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty" codec:"m,omitempty"`
+}
+
+// Reallocation describes a change of the replication factors of existing
+// pins: those with the given CIDs and those matching the search, when it is
+// not empty. Factors set to 0 take the cluster defaults.
+type Reallocation struct {
+	Cids                 []Cid     `json:"cids,omitempty" codec:"c,omitempty"`
+	Search               PinSearch `json:"search,omitempty" codec:"s,omitempty"`
+	ReplicationFactorMin int       `json:"replication_factor_min" codec:"rn,omitempty"`
+	ReplicationFactorMax int       `json:"replication_factor_max" codec:"rx,omitempty"`
+}
+
+// PinGroup describes a named group of pins, as set in their Group option.
+// Groups allow to handle datasets made of many independent pins together.
+type PinGroup struct {
+	Name string `json:"name" codec:"n"`
+	Pins int    `json:"pins" codec:"p,omitempty"`
+}
+
+// Components of a cluster peer checked in HealthReports.
+const (
+	HealthComponentIPFS      = "ipfs"
+	HealthComponentConsensus = "consensus"
+	HealthComponentDatastore = "datastore"
+	HealthComponentMonitor   = "monitor"
+)
+
+// ComponentHealth is the result of checking a component of a cluster peer.
+// Latency is the time that the check took, in milliseconds. Error is set
+// when the component is not healthy.
+type ComponentHealth struct {
+	Component string  `json:"component" codec:"c"`
+	Healthy   bool    `json:"healthy" codec:"h,omitempty"`
+	Latency   float64 `json:"latency_ms" codec:"l,omitempty"`
+	Error     string  `json:"error,omitempty" codec:"e,omitempty"`
+}
+
+// HealthReport contains the results of checking every component of a
+// cluster peer. The peer is healthy when all its components are.
+type HealthReport struct {
+	Peer       peer.ID           `json:"peer" codec:"p"`
+	Healthy    bool              `json:"healthy" codec:"h,omitempty"`
+	Components []ComponentHealth `json:"components" codec:"c,omitempty"`
+}
+
+// PinVerifyRequest asks to verify that the blocks of a pinned DAG are
+// stored by the peers the pin is allocated to. Sample is the number of
+// randomly chosen blocks checked on every peer, or 0 to check all of them.
+type PinVerifyRequest struct {
+	Cid    Cid `json:"cid" codec:"c"`
+	Sample int `json:"sample,omitempty" codec:"s,omitempty"`
+}
+
+// PeerVerification lists the blocks of a pinned DAG which a peer does not
+// store. Error is set when the peer could not be checked.
+type PeerVerification struct {
+	Peer     peer.ID `json:"peer" codec:"p"`
+	Peername string  `json:"peername,omitempty" codec:"pn,omitempty"`
+	Missing  []Cid   `json:"missing,omitempty" codec:"m,omitempty"`
+	Error    string  `json:"error,omitempty" codec:"e,omitempty"`
+}
+
+// OK returns true when the peer was checked and stores all the blocks.
+func (pv PeerVerification) OK() bool {
+	return pv.Error == "" && len(pv.Missing) == 0
+}
+
+// PinVerification is the result of a PinVerifyRequest. Blocks is the
+// number of blocks in the DAG and Checked the number of them checked on
+// every peer.
+type PinVerification struct {
+	Cid     Cid                `json:"cid" codec:"c"`
+	Blocks  int                `json:"blocks" codec:"b,omitempty"`
+	Checked int                `json:"checked" codec:"k,omitempty"`
+	Peers   []PeerVerification `json:"peers" codec:"p,omitempty"`
+}
+
+// OK returns true when all the peers store all the checked blocks.
+func (pv PinVerification) OK() bool {
+	for _, p := range pv.Peers {
+		if !p.OK() {
+			return false
+		}
+	}
+	return true
+}
+
 // IPFSPinInfo represents an IPFS Pin, which only has a CID and type.
 // Its JSON form is what IPFS returns when querying a pinset.
 type IPFSPinInfo struct {
@@ -353,6 +704,7 @@ type GlobalPinInfo struct {
 	Origins     []Multiaddr       `json:"origins" codec:"g,omitempty"`
 	Created     time.Time         `json:"created" codec:"t,omitempty"`
 	Metadata    map[string]string `json:"metadata" codec:"m,omitempty"`
+	Group       string            `json:"group,omitempty" codec:"gr,omitempty"`
 
 	// https://github.com/golang/go/issues/28827
 	// Peer IDs are of string Kind(). We can't use peer IDs here
@@ -379,6 +731,7 @@ func (gpi *GlobalPinInfo) Add(pi PinInfo) {
 		gpi.Origins = pi.Origins
 		gpi.Created = pi.Created
 		gpi.Metadata = pi.Metadata
+		gpi.Group = pi.Group
 	}
 
 	if gpi.PeerMap == nil {
@@ -415,6 +768,20 @@ type PinInfoShort struct {
 	Error         string        `json:"error" codec:"e,omitempty"`
 	AttemptCount  int           `json:"attempt_count" codec:"a,omitempty"`
 	PriorityPin   bool          `json:"priority_pin" codec:"y,omitempty"`
+	// NextRetry is when a failed operation will be retried
+	// automatically. It is zero when no retry is scheduled.
+	NextRetry time.Time `json:"next_retry" codec:"nr,omitempty"`
+	// Progress is set while pinning, once IPFS has reported some.
+	Progress *PinProgress `json:"progress,omitempty" codec:"pg,omitempty"`
+}
+
+// PinProgress is the progress of an ongoing pin in IPFS. Bytes is
+// estimated from the number of blocks fetched. Size is the cumulative size
+// of the DAG, or 0 when it is not known.
+type PinProgress struct {
+	Blocks int    `json:"blocks" codec:"b,omitempty"`
+	Bytes  uint64 `json:"bytes" codec:"by,omitempty"`
+	Size   uint64 `json:"size" codec:"s,omitempty"`
 }
 
 // String provides a string representation of PinInfoShort.
@@ -427,6 +794,10 @@ func (pis PinInfoShort) String() string {
 	fmt.Fprintf(&b, "error: %s\n", pis.Error)
 	fmt.Fprintf(&b, "attemptCount: %d\n", pis.AttemptCount)
 	fmt.Fprintf(&b, "priority: %t\n", pis.PriorityPin)
+	fmt.Fprintf(&b, "nextRetry: %s\n", pis.NextRetry)
+	if p := pis.Progress; p != nil {
+		fmt.Fprintf(&b, "progress: %d blocks, %d/%d bytes\n", p.Blocks, p.Bytes, p.Size)
+	}
 	return b.String()
 }
 
@@ -440,6 +811,7 @@ type PinInfo struct {
 	Origins     []Multiaddr       `json:"origins" codec:"g,omitempty"`
 	Created     time.Time         `json:"created" codec:"t,omitempty"`
 	Metadata    map[string]string `json:"metadata" codec:"md,omitempty"`
+	Group       string            `json:"group,omitempty" codec:"gr,omitempty"`
 
 	PinInfoShort
 }
@@ -738,6 +1110,61 @@ func (pm *PinMode) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// PinPriority is a PinOption that indicates how soon a pin should be
+// pinned by the pin tracker, relative to other pins in its queue.
+type PinPriority int
+
+// PinPriority values. High priority pins are pinned before any other
+// queued pin, while low priority ones, meant for bulk backfills, are only
+// pinned when nothing else is queued.
+const (
+	PinPriorityLow    PinPriority = -1
+	PinPriorityNormal PinPriority = 0
+	PinPriorityHigh   PinPriority = 1
+)
+
+// PinPriorityFromString converts a string to PinPriority.
+func PinPriorityFromString(s string) (PinPriority, error) {
+	switch s {
+	case "low":
+		return PinPriorityLow, nil
+	case "normal", "":
+		return PinPriorityNormal, nil
+	case "high":
+		return PinPriorityHigh, nil
+	default:
+		return PinPriorityNormal, fmt.Errorf("unknown pin priority: %s", s)
+	}
+}
+
+// String returns a human-readable value for PinPriority.
+func (pp PinPriority) String() string {
+	switch {
+	case pp < PinPriorityNormal:
+		return "low"
+	case pp > PinPriorityNormal:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// MarshalJSON converts the PinPriority into a readable string in JSON.
+func (pp PinPriority) MarshalJSON() ([]byte, error) {
+	return json.Marshal(pp.String())
+}
+
+// UnmarshalJSON takes a JSON value and parses it into PinPriority.
+func (pp *PinPriority) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	*pp, err = PinPriorityFromString(s)
+	return err
+}
+
 // ToPinDepth converts the Mode to Depth.
 func (pm PinMode) ToPinDepth() PinDepth {
 	switch pm {
@@ -751,7 +1178,13 @@ func (pm PinMode) ToPinDepth() PinDepth {
 	}
 }
 
-// PinOptions wraps user-defined options for Pins
+// PinOptions wraps user-defined options for Pins. Follow is an IPNS path
+// (/ipns/<name>, where the name can be a DNSLink domain) which the cluster
+// resolves regularly: when it points to a different CID, the pin is updated
+// to it.
+//
+// Owner is the identity which created the pin. It is set by the cluster
+// and the APIs, never from the query arguments given by clients.
 type PinOptions struct {
 	ReplicationFactorMin int               `json:"replication_factor_min" codec:"rn,omitempty"`
 	ReplicationFactorMax int               `json:"replication_factor_max" codec:"rx,omitempty"`
@@ -763,6 +1196,10 @@ type PinOptions struct {
 	Metadata             map[string]string `json:"metadata" codec:"m,omitempty"`
 	PinUpdate            Cid               `json:"pin_update,omitempty" codec:"pu,omitempty"`
 	Origins              []Multiaddr       `json:"origins" codec:"g,omitempty"`
+	Priority             PinPriority       `json:"priority,omitempty" codec:"pr,omitempty"`
+	Group                string            `json:"group,omitempty" codec:"gr,omitempty"`
+	Follow               string            `json:"follow,omitempty" codec:"fl,omitempty"`
+	Owner                string            `json:"owner,omitempty" codec:"ow,omitempty"`
 }
 
 // Equals returns true if two PinOption objects are equivalent. po and po2 may
@@ -788,6 +1225,22 @@ func (po PinOptions) Equals(po2 PinOptions) bool {
 		return false
 	}
 
+	if po.Priority != po2.Priority {
+		return false
+	}
+
+	if po.Group != po2.Group {
+		return false
+	}
+
+	if po.Follow != po2.Follow {
+		return false
+	}
+
+	if po.Owner != po2.Owner {
+		return false
+	}
+
 	lenAllocs1 := len(po.UserAllocations)
 	lenAllocs2 := len(po2.UserAllocations)
 	if lenAllocs1 != lenAllocs2 {
@@ -871,6 +1324,18 @@ func (po PinOptions) ToQuery() (string, error) {
 		q.Set("origins", strings.Join(origins, ","))
 	}
 
+	if po.Priority != PinPriorityNormal {
+		q.Set("priority", po.Priority.String())
+	}
+
+	if po.Group != "" {
+		q.Set("group", po.Group)
+	}
+
+	if po.Follow != "" {
+		q.Set("follow", po.Follow)
+	}
+
 	return q.Encode(), nil
 }
 
@@ -966,6 +1431,21 @@ func (po *PinOptions) FromQuery(q url.Values) error {
 		po.Origins = maOrigins
 	}
 
+	priority, err := PinPriorityFromString(q.Get("priority"))
+	if err != nil {
+		return err
+	}
+	po.Priority = priority
+
+	po.Group = q.Get("group")
+
+	if v := q.Get("follow"); v != "" {
+		if !strings.HasPrefix(v, "/ipns/") {
+			return errors.New("follow must be an /ipns/ path")
+		}
+		po.Follow = v
+	}
+
 	return nil
 }
 
@@ -1138,6 +1618,10 @@ func (pin Pin) ProtoMarshal() ([]byte, error) {
 		// UserAllocations:      pin.UserAllocations,
 		Origins:        origins,
 		SortedMetadata: sortedMetadata,
+		Priority:       int32(pin.Priority),
+		Group:          pin.Group,
+		Follow:         pin.Follow,
+		Owner:          pin.Owner,
 	}
 
 	pbPin := &pb.Pin{
@@ -1201,6 +1685,10 @@ func (pin *Pin) ProtoUnmarshal(data []byte) error {
 	pin.ReplicationFactorMax = int(opts.GetReplicationFactorMax())
 	pin.Name = opts.GetName()
 	pin.ShardSize = opts.GetShardSize()
+	pin.Priority = PinPriority(opts.GetPriority())
+	pin.Group = opts.GetGroup()
+	pin.Follow = opts.GetFollow()
+	pin.Owner = opts.GetOwner()
 
 	// pin.UserAllocations = opts.GetUserAllocations()
 	exp := opts.GetExpireAt()
@@ -1295,6 +1783,24 @@ func (pin Pin) IsRemotePin(pid peer.ID) bool {
 	return true
 }
 
+// peerOwnerPrefix prefixes the Owner of the pins created by cluster peers.
+const peerOwnerPrefix = "peer:"
+
+// PeerOwner returns the Owner recorded on the pins created by the given
+// peer on behalf of clients which were not authenticated.
+func PeerOwner(pid peer.ID) string {
+	return peerOwnerPrefix + pid.String()
+}
+
+// ModifiableBy returns true if the given identity can unpin or modify the
+// pin without overriding its ownership. That is when it is the owner of the
+// pin, or when the pin has no owner other than the peer that created it.
+func (pin Pin) ModifiableBy(identity string) bool {
+	return pin.Owner == "" ||
+		strings.HasPrefix(pin.Owner, peerOwnerPrefix) ||
+		pin.Owner == identity
+}
+
 // ExpiredAt returns whether the pin has expired at the given time.
 func (pin Pin) ExpiredAt(t time.Time) bool {
 	if pin.ExpireAt.IsZero() || pin.ExpireAt.Equal(unixZero) {
@@ -1411,6 +1917,30 @@ type Alert struct {
 	TriggeredAt time.Time `json:"triggered_at" codec:"r,omitempty"`
 }
 
+// Types of Events.
+const (
+	EventPinStatus  = "pin_status"
+	EventPeerJoined = "peer_joined"
+	EventPeerLeft   = "peer_left"
+	EventAlert      = "alert"
+	EventPinExpired = "pin_expired"
+)
+
+// Event describes something that happened in a cluster peer. Which fields
+// are set depends on the Type: pin status events carry the PinInfo of the
+// item in the peer that emits them, peer events carry the Peer, alert
+// events carry the Alert and pin expired events carry the Pin unpinned by
+// the peer, or which would have been unpinned when DryRun is set.
+type Event struct {
+	Type      string    `json:"type" codec:"y"`
+	Timestamp time.Time `json:"timestamp" codec:"t,omitempty"`
+	Peer      peer.ID   `json:"peer,omitempty" codec:"p,omitempty"`
+	PinInfo   *PinInfo  `json:"pin_info,omitempty" codec:"i,omitempty"`
+	Alert     *Alert    `json:"alert,omitempty" codec:"a,omitempty"`
+	Pin       *Pin      `json:"pin,omitempty" codec:"n,omitempty"`
+	DryRun    bool      `json:"dry_run,omitempty" codec:"d,omitempty"`
+}
+
 // Error can be used by APIs to return errors.
 type Error struct {
 	Code    int    `json:"code" codec:"o,omitempty"`
@@ -1440,6 +1970,9 @@ type RepoGC struct {
 	Peername string       `json:"peername" codec:"pn,omitempty"`
 	Keys     []IPFSRepoGC `json:"keys" codec:"k"`
 	Error    string       `json:"error,omitempty" codec:"e,omitempty"`
+	// Reclaimed is the number of bytes freed by the sweep, estimated
+	// from the size of the repo before and after it.
+	Reclaimed uint64 `json:"reclaimed" codec:"r,omitempty"`
 }
 
 // GlobalRepoGC contains cluster-wide information about garbage collected CIDs
@@ -1447,3 +1980,22 @@ type RepoGC struct {
 type GlobalRepoGC struct {
 	PeerMap map[string]RepoGC `json:"peer_map" codec:"pm,omitempty"`
 }
+
+// PinTrackerConcurrency is the number of pin and unpin operations that the
+// pintracker of a peer runs in parallel. With Auto, the number of pins is
+// adjusted to the latency of the IPFS daemon, between 1 and MaxPins.
+//
+// When used to change the concurrency, Pins sets both the number of pins
+// and MaxPins, and zero values leave the current ones unchanged. Paused
+// pintrackers do not start new operations, and it is changed by pausing and
+// resuming them instead.
+type PinTrackerConcurrency struct {
+	Peer     peer.ID `json:"peer" codec:"p,omitempty"`
+	Peername string  `json:"peername" codec:"pn,omitempty"`
+	Pins     int     `json:"pins" codec:"n,omitempty"`
+	MaxPins  int     `json:"max_pins" codec:"m,omitempty"`
+	Unpins   int     `json:"unpins" codec:"u,omitempty"`
+	Auto     bool    `json:"auto" codec:"a,omitempty"`
+	Paused   bool    `json:"paused" codec:"ps,omitempty"`
+	Error    string  `json:"error,omitempty" codec:"e,omitempty"`
+}
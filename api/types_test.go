@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/url"
 	"reflect"
 	"strings"
@@ -177,6 +178,9 @@ func TestPinOptionsQuery(t *testing.T) {
 				NewMultiaddrWithValue(multiaddr.StringCast("/ip4/1.2.3.4/tcp/1234/p2p/12D3KooWKewdAMAU3WjYHm8qkAJc5eW6KHbHWNigWraXXtE1UCng")),
 				NewMultiaddrWithValue(multiaddr.StringCast("/ip4/2.3.3.4/tcp/1234/p2p/12D3KooWF6BgwX966ge5AVFs9Gd2wVTBmypxZVvaBR12eYnUmXkR")),
 			},
+			Priority: PinPriorityHigh,
+			Group:    "dataset",
+			Follow:   "/ipns/example.org",
 		},
 		{
 			ReplicationFactorMax: -1,
@@ -185,6 +189,7 @@ func TestPinOptionsQuery(t *testing.T) {
 			ShardSize:            0,
 			UserAllocations:      []peer.ID{},
 			Metadata:             nil,
+			Priority:             PinPriorityLow,
 		},
 		{
 			ReplicationFactorMax: -1,
@@ -221,6 +226,41 @@ func TestPinOptionsQuery(t *testing.T) {
 	}
 }
 
+func TestPinPriority(t *testing.T) {
+	for _, pp := range []PinPriority{PinPriorityLow, PinPriorityNormal, PinPriorityHigh} {
+		pp2, err := PinPriorityFromString(pp.String())
+		if err != nil || pp2 != pp {
+			t.Errorf("%s: bad conversion from string: %d, %v", pp, pp2, err)
+		}
+
+		b, err := json.Marshal(pp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var pp3 PinPriority
+		if err := json.Unmarshal(b, &pp3); err != nil || pp3 != pp {
+			t.Errorf("%s: bad JSON round trip: %s", pp, b)
+		}
+
+		pin := PinWithOpts(CidUndef, PinOptions{Priority: pp})
+		data, err := pin.ProtoMarshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var pin2 Pin
+		if err := pin2.ProtoUnmarshal(data); err != nil {
+			t.Fatal(err)
+		}
+		if pin2.Priority != pp {
+			t.Errorf("%s: priority not kept in protobuf: %s", pp, pin2.Priority)
+		}
+	}
+
+	if _, err := PinPriorityFromString("urgent"); err == nil {
+		t.Error("expected an error with an unknown priority")
+	}
+}
+
 func TestIDCodec(t *testing.T) {
 	TestPeerID1, _ := peer.Decode("QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc")
 	TestPeerID2, _ := peer.Decode("QmUZ13osndQ5uL4tPWHXe3iBgBgq9gfewcBMSCAuMBsDJ6")
@@ -281,3 +321,111 @@ func TestPinCodec(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestPinGroupProto(t *testing.T) {
+	ci, _ := DecodeCid("QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc")
+	pin := PinWithOpts(ci, PinOptions{Group: "dataset"})
+	data, err := pin.ProtoMarshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pin2 Pin
+	if err := pin2.ProtoUnmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if !pin.Equals(pin2) || pin2.Group != "dataset" {
+		t.Errorf("group not kept in protobuf: %q", pin2.Group)
+	}
+}
+
+func TestStatusAllFilterMatch(t *testing.T) {
+	ci, _ := DecodeCid("QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc")
+	pid1, _ := peer.Decode("QmUZ13osndQ5uL4tPWHXe3iBgBgq9gfewcBMSCAuMBsDJ6")
+	pid2, _ := peer.Decode("QmPGDFvBkgWhvzEK9qaTWrWurSwqXNmhnK3hgELPdZZNPa")
+	now := time.Now()
+
+	pi := PinInfo{
+		Cid:         ci,
+		Name:        "abc",
+		Group:       "dataset",
+		Allocations: []peer.ID{pid1},
+		Created:     now,
+		Metadata:    map[string]string{"a": "b", "c": "d"},
+	}
+	everywhere := pi
+	everywhere.Allocations = nil
+
+	testcases := []struct {
+		filter StatusAllFilter
+		pi     PinInfo
+		match  bool
+	}{
+		{StatusAllFilter{}, pi, true},
+		{StatusAllFilter{CidPrefix: "QmXZ"}, pi, true},
+		{StatusAllFilter{CidPrefix: "QmXY"}, pi, false},
+		{StatusAllFilter{Name: "abc"}, pi, true},
+		{StatusAllFilter{Name: "ab"}, pi, false},
+		{StatusAllFilter{Group: "dataset"}, pi, true},
+		{StatusAllFilter{Group: "other"}, pi, false},
+		{StatusAllFilter{Metadata: map[string]string{"a": "b"}}, pi, true},
+		{StatusAllFilter{Metadata: map[string]string{"a": "b", "c": "e"}}, pi, false},
+		{StatusAllFilter{Metadata: map[string]string{"e": "f"}}, pi, false},
+		{StatusAllFilter{Allocation: pid1}, pi, true},
+		{StatusAllFilter{Allocation: pid2}, pi, false},
+		{StatusAllFilter{Allocation: pid2}, everywhere, true},
+		{StatusAllFilter{CreatedAfter: now.Add(time.Second)}, pi, false},
+		{StatusAllFilter{Name: "abc", CidPrefix: "Qm", CreatedBefore: now}, pi, true},
+	}
+
+	for i, tc := range testcases {
+		if tc.filter.Match(tc.pi) != tc.match {
+			t.Errorf("%d: expected match to be %t", i, tc.match)
+		}
+	}
+
+	if (StatusAllFilter{Status: TrackerStatusPinned}).HasCriteria() {
+		t.Error("a status filter has no other criteria")
+	}
+	if !(StatusAllFilter{Limit: 1}).HasCriteria() || !(StatusAllFilter{Limit: 1}).Paginated() {
+		t.Error("a limit is a criteria and a page")
+	}
+}
+
+func TestPinSearch(t *testing.T) {
+	p := PinWithOpts(CidUndef, PinOptions{
+		Name:     "abc",
+		Metadata: map[string]string{"a": "b", "c": "d"},
+	})
+
+	testcases := []struct {
+		search PinSearch
+		match  bool
+	}{
+		{PinSearch{Name: "abc"}, true},
+		{PinSearch{Name: "ab"}, false},
+		{PinSearch{Metadata: map[string]string{"a": "b"}}, true},
+		{PinSearch{Metadata: map[string]string{"a": ""}}, true},
+		{PinSearch{Metadata: map[string]string{"e": ""}}, false},
+		{PinSearch{Name: "abc", Metadata: map[string]string{"a": "b", "c": "e"}}, false},
+	}
+	for i, tc := range testcases {
+		if tc.search.Match(p) != tc.match {
+			t.Errorf("%d: expected match to be %t", i, tc.match)
+		}
+	}
+
+	search := PinSearch{Name: "abc", Metadata: map[string]string{"a": "b", "c": ""}}
+	q, err := url.ParseQuery(search.ToQuery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var search2 PinSearch
+	search2.FromQuery(q)
+	if search2.Name != search.Name || len(search2.Metadata) != 2 ||
+		search2.Metadata["a"] != "b" || search2.Metadata["c"] != "" {
+		t.Errorf("unexpected search from query: %+v", search2)
+	}
+	if !(PinSearch{}).IsEmpty() || search.IsEmpty() {
+		t.Error("bad IsEmpty")
+	}
+}
@@ -0,0 +1,123 @@
+package ipfscluster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+// budgetRecheckInterval is how often operations waiting for the
+// concurrency budget check it again, as other peers finish theirs.
+const budgetRecheckInterval = time.Second
+
+// opBudget counts the heavy operations (pins and adds) running in this
+// peer, so that they can be limited by the GlobalConcurrencyBudget.
+type opBudget struct {
+	mu       sync.Mutex
+	inFlight int
+	// closed and replaced every time an operation finishes.
+	released chan struct{}
+}
+
+func newOpBudget() *opBudget {
+	return &opBudget{
+		released: make(chan struct{}),
+	}
+}
+
+func (b *opBudget) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inFlight
+}
+
+// tryAcquire starts an operation when less than limit are running.
+// Otherwise it returns a channel closed when an operation finishes.
+func (b *opBudget) tryAcquire(limit int) (bool, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.inFlight < limit {
+		b.inFlight++
+		return true, nil
+	}
+	return false, b.released
+}
+
+func (b *opBudget) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.inFlight == 0 {
+		return
+	}
+	b.inFlight--
+	close(b.released)
+	b.released = make(chan struct{})
+}
+
+// budgetLimit returns how many heavy operations this peer can run. Peers
+// announce the operations they are running in their ping metrics. This
+// peer can use what the others leave of the GlobalConcurrencyBudget, and
+// at least its fair share of it, so that it is never starved. Since
+// metrics are only sent every MonitorPingInterval, the budget may be
+// exceeded for a short time when many peers get busy at once.
+func (c *Cluster) budgetLimit(ctx context.Context) int {
+	budget := c.config.GlobalConcurrencyBudget
+	peers := 1
+	others := 0
+	for _, m := range c.monitor.LatestMetrics(ctx, pingMetricName) {
+		if m.Peer == c.id {
+			continue
+		}
+		peers++
+		others += pingValueFromMetric(m).Operations
+	}
+
+	share := budget / peers
+	if share < 1 {
+		share = 1
+	}
+	if left := budget - others; left > share {
+		return left
+	}
+	return share
+}
+
+// acquireBudget blocks until a heavy operation can be started without
+// exceeding the GlobalConcurrencyBudget, or the context is done. The
+// operation must call releaseBudget when it finishes. It returns right away
+// when no budget is configured.
+func (c *Cluster) acquireBudget(ctx context.Context) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/acquireBudget")
+	defer span.End()
+
+	if c.config.GlobalConcurrencyBudget == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(budgetRecheckInterval)
+	defer timer.Stop()
+	for {
+		ok, released := c.budget.tryAcquire(c.budgetLimit(ctx))
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		case <-timer.C:
+			timer.Reset(budgetRecheckInterval)
+		}
+	}
+}
+
+// releaseBudget finishes an operation started with acquireBudget.
+func (c *Cluster) releaseBudget() {
+	if c.config.GlobalConcurrencyBudget == 0 {
+		return
+	}
+	c.budget.release()
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"mime/multipart"
+	"strings"
 	"sync"
 	"time"
 
@@ -44,6 +45,7 @@ const (
 	reBootstrapInterval = 30 * time.Second
 	mdnsServiceTag      = "_ipfs-cluster-discovery._udp"
 	maxAlerts           = 1000
+	defaultOpLogLimit   = 100
 )
 
 var errFollowerMode = errors.New("this peer is configured to be in follower mode. Write operations are disabled")
@@ -76,6 +78,22 @@ type Cluster struct {
 
 	alerts    []api.Alert
 	alertsMux sync.Mutex
+	// set while the quota usage is above the warning ratio. Protected
+	// by alertsMux.
+	quotaWarned bool
+	// number of pins allocated to this peer, counted every
+	// quotaPinsCountInterval rather than on every ping.
+	quotaPinsMux     sync.Mutex
+	quotaPins        int
+	quotaPinsCounted time.Time
+
+	events *eventBus
+
+	// pins recently unpinned by this peer.
+	tombstones *tombstones
+
+	// heavy operations running in this peer.
+	budget *opBudget
 
 	doneCh  chan struct{}
 	readyCh chan struct{}
@@ -165,6 +183,9 @@ func NewCluster(
 		informers:   informers,
 		tracer:      tracer,
 		alerts:      []api.Alert{},
+		events:      newEventBus(),
+		tombstones:  newTombstones(),
+		budget:      newOpBudget(),
 		peerManager: peerManager,
 		shutdownB:   false,
 		removed:     false,
@@ -256,10 +277,24 @@ func (c *Cluster) watchPinset() {
 	stateSyncTimer := time.NewTimer(c.config.StateSyncInterval)
 
 	// Upon start, every item in the state that is not pinned will appear
-	// as PinError when doing a Status, we should proceed to recover
-	// (try pinning) all of those right away.
+	// as unexpectedly unpinned when doing a Status, we should proceed to
+	// recover (try pinning) all of those right away. Afterwards, we look
+	// for them every PinRecoverInterval.
 	recoverTimer := time.NewTimer(0) // 0 so that it does an initial recover right away
 
+	// Failed operations are retried when the tracker has scheduled them,
+	// with a backoff for each item.
+	retryTimer := time.NewTimer(c.config.PinRecoverInterval)
+
+	// The expiration reaper is disabled with a 0 interval: a nil channel
+	// never fires.
+	var expirationTimer *time.Timer
+	var expirationC <-chan time.Time
+	if c.config.ExpirationInterval > 0 {
+		expirationTimer = time.NewTimer(c.config.ExpirationInterval)
+		expirationC = expirationTimer.C
+	}
+
 	// This prevents doing an StateSync while doing a RecoverAllLocal,
 	// which is intended behavior as for very large pinsets
 	for {
@@ -269,18 +304,28 @@ func (c *Cluster) watchPinset() {
 			c.StateSync(ctx)
 			stateSyncTimer.Reset(c.config.StateSyncInterval)
 		case <-recoverTimer.C:
-			logger.Debug("auto-triggering RecoverAllLocal()")
-
-			out := make(chan api.PinInfo, 1024)
-			go func() {
-				for range out {
-				}
-			}()
-			err := c.RecoverAllLocal(ctx, out)
+			logger.Debug("auto-triggering recover of unpinned items")
+			_, err := c.tracker.RecoverScheduled(ctx, true)
 			if err != nil {
 				logger.Error(err)
 			}
 			recoverTimer.Reset(c.config.PinRecoverInterval)
+		case <-retryTimer.C:
+			next, err := c.tracker.RecoverScheduled(ctx, false)
+			if err != nil {
+				logger.Error(err)
+			}
+			wait := time.Until(next)
+			if wait <= 0 || wait > c.config.PinRecoverInterval {
+				wait = c.config.PinRecoverInterval
+			}
+			retryTimer.Reset(wait)
+		case <-expirationC:
+			logger.Debug("auto-triggering expiration reaper")
+			if err := c.reapExpiredPins(ctx); err != nil {
+				logger.Error(err)
+			}
+			expirationTimer.Reset(c.config.ExpirationInterval)
 		case <-c.ctx.Done():
 			if !stateSyncTimer.Stop() {
 				<-stateSyncTimer.C
@@ -288,6 +333,12 @@ func (c *Cluster) watchPinset() {
 			if !recoverTimer.Stop() {
 				<-recoverTimer.C
 			}
+			if !retryTimer.Stop() {
+				<-retryTimer.C
+			}
+			if expirationTimer != nil {
+				expirationTimer.Stop()
+			}
 			return
 		}
 	}
@@ -394,13 +445,29 @@ func (c *Cluster) sendPingMetric(ctx context.Context) (api.Metric, error) {
 		Peername:      id.Peername,
 		IPFSID:        id.IPFS.ID,
 		IPFSAddresses: publicIPFSAddresses(id.IPFS.Addresses),
+		Passive:       c.config.PassiveMode,
+		Region:        c.config.Region,
 	}
 	if c.curPingVal.Valid() &&
 		!newPingVal.Valid() { // i.e. ipfs down
 		newPingVal = c.curPingVal // use last good value
+		newPingVal.Passive = c.config.PassiveMode
 	}
 	c.curPingVal = newPingVal
 
+	quota, err := c.quotaUsage(ctx)
+	if err != nil {
+		logger.Errorf("error obtaining quota usage: %s", err)
+		// continue anyways
+	}
+	if quota != nil {
+		newPingVal.Quota = quota
+		c.checkQuota(quota)
+	}
+	if c.config.GlobalConcurrencyBudget > 0 {
+		newPingVal.Operations = c.budget.count()
+	}
+
 	v, err := json.Marshal(newPingVal)
 	if err != nil {
 		logger.Error(err)
@@ -476,6 +543,26 @@ func (c *Cluster) Alerts() []api.Alert {
 	return alerts
 }
 
+// recordAlert adds an alert to the list of alerts of this peer and
+// publishes it as an event.
+func (c *Cluster) recordAlert(alrt api.Alert) {
+	c.alertsMux.Lock()
+	{
+		if len(c.alerts) > maxAlerts {
+			c.alerts = c.alerts[:0]
+		}
+
+		c.alerts = append(c.alerts, alrt)
+	}
+	c.alertsMux.Unlock()
+
+	c.events.publish(api.Event{
+		Type:  api.EventAlert,
+		Peer:  alrt.Peer,
+		Alert: &alrt,
+	})
+}
+
 // read the alerts channel from the monitor and triggers repins
 func (c *Cluster) alertsHandler() {
 	for {
@@ -490,15 +577,7 @@ func (c *Cluster) alertsHandler() {
 			}
 
 			logger.Warnf("metric alert for %s: Peer: %s.", alrt.Name, alrt.Peer)
-			c.alertsMux.Lock()
-			{
-				if len(c.alerts) > maxAlerts {
-					c.alerts = c.alerts[:0]
-				}
-
-				c.alerts = append(c.alerts, alrt)
-			}
-			c.alertsMux.Unlock()
+			c.recordAlert(alrt)
 
 			if alrt.Name != pingMetricName {
 				continue // only handle ping alerts
@@ -540,10 +619,13 @@ func (c *Cluster) alertsHandler() {
 
 // detects any changes in the peerset and saves the configuration. When it
 // detects that we have been removed from the peerset, it shuts down this peer.
+// Peers joining and leaving are published as events.
 func (c *Cluster) watchPeers() {
 	ticker := time.NewTicker(c.config.PeerWatchInterval)
 	defer ticker.Stop()
 
+	var prevPeers []peer.ID
+
 	for {
 		select {
 		case <-c.ctx.Done():
@@ -562,6 +644,15 @@ func (c *Cluster) watchPeers() {
 				logger.Error(err)
 				continue
 			}
+			if prevPeers != nil {
+				c.publishPeerChanges(prevPeers, peers)
+			}
+			prevPeers = peers
+
+			if c.config.RegionGossip {
+				c.recordPeerRegions(c.ctx)
+			}
+
 			for _, p := range peers {
 				if p == c.id {
 					hasMe = true
@@ -657,18 +748,31 @@ func (c *Cluster) run() {
 		c.watchPinset()
 	}()
 
-	c.wg.Add(1)
-	go func() {
-		defer c.wg.Done()
-		c.pushPingMetrics(c.ctx)
-	}()
+	// Following IPNS names is disabled with a 0 interval.
+	if c.config.IPNSFollowInterval > 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.watchFollowedPins()
+		}()
+	}
 
-	c.wg.Add(len(c.informers))
-	for _, informer := range c.informers {
-		go func(inf Informer) {
+	// Observers do not publish metrics so that they never become part
+	// of the peerset.
+	if !c.config.ObserverMode {
+		c.wg.Add(1)
+		go func() {
 			defer c.wg.Done()
-			c.pushInformerMetrics(c.ctx, inf)
-		}(informer)
+			c.pushPingMetrics(c.ctx)
+		}()
+
+		c.wg.Add(len(c.informers))
+		for _, informer := range c.informers {
+			go func(inf Informer) {
+				defer c.wg.Done()
+				c.pushInformerMetrics(c.ctx, inf)
+			}(informer)
+		}
 	}
 
 	c.wg.Add(1)
@@ -683,6 +787,12 @@ func (c *Cluster) run() {
 		c.alertsHandler()
 	}()
 
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.statusChangesHandler()
+	}()
+
 	c.wg.Add(1)
 	go func() {
 		defer c.wg.Done()
@@ -1058,15 +1168,17 @@ func (c *Cluster) Join(ctx context.Context, addr ma.Multiaddr) error {
 		logger.Warn(err)
 	}
 
-	// Broadcast our metrics to the world
-	err = c.sendInformersMetrics(ctx)
-	if err != nil {
-		logger.Warn(err)
-	}
+	// Broadcast our metrics to the world, unless we are just observing.
+	if !c.config.ObserverMode {
+		err = c.sendInformersMetrics(ctx)
+		if err != nil {
+			logger.Warn(err)
+		}
 
-	_, err = c.sendPingMetric(ctx)
-	if err != nil {
-		logger.Warn(err)
+		_, err = c.sendPingMetric(ctx)
+		if err != nil {
+			logger.Warn(err)
+		}
 	}
 
 	// We need to trigger a DHT bootstrap asap for this peer to not be
@@ -1143,70 +1255,170 @@ func (c *Cluster) distances(ctx context.Context, exclude peer.ID) (*distanceChec
 	}, nil
 }
 
+// OperationLog returns a page of the updates made to the shared state
+// through the consensus layer, from the most recent. At most
+// defaultOpLogLimit operations are returned when no limit is given.
+func (c *Cluster) OperationLog(ctx context.Context, opts api.OpLogOptions) ([]api.ConsensusOp, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/OperationLog")
+	defer span.End()
+
+	if opts.Limit <= 0 {
+		opts.Limit = defaultOpLogLimit
+	}
+	return c.consensus.OperationLog(ctx, opts)
+}
+
 // StateSync performs maintenance tasks on the global state that require
 // looping through all the items. It is triggered automatically on
 // StateSyncInterval. Currently it:
-//   - Sends unpin for expired items for which this peer is "closest"
-//     (skipped for follower peers)
+//   - Runs the expiration reaper, which unpins expired items for which this
+//     peer is "closest" (skipped for follower peers). The reaper also runs
+//     on its own, more frequent, ExpirationInterval.
 func (c *Cluster) StateSync(ctx context.Context) error {
 	ctx, span := trace.StartSpan(ctx, "cluster/StateSync")
 	defer span.End()
 
 	logger.Debug("StateSync")
 
-	if c.config.FollowerMode {
-		return nil
+	return c.reapExpiredPins(ctx)
+}
+
+// StatusAll returns the GlobalPinInfo for all tracked Cids in all peers on
+// the out channel. This is done by broacasting a StatusAll to all peers.  If
+// an error happens, it is returned. This method blocks until it finishes. The
+// operation can be aborted by canceling the context.
+func (c *Cluster) StatusAll(ctx context.Context, filter api.TrackerStatus, out chan<- api.GlobalPinInfo) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/StatusAll")
+	defer span.End()
+
+	in := make(chan api.TrackerStatus, 1)
+	in <- filter
+	close(in)
+	return c.globalPinInfoStream(ctx, "PinTracker", "StatusAll", in, nil, out)
+}
+
+// StatusAllFiltered works like StatusAll, but only returns the items which
+// match the given filter. Items which do not match are discarded as they
+// are received from the peers. When the filter asks for a page, pages
+// follow the order of the shared state and the After cid is the cursor:
+// the pins in the state after it are checked against the filter in order,
+// and the status is only requested for them until the page is full.
+func (c *Cluster) StatusAllFiltered(ctx context.Context, filter api.StatusAllFilter, out chan<- api.GlobalPinInfo) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/StatusAllFiltered")
+	defer span.End()
+
+	if !filter.Paginated() {
+		in := make(chan api.TrackerStatus, 1)
+		in <- filter.Status
+		close(in)
+		return c.globalPinInfoStream(ctx, "PinTracker", "StatusAll", in, filter.Match, out)
 	}
 
+	defer close(out)
+
 	cState, err := c.consensus.State(ctx)
 	if err != nil {
+		logger.Error(err)
 		return err
 	}
 
-	timeNow := time.Now()
-
-	// Only trigger pin operations if we are the closest with respect to
-	// other trusted peers. We cannot know if our peer ID is trusted by
-	// other peers in the Cluster. This assumes yes. Setting FollowerMode
-	// is a way to assume the opposite and skip this completely.
-	distance, err := c.distances(ctx, "")
-	if err != nil {
-		return err // could not list peers
-	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	clusterPins := make(chan api.Pin, 1024)
+	pins := make(chan api.Pin, statusPageBatch)
+	errCh := make(chan error, 1)
 	go func() {
-		err = cState.List(ctx, clusterPins)
-		if err != nil {
-			logger.Error(err)
+		errCh <- cState.ListAfter(ctx, filter.After, pins)
+	}()
+	// Stop the listing when the page is full before it finishes.
+	listed := false
+	defer func() {
+		if !listed {
+			cancel()
+			for range pins {
+			}
+			<-errCh
 		}
 	}()
 
-	// Unpin expired items when we are the closest peer to them.
-	for p := range clusterPins {
-		if p.ExpiredAt(timeNow) && distance.isClosest(p.Cid) {
-			logger.Infof("Unpinning %s: pin expired at %s", p.Cid, p.ExpireAt)
-			if _, err := c.Unpin(ctx, p.Cid); err != nil {
-				logger.Error(err)
+	skip, left := filter.Offset, filter.Limit
+	batch := make([]api.Pin, 0, statusPageBatch)
+	flush := func() (bool, error) {
+		gpis, err := c.statusBatch(ctx, batch)
+		batch = batch[:0]
+		if err != nil {
+			return false, err
+		}
+		for _, gpi := range gpis {
+			if !gpi.Match(filter.Status) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return false, ctx.Err()
+			case out <- gpi:
+			}
+			left--
+			if left == 0 {
+				return false, nil
 			}
 		}
+		return true, nil
 	}
 
+	for pin := range pins {
+		if !filter.MatchPin(pin) {
+			continue
+		}
+		batch = append(batch, pin)
+		// Do not ask for more statuses than needed for the page.
+		if len(batch) < statusPageBatch && (left <= 0 || skip+left > len(batch)) {
+			continue
+		}
+		more, err := flush()
+		if !more {
+			return err
+		}
+	}
+	listed = true
+	if err := <-errCh; err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		_, err := flush()
+		return err
+	}
 	return nil
 }
 
-// StatusAll returns the GlobalPinInfo for all tracked Cids in all peers on
-// the out channel. This is done by broacasting a StatusAll to all peers.  If
-// an error happens, it is returned. This method blocks until it finishes. The
-// operation can be aborted by canceling the context.
-func (c *Cluster) StatusAll(ctx context.Context, filter api.TrackerStatus, out chan<- api.GlobalPinInfo) error {
-	ctx, span := trace.StartSpan(ctx, "cluster/StatusAll")
-	defer span.End()
-
-	in := make(chan api.TrackerStatus, 1)
-	in <- filter
-	close(in)
-	return c.globalPinInfoStream(ctx, "PinTracker", "StatusAll", in, out)
+// statusPageBatch is the number of pins whose status is requested at once
+// when paginating statuses.
+const statusPageBatch = 32
+
+// statusBatch returns the GlobalPinInfo of the given pins, in the same
+// order, requesting them concurrently.
+func (c *Cluster) statusBatch(ctx context.Context, pins []api.Pin) ([]api.GlobalPinInfo, error) {
+	gpis := make([]api.GlobalPinInfo, len(pins))
+	errs := make([]error, len(pins))
+	var wg sync.WaitGroup
+	for i, pin := range pins {
+		wg.Add(1)
+		go func(i int, pin api.Pin) {
+			defer wg.Done()
+			gpis[i], errs[i] = c.globalPinInfoCid(ctx, "PinTracker", "Status", pin.Cid)
+		}(i, pin)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return gpis, nil
 }
 
 // StatusAllLocal returns the PinInfo for all the tracked Cids in this peer on
@@ -1269,7 +1481,7 @@ func (c *Cluster) RecoverAll(ctx context.Context, out chan<- api.GlobalPinInfo)
 	ctx, span := trace.StartSpan(ctx, "cluster/RecoverAll")
 	defer span.End()
 
-	return c.globalPinInfoStream(ctx, "Cluster", "RecoverAllLocal", nil, out)
+	return c.globalPinInfoStream(ctx, "Cluster", "RecoverAllLocal", nil, nil, out)
 }
 
 // RecoverAllLocal triggers a RecoverLocal operation for all Cids tracked
@@ -1282,7 +1494,8 @@ func (c *Cluster) RecoverAll(ctx context.Context, out chan<- api.GlobalPinInfo)
 // It returns the list of pins that were re-queued for pinning on the out
 // channel. It blocks until done.
 //
-// RecoverAllLocal is called automatically every PinRecoverInterval.
+// Items in error state are also retried automatically by the tracker,
+// with a backoff for every item.
 func (c *Cluster) RecoverAllLocal(ctx context.Context, out chan<- api.PinInfo) error {
 	ctx, span := trace.StartSpan(ctx, "cluster/RecoverAllLocal")
 	defer span.End()
@@ -1290,6 +1503,27 @@ func (c *Cluster) RecoverAllLocal(ctx context.Context, out chan<- api.PinInfo) e
 	return c.tracker.RecoverAll(ctx, out)
 }
 
+// RecoverPermanent triggers a RecoverPermanentLocal operation on all peers
+// and returns GlobalPinInfo objects for all the retried items. This method
+// blocks until finished.
+func (c *Cluster) RecoverPermanent(ctx context.Context, out chan<- api.GlobalPinInfo) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/RecoverPermanent")
+	defer span.End()
+
+	return c.globalPinInfoStream(ctx, "Cluster", "RecoverPermanentLocal", nil, nil, out)
+}
+
+// RecoverPermanentLocal retries the pins in this peer which failed more
+// than RecoverMaxAttempts times and are not retried automatically anymore
+// (TrackerStatusPinErrorPermanent). It returns their updated status on the
+// out channel and blocks until done.
+func (c *Cluster) RecoverPermanentLocal(ctx context.Context, out chan<- api.PinInfo) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/RecoverPermanentLocal")
+	defer span.End()
+
+	return c.tracker.RecoverPermanent(ctx, out)
+}
+
 // Recover triggers a recover operation for a given Cid in all
 // cluster peers.
 //
@@ -1335,6 +1569,27 @@ func (c *Cluster) Pins(ctx context.Context, out chan<- api.Pin) error {
 	return cState.List(ctx, out)
 }
 
+// SearchPins sends the pins matching the given search on the out channel,
+// which is closed when done. The state answers searches from an index over
+// the names and metadata of the pins, so the full pinset is not listed.
+func (c *Cluster) SearchPins(ctx context.Context, search api.PinSearch, out chan<- api.Pin) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/SearchPins")
+	defer span.End()
+
+	if search.IsEmpty() {
+		close(out)
+		return errors.New("a name or metadata is needed to search pins")
+	}
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		close(out)
+		logger.Error(err)
+		return err
+	}
+	return cState.Search(ctx, search, out)
+}
+
 // pinsSlice returns the list of Cids managed by Cluster and which are part
 // of the current global state. This is the source of truth as to which
 // pins are managed and their allocation, but does not indicate if
@@ -1489,6 +1744,20 @@ func (c *Cluster) setupPin(ctx context.Context, pin, existing api.Pin) (api.Pin,
 		return pin, errors.New("pin.ExpireAt set before current time")
 	}
 
+	if pin.Follow != "" && !strings.HasPrefix(pin.Follow, "/ipns/") {
+		return pin, errors.New("pin.Follow must be an /ipns/ path")
+	}
+
+	// The owner of a pin does not change when it is pinned again. Pins
+	// without owner were not created by an authenticated client and
+	// belong to this peer.
+	switch {
+	case existing.Defined() && existing.Owner != "":
+		pin.Owner = existing.Owner
+	case pin.Owner == "":
+		pin.Owner = api.PeerOwner(c.id)
+	}
+
 	if !existing.Defined() {
 		return pin, nil
 	}
@@ -1538,14 +1807,25 @@ func (c *Cluster) pin(
 		return pin, true, err
 	}
 
+	pin, err := c.preparePin(ctx, pin, blacklist)
+	if err != nil {
+		return pin, false, err
+	}
+	return pin, true, c.consensus.LogPin(ctx, pin)
+}
+
+// preparePin checks the given pin against the existing one, sets its
+// timestamp and allocates it, so that it is ready to be logged to the
+// consensus layer.
+func (c *Cluster) preparePin(ctx context.Context, pin api.Pin, blacklist []peer.ID) (api.Pin, error) {
 	existing, err := c.PinGet(ctx, pin.Cid)
 	if err != nil && err != state.ErrNotFound {
-		return pin, false, err
+		return pin, err
 	}
 
 	pin, err = c.setupPin(ctx, pin, existing)
 	if err != nil {
-		return pin, false, err
+		return pin, err
 	}
 
 	// Set the Pin timestamp to now(). This is not an user-controllable
@@ -1553,7 +1833,7 @@ func (c *Cluster) pin(
 	pin.Timestamp = time.Now()
 
 	if pin.Type == api.MetaType {
-		return pin, true, c.consensus.LogPin(ctx, pin)
+		return pin, nil
 	}
 
 	// Usually allocations are unset when pinning normally, however, the
@@ -1575,7 +1855,7 @@ func (c *Cluster) pin(
 			pin.UserAllocations,
 		)
 		if err != nil {
-			return pin, false, err
+			return pin, err
 		}
 		pin.Allocations = allocs
 	}
@@ -1587,7 +1867,91 @@ func (c *Cluster) pin(
 		logger.Infof("pinning %s on %s:", pin.Cid, pin.Allocations)
 	}
 
-	return pin, true, c.consensus.LogPin(ctx, pin)
+	return pin, nil
+}
+
+// PinBatch pins and unpins the given items, submitting them together to the
+// consensus layer, which commits them as a single operation when it
+// supports it. It returns the result of every item in the same order.
+// Items which cannot be prepared fail without affecting the others. Only
+// regular (DataType) pins can be unpinned in a batch, pin updates are not
+// supported and every cid can only appear once.
+func (c *Cluster) PinBatch(ctx context.Context, items []api.BatchItem) ([]api.BatchResult, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/PinBatch")
+	defer span.End()
+
+	if c.config.FollowerMode {
+		return nil, errFollowerMode
+	}
+
+	results := make([]api.BatchResult, len(items))
+	var pins, unpins []api.Pin
+	var logged []int
+	seen := make(map[api.Cid]struct{}, len(items))
+
+	for i, item := range items {
+		results[i] = api.BatchResult{
+			Action: item.Action,
+			Cid:    item.Cid,
+		}
+
+		pin, err := c.prepareBatchItem(ctx, item, seen)
+		results[i].Pin = pin
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		seen[item.Cid] = struct{}{}
+		logged = append(logged, i)
+		if item.Action == api.BatchActionPin {
+			pins = append(pins, pin)
+		} else {
+			unpins = append(unpins, pin)
+		}
+	}
+
+	if len(logged) == 0 {
+		return results, nil
+	}
+
+	logger.Infof("IPFS cluster batch: pinning %d and unpinning %d items", len(pins), len(unpins))
+	if err := c.consensus.LogBatch(ctx, pins, unpins); err != nil {
+		for _, i := range logged {
+			results[i].Error = err.Error()
+		}
+		return results, nil
+	}
+	c.tombstones.add(c.config.UnpinGracePeriod, unpins...)
+	return results, nil
+}
+
+// prepareBatchItem returns the pin to log for the given batch item.
+func (c *Cluster) prepareBatchItem(ctx context.Context, item api.BatchItem, seen map[api.Cid]struct{}) (api.Pin, error) {
+	if !item.Cid.Defined() {
+		return api.Pin{}, errors.New("bad pin object")
+	}
+	if _, ok := seen[item.Cid]; ok {
+		return api.Pin{}, errors.New("cid is already part of the batch")
+	}
+
+	switch item.Action {
+	case api.BatchActionPin:
+		if item.Options.PinUpdate.Defined() {
+			return api.Pin{}, errors.New("pin updates cannot be batched")
+		}
+		return c.preparePin(ctx, api.PinWithOpts(item.Cid, item.Options), []peer.ID{})
+	case api.BatchActionUnpin:
+		pin, err := c.PinGet(ctx, item.Cid)
+		if err != nil {
+			return api.Pin{}, err
+		}
+		if pin.Type != api.DataType {
+			return pin, errors.New("only regular pins can be unpinned in a batch")
+		}
+		return pin, nil
+	default:
+		return api.Pin{}, fmt.Errorf("unknown action: %q", item.Action)
+	}
 }
 
 // Unpin removes a previously pinned Cid from Cluster. It returns
@@ -1595,7 +1959,9 @@ func (c *Cluster) pin(
 // an error if it was not possible to update the global state.
 //
 // Unpin does not reflect the success or failure of underlying IPFS daemon
-// unpinning operations, which happen in async fashion.
+// unpinning operations, which happen in async fashion. When an
+// UnpinGracePeriod is configured, the unpin can be undone with UndoUnpin
+// during that time.
 func (c *Cluster) Unpin(ctx context.Context, h api.Cid) (api.Pin, error) {
 	ctx, span := trace.StartSpan(ctx, "cluster/Unpin")
 	defer span.End()
@@ -1612,7 +1978,11 @@ func (c *Cluster) Unpin(ctx context.Context, h api.Cid) (api.Pin, error) {
 
 	switch pin.Type {
 	case api.DataType:
-		return pin, c.consensus.LogUnpin(ctx, pin)
+		if err := c.consensus.LogUnpin(ctx, pin); err != nil {
+			return pin, err
+		}
+		c.tombstones.add(c.config.UnpinGracePeriod, pin)
+		return pin, nil
 	case api.ShardType:
 		err := "cannot unpin a shard directly. Unpin content root CID instead"
 		return pin, errors.New(err)
@@ -1683,9 +2053,63 @@ func (c *Cluster) PinUpdate(ctx context.Context, from api.Cid, to api.Cid, opts
 	if !opts.ExpireAt.IsZero() && opts.ExpireAt.After(time.Now()) {
 		existing.ExpireAt = opts.ExpireAt
 	}
+	if opts.Priority != api.PinPriorityNormal {
+		existing.Priority = opts.Priority
+	}
+	if opts.Group != "" {
+		existing.Group = opts.Group
+	}
 	return existing, c.consensus.LogPin(ctx, existing)
 }
 
+// PinEdit changes the name, group and metadata of an existing pin. Nothing
+// else changes: the pin keeps its allocations and timestamp, so it is not
+// re-allocated and the peers which have pinned it already do not pin it
+// again. It returns the edited Pin object.
+func (c *Cluster) PinEdit(ctx context.Context, edit api.PinEdit) (api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/PinEdit")
+	defer span.End()
+
+	if c.config.FollowerMode {
+		return api.Pin{}, errFollowerMode
+	}
+
+	pin, err := c.PinGet(ctx, edit.Cid)
+	if err != nil { // including when the pin is not found
+		return api.Pin{}, err
+	}
+
+	existing := pin
+	pin.Metadata = make(map[string]string, len(existing.Metadata))
+	for k, v := range existing.Metadata {
+		pin.Metadata[k] = v
+	}
+
+	if edit.Name != nil {
+		pin.Name = *edit.Name
+	}
+	if edit.Group != nil {
+		pin.Group = *edit.Group
+	}
+	for k, v := range edit.Metadata {
+		if v == "" {
+			delete(pin.Metadata, k)
+			continue
+		}
+		pin.Metadata[k] = v
+	}
+	if len(pin.Metadata) == 0 {
+		pin.Metadata = nil
+	}
+
+	// Edits which change nothing are not committed, so that the peers
+	// do not track the pin again.
+	if pin.Equals(existing) {
+		return pin, nil
+	}
+	return pin, c.consensus.LogPin(ctx, pin)
+}
+
 // PinPath pins an CID resolved from its IPFS Path. It returns the resolved
 // Pin object.
 func (c *Cluster) PinPath(ctx context.Context, path string, opts api.PinOptions) (api.Pin, error) {
@@ -1721,6 +2145,11 @@ func (c *Cluster) UnpinPath(ctx context.Context, path string) (api.Pin, error) {
 func (c *Cluster) AddFile(ctx context.Context, reader *multipart.Reader, params api.AddParams) (api.Cid, error) {
 	// TODO: add context param and tracing
 
+	if err := c.acquireBudget(ctx); err != nil {
+		return api.CidUndef, err
+	}
+	defer c.releaseBudget()
+
 	var dags adder.ClusterDAGService
 	if params.Shard {
 		dags = sharding.New(ctx, c.rpcClient, params, nil)
@@ -1843,6 +2272,7 @@ func (c *Cluster) setTrackerStatus(gpin *api.GlobalPinInfo, h api.Cid, peers []p
 			Origins:     pin.Origins,
 			Created:     pin.Timestamp,
 			Metadata:    pin.Metadata,
+			Group:       pin.Group,
 			Peer:        p,
 			PinInfoShort: api.PinInfoShort{
 				PeerName:      pv.Peername,
@@ -1973,6 +2403,7 @@ func (c *Cluster) globalPinInfoCid(ctx context.Context, comp, method string, h a
 			Origins:     pin.Origins,
 			Created:     pin.Timestamp,
 			Metadata:    pin.Metadata,
+			Group:       pin.Group,
 			PinInfoShort: api.PinInfoShort{
 				PeerName:      pv.Peername,
 				IPFS:          pv.IPFSID,
@@ -1987,7 +2418,91 @@ func (c *Cluster) globalPinInfoCid(ctx context.Context, comp, method string, h a
 	return gpin, nil
 }
 
-func (c *Cluster) globalPinInfoStream(ctx context.Context, comp, method string, inChan interface{}, out chan<- api.GlobalPinInfo) error {
+// pinInfoMerger merges the PinInfos streamed by several peers into
+// GlobalPinInfos. A GlobalPinInfo is complete once all peers have reported
+// its Cid, and only the incomplete ones are kept.
+type pinInfoMerger struct {
+	peers   int
+	keep    func(api.PinInfo) bool
+	pending map[api.Cid]*pendingGlobalPinInfo
+}
+
+type pendingGlobalPinInfo struct {
+	info     api.GlobalPinInfo
+	reported map[peer.ID]struct{}
+	kept     bool
+}
+
+func newPinInfoMerger(peers int, keep func(api.PinInfo) bool) *pinInfoMerger {
+	return &pinInfoMerger{
+		peers:   peers,
+		keep:    keep,
+		pending: make(map[api.Cid]*pendingGlobalPinInfo),
+	}
+}
+
+// add merges a PinInfo and returns its GlobalPinInfo when it is complete.
+// Items which are not kept count as reported but are not merged, and
+// GlobalPinInfos without any kept item are never returned.
+func (m *pinInfoMerger) add(pin api.PinInfo) (api.GlobalPinInfo, bool) {
+	if !pin.Defined() {
+		return api.GlobalPinInfo{}, false
+	}
+	p, ok := m.pending[pin.Cid]
+	if !ok {
+		p = &pendingGlobalPinInfo{
+			reported: make(map[peer.ID]struct{}),
+		}
+		m.pending[pin.Cid] = p
+	}
+	p.reported[pin.Peer] = struct{}{}
+	if m.keep == nil || m.keep(pin) {
+		p.info.Add(pin)
+		p.kept = true
+	}
+	if len(p.reported) < m.peers {
+		return api.GlobalPinInfo{}, false
+	}
+
+	delete(m.pending, pin.Cid)
+	return p.info, p.kept
+}
+
+// addError sets the given status, usually an error, for the given peer on
+// all the incomplete GlobalPinInfos which it has not reported.
+func (m *pinInfoMerger) addError(pid peer.ID, status api.PinInfoShort) {
+	for ci, p := range m.pending {
+		if _, ok := p.reported[pid]; ok || !p.kept {
+			continue
+		}
+		p.info.Add(api.PinInfo{
+			Cid:          ci,
+			Peer:         pid,
+			PinInfoShort: status,
+		})
+	}
+}
+
+// incomplete returns the GlobalPinInfos which are not complete.
+func (m *pinInfoMerger) incomplete() []api.GlobalPinInfo {
+	gpis := make([]api.GlobalPinInfo, 0, len(m.pending))
+	for _, p := range m.pending {
+		if p.kept {
+			gpis = append(gpis, p.info)
+		}
+	}
+	return gpis
+}
+
+// globalPinInfoStream streams the PinInfos returned by the given method on
+// all peers and merges them into GlobalPinInfos. Every GlobalPinInfo is sent
+// as soon as all peers have reported its Cid. Peers list the same shared
+// state in the same order, so only the items which some peers have sent and
+// others not yet are held in memory rather than the full pinset. The items
+// which some peers do not report are sent when all peers have finished,
+// with an error for the peers which failed. When keep is not nil, only the
+// items for which it returns true are merged.
+func (c *Cluster) globalPinInfoStream(ctx context.Context, comp, method string, inChan interface{}, keep func(api.PinInfo) bool, out chan<- api.GlobalPinInfo) error {
 	defer close(out)
 
 	ctx, span := trace.StartSpan(ctx, "cluster/globalPinInfoStream")
@@ -1999,8 +2514,6 @@ func (c *Cluster) globalPinInfoStream(ctx context.Context, comp, method string,
 		inChan = emptyChan
 	}
 
-	fullMap := make(map[api.Cid]api.GlobalPinInfo)
-
 	var members []peer.ID
 	var err error
 	if c.config.FollowerMode {
@@ -2034,26 +2547,32 @@ func (c *Cluster) globalPinInfoStream(ctx context.Context, comp, method string,
 		)
 	}()
 
-	setPinInfo := func(p api.PinInfo) {
-		if !p.Defined() {
-			return
-		}
-		info, ok := fullMap[p.Cid]
-		if !ok {
-			info = api.GlobalPinInfo{}
+	send := func(gpi api.GlobalPinInfo) error {
+		select {
+		case <-ctx.Done():
+			err := fmt.Errorf("%s.%s aborted: %w", comp, method, ctx.Err())
+			logger.Error(err)
+			return err
+		case out <- gpi:
+			return nil
 		}
-		info.Add(p)
-		// Set the new/updated info
-		fullMap[p.Cid] = info
 	}
 
-	// make the big collection.
+	merger := newPinInfoMerger(len(members), keep)
 	for pin := range msOut {
-		setPinInfo(pin)
+		gpi, ok := merger.add(pin)
+		if !ok {
+			continue
+		}
+		if err := send(gpi); err != nil {
+			cancel()
+			for range msOut { // let MultiStream finish
+			}
+			return err
+		}
 	}
 
 	// This WAITs until MultiStream is DONE.
-	erroredPeers := make(map[peer.ID]string)
 	errs, ok := <-errsCh
 	if ok {
 		for i, err := range errs {
@@ -2065,41 +2584,21 @@ func (c *Cluster) globalPinInfoStream(ctx context.Context, comp, method string,
 				continue
 			}
 			logger.Errorf("%s: error in broadcast response from %s: %s ", c.id, members[i], err)
-			erroredPeers[members[i]] = err.Error()
-		}
-	}
-
-	// Merge any errors
-	for p, msg := range erroredPeers {
-		pv := pingValueFromMetric(c.monitor.LatestForPeer(ctx, pingMetricName, p))
-		for c := range fullMap {
-			setPinInfo(api.PinInfo{
-				Cid:         c,
-				Name:        "",
-				Peer:        p,
-				Allocations: nil,
-				Origins:     nil,
-				// Created:    // leave unitialized
-				Metadata: nil,
-				PinInfoShort: api.PinInfoShort{
-					PeerName:      pv.Peername,
-					IPFS:          pv.IPFSID,
-					IPFSAddresses: pv.IPFSAddresses,
-					Status:        api.TrackerStatusClusterError,
-					TS:            time.Now(),
-					Error:         msg,
-				},
+			pv := pingValueFromMetric(c.monitor.LatestForPeer(ctx, pingMetricName, members[i]))
+			merger.addError(members[i], api.PinInfoShort{
+				PeerName:      pv.Peername,
+				IPFS:          pv.IPFSID,
+				IPFSAddresses: pv.IPFSAddresses,
+				Status:        api.TrackerStatusClusterError,
+				TS:            time.Now(),
+				Error:         err.Error(),
 			})
 		}
 	}
 
-	for _, v := range fullMap {
-		select {
-		case <-ctx.Done():
-			err := fmt.Errorf("%s.%s aborted: %w", comp, method, ctx.Err())
-			logger.Error(err)
+	for _, gpi := range merger.incomplete() {
+		if err := send(gpi); err != nil {
 			return err
-		case out <- v:
 		}
 	}
 
@@ -2217,53 +2716,100 @@ func (c *Cluster) cidsFromMetaPin(ctx context.Context, h api.Cid) ([]api.Cid, er
 // 	return
 // }
 
-// RepoGC performs garbage collection sweep on all peers' IPFS repo.
+// RepoGC performs garbage collection sweep on all peers' IPFS repo, one
+// peer at a time.
 func (c *Cluster) RepoGC(ctx context.Context) (api.GlobalRepoGC, error) {
 	ctx, span := trace.StartSpan(ctx, "cluster/RepoGC")
 	defer span.End()
 
+	return c.GarbageCollect(ctx, 1)
+}
+
+// GarbageCollect performs garbage collection sweeps on all peers' IPFS
+// repos in a rolling fashion: the sweeps run on at most the given number of
+// peers at once (1 when not positive), so that most peers keep serving
+// content while the others are busy. The result for every peer includes
+// the space it reclaimed.
+func (c *Cluster) GarbageCollect(ctx context.Context, concurrency int) (api.GlobalRepoGC, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/GarbageCollect")
+	defer span.End()
+
 	members, err := c.consensus.Peers(ctx)
 	if err != nil {
 		logger.Error(err)
 		return api.GlobalRepoGC{}, err
 	}
 
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
 	// to club `RepoGCLocal` responses of all peers into one
 	globalRepoGC := api.GlobalRepoGC{PeerMap: make(map[string]api.RepoGC)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
 
 	for _, member := range members {
-		var repoGC api.RepoGC
-		err = c.rpcClient.CallContext(
-			ctx,
-			member,
-			"Cluster",
-			"RepoGCLocal",
-			struct{}{},
-			&repoGC,
-		)
-		if err == nil {
-			globalRepoGC.PeerMap[member.String()] = repoGC
-			continue
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return globalRepoGC, ctx.Err()
+		case sem <- struct{}{}:
 		}
 
-		if rpc.IsAuthorizationError(err) {
-			logger.Debug("rpc auth error:", err)
-			continue
-		}
+		wg.Add(1)
+		go func(member peer.ID) {
+			defer wg.Done()
+			defer func() { <-sem }()
 
-		logger.Errorf("%s: error in broadcast response from %s: %s ", c.id, member, err)
+			logger.Infof("running repo gc on %s", member)
+			repoGC, ok := c.repoGCPeer(ctx, member)
+			if !ok {
+				return
+			}
+			mu.Lock()
+			globalRepoGC.PeerMap[member.String()] = repoGC
+			mu.Unlock()
+		}(member)
+	}
+	wg.Wait()
 
-		pv := pingValueFromMetric(c.monitor.LatestForPeer(ctx, pingMetricName, member))
+	return globalRepoGC, nil
+}
 
-		globalRepoGC.PeerMap[member.String()] = api.RepoGC{
-			Peer:     member,
-			Peername: pv.Peername,
-			Keys:     []api.IPFSRepoGC{},
-			Error:    err.Error(),
-		}
+// repoGCPeer runs RepoGCLocal on the given peer. On errors, the returned
+// RepoGC carries them. It returns false when the peer did not authorize the
+// request.
+func (c *Cluster) repoGCPeer(ctx context.Context, member peer.ID) (api.RepoGC, bool) {
+	var repoGC api.RepoGC
+	err := c.rpcClient.CallContext(
+		ctx,
+		member,
+		"Cluster",
+		"RepoGCLocal",
+		struct{}{},
+		&repoGC,
+	)
+	if err == nil {
+		return repoGC, true
 	}
 
-	return globalRepoGC, nil
+	if rpc.IsAuthorizationError(err) {
+		logger.Debug("rpc auth error:", err)
+		return api.RepoGC{}, false
+	}
+
+	logger.Errorf("%s: error in broadcast response from %s: %s ", c.id, member, err)
+
+	pv := pingValueFromMetric(c.monitor.LatestForPeer(ctx, pingMetricName, member))
+
+	return api.RepoGC{
+		Peer:     member,
+		Peername: pv.Peername,
+		Keys:     []api.IPFSRepoGC{},
+		Error:    err.Error(),
+	}, true
 }
 
 // RepoGCLocal performs garbage collection only on the local IPFS deamon.
@@ -2271,11 +2817,110 @@ func (c *Cluster) RepoGCLocal(ctx context.Context) (api.RepoGC, error) {
 	ctx, span := trace.StartSpan(ctx, "cluster/RepoGCLocal")
 	defer span.End()
 
+	// The reclaimed space is estimated from the size of the repo
+	// before and after the sweep.
+	before, statErr := c.ipfs.RepoStat(ctx)
+	if statErr != nil {
+		logger.Warnf("error obtaining the repo size before gc: %s", statErr)
+	}
+
 	resp, err := c.ipfs.RepoGC(ctx)
 	if err != nil {
 		return api.RepoGC{}, err
 	}
 	resp.Peer = c.id
 	resp.Peername = c.config.Peername
+
+	if statErr == nil {
+		after, err := c.ipfs.RepoStat(ctx)
+		if err != nil {
+			logger.Warnf("error obtaining the repo size after gc: %s", err)
+		} else if after.RepoSize < before.RepoSize {
+			resp.Reclaimed = before.RepoSize - after.RepoSize
+		}
+	}
 	return resp, nil
 }
+
+// PinTrackerConcurrency returns the number of pin and unpin operations that
+// the pintracker of every peer runs in parallel.
+func (c *Cluster) PinTrackerConcurrency(ctx context.Context) ([]api.PinTrackerConcurrency, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/PinTrackerConcurrency")
+	defer span.End()
+
+	return c.broadcastConcurrency(ctx, "Concurrency", struct{}{})
+}
+
+// SetPinTrackerConcurrency changes the number of pin and unpin operations
+// that the pintracker of every peer runs in parallel, until the peers
+// restart.
+func (c *Cluster) SetPinTrackerConcurrency(ctx context.Context, conc api.PinTrackerConcurrency) ([]api.PinTrackerConcurrency, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/SetPinTrackerConcurrency")
+	defer span.End()
+
+	return c.broadcastConcurrency(ctx, "SetConcurrency", conc)
+}
+
+// PinTrackerPause stops the pintracker of every peer from starting new pin
+// and unpin operations until they are resumed or the peers restart. The
+// shared state and the APIs are not affected: new pins are still accepted
+// and queued.
+func (c *Cluster) PinTrackerPause(ctx context.Context) ([]api.PinTrackerConcurrency, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/PinTrackerPause")
+	defer span.End()
+
+	return c.broadcastConcurrency(ctx, "Pause", struct{}{})
+}
+
+// PinTrackerResume lets the pintracker of every peer start operations again
+// after PinTrackerPause.
+func (c *Cluster) PinTrackerResume(ctx context.Context) ([]api.PinTrackerConcurrency, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/PinTrackerResume")
+	defer span.End()
+
+	return c.broadcastConcurrency(ctx, "Resume", struct{}{})
+}
+
+// broadcastConcurrency calls the given PinTracker method on all peers. The
+// results of the peers which fail carry the error.
+func (c *Cluster) broadcastConcurrency(ctx context.Context, method string, in interface{}) ([]api.PinTrackerConcurrency, error) {
+	members, err := c.consensus.Peers(ctx)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	timeout := 15 * time.Second
+	ctxs, cancels := rpcutil.CtxsWithTimeout(ctx, len(members), timeout)
+	defer rpcutil.MultiCancel(cancels)
+
+	replies := make([]api.PinTrackerConcurrency, len(members))
+	errs := c.rpcClient.MultiCall(
+		ctxs,
+		members,
+		"PinTracker",
+		method,
+		in,
+		rpcutil.CopyPinTrackerConcurrencyToIfaces(replies),
+	)
+
+	results := make([]api.PinTrackerConcurrency, 0, len(members))
+	for i, r := range replies {
+		e := errs[i]
+		if rpc.IsAuthorizationError(e) {
+			logger.Debug("rpc auth error:", e)
+			continue
+		}
+		if e != nil {
+			logger.Errorf("%s: error in broadcast response from %s: %s ", c.id, members[i], e)
+			pv := pingValueFromMetric(c.monitor.LatestForPeer(ctx, pingMetricName, members[i]))
+			r = api.PinTrackerConcurrency{
+				Peer:     members[i],
+				Peername: pv.Peername,
+				Error:    e.Error(),
+			}
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
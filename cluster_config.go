@@ -32,6 +32,10 @@ const (
 	DefaultEnableRelayHop        = true
 	DefaultStateSyncInterval     = 5 * time.Minute
 	DefaultPinRecoverInterval    = 12 * time.Minute
+	DefaultExpirationInterval    = time.Minute
+	DefaultIPNSFollowInterval    = 5 * time.Minute
+	DefaultUnpinGracePeriod      = 0
+	DefaultExpirationDryRun      = false
 	DefaultMonitorPingInterval   = 15 * time.Second
 	DefaultPeerWatchInterval     = 5 * time.Second
 	DefaultReplicationFactor     = -1
@@ -44,6 +48,8 @@ const (
 	DefaultConnMgrGracePeriod    = 2 * time.Minute
 	DefaultDialPeerTimeout       = 3 * time.Second
 	DefaultFollowerMode          = false
+	DefaultPassiveMode           = false
+	DefaultObserverMode          = false
 	DefaultMDNSInterval          = 10 * time.Second
 )
 
@@ -99,10 +105,32 @@ type Config struct {
 	// consistency, increase with larger states.
 	StateSyncInterval time.Duration
 
-	// Time between automatic runs of the "recover" operation
-	// which will retry to pin/unpin items in error state.
+	// Time between automatic runs of the "recover" operation which
+	// will pin items that should be pinned but are not. Items in error
+	// state are retried when the pintracker schedules them.
 	PinRecoverInterval time.Duration
 
+	// Time between runs of the expiration reaper, which unpins the
+	// expired pins for which this peer is the closest trusted peer.
+	// 0 disables it, but expired pins are still unpinned on
+	// StateSyncInterval.
+	ExpirationInterval time.Duration
+
+	// When set, the expiration reaper only logs and publishes the
+	// pins that it would unpin, without unpinning them.
+	ExpirationDryRun bool
+
+	// Time between the resolutions of the IPNS names followed by pins.
+	// Pins are updated when their name points to a different CID. 0
+	// disables following names.
+	IPNSFollowInterval time.Duration
+
+	// UnpinGracePeriod delays the unpinning of items in IPFS after they
+	// are removed from the shared state. During this time, unpins can be
+	// undone by the peer which handled them, and the items are pinned
+	// again without fetching them. 0 unpins items right away.
+	UnpinGracePeriod time.Duration
+
 	// ReplicationFactorMax indicates the target number of nodes
 	// that should pin content. For exampe, a replication_factor of
 	// 3 will have cluster allocate each pinned hash to 3 peers if
@@ -152,6 +180,45 @@ type Config struct {
 	// operations (Pin/Unpin).
 	FollowerMode bool
 
+	// PassiveMode makes this peer participate in consensus and serve
+	// the APIs without ever storing content: it is never allocated
+	// pins and it does not pin anything, not even the pins allocated
+	// everywhere. It is meant for monitoring or API-only peers.
+	PassiveMode bool
+
+	// ObserverMode makes this peer a read-only replica of a CRDT
+	// cluster: it syncs the shared state to serve the APIs, but it
+	// never publishes anything (no CRDT updates, no metrics), so it is
+	// not part of the peerset. It implies FollowerMode and
+	// PassiveMode.
+	ObserverMode bool
+
+	// QuotaMaxBytes and QuotaMaxPins set the storage quota of this peer:
+	// the maximum size of its IPFS repository and the maximum number of
+	// pins allocated to it. The peer announces its usage in its ping
+	// metrics and it is not allocated any new pins once a quota is
+	// reached. A value of 0 means no quota.
+	QuotaMaxBytes uint64
+	QuotaMaxPins  int
+
+	// GlobalConcurrencyBudget caps the number of heavy operations
+	// (pins in progress and adds) which run at the same time across
+	// all the peers in the cluster. Operations above it are queued.
+	// Peers announce their running operations in their ping metrics,
+	// and each peer can always run its share of the budget. It should
+	// be the same in all peers. 0 means no limit.
+	GlobalConcurrencyBudget int
+
+	// Region is a label for the region or datacenter of this peer,
+	// which peers announce in their ping metrics.
+	Region string
+
+	// RegionGossip makes pubsub favor peers in the same Region when
+	// choosing the mesh peers which CRDT broadcasts and metrics are
+	// sent to, reducing traffic across regions. Peers in other regions
+	// are still used when needed.
+	RegionGossip bool
+
 	// Peerstore file specifies the file on which we persist the
 	// libp2p host peerstore addresses. This file is regularly saved.
 	PeerstoreFile string
@@ -169,27 +236,38 @@ type Config struct {
 // saved using JSON. Most configuration keys are converted into simple types
 // like strings, and key names aim to be self-explanatory for the user.
 type configJSON struct {
-	ID                    string             `json:"id,omitempty"`
-	Peername              string             `json:"peername"`
-	PrivateKey            string             `json:"private_key,omitempty" hidden:"true"`
-	Secret                string             `json:"secret" hidden:"true"`
-	LeaveOnShutdown       bool               `json:"leave_on_shutdown"`
-	ListenMultiaddress    config.Strings     `json:"listen_multiaddress"`
-	EnableRelayHop        bool               `json:"enable_relay_hop"`
-	ConnectionManager     *connMgrConfigJSON `json:"connection_manager"`
-	DialPeerTimeout       string             `json:"dial_peer_timeout"`
-	StateSyncInterval     string             `json:"state_sync_interval"`
-	PinRecoverInterval    string             `json:"pin_recover_interval"`
-	ReplicationFactorMin  int                `json:"replication_factor_min"`
-	ReplicationFactorMax  int                `json:"replication_factor_max"`
-	MonitorPingInterval   string             `json:"monitor_ping_interval"`
-	PeerWatchInterval     string             `json:"peer_watch_interval"`
-	MDNSInterval          string             `json:"mdns_interval"`
-	PinOnlyOnTrustedPeers bool               `json:"pin_only_on_trusted_peers"`
-	DisableRepinning      bool               `json:"disable_repinning"`
-	FollowerMode          bool               `json:"follower_mode,omitempty"`
-	PeerstoreFile         string             `json:"peerstore_file,omitempty"`
-	PeerAddresses         []string           `json:"peer_addresses"`
+	ID                      string             `json:"id,omitempty"`
+	Peername                string             `json:"peername"`
+	PrivateKey              string             `json:"private_key,omitempty" hidden:"true"`
+	Secret                  string             `json:"secret" hidden:"true"`
+	LeaveOnShutdown         bool               `json:"leave_on_shutdown"`
+	ListenMultiaddress      config.Strings     `json:"listen_multiaddress"`
+	EnableRelayHop          bool               `json:"enable_relay_hop"`
+	ConnectionManager       *connMgrConfigJSON `json:"connection_manager"`
+	DialPeerTimeout         string             `json:"dial_peer_timeout"`
+	StateSyncInterval       string             `json:"state_sync_interval"`
+	PinRecoverInterval      string             `json:"pin_recover_interval"`
+	ExpirationInterval      string             `json:"expiration_interval"`
+	ExpirationDryRun        bool               `json:"expiration_dry_run,omitempty"`
+	IPNSFollowInterval      string             `json:"ipns_follow_interval"`
+	UnpinGracePeriod        string             `json:"unpin_grace_period"`
+	ReplicationFactorMin    int                `json:"replication_factor_min"`
+	ReplicationFactorMax    int                `json:"replication_factor_max"`
+	MonitorPingInterval     string             `json:"monitor_ping_interval"`
+	PeerWatchInterval       string             `json:"peer_watch_interval"`
+	MDNSInterval            string             `json:"mdns_interval"`
+	PinOnlyOnTrustedPeers   bool               `json:"pin_only_on_trusted_peers"`
+	DisableRepinning        bool               `json:"disable_repinning"`
+	FollowerMode            bool               `json:"follower_mode,omitempty"`
+	PassiveMode             bool               `json:"passive_mode,omitempty"`
+	ObserverMode            bool               `json:"observer_mode,omitempty"`
+	QuotaMaxBytes           uint64             `json:"quota_max_bytes,omitempty"`
+	QuotaMaxPins            int                `json:"quota_max_pins,omitempty"`
+	GlobalConcurrencyBudget int                `json:"global_concurrency_budget,omitempty"`
+	Region                  string             `json:"region,omitempty"`
+	RegionGossip            bool               `json:"region_gossip,omitempty"`
+	PeerstoreFile           string             `json:"peerstore_file,omitempty"`
+	PeerAddresses           []string           `json:"peer_addresses"`
 }
 
 // connMgrConfigJSON configures the libp2p host connection manager.
@@ -279,6 +357,30 @@ func (cfg *Config) Validate() error {
 		return errors.New("cluster.pin_recover_interval is invalid")
 	}
 
+	if cfg.ExpirationInterval < 0 {
+		return errors.New("cluster.expiration_interval is invalid")
+	}
+
+	if cfg.IPNSFollowInterval < 0 {
+		return errors.New("cluster.ipns_follow_interval is invalid")
+	}
+
+	if cfg.QuotaMaxPins < 0 {
+		return errors.New("cluster.quota_max_pins is invalid")
+	}
+
+	if cfg.GlobalConcurrencyBudget < 0 {
+		return errors.New("cluster.global_concurrency_budget is invalid")
+	}
+
+	if cfg.RegionGossip && cfg.Region == "" {
+		return errors.New("cluster.region_gossip needs a cluster.region")
+	}
+
+	if cfg.UnpinGracePeriod < 0 {
+		return errors.New("cluster.unpin_grace_period is invalid")
+	}
+
 	if cfg.MonitorPingInterval <= 0 {
 		return errors.New("cluster.monitoring_interval is invalid")
 	}
@@ -373,6 +475,10 @@ func (cfg *Config) setDefaults() {
 	cfg.LeaveOnShutdown = DefaultLeaveOnShutdown
 	cfg.StateSyncInterval = DefaultStateSyncInterval
 	cfg.PinRecoverInterval = DefaultPinRecoverInterval
+	cfg.ExpirationInterval = DefaultExpirationInterval
+	cfg.IPNSFollowInterval = DefaultIPNSFollowInterval
+	cfg.UnpinGracePeriod = DefaultUnpinGracePeriod
+	cfg.ExpirationDryRun = DefaultExpirationDryRun
 	cfg.ReplicationFactorMin = DefaultReplicationFactor
 	cfg.ReplicationFactorMax = DefaultReplicationFactor
 	cfg.MonitorPingInterval = DefaultMonitorPingInterval
@@ -381,6 +487,13 @@ func (cfg *Config) setDefaults() {
 	cfg.PinOnlyOnTrustedPeers = DefaultPinOnlyOnTrustedPeers
 	cfg.DisableRepinning = DefaultDisableRepinning
 	cfg.FollowerMode = DefaultFollowerMode
+	cfg.PassiveMode = DefaultPassiveMode
+	cfg.ObserverMode = DefaultObserverMode
+	cfg.QuotaMaxBytes = 0
+	cfg.QuotaMaxPins = 0
+	cfg.GlobalConcurrencyBudget = 0
+	cfg.Region = ""
+	cfg.RegionGossip = false
 	cfg.PeerstoreFile = "" // empty so it gets omitted.
 	cfg.PeerAddresses = []ma.Multiaddr{}
 	cfg.RPCPolicy = DefaultRPCPolicy
@@ -448,6 +561,9 @@ func (cfg *Config) applyConfigJSON(jcfg *configJSON) error {
 		&config.DurationOpt{Duration: jcfg.DialPeerTimeout, Dst: &cfg.DialPeerTimeout, Name: "dial_peer_timeout"},
 		&config.DurationOpt{Duration: jcfg.StateSyncInterval, Dst: &cfg.StateSyncInterval, Name: "state_sync_interval"},
 		&config.DurationOpt{Duration: jcfg.PinRecoverInterval, Dst: &cfg.PinRecoverInterval, Name: "pin_recover_interval"},
+		&config.DurationOpt{Duration: jcfg.ExpirationInterval, Dst: &cfg.ExpirationInterval, Name: "expiration_interval"},
+		&config.DurationOpt{Duration: jcfg.IPNSFollowInterval, Dst: &cfg.IPNSFollowInterval, Name: "ipns_follow_interval"},
+		&config.DurationOpt{Duration: jcfg.UnpinGracePeriod, Dst: &cfg.UnpinGracePeriod, Name: "unpin_grace_period"},
 		&config.DurationOpt{Duration: jcfg.MonitorPingInterval, Dst: &cfg.MonitorPingInterval, Name: "monitor_ping_interval"},
 		&config.DurationOpt{Duration: jcfg.PeerWatchInterval, Dst: &cfg.PeerWatchInterval, Name: "peer_watch_interval"},
 		&config.DurationOpt{Duration: jcfg.MDNSInterval, Dst: &cfg.MDNSInterval, Name: "mdns_interval"},
@@ -470,7 +586,15 @@ func (cfg *Config) applyConfigJSON(jcfg *configJSON) error {
 	cfg.LeaveOnShutdown = jcfg.LeaveOnShutdown
 	cfg.PinOnlyOnTrustedPeers = jcfg.PinOnlyOnTrustedPeers
 	cfg.DisableRepinning = jcfg.DisableRepinning
+	cfg.ExpirationDryRun = jcfg.ExpirationDryRun
 	cfg.FollowerMode = jcfg.FollowerMode
+	cfg.PassiveMode = jcfg.PassiveMode
+	cfg.ObserverMode = jcfg.ObserverMode
+	cfg.QuotaMaxBytes = jcfg.QuotaMaxBytes
+	cfg.QuotaMaxPins = jcfg.QuotaMaxPins
+	cfg.GlobalConcurrencyBudget = jcfg.GlobalConcurrencyBudget
+	cfg.Region = jcfg.Region
+	cfg.RegionGossip = jcfg.RegionGossip
 
 	return cfg.Validate()
 }
@@ -516,6 +640,10 @@ func (cfg *Config) toConfigJSON() (jcfg *configJSON, err error) {
 	jcfg.DialPeerTimeout = cfg.DialPeerTimeout.String()
 	jcfg.StateSyncInterval = cfg.StateSyncInterval.String()
 	jcfg.PinRecoverInterval = cfg.PinRecoverInterval.String()
+	jcfg.ExpirationInterval = cfg.ExpirationInterval.String()
+	jcfg.IPNSFollowInterval = cfg.IPNSFollowInterval.String()
+	jcfg.UnpinGracePeriod = cfg.UnpinGracePeriod.String()
+	jcfg.ExpirationDryRun = cfg.ExpirationDryRun
 	jcfg.MonitorPingInterval = cfg.MonitorPingInterval.String()
 	jcfg.PeerWatchInterval = cfg.PeerWatchInterval.String()
 	jcfg.MDNSInterval = cfg.MDNSInterval.String()
@@ -527,6 +655,13 @@ func (cfg *Config) toConfigJSON() (jcfg *configJSON, err error) {
 		jcfg.PeerAddresses = append(jcfg.PeerAddresses, addr.String())
 	}
 	jcfg.FollowerMode = cfg.FollowerMode
+	jcfg.PassiveMode = cfg.PassiveMode
+	jcfg.ObserverMode = cfg.ObserverMode
+	jcfg.QuotaMaxBytes = cfg.QuotaMaxBytes
+	jcfg.QuotaMaxPins = cfg.QuotaMaxPins
+	jcfg.GlobalConcurrencyBudget = cfg.GlobalConcurrencyBudget
+	jcfg.Region = cfg.Region
+	jcfg.RegionGossip = cfg.RegionGossip
 
 	return
 }
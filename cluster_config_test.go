@@ -25,11 +25,22 @@ var ccfgTestJSON = []byte(`
         ],
         "state_sync_interval": "1m0s",
         "pin_recover_interval": "1m",
+        "expiration_interval": "30s",
+        "expiration_dry_run": true,
+        "ipns_follow_interval": "10m",
+        "unpin_grace_period": "1h",
         "replication_factor_min": 5,
         "replication_factor_max": 5,
         "monitor_ping_interval": "2s",
         "pin_only_on_trusted_peers": true,
         "disable_repinning": true,
+        "passive_mode": true,
+        "observer_mode": true,
+        "quota_max_bytes": 1000000,
+        "quota_max_pins": 100,
+        "global_concurrency_budget": 20,
+        "region": "eu-west",
+        "region_gossip": true,
         "peer_addresses": [ "/ip4/127.0.0.1/tcp/1234/p2p/QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc" ]
 }
 `)
@@ -73,6 +84,41 @@ func TestLoadJSON(t *testing.T) {
 		}
 	})
 
+	t.Run("expected passive_mode", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if !cfg.PassiveMode {
+			t.Error("expected passive_mode to be true")
+		}
+	})
+
+	t.Run("expected observer_mode", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if !cfg.ObserverMode {
+			t.Error("expected observer_mode to be true")
+		}
+	})
+
+	t.Run("expected quotas", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if cfg.QuotaMaxBytes != 1000000 || cfg.QuotaMaxPins != 100 {
+			t.Error("expected quota_max_bytes and quota_max_pins to be set")
+		}
+	})
+
+	t.Run("expected global_concurrency_budget", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if cfg.GlobalConcurrencyBudget != 20 {
+			t.Error("expected global_concurrency_budget to be 20")
+		}
+	})
+
+	t.Run("expected region", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if cfg.Region != "eu-west" || !cfg.RegionGossip {
+			t.Error("expected region and region_gossip to be set")
+		}
+	})
+
 	t.Run("expected pin_only_on_trusted_peers", func(t *testing.T) {
 		cfg := loadJSON(t)
 		if !cfg.PinOnlyOnTrustedPeers {
@@ -87,6 +133,30 @@ func TestLoadJSON(t *testing.T) {
 		}
 	})
 
+	t.Run("expected expiration reaper settings", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if cfg.ExpirationInterval != 30*time.Second {
+			t.Error("expected expiration_interval of 30s")
+		}
+		if !cfg.ExpirationDryRun {
+			t.Error("expected expiration_dry_run to be true")
+		}
+	})
+
+	t.Run("expected ipns_follow_interval", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if cfg.IPNSFollowInterval != 10*time.Minute {
+			t.Error("expected ipns_follow_interval of 10m")
+		}
+	})
+
+	t.Run("expected unpin_grace_period", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if cfg.UnpinGracePeriod != time.Hour {
+			t.Error("expected unpin_grace_period of 1h")
+		}
+	})
+
 	t.Run("expected connection_manager", func(t *testing.T) {
 		cfg := loadJSON(t)
 		if cfg.ConnMgr.LowWater != 500 {
@@ -283,4 +353,40 @@ func TestValidate(t *testing.T) {
 	if cfg.Validate() == nil {
 		t.Fatal("expected error validating")
 	}
+
+	cfg.Default()
+	cfg.ExpirationInterval = -time.Second
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.IPNSFollowInterval = -time.Second
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.UnpinGracePeriod = -time.Second
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.QuotaMaxPins = -1
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.GlobalConcurrencyBudget = -1
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.RegionGossip = true
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
 }
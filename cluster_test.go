@@ -21,6 +21,8 @@ import (
 	"github.com/ipfs-cluster/ipfs-cluster/test"
 	"github.com/ipfs-cluster/ipfs-cluster/version"
 
+	ds "github.com/ipfs/go-datastore"
+	merkledag "github.com/ipfs/go-merkledag"
 	gopath "github.com/ipfs/go-path"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
 	peer "github.com/libp2p/go-libp2p/core/peer"
@@ -78,6 +80,10 @@ func (ipfs *mockConnector) Unpin(ctx context.Context, c api.Cid) error {
 	return nil
 }
 
+func (ipfs *mockConnector) PinProgress(ctx context.Context, c api.Cid) (api.PinProgress, error) {
+	return api.PinProgress{}, nil
+}
+
 func (ipfs *mockConnector) PinLsCid(ctx context.Context, pin api.Pin) (api.IPFSPinStatus, error) {
 	dI, ok := ipfs.pins.Load(pin.Cid)
 	if !ok {
@@ -154,6 +160,11 @@ func (ipfs *mockConnector) BlockGet(ctx context.Context, c api.Cid) ([]byte, err
 	return d.([]byte), nil
 }
 
+func (ipfs *mockConnector) BlockHas(ctx context.Context, c api.Cid) (bool, error) {
+	_, ok := ipfs.blocks.Load(c.String())
+	return ok, nil
+}
+
 type mockTracer struct {
 	mockComponent
 }
@@ -283,6 +294,116 @@ func TestClusterStateSync(t *testing.T) {
 	}
 }
 
+func TestClusterReapExpiredPins(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer shutdownTestingCluster(ctx, t, cl)
+
+	events, cancel := cl.events.subscribe()
+	defer cancel()
+
+	expireIn := 500 * time.Millisecond
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{ExpireAt: time.Now().Add(expireIn)})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+	time.Sleep(expireIn)
+
+	nextExpired := func(t *testing.T) api.Event {
+		t.Helper()
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case ev := <-events:
+				if ev.Type == api.EventPinExpired {
+					return ev
+				}
+			case <-timeout:
+				t.Fatal("expected a pin expired event")
+			}
+		}
+	}
+
+	cl.config.ExpirationDryRun = true
+	if err := cl.reapExpiredPins(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ev := nextExpired(t)
+	if !ev.DryRun || ev.Pin == nil || !ev.Pin.Cid.Equals(test.Cid1) || ev.Peer != cl.id {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+	if _, err := cl.PinGet(ctx, test.Cid1); err != nil {
+		t.Error("dry-run should not unpin:", err)
+	}
+
+	cl.config.ExpirationDryRun = false
+	if err := cl.reapExpiredPins(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ev = nextExpired(t)
+	if ev.DryRun || ev.Pin == nil || !ev.Pin.Cid.Equals(test.Cid1) {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+	if _, err := cl.PinGet(ctx, test.Cid1); err == nil {
+		t.Error("expected the expired pin to be unpinned")
+	}
+	if _, err := cl.PinGet(ctx, test.Cid2); err != nil {
+		t.Error("pins without expiration should not be unpinned:", err)
+	}
+}
+
+func TestClusterUpdateFollowedPins(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer shutdownTestingCluster(ctx, t, cl)
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{Name: "followed", Follow: "/ipns/example.org"})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+	_, err = cl.Pin(ctx, test.Cid3, api.PinOptions{Follow: "example.org"})
+	if err == nil {
+		t.Error("expected an error following a non /ipns/ path")
+	}
+
+	if err := cl.updateFollowedPins(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The mock resolves every name to CidResolved.
+	pin, err := cl.PinGet(ctx, test.CidResolved)
+	if err != nil {
+		t.Fatal("expected the pin to follow the name:", err)
+	}
+	if pin.Follow != "/ipns/example.org" || pin.Name != "followed" || !pin.PinUpdate.Equals(test.Cid1) {
+		t.Errorf("unexpected updated pin: %+v", pin)
+	}
+	if _, err := cl.PinGet(ctx, test.Cid1); err == nil {
+		t.Error("expected the previous version to be unpinned")
+	}
+	if _, err := cl.PinGet(ctx, test.Cid2); err != nil {
+		t.Error("pins not following names should not change:", err)
+	}
+
+	// Nothing to do when the name did not change.
+	if err := cl.updateFollowedPins(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.PinGet(ctx, test.CidResolved); err != nil {
+		t.Error("expected the pin to stay:", err)
+	}
+}
+
 func TestClusterID(t *testing.T) {
 	ctx := context.Background()
 	cl, _, _, _ := testingCluster(t)
@@ -336,6 +457,567 @@ func TestClusterPin(t *testing.T) {
 	}
 }
 
+func TestClusterHealthCheck(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer shutdownTestingCluster(ctx, t, cl)
+
+	// Wait for the metrics of this peer to reach the monitor.
+	var report api.HealthReport
+	for i := 0; i < 20; i++ {
+		report = cl.HealthCheck(ctx)
+		if report.Healthy {
+			break
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	if !report.Healthy || report.Peer != cl.id {
+		t.Fatalf("the peer should be healthy: %+v", report)
+	}
+	expected := []string{
+		api.HealthComponentIPFS,
+		api.HealthComponentConsensus,
+		api.HealthComponentDatastore,
+		api.HealthComponentMonitor,
+	}
+	if len(report.Components) != len(expected) {
+		t.Fatalf("expected %d components: %+v", len(expected), report.Components)
+	}
+	for i, comp := range report.Components {
+		if comp.Component != expected[i] || !comp.Healthy || comp.Error != "" {
+			t.Errorf("unexpected component result: %+v", comp)
+		}
+	}
+
+	if _, err := cl.datastore.Get(ctx, healthCheckKey); err != ds.ErrNotFound {
+		t.Error("the datastore check should not leave keys behind")
+	}
+}
+
+func TestClusterEvents(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer shutdownTestingCluster(ctx, t, cl)
+
+	evCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	out := make(chan api.Event, 10)
+	go cl.Events(evCtx, out)
+	// Let the subscription happen.
+	time.Sleep(100 * time.Millisecond)
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case <-timeout:
+			t.Fatal("no pinned event received")
+		case ev := <-out:
+			if ev.Type != api.EventPinStatus {
+				continue
+			}
+			if ev.PinInfo == nil || !ev.PinInfo.Cid.Equals(test.Cid1) || ev.Peer != cl.id {
+				t.Errorf("unexpected event: %+v", ev)
+			}
+			if ev.PinInfo.Status == api.TrackerStatusPinned {
+				return
+			}
+		}
+	}
+}
+
+func TestClusterPublishPeerChanges(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer shutdownTestingCluster(ctx, t, cl)
+
+	events, cancel := cl.events.subscribe()
+	defer cancel()
+
+	cl.publishPeerChanges(
+		[]peer.ID{test.PeerID1, test.PeerID2},
+		[]peer.ID{test.PeerID2, test.PeerID3},
+	)
+
+	joined := <-events
+	left := <-events
+	if joined.Type != api.EventPeerJoined || joined.Peer != test.PeerID3 {
+		t.Errorf("unexpected event: %+v", joined)
+	}
+	if left.Type != api.EventPeerLeft || left.Peer != test.PeerID1 {
+		t.Errorf("unexpected event: %+v", left)
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected event: %+v", ev)
+	default:
+	}
+}
+
+func TestClusterStatusAllFilteredPages(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer shutdownTestingCluster(ctx, t, cl)
+
+	for _, c := range []api.Cid{test.Cid1, test.Cid2, test.Cid3} {
+		_, err := cl.Pin(ctx, c, api.PinOptions{Name: "paged"})
+		if err != nil {
+			t.Fatal("pin should have worked:", err)
+		}
+	}
+	_, err := cl.Pin(ctx, test.Cid4, api.PinOptions{Name: "other"})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+	pinDelay()
+
+	statusAll := func(filter api.StatusAllFilter) []api.Cid {
+		out := make(chan api.GlobalPinInfo, 10)
+		err := cl.StatusAllFiltered(ctx, filter, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cids []api.Cid
+		for gpi := range out {
+			cids = append(cids, gpi.Cid)
+		}
+		return cids
+	}
+
+	// Pages follow the order of the state.
+	all := statusAll(api.StatusAllFilter{Name: "paged", Limit: 10})
+	if len(all) != 3 {
+		t.Fatalf("expected 3 items: %v", all)
+	}
+	var stateOrder []api.Cid
+	pins, err := cl.pinsSlice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range pins {
+		if p.Name == "paged" {
+			stateOrder = append(stateOrder, p.Cid)
+		}
+	}
+	for i := range all {
+		if !all[i].Equals(stateOrder[i]) {
+			t.Errorf("paginated results should be in state order: %v %v", all, stateOrder)
+		}
+	}
+
+	page := statusAll(api.StatusAllFilter{Name: "paged", Limit: 2})
+	if len(page) != 2 || !page[0].Equals(all[0]) || !page[1].Equals(all[1]) {
+		t.Errorf("unexpected first page: %v", page)
+	}
+	page = statusAll(api.StatusAllFilter{Name: "paged", Limit: 2, After: page[1]})
+	if len(page) != 1 || !page[0].Equals(all[2]) {
+		t.Errorf("unexpected second page: %v", page)
+	}
+	page = statusAll(api.StatusAllFilter{Name: "paged", Offset: 1})
+	if len(page) != 2 || !page[0].Equals(all[1]) {
+		t.Errorf("unexpected page with offset: %v", page)
+	}
+	page = statusAll(api.StatusAllFilter{Name: "paged", Offset: 3})
+	if len(page) != 0 {
+		t.Errorf("expected an empty page: %v", page)
+	}
+}
+
+func TestClusterPinBatch(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer shutdownTestingCluster(ctx, t, cl)
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+
+	results, err := cl.PinBatch(ctx, []api.BatchItem{
+		{Action: api.BatchActionPin, Cid: test.Cid2, Options: api.PinOptions{Name: "batched"}},
+		{Action: api.BatchActionUnpin, Cid: test.Cid1},
+		{Action: api.BatchActionUnpin, Cid: test.Cid3},
+		{Action: api.BatchActionPin, Cid: test.Cid2},
+		{Action: "recover", Cid: test.Cid4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 5 {
+		t.Fatalf("expected 5 results: %+v", results)
+	}
+	if results[0].Error != "" || results[0].Pin.Name != "batched" {
+		t.Errorf("pin should have worked: %+v", results[0])
+	}
+	if results[1].Error != "" || !results[1].Pin.Cid.Equals(test.Cid1) {
+		t.Errorf("unpin should have worked: %+v", results[1])
+	}
+	for _, i := range []int{2, 3, 4} {
+		if results[i].Error == "" {
+			t.Errorf("item %d should have failed", i)
+		}
+	}
+
+	pinDelay()
+
+	if _, err := cl.PinGet(ctx, test.Cid2); err != nil {
+		t.Error("Cid2 should be pinned:", err)
+	}
+	if _, err := cl.PinGet(ctx, test.Cid1); err == nil {
+		t.Error("Cid1 should have been unpinned")
+	}
+}
+
+func TestClusterGroups(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer shutdownTestingCluster(ctx, t, cl)
+
+	for _, c := range []api.Cid{test.Cid1, test.Cid2} {
+		_, err := cl.Pin(ctx, c, api.PinOptions{Group: "dataset"})
+		if err != nil {
+			t.Fatal("pin should have worked:", err)
+		}
+	}
+	_, err := cl.Pin(ctx, test.Cid3, api.PinOptions{})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+	pinDelay()
+
+	groups, err := cl.Groups(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Name != "dataset" || groups[0].Pins != 2 {
+		t.Errorf("unexpected groups: %+v", groups)
+	}
+
+	pins, err := cl.groupPins(ctx, "dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 2 {
+		t.Errorf("expected 2 pins in the group: %+v", pins)
+	}
+	if _, err := cl.groupPins(ctx, ""); err != errNoGroup {
+		t.Error("expected an error without a group name")
+	}
+
+	results, err := cl.SetGroupReplication(ctx, "dataset", 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results: %+v", results)
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			t.Errorf("setting the replication should have worked: %+v", r)
+		}
+	}
+	pinDelay()
+
+	pin, err := cl.PinGet(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.ReplicationFactorMin != 1 || pin.ReplicationFactorMax != 1 || pin.Group != "dataset" {
+		t.Errorf("unexpected pin after setting the replication: %+v", pin)
+	}
+
+	if _, err := cl.SetGroupReplication(ctx, "dataset", 2, 1); err == nil {
+		t.Error("expected an error with bad replication factors")
+	}
+
+	results, err = cl.UnpinGroup(ctx, "dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results: %+v", results)
+	}
+	pinDelay()
+
+	for _, c := range []api.Cid{test.Cid1, test.Cid2} {
+		if _, err := cl.PinGet(ctx, c); err == nil {
+			t.Errorf("%s should have been unpinned", c)
+		}
+	}
+	if _, err := cl.PinGet(ctx, test.Cid3); err != nil {
+		t.Error("Cid3 should still be pinned:", err)
+	}
+	groups, err = cl.Groups(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 0 {
+		t.Errorf("the group should be gone: %+v", groups)
+	}
+}
+
+func TestClusterUndoUnpin(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer shutdownTestingCluster(ctx, t, cl)
+
+	for _, c := range []api.Cid{test.Cid1, test.Cid2, test.Cid3} {
+		_, err := cl.Pin(ctx, c, api.PinOptions{Name: "undo"})
+		if err != nil {
+			t.Fatal("pin should have worked:", err)
+		}
+	}
+	pinDelay()
+
+	// Without grace period, unpins cannot be undone.
+	_, err := cl.Unpin(ctx, test.Cid3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl.config.UnpinGracePeriod = time.Minute
+	_, err = cl.Unpin(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.PinBatch(ctx, []api.BatchItem{
+		{Action: api.BatchActionUnpin, Cid: test.Cid2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	if _, err := cl.UndoUnpin(ctx, test.Cid3); err != errNoTombstone {
+		t.Error("expected an error undoing an unpin without grace period")
+	}
+
+	for _, c := range []api.Cid{test.Cid1, test.Cid2} {
+		if _, err := cl.PinGet(ctx, c); err == nil {
+			t.Fatalf("%s should have been unpinned", c)
+		}
+		tombstone, err := cl.Tombstone(ctx, c)
+		if err != nil || tombstone.Name != "undo" {
+			t.Errorf("the tombstone should keep the unpinned pin: %+v %s", tombstone, err)
+		}
+		pin, err := cl.UndoUnpin(ctx, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pin.Name != "undo" {
+			t.Errorf("the pin should keep its options: %+v", pin)
+		}
+	}
+	pinDelay()
+
+	for _, c := range []api.Cid{test.Cid1, test.Cid2} {
+		pin, err := cl.PinGet(ctx, c)
+		if err != nil {
+			t.Fatalf("%s should be pinned again: %s", c, err)
+		}
+		if pin.Name != "undo" {
+			t.Errorf("unexpected pin after undoing the unpin: %+v", pin)
+		}
+	}
+
+	if _, err := cl.UndoUnpin(ctx, test.Cid1); err != errNoTombstone {
+		t.Error("an unpin can only be undone once")
+	}
+	if _, err := cl.Tombstone(ctx, test.Cid1); err != errNoTombstone {
+		t.Error("the tombstone should be gone once the unpin is undone")
+	}
+}
+
+func TestClusterQuotaUsedPins(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer shutdownTestingCluster(ctx, t, cl)
+
+	cl.config.QuotaMaxPins = 10
+	// Pings may have counted the pins already.
+	recount := func() {
+		cl.quotaPinsMux.Lock()
+		cl.quotaPinsCounted = time.Time{}
+		cl.quotaPinsMux.Unlock()
+	}
+	usedPins := func() int {
+		q, err := cl.quotaUsage(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return q.UsedPins
+	}
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+	recount()
+	if used := usedPins(); used != 1 {
+		t.Errorf("expected 1 pin used: %d", used)
+	}
+
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+	if used := usedPins(); used != 1 {
+		t.Errorf("the last count should be used until the interval passes: %d", used)
+	}
+
+	recount()
+	if used := usedPins(); used != 2 {
+		t.Errorf("expected 2 pins used once counted again: %d", used)
+	}
+}
+
+func TestClusterReallocate(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer shutdownTestingCluster(ctx, t, cl)
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{
+		Metadata: map[string]string{"dataset": "climate"},
+	})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+	pinDelay()
+
+	re := api.Reallocation{
+		Cids:                 []api.Cid{test.Cid2, test.Cid4},
+		Search:               api.PinSearch{Metadata: map[string]string{"dataset": "climate"}},
+		ReplicationFactorMin: 1,
+		ReplicationFactorMax: 1,
+	}
+	results, err := cl.Reallocate(ctx, re)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results: %+v", results)
+	}
+	for _, r := range results {
+		switch {
+		case r.Cid.Equals(test.Cid4):
+			if r.Error == "" {
+				t.Error("reallocating an unpinned cid should have failed")
+			}
+		case r.Error != "":
+			t.Errorf("reallocating should have worked: %+v", r)
+		}
+	}
+	pinDelay()
+
+	for _, c := range []api.Cid{test.Cid1, test.Cid2} {
+		pin, err := cl.PinGet(ctx, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pin.ReplicationFactorMin != 1 || pin.ReplicationFactorMax != 1 {
+			t.Errorf("unexpected pin after reallocating: %+v", pin)
+		}
+		if len(pin.Allocations) != 1 || pin.Allocations[0] != cl.id {
+			t.Errorf("the pin should be allocated to the peer: %+v", pin)
+		}
+	}
+
+	// Nothing changes when reallocating with the same factors.
+	results, err = cl.Reallocate(ctx, api.Reallocation{
+		Cids:                 []api.Cid{test.Cid1},
+		ReplicationFactorMin: 1,
+		ReplicationFactorMax: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Error != "" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+
+	if _, err := cl.Reallocate(ctx, api.Reallocation{}); err != errNoReallocation {
+		t.Error("expected an error without cids nor search")
+	}
+	re.ReplicationFactorMin = 2
+	if _, err := cl.Reallocate(ctx, re); err == nil {
+		t.Error("expected an error with bad replication factors")
+	}
+}
+
+func TestClusterPinEdit(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer shutdownTestingCluster(ctx, t, cl)
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{
+		Name:     "old",
+		Metadata: map[string]string{"a": "1", "b": "2"},
+	})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+	pinDelay()
+
+	pin, err := cl.PinGet(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := "new"
+	edited, err := cl.PinEdit(ctx, api.PinEdit{
+		Cid:      test.Cid1,
+		Name:     &name,
+		Metadata: map[string]string{"a": "", "c": "3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Name != "new" || len(edited.Metadata) != 2 ||
+		edited.Metadata["b"] != "2" || edited.Metadata["c"] != "3" {
+		t.Errorf("unexpected edited pin: %+v", edited)
+	}
+	if !edited.Timestamp.Equal(pin.Timestamp) {
+		t.Error("the timestamp should not change")
+	}
+
+	stored, err := cl.PinGet(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "new" || stored.Metadata["c"] != "3" {
+		t.Errorf("the edit should be in the state: %+v", stored)
+	}
+
+	if st := cl.StatusLocal(ctx, test.Cid1); st.Status != api.TrackerStatusPinned {
+		t.Error("the pin should stay pinned:", st.Status)
+	}
+
+	_, err = cl.PinEdit(ctx, api.PinEdit{Cid: test.Cid2, Name: &name})
+	if err != state.ErrNotFound {
+		t.Error("expected not found editing an unknown pin:", err)
+	}
+}
+
 func TestPinExpired(t *testing.T) {
 	ctx := context.Background()
 	cl, _, _, _ := testingCluster(t)
@@ -850,6 +1532,63 @@ func TestClusterPinGet(t *testing.T) {
 	}
 }
 
+func TestClusterVerifyPin(t *testing.T) {
+	ctx := context.Background()
+	cl, _, ipfs, _ := testingCluster(t)
+	defer cleanState()
+	defer shutdownTestingCluster(ctx, t, cl)
+
+	leaf1 := merkledag.NewRawNode([]byte("leaf1"))
+	leaf2 := merkledag.NewRawNode([]byte("leaf2"))
+	root := merkledag.NodeWithData([]byte("root"))
+	root.AddNodeLink("1", leaf1)
+	root.AddNodeLink("2", leaf2)
+	for _, n := range []*merkledag.RawNode{leaf1, leaf2} {
+		ipfs.blocks.Store(n.Cid().String(), n.RawData())
+	}
+	ipfs.blocks.Store(root.Cid().String(), root.RawData())
+
+	rootCid := api.NewCid(root.Cid())
+	_, err := cl.Pin(ctx, rootCid, api.PinOptions{})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+
+	res, err := cl.VerifyPin(ctx, rootCid, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OK() || res.Blocks != 3 || res.Checked != 3 || len(res.Peers) != 1 {
+		t.Errorf("unexpected verification: %+v", res)
+	}
+
+	res, err = cl.VerifyPin(ctx, rootCid, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Blocks != 3 || res.Checked != 1 {
+		t.Errorf("expected a single block to be checked: %+v", res)
+	}
+
+	ipfs.blocks.Delete(leaf2.Cid().String())
+	res, err = cl.VerifyPin(ctx, rootCid, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK() {
+		t.Fatal("expected the verification to fail")
+	}
+	missing := res.Peers[0].Missing
+	if len(missing) != 1 || missing[0].Cid != leaf2.Cid() {
+		t.Errorf("expected leaf2 to be missing: %+v", missing)
+	}
+
+	_, err = cl.VerifyPin(ctx, test.Cid2, 0)
+	if err == nil {
+		t.Error("expected an error verifying an unknown pin")
+	}
+}
+
 func TestClusterUnpin(t *testing.T) {
 	ctx := context.Background()
 	cl, _, _, _ := testingCluster(t)
@@ -1036,3 +1775,54 @@ func testRepoGC(t *testing.T, repoGC api.RepoGC) {
 		t.Errorf("expected a different cid, expected: %s, found: %s", test.Cid1, repoGC.Keys[0].Key)
 	}
 }
+
+func TestPinInfoMerger(t *testing.T) {
+	pinInfo := func(c api.Cid, p peer.ID, st api.TrackerStatus) api.PinInfo {
+		return api.PinInfo{
+			Cid:  c,
+			Peer: p,
+			PinInfoShort: api.PinInfoShort{
+				Status: st,
+			},
+		}
+	}
+
+	m := newPinInfoMerger(3, nil)
+	for _, p := range []peer.ID{test.PeerID1, test.PeerID2} {
+		if _, ok := m.add(pinInfo(test.Cid1, p, api.TrackerStatusPinned)); ok {
+			t.Fatal("the item should not be complete")
+		}
+	}
+	m.add(pinInfo(test.Cid2, test.PeerID1, api.TrackerStatusPinned))
+
+	gpi, ok := m.add(pinInfo(test.Cid1, test.PeerID3, api.TrackerStatusPinned))
+	if !ok || !gpi.Cid.Equals(test.Cid1) || len(gpi.PeerMap) != 3 {
+		t.Fatalf("expected a complete item: %+v", gpi)
+	}
+	if len(m.pending) != 1 {
+		t.Errorf("complete items should not be kept: %d", len(m.pending))
+	}
+
+	m.addError(test.PeerID2, api.PinInfoShort{Status: api.TrackerStatusClusterError})
+	gpis := m.incomplete()
+	if len(gpis) != 1 || len(gpis[0].PeerMap) != 2 {
+		t.Fatalf("unexpected incomplete items: %+v", gpis)
+	}
+	if gpis[0].PeerMap[test.PeerID2.String()].Status != api.TrackerStatusClusterError {
+		t.Error("expected an error for the failed peer")
+	}
+	if gpis[0].PeerMap[test.PeerID1.String()].Status != api.TrackerStatusPinned {
+		t.Error("reported items should keep their status")
+	}
+
+	// Items which are not kept do not produce GlobalPinInfos.
+	m = newPinInfoMerger(1, func(pi api.PinInfo) bool {
+		return pi.Status == api.TrackerStatusPinned
+	})
+	if _, ok := m.add(pinInfo(test.Cid1, test.PeerID1, api.TrackerStatusPinError)); ok {
+		t.Error("the item should have been discarded")
+	}
+	if len(m.pending) != 0 || len(m.incomplete()) != 0 {
+		t.Error("discarded items should not be kept")
+	}
+}
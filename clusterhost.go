@@ -94,7 +94,11 @@ func NewClusterHost(
 		return nil, nil, nil, err
 	}
 
-	psub, err := newPubSub(ctx, h)
+	var psubOpts []pubsub.Option
+	if cfg.RegionGossip {
+		psubOpts = regionGossipOptions(h, cfg.Region)
+	}
+	psub, err := newPubSub(ctx, h, psubOpts...)
 	if err != nil {
 		h.Close()
 		return nil, nil, nil, err
@@ -154,13 +158,15 @@ func newDHT(ctx context.Context, h host.Host, store ds.Datastore, extraopts ...d
 	return dual.New(ctx, h, opts...)
 }
 
-func newPubSub(ctx context.Context, h host.Host) (*pubsub.PubSub, error) {
-	return pubsub.NewGossipSub(
-		ctx,
-		h,
-		pubsub.WithMessageSigning(true),
-		pubsub.WithStrictSignatureVerification(true),
+func newPubSub(ctx context.Context, h host.Host, opts ...pubsub.Option) (*pubsub.PubSub, error) {
+	opts = append(
+		[]pubsub.Option{
+			pubsub.WithMessageSigning(true),
+			pubsub.WithStrictSignatureVerification(true),
+		},
+		opts...,
 	)
+	return pubsub.NewGossipSub(ctx, h, opts...)
 }
 
 // EncodeProtectorKey converts a byte slice to its hex string representation.
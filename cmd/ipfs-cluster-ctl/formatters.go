@@ -12,6 +12,7 @@ import (
 	"github.com/ipfs-cluster/ipfs-cluster/api"
 
 	humanize "github.com/dustin/go-humanize"
+	peer "github.com/libp2p/go-libp2p/core/peer"
 )
 
 type addedOutputQuiet struct {
@@ -78,6 +79,8 @@ func textFormatObject(resp interface{}) {
 		textFormatPrintAddedOutput(r)
 	case addedOutputQuiet:
 		textFormatPrintAddedOutputQuiet(r)
+	case api.AddJob:
+		textFormatPrintAddJob(r)
 	case api.Version:
 		textFormatPrintVersion(r)
 	case api.Error:
@@ -86,6 +89,10 @@ func textFormatObject(resp interface{}) {
 		textFormatPrintMetric(r)
 	case api.Alert:
 		textFormatPrintAlert(r)
+	case api.ConsensusOp:
+		textFormatPrintConsensusOp(r)
+	case api.PinTrackerConcurrency:
+		textFormatPrintPinTrackerConcurrency(r)
 	case chan api.ID:
 		for item := range r {
 			textFormatObject(item)
@@ -112,14 +119,40 @@ func textFormatObject(resp interface{}) {
 		}
 	case api.GlobalRepoGC:
 		textFormatPrintGlobalRepoGC(r)
+	case api.PinVerification:
+		textFormatPrintPinVerification(r)
+	case api.PinGroup:
+		textFormatPrintPinGroup(r)
+	case api.BatchResult:
+		textFormatPrintBatchResult(r)
+	case []api.PinGroup:
+		for _, item := range r {
+			textFormatObject(item)
+		}
+	case []api.BatchResult:
+		for _, item := range r {
+			textFormatObject(item)
+		}
 	case []string:
 		for _, item := range r {
 			textFormatObject(item)
 		}
+	case []peer.ID:
+		for _, item := range r {
+			textFormatObject(item.String())
+		}
 	case []api.Alert:
 		for _, item := range r {
 			textFormatObject(item)
 		}
+	case []api.ConsensusOp:
+		for _, item := range r {
+			textFormatObject(item)
+		}
+	case []api.PinTrackerConcurrency:
+		for _, item := range r {
+			textFormatObject(item)
+		}
 	default:
 		checkErr("", errors.New("unsupported type returned"+reflect.TypeOf(r).String()))
 	}
@@ -189,10 +222,21 @@ func textFormatPrintGPInfo(obj api.GlobalPinInfo) {
 		if v.Error != "" {
 			fmt.Fprintf(&b, ": %s", v.Error)
 		}
+		if p := v.Progress; p != nil {
+			fmt.Fprintf(&b, ": %s", humanize.Bytes(p.Bytes))
+			if p.Size > 0 {
+				fmt.Fprintf(&b, " / %s", humanize.Bytes(p.Size))
+			}
+			fmt.Fprintf(&b, " (%d blocks)", p.Blocks)
+		}
 		txt, _ := v.TS.MarshalText()
 		fmt.Fprintf(&b, " | %s", txt)
 		fmt.Fprintf(&b, " | Attempts: %d", v.AttemptCount)
 		fmt.Fprintf(&b, " | Priority: %t", v.PriorityPin)
+		if !v.NextRetry.IsZero() {
+			txt, _ := v.NextRetry.MarshalText()
+			fmt.Fprintf(&b, " | Next retry: %s", txt)
+		}
 		fmt.Fprintf(&b, "\n")
 	}
 	fmt.Print(b.String())
@@ -231,6 +275,18 @@ func textFormatPrintPin(obj api.Pin) {
 
 	fmt.Printf(" | %s", recStr)
 
+	if obj.Group != "" {
+		fmt.Printf(" | Group: %s", obj.Group)
+	}
+
+	if obj.Follow != "" {
+		fmt.Printf(" | Follow: %s", obj.Follow)
+	}
+
+	if obj.Owner != "" {
+		fmt.Printf(" | Owner: %s", obj.Owner)
+	}
+
 	fmt.Printf(" | Metadata:")
 	if len(obj.Metadata) == 0 {
 		fmt.Printf(" no")
@@ -250,6 +306,18 @@ func textFormatPrintPin(obj api.Pin) {
 	fmt.Printf(" | Added: %s\n", added)
 }
 
+func textFormatPrintPinGroup(obj api.PinGroup) {
+	fmt.Printf("%s | Pins: %d\n", obj.Name, obj.Pins)
+}
+
+func textFormatPrintBatchResult(obj api.BatchResult) {
+	if obj.Error != "" {
+		fmt.Printf("%s | %s | ERROR: %s\n", obj.Cid, obj.Action, obj.Error)
+		return
+	}
+	fmt.Printf("%s | %s | OK\n", obj.Cid, obj.Action)
+}
+
 func textFormatPrintAddedOutput(obj api.AddedOutput) {
 	fmt.Printf("added %s %s\n", obj.Cid, obj.Name)
 }
@@ -262,6 +330,17 @@ func textFormatPrintAddedOutputQuiet(obj addedOutputQuiet) {
 	}
 }
 
+func textFormatPrintAddJob(obj api.AddJob) {
+	fmt.Printf("%s | %s | Uploaded: %d bytes | Added: %d blocks, %d bytes", obj.ID, obj.Status, obj.Uploaded, obj.Blocks, obj.Bytes)
+	if obj.Cid.Defined() {
+		fmt.Printf(" | %s", obj.Cid)
+	}
+	if obj.Error != "" {
+		fmt.Printf(" | ERROR: %s", obj.Error)
+	}
+	fmt.Println()
+}
+
 func textFormatPrintMetric(obj api.Metric) {
 	v := obj.Value
 	if obj.Name == "freespace" && obj.Weight > 0 {
@@ -280,6 +359,65 @@ func textFormatPrintAlert(obj api.Alert) {
 	)
 }
 
+func textFormatPrintConsensusOp(obj api.ConsensusOp) {
+	fmt.Printf("%d | %s", obj.Height, obj.ID)
+	if !obj.Timestamp.IsZero() {
+		fmt.Printf(" | %s", obj.Timestamp.Format(time.RFC3339))
+	}
+	fmt.Printf(" | %d pins, %d unpins\n", len(obj.Pins), len(obj.Unpins))
+	for _, p := range obj.Pins {
+		fmt.Printf("  + %s | %s", p.Cid, p.Name)
+		if p.Owner != "" {
+			fmt.Printf(" | Owner: %s", p.Owner)
+		}
+		if !p.Timestamp.IsZero() {
+			fmt.Printf(" | %s", p.Timestamp.Format(time.RFC3339))
+		}
+		fmt.Println()
+	}
+	for _, c := range obj.Unpins {
+		fmt.Printf("  - %s\n", c)
+	}
+}
+
+func textFormatPrintPinTrackerConcurrency(obj api.PinTrackerConcurrency) {
+	if obj.Error != "" {
+		fmt.Printf("%s (%s) | ERROR: %s\n", obj.Peer, obj.Peername, obj.Error)
+		return
+	}
+	fmt.Printf("%s (%s) | pins: %d/%d", obj.Peer, obj.Peername, obj.Pins, obj.MaxPins)
+	if obj.Auto {
+		fmt.Printf(" (auto)")
+	}
+	fmt.Printf(" | unpins: %d", obj.Unpins)
+	if obj.Paused {
+		fmt.Printf(" | PAUSED")
+	}
+	fmt.Println()
+}
+
+func textFormatPrintPinVerification(obj api.PinVerification) {
+	fmt.Printf("%s: %d/%d blocks checked\n", obj.Cid, obj.Checked, obj.Blocks)
+	for _, item := range obj.Peers {
+		peer := item.Peer.String()
+		// If peer name is set, use it instead of peer ID.
+		if len(item.Peername) > 0 {
+			peer = item.Peername
+		}
+		switch {
+		case item.Error != "":
+			fmt.Printf("    > %-15s : ERROR: %s\n", peer, item.Error)
+		case len(item.Missing) > 0:
+			fmt.Printf("    > %-15s : MISSING %d blocks\n", peer, len(item.Missing))
+			for _, m := range item.Missing {
+				fmt.Printf("      - %s\n", m)
+			}
+		default:
+			fmt.Printf("    > %-15s : OK\n", peer)
+		}
+	}
+}
+
 func textFormatPrintGlobalRepoGC(obj api.GlobalRepoGC) {
 	peers := make(sort.StringSlice, 0, len(obj.PeerMap))
 	for peer := range obj.PeerMap {
@@ -296,7 +434,7 @@ func textFormatPrintGlobalRepoGC(obj api.GlobalRepoGC) {
 		if item.Error != "" {
 			fmt.Printf("%-15s | ERROR: %s\n", peer, item.Error)
 		} else {
-			fmt.Printf("%-15s\n", peer)
+			fmt.Printf("%-15s | Reclaimed: %s\n", peer, humanize.Bytes(item.Reclaimed))
 		}
 
 		fmt.Printf("  > CIDs:\n")
@@ -141,6 +141,10 @@ requires authorization. implies --https, which you can disable with --force-http
 			Name:  "force-http, f",
 			Usage: "force HTTP. only valid when using BasicAuth",
 		},
+		cli.BoolFlag{
+			Name:  "override-ownership",
+			Usage: "let admins unpin and modify pins owned by others",
+		},
 	}
 
 	app.Before = func(c *cli.Context) error {
@@ -163,6 +167,7 @@ requires authorization. implies --https, which you can disable with --force-http
 
 		cfg.SSL = c.Bool("https")
 		cfg.NoVerifyCert = c.Bool("no-check-certificate")
+		cfg.OverrideOwnership = c.Bool("override-ownership")
 		user, pass := parseCredentials(c.String("basic-auth"))
 		cfg.Username = user
 		cfg.Password = pass
@@ -282,6 +287,59 @@ cluster peers.
 						return nil
 					},
 				},
+				{
+					Name:  "trusted",
+					Usage: "list the trusted peers",
+					Description: `
+This command lists the peers trusted by the cluster peer, including those
+from its configuration. When the peer trusts all peers ("*" in trusted_peers
+for CRDT), any peer is trusted regardless of this list.
+`,
+					ArgsUsage: " ",
+					Flags:     []cli.Flag{},
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.TrustedPeers(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "trust",
+					Usage: "make all cluster peers trust a peer",
+					Description: `
+This command makes all cluster peers trust the given peer, without editing
+their configuration or restarting them. The change is recorded through the
+consensus layer (only supported with CRDT), so peers joining later trust the
+peer too.
+`,
+					ArgsUsage: "<peer ID>",
+					Flags:     []cli.Flag{},
+					Action: func(c *cli.Context) error {
+						p, err := peer.Decode(c.Args().First())
+						checkErr("parsing peer ID", err)
+						cerr := globalClient.TrustPeer(ctx, p)
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "distrust",
+					Usage: "make all cluster peers stop trusting a peer",
+					Description: `
+This command makes all cluster peers stop trusting the given peer, even if it
+is part of their configured trusted peers. Like "trust", the change is
+recorded through the consensus layer.
+`,
+					ArgsUsage: "<peer ID>",
+					Flags:     []cli.Flag{},
+					Action: func(c *cli.Context) error {
+						p, err := peer.Decode(c.Args().First())
+						checkErr("parsing peer ID", err)
+						cerr := globalClient.DistrustPeer(ctx, p)
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
 			},
 		},
 		{
@@ -296,8 +354,16 @@ arguments will automatically set --wrap-in-directory.
 
 Cluster "add" works, by default, just like "ipfs add" and has similar options
 in terms of DAG layout, chunker, hash function etc. It also supports adding
-CAR files directly (--format car), as long as they have a single root. When
-adding CAR files, all the options related to dag-building are ignored.
+CAR files directly (--car or --format car): their blocks are sent as they are
+to the allocated peers, without chunking them again, and their root is pinned.
+When the CAR files have several roots, or when wrapping is requested, the
+roots are linked from a new directory, which is pinned instead. When adding
+CAR files, all the options related to dag-building are ignored.
+
+With --format dag-json or --format dag-cbor, every file is instead an IPLD
+node, which is stored as dag-cbor (CIDv1) and pinned, like "ipfs dag put"
+does. Pinning is recursive, so the blocks linked from the nodes must be
+available in IPFS for the pins to complete.
 
 Added content will be allocated and sent block by block to the peers that
 should pin it (among which may not necessarily be the local ipfs daemon).
@@ -312,6 +378,14 @@ Optional replication-min and replication-max factors can be provided: -1 means
 "pin everywhere" and 0 means use cluster's default setting (i.e., replication
 factor set in config). Positive values indicate how many peers should pin this
 content.
+
+When re-adding a new version of some content, --replace <old-cid> sends the
+blocks to the peers pinning the old version, which already hold the
+unmodified ones, and pins the result as an update of the old pin, keeping its
+allocations and replication factors. The old pin is unpinned afterwards.
+
+With --verify, the add fails unless every allocated peer stores all the added
+blocks once pinned, as checked by "pin verify".
 `,
 			/*
 				Cluster Add supports handling huge files and sharding the resulting DAG among
@@ -362,6 +436,15 @@ content.
 					Name:  "expire-in",
 					Usage: "Duration after which the pin should be unpinned automatically",
 				},
+				cli.StringFlag{
+					Name:  "priority",
+					Value: "normal",
+					Usage: "Pinning priority: low, normal or high",
+				},
+				cli.StringFlag{
+					Name:  "group",
+					Usage: "Adds the pin to the given group",
+				},
 				cli.StringSliceFlag{
 					Name:  "metadata",
 					Usage: "Pin metadata: key=value. Can be added multiple times",
@@ -388,7 +471,11 @@ content.
 				cli.StringFlag{
 					Name:  "format",
 					Value: defaultAddParams.Format,
-					Usage: "'unixfs' (add as unixfs DAG), 'car' (import CAR file)",
+					Usage: "'unixfs' (add as unixfs DAG), 'car' (import CAR file), 'dag-json' or 'dag-cbor' (add IPLD nodes)",
+				},
+				cli.BoolFlag{
+					Name:  "car",
+					Usage: "Import CAR files, like --format car",
 				},
 
 				cli.StringFlag{
@@ -402,7 +489,7 @@ content.
 				},
 				cli.StringFlag{
 					Name:  "chunker, s",
-					Usage: "'size-<size>' or 'rabin-<min>-<avg>-<max>'",
+					Usage: "'size-<size>', 'rabin', 'rabin-<min>-<avg>-<max>' or 'buzhash'",
 					Value: defaultAddParams.Chunker,
 				},
 				cli.BoolFlag{
@@ -423,6 +510,14 @@ content.
 					Name:  "nocopy",
 					Usage: "Add the URL using filestore. Implies raw-leaves. (experimental)",
 				},
+				cli.StringFlag{
+					Name:  "replace",
+					Usage: "Pin the result as an update of the given CID, reusing its allocations, and unpin the latter",
+				},
+				cli.BoolFlag{
+					Name:  "verify",
+					Usage: "Check that the allocations store all the blocks once pinned (see \"pin verify\")",
+				},
 
 				// TODO: Uncomment when sharding is supported.
 				// cli.BoolFlag{
@@ -472,6 +567,10 @@ content.
 					checkErr("parsing expire-in", err)
 					p.ExpireAt = time.Now().Add(d)
 				}
+				prio, err := api.PinPriorityFromString(c.String("priority"))
+				checkErr("parsing priority", err)
+				p.Priority = prio
+				p.Group = c.String("group")
 
 				p.Metadata = parseMetadata(c.StringSlice("metadata"))
 				p.Name = name
@@ -479,6 +578,12 @@ content.
 					p.UserAllocations = api.StringsToPeers(strings.Split(c.String("allocations"), ","))
 				}
 				p.Format = c.String("format")
+				if c.Bool("car") {
+					if c.IsSet("format") && p.Format != "car" {
+						checkErr("", errors.New("--car cannot be used with --format "+p.Format))
+					}
+					p.Format = "car"
+				}
 				//p.Shard = shard
 				//p.ShardSize = c.Uint64("shard-size")
 				p.Shard = false
@@ -501,12 +606,14 @@ content.
 				if p.NoCopy {
 					p.RawLeaves = true
 				}
-
-				// Prevent footgun
-				if p.Wrap && p.Format == "car" {
-					checkErr("", errors.New("only a single CAR file can be added and wrap-with-directory is not supported"))
+				if replace := c.String("replace"); replace != "" {
+					ci, err := api.DecodeCid(replace)
+					checkErr("parsing replace", err)
+					p.PinUpdate = ci
 				}
+				p.Verify = c.Bool("verify")
 
+				var root api.Cid // last output, set when done
 				out := make(chan api.AddedOutput, 1)
 				var wg sync.WaitGroup
 				wg.Add(1)
@@ -535,6 +642,7 @@ content.
 					if !lastBuf.AddedOutput.Cid.Defined() {
 						return // no elements at all
 					}
+					root = lastBuf.AddedOutput.Cid
 					if bufferResults { // we buffered.
 						if qq { // [last elem]
 							formatResponse(c, []addedOutputQuiet{lastBuf}, nil)
@@ -561,10 +669,298 @@ content.
 
 				cerr := globalClient.Add(ctx, paths, p, out)
 				wg.Wait()
+				if cerr == nil && p.PinUpdate.Defined() && !root.Equals(p.PinUpdate) {
+					_, cerr = globalClient.Unpin(ctx, p.PinUpdate)
+				}
 				formatResponse(c, nil, cerr)
 				return cerr
 			},
 		},
+		{
+			Name:      "add-url",
+			Usage:     "Have the cluster peer fetch a web URL, add it and pin it",
+			ArgsUsage: "<url>",
+			Description: `
+This command asks the cluster peer to download the content at the given
+HTTP(S) URL and add it as "add" would, so that large remote files do not
+need to go through this machine. The add runs in the background in the
+peer: the command returns the add job, whose progress can be checked with
+"add-job", unless --wait is given, in which case it waits for the add to
+finish.
+`,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "local",
+					Usage: "Add to local peer but pin normally",
+				},
+				cli.StringFlag{
+					Name:  "name, n",
+					Value: defaultAddParams.Name,
+					Usage: "Sets a name for this pin",
+				},
+				cli.IntFlag{
+					Name:  "replication-min, rmin",
+					Value: defaultAddParams.ReplicationFactorMin,
+					Usage: "Sets the minimum replication factor for pinning this file",
+				},
+				cli.IntFlag{
+					Name:  "replication-max, rmax",
+					Value: defaultAddParams.ReplicationFactorMax,
+					Usage: "Sets the maximum replication factor for pinning this file",
+				},
+				cli.StringFlag{
+					Name:  "expire-in",
+					Usage: "Duration after which the pin should be unpinned automatically",
+				},
+				cli.StringFlag{
+					Name:  "priority",
+					Value: "normal",
+					Usage: "Pinning priority: low, normal or high",
+				},
+				cli.StringFlag{
+					Name:  "group",
+					Usage: "Adds the pin to the given group",
+				},
+				cli.StringSliceFlag{
+					Name:  "metadata",
+					Usage: "Pin metadata: key=value. Can be added multiple times",
+				},
+				cli.StringFlag{
+					Name:  "allocations, allocs",
+					Usage: "Optional comma-separated list of peer IDs",
+				},
+				cli.StringFlag{
+					Name:  "layout",
+					Value: defaultAddParams.Layout,
+					Usage: "Dag layout to use for dag generation: balanced or trickle",
+				},
+				cli.StringFlag{
+					Name:  "chunker, s",
+					Usage: "'size-<size>', 'rabin', 'rabin-<min>-<avg>-<max>' or 'buzhash'",
+					Value: defaultAddParams.Chunker,
+				},
+				cli.BoolFlag{
+					Name:  "raw-leaves",
+					Usage: "Use raw blocks for leaves (experimental)",
+				},
+				cli.IntFlag{
+					Name:  "cid-version",
+					Usage: "CID version. Non default implies raw-leaves",
+					Value: defaultAddParams.CidVersion,
+				},
+				cli.StringFlag{
+					Name:  "hash",
+					Usage: "Hash function to use. Implies cid-version=1",
+					Value: defaultAddParams.HashFun,
+				},
+				cli.BoolFlag{
+					Name:  "wait",
+					Usage: "Wait for the add to finish before returning",
+				},
+				cli.DurationFlag{
+					Name:  "wait-timeout, wt",
+					Value: 0,
+					Usage: waitTimeoutFlagDesc,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				u := c.Args().First()
+				if u == "" {
+					checkErr("", errors.New("need a URL"))
+				}
+
+				p := api.DefaultAddParams()
+				p.ReplicationFactorMin = c.Int("replication-min")
+				p.ReplicationFactorMax = c.Int("replication-max")
+				if expireIn := c.String("expire-in"); expireIn != "" {
+					d, err := time.ParseDuration(expireIn)
+					checkErr("parsing expire-in", err)
+					p.ExpireAt = time.Now().Add(d)
+				}
+				prio, err := api.PinPriorityFromString(c.String("priority"))
+				checkErr("parsing priority", err)
+				p.Priority = prio
+				p.Group = c.String("group")
+				p.Metadata = parseMetadata(c.StringSlice("metadata"))
+				p.Name = c.String("name")
+				if c.String("allocations") != "" {
+					p.UserAllocations = api.StringsToPeers(strings.Split(c.String("allocations"), ","))
+				}
+				p.Local = c.Bool("local")
+				p.Layout = c.String("layout")
+				p.Chunker = c.String("chunker")
+				p.RawLeaves = c.Bool("raw-leaves")
+				p.CidVersion = c.Int("cid-version")
+				p.HashFun = c.String("hash")
+				if p.HashFun != defaultAddParams.HashFun {
+					p.CidVersion = 1
+				}
+				if p.CidVersion > 0 {
+					p.RawLeaves = true
+				}
+
+				job, err := globalClient.AddURL(ctx, u, p)
+				if err == nil && c.Bool("wait") {
+					job, err = waitForAddJob(job, c.Duration("wait-timeout"))
+				}
+				formatResponse(c, job, err)
+				return nil
+			},
+		},
+		{
+			Name:      "add-s3",
+			Usage:     "Have the cluster peer fetch objects from S3, add them and pin them",
+			ArgsUsage: "<bucket> [prefix]",
+			Description: `
+This command asks the cluster peer to download the objects whose keys start
+with the given prefix (all of them by default) from a bucket of S3-compatible
+object storage, and add them as "add" would, with the keys turned into paths
+inside a wrapping directory. The storage endpoint and credentials are set in
+the "restapi" section of the peer configuration (s3_endpoint, s3_region,
+s3_access_key_id and s3_secret_access_key), so they never go through this
+machine. As with "add-url", the add runs in the background: the command
+returns the add job, whose progress can be checked with "add-job", unless
+--wait is given.
+`,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "local",
+					Usage: "Add to local peer but pin normally",
+				},
+				cli.StringFlag{
+					Name:  "name, n",
+					Value: defaultAddParams.Name,
+					Usage: "Sets a name for this pin",
+				},
+				cli.IntFlag{
+					Name:  "replication-min, rmin",
+					Value: defaultAddParams.ReplicationFactorMin,
+					Usage: "Sets the minimum replication factor for pinning this file",
+				},
+				cli.IntFlag{
+					Name:  "replication-max, rmax",
+					Value: defaultAddParams.ReplicationFactorMax,
+					Usage: "Sets the maximum replication factor for pinning this file",
+				},
+				cli.StringFlag{
+					Name:  "expire-in",
+					Usage: "Duration after which the pin should be unpinned automatically",
+				},
+				cli.StringFlag{
+					Name:  "priority",
+					Value: "normal",
+					Usage: "Pinning priority: low, normal or high",
+				},
+				cli.StringFlag{
+					Name:  "group",
+					Usage: "Adds the pin to the given group",
+				},
+				cli.StringSliceFlag{
+					Name:  "metadata",
+					Usage: "Pin metadata: key=value. Can be added multiple times",
+				},
+				cli.StringFlag{
+					Name:  "allocations, allocs",
+					Usage: "Optional comma-separated list of peer IDs",
+				},
+				cli.StringFlag{
+					Name:  "layout",
+					Value: defaultAddParams.Layout,
+					Usage: "Dag layout to use for dag generation: balanced or trickle",
+				},
+				cli.StringFlag{
+					Name:  "chunker, s",
+					Usage: "'size-<size>', 'rabin', 'rabin-<min>-<avg>-<max>' or 'buzhash'",
+					Value: defaultAddParams.Chunker,
+				},
+				cli.BoolFlag{
+					Name:  "raw-leaves",
+					Usage: "Use raw blocks for leaves (experimental)",
+				},
+				cli.IntFlag{
+					Name:  "cid-version",
+					Usage: "CID version. Non default implies raw-leaves",
+					Value: defaultAddParams.CidVersion,
+				},
+				cli.StringFlag{
+					Name:  "hash",
+					Usage: "Hash function to use. Implies cid-version=1",
+					Value: defaultAddParams.HashFun,
+				},
+				cli.BoolFlag{
+					Name:  "wait",
+					Usage: "Wait for the add to finish before returning",
+				},
+				cli.DurationFlag{
+					Name:  "wait-timeout, wt",
+					Value: 0,
+					Usage: waitTimeoutFlagDesc,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				bucket := c.Args().First()
+				if bucket == "" {
+					checkErr("", errors.New("need a bucket"))
+				}
+				prefix := c.Args().Get(1)
+
+				p := api.DefaultAddParams()
+				p.ReplicationFactorMin = c.Int("replication-min")
+				p.ReplicationFactorMax = c.Int("replication-max")
+				if expireIn := c.String("expire-in"); expireIn != "" {
+					d, err := time.ParseDuration(expireIn)
+					checkErr("parsing expire-in", err)
+					p.ExpireAt = time.Now().Add(d)
+				}
+				prio, err := api.PinPriorityFromString(c.String("priority"))
+				checkErr("parsing priority", err)
+				p.Priority = prio
+				p.Group = c.String("group")
+				p.Metadata = parseMetadata(c.StringSlice("metadata"))
+				p.Name = c.String("name")
+				if c.String("allocations") != "" {
+					p.UserAllocations = api.StringsToPeers(strings.Split(c.String("allocations"), ","))
+				}
+				p.Local = c.Bool("local")
+				p.Layout = c.String("layout")
+				p.Chunker = c.String("chunker")
+				p.RawLeaves = c.Bool("raw-leaves")
+				p.CidVersion = c.Int("cid-version")
+				p.HashFun = c.String("hash")
+				if p.HashFun != defaultAddParams.HashFun {
+					p.CidVersion = 1
+				}
+				if p.CidVersion > 0 {
+					p.RawLeaves = true
+				}
+
+				job, err := globalClient.AddS3(ctx, bucket, prefix, p)
+				if err == nil && c.Bool("wait") {
+					job, err = waitForAddJob(job, c.Duration("wait-timeout"))
+				}
+				formatResponse(c, job, err)
+				return nil
+			},
+		},
+		{
+			Name:      "add-job",
+			Usage:     "Show the progress of an add running in the background",
+			ArgsUsage: "<job-id>",
+			Description: `
+This command shows the progress of an add running in the background in the
+cluster peer, like those started by "add-url" and "add-s3". Add jobs are only known to
+the peer running them, and are forgotten a while after they finish.
+`,
+			Action: func(c *cli.Context) error {
+				id := c.Args().First()
+				if id == "" {
+					checkErr("", errors.New("need a job ID"))
+				}
+				job, err := globalClient.AddJob(ctx, id)
+				formatResponse(c, job, err)
+				return nil
+			},
+		},
 		{
 			Name:        "pin",
 			Usage:       "Pin and unpin and list items in IPFS Cluster",
@@ -589,6 +985,10 @@ An optional allocations argument can be provided, allocations should be a
 comma-separated list of peer IDs on which we want to pin. Peers in allocations
 are prioritized over automatically-determined ones, but replication factors
 would still be respected.
+
+With --follow, the argument must be an /ipns/ path. Cluster keeps resolving
+it and updates the pin whenever the name points to a different CID,
+unpinning the previous one.
 `,
 					ArgsUsage: "<CID|Path>",
 					Flags: []cli.Flag{
@@ -625,6 +1025,19 @@ would still be respected.
 							Name:  "expire-in",
 							Usage: "Duration after which pin should be unpinned automatically",
 						},
+						cli.StringFlag{
+							Name:  "priority",
+							Value: "normal",
+							Usage: "Pinning priority: low, normal or high",
+						},
+						cli.StringFlag{
+							Name:  "group",
+							Usage: "Adds the pin to the given group",
+						},
+						cli.BoolFlag{
+							Name:  "follow",
+							Usage: "Follow the given /ipns/ path and update the pin when it changes",
+						},
 						cli.StringSliceFlag{
 							Name:  "metadata",
 							Usage: "Pin metadata: key=value. Can be added multiple times",
@@ -670,6 +1083,16 @@ would still be respected.
 							checkErr("parsing expire-in", err)
 							expireAt = time.Now().Add(d)
 						}
+						priority, err := api.PinPriorityFromString(c.String("priority"))
+						checkErr("parsing priority", err)
+
+						var follow string
+						if c.Bool("follow") {
+							if !strings.HasPrefix(arg, "/ipns/") {
+								checkErr("parsing follow", errors.New("only /ipns/ paths can be followed"))
+							}
+							follow = arg
+						}
 
 						opts := api.PinOptions{
 							ReplicationFactorMin: rplMin,
@@ -679,6 +1102,9 @@ would still be respected.
 							UserAllocations:      userAllocs,
 							ExpireAt:             expireAt,
 							Metadata:             parseMetadata(c.StringSlice("metadata")),
+							Priority:             priority,
+							Group:                c.String("group"),
+							Follow:               follow,
 						}
 
 						pin, cerr := globalClient.PinPath(ctx, arg, opts)
@@ -706,11 +1132,54 @@ When the request has succeeded, the command returns the status of the CID
 in the cluster. The CID should disappear from the list offered by "pin ls",
 although unpinning operations in the cluster may take longer or fail.
 `,
-					ArgsUsage: "<CID|Path>",
+					ArgsUsage: "<CID|Path>",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "no-status, ns",
+							Usage: "Prevents fetching pin status after unpinning (faster, quieter)",
+						},
+						cli.BoolFlag{
+							Name:  "wait, w",
+							Usage: waitFlagDesc,
+						},
+						cli.DurationFlag{
+							Name:  "wait-timeout, wt",
+							Value: 0,
+							Usage: waitTimeoutFlagDesc,
+						},
+					},
+					Action: func(c *cli.Context) error {
+						arg := c.Args().First()
+						pin, cerr := globalClient.UnpinPath(ctx, arg)
+						if cerr != nil {
+							formatResponse(c, nil, cerr)
+							return nil
+						}
+						handlePinResponseFormatFlags(
+							ctx,
+							c,
+							pin,
+							api.TrackerStatusUnpinned,
+						)
+						return nil
+					},
+				},
+				{
+					Name:  "undo",
+					Usage: "Undo the unpin of an item",
+					Description: `
+This command pins again an item that was unpinned during the last
+unpin_grace_period of the cluster peer, with the options and allocations
+that it had. Peers delay removing unpinned items from IPFS during that
+time, so the content does not need to be fetched again.
+
+Unpins can only be undone by the cluster peer which handled them.
+`,
+					ArgsUsage: "<CID>",
 					Flags: []cli.Flag{
 						cli.BoolFlag{
 							Name:  "no-status, ns",
-							Usage: "Prevents fetching pin status after unpinning (faster, quieter)",
+							Usage: "Prevents fetching pin status after pinning (faster, quieter)",
 						},
 						cli.BoolFlag{
 							Name:  "wait, w",
@@ -723,8 +1192,9 @@ although unpinning operations in the cluster may take longer or fail.
 						},
 					},
 					Action: func(c *cli.Context) error {
-						arg := c.Args().First()
-						pin, cerr := globalClient.UnpinPath(ctx, arg)
+						ci, err := api.DecodeCid(c.Args().First())
+						checkErr("parsing cid", err)
+						pin, cerr := globalClient.UndoUnpin(ctx, ci)
 						if cerr != nil {
 							formatResponse(c, nil, cerr)
 							return nil
@@ -733,7 +1203,7 @@ although unpinning operations in the cluster may take longer or fail.
 							ctx,
 							c,
 							pin,
-							api.TrackerStatusUnpinned,
+							api.TrackerStatusPinned,
 						)
 						return nil
 					},
@@ -765,6 +1235,11 @@ existing item from the cluster. Please run "pin rm" for that.
 							Name:  "expire-in",
 							Usage: "Duration after which the pin should be unpinned automatically after updating",
 						},
+						cli.StringFlag{
+							Name:  "priority",
+							Value: "normal",
+							Usage: "Pinning priority: low, normal or high",
+						},
 						cli.BoolFlag{
 							Name:  "no-status, ns",
 							Usage: "Prevents fetching pin status after updating (faster, quieter)",
@@ -792,11 +1267,14 @@ existing item from the cluster. Please run "pin rm" for that.
 							checkErr("parsing expire-in", err)
 							expireAt = time.Now().Add(d)
 						}
+						priority, err := api.PinPriorityFromString(c.String("priority"))
+						checkErr("parsing priority", err)
 
 						opts := api.PinOptions{
 							PinUpdate: fromCid,
 							Name:      c.String("name"),
 							ExpireAt:  expireAt,
+							Priority:  priority,
 						}
 
 						pin, cerr := globalClient.PinPath(ctx, to, opts)
@@ -865,6 +1343,252 @@ The filter only takes effect when listing all pins. The possible values are:
 						return nil
 					},
 				},
+				{
+					Name:  "search",
+					Usage: "Find pins by name and metadata",
+					Description: `
+This command lists the pins with the given name and metadata. Every
+--metadata flag must match: "key=value" selects the pins where the key has
+that value, and "key=" those which have the key with any value.
+
+Unlike "pin ls", the full pinset is not listed: pins are looked up in an
+index over their names and metadata.
+`,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "name, n",
+							Usage: "Only list pins with this name",
+						},
+						cli.StringSliceFlag{
+							Name:  "metadata",
+							Usage: "Pin metadata: key=value or key=. Can be added multiple times",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						search := api.PinSearch{
+							Name:     c.String("name"),
+							Metadata: parseMetadata(c.StringSlice("metadata")),
+						}
+						if search.IsEmpty() {
+							checkErr("", errors.New("a name or metadata is needed"))
+						}
+
+						pins := make(chan api.Pin, 1024)
+						errCh := make(chan error, 1)
+						go func() {
+							defer close(errCh)
+							errCh <- globalClient.SearchPins(ctx, search, pins)
+						}()
+						formatResponse(c, pins, nil)
+						err := <-errCh
+						formatResponse(c, nil, err)
+						return nil
+					},
+				},
+				{
+					Name:  "reallocate",
+					Usage: "Change the replication factors of existing pins",
+					Description: `
+This command sets the replication factors of the given CIDs and of the pins
+matching the --name and --metadata flags, which work as in "pin search".
+The pins are not unpinned: the peers already allocated to them keep them,
+and peers are only added or removed as needed to meet the new factors.
+Factors set to 0 use the cluster's defaults. The result of every pin is
+listed.
+`,
+					ArgsUsage: "[CID...]",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "name, n",
+							Usage: "Reallocate the pins with this name",
+						},
+						cli.StringSliceFlag{
+							Name:  "metadata",
+							Usage: "Pin metadata: key=value or key=. Can be added multiple times",
+						},
+						cli.IntFlag{
+							Name:  "replication, r",
+							Value: 0,
+							Usage: "Sets a custom replication factor (overrides -rmax and -rmin)",
+						},
+						cli.IntFlag{
+							Name:  "replication-min, rmin",
+							Value: 0,
+							Usage: "Sets the minimum replication factor for the pins",
+						},
+						cli.IntFlag{
+							Name:  "replication-max, rmax",
+							Value: 0,
+							Usage: "Sets the maximum replication factor for the pins",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						re := api.Reallocation{
+							Search: api.PinSearch{
+								Name:     c.String("name"),
+								Metadata: parseMetadata(c.StringSlice("metadata")),
+							},
+							ReplicationFactorMin: c.Int("replication-min"),
+							ReplicationFactorMax: c.Int("replication-max"),
+						}
+						if rpl := c.Int("replication"); rpl != 0 {
+							re.ReplicationFactorMin = rpl
+							re.ReplicationFactorMax = rpl
+						}
+						for _, cidStr := range c.Args() {
+							ci, err := api.DecodeCid(cidStr)
+							checkErr("parsing cid", err)
+							re.Cids = append(re.Cids, ci)
+						}
+						if len(re.Cids) == 0 && re.Search.IsEmpty() {
+							checkErr("", errors.New("CIDs, a name or metadata are needed"))
+						}
+						resp, cerr := globalClient.Reallocate(ctx, re)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "verify",
+					Usage: "Verify that the allocated peers store the blocks of a pin",
+					Description: `
+This command checks that the IPFS daemons of the peers to which a CID is
+allocated actually store all the blocks of its DAG. The DAG is walked
+using the IPFS daemon of the peer handling the request and the blocks are
+then looked up on every allocated peer, without fetching them from the
+network. The blocks missing on each peer are listed.
+
+Checking every block of large DAGs is expensive: the --sample flag checks
+only the given number of randomly chosen blocks on each peer.
+
+Meta-pins (sharded pins) cannot be verified, but their shard-pins can.
+The command exits with status 2 when any block is missing.
+`,
+					ArgsUsage: "<CID>",
+					Flags: []cli.Flag{
+						cli.IntFlag{
+							Name:  "sample",
+							Usage: "number of randomly chosen blocks to check (0: all)",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						ci, err := api.DecodeCid(c.Args().First())
+						checkErr("parsing cid", err)
+						resp, cerr := globalClient.VerifyPin(ctx, ci, c.Int("sample"))
+						formatResponse(c, resp, cerr)
+						if !resp.OK() {
+							os.Exit(2)
+						}
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:        "group",
+			Usage:       "List and manage groups of pins",
+			Description: "List and manage groups of pins",
+			Subcommands: []cli.Command{
+				{
+					Name:  "ls",
+					Usage: "List the groups in the cluster",
+					Description: `
+This command lists the groups of pins in the cluster pinset and the number
+of pins in each of them. Pins are added to a group with the --group flag of
+"pin add" and "add". A group exists as long as it has pins.
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.Groups(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "export",
+					Usage: "List the pins in a group",
+					Description: `
+This command lists the pins in a group, like "pin ls" does. With
+"--enc=json", the output can be used to re-create the pins in another
+cluster.
+`,
+					ArgsUsage: "<group>",
+					Action: func(c *cli.Context) error {
+						group := c.Args().First()
+						if group == "" {
+							checkErr("", errors.New("need a group name"))
+						}
+						pins := make(chan api.Pin, 1024)
+						errCh := make(chan error, 1)
+						go func() {
+							defer close(errCh)
+							errCh <- globalClient.GroupPins(ctx, group, pins)
+						}()
+						formatResponse(c, pins, nil)
+						err := <-errCh
+						formatResponse(c, nil, err)
+						return nil
+					},
+				},
+				{
+					Name:  "rm",
+					Usage: "Unpin all the pins in a group",
+					Description: `
+This command unpins all the pins in a group. The result of every unpin is
+listed.
+`,
+					ArgsUsage: "<group>",
+					Action: func(c *cli.Context) error {
+						group := c.Args().First()
+						if group == "" {
+							checkErr("", errors.New("need a group name"))
+						}
+						resp, cerr := globalClient.UnpinGroup(ctx, group)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "replication",
+					Usage: "Change the replication factors of all the pins in a group",
+					Description: `
+This command sets the replication factors of all the pins in a group, which
+are re-allocated as needed. Factors set to 0 use the cluster's defaults.
+The result of every pin is listed.
+`,
+					ArgsUsage: "<group>",
+					Flags: []cli.Flag{
+						cli.IntFlag{
+							Name:  "replication, r",
+							Value: 0,
+							Usage: "Sets a custom replication factor (overrides -rmax and -rmin)",
+						},
+						cli.IntFlag{
+							Name:  "replication-min, rmin",
+							Value: 0,
+							Usage: "Sets the minimum replication factor for the pins",
+						},
+						cli.IntFlag{
+							Name:  "replication-max, rmax",
+							Value: 0,
+							Usage: "Sets the maximum replication factor for the pins",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						group := c.Args().First()
+						if group == "" {
+							checkErr("", errors.New("need a group name"))
+						}
+						rplMin := c.Int("replication-min")
+						rplMax := c.Int("replication-max")
+						if rpl := c.Int("replication"); rpl != 0 {
+							rplMin = rpl
+							rplMax = rpl
+						}
+						resp, cerr := globalClient.SetGroupReplication(ctx, group, rplMin, rplMax)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
 			},
 		},
 		{
@@ -941,14 +1665,37 @@ CIDs (without argument), it may take a considerably long time.
 
 When the --local flag is passed, it will only trigger recover
 operations on the contacted peer (as opposed to on every peer).
+
+Pins which failed too many times are not retried automatically anymore and
+show the pin_error_permanent status (they can be listed with
+"status --filter pin_error_permanent"). Recovering them by CID retries them
+once. The --permanent flag retries all of them, which are otherwise skipped
+when recovering all tracked CIDs.
 `,
 			ArgsUsage: "[CID]",
 			Flags: []cli.Flag{
 				localFlag(),
+				cli.BoolFlag{
+					Name:  "permanent",
+					Usage: "retry all the pins in pin_error_permanent status",
+				},
 			},
 			Action: func(c *cli.Context) error {
 				cidStr := c.Args().First()
-				if cidStr != "" {
+				if c.Bool("permanent") {
+					if cidStr != "" {
+						checkErr("", errors.New("--permanent does not take a CID"))
+					}
+					out := make(chan api.GlobalPinInfo, 1024)
+					errCh := make(chan error, 1)
+					go func() {
+						defer close(errCh)
+						errCh <- globalClient.RecoverPermanent(ctx, c.Bool("local"), out)
+					}()
+					formatResponse(c, out, nil)
+					err := <-errCh
+					formatResponse(c, nil, err)
+				} else if cidStr != "" {
 					ci, err := api.DecodeCid(cidStr)
 					checkErr("parsing cid", err)
 					resp, cerr := globalClient.Recover(ctx, ci, c.Bool("local"))
@@ -983,6 +1730,46 @@ to check that it matches the CLI version (shown by -v).
 				return nil
 			},
 		},
+		{
+			Name:        "state",
+			Usage:       "Inspect the shared state",
+			Description: "Inspect the shared state",
+			Subcommands: []cli.Command{
+				{
+					Name:  "log",
+					Usage: "list the latest updates to the shared state",
+					Description: `
+This command lists the latest updates made to the shared state through the
+consensus layer, from the most recent, with the pins and unpins that each of
+them contains. Use --offset and --limit to page through older updates. With
+CRDT, every update is a delta from the DAG (a batch when batching is
+enabled). With Raft, every update is a log entry, and only the entries not yet
+compacted into a snapshot are available.
+`,
+					ArgsUsage: " ",
+					Flags: []cli.Flag{
+						cli.IntFlag{
+							Name:  "offset",
+							Usage: "number of updates to skip",
+						},
+						cli.IntFlag{
+							Name:  "limit",
+							Value: 20,
+							Usage: "maximum number of updates to list",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						opts := api.OpLogOptions{
+							Offset: c.Int("offset"),
+							Limit:  c.Int("limit"),
+						}
+						resp, cerr := globalClient.OperationLog(ctx, opts)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+			},
+		},
 		{
 			Name:        "health",
 			Usage:       "Cluster monitoring information",
@@ -1090,13 +1877,125 @@ This command will instruct current Cluster peers to run "repo gc" on their
 respective IPFS daemons.
 
 When --local flag is passed, it will garbage collect only on the local IPFS
-deamon, otherwise on all IPFS daemons.
+deamon, otherwise on all IPFS daemons. Garbage collection runs on one peer
+at a time, or on as many as given with --concurrency, so that the rest of
+the cluster keeps serving content. The space reclaimed by every peer is
+reported.
+`,
+					Flags: []cli.Flag{
+						localFlag(),
+						cli.IntFlag{
+							Name:  "concurrency, n",
+							Value: 1,
+							Usage: "number of peers running garbage collection at once",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						if c.Bool("local") {
+							resp, cerr := globalClient.RepoGC(ctx, true)
+							formatResponse(c, resp, cerr)
+							return nil
+						}
+						resp, cerr := globalClient.GarbageCollect(ctx, c.Int("concurrency"))
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:        "pintracker",
+			Usage:       "Manage the pintracker of cluster peers",
+			Description: "Manage the pintracker of cluster peers",
+			Subcommands: []cli.Command{
+				{
+					Name:  "concurrency",
+					Usage: "show or change how many pins and unpins run in parallel",
+					Description: `
+This command shows how many pin and unpin operations the pintracker of every
+peer runs in parallel. With --pins, --unpins or --auto, it changes them
+without restarting the peers, which is useful to throttle pinning during an
+incident. The new values are kept until the peers restart.
+
+With --auto, the number of pins is adjusted to the latency of the IPFS daemon,
+between 1 and the number given with --pins (or the current maximum). Changing
+the concurrency without --auto disables the automatic adjustment.
+
+When --local flag is passed, only the local peer is shown or changed.
+`,
+					ArgsUsage: " ",
+					Flags: []cli.Flag{
+						localFlag(),
+						cli.IntFlag{
+							Name:  "pins",
+							Usage: "number of pins to run in parallel",
+						},
+						cli.IntFlag{
+							Name:  "unpins",
+							Usage: "number of unpins to run in parallel",
+						},
+						cli.BoolFlag{
+							Name:  "auto",
+							Usage: "adjust the number of pins to the IPFS daemon latency",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						local := c.Bool("local")
+						if !c.IsSet("pins") && !c.IsSet("unpins") && !c.IsSet("auto") {
+							resp, cerr := globalClient.PinTrackerConcurrency(ctx, local)
+							formatResponse(c, resp, cerr)
+							return nil
+						}
+
+						conc := api.PinTrackerConcurrency{
+							Pins:   c.Int("pins"),
+							Unpins: c.Int("unpins"),
+							Auto:   c.Bool("auto"),
+						}
+						resp, cerr := globalClient.SetPinTrackerConcurrency(ctx, conc, local)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "pause",
+					Usage: "stop starting pin and unpin operations",
+					Description: `
+This command stops the pintracker of every peer from starting new pin and
+unpin operations, for example during a maintenance window of the IPFS
+daemons. Operations in progress finish. The cluster APIs and the shared state
+are not affected: new pins are accepted and queued, and they will be
+processed when the pintrackers are resumed with "pintracker resume". Peers
+which restart are no longer paused.
+
+When --local flag is passed, only the local peer is paused. Use --host to
+pause a different peer.
+`,
+					ArgsUsage: " ",
+					Flags: []cli.Flag{
+						localFlag(),
+					},
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.PinTrackerPause(ctx, c.Bool("local"))
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "resume",
+					Usage: "resume paused pin and unpin operations",
+					Description: `
+This command lets the pintracker of every peer start the pin and unpin
+operations queued after "pintracker pause" again.
+
+When --local flag is passed, only the local peer is resumed.
 `,
+					ArgsUsage: " ",
 					Flags: []cli.Flag{
 						localFlag(),
 					},
 					Action: func(c *cli.Context) error {
-						resp, cerr := globalClient.RepoGC(ctx, c.Bool("local"))
+						resp, cerr := globalClient.PinTrackerResume(ctx, c.Bool("local"))
 						formatResponse(c, resp, cerr)
 						return nil
 					},
@@ -1248,6 +2147,33 @@ func waitFor(
 	return client.WaitFor(ctx, globalClient, fp)
 }
 
+// waitForAddJob polls an add job until it finishes or the timeout, if any,
+// expires.
+func waitForAddJob(job api.AddJob, timeout time.Duration) (api.AddJob, error) {
+	ctx := context.Background()
+	if timeout > defaultWaitCheckFreq {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(defaultWaitCheckFreq)
+	defer ticker.Stop()
+	for job.Status == api.AddJobRunning {
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+		var err error
+		job, err = globalClient.AddJob(ctx, job.ID)
+		if err != nil {
+			return job, err
+		}
+	}
+	return job, nil
+}
+
 func parseMetadata(metadata []string) map[string]string {
 	metadataMap := make(map[string]string)
 	for _, str := range metadata {
@@ -308,6 +308,11 @@ func runCmd(c *cli.Context) error {
 
 	// Always run followers in follower mode.
 	cfgs.Cluster.FollowerMode = true
+	// Observers additionally never publish to the cluster.
+	if cfgs.Cluster.ObserverMode {
+		cfgs.Cluster.PassiveMode = true
+		cfgs.Crdt.ReadOnly = true
+	}
 	// Do not let trusted peers GC this peer
 	// Defaults to Trusted otherwise.
 	cfgs.Cluster.RPCPolicy["Cluster.RepoGCLocal"] = ipfscluster.RPCClosed
@@ -358,6 +363,9 @@ func runCmd(c *cli.Context) error {
 		return cli.Exit(errors.Wrap(err, "creating CRDT component"), 1)
 	}
 
+	cfgs.Statelesstracker.Passive = cfgs.Cluster.PassiveMode
+	cfgs.Statelesstracker.UnpinDelay = cfgs.Cluster.UnpinGracePeriod
+	cfgs.Statelesstracker.ConcurrencyBudget = cfgs.Cluster.GlobalConcurrencyBudget > 0
 	tracker := stateless.New(cfgs.Statelesstracker, host.ID(), cfgs.Cluster.Peername, crdtcons.State)
 
 	mon, err := pubsubmon.New(ctx, cfgs.Pubsubmon, pubsub, nil)
@@ -7,12 +7,14 @@ import (
 
 	ipfscluster "github.com/ipfs-cluster/ipfs-cluster"
 	"github.com/ipfs-cluster/ipfs-cluster/allocator/balanced"
+	"github.com/ipfs-cluster/ipfs-cluster/api/graphqlapi"
 	"github.com/ipfs-cluster/ipfs-cluster/api/ipfsproxy"
 	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi"
 	"github.com/ipfs-cluster/ipfs-cluster/api/rest"
 	"github.com/ipfs-cluster/ipfs-cluster/cmdutils"
 	"github.com/ipfs-cluster/ipfs-cluster/config"
 	"github.com/ipfs-cluster/ipfs-cluster/consensus/crdt"
+	"github.com/ipfs-cluster/ipfs-cluster/consensus/external"
 	"github.com/ipfs-cluster/ipfs-cluster/consensus/raft"
 	"github.com/ipfs-cluster/ipfs-cluster/informer/disk"
 	"github.com/ipfs-cluster/ipfs-cluster/informer/pinqueue"
@@ -70,6 +72,16 @@ func daemon(c *cli.Context) error {
 	}
 	cfgHelper.SetupTracing(c.Bool("tracing"))
 
+	// Observers sync the CRDT state but never write to it.
+	if cfgs.Cluster.ObserverMode {
+		if cfgHelper.GetConsensus() != cfgs.Crdt.ConfigKey() {
+			checkErr("setting up observer mode", errors.New("observer_mode is only supported with crdt consensus"))
+		}
+		cfgs.Cluster.FollowerMode = true
+		cfgs.Cluster.PassiveMode = true
+		cfgs.Crdt.ReadOnly = true
+	}
+
 	// Setup bootstrapping
 	raftStaging := false
 	switch cfgHelper.GetConsensus() {
@@ -84,6 +96,11 @@ func daemon(c *cli.Context) error {
 			crdtCfg := cfgs.Crdt
 			crdtCfg.TrustedPeers = append(crdtCfg.TrustedPeers, ipfscluster.PeersFromMultiaddrs(bootstraps)...)
 		}
+	case cfgs.External.ConfigKey():
+		if !c.Bool("no-trust") {
+			extCfg := cfgs.External
+			extCfg.TrustedPeers = append(extCfg.TrustedPeers, ipfscluster.PeersFromMultiaddrs(bootstraps)...)
+		}
 	}
 
 	if c.Bool("leave") {
@@ -161,6 +178,13 @@ func createCluster(
 		apis = append(apis, pinsvcapi)
 	}
 
+	if cfgMgr.IsLoadedFromJSON(config.API, cfgs.Graphqlapi.ConfigKey()) {
+		graphqlapi, err := graphqlapi.NewAPI(ctx, cfgs.Graphqlapi)
+		checkErr("creating GraphQL API component", err)
+
+		apis = append(apis, graphqlapi)
+	}
+
 	if cfgMgr.IsLoadedFromJSON(config.API, cfgs.Ipfsproxy.ConfigKey()) {
 		proxy, err := ipfsproxy.New(cfgs.Ipfsproxy)
 		checkErr("creating IPFS Proxy component", err)
@@ -217,6 +241,9 @@ func createCluster(
 		peersF = cons.Peers
 	}
 
+	cfgs.Statelesstracker.Passive = cfgs.Cluster.PassiveMode
+	cfgs.Statelesstracker.UnpinDelay = cfgs.Cluster.UnpinGracePeriod
+	cfgs.Statelesstracker.ConcurrencyBudget = cfgs.Cluster.GlobalConcurrencyBudget > 0
 	tracker := stateless.New(cfgs.Statelesstracker, host.ID(), cfgs.Cluster.Peername, cons.State)
 	logger.Debug("stateless pintracker loaded")
 
@@ -305,6 +332,13 @@ func setupConsensus(
 		// additional time for this consensus layer to be ready.
 		ipfscluster.ReadyTimeout = 356 * 24 * time.Hour
 		return convrdt, nil
+	case cfgs.External.ConfigKey():
+		ext, err := external.New(cfgHelper.Configs().External, h.ID())
+		if err != nil {
+			return nil, errors.Wrap(err, "creating external consensus component")
+		}
+		ipfscluster.ReadyTimeout = cfgs.External.DialTimeout + 5*time.Second
+		return ext, nil
 	default:
 		return nil, errors.New("unknown consensus component")
 	}
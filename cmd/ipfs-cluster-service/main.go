@@ -16,7 +16,9 @@ import (
 	ipfscluster "github.com/ipfs-cluster/ipfs-cluster"
 	"github.com/ipfs-cluster/ipfs-cluster/api"
 	"github.com/ipfs-cluster/ipfs-cluster/cmdutils"
+	"github.com/ipfs-cluster/ipfs-cluster/config"
 	"github.com/ipfs-cluster/ipfs-cluster/consensus/crdt"
+	"github.com/ipfs-cluster/ipfs-cluster/consensus/raft"
 	"github.com/ipfs-cluster/ipfs-cluster/pstoremgr"
 	"github.com/ipfs-cluster/ipfs-cluster/version"
 	peer "github.com/libp2p/go-libp2p/core/peer"
@@ -246,7 +248,9 @@ provided with --custom-secret (in which case it will be prompted), or
 by setting the CLUSTER_SECRET environment variable.
 
 The --consensus flag allows to select an alternative consensus components for
-in the newly-generated configuration.
+in the newly-generated configuration. The "external" consensus delegates to a
+backend running in a separate process, reachable over gRPC at the "endpoint"
+given in its configuration section.
 
 Note that the --force flag allows to overwrite an existing
 configuration with default values. To generate a new identity, please
@@ -254,9 +258,9 @@ remove the %s file first and clean any Raft state.
 
 By default, an empty peerstore file will be created too. Initial contents can
 be provided with the --peers flag. Depending on the chosen consensus, the
-"trusted_peers" list in the "crdt" configuration section and the
-"init_peerset" list in the "raft" configuration section will be prefilled to
-the peer IDs in the given multiaddresses.
+"trusted_peers" list in the "crdt" and "external" configuration sections and
+the "init_peerset" list in the "raft" configuration section will be prefilled
+to the peer IDs in the given multiaddresses.
 `,
 
 				DefaultConfigFile,
@@ -269,7 +273,7 @@ the peer IDs in the given multiaddresses.
 			Flags: []cli.Flag{
 				cli.StringFlag{
 					Name:  "consensus",
-					Usage: "select consensus: 'crdt' or 'raft'",
+					Usage: "select consensus: 'crdt', 'raft' or 'external'",
 					Value: defaultConsensus,
 				},
 				cli.StringFlag{
@@ -297,9 +301,9 @@ the peer IDs in the given multiaddresses.
 			Action: func(c *cli.Context) error {
 				consensus := c.String("consensus")
 				switch consensus {
-				case "raft", "crdt":
+				case "raft", "crdt", "external":
 				default:
-					checkErr("choosing consensus", errors.New("flag value must be set to 'raft' or 'crdt'"))
+					checkErr("choosing consensus", errors.New("flag value must be set to 'raft', 'crdt' or 'external'"))
 				}
 
 				datastore := c.String("datastore")
@@ -361,6 +365,8 @@ the peer IDs in the given multiaddresses.
 					checkErr("randomizing ports", err)
 					cfgs.Pinsvcapi.HTTPListenAddr, err = cmdutils.RandomizePorts(cfgs.Pinsvcapi.HTTPListenAddr)
 					checkErr("randomizing ports", err)
+					cfgs.Graphqlapi.HTTPListenAddr, err = cmdutils.RandomizePorts(cfgs.Graphqlapi.HTTPListenAddr)
+					checkErr("randomizing ports", err)
 				}
 				err = cfgHelper.Manager().ApplyEnvVars()
 				checkErr("applying environment variables to configuration", err)
@@ -386,6 +392,8 @@ the peer IDs in the given multiaddresses.
 					peers := ipfscluster.PeersFromMultiaddrs(multiAddrs)
 					cfgHelper.Configs().Crdt.TrustAll = false
 					cfgHelper.Configs().Crdt.TrustedPeers = peers
+					cfgHelper.Configs().External.TrustAll = false
+					cfgHelper.Configs().External.TrustedPeers = peers
 					cfgHelper.Configs().Raft.InitPeerset = peers
 				}
 
@@ -450,7 +458,7 @@ the peer IDs in the given multiaddresses.
 				},
 				cli.BoolFlag{
 					Name:  "no-trust",
-					Usage: "do not trust bootstrap peers (only for \"crdt\" consensus)",
+					Usage: "do not trust bootstrap peers (only for \"crdt\" and \"external\" consensus)",
 				},
 			},
 			Action: daemon,
@@ -730,6 +738,54 @@ to all effects. Peers may need to bootstrap and sync from scratch after this.
 						return nil
 					},
 				},
+				{
+					Name:  "migrate-consensus",
+					Usage: "switch a Raft peer to CRDT consensus",
+					Description: fmt.Sprintf(`
+This command converts a peer using "raft" consensus into a peer using "crdt"
+consensus. The current Raft state is written into a new CRDT store, and the
+"raft" section of the configuration is replaced by a "crdt" one. The
+"trusted_peers" are set to the last known Raft peerset, unless a "crdt"
+section existed already.
+
+To migrate a cluster, stop all the peers, run this command on each of them and
+start them again. As every peer converts its own copy of the Raft state, peers
+do not need to sync the pinset from others after the switch and can be
+restarted one by one. Peers should have been stopped cleanly, so that the Raft
+state is up to date.
+
+The Raft data folder is left untouched and can be removed once the migration
+is complete.
+
+The datastore for the CRDT store can be selected with --datastore (default:
+%s).
+`, defaultDatastore),
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "datastore",
+							Usage: "select datastore: 'badger', 'badger3', 'leveldb', 'pebble'",
+							Value: defaultDatastore,
+						},
+						cli.BoolFlag{
+							Name:  "force, f",
+							Usage: "skip confirmation prompt",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						locker.lock()
+						defer locker.tryUnlock()
+
+						confirm := "The consensus of this peer will be switched to CRDT "
+						confirm += "and the configuration will be overwritten. Continue? [y/n]:"
+						if !c.Bool("force") && !yesNoPrompt(confirm) {
+							return nil
+						}
+
+						migrateConsensus(c.String("datastore"))
+						logger.Info("peer migrated to crdt consensus. Make sure all peers are migrated before starting them")
+						return nil
+					},
+				},
 			},
 		},
 		{
@@ -879,6 +935,50 @@ func getStateManager() cmdutils.StateManager {
 	return mgr
 }
 
+// migrateConsensus writes the Raft state into a new CRDT store using the
+// given datastore, and switches the consensus section of the configuration.
+func migrateConsensus(datastore string) {
+	cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
+	checkErr("loading configurations", err)
+	defer cfgHelper.Manager().Shutdown()
+	cfgs := cfgHelper.Configs()
+
+	if cfgHelper.GetConsensus() != cfgs.Raft.ConfigKey() {
+		checkErr("", errors.New("only peers initialized with raft consensus can be migrated"))
+	}
+	raftMgr, err := cmdutils.NewStateManagerWithHelper(cfgHelper)
+	checkErr("creating raft state manager", err)
+
+	peers, err := raft.LastPeers(cfgs.Raft)
+	checkErr("reading raft peers", err)
+	if len(peers) == 0 {
+		peers = cfgs.Raft.InitPeerset
+	}
+
+	newHelper := cmdutils.NewConfigHelper(configPath, identityPath, cfgs.Crdt.ConfigKey(), datastore)
+	defer newHelper.Manager().Shutdown()
+	checkErr("loading configurations", newHelper.LoadFromDisk())
+	newCfgs := newHelper.Configs()
+	if !newHelper.Manager().IsLoadedFromJSON(config.Consensus, newCfgs.Crdt.ConfigKey()) {
+		newCfgs.Crdt.TrustAll = false
+		newCfgs.Crdt.TrustedPeers = peers
+	}
+
+	crdtMgr, err := cmdutils.NewStateManagerWithHelper(newHelper)
+	checkErr("creating crdt state manager", err)
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(raftMgr.ExportState(pw))
+	}()
+	err = crdtMgr.ImportState(pr, api.PinOptions{})
+	pr.CloseWithError(err)
+	checkErr("migrating state", err)
+
+	newHelper.Manager().RemoveFromJSON(config.Consensus, newCfgs.Raft.ConfigKey())
+	checkErr("saving configuration", newHelper.SaveConfigToDisk())
+}
+
 func getCrdt() *dscrdt.Datastore {
 	// Load all the configurations and identity
 	cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
@@ -9,11 +9,13 @@ import (
 
 	ipfscluster "github.com/ipfs-cluster/ipfs-cluster"
 	"github.com/ipfs-cluster/ipfs-cluster/allocator/balanced"
+	"github.com/ipfs-cluster/ipfs-cluster/api/graphqlapi"
 	"github.com/ipfs-cluster/ipfs-cluster/api/ipfsproxy"
 	"github.com/ipfs-cluster/ipfs-cluster/api/pinsvcapi"
 	"github.com/ipfs-cluster/ipfs-cluster/api/rest"
 	"github.com/ipfs-cluster/ipfs-cluster/config"
 	"github.com/ipfs-cluster/ipfs-cluster/consensus/crdt"
+	"github.com/ipfs-cluster/ipfs-cluster/consensus/external"
 	"github.com/ipfs-cluster/ipfs-cluster/consensus/raft"
 	"github.com/ipfs-cluster/ipfs-cluster/datastore/badger"
 	"github.com/ipfs-cluster/ipfs-cluster/datastore/badger3"
@@ -34,10 +36,12 @@ type Configs struct {
 	Cluster          *ipfscluster.Config
 	Restapi          *rest.Config
 	Pinsvcapi        *pinsvcapi.Config
+	Graphqlapi       *graphqlapi.Config
 	Ipfsproxy        *ipfsproxy.Config
 	Ipfshttp         *ipfshttp.Config
 	Raft             *raft.Config
 	Crdt             *crdt.Config
+	External         *external.Config
 	Statelesstracker *stateless.Config
 	Pubsubmon        *pubsubmon.Config
 	BalancedAlloc    *balanced.Config
@@ -169,7 +173,7 @@ func (ch *ConfigHelper) Configs() *Configs {
 // then it returns that.
 //
 // Otherwise it checks whether one of the consensus configurations
-// has been loaded. If both or none have been loaded, it returns
+// has been loaded. If none or more than one have been loaded, it returns
 // an empty string.
 func (ch *ConfigHelper) GetConsensus() string {
 	if ch.consensus != "" {
@@ -177,14 +181,25 @@ func (ch *ConfigHelper) GetConsensus() string {
 	}
 	crdtLoaded := ch.manager.IsLoadedFromJSON(config.Consensus, ch.configs.Crdt.ConfigKey())
 	raftLoaded := ch.manager.IsLoadedFromJSON(config.Consensus, ch.configs.Raft.ConfigKey())
-	if crdtLoaded == raftLoaded { //both loaded or none
+	externalLoaded := ch.manager.IsLoadedFromJSON(config.Consensus, ch.configs.External.ConfigKey())
+
+	nLoaded := 0
+	for _, v := range []bool{crdtLoaded, raftLoaded, externalLoaded} {
+		if v {
+			nLoaded++
+		}
+	}
+	if nLoaded != 1 {
 		return ""
 	}
-
-	if crdtLoaded {
+	switch {
+	case crdtLoaded:
 		return ch.configs.Crdt.ConfigKey()
+	case raftLoaded:
+		return ch.configs.Raft.ConfigKey()
+	default:
+		return ch.configs.External.ConfigKey()
 	}
-	return ch.configs.Raft.ConfigKey()
 }
 
 // GetDatastore attempts to return the configured datastore.  If the
@@ -233,10 +248,12 @@ func (ch *ConfigHelper) init() {
 		Cluster:          &ipfscluster.Config{},
 		Restapi:          rest.NewConfig(),
 		Pinsvcapi:        pinsvcapi.NewConfig(),
+		Graphqlapi:       graphqlapi.NewConfig(),
 		Ipfsproxy:        &ipfsproxy.Config{},
 		Ipfshttp:         &ipfshttp.Config{},
 		Raft:             &raft.Config{},
 		Crdt:             &crdt.Config{},
+		External:         &external.Config{},
 		Statelesstracker: &stateless.Config{},
 		Pubsubmon:        &pubsubmon.Config{},
 		BalancedAlloc:    &balanced.Config{},
@@ -254,6 +271,7 @@ func (ch *ConfigHelper) init() {
 	man.RegisterComponent(config.Cluster, cfgs.Cluster)
 	man.RegisterComponent(config.API, cfgs.Restapi)
 	man.RegisterComponent(config.API, cfgs.Pinsvcapi)
+	man.RegisterComponent(config.API, cfgs.Graphqlapi)
 	man.RegisterComponent(config.API, cfgs.Ipfsproxy)
 	man.RegisterComponent(config.IPFSConn, cfgs.Ipfshttp)
 	man.RegisterComponent(config.PinTracker, cfgs.Statelesstracker)
@@ -274,9 +292,12 @@ func (ch *ConfigHelper) init() {
 	case cfgs.Crdt.ConfigKey():
 		man.RegisterComponent(config.Consensus, cfgs.Crdt)
 		registerDatastores = true
+	case cfgs.External.ConfigKey():
+		man.RegisterComponent(config.Consensus, cfgs.External)
 	default:
 		man.RegisterComponent(config.Consensus, cfgs.Raft)
 		man.RegisterComponent(config.Consensus, cfgs.Crdt)
+		man.RegisterComponent(config.Consensus, cfgs.External)
 		registerDatastores = true
 	}
 
@@ -348,8 +369,10 @@ func (ch *ConfigHelper) SetupTracing(forceEnabled bool) {
 	ch.configs.Cluster.Tracing = enabled
 	ch.configs.Raft.Tracing = enabled
 	ch.configs.Crdt.Tracing = enabled
+	ch.configs.External.Tracing = enabled
 	ch.configs.Restapi.Tracing = enabled
 	ch.configs.Pinsvcapi.Tracing = enabled
+	ch.configs.Graphqlapi.Tracing = enabled
 	ch.configs.Ipfshttp.Tracing = enabled
 	ch.configs.Ipfsproxy.Tracing = enabled
 }
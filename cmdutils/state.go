@@ -34,7 +34,7 @@ type StateManager interface {
 }
 
 // NewStateManager returns an state manager implementation for the given
-// consensus ("raft" or "crdt"). It will need initialized configs.
+// consensus ("raft", "crdt" or "external"). It will need initialized configs.
 func NewStateManager(consensus string, datastore string, ident *config.Identity, cfgs *Configs) (StateManager, error) {
 	switch consensus {
 	case cfgs.Raft.ConfigKey():
@@ -44,6 +44,8 @@ func NewStateManager(consensus string, datastore string, ident *config.Identity,
 			cfgs:      cfgs,
 			datastore: datastore,
 		}, nil
+	case cfgs.External.ConfigKey():
+		return &externalStateManager{}, nil
 	case "":
 		return nil, errors.New("could not determine the consensus component")
 	default:
@@ -191,6 +193,32 @@ func (crdtsm *crdtStateManager) Clean() error {
 	return crdt.Clean(context.Background(), crdtsm.cfgs.Crdt, store)
 }
 
+// errExternalState is returned by the state commands on peers using an
+// external consensus, whose state is handled by the backend.
+var errExternalState = errors.New("the state of an external consensus is handled by its backend")
+
+type externalStateManager struct{}
+
+func (extsm *externalStateManager) GetStore() (ds.Datastore, error) {
+	return inmem.New(), nil
+}
+
+func (extsm *externalStateManager) GetOfflineState(store ds.Datastore) (state.State, error) {
+	return nil, errExternalState
+}
+
+func (extsm *externalStateManager) ImportState(r io.Reader, opts api.PinOptions) error {
+	return errExternalState
+}
+
+func (extsm *externalStateManager) ExportState(w io.Writer) error {
+	return errExternalState
+}
+
+func (extsm *externalStateManager) Clean() error {
+	return errExternalState
+}
+
 func importState(r io.Reader, st state.State, opts api.PinOptions) error {
 	ctx := context.Background()
 	dec := json.NewDecoder(r)
@@ -603,6 +603,20 @@ func (cfg *Manager) applyUpdateJSONConfigs(jcfg *jsonConfig, updateJSONConfigs f
 	return nil
 }
 
+// RemoveFromJSON removes the given component from the JSON configuration
+// loaded from disk, if present, so that it is not written anymore on
+// save. Registered components are always written.
+func (cfg *Manager) RemoveFromJSON(t SectionType, name string) {
+	if cfg.jsonCfg == nil {
+		return
+	}
+	jsection := cfg.jsonCfg.getSection(t)
+	if jsection == nil || *jsection == nil {
+		return
+	}
+	delete(*jsection, name)
+}
+
 // IsLoadedFromJSON tells whether the given component belonging to
 // the given section type is present in the cluster JSON
 // config or not.
@@ -126,6 +126,36 @@ func TestManager_ToJSON(t *testing.T) {
 	}
 }
 
+func TestRemoveFromJSON(t *testing.T) {
+	cfgMgr := NewManager()
+	mockCfg := &mockCfg{}
+	cfgMgr.RegisterComponent(Cluster, mockCfg)
+	cfgMgr.RegisterComponent(API, mockCfg)
+	err := cfgMgr.LoadJSON(mockJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfgMgr.RemoveFromJSON(Consensus, "mock")
+	got, err := cfgMgr.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(got, []byte(`"consensus"`)) {
+		t.Errorf("consensus section should have been removed: %s", got)
+	}
+
+	// registered components are kept
+	cfgMgr.RemoveFromJSON(API, "mock")
+	got, err = cfgMgr.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(got, []byte(`"api"`)) {
+		t.Errorf("api section should be present: %s", got)
+	}
+}
+
 func TestLoadFromHTTPSourceRedirect(t *testing.T) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
@@ -27,6 +27,11 @@ var (
 	DefaultTrustAll             = true
 	DefaultBatchingMaxQueueSize = 50000
 	DefaultRepairInterval       = time.Hour
+	DefaultSnapshotInterval     = time.Duration(0)
+	DefaultSnapshotBootstrap    = time.Duration(0)
+	DefaultEncryption           = false
+	DefaultKeyRotationInterval  = 24 * time.Hour
+	DefaultDesignatedPublishers = 0
 )
 
 // BatchingConfig configures parameters for batching multiple pins in a single
@@ -83,8 +88,36 @@ type Config struct {
 	// datastore is marked dirty.
 	RepairInterval time.Duration
 
+	// How often to produce a signed snapshot of the CRDT state and
+	// announce it to other peers. 0 disables producing snapshots.
+	SnapshotInterval time.Duration
+
+	// How long a peer without any CRDT state waits for a snapshot
+	// announcement to bootstrap from when starting, before syncing the
+	// full DAG instead. It should be larger than the SnapshotInterval of
+	// the producers. 0 disables bootstrapping from snapshots.
+	SnapshotBootstrapTimeout time.Duration
+
+	// Encryption makes this peer encrypt the pins it writes to the
+	// shared state, so that they can only be read by trusted peers.
+	Encryption bool
+
+	// How often to generate a new encryption key. 0 keeps using the
+	// same key.
+	KeyRotationInterval time.Duration
+
+	// DesignatedPublishers is the number of trusted peers which
+	// re-broadcast the current heads. Other peers only broadcast the
+	// heads from their own updates. 0 lets every peer re-broadcast.
+	DesignatedPublishers int
+
 	// Tracing enables propagation of contexts across binary boundaries.
 	Tracing bool
+
+	// ReadOnly makes this peer sync the shared state without ever
+	// publishing anything to the network. It is not part of the JSON
+	// configuration and is set for observer peers.
+	ReadOnly bool
 }
 
 type batchingConfigJSON struct {
@@ -100,6 +133,14 @@ type jsonConfig struct {
 	RepairInterval      string             `json:"repair_interval"`
 	RebroadcastInterval string             `json:"rebroadcast_interval,omitempty"`
 
+	SnapshotInterval         string `json:"snapshot_interval,omitempty"`
+	SnapshotBootstrapTimeout string `json:"snapshot_bootstrap_timeout,omitempty"`
+
+	Encryption          bool   `json:"encryption,omitempty"`
+	KeyRotationInterval string `json:"key_rotation_interval,omitempty"`
+
+	DesignatedPublishers int `json:"designated_publishers,omitempty"`
+
 	PeersetMetric      string `json:"peerset_metric,omitempty"`
 	DatastoreNamespace string `json:"datastore_namespace,omitempty"`
 }
@@ -130,6 +171,22 @@ func (cfg *Config) Validate() error {
 	if cfg.RepairInterval < 0 {
 		return errors.New("crdt.repair_interval is invalid")
 	}
+
+	if cfg.SnapshotInterval < 0 {
+		return errors.New("crdt.snapshot_interval is invalid")
+	}
+
+	if cfg.SnapshotBootstrapTimeout < 0 {
+		return errors.New("crdt.snapshot_bootstrap_timeout is invalid")
+	}
+
+	if cfg.KeyRotationInterval < 0 {
+		return errors.New("crdt.key_rotation_interval is invalid")
+	}
+
+	if cfg.DesignatedPublishers < 0 {
+		return errors.New("crdt.designated_publishers is invalid")
+	}
 	return nil
 }
 
@@ -171,11 +228,16 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 	config.SetIfNotDefault(jcfg.Batching.MaxQueueSize, &cfg.Batching.MaxQueueSize)
 	config.SetIfNotDefault(jcfg.PeersetMetric, &cfg.PeersetMetric)
 	config.SetIfNotDefault(jcfg.DatastoreNamespace, &cfg.DatastoreNamespace)
+	cfg.Encryption = jcfg.Encryption
+	cfg.DesignatedPublishers = jcfg.DesignatedPublishers
 	config.ParseDurations(
 		"crdt",
 		&config.DurationOpt{Duration: jcfg.RebroadcastInterval, Dst: &cfg.RebroadcastInterval, Name: "rebroadcast_interval"},
 		&config.DurationOpt{Duration: jcfg.Batching.MaxBatchAge, Dst: &cfg.Batching.MaxBatchAge, Name: "max_batch_age"},
 		&config.DurationOpt{Duration: jcfg.RepairInterval, Dst: &cfg.RepairInterval, Name: "repair_interval"},
+		&config.DurationOpt{Duration: jcfg.SnapshotInterval, Dst: &cfg.SnapshotInterval, Name: "snapshot_interval"},
+		&config.DurationOpt{Duration: jcfg.SnapshotBootstrapTimeout, Dst: &cfg.SnapshotBootstrapTimeout, Name: "snapshot_bootstrap_timeout"},
+		&config.DurationOpt{Duration: jcfg.KeyRotationInterval, Dst: &cfg.KeyRotationInterval, Name: "key_rotation_interval"},
 	)
 	return cfg.Validate()
 }
@@ -223,6 +285,20 @@ func (cfg *Config) toJSONConfig() *jsonConfig {
 
 	jcfg.RepairInterval = cfg.RepairInterval.String()
 
+	if cfg.SnapshotInterval != DefaultSnapshotInterval {
+		jcfg.SnapshotInterval = cfg.SnapshotInterval.String()
+	}
+
+	if cfg.SnapshotBootstrapTimeout != DefaultSnapshotBootstrap {
+		jcfg.SnapshotBootstrapTimeout = cfg.SnapshotBootstrapTimeout.String()
+	}
+
+	jcfg.Encryption = cfg.Encryption
+	if cfg.KeyRotationInterval != DefaultKeyRotationInterval {
+		jcfg.KeyRotationInterval = cfg.KeyRotationInterval.String()
+	}
+	jcfg.DesignatedPublishers = cfg.DesignatedPublishers
+
 	return jcfg
 }
 
@@ -240,6 +316,11 @@ func (cfg *Config) Default() error {
 		MaxQueueSize: DefaultBatchingMaxQueueSize,
 	}
 	cfg.RepairInterval = DefaultRepairInterval
+	cfg.SnapshotInterval = DefaultSnapshotInterval
+	cfg.SnapshotBootstrapTimeout = DefaultSnapshotBootstrap
+	cfg.Encryption = DefaultEncryption
+	cfg.KeyRotationInterval = DefaultKeyRotationInterval
+	cfg.DesignatedPublishers = DefaultDesignatedPublishers
 	return nil
 }
 
@@ -265,3 +346,8 @@ func (cfg *Config) batchingEnabled() bool {
 	return cfg.Batching.MaxBatchSize > 0 &&
 		cfg.Batching.MaxBatchAge > 0
 }
+
+func (cfg *Config) snapshotsEnabled() bool {
+	return cfg.SnapshotInterval > 0 ||
+		cfg.SnapshotBootstrapTimeout > 0
+}
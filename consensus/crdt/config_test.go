@@ -15,7 +15,12 @@ var cfgJSON = []byte(`
         "max_batch_age": "5s",
         "max_queue_size": 150
     },
-    "repair_interval": "1m"
+    "repair_interval": "1m",
+    "snapshot_interval": "1h",
+    "snapshot_bootstrap_timeout": "2h",
+    "encryption": true,
+    "key_rotation_interval": "12h",
+    "designated_publishers": 3
 }
 `)
 
@@ -37,6 +42,16 @@ func TestLoadJSON(t *testing.T) {
 	if cfg.RepairInterval != time.Minute {
 		t.Error("repair interval not set")
 	}
+	if cfg.SnapshotInterval != time.Hour ||
+		cfg.SnapshotBootstrapTimeout != 2*time.Hour {
+		t.Error("snapshot options were not parsed correctly")
+	}
+	if !cfg.Encryption || cfg.KeyRotationInterval != 12*time.Hour {
+		t.Error("encryption options were not parsed correctly")
+	}
+	if cfg.DesignatedPublishers != 3 {
+		t.Error("designated publishers not set")
+	}
 
 	cfg = &Config{}
 	err = cfg.LoadJSON([]byte(`
@@ -131,6 +146,30 @@ func TestDefault(t *testing.T) {
 	if cfg.Validate() == nil {
 		t.Fatal("expected error validating")
 	}
+
+	cfg.Default()
+	cfg.SnapshotInterval = -3
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.SnapshotBootstrapTimeout = -3
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.KeyRotationInterval = -3
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.DesignatedPublishers = -1
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
 }
 
 func TestApplyEnvVars(t *testing.T) {
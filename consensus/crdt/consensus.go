@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ipfs-cluster/ipfs-cluster/api"
@@ -43,11 +44,23 @@ var (
 
 // Common variables for the module.
 var (
-	ErrNoLeader            = errors.New("crdt consensus component does not provide a leader")
+	ErrNoLeader            = fmt.Errorf("crdt %w", api.ErrLeaderless)
 	ErrRmPeer              = errors.New("crdt consensus component cannot remove peers")
 	ErrMaxQueueSizeReached = errors.New("batching max_queue_size reached. Too many operations are waiting to be batched. Try increasing the max_queue_size or adjusting the batching options")
+	ErrReadOnly            = errors.New("crdt consensus component is read-only")
 )
 
+// readOnlyBroadcaster receives updates from the network but drops those
+// that the local replica wants to publish, including re-broadcasts of the
+// current heads.
+type readOnlyBroadcaster struct {
+	crdt.Broadcaster
+}
+
+func (rob readOnlyBroadcaster) Broadcast(data []byte) error {
+	return nil
+}
+
 // wraps pins so that they can be batched.
 type batchItem struct {
 	ctx     context.Context
@@ -68,6 +81,9 @@ type Consensus struct {
 	config *Config
 
 	trustedPeers sync.Map
+	keys         encryptionKeys
+	localWrites  atomic.Int32
+	publisher    atomic.Bool
 
 	host        host.Host
 	peerManager *pstoremgr.Manager
@@ -78,6 +94,7 @@ type Consensus struct {
 	state         state.State
 	batchingState state.BatchingState
 	crdt          *crdt.Datastore
+	trustCrdt     *crdt.Datastore
 	ipfs          *ipfslite.Peer
 
 	dht    routing.Routing
@@ -201,7 +218,32 @@ func (css *Consensus) setup() {
 		logger.Errorf("error registering topic validator: %s", err)
 	}
 
-	broadcaster, err := crdt.NewPubSubBroadcaster(
+	// Apply the trust changes recorded at runtime before anything
+	// else checks the trusted peers.
+	err = css.setupTrust(trustTopicName(topicName))
+	if err != nil {
+		logger.Errorf("error setting up the trust store: %s", err)
+		return
+	}
+
+	err = css.setupEncryption()
+	if err != nil {
+		logger.Errorf("error setting up encryption: %s", err)
+		return
+	}
+
+	var snapshotTopic *pubsub.Topic
+	if css.config.snapshotsEnabled() {
+		snapshotTopic, err = css.joinSnapshotTopic(snapshotTopicName(topicName))
+		if err != nil {
+			logger.Errorf("error joining snapshots topic: %s", err)
+		}
+	}
+	if snapshotTopic != nil && css.config.SnapshotBootstrapTimeout > 0 {
+		css.bootstrapFromSnapshot(snapshotTopic)
+	}
+
+	psBroadcaster, err := crdt.NewPubSubBroadcaster(
 		css.ctx,
 		css.pubsub,
 		topicName, // subscription name
@@ -210,6 +252,15 @@ func (css *Consensus) setup() {
 		logger.Errorf("error creating broadcaster: %s", err)
 		return
 	}
+	var broadcaster crdt.Broadcaster = metricsBroadcaster{psBroadcaster}
+	if css.config.DesignatedPublishers > 0 {
+		logger.Infof("'crdt designated publishers' enabled: %d peers re-broadcast heads", css.config.DesignatedPublishers)
+		broadcaster = publisherBroadcaster{Broadcaster: broadcaster, css: css}
+	}
+	if css.config.ReadOnly {
+		logger.Info("'crdt read-only' enabled: updates will not be published")
+		broadcaster = readOnlyBroadcaster{psBroadcaster}
+	}
 
 	opts := crdt.DefaultOptions()
 	opts.RebroadcastInterval = css.config.RebroadcastInterval
@@ -222,12 +273,19 @@ func (css *Consensus) setup() {
 		ctx, span := trace.StartSpan(css.ctx, "crdt/PutHook")
 		defer span.End()
 
+		v, err := css.decrypt(ctx, k, v)
+		if err != nil {
+			logger.Errorf("cannot decrypt %s: %s", k, err)
+			return
+		}
+
 		pin := api.Pin{}
-		err := pin.ProtoUnmarshal(v)
+		err = pin.ProtoUnmarshal(v)
 		if err != nil {
 			logger.Error(err)
 			return
 		}
+		css.updateIndexes(func(idx indexer) { idx.Index(pin) })
 
 		// TODO: tracing for this context
 		err = css.rpcClient.CallContext(
@@ -258,6 +316,7 @@ func (css *Consensus) setup() {
 			return
 		}
 
+		css.updateIndexes(func(idx indexer) { idx.Unindex(c) })
 		pin := api.PinCid(c)
 
 		err = css.rpcClient.CallContext(
@@ -287,10 +346,15 @@ func (css *Consensus) setup() {
 	}
 
 	css.crdt = crdt
+	var store ds.Batching = crdt
+	if css.config.DesignatedPublishers > 0 {
+		store = &localWritesStore{Batching: crdt, css: css}
+	}
+	encrypted := &encryptedStore{Batching: store, css: css}
 
 	clusterState, err := dsstate.New(
 		css.ctx,
-		css.crdt,
+		encrypted,
 		// unsure if we should set something else but crdt is already
 		// namespaced and this would only namespace the keys, which only
 		// complicates things.
@@ -305,7 +369,7 @@ func (css *Consensus) setup() {
 
 	batchingState, err := dsstate.NewBatching(
 		css.ctx,
-		css.crdt,
+		encrypted,
 		"",
 		dsstate.DefaultHandle(),
 	)
@@ -329,6 +393,13 @@ func (css *Consensus) setup() {
 		go css.batchWorker()
 	}
 
+	if snapshotTopic != nil && css.config.SnapshotInterval > 0 && !css.config.ReadOnly {
+		logger.Infof("'crdt snapshots' enabled: every %s", css.config.SnapshotInterval)
+		go css.snapshotWorker(snapshotTopic)
+	}
+
+	go css.statsWorker()
+
 	// notifies State() it is safe to return
 	close(css.stateReady)
 	css.readyCh <- struct{}{}
@@ -361,6 +432,9 @@ func (css *Consensus) Shutdown(ctx context.Context) error {
 	if crdt := css.crdt; crdt != nil {
 		crdt.Close()
 	}
+	if crdt := css.trustCrdt; crdt != nil {
+		crdt.Close()
+	}
 
 	if css.config.hostShutdown {
 		css.host.Close()
@@ -434,6 +508,10 @@ func (css *Consensus) LogPin(ctx context.Context, pin api.Pin) error {
 	ctx, span := trace.StartSpan(ctx, "consensus/LogPin")
 	defer span.End()
 
+	if css.config.ReadOnly {
+		return ErrReadOnly
+	}
+
 	if css.config.batchingEnabled() {
 		batched := make(chan error)
 		css.sendToBatchCh <- batchItem{
@@ -453,6 +531,10 @@ func (css *Consensus) LogUnpin(ctx context.Context, pin api.Pin) error {
 	ctx, span := trace.StartSpan(ctx, "consensus/LogUnpin")
 	defer span.End()
 
+	if css.config.ReadOnly {
+		return ErrReadOnly
+	}
+
 	if css.config.batchingEnabled() {
 		batched := make(chan error)
 		css.sendToBatchCh <- batchItem{
@@ -467,6 +549,29 @@ func (css *Consensus) LogUnpin(ctx context.Context, pin api.Pin) error {
 	return css.state.Rm(ctx, pin.Cid)
 }
 
+// LogBatch adds and removes the given pins from the shared state and commits
+// all the changes in a single batch.
+func (css *Consensus) LogBatch(ctx context.Context, pins []api.Pin, unpins []api.Pin) error {
+	ctx, span := trace.StartSpan(ctx, "consensus/LogBatch")
+	defer span.End()
+
+	if css.config.ReadOnly {
+		return ErrReadOnly
+	}
+
+	for _, pin := range pins {
+		if err := css.batchingState.Add(ctx, pin); err != nil {
+			return err
+		}
+	}
+	for _, pin := range unpins {
+		if err := css.batchingState.Rm(ctx, pin.Cid); err != nil {
+			return err
+		}
+	}
+	return css.batchingState.Commit(ctx)
+}
+
 func (css *Consensus) sendToBatchWorker() {
 	for {
 		select {
@@ -640,6 +745,30 @@ func (css *Consensus) RmPeer(ctx context.Context, pid peer.ID) error {
 	return ErrRmPeer
 }
 
+// indexer is implemented by states which keep a search index, which must be
+// updated with the pins replicated from other peers.
+type indexer interface {
+	Index(api.Pin)
+	Unindex(api.Cid)
+}
+
+// updateIndexes calls f with the states which keep a search index. Changes
+// received before the states are ready are skipped: the indexes are built
+// later, from the full pinset, on the first search.
+func (css *Consensus) updateIndexes(f func(indexer)) {
+	select {
+	case <-css.stateReady:
+	default:
+		return
+	}
+	if idx, ok := css.state.(indexer); ok {
+		f(idx)
+	}
+	if idx, ok := css.batchingState.(indexer); ok {
+		f(idx)
+	}
+}
+
 // State returns the cluster shared state. It will block until the consensus
 // component is ready, shutdown or the given context has been canceled.
 func (css *Consensus) State(ctx context.Context) (state.ReadOnly, error) {
@@ -704,6 +833,9 @@ func OfflineState(cfg *Config, store ds.Datastore) (state.BatchingState, error)
 	}
 	opts := crdt.DefaultOptions()
 	opts.Logger = logger
+	// An offline store cannot fetch blocks for repairs. Disabling them
+	// also avoids touching the datastore once the caller closes it.
+	opts.RepairInterval = 0
 
 	var blocksDatastore ds.Batching = namespace.Wrap(
 		batching,
@@ -734,5 +866,23 @@ func OfflineState(cfg *Config, store ds.Datastore) (state.BatchingState, error)
 	if err != nil {
 		return nil, err
 	}
-	return dsstate.NewBatching(context.Background(), crdt, "", dsstate.DefaultHandle())
+
+	// Decrypt with the keys stored locally, as there is nobody to ask
+	// for others.
+	css := &Consensus{
+		ctx:       context.Background(),
+		config:    cfg,
+		store:     batching,
+		namespace: ds.NewKey(cfg.DatastoreNamespace),
+	}
+	if err := css.loadCurrentKey(); err != nil {
+		return nil, err
+	}
+	if cfg.Encryption && css.keys.current == nil {
+		if err := css.rotateKey(); err != nil {
+			return nil, err
+		}
+	}
+	encrypted := &encryptedStore{Batching: crdt, css: css}
+	return dsstate.NewBatching(context.Background(), encrypted, "", dsstate.DefaultHandle())
 }
@@ -1,24 +1,32 @@
 package crdt
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/ipfs-cluster/ipfs-cluster/api"
 	"github.com/ipfs-cluster/ipfs-cluster/datastore/inmem"
+	"github.com/ipfs-cluster/ipfs-cluster/observations"
 	"github.com/ipfs-cluster/ipfs-cluster/test"
 
+	query "github.com/ipfs/go-datastore/query"
+	crdt "github.com/ipfs/go-ds-crdt"
 	ipns "github.com/ipfs/go-ipns"
 	libp2p "github.com/libp2p/go-libp2p"
 	host "github.com/libp2p/go-libp2p/core/host"
+	peer "github.com/libp2p/go-libp2p/core/peer"
 	peerstore "github.com/libp2p/go-libp2p/core/peerstore"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	dual "github.com/libp2p/go-libp2p-kad-dht/dual"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	record "github.com/libp2p/go-libp2p-record"
 	routedhost "github.com/libp2p/go-libp2p/p2p/host/routed"
+	"go.opencensus.io/stats/view"
 )
 
 func makeTestingHost(t *testing.T) (host.Host, *pubsub.PubSub, *dual.DHT) {
@@ -157,6 +165,47 @@ func TestConsensusUnpin(t *testing.T) {
 	}
 }
 
+func TestConsensusLogBatch(t *testing.T) {
+	ctx := context.Background()
+	cc := testingConsensus(t, 1)
+	defer clean(t, cc)
+	defer cc.Shutdown(ctx)
+
+	err := cc.LogPin(ctx, testPin(test.Cid1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cc.LogBatch(
+		ctx,
+		[]api.Pin{testPin(test.Cid2), testPin(test.Cid3)},
+		[]api.Pin{api.PinCid(test.Cid1)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	st, err := cc.State(ctx)
+	if err != nil {
+		t.Fatal("error getting state:", err)
+	}
+
+	out := make(chan api.Pin, 10)
+	err = st.List(ctx, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pins := make(map[api.Cid]bool)
+	for p := range out {
+		pins[p.Cid] = true
+	}
+	if len(pins) != 2 || !pins[test.Cid2] || !pins[test.Cid3] {
+		t.Errorf("unexpected pins in the state: %v", pins)
+	}
+}
+
 func TestConsensusUpdate(t *testing.T) {
 	ctx := context.Background()
 	cc := testingConsensus(t, 1)
@@ -329,6 +378,173 @@ func TestConsensusDistrustPeer(t *testing.T) {
 	}
 }
 
+func TestReadOnly(t *testing.T) {
+	ctx := context.Background()
+	cc := testingConsensus(t, 1)
+	cfg := &Config{}
+	cfg.Default()
+	cfg.ReadOnly = true
+	cc2 := testingConsensusWithCfg(t, 2, cfg)
+	defer clean(t, cc)
+	defer clean(t, cc2)
+	defer cc.Shutdown(ctx)
+	defer cc2.Shutdown(ctx)
+
+	cc.host.Peerstore().AddAddrs(cc2.host.ID(), cc2.host.Addrs(), peerstore.PermanentAddrTTL)
+	_, err := cc.host.Network().DialPeer(ctx, cc2.host.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	err = cc2.LogPin(ctx, testPin(test.Cid2))
+	if err != ErrReadOnly {
+		t.Error("expected ErrReadOnly:", err)
+	}
+	err = cc2.LogBatch(ctx, []api.Pin{testPin(test.Cid2)}, nil)
+	if err != ErrReadOnly {
+		t.Error("expected ErrReadOnly:", err)
+	}
+	err = cc2.LogTrust(ctx, test.PeerID1)
+	if err != ErrReadOnly {
+		t.Error("expected ErrReadOnly:", err)
+	}
+
+	// Pins from other peers reach the read-only peer. Give pubsub
+	// some time to form the mesh first.
+	time.Sleep(time.Second)
+	err = cc.LogPin(ctx, testPin(test.Cid1))
+	if err != nil {
+		t.Error(err)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	st, err := cc2.State(ctx)
+	if err != nil {
+		t.Fatal("error getting state:", err)
+	}
+
+	out := make(chan api.Pin, 10)
+	err = st.List(ctx, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pins []api.Pin
+
+	for p := range out {
+		pins = append(pins, p)
+	}
+
+	if len(pins) != 1 || !pins[0].Cid.Equals(test.Cid1) {
+		t.Error("only the first peer's pin should be in the state")
+	}
+}
+
+func TestLogTrust(t *testing.T) {
+	ctx := context.Background()
+	cc := testingConsensus(t, 1)
+	cfg := &Config{}
+	cfg.Default()
+	cfg.TrustAll = false
+	cc2 := testingConsensusWithCfg(t, 2, cfg)
+	defer clean(t, cc)
+	defer clean(t, cc2)
+	defer cc.Shutdown(ctx)
+	defer cc2.Shutdown(ctx)
+
+	cc.host.Peerstore().AddAddrs(cc2.host.ID(), cc2.host.Addrs(), peerstore.PermanentAddrTTL)
+	_, err := cc.host.Network().DialPeer(ctx, cc2.host.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cc2.IsTrustedPeer(ctx, cc.host.ID()) {
+		t.Fatal("peer1 should not be trusted yet")
+	}
+	err = cc2.LogTrust(ctx, cc.host.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cc2.IsTrustedPeer(ctx, cc.host.ID()) {
+		t.Fatal("peer1 should be trusted")
+	}
+	peers, err := cc2.TrustedPeers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 1 || peers[0] != cc.host.ID() {
+		t.Errorf("unexpected trusted peers: %s", peers)
+	}
+
+	// Give pubsub some time to form the mesh.
+	time.Sleep(time.Second)
+
+	waitTrust := func(pid peer.ID, trusted bool) {
+		t.Helper()
+		for i := 0; i < 50; i++ {
+			if cc2.IsTrustedPeer(ctx, pid) == trusted {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatalf("%s should be trusted: %t", pid, trusted)
+	}
+
+	// Changes recorded by peer1 now reach peer2.
+	err = cc.LogTrust(ctx, test.PeerID1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitTrust(test.PeerID1, true)
+
+	err = cc.LogDistrust(ctx, test.PeerID1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitTrust(test.PeerID1, false)
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	views := []*view.View{
+		observations.CrdtQueuedOpsView,
+		observations.CrdtDAGHeightView,
+		observations.CrdtBroadcastLatencyView,
+	}
+	err := view.Register(views...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(views...)
+
+	cc := testingConsensus(t, 1)
+	defer clean(t, cc)
+	defer cc.Shutdown(ctx)
+
+	err = cc.LogPin(ctx, testPin(test.Cid1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc.recordStats()
+
+	rows, err := view.RetrieveData(observations.CrdtDAGHeightView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Data.(*view.LastValueData).Value != 1 {
+		t.Errorf("unexpected DAG height: %v", rows)
+	}
+
+	rows, err = view.RetrieveData(observations.CrdtBroadcastLatencyView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Data.(*view.DistributionData).Count == 0 {
+		t.Errorf("expected broadcast latencies: %v", rows)
+	}
+}
+
 func TestPeers(t *testing.T) {
 	ctx := context.Background()
 	cc := testingConsensus(t, 1)
@@ -494,3 +710,365 @@ func TestBatching(t *testing.T) {
 		t.Error("expected 5 items pinned")
 	}
 }
+
+func TestSnapshots(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
+	cfg.SnapshotInterval = 200 * time.Millisecond
+	cc := testingConsensusWithCfg(t, 1, cfg)
+	defer clean(t, cc)
+	defer cc.Shutdown(ctx)
+
+	for _, c := range []api.Cid{test.Cid1, test.Cid2} {
+		err := cc.LogPin(ctx, testPin(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	// A fresh peer should import the snapshot and be in sync as soon
+	// as it is ready.
+	h, psub, dht := makeTestingHost(t)
+	cfg2 := &Config{}
+	cfg2.Default()
+	cfg2.DatastoreNamespace = "crdttest-2"
+	cfg2.SnapshotBootstrapTimeout = 10 * time.Second
+	cfg2.hostShutdown = true
+	cc2, err := New(h, dht, psub, cfg2, inmem.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clean(t, cc2)
+	defer cc2.Shutdown(ctx)
+
+	h.Peerstore().AddAddrs(cc.host.ID(), cc.host.Addrs(), peerstore.PermanentAddrTTL)
+	_, err = h.Network().DialPeer(ctx, cc.host.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc2.SetClient(test.NewMockRPCClientWithHost(t, h))
+
+	select {
+	case <-cc2.Ready(ctx):
+	case <-time.After(15 * time.Second):
+		t.Fatal("consensus should be ready")
+	}
+
+	st, err := cc2.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(chan api.Pin, 10)
+	err = st.List(ctx, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pins []api.Pin
+	for p := range out {
+		pins = append(pins, p)
+	}
+	if len(pins) != 2 {
+		t.Fatalf("expected 2 pins from the snapshot: %v", pins)
+	}
+
+	// And should keep syncing new deltas, once the pubsub mesh is
+	// formed.
+	time.Sleep(time.Second)
+	err = cc.LogPin(ctx, testPin(test.Cid3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if has, _ := st.Has(ctx, test.Cid3); has {
+			return
+		}
+	}
+	t.Error("the new pin should have been synced")
+}
+
+func TestSnapshotSignature(t *testing.T) {
+	ctx := context.Background()
+	cc := testingConsensus(t, 1)
+	defer clean(t, cc)
+	defer cc.Shutdown(ctx)
+
+	err := cc.LogPin(ctx, testPin(test.Cid1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := cc.exportSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Entries) == 0 {
+		t.Fatal("the snapshot should have entries")
+	}
+	data, err := cc.signSnapshot(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cc.verifySnapshot(ctx, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap.Entries = snap.Entries[1:]
+	tampered, err := cc.signSnapshot(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signed, orig signedSnapshot
+	json.Unmarshal(tampered, &signed)
+	json.Unmarshal(data, &orig)
+	signed.Signature = orig.Signature
+	tampered, _ = json.Marshal(signed)
+	_, err = cc.verifySnapshot(ctx, tampered)
+	if err == nil {
+		t.Error("expected an error verifying a tampered snapshot")
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
+	cfg.Encryption = true
+	cc := testingConsensusWithCfg(t, 1, cfg)
+	cc2 := testingConsensus(t, 2)
+	defer clean(t, cc)
+	defer clean(t, cc2)
+	defer cc.Shutdown(ctx)
+	defer cc2.Shutdown(ctx)
+
+	// The mock RPC cannot provide keys. Fail to fetch them quickly.
+	oldTimeout := keyFetchTimeout
+	keyFetchTimeout = 100 * time.Millisecond
+	defer func() { keyFetchTimeout = oldTimeout }()
+
+	cc.host.Peerstore().AddAddrs(cc2.host.ID(), cc2.host.Addrs(), peerstore.PermanentAddrTTL)
+	_, err := cc.host.Network().DialPeer(ctx, cc2.host.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	pinNames := func(cc *Consensus) []string {
+		st, err := cc.State(ctx)
+		if err != nil {
+			t.Fatal("error getting state:", err)
+		}
+		out := make(chan api.Pin, 10)
+		err = st.List(ctx, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for p := range out {
+			names = append(names, p.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	pin := testPin(test.Cid1)
+	pin.Name = "secret-name-1"
+	err = cc.LogPin(ctx, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldKey := cc.keys.current.ID
+	err = cc.rotateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc.keys.current.ID == oldKey {
+		t.Fatal("the key should have been rotated")
+	}
+
+	pin = testPin(test.Cid2)
+	pin.Name = "secret-name-2"
+	err = cc.LogPin(ctx, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	names := pinNames(cc)
+	if len(names) != 2 || names[0] != "secret-name-1" || names[1] != "secret-name-2" {
+		t.Errorf("the encrypting peer should read both pins: %v", names)
+	}
+
+	results, err := cc2.crdt.Query(ctx, query.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for r := range results.Next() {
+		n++
+		if r.Value[0] != encryptedPrefix || bytes.Contains(r.Value, []byte("secret-name")) {
+			t.Errorf("%s should be encrypted", r.Key)
+		}
+	}
+	results.Close()
+	if n != 2 {
+		t.Fatalf("expected 2 values in the replica: %d", n)
+	}
+
+	if names := pinNames(cc2); len(names) != 0 {
+		t.Errorf("the values should not be readable without the keys: %v", names)
+	}
+
+	// Keys which could not be fetched are not asked for again right
+	// away, and listings do not wait for them.
+	if _, err := cc2.getKey(ctx, oldKey); err != ErrEncryptionKeyUnavailable {
+		t.Error("expected ErrEncryptionKeyUnavailable:", err)
+	}
+	if cc2.keys.startFetch(oldKey) {
+		t.Error("the key should not be fetched again until the retry interval passes")
+	}
+	start := time.Now()
+	pinNames(cc2)
+	if time.Since(start) >= keyFetchTimeout {
+		t.Error("listing should not wait for the keys to be fetched")
+	}
+
+	for _, id := range []string{oldKey, cc.keys.current.ID} {
+		key, err := cc.EncryptionKey(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cc2.store.Put(ctx, cc2.keysKey(id), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names = pinNames(cc2)
+	if len(names) != 2 || names[0] != "secret-name-1" || names[1] != "secret-name-2" {
+		t.Errorf("the values should be readable with the keys: %v", names)
+	}
+
+	_, err = cc2.EncryptionKey(ctx, "abcd")
+	if err != ErrEncryptionKeyUnavailable {
+		t.Error("expected ErrEncryptionKeyUnavailable:", err)
+	}
+}
+
+type countingBroadcaster struct {
+	crdt.Broadcaster
+	n int
+}
+
+func (cb *countingBroadcaster) Broadcast(data []byte) error {
+	cb.n++
+	return nil
+}
+
+func TestDesignatedPublishers(t *testing.T) {
+	ctx := context.Background()
+
+	trusted := func(p peer.ID) bool { return p != test.PeerID1 }
+	peers := []peer.ID{test.PeerID3, test.PeerID1, test.PeerID2}
+	pubs := electPublishers(peers, trusted, 1)
+	expected := test.PeerID2
+	if test.PeerID3 < test.PeerID2 {
+		expected = test.PeerID3
+	}
+	if len(pubs) != 1 || pubs[0] != expected {
+		t.Errorf("expected %s to be elected: %s", expected, pubs)
+	}
+	if pubs := electPublishers(peers, trusted, 5); len(pubs) != 2 {
+		t.Errorf("only trusted peers should be elected: %s", pubs)
+	}
+
+	cfg := &Config{}
+	cfg.Default()
+	cfg.TrustAll = false
+	cfg.DesignatedPublishers = 1
+	cc := testingConsensusWithCfg(t, 1, cfg)
+	defer clean(t, cc)
+	defer cc.Shutdown(ctx)
+
+	cb := &countingBroadcaster{}
+	pb := publisherBroadcaster{Broadcaster: cb, css: cc}
+
+	// The peerset has this peer and PeerID1, which is not trusted.
+	pb.Broadcast(nil)
+	if cb.n != 1 {
+		t.Error("the only trusted peer should be a publisher")
+	}
+
+	cc.Trust(ctx, test.PeerID1)
+	publisher := cc.host.ID() < test.PeerID1
+	pb.Broadcast(nil)
+	if publisher && cb.n != 2 || !publisher && cb.n != 1 {
+		t.Errorf("unexpected broadcasts (publisher: %t): %d", publisher, cb.n)
+	}
+
+	// Broadcasts from local updates always go through.
+	cb.n = 0
+	cc.localWrites.Add(1)
+	pb.Broadcast(nil)
+	cc.localWrites.Add(-1)
+	if cb.n != 1 {
+		t.Error("local updates should be broadcasted")
+	}
+
+	err := cc.LogPin(ctx, testPin(test.Cid1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc.localWrites.Load() != 0 {
+		t.Error("no writes should be in progress")
+	}
+}
+
+func TestOperationLog(t *testing.T) {
+	ctx := context.Background()
+	cc := testingConsensus(t, 1)
+	defer clean(t, cc)
+	defer cc.Shutdown(ctx)
+
+	err := cc.LogPin(ctx, testPin(test.Cid1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cc.LogPin(ctx, testPin(test.Cid2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cc.LogUnpin(ctx, api.PinCid(test.Cid1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ops, err := cc.OperationLog(ctx, api.OpLogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 3 {
+		t.Fatalf("expected 3 operations, got %d", len(ops))
+	}
+	if ops[0].Height != 3 || ops[2].Height != 1 {
+		t.Error("operations should be sorted from the most recent")
+	}
+	if len(ops[0].Unpins) != 1 || !ops[0].Unpins[0].Equals(test.Cid1) {
+		t.Error("the last operation should unpin Cid1")
+	}
+	if len(ops[1].Pins) != 1 || !ops[1].Pins[0].Cid.Equals(test.Cid2) {
+		t.Error("the second operation should pin Cid2")
+	}
+
+	ops, err = cc.OperationLog(ctx, api.OpLogOptions{Offset: 1, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 1 || ops[0].Height != 2 {
+		t.Error("offset and limit should select the second operation")
+	}
+}
@@ -0,0 +1,497 @@
+package crdt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	query "github.com/ipfs/go-datastore/query"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+
+	trace "go.opencensus.io/trace"
+)
+
+// When encryption is enabled, pin values are encrypted with AES-GCM before
+// they are written to the CRDT store, so that they are not readable from the
+// DAG by peers that can join the topic and fetch its blocks. Keys (the CIDs)
+// are not encrypted.
+//
+// Every peer encrypts with a key of its own, which is rotated every
+// KeyRotationInterval. Encrypted values carry the ID of their key, and
+// peers which do not know it ask for it to the other peers in the
+// cluster. This is only allowed to trusted peers, which makes encryption
+// meaningless when all peers are trusted.
+const (
+	keysNs       = "keys"
+	currentKeyNs = "current_key"
+)
+
+// encryptedPrefix starts every encrypted value. Serialized pins never
+// start with it, since it is not a valid protobuf tag.
+const encryptedPrefix = 0x00
+
+const keyIDSize = 8
+
+// keyFetchTimeout bounds how long we try to fetch an unknown key from
+// other peers.
+var keyFetchTimeout = 30 * time.Second
+
+// keyRetryInterval is how long we wait before asking other peers again for
+// a key that none of them provided, i.e. because the peer which used it has
+// left the cluster.
+var keyRetryInterval = 5 * time.Minute
+
+// ErrEncryptionKeyUnavailable is returned when a value cannot be decrypted
+// because its key is not known locally and no peer provided it.
+var ErrEncryptionKeyUnavailable = errors.New("crdt encryption key not available")
+
+type currentKey struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+
+	key []byte
+}
+
+// encryptionKeys holds the keys known to this peer.
+type encryptionKeys struct {
+	mux     sync.RWMutex
+	keys    map[string][]byte
+	current *currentKey
+
+	// retry holds, for the keys which are being fetched from other
+	// peers or that they could not provide, when to ask for them again.
+	retry map[string]time.Time
+}
+
+// startFetch returns true when the key with the given ID can be asked for
+// to other peers now, and records that it is being fetched.
+func (ek *encryptionKeys) startFetch(id string) bool {
+	ek.mux.Lock()
+	defer ek.mux.Unlock()
+	now := time.Now()
+	if now.Before(ek.retry[id]) {
+		return false
+	}
+	if ek.retry == nil {
+		ek.retry = make(map[string]time.Time)
+	}
+	ek.retry[id] = now.Add(keyFetchTimeout)
+	return true
+}
+
+// endFetch records the result of fetching the key with the given ID. Keys
+// which could not be fetched are not asked for until keyRetryInterval
+// passes.
+func (ek *encryptionKeys) endFetch(id string, fetched bool) {
+	ek.mux.Lock()
+	defer ek.mux.Unlock()
+	if fetched {
+		delete(ek.retry, id)
+		return
+	}
+	ek.retry[id] = time.Now().Add(keyRetryInterval)
+}
+
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:keyIDSize])
+}
+
+func (css *Consensus) keysKey(id string) ds.Key {
+	return css.namespace.ChildString(keysNs).ChildString(id)
+}
+
+// setupEncryption loads the current key, rotating it when needed, and
+// launches the rotation worker.
+func (css *Consensus) setupEncryption() error {
+	if err := css.loadCurrentKey(); err != nil {
+		return err
+	}
+
+	if !css.config.Encryption {
+		return nil
+	}
+
+	if css.config.TrustAll {
+		logger.Warn("crdt encryption enabled but all peers are trusted. Any peer can obtain the encryption keys")
+	}
+
+	interval := css.config.KeyRotationInterval
+	if css.keys.current == nil || (interval > 0 && css.nextRotation() <= 0) {
+		if err := css.rotateKey(); err != nil {
+			return err
+		}
+	}
+
+	if interval > 0 && !css.config.ReadOnly {
+		go css.keyRotationWorker()
+	}
+	return nil
+}
+
+// loadCurrentKey loads the key that this peer last used for encryption.
+func (css *Consensus) loadCurrentKey() error {
+	css.keys.keys = make(map[string][]byte)
+
+	v, err := css.store.Get(css.ctx, css.namespace.ChildString(currentKeyNs))
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var cur currentKey
+	if err := json.Unmarshal(v, &cur); err != nil {
+		return err
+	}
+	key, err := css.store.Get(css.ctx, css.keysKey(cur.ID))
+	if err != nil {
+		return err
+	}
+	cur.key = key
+	css.keys.keys[cur.ID] = key
+	css.keys.current = &cur
+	return nil
+}
+
+// nextRotation returns how long until the current key must be rotated.
+func (css *Consensus) nextRotation() time.Duration {
+	css.keys.mux.RLock()
+	defer css.keys.mux.RUnlock()
+
+	cur := css.keys.current
+	if cur == nil {
+		return 0
+	}
+	return time.Until(cur.Created.Add(css.config.KeyRotationInterval))
+}
+
+func (css *Consensus) keyRotationWorker() {
+	timer := time.NewTimer(css.nextRotation())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-css.ctx.Done():
+			return
+		case <-timer.C:
+			if err := css.rotateKey(); err != nil {
+				logger.Errorf("error rotating encryption key: %s", err)
+			}
+			timer.Reset(css.nextRotation())
+		}
+	}
+}
+
+// rotateKey generates a new key and starts using it for encryption. Old
+// keys are kept to decrypt older values and to give them to other peers.
+func (css *Consensus) rotateKey() error {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return err
+	}
+	cur := &currentKey{
+		ID:      keyID(key),
+		Created: time.Now(),
+		key:     key,
+	}
+	v, err := json.Marshal(cur)
+	if err != nil {
+		return err
+	}
+	if err := css.store.Put(css.ctx, css.keysKey(cur.ID), key); err != nil {
+		return err
+	}
+	if err := css.store.Put(css.ctx, css.namespace.ChildString(currentKeyNs), v); err != nil {
+		return err
+	}
+
+	css.keys.mux.Lock()
+	css.keys.keys[cur.ID] = key
+	css.keys.current = cur
+	css.keys.mux.Unlock()
+	logger.Infof("using new crdt encryption key %s", cur.ID)
+	return nil
+}
+
+// EncryptionKey returns the encryption key with the given ID when this
+// peer knows it. Other peers call it to decrypt values.
+func (css *Consensus) EncryptionKey(ctx context.Context, id string) ([]byte, error) {
+	_, span := trace.StartSpan(ctx, "consensus/EncryptionKey")
+	defer span.End()
+
+	return css.localKey(ctx, id)
+}
+
+func (css *Consensus) localKey(ctx context.Context, id string) ([]byte, error) {
+	css.keys.mux.RLock()
+	key, ok := css.keys.keys[id]
+	css.keys.mux.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	key, err := css.store.Get(ctx, css.keysKey(id))
+	if err == ds.ErrNotFound {
+		return nil, ErrEncryptionKeyUnavailable
+	}
+	if err != nil {
+		return nil, err
+	}
+	css.keys.mux.Lock()
+	css.keys.keys[id] = key
+	css.keys.mux.Unlock()
+	return key, nil
+}
+
+// getKey returns the key with the given ID, asking other peers for it
+// when it is not known locally, unless it is being fetched already or they
+// could not provide it recently.
+func (css *Consensus) getKey(ctx context.Context, id string) ([]byte, error) {
+	key, err := css.localKey(ctx, id)
+	if err != ErrEncryptionKeyUnavailable || css.rpcClient == nil {
+		return key, err
+	}
+	if !css.keys.startFetch(id) {
+		return nil, ErrEncryptionKeyUnavailable
+	}
+	key, err = css.fetchKeyFromPeers(ctx, id)
+	css.keys.endFetch(id, err == nil)
+	return key, err
+}
+
+// fetchKeyInBackground asks other peers for the key with the given ID
+// without waiting for them, unless it is being fetched already or they
+// could not provide it recently.
+func (css *Consensus) fetchKeyInBackground(id string) {
+	if css.rpcClient == nil || !css.keys.startFetch(id) {
+		return
+	}
+	go func() {
+		_, err := css.fetchKeyFromPeers(css.ctx, id)
+		css.keys.endFetch(id, err == nil)
+	}()
+}
+
+// fetchKeyFromPeers asks every other peer for the key with the given ID
+// until one provides it, and stores it.
+func (css *Consensus) fetchKeyFromPeers(ctx context.Context, id string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, keyFetchTimeout)
+	defer cancel()
+
+	peers, err := css.Peers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range peers {
+		if p == css.host.ID() {
+			continue
+		}
+		key, err := css.fetchKey(ctx, p, id)
+		if err != nil {
+			logger.Debugf("error fetching encryption key %s from %s: %s", id, p, err)
+			continue
+		}
+		if err := css.store.Put(ctx, css.keysKey(id), key); err != nil {
+			return nil, err
+		}
+		css.keys.mux.Lock()
+		css.keys.keys[id] = key
+		css.keys.mux.Unlock()
+		logger.Infof("obtained crdt encryption key %s from %s", id, p)
+		return key, nil
+	}
+	return nil, ErrEncryptionKeyUnavailable
+}
+
+func (css *Consensus) fetchKey(ctx context.Context, p peer.ID, id string) ([]byte, error) {
+	var key []byte
+	err := css.rpcClient.CallContext(
+		ctx,
+		p,
+		"Consensus",
+		"EncryptionKey",
+		id,
+		&key,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if keyID(key) != id {
+		return nil, fmt.Errorf("peer %s sent a wrong key for %s", p, id)
+	}
+	return key, nil
+}
+
+// encrypt returns the encrypted value, bound to the given key, when
+// encryption is enabled.
+func (css *Consensus) encrypt(k ds.Key, v []byte) ([]byte, error) {
+	if !css.config.Encryption {
+		return v, nil
+	}
+
+	css.keys.mux.RLock()
+	cur := css.keys.current
+	css.keys.mux.RUnlock()
+	if cur == nil {
+		return nil, ErrEncryptionKeyUnavailable
+	}
+
+	aead, err := newAEAD(cur.key)
+	if err != nil {
+		return nil, err
+	}
+	id, err := hex.DecodeString(cur.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, 1+keyIDSize+aead.NonceSize()+len(v)+aead.Overhead())
+	out = append(out, encryptedPrefix)
+	out = append(out, id...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, v, k.Bytes()), nil
+}
+
+// decrypt returns the plain value. Values which are not encrypted are
+// returned as they are.
+func (css *Consensus) decrypt(ctx context.Context, k ds.Key, v []byte) ([]byte, error) {
+	return css.decryptWith(ctx, k, v, css.getKey)
+}
+
+// decryptLocal works like decrypt, but only with the keys known locally.
+// Unknown keys are fetched from other peers in the background, so that the
+// values can be decrypted later on.
+func (css *Consensus) decryptLocal(ctx context.Context, k ds.Key, v []byte) ([]byte, error) {
+	return css.decryptWith(ctx, k, v, func(ctx context.Context, id string) ([]byte, error) {
+		key, err := css.localKey(ctx, id)
+		if err == ErrEncryptionKeyUnavailable {
+			css.fetchKeyInBackground(id)
+		}
+		return key, err
+	})
+}
+
+func (css *Consensus) decryptWith(ctx context.Context, k ds.Key, v []byte, getKey func(context.Context, string) ([]byte, error)) ([]byte, error) {
+	if len(v) == 0 || v[0] != encryptedPrefix {
+		return v, nil
+	}
+	if len(v) < 1+keyIDSize {
+		return nil, errors.New("encrypted value is too short")
+	}
+
+	id := hex.EncodeToString(v[1 : 1+keyIDSize])
+	key, err := getKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	rest := v[1+keyIDSize:]
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("encrypted value is too short")
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, k.Bytes())
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptedStore wraps the CRDT store to encrypt the values written to it
+// and decrypt the values read from it.
+type encryptedStore struct {
+	ds.Batching
+	css *Consensus
+}
+
+func (es *encryptedStore) Get(ctx context.Context, k ds.Key) ([]byte, error) {
+	v, err := es.Batching.Get(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+	return es.css.decrypt(ctx, k, v)
+}
+
+func (es *encryptedStore) Put(ctx context.Context, k ds.Key, v []byte) error {
+	v, err := es.css.encrypt(k, v)
+	if err != nil {
+		return err
+	}
+	return es.Batching.Put(ctx, k, v)
+}
+
+// Query decrypts the results. Those which cannot be decrypted are skipped.
+// Only the keys known locally are used, so that listings do not wait for
+// other peers, which are asked for the unknown keys in the background.
+func (es *encryptedStore) Query(ctx context.Context, q query.Query) (query.Results, error) {
+	results, err := es.Batching.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if q.KeysOnly {
+		return results, nil
+	}
+
+	return query.ResultsFromIterator(q, query.Iterator{
+		Next: func() (query.Result, bool) {
+			for {
+				r, ok := results.NextSync()
+				if !ok || r.Error != nil {
+					return r, ok
+				}
+				v, err := es.css.decryptLocal(ctx, ds.NewKey(r.Key), r.Value)
+				if err != nil {
+					logger.Errorf("cannot decrypt %s: %s", r.Key, err)
+					continue
+				}
+				r.Value = v
+				return r, true
+			}
+		},
+		Close: results.Close,
+	}), nil
+}
+
+func (es *encryptedStore) Batch(ctx context.Context) (ds.Batch, error) {
+	b, err := es.Batching.Batch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedBatch{Batch: b, css: es.css}, nil
+}
+
+type encryptedBatch struct {
+	ds.Batch
+	css *Consensus
+}
+
+func (eb *encryptedBatch) Put(ctx context.Context, k ds.Key, v []byte) error {
+	v, err := eb.css.encrypt(k, v)
+	if err != nil {
+		return err
+	}
+	return eb.Batch.Put(ctx, k, v)
+}
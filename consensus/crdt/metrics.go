@@ -0,0 +1,55 @@
+package crdt
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/observations"
+
+	crdt "github.com/ipfs/go-ds-crdt"
+	"go.opencensus.io/stats"
+)
+
+// statsInterval is how often the queued operations and the DAG height are
+// recorded.
+var statsInterval = 10 * time.Second
+
+// metricsBroadcaster records how long it takes to broadcast every update.
+type metricsBroadcaster struct {
+	crdt.Broadcaster
+}
+
+func (mb metricsBroadcaster) Broadcast(data []byte) error {
+	start := time.Now()
+	err := mb.Broadcaster.Broadcast(data)
+	latency := float64(time.Since(start)) / float64(time.Millisecond)
+	stats.Record(context.Background(), observations.CrdtBroadcastLatency.M(latency))
+	return err
+}
+
+// statsWorker records the CRDT stats every statsInterval.
+func (css *Consensus) statsWorker() {
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-css.ctx.Done():
+			return
+		case <-ticker.C:
+			css.recordStats()
+		}
+	}
+}
+
+// recordStats records the operations waiting to be batched along with the
+// DAG jobs waiting to be processed, and the current DAG height.
+func (css *Consensus) recordStats() {
+	st := css.crdt.InternalStats()
+	queued := len(css.batchItemCh) + st.QueuedJobs
+	stats.Record(
+		css.ctx,
+		observations.CrdtQueuedOps.M(int64(queued)),
+		observations.CrdtDAGHeight.M(int64(st.MaxHeight)),
+	)
+}
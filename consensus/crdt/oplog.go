@@ -0,0 +1,165 @@
+package crdt
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	pb "github.com/ipfs/go-ds-crdt/pb"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	dag "github.com/ipfs/go-merkledag"
+	"google.golang.org/protobuf/proto"
+
+	trace "go.opencensus.io/trace"
+)
+
+type opLogNode struct {
+	c     cid.Cid
+	nd    *dag.ProtoNode
+	delta *pb.Delta
+}
+
+// OperationLog walks the CRDT DAG from the current heads and returns a
+// page of the deltas found, from the highest priority. Branches end on
+// blocks which are not stored locally, i.e. those removed after taking a
+// snapshot.
+func (css *Consensus) OperationLog(ctx context.Context, opts api.OpLogOptions) ([]api.ConsensusOp, error) {
+	ctx, span := trace.StartSpan(ctx, "consensus/OperationLog")
+	defer span.End()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-css.ctx.Done():
+		return nil, css.ctx.Err()
+	case <-css.stateReady:
+	}
+
+	heads, err := css.queryEntries(ctx, crdtHeadsNs, true)
+	if err != nil {
+		return nil, err
+	}
+
+	visited := make(map[cid.Cid]struct{})
+	var pending []opLogNode
+	push := func(c cid.Cid) {
+		if _, ok := visited[c]; ok {
+			return
+		}
+		visited[c] = struct{}{}
+		n, err := css.opLogNode(ctx, c)
+		if err != nil {
+			logger.Debugf("operation log: skipping %s: %s", c, err)
+			return
+		}
+		pending = append(pending, n)
+	}
+
+	for _, h := range heads {
+		mh, err := dshelp.DsKeyToMultihash(ds.NewKey(ds.NewKey(h.Key).BaseNamespace()))
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+		push(cid.NewCidV1(cid.DagProtobuf, mh))
+	}
+
+	var ops []api.ConsensusOp
+	for i := 0; len(pending) > 0; i++ {
+		if opts.Limit > 0 && len(ops) == opts.Limit {
+			break
+		}
+
+		// Pop the highest priority node.
+		sort.Slice(pending, func(i, j int) bool {
+			pi, pj := pending[i].delta.GetPriority(), pending[j].delta.GetPriority()
+			if pi != pj {
+				return pi > pj
+			}
+			return pending[i].c.KeyString() < pending[j].c.KeyString()
+		})
+		n := pending[0]
+		pending = pending[1:]
+		for _, l := range n.nd.Links() {
+			push(l.Cid)
+		}
+
+		if i < opts.Offset {
+			continue
+		}
+		ops = append(ops, css.consensusOp(ctx, n))
+	}
+	return ops, nil
+}
+
+func (css *Consensus) opLogNode(ctx context.Context, c cid.Cid) (opLogNode, error) {
+	has, err := css.ipfs.BlockStore().Has(ctx, c)
+	if err != nil {
+		return opLogNode{}, err
+	}
+	if !has {
+		return opLogNode{}, errors.New("block not available locally")
+	}
+	nd, err := css.ipfs.Get(ctx, c)
+	if err != nil {
+		return opLogNode{}, err
+	}
+	pnd, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return opLogNode{}, errors.New("node is not a ProtoNode")
+	}
+	delta := &pb.Delta{}
+	if err := proto.Unmarshal(pnd.Data(), delta); err != nil {
+		return opLogNode{}, err
+	}
+	return opLogNode{c: c, nd: pnd, delta: delta}, nil
+}
+
+// consensusOp decodes the pins and unpins in a delta. Pins which cannot be
+// decrypted are left out.
+func (css *Consensus) consensusOp(ctx context.Context, n opLogNode) api.ConsensusOp {
+	op := api.ConsensusOp{
+		ID:     n.c.String(),
+		Height: n.delta.GetPriority(),
+	}
+
+	for _, e := range n.delta.GetElements() {
+		k := ds.NewKey(e.GetKey())
+		v, err := css.decrypt(ctx, k, e.GetValue())
+		if err != nil {
+			logger.Debugf("operation log: cannot decrypt %s: %s", k, err)
+			continue
+		}
+		var pin api.Pin
+		if err := pin.ProtoUnmarshal(v); err != nil {
+			logger.Error(err)
+			continue
+		}
+		op.Pins = append(op.Pins, pin)
+	}
+
+	// There is a tombstone for every previous value of the key.
+	seen := make(map[string]struct{})
+	for _, e := range n.delta.GetTombstones() {
+		if _, ok := seen[e.GetKey()]; ok {
+			continue
+		}
+		seen[e.GetKey()] = struct{}{}
+		kb, err := dshelp.BinaryFromDsKey(ds.NewKey(e.GetKey()))
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+		c, err := api.CastCid(kb)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+		op.Unpins = append(op.Unpins, c)
+	}
+	return op
+}
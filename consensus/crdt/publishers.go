@@ -0,0 +1,118 @@
+package crdt
+
+import (
+	"context"
+	"sort"
+
+	ds "github.com/ipfs/go-datastore"
+	crdt "github.com/ipfs/go-ds-crdt"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// With DesignatedPublishers set, only the elected publishers re-broadcast
+// the current heads. The rest of the peers still broadcast the heads that
+// result from their own updates. Publishers are the first trusted peers in
+// the peerset, sorted by peer ID, so every peer agrees on them without
+// exchanging messages, and a publisher is replaced as soon as it leaves
+// the peerset.
+
+// electPublishers returns the first n trusted peers, sorted.
+func electPublishers(peers []peer.ID, trusted func(peer.ID) bool, n int) []peer.ID {
+	sorted := make([]peer.ID, len(peers))
+	copy(sorted, peers)
+	sort.Sort(peer.IDSlice(sorted))
+
+	var publishers []peer.ID
+	for _, p := range sorted {
+		if len(publishers) == n {
+			break
+		}
+		if trusted(p) {
+			publishers = append(publishers, p)
+		}
+	}
+	return publishers
+}
+
+// isPublisher returns true when this peer is one of the designated
+// publishers. When the peerset is not available, it is.
+func (css *Consensus) isPublisher(ctx context.Context) bool {
+	peers, err := css.Peers(ctx)
+	if err != nil {
+		logger.Warnf("cannot elect publishers: %s", err)
+		return true
+	}
+
+	trusted := func(p peer.ID) bool {
+		return css.IsTrustedPeer(ctx, p)
+	}
+	elected := false
+	for _, p := range electPublishers(peers, trusted, css.config.DesignatedPublishers) {
+		if p == css.host.ID() {
+			elected = true
+			break
+		}
+	}
+
+	if css.publisher.Swap(elected) != elected {
+		if elected {
+			logger.Info("this peer is now a designated publisher")
+		} else {
+			logger.Info("this peer is no longer a designated publisher")
+		}
+	}
+	return elected
+}
+
+// publisherBroadcaster drops the broadcasts that do not come from local
+// updates unless this peer is a designated publisher. Those are
+// re-broadcasts of the current heads.
+type publisherBroadcaster struct {
+	crdt.Broadcaster
+	css *Consensus
+}
+
+func (pb publisherBroadcaster) Broadcast(data []byte) error {
+	if pb.css.localWrites.Load() == 0 && !pb.css.isPublisher(pb.css.ctx) {
+		return nil
+	}
+	return pb.Broadcaster.Broadcast(data)
+}
+
+// localWritesStore keeps count of the writes to the CRDT store that are in
+// progress, as they broadcast new heads before returning.
+type localWritesStore struct {
+	ds.Batching
+	css *Consensus
+}
+
+func (lws *localWritesStore) Put(ctx context.Context, k ds.Key, v []byte) error {
+	lws.css.localWrites.Add(1)
+	defer lws.css.localWrites.Add(-1)
+	return lws.Batching.Put(ctx, k, v)
+}
+
+func (lws *localWritesStore) Delete(ctx context.Context, k ds.Key) error {
+	lws.css.localWrites.Add(1)
+	defer lws.css.localWrites.Add(-1)
+	return lws.Batching.Delete(ctx, k)
+}
+
+func (lws *localWritesStore) Batch(ctx context.Context) (ds.Batch, error) {
+	b, err := lws.Batching.Batch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &localWritesBatch{Batch: b, css: lws.css}, nil
+}
+
+type localWritesBatch struct {
+	ds.Batch
+	css *Consensus
+}
+
+func (lwb *localWritesBatch) Commit(ctx context.Context) error {
+	lwb.css.localWrites.Add(1)
+	defer lwb.css.localWrites.Add(-1)
+	return lwb.Batch.Commit(ctx)
+}
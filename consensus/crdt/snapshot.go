@@ -0,0 +1,397 @@
+package crdt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	query "github.com/ipfs/go-datastore/query"
+	ipld "github.com/ipfs/go-ipld-format"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	crypto "github.com/libp2p/go-libp2p/core/crypto"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Namespaces used by go-ds-crdt under the DatastoreNamespace. Snapshots copy
+// the set and the heads. When importing, the heads are also marked as
+// processed so that syncing stops at them.
+const (
+	crdtHeadsNs     = "h"
+	crdtSetNs       = "s"
+	crdtProcessedNs = "b"
+)
+
+// snapshot is a copy of the CRDT set and heads of a peer. A fresh peer
+// which imports it has the same state as the producer and only needs to
+// sync the deltas which came after it.
+type snapshot struct {
+	Peer      peer.ID         `json:"peer"`
+	Timestamp time.Time       `json:"timestamp"`
+	Entries   []snapshotEntry `json:"entries"`
+}
+
+// snapshotEntry is a key-value entry, relative to the DatastoreNamespace.
+type snapshotEntry struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// signedSnapshot is what gets stored and shared: the serialized snapshot
+// and the signature of the producer over it.
+type signedSnapshot struct {
+	Snapshot  []byte `json:"snapshot"`
+	PublicKey []byte `json:"public_key"`
+	Signature []byte `json:"signature"`
+}
+
+func snapshotTopicName(topicName string) string {
+	return topicName + "/snapshots"
+}
+
+// exportSnapshot copies the heads and the set from the datastore. Heads are
+// read first: if deltas are merged meanwhile, they will be processed again
+// by the peers importing the snapshot, which is harmless.
+func (css *Consensus) exportSnapshot(ctx context.Context) (*snapshot, error) {
+	snap := &snapshot{
+		Peer:      css.host.ID(),
+		Timestamp: time.Now(),
+	}
+	for _, ns := range []string{crdtHeadsNs, crdtSetNs} {
+		entries, err := css.queryEntries(ctx, ns, false)
+		if err != nil {
+			return nil, err
+		}
+		snap.Entries = append(snap.Entries, entries...)
+	}
+	return snap, nil
+}
+
+// queryEntries returns the entries in the given namespace, with keys
+// relative to the DatastoreNamespace.
+func (css *Consensus) queryEntries(ctx context.Context, ns string, keysOnly bool) ([]snapshotEntry, error) {
+	prefix := css.namespace.ChildString(ns).String()
+	results, err := css.store.Query(ctx, query.Query{
+		Prefix:   prefix,
+		KeysOnly: keysOnly,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var entries []snapshotEntry
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		entries = append(entries, snapshotEntry{
+			Key:   strings.TrimPrefix(r.Key, css.namespace.String()),
+			Value: r.Value,
+		})
+	}
+	return entries, nil
+}
+
+// importSnapshot writes the snapshot entries in the datastore. It must run
+// before the CRDT datastore is created.
+func (css *Consensus) importSnapshot(ctx context.Context, snap *snapshot) error {
+	var w ds.Write = css.store
+	batching, ok := css.store.(ds.Batching)
+	var b ds.Batch
+	if ok {
+		var err error
+		b, err = batching.Batch(ctx)
+		if err != nil {
+			return err
+		}
+		w = b
+	}
+
+	headsPrefix := "/" + crdtHeadsNs + "/"
+	for _, e := range snap.Entries {
+		k := ds.NewKey(e.Key)
+		if k.String() != e.Key || (!strings.HasPrefix(e.Key, headsPrefix) && !strings.HasPrefix(e.Key, "/"+crdtSetNs+"/")) {
+			return fmt.Errorf("bad snapshot key: %s", e.Key)
+		}
+		err := w.Put(ctx, css.namespace.Child(k), e.Value)
+		if err != nil {
+			return err
+		}
+		// heads and processed blocks are keyed by the same multihash
+		if strings.HasPrefix(e.Key, headsPrefix) {
+			processed := "/" + crdtProcessedNs + "/" + strings.TrimPrefix(e.Key, headsPrefix)
+			err := w.Put(ctx, css.namespace.Child(ds.NewKey(processed)), nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if b != nil {
+		return b.Commit(ctx)
+	}
+	return nil
+}
+
+// signSnapshot serializes the snapshot and signs it with the peer key.
+func (css *Consensus) signSnapshot(snap *snapshot) ([]byte, error) {
+	priv := css.host.Peerstore().PrivKey(css.host.ID())
+	if priv == nil {
+		return nil, errors.New("no private key to sign the snapshot")
+	}
+	pub, err := crypto.MarshalPublicKey(priv.GetPublic())
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := priv.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(signedSnapshot{
+		Snapshot:  data,
+		PublicKey: pub,
+		Signature: sig,
+	})
+}
+
+// verifySnapshot checks that the snapshot was signed by the peer which
+// produced it and that the peer is trusted.
+func (css *Consensus) verifySnapshot(ctx context.Context, data []byte) (*snapshot, error) {
+	var signed signedSnapshot
+	err := json.Unmarshal(data, &signed)
+	if err != nil {
+		return nil, err
+	}
+	var snap snapshot
+	err = json.Unmarshal(signed.Snapshot, &snap)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := crypto.UnmarshalPublicKey(signed.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	pid, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	if pid != snap.Peer {
+		return nil, fmt.Errorf("snapshot from %s is signed by %s", snap.Peer, pid)
+	}
+	ok, err := pub.Verify(signed.Snapshot, signed.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("bad snapshot signature from %s", pid)
+	}
+	if !css.IsTrustedPeer(ctx, pid) {
+		return nil, fmt.Errorf("snapshot from non trusted peer %s", pid)
+	}
+	return &snap, nil
+}
+
+// snapshotWorker produces a snapshot every SnapshotInterval and announces
+// it on the given topic. A new snapshot is only produced when the heads
+// changed. Launched in setup as a goroutine.
+func (css *Consensus) snapshotWorker(topic *pubsub.Topic) {
+	ticker := time.NewTicker(css.config.SnapshotInterval)
+	defer ticker.Stop()
+
+	var current cid.Cid
+	var currentHeads string
+	for {
+		select {
+		case <-css.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		heads, err := css.queryEntries(css.ctx, crdtHeadsNs, true)
+		if err != nil {
+			logger.Errorf("error reading heads for snapshot: %s", err)
+			continue
+		}
+		var hkeys []string
+		for _, h := range heads {
+			hkeys = append(hkeys, h.Key)
+		}
+		headsKey := strings.Join(hkeys, ",")
+
+		if !current.Defined() || headsKey != currentHeads {
+			c, err := css.makeSnapshot(css.ctx)
+			if err != nil {
+				logger.Errorf("error producing snapshot: %s", err)
+				continue
+			}
+			if current.Defined() {
+				css.removeDAG(css.ctx, current, c)
+			}
+			logger.Infof("produced new state snapshot: %s", c)
+			current = c
+			currentHeads = headsKey
+		}
+
+		err = topic.Publish(css.ctx, current.Bytes())
+		if err != nil {
+			logger.Errorf("error announcing snapshot: %s", err)
+		}
+	}
+}
+
+// makeSnapshot exports, signs and stores a snapshot, returning the CID of
+// the DAG holding it.
+func (css *Consensus) makeSnapshot(ctx context.Context) (cid.Cid, error) {
+	snap, err := css.exportSnapshot(ctx)
+	if err != nil {
+		return cid.Undef, err
+	}
+	data, err := css.signSnapshot(snap)
+	if err != nil {
+		return cid.Undef, err
+	}
+	nd, err := css.ipfs.AddFile(ctx, bytes.NewReader(data), nil)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return nd.Cid(), nil
+}
+
+// removeDAG removes a previous snapshot from the blockstore, keeping the
+// blocks shared with the given one.
+func (css *Consensus) removeDAG(ctx context.Context, root, keep cid.Cid) {
+	keepSet := cid.NewSet()
+	if keep.Defined() {
+		for _, c := range css.dagCids(ctx, keep) {
+			keepSet.Add(c)
+		}
+	}
+	var cids []cid.Cid
+	for _, c := range css.dagCids(ctx, root) {
+		if !keepSet.Has(c) {
+			cids = append(cids, c)
+		}
+	}
+	if err := css.ipfs.RemoveMany(ctx, cids); err != nil && !ipld.IsNotFound(err) {
+		logger.Errorf("error removing old snapshot %s: %s", root, err)
+	}
+}
+
+// dagCids returns the CIDs of the blocks in a DAG.
+func (css *Consensus) dagCids(ctx context.Context, root cid.Cid) []cid.Cid {
+	var cids []cid.Cid
+	var walk func(c cid.Cid) error
+	walk = func(c cid.Cid) error {
+		nd, err := css.ipfs.Get(ctx, c)
+		if err != nil {
+			return err
+		}
+		cids = append(cids, c)
+		for _, l := range nd.Links() {
+			if err := walk(l.Cid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root); err != nil {
+		logger.Errorf("error walking snapshot %s: %s", root, err)
+	}
+	return cids
+}
+
+// joinSnapshotTopic joins the topic used to announce snapshots, only
+// accepting messages from trusted peers.
+func (css *Consensus) joinSnapshotTopic(name string) (*pubsub.Topic, error) {
+	err := css.pubsub.RegisterTopicValidator(
+		name,
+		func(ctx context.Context, _ peer.ID, msg *pubsub.Message) bool {
+			return css.IsTrustedPeer(ctx, msg.GetFrom())
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return css.pubsub.Join(name)
+}
+
+// bootstrapFromSnapshot waits up to SnapshotBootstrapTimeout for a snapshot
+// announcement and imports it. It does nothing when the peer already has
+// CRDT heads. On failure, the peer just syncs the full DAG.
+func (css *Consensus) bootstrapFromSnapshot(topic *pubsub.Topic) {
+	heads, err := css.queryEntries(css.ctx, crdtHeadsNs, true)
+	if err != nil {
+		logger.Errorf("error reading heads: %s", err)
+		return
+	}
+	if len(heads) > 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(css.ctx, css.config.SnapshotBootstrapTimeout)
+	defer cancel()
+
+	sub, err := topic.Subscribe()
+	if err != nil {
+		logger.Errorf("error subscribing to snapshots: %s", err)
+		return
+	}
+	defer sub.Cancel()
+
+	logger.Infof("waiting up to %s for a state snapshot", css.config.SnapshotBootstrapTimeout)
+	for {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			logger.Warn("no state snapshot could be imported. Syncing the full DAG")
+			return
+		}
+		if msg.GetFrom() == css.host.ID() {
+			continue
+		}
+		c, err := cid.Cast(msg.GetData())
+		if err != nil {
+			logger.Errorf("bad snapshot announcement from %s: %s", msg.GetFrom(), err)
+			continue
+		}
+		err = css.fetchSnapshot(ctx, c)
+		if err != nil {
+			logger.Errorf("error importing snapshot %s: %s", c, err)
+			continue
+		}
+		logger.Infof("imported state snapshot %s from %s", c, msg.GetFrom())
+		return
+	}
+}
+
+func (css *Consensus) fetchSnapshot(ctx context.Context, c cid.Cid) error {
+	r, err := css.ipfs.GetFile(ctx, c)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	snap, err := css.verifySnapshot(ctx, data)
+	if err != nil {
+		return err
+	}
+	err = css.importSnapshot(ctx, snap)
+	if err != nil {
+		return err
+	}
+	// the snapshot is not needed anymore
+	css.removeDAG(ctx, c, cid.Undef)
+	return nil
+}
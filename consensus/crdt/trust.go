@@ -0,0 +1,157 @@
+package crdt
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	query "github.com/ipfs/go-datastore/query"
+	crdt "github.com/ipfs/go-ds-crdt"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+
+	trace "go.opencensus.io/trace"
+)
+
+// Trust changes made at runtime are recorded in a separate CRDT store under
+// the DatastoreNamespace, with its own topic, so that they do not mix with
+// the pinset. Keys are peer IDs and values say whether the peer is trusted.
+// Distrusting is a value too, and not a deletion, so that it also applies
+// to peers trusted in the configuration.
+const trustNs = "trust"
+
+var (
+	trustedValue    = []byte("trusted")
+	distrustedValue = []byte("distrusted")
+)
+
+func trustTopicName(topic string) string {
+	return topic + "/trust"
+}
+
+// setupTrust creates the CRDT store for trust changes and applies the
+// changes already recorded in it, which take precedence over the
+// configuration.
+func (css *Consensus) setupTrust(topicName string) error {
+	err := css.pubsub.RegisterTopicValidator(
+		topicName,
+		func(ctx context.Context, _ peer.ID, msg *pubsub.Message) bool {
+			return css.IsTrustedPeer(ctx, msg.GetFrom())
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	psBroadcaster, err := crdt.NewPubSubBroadcaster(css.ctx, css.pubsub, topicName)
+	if err != nil {
+		return err
+	}
+	var broadcaster crdt.Broadcaster = psBroadcaster
+	if css.config.ReadOnly {
+		broadcaster = readOnlyBroadcaster{psBroadcaster}
+	}
+
+	opts := crdt.DefaultOptions()
+	opts.RebroadcastInterval = css.config.RebroadcastInterval
+	opts.DAGSyncerTimeout = 2 * time.Minute
+	opts.Logger = logger
+	opts.RepairInterval = css.config.RepairInterval
+	opts.PutHook = func(k ds.Key, v []byte) {
+		css.applyTrust(k, v)
+	}
+
+	store, err := crdt.New(
+		css.store,
+		css.namespace.ChildString(trustNs),
+		css.ipfs,
+		broadcaster,
+		opts,
+	)
+	if err != nil {
+		return err
+	}
+	css.trustCrdt = store
+
+	results, err := store.Query(css.ctx, query.Query{})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		css.applyTrust(ds.NewKey(r.Key), r.Value)
+	}
+	return nil
+}
+
+// applyTrust trusts or distrusts the peer in a trust store entry.
+func (css *Consensus) applyTrust(k ds.Key, v []byte) {
+	pid, err := peer.Decode(k.BaseNamespace())
+	if err != nil {
+		logger.Errorf("bad peer ID in trust store: %s", err)
+		return
+	}
+	if bytes.Equal(v, trustedValue) {
+		logger.Infof("trusting peer %s", pid)
+		css.Trust(css.ctx, pid)
+		return
+	}
+	logger.Infof("distrusting peer %s", pid)
+	css.Distrust(css.ctx, pid)
+}
+
+// LogTrust records that the given peer is trusted, so that every peer in
+// the cluster starts trusting it.
+func (css *Consensus) LogTrust(ctx context.Context, pid peer.ID) error {
+	ctx, span := trace.StartSpan(ctx, "consensus/LogTrust")
+	defer span.End()
+
+	return css.logTrust(ctx, pid, trustedValue)
+}
+
+// LogDistrust records that the given peer is not trusted, so that every peer
+// in the cluster stops trusting it, even if it is part of their configured
+// trusted peers.
+func (css *Consensus) LogDistrust(ctx context.Context, pid peer.ID) error {
+	ctx, span := trace.StartSpan(ctx, "consensus/LogDistrust")
+	defer span.End()
+
+	return css.logTrust(ctx, pid, distrustedValue)
+}
+
+func (css *Consensus) logTrust(ctx context.Context, pid peer.ID, v []byte) error {
+	if css.config.ReadOnly {
+		return ErrReadOnly
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-css.ctx.Done():
+		return css.ctx.Err()
+	case <-css.stateReady:
+	}
+	return css.trustCrdt.Put(ctx, ds.NewKey(pid.String()), v)
+}
+
+// TrustedPeers returns the peers currently trusted, sorted. When TrustAll
+// is set, any other peer is trusted too.
+func (css *Consensus) TrustedPeers(ctx context.Context) ([]peer.ID, error) {
+	_, span := trace.StartSpan(ctx, "consensus/TrustedPeers")
+	defer span.End()
+
+	var peers []peer.ID
+	css.trustedPeers.Range(func(k, v interface{}) bool {
+		peers = append(peers, k.(peer.ID))
+		return true
+	})
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i] < peers[j]
+	})
+	return peers, nil
+}
@@ -0,0 +1,163 @@
+package external
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/config"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+var configKey = "external"
+var envConfigKey = "cluster_external"
+
+// Default configuration values
+var (
+	DefaultEndpoint     = "127.0.0.1:9098"
+	DefaultDialTimeout  = 10 * time.Second
+	DefaultTrustedPeers = []peer.ID{}
+	DefaultTrustAll     = true
+)
+
+// Config is the configuration object for Consensus.
+type Config struct {
+	config.Saver
+
+	// Endpoint is the gRPC target of the consensus backend, like
+	// "127.0.0.1:9098" or "unix:///path/to/socket".
+	Endpoint string
+
+	// How long to wait for the backend to become reachable when starting.
+	DialTimeout time.Duration
+
+	// TrustAll specifies whether we should trust all peers regardless of
+	// the TrustedPeers contents.
+	TrustAll bool
+
+	// Trusted peers can access additional RPC endpoints for this peer
+	// that are forbidden for other peers.
+	TrustedPeers []peer.ID
+
+	// Tracing enables propagation of contexts across binary boundaries.
+	Tracing bool
+}
+
+type jsonConfig struct {
+	Endpoint     string   `json:"endpoint"`
+	DialTimeout  string   `json:"dial_timeout"`
+	TrustedPeers []string `json:"trusted_peers"`
+}
+
+// ConfigKey returns the section name for this type of configuration.
+func (cfg *Config) ConfigKey() string {
+	return configKey
+}
+
+// Validate returns an error if the configuration has invalid values.
+func (cfg *Config) Validate() error {
+	if cfg.Endpoint == "" {
+		return errors.New("external.endpoint cannot be empty")
+	}
+
+	if cfg.DialTimeout <= 0 {
+		return errors.New("external.dial_timeout is invalid")
+	}
+	return nil
+}
+
+// LoadJSON takes a raw JSON slice and sets all the configuration fields.
+func (cfg *Config) LoadJSON(raw []byte) error {
+	jcfg := &jsonConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		return fmt.Errorf("error unmarshaling %s config", configKey)
+	}
+
+	cfg.Default()
+
+	return cfg.applyJSONConfig(jcfg)
+}
+
+func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
+	config.SetIfNotDefault(jcfg.Endpoint, &cfg.Endpoint)
+
+	// Whenever we parse JSON, TrustAll is false unless an '*' peer exists
+	cfg.TrustAll = false
+	cfg.TrustedPeers = []peer.ID{}
+
+	for _, p := range jcfg.TrustedPeers {
+		if p == "*" {
+			cfg.TrustAll = true
+			cfg.TrustedPeers = []peer.ID{}
+			break
+		}
+		pid, err := peer.Decode(p)
+		if err != nil {
+			return fmt.Errorf("error parsing trusted peers: %s", err)
+		}
+		cfg.TrustedPeers = append(cfg.TrustedPeers, pid)
+	}
+
+	err := config.ParseDurations(
+		"external",
+		&config.DurationOpt{Duration: jcfg.DialTimeout, Dst: &cfg.DialTimeout, Name: "dial_timeout"},
+	)
+	if err != nil {
+		return err
+	}
+	return cfg.Validate()
+}
+
+// ToJSON returns the JSON representation of this configuration.
+func (cfg *Config) ToJSON() ([]byte, error) {
+	jcfg := cfg.toJSONConfig()
+
+	return config.DefaultJSONMarshal(jcfg)
+}
+
+func (cfg *Config) toJSONConfig() *jsonConfig {
+	jcfg := &jsonConfig{
+		Endpoint:    cfg.Endpoint,
+		DialTimeout: cfg.DialTimeout.String(),
+	}
+
+	if cfg.TrustAll {
+		jcfg.TrustedPeers = []string{"*"}
+	} else {
+		jcfg.TrustedPeers = api.PeersToStrings(cfg.TrustedPeers)
+	}
+	return jcfg
+}
+
+// Default sets the configuration fields to their default values.
+func (cfg *Config) Default() error {
+	cfg.Endpoint = DefaultEndpoint
+	cfg.DialTimeout = DefaultDialTimeout
+	cfg.TrustedPeers = DefaultTrustedPeers
+	cfg.TrustAll = DefaultTrustAll
+	return nil
+}
+
+// ApplyEnvVars fills in any Config fields found
+// as environment variables.
+func (cfg *Config) ApplyEnvVars() error {
+	jcfg := cfg.toJSONConfig()
+
+	err := envconfig.Process(envConfigKey, jcfg)
+	if err != nil {
+		return err
+	}
+
+	return cfg.applyJSONConfig(jcfg)
+}
+
+// ToDisplayJSON returns JSON config as a string.
+func (cfg *Config) ToDisplayJSON() ([]byte, error) {
+	return config.DisplayJSON(cfg.toJSONConfig())
+}
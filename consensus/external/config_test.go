@@ -0,0 +1,102 @@
+package external
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+var cfgJSON = []byte(`
+{
+    "endpoint": "unix:///tmp/consensus.sock",
+    "dial_timeout": "5s",
+    "trusted_peers": ["QmUZ13osndQ5uL4tPWHXe3iBgBgq9gfewcBMSCAuMBsDJ6"]
+}
+`)
+
+func TestLoadJSON(t *testing.T) {
+	cfg := &Config{}
+	err := cfg.LoadJSON(cfgJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Endpoint != "unix:///tmp/consensus.sock" {
+		t.Error("endpoint not set")
+	}
+	if cfg.DialTimeout != 5*time.Second {
+		t.Error("dial timeout not set")
+	}
+	if cfg.TrustAll || len(cfg.TrustedPeers) != 1 {
+		t.Error("TrustAll should not be enabled when peers in trusted peers")
+	}
+
+	cfg = &Config{}
+	err = cfg.LoadJSON([]byte(`
+{
+    "trusted_peers": ["abc"]
+}`))
+	if err == nil {
+		t.Fatal("expected error parsing trusted_peers")
+	}
+
+	cfg = &Config{}
+	err = cfg.LoadJSON([]byte(`
+{
+    "trusted_peers": ["QmUZ13osndQ5uL4tPWHXe3iBgBgq9gfewcBMSCAuMBsDJ6", "*"]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.TrustAll {
+		t.Error("expected TrustAll to be true")
+	}
+	if cfg.Endpoint != DefaultEndpoint {
+		t.Error("expected default endpoint when unset")
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	cfg := &Config{}
+	cfg.LoadJSON(cfgJSON)
+	newjson, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = &Config{}
+	err = cfg.LoadJSON(newjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	if cfg.Validate() != nil {
+		t.Fatal("error validating")
+	}
+
+	cfg.Endpoint = ""
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.DialTimeout = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+}
+
+func TestApplyEnvVars(t *testing.T) {
+	os.Setenv("CLUSTER_EXTERNAL_ENDPOINT", "127.0.0.1:10000")
+
+	cfg := &Config{}
+	cfg.Default()
+	cfg.ApplyEnvVars()
+
+	if cfg.Endpoint != "127.0.0.1:10000" {
+		t.Error("failed to override endpoint with env var")
+	}
+}
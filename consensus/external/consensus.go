@@ -0,0 +1,477 @@
+// Package external implements the IPFS Cluster consensus interface by
+// delegating to a consensus backend which runs in a separate process and
+// offers the gRPC service described in pb/consensus.proto. This allows
+// experimenting with alternative consensus implementations without
+// modifying Cluster.
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/consensus/external/pb"
+	"github.com/ipfs-cluster/ipfs-cluster/state"
+
+	logging "github.com/ipfs/go-log/v2"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+
+	"go.opencensus.io/plugin/ocgrpc"
+	trace "go.opencensus.io/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+var logger = logging.Logger("external")
+
+// ErrNoLeader is returned by Leader when the backend has no leader.
+var ErrNoLeader = fmt.Errorf("external %w", api.ErrLeaderless)
+
+// ErrLogTrust is returned by LogTrust and LogDistrust, as trusted peers are
+// managed locally.
+var ErrLogTrust = errors.New("external consensus cannot record trust changes. Use trusted_peers")
+
+// ErrEncryptionUnsupported is returned by EncryptionKey, as encrypting the
+// shared state is up to the backend.
+var ErrEncryptionUnsupported = errors.New("external consensus does not encrypt the state")
+
+// ErrOperationLogUnsupported is returned by OperationLog, as the backend
+// does not expose its log.
+var ErrOperationLogUnsupported = errors.New("external consensus does not expose its operation log")
+
+// Consensus implements ipfscluster.Consensus by calling an external
+// consensus backend over gRPC. The backend is responsible for replicating
+// the shared state and managing the peerset. Trusted peers are managed
+// locally, as they are checked for every RPC request.
+type Consensus struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	config *Config
+	id     peer.ID
+
+	conn   *grpc.ClientConn
+	client pb.ConsensusClient
+
+	trustedPeers sync.Map
+
+	rpcClient *rpc.Client
+	readyCh   chan struct{}
+
+	shutdownLock sync.RWMutex
+	shutdown     bool
+}
+
+// New creates a new external Consensus component for the peer with the
+// given ID, which connects to the backend in cfg.Endpoint. The component is
+// ready once the backend can be reached and is in sync.
+func New(cfg *Config, id peer.ID) (*Consensus, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+	if cfg.Tracing {
+		opts = append(opts, grpc.WithStatsHandler(&ocgrpc.ClientHandler{}))
+	}
+	conn, err := grpc.Dial(cfg.Endpoint, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	css := &Consensus{
+		ctx:     ctx,
+		cancel:  cancel,
+		config:  cfg,
+		id:      id,
+		conn:    conn,
+		client:  pb.NewConsensusClient(conn),
+		readyCh: make(chan struct{}, 1),
+	}
+
+	for _, p := range cfg.TrustedPeers {
+		css.Trust(ctx, p)
+	}
+
+	go css.setup()
+	return css, nil
+}
+
+func (css *Consensus) setup() {
+	ctx, cancel := context.WithTimeout(css.ctx, css.config.DialTimeout)
+	defer cancel()
+
+	_, err := css.client.WaitForSync(ctx, &pb.Empty{}, grpc.WaitForReady(true))
+	if err != nil {
+		logger.Errorf("error reaching the consensus backend at %s: %s", css.config.Endpoint, err)
+		return
+	}
+	logger.Infof("connected to the consensus backend at %s", css.config.Endpoint)
+	css.readyCh <- struct{}{}
+}
+
+// Shutdown closes the connection to the backend.
+func (css *Consensus) Shutdown(ctx context.Context) error {
+	css.shutdownLock.Lock()
+	defer css.shutdownLock.Unlock()
+
+	if css.shutdown {
+		logger.Debug("already shutdown")
+		return nil
+	}
+
+	logger.Info("stopping Consensus component")
+	css.cancel()
+	css.shutdown = true
+	return css.conn.Close()
+}
+
+// SetClient gives the component the ability to communicate with other
+// Cluster components.
+func (css *Consensus) SetClient(c *rpc.Client) {
+	css.rpcClient = c
+}
+
+// Ready returns a channel which is signaled when the component
+// is ready to use.
+func (css *Consensus) Ready(ctx context.Context) <-chan struct{} {
+	return css.readyCh
+}
+
+// IsTrustedPeer returns whether the given peer is trusted.
+func (css *Consensus) IsTrustedPeer(ctx context.Context, pid peer.ID) bool {
+	_, span := trace.StartSpan(ctx, "consensus/IsTrustedPeer")
+	defer span.End()
+
+	if css.config.TrustAll {
+		return true
+	}
+
+	if pid == css.id {
+		return true
+	}
+
+	_, ok := css.trustedPeers.Load(pid)
+	return ok
+}
+
+// Trust marks a peer as "trusted".
+func (css *Consensus) Trust(ctx context.Context, pid peer.ID) error {
+	_, span := trace.StartSpan(ctx, "consensus/Trust")
+	defer span.End()
+
+	css.trustedPeers.Store(pid, struct{}{})
+	return nil
+}
+
+// Distrust removes a peer from the "trusted" set.
+func (css *Consensus) Distrust(ctx context.Context, pid peer.ID) error {
+	_, span := trace.StartSpan(ctx, "consensus/Distrust")
+	defer span.End()
+
+	css.trustedPeers.Delete(pid)
+	return nil
+}
+
+// LogTrust returns ErrLogTrust.
+func (css *Consensus) LogTrust(ctx context.Context, pid peer.ID) error {
+	return ErrLogTrust
+}
+
+// LogDistrust returns ErrLogTrust.
+func (css *Consensus) LogDistrust(ctx context.Context, pid peer.ID) error {
+	return ErrLogTrust
+}
+
+// TrustedPeers returns the peers trusted locally, sorted.
+func (css *Consensus) TrustedPeers(ctx context.Context) ([]peer.ID, error) {
+	var peers []peer.ID
+	css.trustedPeers.Range(func(k, v interface{}) bool {
+		peers = append(peers, k.(peer.ID))
+		return true
+	})
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i] < peers[j]
+	})
+	return peers, nil
+}
+
+// OperationLog returns ErrOperationLogUnsupported.
+func (css *Consensus) OperationLog(ctx context.Context, opts api.OpLogOptions) ([]api.ConsensusOp, error) {
+	return nil, ErrOperationLogUnsupported
+}
+
+// EncryptionKey returns ErrEncryptionUnsupported.
+func (css *Consensus) EncryptionKey(ctx context.Context, id string) ([]byte, error) {
+	return nil, ErrEncryptionUnsupported
+}
+
+// LogPin adds a new pin to the shared state.
+func (css *Consensus) LogPin(ctx context.Context, pin api.Pin) error {
+	ctx, span := trace.StartSpan(ctx, "consensus/LogPin")
+	defer span.End()
+
+	data, err := pin.ProtoMarshal()
+	if err != nil {
+		return err
+	}
+	_, err = css.client.LogPin(ctx, &pb.Pin{Pin: data})
+	return fromStatus(err)
+}
+
+// LogUnpin removes a pin from the shared state.
+func (css *Consensus) LogUnpin(ctx context.Context, pin api.Pin) error {
+	ctx, span := trace.StartSpan(ctx, "consensus/LogUnpin")
+	defer span.End()
+
+	data, err := pin.ProtoMarshal()
+	if err != nil {
+		return err
+	}
+	_, err = css.client.LogUnpin(ctx, &pb.Pin{Pin: data})
+	return fromStatus(err)
+}
+
+// LogBatch adds and removes the given pins from the shared state, as a
+// single operation when the backend supports it.
+func (css *Consensus) LogBatch(ctx context.Context, pins []api.Pin, unpins []api.Pin) error {
+	ctx, span := trace.StartSpan(ctx, "consensus/LogBatch")
+	defer span.End()
+
+	batch := &pb.Batch{}
+	for _, p := range pins {
+		data, err := p.ProtoMarshal()
+		if err != nil {
+			return err
+		}
+		batch.Pins = append(batch.Pins, data)
+	}
+	for _, p := range unpins {
+		data, err := p.ProtoMarshal()
+		if err != nil {
+			return err
+		}
+		batch.Unpins = append(batch.Unpins, data)
+	}
+	_, err := css.client.LogBatch(ctx, batch)
+	return fromStatus(err)
+}
+
+// Peers returns the peerset managed by the backend.
+func (css *Consensus) Peers(ctx context.Context) ([]peer.ID, error) {
+	ctx, span := trace.StartSpan(ctx, "consensus/Peers")
+	defer span.End()
+
+	ps, err := css.client.Peers(ctx, &pb.Empty{})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	peers := make([]peer.ID, 0, len(ps.GetPeers()))
+	for _, p := range ps.GetPeers() {
+		pid, err := peer.IDFromBytes(p)
+		if err != nil {
+			return nil, err
+		}
+		peers = append(peers, pid)
+	}
+	return peers, nil
+}
+
+// WaitForSync returns when the backend has applied all the operations
+// logged so far to the shared state.
+func (css *Consensus) WaitForSync(ctx context.Context) error {
+	ctx, span := trace.StartSpan(ctx, "consensus/WaitForSync")
+	defer span.End()
+
+	_, err := css.client.WaitForSync(ctx, &pb.Empty{})
+	return fromStatus(err)
+}
+
+// AddPeer asks the backend to add a peer to the peerset.
+func (css *Consensus) AddPeer(ctx context.Context, pid peer.ID) error {
+	ctx, span := trace.StartSpan(ctx, "consensus/AddPeer")
+	defer span.End()
+
+	_, err := css.client.AddPeer(ctx, &pb.Peer{ID: []byte(pid)})
+	return fromStatus(err)
+}
+
+// RmPeer asks the backend to remove a peer from the peerset.
+func (css *Consensus) RmPeer(ctx context.Context, pid peer.ID) error {
+	ctx, span := trace.StartSpan(ctx, "consensus/RmPeer")
+	defer span.End()
+
+	_, err := css.client.RmPeer(ctx, &pb.Peer{ID: []byte(pid)})
+	return fromStatus(err)
+}
+
+// State returns the cluster shared state. Reads are served by the
+// backend.
+func (css *Consensus) State(ctx context.Context) (state.ReadOnly, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-css.ctx.Done():
+		return nil, css.ctx.Err()
+	default:
+		return &remoteState{client: css.client}, nil
+	}
+}
+
+// Clean asks the backend to remove all the consensus data.
+func (css *Consensus) Clean(ctx context.Context) error {
+	_, err := css.client.Clean(ctx, &pb.Empty{})
+	return fromStatus(err)
+}
+
+// Leader returns the peer chosen by the backend to perform the tasks which
+// must run in a single peer, or ErrNoLeader.
+func (css *Consensus) Leader(ctx context.Context) (peer.ID, error) {
+	ctx, span := trace.StartSpan(ctx, "consensus/Leader")
+	defer span.End()
+
+	p, err := css.client.Leader(ctx, &pb.Empty{})
+	if status.Code(err) == codes.NotFound {
+		return "", ErrNoLeader
+	}
+	if err != nil {
+		return "", fromStatus(err)
+	}
+	return peer.IDFromBytes(p.GetID())
+}
+
+// remoteState implements state.ReadOnly with the state served by the
+// backend.
+type remoteState struct {
+	client pb.ConsensusClient
+}
+
+// List sends all the pins in the state on the channel and closes it.
+func (st *remoteState) List(ctx context.Context, out chan<- api.Pin) error {
+	defer close(out)
+
+	stream, err := st.client.List(ctx, &pb.Empty{})
+	if err != nil {
+		return fromStatus(err)
+	}
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fromStatus(err)
+		}
+		var pin api.Pin
+		if err := pin.ProtoUnmarshal(msg.GetPin()); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case out <- pin:
+		}
+	}
+}
+
+// ListAfter sends the pins which the service lists after the given one on
+// the channel and closes it. The service is expected to list pins in a
+// stable order. All the pins are sent when the given cid is undefined.
+func (st *remoteState) ListAfter(ctx context.Context, after api.Cid, out chan<- api.Pin) error {
+	defer close(out)
+
+	pins := make(chan api.Pin, 1024)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- st.List(ctx, pins)
+	}()
+	found := !after.Defined()
+	for p := range pins {
+		if !found {
+			found = p.Cid.Equals(after)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			// drain
+			for range pins {
+			}
+			return ctx.Err()
+		case out <- p:
+		}
+	}
+	return <-errCh
+}
+
+// Has returns true if the state holds a pin for the given CID.
+func (st *remoteState) Has(ctx context.Context, c api.Cid) (bool, error) {
+	_, err := st.Get(ctx, c)
+	if err == state.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Get returns the pin for the given CID, or state.ErrNotFound.
+func (st *remoteState) Get(ctx context.Context, c api.Cid) (api.Pin, error) {
+	msg, err := st.client.Get(ctx, &pb.Cid{Cid: c.Bytes()})
+	if err != nil {
+		return api.Pin{}, fromStatus(err)
+	}
+	var pin api.Pin
+	err = pin.ProtoUnmarshal(msg.GetPin())
+	return pin, err
+}
+
+// Search sends the pins matching the given search on the channel and
+// closes it. The pins are filtered from the full list.
+func (st *remoteState) Search(ctx context.Context, search api.PinSearch, out chan<- api.Pin) error {
+	defer close(out)
+
+	pins := make(chan api.Pin, 1024)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- st.List(ctx, pins)
+	}()
+	for p := range pins {
+		if !search.Match(p) {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			// drain
+			for range pins {
+			}
+			return ctx.Err()
+		case out <- p:
+		}
+	}
+	return <-errCh
+}
+
+// fromStatus converts the errors returned by the backend. NOT_FOUND errors
+// become state.ErrNotFound, as that is what Cluster expects.
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	if s.Code() == codes.NotFound {
+		return state.ErrNotFound
+	}
+	return errors.New(s.Message())
+}
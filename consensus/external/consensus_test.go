@@ -0,0 +1,281 @@
+package external
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/datastore/inmem"
+	"github.com/ipfs-cluster/ipfs-cluster/state"
+	"github.com/ipfs-cluster/ipfs-cluster/state/dsstate"
+	"github.com/ipfs-cluster/ipfs-cluster/test"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+
+	"google.golang.org/grpc"
+)
+
+// memBackend is a Backend which keeps everything in memory.
+type memBackend struct {
+	st state.State
+
+	mu    sync.Mutex
+	peers []peer.ID
+}
+
+func (b *memBackend) LogPin(ctx context.Context, pin api.Pin) error {
+	return b.st.Add(ctx, pin)
+}
+
+func (b *memBackend) LogUnpin(ctx context.Context, pin api.Pin) error {
+	return b.st.Rm(ctx, pin.Cid)
+}
+
+func (b *memBackend) LogBatch(ctx context.Context, pins []api.Pin, unpins []api.Pin) error {
+	for _, p := range pins {
+		if err := b.st.Add(ctx, p); err != nil {
+			return err
+		}
+	}
+	for _, p := range unpins {
+		if err := b.st.Rm(ctx, p.Cid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *memBackend) State(ctx context.Context) (state.ReadOnly, error) {
+	return b.st, nil
+}
+
+func (b *memBackend) WaitForSync(ctx context.Context) error {
+	return nil
+}
+
+func (b *memBackend) AddPeer(ctx context.Context, pid peer.ID) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.peers = append(b.peers, pid)
+	return nil
+}
+
+func (b *memBackend) RmPeer(ctx context.Context, pid peer.ID) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, p := range b.peers {
+		if p == pid {
+			b.peers = append(b.peers[:i], b.peers[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (b *memBackend) Peers(ctx context.Context) ([]peer.ID, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]peer.ID{}, b.peers...), nil
+}
+
+func (b *memBackend) Leader(ctx context.Context) (peer.ID, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.peers) == 0 {
+		return "", api.ErrLeaderless
+	}
+	return b.peers[0], nil
+}
+
+func (b *memBackend) Clean(ctx context.Context) error {
+	return nil
+}
+
+func testingConsensus(t *testing.T) *Consensus {
+	t.Helper()
+	ctx := context.Background()
+
+	st, err := dsstate.New(ctx, inmem.New(), "", dsstate.DefaultHandle())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	RegisterBackend(srv, &memBackend{st: st})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	cfg := &Config{}
+	cfg.Default()
+	cfg.Endpoint = lis.Addr().String()
+	cfg.TrustAll = false
+	cc, err := New(cfg, test.PeerID1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Shutdown(ctx) })
+
+	select {
+	case <-cc.Ready(ctx):
+	case <-time.After(5 * time.Second):
+		t.Fatal("consensus should be ready")
+	}
+	return cc
+}
+
+func TestConsensusPin(t *testing.T) {
+	ctx := context.Background()
+	cc := testingConsensus(t)
+
+	pin := api.PinWithOpts(test.Cid1, api.PinOptions{
+		Name:     "external",
+		Metadata: map[string]string{"a": "b"},
+	})
+	err := cc.LogPin(ctx, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := cc.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := st.Get(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Equals(pin) {
+		t.Errorf("unexpected pin: %+v", p)
+	}
+
+	_, err = st.Get(ctx, test.Cid2)
+	if err != state.ErrNotFound {
+		t.Errorf("expected state.ErrNotFound: %v", err)
+	}
+	has, err := st.Has(ctx, test.Cid2)
+	if err != nil || has {
+		t.Error("Cid2 should not be in the state")
+	}
+
+	err = cc.LogBatch(ctx, []api.Pin{api.PinCid(test.Cid2), api.PinCid(test.Cid3)}, []api.Pin{pin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := make(chan api.Pin, 10)
+	err = st.List(ctx, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cids []string
+	for p := range out {
+		cids = append(cids, p.Cid.String())
+	}
+	sort.Strings(cids)
+	expected := []string{test.Cid2.String(), test.Cid3.String()}
+	sort.Strings(expected)
+	if len(cids) != 2 || cids[0] != expected[0] || cids[1] != expected[1] {
+		t.Errorf("unexpected pins: %s", cids)
+	}
+
+	err = cc.LogPin(ctx, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = make(chan api.Pin, 10)
+	err = st.Search(ctx, api.PinSearch{Metadata: map[string]string{"a": ""}}, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []api.Pin
+	for p := range out {
+		found = append(found, p)
+	}
+	if len(found) != 1 || !found[0].Cid.Equals(test.Cid1) {
+		t.Errorf("unexpected search results: %v", found)
+	}
+
+	err = cc.LogUnpin(ctx, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	has, err = st.Has(ctx, test.Cid1)
+	if err != nil || has {
+		t.Error("Cid1 should have been unpinned")
+	}
+}
+
+func TestConsensusPeers(t *testing.T) {
+	ctx := context.Background()
+	cc := testingConsensus(t)
+
+	_, err := cc.Leader(ctx)
+	if err != ErrNoLeader {
+		t.Errorf("expected ErrNoLeader: %v", err)
+	}
+
+	for _, p := range []peer.ID{test.PeerID1, test.PeerID2, test.PeerID3} {
+		if err := cc.AddPeer(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cc.RmPeer(ctx, test.PeerID2); err != nil {
+		t.Fatal(err)
+	}
+
+	peers, err := cc.Peers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 2 || peers[0] != test.PeerID1 || peers[1] != test.PeerID3 {
+		t.Errorf("unexpected peers: %s", peers)
+	}
+
+	leader, err := cc.Leader(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leader != test.PeerID1 {
+		t.Errorf("unexpected leader: %s", leader)
+	}
+}
+
+func TestConsensusTrust(t *testing.T) {
+	ctx := context.Background()
+	cc := testingConsensus(t)
+
+	if !cc.IsTrustedPeer(ctx, test.PeerID1) {
+		t.Error("the peer itself should be trusted")
+	}
+	if cc.IsTrustedPeer(ctx, test.PeerID2) {
+		t.Error("the peer should not be trusted")
+	}
+	cc.Trust(ctx, test.PeerID2)
+	if !cc.IsTrustedPeer(ctx, test.PeerID2) {
+		t.Error("the peer should be trusted")
+	}
+	cc.Distrust(ctx, test.PeerID2)
+	if cc.IsTrustedPeer(ctx, test.PeerID2) {
+		t.Error("the peer should not be trusted anymore")
+	}
+
+	if cc.LogTrust(ctx, test.PeerID2) != ErrLogTrust {
+		t.Error("expected ErrLogTrust")
+	}
+	cc.Trust(ctx, test.PeerID3)
+	peers, err := cc.TrustedPeers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 1 || peers[0] != test.PeerID3 {
+		t.Errorf("unexpected trusted peers: %s", peers)
+	}
+}
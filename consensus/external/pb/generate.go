@@ -0,0 +1,4 @@
+// Package pb provides the protobuf definitions of the gRPC service offered by
+// external consensus backends.
+//go:generate protoc -I=. --go_out=. --go-grpc_out=. consensus.proto
+package pb
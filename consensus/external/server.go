@@ -0,0 +1,185 @@
+package external
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/consensus/external/pb"
+	"github.com/ipfs-cluster/ipfs-cluster/state"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Backend is the part of the Consensus component which external backends
+// provide. Every ipfscluster.Consensus implementation satisfies it. Go
+// backends can be served with RegisterBackend, while backends in other
+// languages implement pb/consensus.proto directly.
+type Backend interface {
+	LogPin(context.Context, api.Pin) error
+	LogUnpin(context.Context, api.Pin) error
+	LogBatch(ctx context.Context, pins []api.Pin, unpins []api.Pin) error
+	State(context.Context) (state.ReadOnly, error)
+	WaitForSync(context.Context) error
+	AddPeer(context.Context, peer.ID) error
+	RmPeer(context.Context, peer.ID) error
+	Peers(context.Context) ([]peer.ID, error)
+	Leader(context.Context) (peer.ID, error)
+	Clean(context.Context) error
+}
+
+// RegisterBackend registers the Consensus gRPC service on the given server,
+// served by the given backend.
+func RegisterBackend(s grpc.ServiceRegistrar, b Backend) {
+	pb.RegisterConsensusServer(s, &server{backend: b})
+}
+
+type server struct {
+	pb.UnimplementedConsensusServer
+
+	backend Backend
+}
+
+func (srv *server) LogPin(ctx context.Context, in *pb.Pin) (*pb.Empty, error) {
+	var pin api.Pin
+	if err := pin.ProtoUnmarshal(in.GetPin()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &pb.Empty{}, toStatus(srv.backend.LogPin(ctx, pin))
+}
+
+func (srv *server) LogUnpin(ctx context.Context, in *pb.Pin) (*pb.Empty, error) {
+	var pin api.Pin
+	if err := pin.ProtoUnmarshal(in.GetPin()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &pb.Empty{}, toStatus(srv.backend.LogUnpin(ctx, pin))
+}
+
+func (srv *server) LogBatch(ctx context.Context, in *pb.Batch) (*pb.Empty, error) {
+	unmarshal := func(data [][]byte) ([]api.Pin, error) {
+		pins := make([]api.Pin, len(data))
+		for i, d := range data {
+			if err := pins[i].ProtoUnmarshal(d); err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+		}
+		return pins, nil
+	}
+	pins, err := unmarshal(in.GetPins())
+	if err != nil {
+		return nil, err
+	}
+	unpins, err := unmarshal(in.GetUnpins())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Empty{}, toStatus(srv.backend.LogBatch(ctx, pins, unpins))
+}
+
+func (srv *server) List(in *pb.Empty, stream pb.Consensus_ListServer) error {
+	ctx := stream.Context()
+	st, err := srv.backend.State(ctx)
+	if err != nil {
+		return toStatus(err)
+	}
+
+	pins := make(chan api.Pin, 1024)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- st.List(ctx, pins)
+	}()
+	for p := range pins {
+		data, err := p.ProtoMarshal()
+		if err == nil {
+			err = stream.Send(&pb.Pin{Pin: data})
+		}
+		if err != nil {
+			// drain
+			for range pins {
+			}
+			return err
+		}
+	}
+	return toStatus(<-errCh)
+}
+
+func (srv *server) Get(ctx context.Context, in *pb.Cid) (*pb.Pin, error) {
+	c, err := api.CastCid(in.GetCid())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	st, err := srv.backend.State(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	pin, err := st.Get(ctx, c)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	data, err := pin.ProtoMarshal()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Pin{Pin: data}, nil
+}
+
+func (srv *server) WaitForSync(ctx context.Context, in *pb.Empty) (*pb.Empty, error) {
+	return &pb.Empty{}, toStatus(srv.backend.WaitForSync(ctx))
+}
+
+func (srv *server) AddPeer(ctx context.Context, in *pb.Peer) (*pb.Empty, error) {
+	pid, err := peer.IDFromBytes(in.GetID())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &pb.Empty{}, toStatus(srv.backend.AddPeer(ctx, pid))
+}
+
+func (srv *server) RmPeer(ctx context.Context, in *pb.Peer) (*pb.Empty, error) {
+	pid, err := peer.IDFromBytes(in.GetID())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &pb.Empty{}, toStatus(srv.backend.RmPeer(ctx, pid))
+}
+
+func (srv *server) Peers(ctx context.Context, in *pb.Empty) (*pb.PeerSet, error) {
+	peers, err := srv.backend.Peers(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	ps := &pb.PeerSet{}
+	for _, p := range peers {
+		ps.Peers = append(ps.Peers, []byte(p))
+	}
+	return ps, nil
+}
+
+func (srv *server) Leader(ctx context.Context, in *pb.Empty) (*pb.Peer, error) {
+	pid, err := srv.backend.Leader(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.Peer{ID: []byte(pid)}, nil
+}
+
+func (srv *server) Clean(ctx context.Context, in *pb.Empty) (*pb.Empty, error) {
+	return &pb.Empty{}, toStatus(srv.backend.Clean(ctx))
+}
+
+// toStatus converts the errors of the backend into gRPC errors, with a
+// NOT_FOUND code for missing pins and missing leaders.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if err == state.ErrNotFound || errors.Is(err, api.ErrLeaderless) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/observations"
 	"github.com/ipfs-cluster/ipfs-cluster/state"
 	"github.com/ipfs-cluster/ipfs-cluster/state/dsstate"
 
@@ -22,12 +23,21 @@ import (
 	host "github.com/libp2p/go-libp2p/core/host"
 	peer "github.com/libp2p/go-libp2p/core/peer"
 
+	"go.opencensus.io/stats"
 	"go.opencensus.io/tag"
 	"go.opencensus.io/trace"
 )
 
 var logger = logging.Logger("raft")
 
+// ErrTrustUnsupported is returned when trying to manage the trusted peers,
+// as Raft trusts all peers.
+var ErrTrustUnsupported = errors.New("raft consensus trusts all peers")
+
+// ErrEncryptionUnsupported is returned when asked for encryption keys, as
+// the Raft log is not encrypted.
+var ErrEncryptionUnsupported = errors.New("raft consensus does not encrypt the state")
+
 // Consensus handles the work of keeping a shared-state between
 // the peers of an IPFS Cluster, as well as modifying that state and
 // applying any updates in a thread-safe manner.
@@ -241,6 +251,35 @@ func (cc *Consensus) Trust(ctx context.Context, pid peer.ID) error { return nil
 // Distrust is a no-op.
 func (cc *Consensus) Distrust(ctx context.Context, pid peer.ID) error { return nil }
 
+// LogTrust returns ErrTrustUnsupported.
+func (cc *Consensus) LogTrust(ctx context.Context, pid peer.ID) error {
+	return ErrTrustUnsupported
+}
+
+// LogDistrust returns ErrTrustUnsupported.
+func (cc *Consensus) LogDistrust(ctx context.Context, pid peer.ID) error {
+	return ErrTrustUnsupported
+}
+
+// TrustedPeers returns ErrTrustUnsupported.
+func (cc *Consensus) TrustedPeers(ctx context.Context) ([]peer.ID, error) {
+	return nil, ErrTrustUnsupported
+}
+
+// OperationLog returns a page of the operations in the Raft log, from the
+// most recent.
+func (cc *Consensus) OperationLog(ctx context.Context, opts api.OpLogOptions) ([]api.ConsensusOp, error) {
+	_, span := trace.StartSpan(ctx, "consensus/OperationLog")
+	defer span.End()
+
+	return cc.raft.OperationLog(opts)
+}
+
+// EncryptionKey returns ErrEncryptionUnsupported.
+func (cc *Consensus) EncryptionKey(ctx context.Context, id string) ([]byte, error) {
+	return nil, ErrEncryptionUnsupported
+}
+
 func (cc *Consensus) op(ctx context.Context, pin api.Pin, t LogOpType) *LogOp {
 	return &LogOp{
 		Cid:  pin,
@@ -348,11 +387,13 @@ func (cc *Consensus) commit(ctx context.Context, op *LogOp, rpcOp string, redire
 
 		// now commit the changes to our state
 		cc.shutdownLock.RLock() // do not shut down while committing
+		start := time.Now()
 		_, finalErr = cc.consensus.CommitOp(op)
 		cc.shutdownLock.RUnlock()
 		if finalErr != nil {
 			goto RETRY
 		}
+		stats.Record(ctx, observations.RaftCommitLatency.M(float64(time.Since(start))/float64(time.Millisecond)))
 
 		switch op.Type {
 		case LogOpPin:
@@ -395,6 +436,26 @@ func (cc *Consensus) LogUnpin(ctx context.Context, pin api.Pin) error {
 	return nil
 }
 
+// LogBatch submits the given pins and unpins to the shared state. Raft
+// commits every operation separately, in order, and stops on the first
+// error.
+func (cc *Consensus) LogBatch(ctx context.Context, pins []api.Pin, unpins []api.Pin) error {
+	ctx, span := trace.StartSpan(ctx, "consensus/LogBatch")
+	defer span.End()
+
+	for _, pin := range pins {
+		if err := cc.LogPin(ctx, pin); err != nil {
+			return err
+		}
+	}
+	for _, pin := range unpins {
+		if err := cc.LogUnpin(ctx, pin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // AddPeer adds a new peer to participate in this consensus. It will
 // forward the operation to the leader if this is not it.
 func (cc *Consensus) AddPeer(ctx context.Context, pid peer.ID) error {
@@ -347,3 +347,77 @@ func TestRaftLatestSnapshot(t *testing.T) {
 		t.Fatal("Latest snapshot not read")
 	}
 }
+
+func TestRaftLastPeers(t *testing.T) {
+	ctx := context.Background()
+	cc := testingConsensus(t, 1)
+	defer cleanRaft(1)
+	defer cc.Shutdown(ctx)
+
+	peers, err := LastPeers(cc.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 0 {
+		t.Error("there should be no peers before a snapshot")
+	}
+
+	err = cc.LogPin(ctx, testPin(test.Cid1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(250 * time.Millisecond)
+	err = cc.raft.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peers, err = LastPeers(cc.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 1 || peers[0] != cc.host.ID() {
+		t.Errorf("unexpected peers in snapshot: %s", peers)
+	}
+}
+
+func TestConsensusOperationLog(t *testing.T) {
+	ctx := context.Background()
+	cc := testingConsensus(t, 1)
+	defer cleanRaft(1)
+	defer cc.Shutdown(ctx)
+
+	err := cc.LogPin(ctx, testPin(test.Cid1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cc.LogUnpin(ctx, api.PinCid(test.Cid1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ops, err := cc.OperationLog(ctx, api.OpLogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 2 {
+		t.Fatalf("expected 2 operations, got %d", len(ops))
+	}
+	if len(ops[0].Unpins) != 1 || !ops[0].Unpins[0].Equals(test.Cid1) {
+		t.Error("the last operation should unpin Cid1")
+	}
+	if len(ops[1].Pins) != 1 || !ops[1].Pins[0].Cid.Equals(test.Cid1) {
+		t.Error("the first operation should pin Cid1")
+	}
+	if ops[0].Height <= ops[1].Height {
+		t.Error("operations should be sorted from the most recent")
+	}
+
+	ops, err = cc.OperationLog(ctx, api.OpLogOptions{Offset: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 1 || len(ops[0].Pins) != 1 {
+		t.Error("offset should skip the unpin")
+	}
+}
@@ -7,8 +7,11 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/observations"
 	"github.com/ipfs-cluster/ipfs-cluster/state"
 
 	p2praft "github.com/libp2p/go-libp2p-raft"
@@ -17,6 +20,8 @@ import (
 
 	hraft "github.com/hashicorp/raft"
 	raftboltdb "github.com/hashicorp/raft-boltdb"
+	"github.com/ugorji/go/codec"
+	"go.opencensus.io/stats"
 	"go.opencensus.io/trace"
 )
 
@@ -112,6 +117,7 @@ func newRaftWrapper(
 
 	raftW.ctx, raftW.cancel = context.WithCancel(context.Background())
 	go raftW.observePeers()
+	go raftW.observeLeader()
 
 	return raftW, nil
 }
@@ -570,6 +576,34 @@ func LastStateRaw(cfg *Config) (io.Reader, bool, error) {
 	return r, true, nil
 }
 
+// LastPeers returns the peerset stored in the metadata of the last snapshot,
+// or nil if no snapshot was found.
+func LastPeers(cfg *Config) ([]peer.ID, error) {
+	dataFolder := cfg.GetDataFolder()
+	if _, err := os.Stat(dataFolder); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	meta, r, err := latestSnapshot(dataFolder)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		return nil, nil
+	}
+	r.Close()
+
+	var peers []peer.ID
+	for _, srv := range meta.Configuration.Servers {
+		pid, err := peer.Decode(string(srv.ID))
+		if err != nil {
+			return nil, err
+		}
+		peers = append(peers, pid)
+	}
+	return peers, nil
+}
+
 // SnapshotSave saves the provided state to a snapshot in the
 // raft data path.  Old raft data is backed up and replaced
 // by the new snapshot.  pids contains the config-specified
@@ -667,6 +701,32 @@ func find(s []string, elem string) bool {
 	return false
 }
 
+// observeLeader counts the leadership changes.
+func (rw *raftWrapper) observeLeader() {
+	obsCh := make(chan hraft.Observation, 1)
+	defer close(obsCh)
+
+	observer := hraft.NewObserver(obsCh, true, func(o *hraft.Observation) bool {
+		_, ok := o.Data.(hraft.LeaderObservation)
+		return ok
+	})
+
+	rw.raft.RegisterObserver(observer)
+	defer rw.raft.DeregisterObserver(observer)
+
+	for {
+		select {
+		case obs := <-obsCh:
+			lObs := obs.Data.(hraft.LeaderObservation)
+			logger.Infof("raft leader changed: %s", lObs.LeaderID)
+			stats.Record(rw.ctx, observations.RaftLeaderChanges.M(1))
+		case <-rw.ctx.Done():
+			logger.Debug("stopped observing raft leader")
+			return
+		}
+	}
+}
+
 func (rw *raftWrapper) observePeers() {
 	obsCh := make(chan hraft.Observation, 1)
 	defer close(obsCh)
@@ -696,3 +756,64 @@ func (rw *raftWrapper) observePeers() {
 		}
 	}
 }
+
+// OperationLog returns a page of the pins and unpins in the log, from the
+// most recent. Entries already compacted into a snapshot are not
+// available.
+func (rw *raftWrapper) OperationLog(opts api.OpLogOptions) ([]api.ConsensusOp, error) {
+	first, err := rw.logStore.FirstIndex()
+	if err != nil {
+		return nil, err
+	}
+	last, err := rw.logStore.LastIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []api.ConsensusOp
+	skipped := 0
+	for idx := last; idx >= first && idx > 0; idx-- {
+		if opts.Limit > 0 && len(ops) == opts.Limit {
+			break
+		}
+
+		var log hraft.Log
+		err := rw.logStore.GetLog(idx, &log)
+		if err == hraft.ErrLogNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if log.Type != hraft.LogCommand {
+			continue
+		}
+
+		// Operations are encoded by go-libp2p-raft.
+		var op LogOp
+		err = codec.NewDecoderBytes(log.Data, &codec.MsgpackHandle{}).Decode(&op)
+		if err != nil {
+			logger.Errorf("error decoding log entry %d: %s", idx, err)
+			continue
+		}
+
+		if skipped < opts.Offset {
+			skipped++
+			continue
+		}
+
+		cop := api.ConsensusOp{
+			ID:        strconv.FormatUint(idx, 10),
+			Height:    idx,
+			Timestamp: log.AppendedAt,
+		}
+		switch op.Type {
+		case LogOpPin:
+			cop.Pins = []api.Pin{op.Cid}
+		case LogOpUnpin:
+			cop.Unpins = []api.Cid{op.Cid.Cid}
+		}
+		ops = append(ops, cop)
+	}
+	return ops, nil
+}
@@ -0,0 +1,132 @@
+package ipfscluster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// eventSubscriptionSize is the buffer size of the channels of every
+// subscription. Events are dropped for subscribers which fall behind.
+const eventSubscriptionSize = 1024
+
+// eventBus distributes the events produced by this peer to all subscribers.
+type eventBus struct {
+	mu   sync.RWMutex
+	subs map[chan api.Event]struct{}
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		subs: make(map[chan api.Event]struct{}),
+	}
+}
+
+// subscribe returns a channel which receives all the events published from
+// now on, and a function to cancel the subscription, which closes it.
+func (eb *eventBus) subscribe() (<-chan api.Event, func()) {
+	ch := make(chan api.Event, eventSubscriptionSize)
+	eb.mu.Lock()
+	eb.subs[ch] = struct{}{}
+	eb.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			eb.mu.Lock()
+			delete(eb.subs, ch)
+			eb.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
+
+// publish sends the event to all subscribers without blocking.
+func (eb *eventBus) publish(ev api.Event) {
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now()
+	}
+
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+	for ch := range eb.subs {
+		select {
+		case ch <- ev:
+		default:
+			logger.Warnf("event subscriber is too slow: dropping %s event", ev.Type)
+		}
+	}
+}
+
+// Events sends the events produced by this peer to the out channel until
+// the context is canceled: local pin status changes, peers joining and
+// leaving the peerset and alerts.
+func (c *Cluster) Events(ctx context.Context, out chan<- api.Event) error {
+	defer close(out)
+
+	events, cancel := c.events.subscribe()
+	defer cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.ctx.Done():
+			return nil
+		case ev := <-events:
+			select {
+			case <-ctx.Done():
+				return nil
+			case out <- ev:
+			}
+		}
+	}
+}
+
+// statusChangesHandler publishes the status changes reported by the pin
+// tracker.
+func (c *Cluster) statusChangesHandler() {
+	changes := c.tracker.StatusChanges()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case pi, ok := <-changes:
+			if !ok {
+				return
+			}
+			c.events.publish(api.Event{
+				Type:      api.EventPinStatus,
+				Timestamp: pi.TS,
+				Peer:      pi.Peer,
+				PinInfo:   &pi,
+			})
+		}
+	}
+}
+
+// publishPeerChanges publishes the peers which joined or left between the
+// previous and the current peerset.
+func (c *Cluster) publishPeerChanges(prev, cur []peer.ID) {
+	prevSet := make(map[peer.ID]struct{}, len(prev))
+	for _, p := range prev {
+		prevSet[p] = struct{}{}
+	}
+	curSet := make(map[peer.ID]struct{}, len(cur))
+	for _, p := range cur {
+		curSet[p] = struct{}{}
+		if _, ok := prevSet[p]; !ok {
+			c.events.publish(api.Event{Type: api.EventPeerJoined, Peer: p})
+		}
+	}
+	for _, p := range prev {
+		if _, ok := curSet[p]; !ok {
+			c.events.publish(api.Event{Type: api.EventPeerLeft, Peer: p})
+		}
+	}
+}
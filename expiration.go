@@ -0,0 +1,90 @@
+package ipfscluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/observations"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/trace"
+)
+
+// reapExpiredPins unpins the pins whose ExpireAt has passed. Every peer
+// only unpins the expired pins for which it is the closest with respect to
+// the other trusted peers, so that each of them is unpinned once. Every
+// reaped pin is logged and published as a pin expired event. With
+// ExpirationDryRun, they are logged and published, but not unpinned.
+//
+// Follower peers do not reap pins: they cannot unpin, and cannot know if
+// their peer ID is trusted by other peers.
+func (c *Cluster) reapExpiredPins(ctx context.Context) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/reapExpiredPins")
+	defer span.End()
+
+	if c.config.FollowerMode {
+		return nil
+	}
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return err
+	}
+
+	timeNow := time.Now()
+
+	// Only trigger pin operations if we are the closest with respect to
+	// other trusted peers. We cannot know if our peer ID is trusted by
+	// other peers in the Cluster. This assumes yes. Setting FollowerMode
+	// is a way to assume the opposite and skip this completely.
+	distance, err := c.distances(ctx, "")
+	if err != nil {
+		return err // could not list peers
+	}
+
+	clusterPins := make(chan api.Pin, 1024)
+	go func() {
+		if err := cState.List(ctx, clusterPins); err != nil {
+			logger.Error(err)
+		}
+	}()
+
+	dryRun := c.config.ExpirationDryRun
+	reaped := 0
+	for p := range clusterPins {
+		if !p.ExpiredAt(timeNow) || !distance.isClosest(p.Cid) {
+			continue
+		}
+
+		if dryRun {
+			logger.Infof("Expiration dry-run: would unpin %s: pin expired at %s", p.Cid, p.ExpireAt)
+		} else {
+			logger.Infof("Unpinning %s: pin expired at %s", p.Cid, p.ExpireAt)
+			if _, err := c.Unpin(ctx, p.Cid); err != nil {
+				logger.Error(err)
+				continue
+			}
+		}
+		reaped++
+
+		pin := p
+		c.events.publish(api.Event{
+			Type:   api.EventPinExpired,
+			Peer:   c.id,
+			Pin:    &pin,
+			DryRun: dryRun,
+		})
+	}
+
+	if reaped == 0 {
+		return nil
+	}
+	if dryRun {
+		logger.Infof("Expiration dry-run: %d expired pins would have been unpinned", reaped)
+		return nil
+	}
+	logger.Infof("Unpinned %d expired pins", reaped)
+	stats.Record(ctx, observations.PinsExpired.M(int64(reaped)))
+	return nil
+}
@@ -0,0 +1,113 @@
+package ipfscluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+
+	"go.opencensus.io/trace"
+)
+
+// followResolveTimeout bounds the time spent resolving each followed name,
+// so that names which cannot be resolved do not hold the whole round.
+const followResolveTimeout = 30 * time.Second
+
+// watchFollowedPins updates the followed pins every IPNSFollowInterval. It
+// runs separately from watchPinset, as resolving many names may take long.
+func (c *Cluster) watchFollowedPins() {
+	ctx, span := trace.StartSpan(c.ctx, "cluster/watchFollowedPins")
+	defer span.End()
+
+	followTimer := time.NewTimer(c.config.IPNSFollowInterval)
+	defer followTimer.Stop()
+	for {
+		select {
+		case <-followTimer.C:
+			logger.Debug("auto-triggering update of followed pins")
+			if err := c.updateFollowedPins(ctx); err != nil {
+				logger.Error(err)
+			}
+			followTimer.Reset(c.config.IPNSFollowInterval)
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// updateFollowedPins resolves the IPNS names followed by pins and updates
+// the pins whose name points to a different CID: the pin is moved to the
+// new CID, keeping its options, and the previous CID is unpinned. Like the
+// expiration reaper, every peer only handles the pins for which it is the
+// closest with respect to the other trusted peers, so that each of them is
+// updated once. Names which cannot be resolved, or take longer than
+// followResolveTimeout, are skipped until the next round.
+//
+// Follower peers do not update pins.
+func (c *Cluster) updateFollowedPins(ctx context.Context) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/updateFollowedPins")
+	defer span.End()
+
+	if c.config.FollowerMode {
+		return nil
+	}
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return err
+	}
+
+	distance, err := c.distances(ctx, "")
+	if err != nil {
+		return err // could not list peers
+	}
+
+	clusterPins := make(chan api.Pin, 1024)
+	go func() {
+		if err := cState.List(ctx, clusterPins); err != nil {
+			logger.Error(err)
+		}
+	}()
+
+	// Resolving may be slow. Collect the pins first so that the state
+	// is not being listed meanwhile.
+	var followed []api.Pin
+	for p := range clusterPins {
+		if p.Follow == "" || p.Type != api.DataType || !distance.isClosest(p.Cid) {
+			continue
+		}
+		followed = append(followed, p)
+	}
+
+	updated := 0
+	for _, p := range followed {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		resolveCtx, cancel := context.WithTimeout(ctx, followResolveTimeout)
+		to, err := c.ipfs.Resolve(resolveCtx, p.Follow)
+		cancel()
+		if err != nil {
+			logger.Warnf("could not resolve %s, followed by %s: %s", p.Follow, p.Cid, err)
+			continue
+		}
+		if to.Equals(p.Cid) {
+			continue
+		}
+
+		logger.Infof("%s now points to %s: updating pin %s", p.Follow, to, p.Cid)
+		if _, err := c.PinUpdate(ctx, p.Cid, to, api.PinOptions{}); err != nil {
+			logger.Error(err)
+			continue
+		}
+		if _, err := c.Unpin(ctx, p.Cid); err != nil {
+			logger.Error(err)
+		}
+		updated++
+	}
+
+	if updated > 0 {
+		logger.Infof("Updated %d pins following IPNS names", updated)
+	}
+	return nil
+}
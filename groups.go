@@ -0,0 +1,148 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+
+	"go.opencensus.io/trace"
+)
+
+var errNoGroup = errors.New("a group name is needed")
+
+// Groups returns the groups which have pins in the shared state, sorted by
+// name, with the number of pins in each. Groups only exist as long as some
+// pin has them set in their options.
+func (c *Cluster) Groups(ctx context.Context) ([]api.PinGroup, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/Groups")
+	defer span.End()
+
+	pins, err := c.pinsSlice(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, p := range pins {
+		if p.Group != "" {
+			counts[p.Group]++
+		}
+	}
+	groups := make([]api.PinGroup, 0, len(counts))
+	for name, n := range counts {
+		groups = append(groups, api.PinGroup{Name: name, Pins: n})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups, nil
+}
+
+// GroupPins sends the pins in the given group on the out channel, which is
+// closed when done. It can be used to export a dataset.
+func (c *Cluster) GroupPins(ctx context.Context, group string, out chan<- api.Pin) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/GroupPins")
+	defer span.End()
+
+	if group == "" {
+		close(out)
+		return errNoGroup
+	}
+
+	defer close(out)
+
+	all := make(chan api.Pin, 1024)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Pins(ctx, all)
+	}()
+
+	for p := range all {
+		if p.Group != group {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case out <- p:
+		}
+	}
+	return <-errCh
+}
+
+// groupPins returns the pins in the given group.
+func (c *Cluster) groupPins(ctx context.Context, group string) ([]api.Pin, error) {
+	out := make(chan api.Pin, 1024)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.GroupPins(ctx, group, out)
+	}()
+
+	var pins []api.Pin
+	for p := range out {
+		pins = append(pins, p)
+	}
+	return pins, <-errCh
+}
+
+// UnpinGroup unpins all the pins in the given group. They are submitted
+// together to the consensus layer with PinBatch, so that the whole group is
+// unpinned in a single operation when the consensus layer supports it. It
+// returns the results for every pin.
+func (c *Cluster) UnpinGroup(ctx context.Context, group string) ([]api.BatchResult, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/UnpinGroup")
+	defer span.End()
+
+	if c.config.FollowerMode {
+		return nil, errFollowerMode
+	}
+
+	pins, err := c.groupPins(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]api.BatchItem, len(pins))
+	for i, p := range pins {
+		items[i] = api.BatchItem{
+			Action: api.BatchActionUnpin,
+			Cid:    p.Cid,
+		}
+	}
+	logger.Infof("unpinning group %s: %d pins", group, len(items))
+	return c.PinBatch(ctx, items)
+}
+
+// SetGroupReplication changes the replication factors of all the pins in
+// the given group, which are re-allocated as needed. Factors set to 0 take
+// the configured defaults. Like UnpinGroup, the pins are submitted with
+// PinBatch. Only regular pins are changed: the results of other pins carry
+// an error.
+func (c *Cluster) SetGroupReplication(ctx context.Context, group string, rplMin, rplMax int) ([]api.BatchResult, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/SetGroupReplication")
+	defer span.End()
+
+	if c.config.FollowerMode {
+		return nil, errFollowerMode
+	}
+
+	if rplMin == 0 {
+		rplMin = c.config.ReplicationFactorMin
+	}
+	if rplMax == 0 {
+		rplMax = c.config.ReplicationFactorMax
+	}
+	if err := isReplicationFactorValid(rplMin, rplMax); err != nil {
+		return nil, err
+	}
+
+	pins, err := c.groupPins(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("setting replication of group %s to [%d, %d]: %d pins", group, rplMin, rplMax, len(pins))
+	return c.setReplication(ctx, pins, rplMin, rplMax)
+}
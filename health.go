@@ -0,0 +1,128 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+
+	ds "github.com/ipfs/go-datastore"
+	"go.opencensus.io/trace"
+)
+
+// healthCheckTimeout bounds the time that every component check can take.
+const healthCheckTimeout = 10 * time.Second
+
+// healthCheckKey is the datastore key written and removed to check that
+// the datastore is writable.
+var healthCheckKey = ds.NewKey("/cluster/health-check")
+
+// HealthCheck actively checks the components of this peer: that the IPFS
+// daemon is reachable, that the consensus component is ready, that the
+// datastore is writable and that the metrics of this peer are fresh. The
+// checks run in parallel and the results are returned in a fixed order.
+func (c *Cluster) HealthCheck(ctx context.Context) api.HealthReport {
+	ctx, span := trace.StartSpan(ctx, "cluster/HealthCheck")
+	defer span.End()
+
+	checks := []struct {
+		component string
+		check     func(context.Context) error
+	}{
+		{api.HealthComponentIPFS, c.checkIPFSHealth},
+		{api.HealthComponentConsensus, c.checkConsensusHealth},
+		{api.HealthComponentDatastore, c.checkDatastoreHealth},
+		{api.HealthComponentMonitor, c.checkMonitorHealth},
+	}
+
+	report := api.HealthReport{
+		Peer:       c.id,
+		Healthy:    true,
+		Components: make([]api.ComponentHealth, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i, chk := range checks {
+		wg.Add(1)
+		go func(i int, component string, check func(context.Context) error) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(ctx)
+			res := api.ComponentHealth{
+				Component: component,
+				Healthy:   err == nil,
+				Latency:   float64(time.Since(start)) / float64(time.Millisecond),
+			}
+			if err != nil {
+				res.Error = err.Error()
+			}
+			report.Components[i] = res
+		}(i, chk.component, chk.check)
+	}
+	wg.Wait()
+
+	for _, res := range report.Components {
+		if !res.Healthy {
+			report.Healthy = false
+			logger.Warnf("health check: %s: %s", res.Component, res.Error)
+		}
+	}
+	return report
+}
+
+func (c *Cluster) checkIPFSHealth(ctx context.Context) error {
+	_, err := c.ipfs.ID(ctx)
+	return err
+}
+
+// checkConsensusHealth checks that the consensus component is ready and
+// that it has a leader, for components which have one. The peer is only
+// ready once consensus is, and the readiness channels of some consensus
+// components can only be read once, so the one of the peer is used.
+func (c *Cluster) checkConsensusHealth(ctx context.Context) error {
+	select {
+	case <-c.readyCh:
+	default:
+		return errors.New("consensus is not ready")
+	}
+
+	if _, err := c.consensus.Leader(ctx); err != nil && !api.IsErrLeaderless(err) {
+		return err
+	}
+	_, err := c.consensus.State(ctx)
+	return err
+}
+
+func (c *Cluster) checkDatastoreHealth(ctx context.Context) error {
+	value := []byte(time.Now().UTC().Format(time.RFC3339Nano))
+	if err := c.datastore.Put(ctx, healthCheckKey, value); err != nil {
+		return err
+	}
+	return c.datastore.Delete(ctx, healthCheckKey)
+}
+
+// checkMonitorHealth checks that the monitor has valid, unexpired metrics
+// from this peer for the ping and the informer metrics.
+func (c *Cluster) checkMonitorHealth(ctx context.Context) error {
+	names := []string{pingMetricName}
+	for _, inf := range c.informers {
+		names = append(names, inf.Name())
+	}
+
+	for _, name := range names {
+		m := c.monitor.LatestForPeer(ctx, name, c.id)
+		switch {
+		case !m.Defined():
+			return fmt.Errorf("no %s metric from this peer", name)
+		case m.Discard():
+			return fmt.Errorf("the %s metric from this peer is stale", name)
+		}
+	}
+	return nil
+}
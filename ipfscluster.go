@@ -10,6 +10,7 @@ package ipfscluster
 
 import (
 	"context"
+	"time"
 
 	"github.com/ipfs-cluster/ipfs-cluster/api"
 	"github.com/ipfs-cluster/ipfs-cluster/state"
@@ -32,6 +33,10 @@ type Component interface {
 // Currently, Consensus needs to be able to elect/provide a
 // Cluster Leader and the implementation is very tight to
 // the Cluster main component.
+//
+// Implementations are provided by the consensus/crdt and consensus/raft
+// packages. Consensus backends running in a separate process can be used
+// through consensus/external, which calls them over gRPC.
 type Consensus interface {
 	Component
 	// Returns a channel to signal that the consensus layer is ready
@@ -41,11 +46,19 @@ type Consensus interface {
 	LogPin(context.Context, api.Pin) error
 	// Logs an unpin operation.
 	LogUnpin(context.Context, api.Pin) error
+	// Logs several pin and unpin operations together,
+	// as a single operation when supported.
+	LogBatch(ctx context.Context, pins []api.Pin, unpins []api.Pin) error
+	// Adds a peer to the peerset.
 	AddPeer(context.Context, peer.ID) error
+	// Removes a peer from the peerset.
 	RmPeer(context.Context, peer.ID) error
+	// Returns the shared state. Get must return state.ErrNotFound
+	// for pins which are not part of it.
 	State(context.Context) (state.ReadOnly, error)
 	// Provide a node which is responsible to perform
 	// specific tasks which must only run in 1 cluster peer.
+	// Returns an error wrapping api.ErrLeaderless when there is none.
 	Leader(context.Context) (peer.ID, error)
 	// Only returns when the consensus state has all log
 	// updates applied to it.
@@ -63,6 +76,20 @@ type Consensus interface {
 	Trust(context.Context, peer.ID) error
 	// Distrust removes a peer from the "trusted" set.
 	Distrust(context.Context, peer.ID) error
+	// LogTrust records through the consensus layer that a peer is
+	// "trusted", so that all peers trust it.
+	LogTrust(context.Context, peer.ID) error
+	// LogDistrust records through the consensus layer that a peer
+	// is not "trusted", so that all peers distrust it.
+	LogDistrust(context.Context, peer.ID) error
+	// TrustedPeers returns the peers currently in the "trusted" set.
+	TrustedPeers(context.Context) ([]peer.ID, error)
+	// OperationLog returns a page of the updates to the shared
+	// state, from the most recent.
+	OperationLog(context.Context, api.OpLogOptions) ([]api.ConsensusOp, error)
+	// EncryptionKey returns the key with the given ID used to
+	// encrypt the shared state, for trusted peers to decrypt it.
+	EncryptionKey(context.Context, string) ([]byte, error)
 }
 
 // API is a component which offers an API for Cluster. This is
@@ -81,6 +108,8 @@ type IPFSConnector interface {
 	Ready(context.Context) <-chan struct{}
 	ID(context.Context) (api.IPFSID, error)
 	Pin(context.Context, api.Pin) error
+	// PinProgress returns the progress of an ongoing pin.
+	PinProgress(context.Context, api.Cid) (api.PinProgress, error)
 	Unpin(context.Context, api.Cid) error
 	PinLsCid(context.Context, api.Pin) (api.IPFSPinStatus, error)
 	// PinLs returns pins in the pinset of the given types (recursive, direct...)
@@ -104,6 +133,9 @@ type IPFSConnector interface {
 	BlockStream(context.Context, <-chan api.NodeWithMeta) error
 	// BlockGet retrieves the raw data of an IPFS block.
 	BlockGet(context.Context, api.Cid) ([]byte, error)
+	// BlockHas returns whether the IPFS daemon stores a block, without
+	// fetching it.
+	BlockHas(context.Context, api.Cid) (bool, error)
 }
 
 // Peered represents a component which needs to be aware of the peers
@@ -134,8 +166,29 @@ type PinTracker interface {
 	RecoverAll(context.Context, chan<- api.PinInfo) error
 	// Recover retriggers a Pin/Unpin operation in a Cids with error status.
 	Recover(context.Context, api.Cid) (api.PinInfo, error)
+	// RecoverPermanent retries the pins which are not retried
+	// automatically anymore because they failed too many times.
+	RecoverPermanent(context.Context, chan<- api.PinInfo) error
+	// RecoverScheduled retries the failed operations which are due to be
+	// retried and returns when it should be called again. With scan, it
+	// also recovers the items that should be pinned but are not.
+	RecoverScheduled(ctx context.Context, scan bool) (time.Time, error)
 	// PinQueueSize returns the current size of the pinning queue.
 	PinQueueSize(context.Context) (int64, error)
+	// Concurrency returns the number of pin and unpin operations that
+	// the tracker runs in parallel.
+	Concurrency(context.Context) api.PinTrackerConcurrency
+	// SetConcurrency changes the number of pin and unpin operations
+	// that the tracker runs in parallel, without restarting.
+	SetConcurrency(context.Context, api.PinTrackerConcurrency) (api.PinTrackerConcurrency, error)
+	// Pause stops the tracker from starting new operations, which are
+	// still queued, until Resume is called.
+	Pause(context.Context) api.PinTrackerConcurrency
+	// Resume lets a paused tracker start operations again.
+	Resume(context.Context) api.PinTrackerConcurrency
+	// StatusChanges returns a channel on which the tracker sends the
+	// local status of items every time that it changes.
+	StatusChanges() <-chan api.PinInfo
 }
 
 // Informer provides Metric information from a peer. The metrics produced by
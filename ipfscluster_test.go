@@ -960,6 +960,52 @@ func TestClustersStatusAll(t *testing.T) {
 	runF(t, clusters, f)
 }
 
+func TestClustersStatusAllFiltered(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+	clusters[0].Pin(ctx, test.Cid1, api.PinOptions{})
+	pinDelay()
+	// Pin timestamps are stored with second precision.
+	time.Sleep(time.Second)
+	between := time.Now()
+	time.Sleep(time.Second)
+	clusters[0].Pin(ctx, test.Cid2, api.PinOptions{})
+	pinDelay()
+
+	statusAll := func(t *testing.T, c *Cluster, filter api.StatusAllFilter) []api.GlobalPinInfo {
+		out := make(chan api.GlobalPinInfo, 10)
+		go func() {
+			err := c.StatusAllFiltered(ctx, filter, out)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+		return collectGlobalPinInfos(t, out, 5*time.Second)
+	}
+
+	f := func(t *testing.T, c *Cluster) {
+		statuses := statusAll(t, c, api.StatusAllFilter{CreatedAfter: between})
+		if len(statuses) != 1 || !statuses[0].Cid.Equals(test.Cid2) {
+			t.Fatalf("expected only Cid2 after: %+v", statuses)
+		}
+		if len(statuses[0].PeerMap) != nClusters {
+			t.Error("bad info in status")
+		}
+
+		statuses = statusAll(t, c, api.StatusAllFilter{CreatedBefore: between})
+		if len(statuses) != 1 || !statuses[0].Cid.Equals(test.Cid1) {
+			t.Fatalf("expected only Cid1 before: %+v", statuses)
+		}
+
+		statuses = statusAll(t, c, api.StatusAllFilter{Status: api.TrackerStatusPinned})
+		if len(statuses) != 2 {
+			t.Fatalf("expected two items without bounds: %+v", statuses)
+		}
+	}
+	runF(t, clusters, f)
+}
+
 func TestClustersStatusAllWithErrors(t *testing.T) {
 	ctx := context.Background()
 	clusters, mock := createClusters(t)
@@ -1392,6 +1438,249 @@ func TestClustersReplicationFactorMax(t *testing.T) {
 	runF(t, clusters, f)
 }
 
+func TestClustersSearchPins(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+
+	search := api.PinSearch{Metadata: map[string]string{"dataset": "climate"}}
+	searchPins := func(t *testing.T, c *Cluster) []api.Pin {
+		t.Helper()
+		out := make(chan api.Pin, 10)
+		if err := c.SearchPins(ctx, search, out); err != nil {
+			t.Fatal(err)
+		}
+		var pins []api.Pin
+		for p := range out {
+			pins = append(pins, p)
+		}
+		return pins
+	}
+
+	// Build the indexes before pinning, so that they have to be updated
+	// with the replicated pins.
+	for _, c := range clusters {
+		if pins := searchPins(t, c); len(pins) != 0 {
+			t.Fatalf("%s: expected no results: %v", c.id, pins)
+		}
+	}
+
+	_, err := clusters[0].Pin(ctx, test.Cid1, api.PinOptions{
+		Metadata: map[string]string{"dataset": "climate"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = clusters[0].Pin(ctx, test.Cid2, api.PinOptions{
+		Metadata: map[string]string{"dataset": "other"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	for _, c := range clusters {
+		pins := searchPins(t, c)
+		if len(pins) != 1 || !pins[0].Cid.Equals(test.Cid1) {
+			t.Errorf("%s: unexpected search results: %v", c.id, pins)
+		}
+	}
+
+	_, err = clusters[0].Unpin(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	for _, c := range clusters {
+		if pins := searchPins(t, c); len(pins) != 0 {
+			t.Errorf("%s: expected the unpinned item to be unindexed: %v", c.id, pins)
+		}
+	}
+
+	out := make(chan api.Pin)
+	if err := clusters[0].SearchPins(ctx, api.PinSearch{}, out); err == nil {
+		t.Error("expected an error searching without criteria")
+	}
+}
+
+// TestClustersPassivePeer checks that passive peers are never allocated
+// pins, not even when asked to.
+func TestClustersPassivePeer(t *testing.T) {
+	ctx := context.Background()
+	if nClusters < 3 {
+		t.Skip("Need at least 3 peers")
+	}
+
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+
+	passive := clusters[1]
+	passive.config.PassiveMode = true
+	if _, err := passive.sendPingMetric(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range clusters {
+		c.config.ReplicationFactorMin = 1
+		c.config.ReplicationFactorMax = nClusters
+	}
+
+	ttlDelay()
+
+	_, err := clusters[0].Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = clusters[0].Pin(ctx, test.Cid2, api.PinOptions{
+		ReplicationFactorMin: 1,
+		ReplicationFactorMax: 1,
+		UserAllocations:      []peer.ID{passive.id},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pinDelay()
+
+	for _, h := range []api.Cid{test.Cid1, test.Cid2} {
+		p, err := clusters[0].PinGet(ctx, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if containsPeer(p.Allocations, passive.id) {
+			t.Errorf("%s: the passive peer should not be allocated", h)
+		}
+	}
+	p, err := clusters[0].PinGet(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Allocations) != nClusters-1 {
+		t.Errorf("expected all peers but the passive one: %s", p.Allocations)
+	}
+}
+
+// TestClustersQuota checks that peers which have reached their quota are
+// not allocated new pins and raise an alert.
+func TestClustersQuota(t *testing.T) {
+	ctx := context.Background()
+	if nClusters < 3 {
+		t.Skip("Need at least 3 peers")
+	}
+
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+
+	for _, c := range clusters {
+		c.config.ReplicationFactorMin = 1
+		c.config.ReplicationFactorMax = nClusters
+	}
+
+	_, err := clusters[0].Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	full := clusters[1]
+	full.config.QuotaMaxPins = 1
+	if _, err := full.sendPingMetric(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ttlDelay()
+
+	alerts := full.Alerts()
+	if len(alerts) == 0 || alerts[0].Name != quotaMetricName {
+		t.Errorf("expected a quota alert: %v", alerts)
+	}
+
+	_, err = clusters[0].Pin(ctx, test.Cid2, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	p, err := clusters[0].PinGet(ctx, test.Cid2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if containsPeer(p.Allocations, full.id) {
+		t.Error("the peer over its quota should not be allocated")
+	}
+	if len(p.Allocations) != nClusters-1 {
+		t.Errorf("expected all peers but the one over its quota: %s", p.Allocations)
+	}
+
+	// Existing allocations are kept.
+	p, err = clusters[0].PinGet(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsPeer(p.Allocations, full.id) {
+		t.Error("the peer over its quota should keep its allocations")
+	}
+}
+
+func TestClustersConcurrencyBudget(t *testing.T) {
+	ctx := context.Background()
+	if nClusters < 2 {
+		t.Skip("Need at least 2 peers")
+	}
+
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+
+	budget := 2 * nClusters
+	for _, c := range clusters {
+		c.config.GlobalConcurrencyBudget = budget
+	}
+
+	acquire := func(c *Cluster) error {
+		ctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer cancel()
+		return c.acquireBudget(ctx)
+	}
+
+	// The first peer can take the whole budget while the others are
+	// idle.
+	busy := clusters[0]
+	for i := 0; i < budget; i++ {
+		if err := acquire(busy); err != nil {
+			t.Fatal("operations within the budget should start:", err)
+		}
+	}
+	if err := acquire(busy); err == nil {
+		t.Fatal("operations above the budget should wait")
+	}
+	if _, err := busy.sendPingMetric(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ttlDelay()
+
+	// The others can still run their share.
+	other := clusters[1]
+	for i := 0; i < budget/nClusters; i++ {
+		if err := acquire(other); err != nil {
+			t.Fatal("a peer should be able to use its share:", err)
+		}
+	}
+	if err := acquire(other); err == nil {
+		t.Fatal("the budget is used by another peer")
+	}
+
+	for i := 0; i < budget; i++ {
+		busy.releaseBudget()
+	}
+	if _, err := busy.sendPingMetric(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ttlDelay()
+
+	if err := acquire(other); err != nil {
+		t.Fatal("the budget given back should be usable:", err)
+	}
+}
+
 // This tests checks that repinning something that is overpinned
 // removes some allocations
 func TestClustersReplicationFactorMaxLower(t *testing.T) {
@@ -2128,6 +2417,111 @@ func TestRepoGC(t *testing.T) {
 	runF(t, clusters, f)
 }
 
+func TestClustersGarbageCollect(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+
+	for _, concurrency := range []int{0, 2, nClusters + 1} {
+		gRepoGC, err := clusters[0].GarbageCollect(ctx, concurrency)
+		if err != nil {
+			t.Fatal("gc should have worked:", err)
+		}
+		if len(gRepoGC.PeerMap) != nClusters {
+			t.Errorf("%d: expected repo gc information for %d peers", concurrency, nClusters)
+		}
+		for _, repoGC := range gRepoGC.PeerMap {
+			testRepoGC(t, repoGC)
+		}
+	}
+}
+
+func TestClustersPinTrackerConcurrency(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+
+	concs, err := clusters[0].SetPinTrackerConcurrency(ctx, api.PinTrackerConcurrency{Pins: 3, Unpins: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(concs) != nClusters {
+		t.Fatalf("expected the concurrency of %d peers: %d", nClusters, len(concs))
+	}
+
+	concs, err = clusters[1].PinTrackerConcurrency(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, conc := range concs {
+		if conc.Error != "" || conc.Pins != 3 || conc.MaxPins != 3 || conc.Unpins != 2 {
+			t.Errorf("unexpected concurrency: %+v", conc)
+		}
+	}
+
+	concs, err = clusters[0].SetPinTrackerConcurrency(ctx, api.PinTrackerConcurrency{Pins: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, conc := range concs {
+		if conc.Error == "" {
+			t.Errorf("expected an error from %s", conc.Peer)
+		}
+	}
+	for _, c := range clusters {
+		if c.tracker.Concurrency(ctx).Pins != 3 {
+			t.Error("an invalid concurrency should not be applied")
+		}
+	}
+}
+
+func TestClustersPinTrackerPause(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+	prefix := test.Cid1.Prefix()
+
+	ttlDelay()
+
+	concs, err := clusters[0].PinTrackerPause(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, conc := range concs {
+		if conc.Error != "" || !conc.Paused {
+			t.Errorf("expected a paused pintracker: %+v", conc)
+		}
+	}
+
+	h, _ := prefix.Sum(randomBytes()) // create random cid
+	_, err = clusters[0].Pin(ctx, api.NewCid(h), api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	for _, c := range clusters {
+		if st := c.StatusLocal(ctx, api.NewCid(h)).Status; st != api.TrackerStatusPinQueued {
+			t.Errorf("%s: the pin should be queued while paused: %s", c.id, st)
+		}
+	}
+
+	concs, err = clusters[1].PinTrackerResume(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(concs) != nClusters {
+		t.Fatalf("expected %d peers to be resumed: %d", nClusters, len(concs))
+	}
+	pinDelay()
+
+	for _, c := range clusters {
+		if st := c.StatusLocal(ctx, api.NewCid(h)).Status; st != api.TrackerStatusPinned {
+			t.Errorf("%s: the pin should be pinned after resuming: %s", c.id, st)
+		}
+	}
+}
+
 func TestClustersFollowerMode(t *testing.T) {
 	ctx := context.Background()
 	clusters, mock := createClusters(t)
@@ -2272,3 +2666,89 @@ func TestClusterAlerts(t *testing.T) {
 		t.Error("expected at least one alert")
 	}
 }
+
+func TestClustersTrustPeer(t *testing.T) {
+	ctx := context.Background()
+	if consensus != "crdt" {
+		t.Skip("trust changes are only recorded by crdt")
+	}
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+
+	isTrusted := func(c *Cluster, pid peer.ID) bool {
+		peers, err := c.TrustedPeers(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range peers {
+			if p == pid {
+				return true
+			}
+		}
+		return false
+	}
+
+	err := clusters[0].TrustPeer(ctx, test.PeerID4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+	for _, c := range clusters {
+		if !isTrusted(c, test.PeerID4) || !c.consensus.IsTrustedPeer(ctx, test.PeerID4) {
+			t.Errorf("%s should trust the peer", c.id)
+		}
+	}
+
+	err = clusters[1].DistrustPeer(ctx, test.PeerID4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+	for _, c := range clusters {
+		if isTrusted(c, test.PeerID4) {
+			t.Errorf("%s should not trust the peer", c.id)
+		}
+	}
+
+	clusters[1].config.FollowerMode = true
+	err = clusters[1].TrustPeer(ctx, test.PeerID4)
+	if err != errFollowerMode {
+		t.Error("expected follower mode error")
+	}
+}
+
+func TestClustersRegions(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+
+	regions := []string{"eu-west", "us-east"}
+	for i, c := range clusters {
+		c.config.Region = regions[i%2]
+		if _, err := c.sendPingMetric(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ttlDelay()
+
+	c0 := clusters[0]
+	c0.recordPeerRegions(ctx)
+	score := regionScoreFunc(c0.host, c0.config.Region)
+	for i, c := range clusters[1:] {
+		v, err := c0.host.Peerstore().Get(c.id, regionPeerstoreKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != regions[(i+1)%2] {
+			t.Errorf("%s: expected region %s, got %s", c.id, regions[(i+1)%2], v)
+		}
+
+		expected := 0.0
+		if (i+1)%2 == 0 {
+			expected = regionScore
+		}
+		if s := score(c.id); s != expected {
+			t.Errorf("%s: expected score %f, got %f", c.id, expected, s)
+		}
+	}
+}
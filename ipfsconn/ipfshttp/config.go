@@ -26,6 +26,8 @@ const (
 	DefaultRepoGCTimeout           = 24 * time.Hour
 	DefaultInformerTriggerInterval = 0 // disabled
 	DefaultUnpinDisable            = false
+	DefaultPinFetchLimit           = 0 // unlimited
+	DefaultPinThroughputFloor      = 0 // disabled
 )
 
 // Config is used to initialize a Connector and allows to customize
@@ -44,7 +46,9 @@ type Config struct {
 	// IPFS Daemon HTTP Client POST timeout
 	IPFSRequestTimeout time.Duration
 
-	// Pin Operation timeout
+	// Pin Operation timeout. Pins are cancelled when IPFS reports no
+	// progress for this long, unless PinThroughputFloor is set and the
+	// size of the DAG is known.
 	PinTimeout time.Duration
 
 	// Unpin Operation timeout
@@ -60,6 +64,19 @@ type Config struct {
 	// Disables the unpin operation and returns an error.
 	UnpinDisable bool
 
+	// Maximum number of bytes, as estimated from the pinning progress
+	// reported by IPFS, that ongoing pins may be fetching at the same
+	// time. Further pins wait until there is room. 0 means no limit.
+	PinFetchLimit uint64
+
+	// Minimum average speed, in bytes per second, expected for pins.
+	// When set, the size of the DAG is obtained from IPFS before pinning
+	// and pins are cancelled when they take longer than PinTimeout plus
+	// the time needed to fetch that size at this speed, regardless of
+	// their progress. Pins whose size cannot be obtained use PinTimeout
+	// as usual. 0 disables it.
+	PinThroughputFloor uint64
+
 	// Tracing flag used to skip tracing specific paths when not enabled.
 	Tracing bool
 }
@@ -73,6 +90,8 @@ type jsonConfig struct {
 	RepoGCTimeout           string `json:"repogc_timeout"`
 	InformerTriggerInterval int    `json:"informer_trigger_interval"`
 	UnpinDisable            bool   `json:"unpin_disable,omitempty"`
+	PinFetchLimit           uint64 `json:"pin_fetch_limit,omitempty"`
+	PinThroughputFloor      uint64 `json:"pin_throughput_floor,omitempty"`
 }
 
 // ConfigKey provides a human-friendly identifier for this type of Config.
@@ -91,6 +110,8 @@ func (cfg *Config) Default() error {
 	cfg.RepoGCTimeout = DefaultRepoGCTimeout
 	cfg.InformerTriggerInterval = DefaultInformerTriggerInterval
 	cfg.UnpinDisable = DefaultUnpinDisable
+	cfg.PinFetchLimit = DefaultPinFetchLimit
+	cfg.PinThroughputFloor = DefaultPinThroughputFloor
 
 	return nil
 }
@@ -169,6 +190,8 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 	cfg.NodeAddr = nodeAddr
 	cfg.UnpinDisable = jcfg.UnpinDisable
 	cfg.InformerTriggerInterval = jcfg.InformerTriggerInterval
+	cfg.PinFetchLimit = jcfg.PinFetchLimit
+	cfg.PinThroughputFloor = jcfg.PinThroughputFloor
 
 	err = config.ParseDurations(
 		"ipfshttp",
@@ -215,6 +238,8 @@ func (cfg *Config) toJSONConfig() (jcfg *jsonConfig, err error) {
 	jcfg.RepoGCTimeout = cfg.RepoGCTimeout.String()
 	jcfg.InformerTriggerInterval = cfg.InformerTriggerInterval
 	jcfg.UnpinDisable = cfg.UnpinDisable
+	jcfg.PinFetchLimit = cfg.PinFetchLimit
+	jcfg.PinThroughputFloor = cfg.PinThroughputFloor
 
 	return
 }
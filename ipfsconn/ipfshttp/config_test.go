@@ -15,7 +15,9 @@ var cfgJSON = []byte(`
 	"pin_timeout": "2m",
 	"unpin_timeout": "3h",
 	"repogc_timeout": "24h",
-	"informer_trigger_interval": 10
+	"informer_trigger_interval": 10,
+	"pin_fetch_limit": 1048576,
+	"pin_throughput_floor": 102400
 }
 `)
 
@@ -33,6 +35,14 @@ func TestLoadJSON(t *testing.T) {
 		t.Error("missing value")
 	}
 
+	if cfg.PinFetchLimit != 1048576 {
+		t.Error("missing pin_fetch_limit")
+	}
+
+	if cfg.PinThroughputFloor != 102400 {
+		t.Error("missing pin_throughput_floor")
+	}
+
 	j.NodeMultiaddress = "abc"
 	tst, _ := json.Marshal(j)
 	err = cfg.LoadJSON(tst)
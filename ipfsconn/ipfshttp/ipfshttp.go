@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -66,6 +67,9 @@ type Connector struct {
 	failedRequests atomic.Uint64 // count failed requests.
 	reqRateLimitCh chan struct{}
 
+	fetchThrottle *fetchThrottle
+	inFlight      *inFlightPins
+
 	shutdownLock sync.Mutex
 	shutdown     bool
 	wg           sync.WaitGroup
@@ -128,6 +132,11 @@ type ipfsBlockPutResp struct {
 	Size int
 }
 
+type ipfsObjectStatResp struct {
+	Hash           string
+	CumulativeSize uint64
+}
+
 type ipfsPeer struct {
 	Peer string
 }
@@ -178,6 +187,8 @@ func NewConnector(cfg *Config) (*Connector, error) {
 		nodeAddr:       nodeAddr,
 		rpcReady:       make(chan struct{}, 1),
 		reqRateLimitCh: make(chan struct{}),
+		fetchThrottle:  newFetchThrottle(cfg.PinFetchLimit),
+		inFlight:       newInFlightPins(),
 		client:         c,
 	}
 
@@ -417,6 +428,9 @@ func (ipfs *Connector) Pin(ctx context.Context, pin api.Pin) error {
 
 	defer ipfs.updateInformerMetric(ctx)
 
+	ipfs.inFlight.start(hash)
+	defer ipfs.inFlight.done(hash)
+
 	ctx, cancelRequest := context.WithCancel(ctx)
 	defer cancelRequest()
 
@@ -457,17 +471,48 @@ func (ipfs *Connector) Pin(ctx context.Context, pin api.Pin) error {
 		}
 	}
 
-	// Pin request and timeout if there is no progress
+	// The size of the DAG is needed beforehand to scale the timeout.
+	// Otherwise it is only reported with the progress.
+	var timeout time.Duration
+	if ipfs.config.PinThroughputFloor > 0 {
+		timeout = ipfs.pinTimeout(ctx, hash)
+	} else {
+		go ipfs.recordDagSize(ctx, hash)
+	}
+
+	// Wait until the pins being fetched leave room for this one.
+	if inFlight := ipfs.fetchThrottle.bytesInFlight(); ipfs.config.PinFetchLimit > 0 && inFlight >= ipfs.config.PinFetchLimit {
+		logger.Debugf("pin %s waiting: %d bytes being fetched by other pins", hash, inFlight)
+	}
+	reservation, err := ipfs.fetchThrottle.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer reservation.release()
+
+	// Pins with a known size must finish before their timeout, which
+	// does not include waiting for the throttle. Otherwise, timeout if
+	// there is no progress.
+	var noProgress <-chan time.Time
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	} else {
+		ticker := time.NewTicker(ipfs.config.PinTimeout)
+		defer ticker.Stop()
+		noProgress = ticker.C
+	}
+
+	// Pin request
 	outPins := make(chan int)
 	go func() {
 		var lastProgress int
 		lastProgressTime := time.Now()
 
-		ticker := time.NewTicker(ipfs.config.PinTimeout)
-		defer ticker.Stop()
 		for {
 			select {
-			case <-ticker.C:
+			case <-noProgress:
 				if time.Since(lastProgressTime) > ipfs.config.PinTimeout {
 					// timeout request
 					cancelRequest()
@@ -481,6 +526,8 @@ func (ipfs *Connector) Pin(ctx context.Context, pin api.Pin) error {
 				if p > lastProgress {
 					lastProgress = p
 					lastProgressTime = time.Now()
+					reservation.progress(p)
+					ipfs.inFlight.setBlocks(hash, p)
 				}
 			case <-ctx.Done():
 				return
@@ -492,6 +539,9 @@ func (ipfs *Connector) Pin(ctx context.Context, pin api.Pin) error {
 	err = ipfs.pinProgress(ctx, hash, maxDepth, outPins)
 	if err != nil {
 		stats.Record(ipfs.ctx, observations.PinsPinAddError.M(1))
+		if timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("pin timed out after %s: %w", timeout, err)
+		}
 		return err
 	}
 	totalPins := atomic.AddInt64(&ipfs.ipfsPinCount, 1)
@@ -501,6 +551,58 @@ func (ipfs *Connector) Pin(ctx context.Context, pin api.Pin) error {
 	return nil
 }
 
+// pinTimeout returns how long pinning the given cid may take when
+// PinThroughputFloor is set: PinTimeout plus the time that fetching the
+// whole DAG takes at that speed. It returns 0 when the floor is not set or
+// the size of the DAG cannot be obtained.
+func (ipfs *Connector) pinTimeout(ctx context.Context, hash api.Cid) time.Duration {
+	floor := ipfs.config.PinThroughputFloor
+	if floor == 0 {
+		return 0
+	}
+
+	size, err := ipfs.recordDagSize(ctx, hash)
+	if err != nil {
+		logger.Warnf("cannot obtain the size of %s, using the no-progress timeout: %s", hash, err)
+		return 0
+	}
+	timeout := scaledTimeout(ipfs.config.PinTimeout, size, floor)
+	logger.Debugf("pin %s: %d bytes, timeout %s", hash, size, timeout)
+	return timeout
+}
+
+// scaledTimeout returns base plus the time that transferring size bytes
+// takes at the given bytes per second.
+func scaledTimeout(base time.Duration, size, bytesPerSecond uint64) time.Duration {
+	transfer := float64(size) / float64(bytesPerSecond) * float64(time.Second)
+	if transfer >= float64(math.MaxInt64-base) {
+		return math.MaxInt64
+	}
+	return base + time.Duration(transfer)
+}
+
+// dagSize returns the cumulative size of the DAG as reported by
+// object/stat, which only needs the root block. Fetching it is bounded by
+// PinTimeout.
+func (ipfs *Connector) dagSize(ctx context.Context, hash api.Cid) (uint64, error) {
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/dagSize")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, ipfs.config.PinTimeout)
+	defer cancel()
+	res, err := ipfs.postCtx(ctx, "object/stat?arg="+hash.String(), "", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var stat ipfsObjectStatResp
+	err = json.Unmarshal(res, &stat)
+	if err != nil {
+		return 0, err
+	}
+	return stat.CumulativeSize, nil
+}
+
 // pinProgress pins an item and sends fetched node's progress on a
 // channel. Blocks until done or error. pinProgress will always close the out
 // channel.  pinProgress will not block on sending to the channel if it is full.
@@ -1161,6 +1263,27 @@ func (ipfs *Connector) BlockGet(ctx context.Context, c api.Cid) ([]byte, error)
 	return ipfs.postCtx(ctx, url, "", nil)
 }
 
+// BlockHas returns whether the IPFS daemon stores the block with the given
+// cid. The daemon is asked for a block/stat offline, so that the block is
+// not fetched from the network when missing.
+func (ipfs *Connector) BlockHas(ctx context.Context, c api.Cid) (bool, error) {
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/BlockHas")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+	url := "block/stat?offline=true&arg=" + c.String()
+	_, err := ipfs.postCtx(ctx, url, "", nil)
+	var ipfsErr ipfsError
+	if errors.As(err, &ipfsErr) && strings.Contains(ipfsErr.Message, "not found") {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // // FetchRefs asks IPFS to download blocks recursively to the given depth.
 // // It discards the response, but waits until it completes.
 // func (ipfs *Connector) FetchRefs(ctx context.Context, c api.Cid, maxDepth int) error {
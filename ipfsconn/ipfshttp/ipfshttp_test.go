@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"math"
+	"strings"
 	"testing"
 	"time"
 
@@ -107,6 +109,111 @@ func TestPin(t *testing.T) {
 	}
 }
 
+func TestPinThroughputFloor(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	ipfs.config.PinTimeout = 2 * time.Second
+	ipfs.config.PinThroughputFloor = 1024 * 1024
+	if to := ipfs.pinTimeout(ctx, test.Cid1); to != 12*time.Second {
+		t.Errorf("unexpected pin timeout: %s", to)
+	}
+	if to := ipfs.pinTimeout(ctx, test.ErrorCid); to != 0 {
+		t.Errorf("expected no timeout when the size is unknown: %s", to)
+	}
+
+	err := ipfs.Pin(ctx, api.PinCid(test.Cid1))
+	if err != nil {
+		t.Error("expected success pinning cid:", err)
+	}
+
+	// The slow cid makes progress, but not fast enough.
+	ipfs.config.PinThroughputFloor = 10 * 1024 * 1024
+	err = ipfs.Pin(ctx, api.PinCid(test.SlowCid1))
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Error("expected the pin to time out:", err)
+	}
+}
+
+func TestScaledTimeout(t *testing.T) {
+	if to := scaledTimeout(time.Minute, 0, 100); to != time.Minute {
+		t.Errorf("unexpected timeout: %s", to)
+	}
+	if to := scaledTimeout(time.Minute, 1000, 100); to != time.Minute+10*time.Second {
+		t.Errorf("unexpected timeout: %s", to)
+	}
+	if to := scaledTimeout(time.Minute, 1<<62, 1); to != math.MaxInt64 {
+		t.Errorf("expected the timeout to saturate: %s", to)
+	}
+}
+
+func TestPinFetchThrottle(t *testing.T) {
+	ctx := context.Background()
+	ft := newFetchThrottle(4 * estimatedBlockSize)
+
+	r1, err := ft.acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := ft.acquire(ctx)
+	if err != nil {
+		t.Fatal("expected room for a second pin:", err)
+	}
+	r1.progress(3)
+	r1.progress(2) // progress does not go back
+	if b := ft.bytesInFlight(); b != 4*estimatedBlockSize {
+		t.Fatalf("unexpected bytes in flight: %d", b)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := ft.acquire(tctx); err == nil {
+		t.Fatal("expected the throttle to block new pins")
+	}
+
+	acquired := make(chan *fetchReservation)
+	go func() {
+		r, err := ft.acquire(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- r
+	}()
+	r1.release()
+	r1.release() // releasing twice is harmless
+	r3 := <-acquired
+	if b := ft.bytesInFlight(); b != 2*estimatedBlockSize {
+		t.Errorf("unexpected bytes in flight: %d", b)
+	}
+	r2.release()
+	r3.release()
+	r3.progress(10) // no effect after release
+	if b := ft.bytesInFlight(); b != 0 {
+		t.Errorf("unexpected bytes in flight: %d", b)
+	}
+}
+
+func TestPinWithFetchLimit(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	ipfs.config.PinFetchLimit = 1
+	ipfs.fetchThrottle = newFetchThrottle(1)
+
+	for _, c := range []api.Cid{test.Cid1, test.Cid2} {
+		if err := ipfs.Pin(ctx, api.PinCid(c)); err != nil {
+			t.Error("expected success pinning cid:", err)
+		}
+	}
+	if b := ipfs.fetchThrottle.bytesInFlight(); b != 0 {
+		t.Errorf("expected finished pins to release their bytes: %d", b)
+	}
+}
+
 func TestPinUpdate(t *testing.T) {
 	ctx := context.Background()
 	ipfs, mock := testIPFSConnector(t)
@@ -387,6 +494,40 @@ func TestBlockGet(t *testing.T) {
 	}
 }
 
+func TestBlockHas(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	ok, err := ipfs.BlockHas(ctx, test.ShardCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("the block should not be stored before putting it")
+	}
+
+	blocks := make(chan api.NodeWithMeta, 1)
+	blocks <- api.NodeWithMeta{
+		Data: test.ShardData,
+		Cid:  test.ShardCid,
+	}
+	close(blocks)
+	err = ipfs.BlockStream(ctx, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err = ipfs.BlockHas(ctx, test.ShardCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("the block should be stored after putting it")
+	}
+}
+
 func TestRepoStat(t *testing.T) {
 	ctx := context.Background()
 	ipfs, mock := testIPFSConnector(t)
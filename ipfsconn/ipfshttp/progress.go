@@ -0,0 +1,82 @@
+package ipfshttp
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+)
+
+// inFlightPins keeps the progress of the ongoing pins, as reported by IPFS
+// while pinning, so that the pintracker can show it.
+type inFlightPins struct {
+	mu   sync.Mutex
+	pins map[api.Cid]api.PinProgress
+}
+
+func newInFlightPins() *inFlightPins {
+	return &inFlightPins{
+		pins: make(map[api.Cid]api.PinProgress),
+	}
+}
+
+func (fp *inFlightPins) start(c api.Cid) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	fp.pins[c] = api.PinProgress{}
+}
+
+func (fp *inFlightPins) done(c api.Cid) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	delete(fp.pins, c)
+}
+
+func (fp *inFlightPins) get(c api.Cid) api.PinProgress {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	return fp.pins[c]
+}
+
+// update changes the progress of an ongoing pin with f. Estimated bytes do
+// not go over the size of the DAG.
+func (fp *inFlightPins) update(c api.Cid, f func(*api.PinProgress)) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	p, ok := fp.pins[c]
+	if !ok {
+		return
+	}
+	f(&p)
+	p.Bytes = uint64(p.Blocks) * estimatedBlockSize
+	if p.Size > 0 && p.Bytes > p.Size {
+		p.Bytes = p.Size
+	}
+	fp.pins[c] = p
+}
+
+func (fp *inFlightPins) setBlocks(c api.Cid, blocks int) {
+	fp.update(c, func(p *api.PinProgress) { p.Blocks = blocks })
+}
+
+func (fp *inFlightPins) setSize(c api.Cid, size uint64) {
+	fp.update(c, func(p *api.PinProgress) { p.Size = size })
+}
+
+// PinProgress returns the progress of an ongoing pin. It is empty when the
+// Cid is not being pinned.
+func (ipfs *Connector) PinProgress(ctx context.Context, c api.Cid) (api.PinProgress, error) {
+	return ipfs.inFlight.get(c), nil
+}
+
+// recordDagSize obtains the size of the DAG of an ongoing pin and sets it
+// in its progress.
+func (ipfs *Connector) recordDagSize(ctx context.Context, hash api.Cid) (uint64, error) {
+	size, err := ipfs.dagSize(ctx, hash)
+	if err != nil {
+		return 0, err
+	}
+	ipfs.inFlight.setSize(hash, size)
+	return size, nil
+}
@@ -0,0 +1,64 @@
+package ipfshttp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/test"
+)
+
+func TestInFlightPins(t *testing.T) {
+	fp := newInFlightPins()
+	fp.setBlocks(test.Cid1, 1) // not in flight
+	if p := fp.get(test.Cid1); p != (api.PinProgress{}) {
+		t.Errorf("unexpected progress: %+v", p)
+	}
+
+	fp.start(test.Cid1)
+	fp.setBlocks(test.Cid1, 3)
+	if p := fp.get(test.Cid1); p.Blocks != 3 || p.Bytes != 3*estimatedBlockSize {
+		t.Errorf("unexpected progress: %+v", p)
+	}
+	fp.setSize(test.Cid1, estimatedBlockSize)
+	if p := fp.get(test.Cid1); p.Size != estimatedBlockSize || p.Bytes != estimatedBlockSize {
+		t.Errorf("estimated bytes should not go over the size: %+v", p)
+	}
+
+	fp.done(test.Cid1)
+	if p := fp.get(test.Cid1); p != (api.PinProgress{}) {
+		t.Errorf("unexpected progress after finishing: %+v", p)
+	}
+}
+
+func TestPinProgress(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	pinCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ipfs.Pin(pinCtx, api.PinCid(test.SlowCid1))
+	}()
+
+	// The mock does not flush the pin progress, so only the size is known.
+	time.Sleep(500 * time.Millisecond)
+	p, err := ipfs.PinProgress(ctx, test.SlowCid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Size != test.IpfsDagSize {
+		t.Errorf("expected the size of the DAG: %+v", p)
+	}
+
+	cancel()
+	<-done
+	p, _ = ipfs.PinProgress(ctx, test.SlowCid1)
+	if p != (api.PinProgress{}) {
+		t.Errorf("unexpected progress after the pin: %+v", p)
+	}
+}
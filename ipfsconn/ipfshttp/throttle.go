@@ -0,0 +1,94 @@
+package ipfshttp
+
+import (
+	"context"
+	"sync"
+)
+
+// estimatedBlockSize is used to estimate how many bytes a pin has fetched
+// from the number of nodes reported by IPFS. It is the default chunk size
+// of UnixFS DAGs.
+const estimatedBlockSize = 256 * 1024
+
+// fetchThrottle limits the bytes that are being fetched by ongoing pins.
+// IPFS does not report the size of what it fetches while pinning, so the
+// bytes of every pin are estimated from the number of nodes fetched so
+// far. New pins wait until the estimated bytes of the ongoing pins are
+// under the limit. A pin can always start when no other pin is ongoing.
+type fetchThrottle struct {
+	limit uint64
+
+	mu       sync.Mutex
+	inFlight uint64
+	// closed and replaced every time bytes are released.
+	released chan struct{}
+}
+
+func newFetchThrottle(limit uint64) *fetchThrottle {
+	return &fetchThrottle{
+		limit:    limit,
+		released: make(chan struct{}),
+	}
+}
+
+// fetchReservation tracks the estimated bytes fetched by a pin.
+type fetchReservation struct {
+	ft       *fetchThrottle
+	bytes    uint64
+	released bool
+}
+
+// acquire blocks until a new pin can start fetching, or the context is
+// done. The reservation must be released when the pin finishes.
+func (ft *fetchThrottle) acquire(ctx context.Context) (*fetchReservation, error) {
+	for {
+		ft.mu.Lock()
+		if ft.limit == 0 || ft.inFlight < ft.limit {
+			ft.inFlight += estimatedBlockSize
+			ft.mu.Unlock()
+			return &fetchReservation{ft: ft, bytes: estimatedBlockSize}, nil
+		}
+		released := ft.released
+		ft.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+// bytesInFlight returns the estimated bytes being fetched by ongoing pins.
+func (ft *fetchThrottle) bytesInFlight() uint64 {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	return ft.inFlight
+}
+
+// progress updates the estimated bytes of the pin with the number of nodes
+// that IPFS reports as fetched.
+func (r *fetchReservation) progress(nodes int) {
+	bytes := uint64(nodes) * estimatedBlockSize
+	r.ft.mu.Lock()
+	defer r.ft.mu.Unlock()
+	if !r.released && bytes > r.bytes {
+		r.ft.inFlight += bytes - r.bytes
+		r.bytes = bytes
+	}
+}
+
+// release returns the bytes of the pin and wakes up the pins waiting to
+// start.
+func (r *fetchReservation) release() {
+	r.ft.mu.Lock()
+	defer r.ft.mu.Unlock()
+	if r.released {
+		return
+	}
+	r.released = true
+	r.ft.inFlight -= r.bytes
+	r.bytes = 0
+	close(r.ft.released)
+	r.ft.released = make(chan struct{})
+}
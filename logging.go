@@ -9,29 +9,31 @@ var logger = logging.Logger("cluster")
 // LoggingFacilities provides a list of logging identifiers
 // used by cluster and their default logging level.
 var LoggingFacilities = map[string]string{
-	"cluster":      "INFO",
-	"restapi":      "INFO",
-	"restapilog":   "INFO",
-	"pinsvcapi":    "INFO",
-	"pinsvcapilog": "INFO",
-	"ipfsproxy":    "INFO",
-	"ipfsproxylog": "INFO",
-	"ipfshttp":     "INFO",
-	"monitor":      "INFO",
-	"dsstate":      "INFO",
-	"raft":         "INFO",
-	"crdt":         "INFO",
-	"pintracker":   "INFO",
-	"diskinfo":     "INFO",
-	"tags":         "INFO",
-	"apitypes":     "INFO",
-	"config":       "INFO",
-	"shardingdags": "INFO",
-	"singledags":   "INFO",
-	"adder":        "INFO",
-	"optracker":    "INFO",
-	"pstoremgr":    "INFO",
-	"allocator":    "INFO",
+	"cluster":       "INFO",
+	"restapi":       "INFO",
+	"restapilog":    "INFO",
+	"pinsvcapi":     "INFO",
+	"pinsvcapilog":  "INFO",
+	"graphqlapi":    "INFO",
+	"graphqlapilog": "INFO",
+	"ipfsproxy":     "INFO",
+	"ipfsproxylog":  "INFO",
+	"ipfshttp":      "INFO",
+	"monitor":       "INFO",
+	"dsstate":       "INFO",
+	"raft":          "INFO",
+	"crdt":          "INFO",
+	"pintracker":    "INFO",
+	"diskinfo":      "INFO",
+	"tags":          "INFO",
+	"apitypes":      "INFO",
+	"config":        "INFO",
+	"shardingdags":  "INFO",
+	"singledags":    "INFO",
+	"adder":         "INFO",
+	"optracker":     "INFO",
+	"pstoremgr":     "INFO",
+	"allocator":     "INFO",
 }
 
 // LoggingFacilitiesExtra provides logging identifiers
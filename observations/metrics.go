@@ -27,6 +27,11 @@ var (
 var (
 	HostKey       = makeKey("host")
 	RemotePeerKey = makeKey("remote_peer")
+
+	// These keys tag the metrics of the HTTP API components.
+	APIKey        = makeKey("api")
+	RouteKey      = makeKey("route")
+	StatusCodeKey = makeKey("status_code")
 )
 
 // metrics
@@ -39,6 +44,14 @@ var (
 	PinsPinning  = stats.Int64("pins/pinning", "Current number of pins currently pinning", stats.UnitDimensionless)
 	PinsPinError = stats.Int64("pins/pin_error", "Current number of pins in pin_error state", stats.UnitDimensionless)
 
+	// This metric is managed by the expiration reaper in the main
+	// cluster component.
+	PinsExpired = stats.Int64("pins/expired", "Total number of expired pins unpinned", stats.UnitDimensionless)
+
+	// These metrics are managed by the pinsvcapi module.
+	PinsStuckQueued = stats.Int64("pins/stuck_queued", "Current number of pins queued for longer than expected", stats.UnitDimensionless)
+	ListPinsSize    = stats.Int64("pinsvcapi/list_pins_size", "Size of listPins responses in bytes", stats.UnitBytes)
+
 	// These metrics and managed in the ipfshttp module.
 	PinsIpfsPins    = stats.Int64("pins/ipfs_pins", "Current number of items pinned on IPFS", stats.UnitDimensionless)
 	PinsPinAdd      = stats.Int64("pins/pin_add", "Total number of IPFS pin requests", stats.UnitDimensionless)
@@ -49,7 +62,25 @@ var (
 	BlocksAdded      = stats.Int64("blocks/added", "Total number of blocks added", stats.UnitDimensionless)
 	BlocksAddedError = stats.Int64("blocks/put_errors", "Total number of block/put errors", stats.UnitDimensionless)
 
+	// These metrics are managed by the main cluster component when
+	// storage quotas are configured.
+	QuotaUsedBytes = stats.Int64("quota/used_bytes", "Current size of the IPFS repository counted against the quota", stats.UnitBytes)
+	QuotaUsedPins  = stats.Int64("quota/used_pins", "Current number of pins allocated to the peer counted against the quota", stats.UnitDimensionless)
+
 	InformerDisk = stats.Int64("informer/disk", "The metric value weight issued by disk informer", stats.UnitDimensionless)
+
+	// These metrics are managed by the consensus components, to show
+	// when the shared state is falling behind.
+	CrdtQueuedOps        = stats.Int64("consensus/crdt_queued_ops", "Current number of CRDT operations waiting to be batched or processed", stats.UnitDimensionless)
+	CrdtDAGHeight        = stats.Int64("consensus/crdt_dag_height", "Current height of the highest CRDT DAG head", stats.UnitDimensionless)
+	CrdtBroadcastLatency = stats.Float64("consensus/crdt_broadcast_latency", "Latency of CRDT broadcasts in milliseconds", stats.UnitMilliseconds)
+	RaftCommitLatency    = stats.Float64("consensus/raft_commit_latency", "Latency of Raft commits in milliseconds", stats.UnitMilliseconds)
+	RaftLeaderChanges    = stats.Int64("consensus/raft_leader_changes", "Total number of Raft leadership changes observed", stats.UnitDimensionless)
+
+	// These metrics are managed by the api/common module for every API
+	// route.
+	APIRequestLatency   = stats.Float64("api/request_latency", "Latency of API requests in milliseconds", stats.UnitMilliseconds)
+	APIRequestsInFlight = stats.Int64("api/requests_in_flight", "Current number of API requests being served", stats.UnitDimensionless)
 )
 
 // views, which is just the aggregation of the metrics
@@ -81,6 +112,16 @@ var (
 		Aggregation: view.LastValue(),
 	}
 
+	PinsStuckQueuedView = &view.View{
+		Measure:     PinsStuckQueued,
+		Aggregation: view.LastValue(),
+	}
+
+	ListPinsSizeView = &view.View{
+		Measure:     ListPinsSize,
+		Aggregation: view.Distribution(0, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216, 67108864),
+	}
+
 	PinsIpfsPinsView = &view.View{
 		Measure:     PinsIpfsPins,
 		Aggregation: view.LastValue(),
@@ -116,16 +157,79 @@ var (
 		Aggregation: view.Sum(),
 	}
 
+	PinsExpiredView = &view.View{
+		Measure:     PinsExpired,
+		Aggregation: view.Sum(),
+	}
+
+	QuotaUsedBytesView = &view.View{
+		Measure:     QuotaUsedBytes,
+		Aggregation: view.LastValue(),
+	}
+
+	QuotaUsedPinsView = &view.View{
+		Measure:     QuotaUsedPins,
+		Aggregation: view.LastValue(),
+	}
+
 	InformerDiskView = &view.View{
 		Measure:     InformerDisk,
 		Aggregation: view.LastValue(),
 	}
 
+	CrdtQueuedOpsView = &view.View{
+		Measure:     CrdtQueuedOps,
+		Aggregation: view.LastValue(),
+	}
+
+	CrdtDAGHeightView = &view.View{
+		Measure:     CrdtDAGHeight,
+		Aggregation: view.LastValue(),
+	}
+
+	CrdtBroadcastLatencyView = &view.View{
+		Measure:     CrdtBroadcastLatency,
+		Aggregation: view.Distribution(0, 1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000),
+	}
+
+	RaftCommitLatencyView = &view.View{
+		Measure:     RaftCommitLatency,
+		Aggregation: view.Distribution(0, 1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000),
+	}
+
+	RaftLeaderChangesView = &view.View{
+		Measure:     RaftLeaderChanges,
+		Aggregation: view.Sum(),
+	}
+
+	APIRequestsView = &view.View{
+		Name:        "api/requests",
+		Description: "Total number of API requests",
+		Measure:     APIRequestLatency,
+		TagKeys:     []tag.Key{APIKey, RouteKey, StatusCodeKey},
+		Aggregation: view.Count(),
+	}
+
+	APIRequestLatencyView = &view.View{
+		Measure:     APIRequestLatency,
+		TagKeys:     []tag.Key{APIKey, RouteKey, StatusCodeKey},
+		Aggregation: view.Distribution(0, 1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000),
+	}
+
+	APIRequestsInFlightView = &view.View{
+		Measure:     APIRequestsInFlight,
+		TagKeys:     []tag.Key{APIKey, RouteKey},
+		Aggregation: view.LastValue(),
+	}
+
 	DefaultViews = []*view.View{
 		PinsView,
 		PinsQueuedView,
 		PinsPinningView,
 		PinsPinErrorView,
+		PinsExpiredView,
+		PinsStuckQueuedView,
+		ListPinsSizeView,
 		PinsIpfsPinsView,
 		PinsPinAddView,
 		PinsPinAddErrorView,
@@ -133,7 +237,17 @@ var (
 		BlocksAddedSizeView,
 		BlocksAddedView,
 		BlocksAddedErrorView,
+		QuotaUsedBytesView,
+		QuotaUsedPinsView,
 		InformerDiskView,
+		CrdtQueuedOpsView,
+		CrdtDAGHeightView,
+		CrdtBroadcastLatencyView,
+		RaftCommitLatencyView,
+		RaftLeaderChangesView,
+		APIRequestsView,
+		APIRequestLatencyView,
+		APIRequestsInFlightView,
 	}
 )
 
@@ -67,6 +67,9 @@ type Operation struct {
 	priority     bool
 	error        string
 	ts           time.Time
+	nextRetry    time.Time
+	permanent    bool
+	progress     api.PinProgress
 }
 
 // newOperation creates a new Operation.
@@ -147,6 +150,7 @@ func (op *Operation) SetPhase(ph Phase) {
 		op.tracker.recordMetricUnsafe(op, 1)
 	}
 	op.mu.Unlock()
+	op.tracker.notifyStatus(op)
 }
 
 // AttemptCount returns the number of times that this operation has been in
@@ -184,6 +188,65 @@ func (op *Operation) SetPriorityPin(p bool) {
 	op.mu.Unlock()
 }
 
+// NextRetry returns when the operation is scheduled to be retried after
+// an error. It is zero when no retry is scheduled.
+func (op *Operation) NextRetry() time.Time {
+	var t time.Time
+	op.mu.RLock()
+	t = op.nextRetry
+	op.mu.RUnlock()
+	return t
+}
+
+// SetNextRetry sets when the operation should be retried.
+func (op *Operation) SetNextRetry(t time.Time) {
+	op.mu.Lock()
+	op.nextRetry = t
+	op.mu.Unlock()
+}
+
+// Permanent returns true when the operation failed and is not retried
+// automatically anymore.
+func (op *Operation) Permanent() bool {
+	var p bool
+	op.mu.RLock()
+	p = op.permanent
+	op.mu.RUnlock()
+	return p
+}
+
+// SetPermanent marks a failed operation as not retried automatically
+// anymore. Failed pin operations are then reported with the
+// TrackerStatusPinErrorPermanent status.
+func (op *Operation) SetPermanent() {
+	op.mu.Lock()
+	op.permanent = true
+	op.mu.Unlock()
+	op.tracker.notifyStatus(op)
+}
+
+// Progress returns the progress of a pin operation in progress, or nil
+// when there is none.
+func (op *Operation) Progress() *api.PinProgress {
+	op.mu.RLock()
+	defer op.mu.RUnlock()
+	if op.opType != OperationPin || op.phase != PhaseInProgress {
+		return nil
+	}
+	if op.progress == (api.PinProgress{}) {
+		return nil
+	}
+	p := op.progress
+	return &p
+}
+
+// SetProgress sets the progress of a pin operation.
+func (op *Operation) SetProgress(p api.PinProgress) {
+	op.mu.Lock()
+	op.progress = p
+	op.mu.Unlock()
+}
+
 // Error returns any error message attached to the operation.
 func (op *Operation) Error() string {
 	var err string
@@ -205,6 +268,7 @@ func (op *Operation) SetError(err error) {
 		op.tracker.recordMetricUnsafe(op, 1)
 	}
 	op.mu.Unlock()
+	op.tracker.notifyStatus(op)
 }
 
 // Type returns the operation Type.
@@ -214,9 +278,21 @@ func (op *Operation) Type() OperationType {
 
 // Pin returns the Pin object associated to the operation.
 func (op *Operation) Pin() api.Pin {
+	op.mu.RLock()
+	defer op.mu.RUnlock()
 	return op.pin
 }
 
+// setPinInfo sets the name, group and metadata of the pin associated to
+// the operation.
+func (op *Operation) setPinInfo(pin api.Pin) {
+	op.mu.Lock()
+	op.pin.Name = pin.Name
+	op.pin.Group = pin.Group
+	op.pin.Metadata = pin.Metadata
+	op.mu.Unlock()
+}
+
 // Timestamp returns the time when this operation was
 // last modified (phase changed, error was set...).
 func (op *Operation) Timestamp() time.Time {
@@ -248,6 +324,9 @@ func (op *Operation) ToTrackerStatus() api.TrackerStatus {
 	case OperationPin:
 		switch ph {
 		case PhaseError:
+			if op.Permanent() {
+				return api.TrackerStatusPinErrorPermanent
+			}
 			return api.TrackerStatusPinError
 		case PhaseQueued:
 			return api.TrackerStatusPinQueued
@@ -285,7 +364,7 @@ func (op *Operation) ToTrackerStatus() api.TrackerStatus {
 // converts it to an OpType and Phase.
 func TrackerStatusToOperationPhase(status api.TrackerStatus) (OperationType, Phase) {
 	switch status {
-	case api.TrackerStatusPinError:
+	case api.TrackerStatusPinError, api.TrackerStatusPinErrorPermanent:
 		return OperationPin, PhaseError
 	case api.TrackerStatusPinQueued:
 		return OperationPin, PhaseQueued
@@ -51,3 +51,38 @@ func TestOperation(t *testing.T) {
 		t.Error("should be in unpin error")
 	}
 }
+
+func TestOperationPermanent(t *testing.T) {
+	op := newOperation(context.Background(), api.PinCid(test.Cid1), OperationPin, PhaseInProgress, nil)
+	op.SetError(errors.New("fake error"))
+	if op.ToTrackerStatus() != api.TrackerStatusPinError {
+		t.Error("expected pin_error")
+	}
+
+	op.SetPermanent()
+	if !op.Permanent() || op.ToTrackerStatus() != api.TrackerStatusPinErrorPermanent {
+		t.Error("expected pin_error_permanent")
+	}
+	if !op.ToTrackerStatus().Match(api.TrackerStatusError) {
+		t.Error("permanent errors should match the error filter")
+	}
+}
+
+func TestOperationProgress(t *testing.T) {
+	op := newOperation(context.Background(), api.PinCid(test.Cid1), OperationPin, PhaseQueued, nil)
+	progress := api.PinProgress{Blocks: 2, Bytes: 512, Size: 1024}
+	op.SetProgress(progress)
+	if op.Progress() != nil {
+		t.Error("queued operations should not report progress")
+	}
+
+	op.SetPhase(PhaseInProgress)
+	if p := op.Progress(); p == nil || *p != progress {
+		t.Errorf("unexpected progress: %v", p)
+	}
+
+	op.SetPhase(PhaseDone)
+	if op.Progress() != nil {
+		t.Error("finished operations should not report progress")
+	}
+}
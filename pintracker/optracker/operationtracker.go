@@ -38,8 +38,14 @@ type OperationTracker struct {
 
 	mu         sync.RWMutex
 	operations map[api.Cid]*Operation
+
+	statusChanges chan api.PinInfo
 }
 
+// statusChangesSize is the size of the buffer of the StatusChanges channel.
+// Changes are dropped when it is full.
+const statusChangesSize = 1024
+
 func (opt *OperationTracker) String() string {
 	var b strings.Builder
 	fmt.Fprintf(&b, "pid: %v\n", opt.pid)
@@ -67,6 +73,42 @@ func NewOperationTracker(ctx context.Context, pid peer.ID, peerName string) *Ope
 		pid:        pid,
 		peerName:   peerName,
 		operations: make(map[api.Cid]*Operation),
+
+		statusChanges: make(chan api.PinInfo, statusChangesSize),
+	}
+}
+
+// StatusChanges returns a channel on which the status of pin and unpin
+// operations is sent every time that it changes.
+func (opt *OperationTracker) StatusChanges() <-chan api.PinInfo {
+	return opt.statusChanges
+}
+
+// notifyStatus sends the current status of the operation to the
+// StatusChanges channel, unless it is full.
+func (opt *OperationTracker) notifyStatus(op *Operation) {
+	if opt == nil || op == nil {
+		return
+	}
+	if typ := op.Type(); typ != OperationPin && typ != OperationUnpin {
+		return
+	}
+
+	pi := api.PinInfo{
+		Cid:  op.Cid(),
+		Name: op.Pin().Name,
+		Peer: opt.pid,
+		PinInfoShort: api.PinInfoShort{
+			PeerName: opt.peerName,
+			Status:   op.ToTrackerStatus(),
+			TS:       op.Timestamp(),
+			Error:    op.Error(),
+		},
+	}
+	select {
+	case opt.statusChanges <- pi:
+	default:
+		logger.Warnf("status changes channel full: dropping status of %s", pi.Cid)
 	}
 }
 
@@ -105,9 +147,35 @@ func (opt *OperationTracker) TrackNewOperation(ctx context.Context, pin api.Pin,
 	logger.Debugf("'%s' on cid '%s' has been created with phase '%s'", typ, pin.Cid, ph)
 	opt.operations[pin.Cid] = op2
 	opt.recordMetricUnsafe(op2, 1)
+	opt.notifyStatus(op2)
 	return op2
 }
 
+// UpdatePin sets the name, group and metadata of the given pin in the
+// ongoing operation of the given type for its cid. It returns false, doing
+// nothing, when there is no such operation or when the pin differs from the
+// operation's in anything else.
+func (opt *OperationTracker) UpdatePin(ctx context.Context, pin api.Pin, typ OperationType) bool {
+	opt.mu.RLock()
+	defer opt.mu.RUnlock()
+
+	op, ok := opt.operations[pin.Cid]
+	if !ok || op.Type() != typ || op.Phase() == PhaseError || op.Phase() == PhaseDone {
+		return false
+	}
+
+	edited := op.Pin()
+	edited.Name = pin.Name
+	edited.Group = pin.Group
+	edited.Metadata = pin.Metadata
+	if !edited.Equals(pin) {
+		return false
+	}
+	op.setPinInfo(pin)
+	opt.notifyStatus(op)
+	return true
+}
+
 // Clean deletes an operation from the tracker if it is the one we are tracking
 // (compares pointers).
 func (opt *OperationTracker) Clean(ctx context.Context, op *Operation) {
@@ -184,6 +252,7 @@ func (opt *OperationTracker) unsafePinInfo(ctx context.Context, op *Operation, i
 		Origins:     op.Pin().Origins,
 		Created:     op.Pin().Timestamp,
 		Metadata:    op.Pin().Metadata,
+		Group:       op.Pin().Group,
 		PinInfoShort: api.PinInfoShort{
 			PeerName:      opt.peerName,
 			IPFS:          ipfs.ID,
@@ -193,6 +262,8 @@ func (opt *OperationTracker) unsafePinInfo(ctx context.Context, op *Operation, i
 			AttemptCount:  op.AttemptCount(),
 			PriorityPin:   op.PriorityPin(),
 			Error:         op.Error(),
+			NextRetry:     op.NextRetry(),
+			Progress:      op.Progress(),
 		},
 	}
 }
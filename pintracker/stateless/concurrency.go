@@ -0,0 +1,182 @@
+package stateless
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+)
+
+// concurrencyAdjustInterval is how often the latency of the IPFS daemon is
+// measured to adjust the number of concurrent pins, when AutoConcurrency
+// is enabled.
+var concurrencyAdjustInterval = 10 * time.Second
+
+// errInvalidConcurrency is returned when setting a negative concurrency.
+var errInvalidConcurrency = errors.New("concurrency cannot be negative")
+
+// workerPool runs a number of workers which can be changed at any time.
+// Workers finish the operation in progress before stopping.
+type workerPool struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	cancels []context.CancelFunc
+	run     func(ctx context.Context)
+}
+
+func newWorkerPool(ctx context.Context, n int, run func(ctx context.Context)) *workerPool {
+	wp := &workerPool{
+		ctx: ctx,
+		run: run,
+	}
+	wp.resize(n)
+	return wp
+}
+
+// resize starts or stops workers until there are n of them.
+func (wp *workerPool) resize(n int) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	for len(wp.cancels) < n {
+		ctx, cancel := context.WithCancel(wp.ctx)
+		wp.cancels = append(wp.cancels, cancel)
+		go wp.run(ctx)
+	}
+	for len(wp.cancels) > n {
+		last := len(wp.cancels) - 1
+		wp.cancels[last]()
+		wp.cancels = wp.cancels[:last]
+	}
+}
+
+func (wp *workerPool) size() int {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	return len(wp.cancels)
+}
+
+// Concurrency returns the number of pin and unpin operations that this
+// tracker runs in parallel.
+func (spt *Tracker) Concurrency(ctx context.Context) api.PinTrackerConcurrency {
+	spt.concurrencyMu.Lock()
+	defer spt.concurrencyMu.Unlock()
+	return spt.concurrencyUnsafe()
+}
+
+func (spt *Tracker) concurrencyUnsafe() api.PinTrackerConcurrency {
+	return api.PinTrackerConcurrency{
+		Peer:     spt.peerID,
+		Peername: spt.peerName,
+		Pins:     spt.pinWorkers.size(),
+		MaxPins:  spt.maxPins,
+		Unpins:   spt.unpinWorkers.size(),
+		Auto:     spt.autoConcurrency,
+		Paused:   spt.pauses.paused(),
+	}
+}
+
+// SetConcurrency changes the number of pin and unpin operations that this
+// tracker runs in parallel, until the peer restarts, and enables or
+// disables the automatic adjustment of the number of pins. Lowering it does
+// not interrupt the operations in progress.
+func (spt *Tracker) SetConcurrency(ctx context.Context, conc api.PinTrackerConcurrency) (api.PinTrackerConcurrency, error) {
+	if conc.Pins < 0 || conc.Unpins < 0 {
+		return api.PinTrackerConcurrency{}, errInvalidConcurrency
+	}
+
+	spt.concurrencyMu.Lock()
+	defer spt.concurrencyMu.Unlock()
+
+	if conc.Pins > 0 {
+		spt.maxPins = conc.Pins
+		spt.pinWorkers.resize(conc.Pins)
+	}
+	if conc.Unpins > 0 {
+		spt.unpinWorkers.resize(conc.Unpins)
+	}
+	spt.autoConcurrency = conc.Auto
+
+	cur := spt.concurrencyUnsafe()
+	logger.Infof("pintracker concurrency set to %d pins, %d unpins (auto: %t)", cur.Pins, cur.Unpins, cur.Auto)
+	return cur, nil
+}
+
+// concurrencyWorker adjusts the number of concurrent pins every
+// concurrencyAdjustInterval while AutoConcurrency is enabled.
+func (spt *Tracker) concurrencyWorker() {
+	select {
+	case <-spt.ctx.Done():
+		return
+	case <-spt.rpcReady:
+	}
+
+	ticker := time.NewTicker(concurrencyAdjustInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-spt.ctx.Done():
+			return
+		case <-ticker.C:
+			spt.adjustConcurrency(spt.ctx)
+		}
+	}
+}
+
+// adjustConcurrency halves the number of concurrent pins when the IPFS
+// daemon takes longer than IPFSLatencyTarget to respond, or does not
+// respond, and increases it by one, up to the maximum, when it takes less
+// than half of it.
+func (spt *Tracker) adjustConcurrency(ctx context.Context) {
+	spt.concurrencyMu.Lock()
+	auto := spt.autoConcurrency
+	spt.concurrencyMu.Unlock()
+	if !auto {
+		return
+	}
+
+	target := spt.config.IPFSLatencyTarget
+	ctx, cancel := context.WithTimeout(ctx, 2*target)
+	defer cancel()
+
+	start := time.Now()
+	var ipfsid api.IPFSID
+	err := spt.rpcClient.CallContext(
+		ctx,
+		"",
+		"IPFSConnector",
+		"ID",
+		struct{}{},
+		&ipfsid,
+	)
+	latency := time.Since(start)
+	if err != nil {
+		logger.Debugf("error measuring the IPFS latency: %s", err)
+	}
+
+	spt.concurrencyMu.Lock()
+	defer spt.concurrencyMu.Unlock()
+	if !spt.autoConcurrency {
+		return
+	}
+
+	cur := spt.pinWorkers.size()
+	pins := cur
+	switch {
+	case err != nil || latency > target:
+		pins = cur / 2
+		if pins < 1 {
+			pins = 1
+		}
+	case latency < target/2 && cur < spt.maxPins:
+		pins = cur + 1
+	}
+	if pins == cur {
+		return
+	}
+	logger.Infof("IPFS latency is %s: adjusting concurrent pins from %d to %d", latency, cur, pins)
+	spt.pinWorkers.resize(pins)
+}
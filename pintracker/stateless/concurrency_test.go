@@ -0,0 +1,98 @@
+package stateless
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+)
+
+func TestSetConcurrency(t *testing.T) {
+	ctx := context.Background()
+	spt := testStatelessPinTracker(t)
+	defer spt.Shutdown(ctx)
+
+	conc := spt.Concurrency(ctx)
+	if conc.Pins != 1 || conc.MaxPins != 1 || conc.Unpins != 1 || conc.Auto {
+		t.Fatalf("unexpected initial concurrency: %+v", conc)
+	}
+
+	conc, err := spt.SetConcurrency(ctx, api.PinTrackerConcurrency{Pins: 4, Unpins: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conc.Pins != 4 || conc.MaxPins != 4 || conc.Unpins != 2 {
+		t.Errorf("unexpected concurrency: %+v", conc)
+	}
+
+	// Zero values are left unchanged.
+	conc, err = spt.SetConcurrency(ctx, api.PinTrackerConcurrency{Unpins: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conc.Pins != 4 || conc.Unpins != 3 {
+		t.Errorf("unexpected concurrency: %+v", conc)
+	}
+
+	_, err = spt.SetConcurrency(ctx, api.PinTrackerConcurrency{Pins: -1})
+	if err == nil {
+		t.Error("expected an error with a negative concurrency")
+	}
+
+	// Pins still work after shrinking the pool.
+	_, err = spt.SetConcurrency(ctx, api.PinTrackerConcurrency{Pins: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pin := api.PinWithOpts(pinErrCid, pinOpts)
+	if err := spt.Track(ctx, pin); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if st := spt.Status(ctx, pinErrCid); st.AttemptCount != 1 {
+		t.Errorf("expected the pin to be attempted: %+v", st)
+	}
+}
+
+func TestAdjustConcurrency(t *testing.T) {
+	ctx := context.Background()
+	spt := testStatelessPinTracker(t)
+	defer spt.Shutdown(ctx)
+
+	_, err := spt.SetConcurrency(ctx, api.PinTrackerConcurrency{Pins: 4, Auto: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The daemon responds fast and we are at the maximum.
+	spt.adjustConcurrency(ctx)
+	if n := spt.Concurrency(ctx).Pins; n != 4 {
+		t.Errorf("expected 4 pins with a low latency: %d", n)
+	}
+
+	// Every latency is over the target.
+	spt.config.IPFSLatencyTarget = time.Nanosecond
+	for _, expected := range []int{2, 1, 1} {
+		spt.adjustConcurrency(ctx)
+		if n := spt.Concurrency(ctx).Pins; n != expected {
+			t.Errorf("expected %d pins with a high latency: %d", expected, n)
+		}
+	}
+
+	spt.config.IPFSLatencyTarget = time.Second
+	spt.adjustConcurrency(ctx)
+	if n := spt.Concurrency(ctx).Pins; n != 2 {
+		t.Errorf("expected 2 pins after the latency recovers: %d", n)
+	}
+
+	_, err = spt.SetConcurrency(ctx, api.PinTrackerConcurrency{Auto: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spt.config.IPFSLatencyTarget = time.Nanosecond
+	spt.adjustConcurrency(ctx)
+	if n := spt.Concurrency(ctx).Pins; n != 2 {
+		t.Errorf("expected no adjustments when auto is disabled: %d", n)
+	}
+}
@@ -17,8 +17,18 @@ const envConfigKey = "cluster_stateless"
 const (
 	DefaultMaxPinQueueSize       = 1000000
 	DefaultConcurrentPins        = 10
+	DefaultConcurrentUnpins      = 1
+	DefaultIPFSLatencyTarget     = time.Second
 	DefaultPriorityPinMaxAge     = 24 * time.Hour
 	DefaultPriorityPinMaxRetries = 5
+	DefaultRecoverBackoffMin     = time.Minute
+	DefaultRecoverBackoffMax     = time.Hour
+	DefaultRecoverMaxAttempts    = 50
+	DefaultPriorityQueueWeight   = 6
+	DefaultRetryQueueWeight      = 3
+	DefaultBulkQueueWeight       = 1
+	DefaultVerifyInterval        = 0
+	DefaultVerifySampleSize      = 0
 )
 
 // Config allows to initialize a Monitor and customize some parameters.
@@ -29,8 +39,18 @@ type Config struct {
 	MaxPinQueueSize int
 	// ConcurrentPins specifies how many pin requests can be sent to the ipfs
 	// daemon in parallel. If the pinning method is "refs", it might increase
-	// speed. Unpin requests are always processed one by one.
+	// speed.
 	ConcurrentPins int
+	// ConcurrentUnpins specifies how many unpin requests can be sent to
+	// the ipfs daemon in parallel. Both can be changed at runtime.
+	ConcurrentUnpins int
+
+	// AutoConcurrency adjusts the number of concurrent pins, up to
+	// ConcurrentPins, to the latency of the ipfs daemon: it is halved
+	// when the daemon takes longer than IPFSLatencyTarget to respond
+	// and increased again when it takes less than half of it.
+	AutoConcurrency   bool
+	IPFSLatencyTarget time.Duration
 
 	// PriorityPinMaxAge specifies the maximum age that a pin needs to
 	// can have since it was submitted to the cluster to be pinned
@@ -40,13 +60,69 @@ type Config struct {
 	// PriorityPinMaxRetries specifies the maximum amount of retries that
 	// a pin can have before it is moved to a non-prioritary queue.
 	PriorityPinMaxRetries int
+
+	// RecoverBackoffMin is how long to wait before automatically
+	// retrying an operation that failed for the first time. The wait
+	// doubles with every failed attempt, up to RecoverBackoffMax.
+	RecoverBackoffMin time.Duration
+	RecoverBackoffMax time.Duration
+
+	// RecoverMaxAttempts is the number of attempts after which failed
+	// operations are not retried automatically anymore. They can still
+	// be recovered manually. A negative value means no limit.
+	RecoverMaxAttempts int
+
+	// PriorityQueueWeight, RetryQueueWeight and BulkQueueWeight set
+	// how often pins are taken from the priority, retry and bulk
+	// queues when all of them have items. With the defaults, six
+	// priority pins are started for every three retries and every
+	// bulk pin.
+	PriorityQueueWeight int
+	RetryQueueWeight    int
+	BulkQueueWeight     int
+
+	// VerifyInterval specifies how often the items that the ipfs daemon
+	// reports as pinned are checked to have their root block, and
+	// VerifySampleSize randomly chosen children of it, stored. Items
+	// with missing blocks are set in error. The check is disabled when
+	// VerifyInterval is 0.
+	VerifyInterval   time.Duration
+	VerifySampleSize int
+
+	// Passive makes the tracker handle every pin as a remote pin, so
+	// that nothing is pinned on this peer. It is not part of the JSON
+	// configuration: it is set from the cluster's PassiveMode.
+	Passive bool
+
+	// UnpinDelay delays the unpinning of items removed from the
+	// shared state, so that they can be pinned again without fetching
+	// them. It is not part of the JSON configuration: it is set from
+	// the cluster's UnpinGracePeriod.
+	UnpinDelay time.Duration
+
+	// ConcurrencyBudget makes pins wait for the cluster's concurrency
+	// budget before they start. It is not part of the JSON
+	// configuration: it is set when the cluster has a
+	// GlobalConcurrencyBudget.
+	ConcurrencyBudget bool
 }
 
 type jsonConfig struct {
 	MaxPinQueueSize       int    `json:"max_pin_queue_size,omitempty"`
 	ConcurrentPins        int    `json:"concurrent_pins"`
+	ConcurrentUnpins      int    `json:"concurrent_unpins"`
+	AutoConcurrency       bool   `json:"auto_concurrency"`
+	IPFSLatencyTarget     string `json:"ipfs_latency_target"`
 	PriorityPinMaxAge     string `json:"priority_pin_max_age"`
 	PriorityPinMaxRetries int    `json:"priority_pin_max_retries"`
+	RecoverBackoffMin     string `json:"recover_backoff_min"`
+	RecoverBackoffMax     string `json:"recover_backoff_max"`
+	RecoverMaxAttempts    int    `json:"recover_max_attempts"`
+	PriorityQueueWeight   int    `json:"priority_queue_weight"`
+	RetryQueueWeight      int    `json:"retry_queue_weight"`
+	BulkQueueWeight       int    `json:"bulk_queue_weight"`
+	VerifyInterval        string `json:"verify_interval"`
+	VerifySampleSize      int    `json:"verify_sample_size"`
 }
 
 // ConfigKey provides a human-friendly identifier for this type of Config.
@@ -58,8 +134,19 @@ func (cfg *Config) ConfigKey() string {
 func (cfg *Config) Default() error {
 	cfg.MaxPinQueueSize = DefaultMaxPinQueueSize
 	cfg.ConcurrentPins = DefaultConcurrentPins
+	cfg.ConcurrentUnpins = DefaultConcurrentUnpins
+	cfg.AutoConcurrency = false
+	cfg.IPFSLatencyTarget = DefaultIPFSLatencyTarget
 	cfg.PriorityPinMaxAge = DefaultPriorityPinMaxAge
 	cfg.PriorityPinMaxRetries = DefaultPriorityPinMaxRetries
+	cfg.RecoverBackoffMin = DefaultRecoverBackoffMin
+	cfg.RecoverBackoffMax = DefaultRecoverBackoffMax
+	cfg.RecoverMaxAttempts = DefaultRecoverMaxAttempts
+	cfg.PriorityQueueWeight = DefaultPriorityQueueWeight
+	cfg.RetryQueueWeight = DefaultRetryQueueWeight
+	cfg.BulkQueueWeight = DefaultBulkQueueWeight
+	cfg.VerifyInterval = DefaultVerifyInterval
+	cfg.VerifySampleSize = DefaultVerifySampleSize
 	return nil
 }
 
@@ -87,6 +174,14 @@ func (cfg *Config) Validate() error {
 		return errors.New("statelesstracker.concurrent_pins is too low")
 	}
 
+	if cfg.ConcurrentUnpins <= 0 {
+		return errors.New("statelesstracker.concurrent_unpins is too low")
+	}
+
+	if cfg.IPFSLatencyTarget <= 0 {
+		return errors.New("statelesstracker.ipfs_latency_target is too low")
+	}
+
 	if cfg.PriorityPinMaxAge <= 0 {
 		return errors.New("statelesstracker.priority_pin_max_age is too low")
 	}
@@ -95,6 +190,38 @@ func (cfg *Config) Validate() error {
 		return errors.New("statelesstracker.priority_pin_max_retries is too low")
 	}
 
+	if cfg.RecoverBackoffMin <= 0 {
+		return errors.New("statelesstracker.recover_backoff_min is too low")
+	}
+
+	if cfg.RecoverBackoffMax < cfg.RecoverBackoffMin {
+		return errors.New("statelesstracker.recover_backoff_max is lower than recover_backoff_min")
+	}
+
+	if cfg.RecoverMaxAttempts == 0 {
+		return errors.New("statelesstracker.recover_max_attempts cannot be 0")
+	}
+
+	if cfg.PriorityQueueWeight <= 0 {
+		return errors.New("statelesstracker.priority_queue_weight is too low")
+	}
+
+	if cfg.RetryQueueWeight <= 0 {
+		return errors.New("statelesstracker.retry_queue_weight is too low")
+	}
+
+	if cfg.BulkQueueWeight <= 0 {
+		return errors.New("statelesstracker.bulk_queue_weight is too low")
+	}
+
+	if cfg.VerifyInterval < 0 {
+		return errors.New("statelesstracker.verify_interval cannot be negative")
+	}
+
+	if cfg.VerifySampleSize < 0 {
+		return errors.New("statelesstracker.verify_sample_size cannot be negative")
+	}
+
 	return nil
 }
 
@@ -116,18 +243,45 @@ func (cfg *Config) LoadJSON(raw []byte) error {
 func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 	config.SetIfNotDefault(jcfg.MaxPinQueueSize, &cfg.MaxPinQueueSize)
 	config.SetIfNotDefault(jcfg.ConcurrentPins, &cfg.ConcurrentPins)
+	config.SetIfNotDefault(jcfg.ConcurrentUnpins, &cfg.ConcurrentUnpins)
+	cfg.AutoConcurrency = jcfg.AutoConcurrency
 	err := config.ParseDurations(cfg.ConfigKey(),
 		&config.DurationOpt{
 			Duration: jcfg.PriorityPinMaxAge,
 			Dst:      &cfg.PriorityPinMaxAge,
 			Name:     "priority_pin_max_age",
 		},
+		&config.DurationOpt{
+			Duration: jcfg.IPFSLatencyTarget,
+			Dst:      &cfg.IPFSLatencyTarget,
+			Name:     "ipfs_latency_target",
+		},
+		&config.DurationOpt{
+			Duration: jcfg.RecoverBackoffMin,
+			Dst:      &cfg.RecoverBackoffMin,
+			Name:     "recover_backoff_min",
+		},
+		&config.DurationOpt{
+			Duration: jcfg.RecoverBackoffMax,
+			Dst:      &cfg.RecoverBackoffMax,
+			Name:     "recover_backoff_max",
+		},
+		&config.DurationOpt{
+			Duration: jcfg.VerifyInterval,
+			Dst:      &cfg.VerifyInterval,
+			Name:     "verify_interval",
+		},
 	)
 	if err != nil {
 		return err
 	}
 
 	config.SetIfNotDefault(jcfg.PriorityPinMaxRetries, &cfg.PriorityPinMaxRetries)
+	config.SetIfNotDefault(jcfg.RecoverMaxAttempts, &cfg.RecoverMaxAttempts)
+	config.SetIfNotDefault(jcfg.PriorityQueueWeight, &cfg.PriorityQueueWeight)
+	config.SetIfNotDefault(jcfg.RetryQueueWeight, &cfg.RetryQueueWeight)
+	config.SetIfNotDefault(jcfg.BulkQueueWeight, &cfg.BulkQueueWeight)
+	config.SetIfNotDefault(jcfg.VerifySampleSize, &cfg.VerifySampleSize)
 
 	return cfg.Validate()
 }
@@ -142,8 +296,19 @@ func (cfg *Config) ToJSON() ([]byte, error) {
 func (cfg *Config) toJSONConfig() *jsonConfig {
 	jCfg := &jsonConfig{
 		ConcurrentPins:        cfg.ConcurrentPins,
+		ConcurrentUnpins:      cfg.ConcurrentUnpins,
+		AutoConcurrency:       cfg.AutoConcurrency,
+		IPFSLatencyTarget:     cfg.IPFSLatencyTarget.String(),
 		PriorityPinMaxAge:     cfg.PriorityPinMaxAge.String(),
 		PriorityPinMaxRetries: cfg.PriorityPinMaxRetries,
+		RecoverBackoffMin:     cfg.RecoverBackoffMin.String(),
+		RecoverBackoffMax:     cfg.RecoverBackoffMax.String(),
+		RecoverMaxAttempts:    cfg.RecoverMaxAttempts,
+		PriorityQueueWeight:   cfg.PriorityQueueWeight,
+		RetryQueueWeight:      cfg.RetryQueueWeight,
+		BulkQueueWeight:       cfg.BulkQueueWeight,
+		VerifyInterval:        cfg.VerifyInterval.String(),
+		VerifySampleSize:      cfg.VerifySampleSize,
 	}
 	if cfg.MaxPinQueueSize != DefaultMaxPinQueueSize {
 		jCfg.MaxPinQueueSize = cfg.MaxPinQueueSize
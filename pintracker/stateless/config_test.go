@@ -11,8 +11,19 @@ var cfgJSON = []byte(`
 {
 	"max_pin_queue_size": 4092,
 	"concurrent_pins": 2,
+	"concurrent_unpins": 3,
+	"auto_concurrency": true,
+	"ipfs_latency_target": "2s",
 	"priority_pin_max_age": "240h",
-	"priority_pin_max_retries": 4
+	"priority_pin_max_retries": 4,
+	"recover_backoff_min": "30s",
+	"recover_backoff_max": "2h",
+	"recover_max_attempts": -1,
+	"priority_queue_weight": 8,
+	"retry_queue_weight": 2,
+	"bulk_queue_weight": 1,
+	"verify_interval": "12h",
+	"verify_sample_size": 5
 }
 `)
 
@@ -43,6 +54,31 @@ func TestLoadJSON(t *testing.T) {
 	if cfg.PriorityPinMaxRetries != 2 {
 		t.Error("expected 2 max retries")
 	}
+	if cfg.RecoverBackoffMin != 30*time.Second || cfg.RecoverBackoffMax != 2*time.Hour {
+		t.Error("expected recover backoff between 30s and 2h")
+	}
+	if cfg.RecoverMaxAttempts != -1 {
+		t.Error("expected unlimited recover attempts")
+	}
+	if cfg.ConcurrentUnpins != 3 {
+		t.Error("expected 3 concurrent unpins")
+	}
+	if !cfg.AutoConcurrency || cfg.IPFSLatencyTarget != 2*time.Second {
+		t.Error("expected auto concurrency with a 2s latency target")
+	}
+	if cfg.PriorityQueueWeight != 8 || cfg.RetryQueueWeight != 2 || cfg.BulkQueueWeight != 1 {
+		t.Error("expected queue weights 8, 2 and 1")
+	}
+	if cfg.VerifyInterval != 12*time.Hour || cfg.VerifySampleSize != 5 {
+		t.Error("expected verification every 12h with 5 sampled blocks")
+	}
+
+	j.RecoverBackoffMin = "3h"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected an error with recover_backoff_min over the max")
+	}
 }
 
 func TestToJSON(t *testing.T) {
@@ -75,6 +111,26 @@ func TestDefault(t *testing.T) {
 	if cfg.Validate() == nil {
 		t.Fatal("expected error validating")
 	}
+	cfg.PriorityPinMaxRetries = 3
+	cfg.RecoverMaxAttempts = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+	cfg.RecoverMaxAttempts = 3
+	cfg.ConcurrentUnpins = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+	cfg.ConcurrentUnpins = 1
+	cfg.BulkQueueWeight = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+	cfg.BulkQueueWeight = 1
+	cfg.VerifySampleSize = -1
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
 }
 
 func TestApplyEnvVars(t *testing.T) {
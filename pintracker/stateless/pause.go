@@ -0,0 +1,103 @@
+package stateless
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+)
+
+// pauseGate stops the workers from taking new operations from the queues
+// while the tracker is paused. Operations can still be queued, and those in
+// progress finish.
+type pauseGate struct {
+	mu sync.Mutex
+	// pause is closed while paused and resume while running.
+	pause  chan struct{}
+	resume chan struct{}
+}
+
+func newPauseGate() *pauseGate {
+	g := &pauseGate{
+		pause:  make(chan struct{}),
+		resume: make(chan struct{}),
+	}
+	close(g.resume)
+	return g
+}
+
+func (g *pauseGate) pausedUnsafe() bool {
+	select {
+	case <-g.pause:
+		return true
+	default:
+		return false
+	}
+}
+
+func (g *pauseGate) paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.pausedUnsafe()
+}
+
+// set pauses or resumes. It returns false when there was nothing to do.
+func (g *pauseGate) set(paused bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.pausedUnsafe() == paused {
+		return false
+	}
+	if paused {
+		close(g.pause)
+		g.resume = make(chan struct{})
+	} else {
+		close(g.resume)
+		g.pause = make(chan struct{})
+	}
+	return true
+}
+
+// wait blocks while paused. It returns a channel which is closed when
+// pausing again, or false when the context is done first.
+func (g *pauseGate) wait(ctx context.Context) (<-chan struct{}, bool) {
+	for {
+		if ctx.Err() != nil {
+			return nil, false
+		}
+
+		g.mu.Lock()
+		pause, resume := g.pause, g.resume
+		g.mu.Unlock()
+
+		select {
+		case <-resume:
+			return pause, true
+		default:
+		}
+
+		select {
+		case <-resume:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+// Pause stops the tracker from starting new pin and unpin operations until
+// it is resumed or the peer restarts. New operations are still queued.
+func (spt *Tracker) Pause(ctx context.Context) api.PinTrackerConcurrency {
+	if spt.pauses.set(true) {
+		logger.Info("pintracker paused: no new pins or unpins will start")
+	}
+	return spt.Concurrency(ctx)
+}
+
+// Resume lets a paused tracker start operations again.
+func (spt *Tracker) Resume(ctx context.Context) api.PinTrackerConcurrency {
+	if spt.pauses.set(false) {
+		logger.Info("pintracker resumed")
+	}
+	return spt.Concurrency(ctx)
+}
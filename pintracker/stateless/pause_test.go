@@ -0,0 +1,77 @@
+package stateless
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+)
+
+func TestPauseResume(t *testing.T) {
+	ctx := context.Background()
+	spt := testStatelessPinTracker(t)
+	defer spt.Shutdown(ctx)
+
+	if conc := spt.Pause(ctx); !conc.Paused {
+		t.Fatalf("expected the tracker to be paused: %+v", conc)
+	}
+	spt.Pause(ctx) // pausing twice is harmless
+
+	pin := api.PinWithOpts(pinErrCid, pinOpts)
+	if err := spt.Track(ctx, pin); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	st := spt.Status(ctx, pinErrCid)
+	if st.Status != api.TrackerStatusPinQueued || st.AttemptCount != 0 {
+		t.Errorf("expected the pin to stay queued while paused: %+v", st)
+	}
+
+	if conc := spt.Resume(ctx); conc.Paused {
+		t.Fatalf("expected the tracker to be resumed: %+v", conc)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if st := spt.Status(ctx, pinErrCid); st.AttemptCount != 1 {
+		t.Errorf("expected the pin to be attempted after resuming: %+v", st)
+	}
+}
+
+func TestPauseGate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	g := newPauseGate()
+
+	pause, ok := g.wait(ctx)
+	if !ok {
+		t.Fatal("a running gate should not block")
+	}
+	if !g.set(true) || g.set(true) {
+		t.Fatal("set should only report changes")
+	}
+	select {
+	case <-pause:
+	default:
+		t.Fatal("pausing should close the pause channel")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, ok := g.wait(ctx); !ok {
+			t.Error("expected wait to return after resuming")
+		}
+	}()
+	select {
+	case <-done:
+		t.Fatal("wait should block while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	g.set(false)
+	<-done
+
+	g.set(true)
+	cancel()
+	if _, ok := g.wait(ctx); ok {
+		t.Error("expected wait to fail with a canceled context")
+	}
+}
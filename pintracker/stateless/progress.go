@@ -0,0 +1,45 @@
+package stateless
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/pintracker/optracker"
+)
+
+// pinProgressInterval is how often the progress of ongoing pins is obtained
+// from the IPFS connector.
+var pinProgressInterval = 2 * time.Second
+
+// pollPinProgress sets the progress of a pin operation every
+// pinProgressInterval until the context is canceled.
+func (spt *Tracker) pollPinProgress(ctx context.Context, op *optracker.Operation) {
+	ticker := time.NewTicker(pinProgressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var progress api.PinProgress
+		err := spt.rpcClient.CallContext(
+			ctx,
+			"",
+			"IPFSConnector",
+			"PinProgress",
+			op.Cid(),
+			&progress,
+		)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Debugf("error obtaining the progress of %s: %s", op.Cid(), err)
+			}
+			continue
+		}
+		op.SetProgress(progress)
+	}
+}
@@ -0,0 +1,37 @@
+package stateless
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/test"
+)
+
+func TestPinProgress(t *testing.T) {
+	ctx := context.Background()
+	spt := testStatelessPinTracker(t)
+	defer spt.Shutdown(ctx)
+
+	interval := pinProgressInterval
+	pinProgressInterval = 50 * time.Millisecond
+	defer func() { pinProgressInterval = interval }()
+
+	slowPin := api.PinWithOpts(test.SlowCid1, pinOpts)
+	if err := spt.Track(ctx, slowPin); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	st := spt.Status(ctx, test.SlowCid1)
+	if st.Status != api.TrackerStatusPinning || st.Progress == nil || st.Progress.Size != 2048 {
+		t.Errorf("expected the progress of the pin: %+v", st)
+	}
+
+	time.Sleep(time.Second)
+	st = spt.Status(ctx, test.SlowCid1)
+	if st.Progress != nil {
+		t.Errorf("finished pins should not report progress: %+v", st)
+	}
+}
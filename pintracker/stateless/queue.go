@@ -0,0 +1,123 @@
+package stateless
+
+import (
+	"context"
+
+	"github.com/ipfs-cluster/ipfs-cluster/pintracker/optracker"
+)
+
+// Pin operations are queued in buckets:
+//
+//   - priority: pins which are high priority, or recent and not retried
+//     too many times. These are normally user requests.
+//   - retry: failed pins being recovered.
+//   - bulk: everything else, i.e. older pins found when syncing the state.
+//   - low: low priority pins.
+//
+// Workers serve the first three buckets in turns following their weights,
+// so that every bucket makes progress when all of them have items: a large
+// backfill in the bulk bucket does not hold new pins back, and a flood of
+// new pins does not stall recovery. Low priority pins are only served
+// when the other buckets are empty.
+
+// opQueue is a set of buckets served by a worker. Nil buckets are ignored.
+// Every worker has its own opQueue over the same buckets.
+type opQueue struct {
+	priority chan *optracker.Operation
+	retry    chan *optracker.Operation
+	bulk     chan *optracker.Operation
+	low      chan *optracker.Operation
+
+	// schedule is the order in which the buckets are served, and turn
+	// the position in it.
+	schedule []chan *optracker.Operation
+	turn     int
+}
+
+// newOpQueue builds a queue whose schedule interleaves the buckets
+// according to their weights. Buckets with weight 0 are not scheduled, and
+// only served when the due one is empty.
+func newOpQueue(priority, retry, bulk, low chan *optracker.Operation, weights [3]int) *opQueue {
+	q := &opQueue{
+		priority: priority,
+		retry:    retry,
+		bulk:     bulk,
+		low:      low,
+	}
+	q.schedule = weightedSchedule([]chan *optracker.Operation{priority, retry, bulk}, weights[:])
+	return q
+}
+
+// weightedSchedule returns a sequence in which every bucket appears as
+// many times as its weight, spread as evenly as possible (smooth weighted
+// round-robin).
+func weightedSchedule(buckets []chan *optracker.Operation, weights []int) []chan *optracker.Operation {
+	total := 0
+	for i, w := range weights {
+		if buckets[i] != nil {
+			total += w
+		}
+	}
+
+	var schedule []chan *optracker.Operation
+	current := make([]int, len(weights))
+	for n := 0; n < total; n++ {
+		best := -1
+		for i, w := range weights {
+			if buckets[i] == nil || w == 0 {
+				continue
+			}
+			current[i] += w
+			if best < 0 || current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		schedule = append(schedule, buckets[best])
+	}
+	return schedule
+}
+
+// next returns the operation at the front of the bucket which is due.
+// When that bucket is empty, it takes one from the others, in order of
+// priority, and from the low priority bucket last. It blocks until an
+// operation is available, the context is canceled or the interrupt channel
+// is closed.
+func (q *opQueue) next(ctx context.Context, interrupt <-chan struct{}) (*optracker.Operation, bool) {
+	var due chan *optracker.Operation
+	if len(q.schedule) > 0 {
+		due = q.schedule[q.turn]
+		q.turn = (q.turn + 1) % len(q.schedule)
+	}
+
+	for _, ch := range []chan *optracker.Operation{due, q.priority, q.retry, q.bulk, q.low} {
+		if ch == nil {
+			continue
+		}
+		select {
+		case op := <-ch:
+			return op, true
+		case <-ctx.Done():
+			return nil, false
+		case <-interrupt:
+			return nil, false
+		default:
+		}
+	}
+
+	// Block until there is something in any bucket.
+	select {
+	case op := <-q.priority:
+		return op, true
+	case op := <-q.retry:
+		return op, true
+	case op := <-q.bulk:
+		return op, true
+	case op := <-q.low:
+		return op, true
+	case <-ctx.Done():
+		return nil, false
+	case <-interrupt:
+		return nil, false
+	}
+}
@@ -0,0 +1,79 @@
+package stateless
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs-cluster/ipfs-cluster/pintracker/optracker"
+)
+
+func TestOpQueueWeights(t *testing.T) {
+	ctx := context.Background()
+
+	newBucket := func() chan *optracker.Operation {
+		ch := make(chan *optracker.Operation, 100)
+		for i := 0; i < 100; i++ {
+			ch <- nil
+		}
+		return ch
+	}
+	priority, retry, bulk := newBucket(), newBucket(), newBucket()
+	low := make(chan *optracker.Operation, 10)
+	low <- nil
+	q := newOpQueue(priority, retry, bulk, low, [3]int{6, 3, 1})
+
+	for i := 0; i < 20; i++ {
+		if _, ok := q.next(ctx, nil); !ok {
+			t.Fatal("expected an operation")
+		}
+	}
+	if taken := 100 - len(priority); taken != 12 {
+		t.Errorf("expected 12 operations from the priority bucket: %d", taken)
+	}
+	if taken := 100 - len(retry); taken != 6 {
+		t.Errorf("expected 6 operations from the retry bucket: %d", taken)
+	}
+	if taken := 100 - len(bulk); taken != 2 {
+		t.Errorf("expected 2 operations from the bulk bucket: %d", taken)
+	}
+	if len(low) != 1 {
+		t.Error("low priority operations should wait for the rest")
+	}
+
+	// Empty buckets give their turn to the others.
+	for len(priority) > 0 {
+		<-priority
+	}
+	for len(retry) > 0 {
+		<-retry
+	}
+	for i := 0; i < 10; i++ {
+		q.next(ctx, nil)
+	}
+	if taken := 98 - len(bulk); taken != 10 {
+		t.Errorf("expected 10 more operations from the bulk bucket: %d", taken)
+	}
+
+	for len(bulk) > 0 {
+		<-bulk
+	}
+	q.next(ctx, nil)
+	if len(low) != 0 {
+		t.Error("the low priority operation should be taken last")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, ok := q.next(ctx, nil); ok {
+		t.Error("expected no operation when canceled")
+	}
+}
+
+func TestWeightedSchedule(t *testing.T) {
+	a := make(chan *optracker.Operation)
+	b := make(chan *optracker.Operation)
+	schedule := weightedSchedule([]chan *optracker.Operation{a, b, nil}, []int{2, 1, 5})
+	if len(schedule) != 3 || schedule[0] != a || schedule[1] != b || schedule[2] != a {
+		t.Error("expected the a, b, a schedule")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -52,9 +53,22 @@ type Tracker struct {
 	rpcReady  chan struct{}
 
 	priorityPinCh chan *optracker.Operation
+	retryPinCh    chan *optracker.Operation
 	pinCh         chan *optracker.Operation
+	lowPinCh      chan *optracker.Operation
 	unpinCh       chan *optracker.Operation
 
+	pinWorkers   *workerPool
+	unpinWorkers *workerPool
+
+	// concurrencyMu protects the concurrency settings, which can be
+	// changed at runtime.
+	concurrencyMu   sync.Mutex
+	maxPins         int
+	autoConcurrency bool
+
+	pauses *pauseGate
+
 	shutdownMu sync.Mutex
 	shutdown   bool
 	wg         sync.WaitGroup
@@ -74,14 +88,29 @@ func New(cfg *Config, pid peer.ID, peerName string, getState func(ctx context.Co
 		optracker:     optracker.NewOperationTracker(ctx, pid, peerName),
 		rpcReady:      make(chan struct{}, 1),
 		priorityPinCh: make(chan *optracker.Operation, cfg.MaxPinQueueSize),
+		retryPinCh:    make(chan *optracker.Operation, cfg.MaxPinQueueSize),
 		pinCh:         make(chan *optracker.Operation, cfg.MaxPinQueueSize),
+		lowPinCh:      make(chan *optracker.Operation, cfg.MaxPinQueueSize),
 		unpinCh:       make(chan *optracker.Operation, cfg.MaxPinQueueSize),
+
+		maxPins:         cfg.ConcurrentPins,
+		autoConcurrency: cfg.AutoConcurrency,
+		pauses:          newPauseGate(),
 	}
 
-	for i := 0; i < spt.config.ConcurrentPins; i++ {
-		go spt.opWorker(spt.pin, spt.priorityPinCh, spt.pinCh)
+	weights := [3]int{cfg.PriorityQueueWeight, cfg.RetryQueueWeight, cfg.BulkQueueWeight}
+	spt.pinWorkers = newWorkerPool(ctx, cfg.ConcurrentPins, func(ctx context.Context) {
+		q := newOpQueue(spt.priorityPinCh, spt.retryPinCh, spt.pinCh, spt.lowPinCh, weights)
+		spt.opWorker(ctx, spt.pin, q)
+	})
+	spt.unpinWorkers = newWorkerPool(ctx, cfg.ConcurrentUnpins, func(ctx context.Context) {
+		q := newOpQueue(spt.unpinCh, nil, nil, nil, [3]int{1, 0, 0})
+		spt.opWorker(ctx, spt.unpin, q)
+	})
+	go spt.concurrencyWorker()
+	if cfg.VerifyInterval > 0 {
+		go spt.verifyWorker()
 	}
-	go spt.opWorker(spt.unpin, spt.unpinCh, nil)
 
 	return spt
 }
@@ -107,37 +136,25 @@ func (spt *Tracker) getIPFSID(ctx context.Context) api.IPFSID {
 	return ipfsid
 }
 
-// receives a pin Function (pin or unpin) and channels.  Used for both pinning
-// and unpinning.
-func (spt *Tracker) opWorker(pinF func(*optracker.Operation) error, prioCh, normalCh chan *optracker.Operation) {
-
-	var op *optracker.Operation
-
+// receives a pin Function (pin or unpin) and the queue to take operations
+// from. Used for both pinning and unpinning. It runs until the given
+// context is canceled, and does not take operations while the tracker is
+// paused.
+func (spt *Tracker) opWorker(ctx context.Context, pinF func(*optracker.Operation) error, q *opQueue) {
 	for {
-		// Process the priority channel first.
-		select {
-		case op = <-prioCh:
-			goto APPLY_OP
-		case <-spt.ctx.Done():
+		pause, ok := spt.pauses.wait(ctx)
+		if !ok {
 			return
-		default:
 		}
-
-		// Then process things on the other channels.
-		// Block if there are no things to process.
-		select {
-		case op = <-prioCh:
-			goto APPLY_OP
-		case op = <-normalCh:
-			goto APPLY_OP
-		case <-spt.ctx.Done():
-			return
+		op, ok := q.next(ctx, pause)
+		if !ok {
+			continue
 		}
 
-		// apply operations that came from some channel
-	APPLY_OP:
 		if clean := applyPinF(pinF, op); clean {
 			spt.optracker.Clean(op.Context(), op)
+		} else if op.Phase() == optracker.PhaseError {
+			spt.scheduleRetry(op)
 		}
 	}
 }
@@ -171,6 +188,19 @@ func (spt *Tracker) pin(op *optracker.Operation) error {
 	ctx, span := trace.StartSpan(op.Context(), "tracker/stateless/pin")
 	defer span.End()
 
+	if spt.config.ConcurrencyBudget {
+		release, err := spt.acquireBudget(ctx, op)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	op.SetProgress(api.PinProgress{})
+	progressCtx, cancelProgress := context.WithCancel(ctx)
+	defer cancelProgress()
+	go spt.pollPinProgress(progressCtx, op)
+
 	logger.Debugf("issuing pin call for %s", op.Cid())
 	err := spt.rpcClient.CallContext(
 		ctx,
@@ -186,6 +216,40 @@ func (spt *Tracker) pin(op *optracker.Operation) error {
 	return nil
 }
 
+// acquireBudget waits until the cluster's concurrency budget allows the
+// pin to start. The operation is queued meanwhile. The returned function
+// gives back the budget.
+func (spt *Tracker) acquireBudget(ctx context.Context, op *optracker.Operation) (func(), error) {
+	op.SetPhase(optracker.PhaseQueued)
+	err := spt.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"AcquireBudget",
+		struct{}{},
+		&struct{}{},
+	)
+	if err != nil {
+		return nil, err
+	}
+	op.SetPhase(optracker.PhaseInProgress)
+
+	release := func() {
+		err := spt.rpcClient.CallContext(
+			spt.ctx,
+			"",
+			"Cluster",
+			"ReleaseBudget",
+			struct{}{},
+			&struct{}{},
+		)
+		if err != nil {
+			logger.Error(err)
+		}
+	}
+	return release, nil
+}
+
 func (spt *Tracker) unpin(op *optracker.Operation) error {
 	ctx, span := trace.StartSpan(op.Context(), "tracker/stateless/unpin")
 	defer span.End()
@@ -220,31 +284,104 @@ func (spt *Tracker) enqueue(ctx context.Context, c api.Pin, typ optracker.Operat
 
 	switch typ {
 	case optracker.OperationPin:
-		isPriorityPin := time.Now().Before(c.Timestamp.Add(spt.config.PriorityPinMaxAge)) &&
-			op.AttemptCount() <= spt.config.PriorityPinMaxRetries
+		// High priority pins always go to the priority bucket.
+		// Otherwise, recent pins which have not been retried too
+		// many times do, unless they are low priority: those wait
+		// for everything else. Other failed pins are retried from
+		// their own bucket, so that they are not stuck behind
+		// bulk pins.
+		isPriorityPin := c.Priority > api.PinPriorityNormal ||
+			(c.Priority == api.PinPriorityNormal &&
+				time.Now().Before(c.Timestamp.Add(spt.config.PriorityPinMaxAge)) &&
+				op.AttemptCount() <= spt.config.PriorityPinMaxRetries)
 		op.SetPriorityPin(isPriorityPin)
 
-		if isPriorityPin {
+		switch {
+		case isPriorityPin:
 			ch = spt.priorityPinCh
-		} else {
+		case c.Priority < api.PinPriorityNormal:
+			ch = spt.lowPinCh
+		case op.AttemptCount() > 0:
+			ch = spt.retryPinCh
+		default:
 			ch = spt.pinCh
 		}
 	case optracker.OperationUnpin:
 		ch = spt.unpinCh
 	}
+	return spt.queue(op, ch)
+}
 
+// queue sends a queued operation to the given channel, or sets it in error
+// when the channel is full.
+func (spt *Tracker) queue(op *optracker.Operation, ch chan *optracker.Operation) error {
 	select {
 	case ch <- op:
 	default:
 		err := ErrFullQueue
 		op.SetError(err)
 		op.Cancel()
+		spt.scheduleRetry(op)
 		logger.Error(err.Error())
 		return err
 	}
 	return nil
 }
 
+// enqueueDelayedUnpin tracks a queued unpin operation which is only sent
+// to the unpin queue after UnpinDelay. Tracking the item again in the
+// meantime cancels the operation, and nothing is unpinned.
+func (spt *Tracker) enqueueDelayedUnpin(ctx context.Context, c api.Pin) error {
+	op := spt.optracker.TrackNewOperation(ctx, c, optracker.OperationUnpin, optracker.PhaseQueued)
+	if op == nil {
+		return nil // the operation exists and must be queued already.
+	}
+
+	spt.wg.Add(1)
+	go func() {
+		defer spt.wg.Done()
+		timer := time.NewTimer(spt.config.UnpinDelay)
+		defer timer.Stop()
+		select {
+		case <-spt.ctx.Done():
+			return
+		case <-op.Context().Done():
+			return // canceled
+		case <-timer.C:
+		}
+		if err := spt.queue(op, spt.unpinCh); err != nil {
+			logger.Error(err)
+		}
+	}()
+	return nil
+}
+
+// scheduleRetry sets when a failed operation should be retried
+// automatically. The wait starts at RecoverBackoffMin and doubles with
+// every attempt, up to RecoverBackoffMax. Operations which have been
+// attempted RecoverMaxAttempts times are not scheduled, and pins are moved
+// to TrackerStatusPinErrorPermanent.
+func (spt *Tracker) scheduleRetry(op *optracker.Operation) {
+	attempts := op.AttemptCount()
+	if max := spt.config.RecoverMaxAttempts; max > 0 && attempts >= max {
+		logger.Warnf("%s failed %d times: not retrying it automatically anymore", op.Cid(), attempts)
+		op.SetNextRetry(time.Time{})
+		if op.Type() == optracker.OperationPin {
+			op.SetPermanent()
+		}
+		return
+	}
+
+	backoff := spt.config.RecoverBackoffMin
+	for i := 1; i < attempts && backoff < spt.config.RecoverBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > spt.config.RecoverBackoffMax {
+		backoff = spt.config.RecoverBackoffMax
+	}
+	op.SetNextRetry(op.Timestamp().Add(backoff))
+}
+
 // SetClient makes the StatelessPinTracker ready to perform RPC requests to
 // other components.
 func (spt *Tracker) SetClient(c *rpc.Client) {
@@ -274,6 +411,12 @@ func (spt *Tracker) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// isRemotePin returns true when the pin should not be pinned on this peer.
+// In passive mode, no pin is.
+func (spt *Tracker) isRemotePin(p api.Pin) bool {
+	return spt.config.Passive || p.IsRemotePin(spt.peerID)
+}
+
 // Track tells the StatelessPinTracker to start managing a Cid,
 // possibly triggering Pin operations on the IPFS daemon.
 func (spt *Tracker) Track(ctx context.Context, c api.Pin) error {
@@ -292,7 +435,7 @@ func (spt *Tracker) Track(ctx context.Context, c api.Pin) error {
 	// Trigger unpin whenever something remote is tracked
 	// Note, IPFSConn checks with pin/ls before triggering
 	// pin/rm.
-	if c.IsRemotePin(spt.peerID) {
+	if spt.isRemotePin(c) {
 		op := spt.optracker.TrackNewOperation(ctx, c, optracker.OperationRemote, optracker.PhaseInProgress)
 		if op == nil {
 			return nil // ongoing unpin
@@ -309,16 +452,28 @@ func (spt *Tracker) Track(ctx context.Context, c api.Pin) error {
 		return nil
 	}
 
+	// Edits of the name, group or metadata of pins which are being
+	// pinned are applied to the ongoing operation. Otherwise the pin is
+	// queued: the connector does not pin again what IPFS has pinned
+	// already.
+	if spt.optracker.UpdatePin(ctx, c, optracker.OperationPin) {
+		return nil
+	}
+
 	return spt.enqueue(ctx, c, optracker.OperationPin)
 }
 
 // Untrack tells the StatelessPinTracker to stop managing a Cid.
-// If the Cid is pinned locally, it will be unpinned.
+// If the Cid is pinned locally, it will be unpinned, after UnpinDelay when
+// set.
 func (spt *Tracker) Untrack(ctx context.Context, c api.Cid) error {
 	ctx, span := trace.StartSpan(ctx, "tracker/stateless/Untrack")
 	defer span.End()
 
 	logger.Debugf("untracking %s", c)
+	if spt.config.UnpinDelay > 0 {
+		return spt.enqueueDelayedUnpin(ctx, api.PinCid(c))
+	}
 	return spt.enqueue(ctx, api.PinCid(c), optracker.OperationUnpin)
 }
 
@@ -413,6 +568,7 @@ func (spt *Tracker) StatusAll(ctx context.Context, filter api.TrackerStatus, out
 			Origins:     p.Origins,
 			Created:     p.Timestamp,
 			Metadata:    p.Metadata,
+			Group:       p.Group,
 
 			PinInfoShort: api.PinInfoShort{
 				PeerName:      spt.peerName,
@@ -431,7 +587,7 @@ func (spt *Tracker) StatusAll(ctx context.Context, filter api.TrackerStatus, out
 		switch {
 		case p.Type == api.MetaType:
 			info.Status = api.TrackerStatusSharded
-		case p.IsRemotePin(spt.peerID):
+		case spt.isRemotePin(p):
 			info.Status = api.TrackerStatusRemote
 		case pinnedInIpfs:
 			// No need to filter. pinnedInIpfs is false
@@ -513,6 +669,7 @@ func (spt *Tracker) Status(ctx context.Context, c api.Cid) api.PinInfo {
 	pinInfo.Origins = gpin.Origins
 	pinInfo.Created = gpin.Timestamp
 	pinInfo.Metadata = gpin.Metadata
+	pinInfo.Group = gpin.Group
 
 	// check if pin is a meta pin
 	if gpin.Type == api.MetaType {
@@ -521,7 +678,7 @@ func (spt *Tracker) Status(ctx context.Context, c api.Cid) api.PinInfo {
 	}
 
 	// check if pin is a remote pin
-	if gpin.IsRemotePin(spt.peerID) {
+	if spt.isRemotePin(gpin) {
 		pinInfo.Status = api.TrackerStatusRemote
 		return pinInfo
 	}
@@ -556,7 +713,8 @@ func (spt *Tracker) Status(ctx context.Context, c api.Cid) api.PinInfo {
 }
 
 // RecoverAll attempts to recover all items tracked by this peer. It returns
-// any errors or when it is done re-tracking.
+// any errors or when it is done re-tracking. Pins in
+// TrackerStatusPinErrorPermanent are left alone: see RecoverPermanent.
 func (spt *Tracker) RecoverAll(ctx context.Context, out chan<- api.PinInfo) error {
 	defer close(out)
 
@@ -600,13 +758,81 @@ func (spt *Tracker) RecoverAll(ctx context.Context, out chan<- api.PinInfo) erro
 	return nil
 }
 
+// RecoverScheduled retries the failed operations whose retry is due, the
+// most recently failed first. With scan, it also triggers pinning for the
+// items which should be pinned but are not, as found by StatusAll. It
+// returns when it should be called again to retry the next operations.
+func (spt *Tracker) RecoverScheduled(ctx context.Context, scan bool) (time.Time, error) {
+	ctx, span := trace.StartSpan(ctx, "tracker/stateless/RecoverScheduled")
+	defer span.End()
+
+	now := time.Now()
+	next := now.Add(spt.config.RecoverBackoffMin)
+
+	var due []api.PinInfo
+	for _, pi := range spt.optracker.Filter(ctx, api.IPFSID{}, optracker.PhaseError) {
+		retry := pi.NextRetry
+		if retry.IsZero() { // not retried automatically
+			continue
+		}
+		if retry.After(now) {
+			if retry.Before(next) {
+				next = retry
+			}
+			continue
+		}
+		due = append(due, pi)
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].TS.After(due[j].TS)
+	})
+	for _, pi := range due {
+		_, err := spt.recoverWithPinInfo(ctx, pi)
+		if err != nil {
+			return next, fmt.Errorf("RecoverScheduled error: %w", err)
+		}
+	}
+
+	if !scan {
+		return next, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	statusesCh := make(chan api.PinInfo, 1024)
+	go func() {
+		err := spt.StatusAll(ctx, api.TrackerStatusUnexpectedlyUnpinned, statusesCh)
+		if err != nil {
+			logger.Error(err)
+		}
+	}()
+
+	for st := range statusesCh {
+		// Items with a failed operation are only retried when
+		// scheduled.
+		if _, ok := spt.optracker.Status(ctx, st.Cid); ok {
+			continue
+		}
+		_, err := spt.recoverWithPinInfo(ctx, st)
+		if err != nil {
+			return next, fmt.Errorf("RecoverScheduled error: %w", err)
+		}
+	}
+	return next, nil
+}
+
 // Recover will trigger pinning or unpinning for items in
-// PinError or UnpinError states.
+// PinError, PinErrorPermanent or UnpinError states.
 func (spt *Tracker) Recover(ctx context.Context, c api.Cid) (api.PinInfo, error) {
 	ctx, span := trace.StartSpan(ctx, "tracker/stateless/Recover")
 	defer span.End()
 
 	pi := spt.Status(ctx, c)
+	if pi.Status == api.TrackerStatusPinErrorPermanent {
+		pi.Status = api.TrackerStatusPinError
+	}
 
 	recPi, err := spt.recoverWithPinInfo(ctx, pi)
 	// if it was not enqueued, no updated pin-info is returned.
@@ -617,6 +843,37 @@ func (spt *Tracker) Recover(ctx context.Context, c api.Cid) (api.PinInfo, error)
 	return recPi, err
 }
 
+// RecoverPermanent retries the pins which failed too many times to be
+// retried automatically, and sends their updated status on the given
+// channel. Pins which fail again go back to TrackerStatusPinErrorPermanent.
+func (spt *Tracker) RecoverPermanent(ctx context.Context, out chan<- api.PinInfo) error {
+	defer close(out)
+
+	ctx, span := trace.StartSpan(ctx, "tracker/stateless/RecoverPermanent")
+	defer span.End()
+
+	for _, pi := range spt.optracker.Filter(ctx, api.IPFSID{}, optracker.OperationPin, optracker.PhaseError) {
+		if pi.Status != api.TrackerStatusPinErrorPermanent {
+			continue
+		}
+		pi.Status = api.TrackerStatusPinError
+		p, err := spt.recoverWithPinInfo(ctx, pi)
+		if err != nil {
+			err = fmt.Errorf("RecoverPermanent error: %w", err)
+			logger.Error(err)
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			err = fmt.Errorf("RecoverPermanent aborted: %w", ctx.Err())
+			logger.Error(err)
+			return err
+		case out <- p:
+		}
+	}
+	return nil
+}
+
 func (spt *Tracker) recoverWithPinInfo(ctx context.Context, pi api.PinInfo) (api.PinInfo, error) {
 	st, err := spt.getState(ctx)
 	if err != nil {
@@ -681,6 +938,12 @@ func (spt *Tracker) PinQueueSize(ctx context.Context) (int64, error) {
 	return spt.optracker.PinQueueSize(), nil
 }
 
+// StatusChanges returns a channel on which the status of pin and unpin
+// operations is sent every time that it changes.
+func (spt *Tracker) StatusChanges() <-chan api.PinInfo {
+	return spt.optracker.StatusChanges()
+}
+
 // func (spt *Tracker) getErrorsAll(ctx context.Context) []api.PinInfo {
 // 	return spt.optracker.Filter(ctx, optracker.PhaseError)
 // }
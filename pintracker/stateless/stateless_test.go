@@ -3,6 +3,7 @@ package stateless
 import (
 	"context"
 	"errors"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -12,6 +13,8 @@ import (
 	"github.com/ipfs-cluster/ipfs-cluster/state/dsstate"
 	"github.com/ipfs-cluster/ipfs-cluster/test"
 
+	ipld "github.com/ipfs/go-ipld-format"
+	merkledag "github.com/ipfs/go-merkledag"
 	peer "github.com/libp2p/go-libp2p/core/peer"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
 )
@@ -88,8 +91,52 @@ func (mock *mockIPFS) PinLsCid(ctx context.Context, in api.Pin, out *api.IPFSPin
 	return nil
 }
 
+func (mock *mockIPFS) PinProgress(ctx context.Context, in api.Cid, out *api.PinProgress) error {
+	*out = api.PinProgress{
+		Blocks: 1,
+		Bytes:  1024,
+		Size:   2048,
+	}
+	return nil
+}
+
+// BlocksMissing reports test.Cid2 and test.Cid3 as missing.
+func (mock *mockIPFS) BlocksMissing(ctx context.Context, in []api.Cid, out *[]api.Cid) error {
+	missing := []api.Cid{}
+	for _, c := range in {
+		if c.Equals(test.Cid2) || c.Equals(test.Cid3) {
+			missing = append(missing, c)
+		}
+	}
+	*out = missing
+	return nil
+}
+
+// BlockGet returns a block for test.Cid1 linking to test.Cid3 and
+// test.Cid4.
+func (mock *mockIPFS) BlockGet(ctx context.Context, in api.Cid, out *[]byte) error {
+	if !in.Equals(test.Cid1) {
+		return errors.New("block not found")
+	}
+	nd := merkledag.NodeWithData([]byte("data"))
+	nd.AddRawLink("a", &ipld.Link{Cid: test.Cid3.Cid})
+	nd.AddRawLink("b", &ipld.Link{Cid: test.Cid4.Cid})
+	*out = nd.RawData()
+	return nil
+}
+
+func (mock *mockIPFS) ID(ctx context.Context, in struct{}, out *api.IPFSID) error {
+	*out = api.IPFSID{
+		ID: test.PeerID1,
+	}
+	return nil
+}
+
 type mockCluster struct{}
 
+// operations started with the concurrency budget and not finished.
+var budgetInUse int64
+
 func (mock *mockCluster) IPFSID(ctx context.Context, in peer.ID, out *api.IPFSID) error {
 	addr, _ := api.NewMultiaddr("/ip4/127.0.0.1/tcp/4001/p2p/" + test.PeerID1.Pretty())
 	*out = api.IPFSID{
@@ -99,6 +146,16 @@ func (mock *mockCluster) IPFSID(ctx context.Context, in peer.ID, out *api.IPFSID
 	return nil
 }
 
+func (mock *mockCluster) AcquireBudget(ctx context.Context, in struct{}, out *struct{}) error {
+	atomic.AddInt64(&budgetInUse, 1)
+	return nil
+}
+
+func (mock *mockCluster) ReleaseBudget(ctx context.Context, in struct{}, out *struct{}) error {
+	atomic.AddInt64(&budgetInUse, -1)
+	return nil
+}
+
 func mockRPCClient(t testing.TB) *rpc.Client {
 	t.Helper()
 
@@ -234,6 +291,38 @@ func TestTrackUntrackWithCancel(t *testing.T) {
 	}
 }
 
+func TestTrackEditOngoing(t *testing.T) {
+	ctx := context.Background()
+	spt := testStatelessPinTracker(t)
+	defer spt.Shutdown(ctx)
+
+	slowPin := api.PinWithOpts(test.SlowCid1, pinOpts)
+	err := spt.Track(ctx, slowPin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond) // let pinning start
+
+	opCtx := spt.optracker.OpContext(ctx, slowPin.Cid)
+	edited := slowPin
+	edited.Name = "renamed"
+	err = spt.Track(ctx, edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-opCtx.Done():
+		t.Fatal("editing the pin should not cancel the ongoing operation")
+	default:
+	}
+	pInfo := spt.optracker.Get(ctx, slowPin.Cid, api.IPFSID{})
+	if pInfo.Status != api.TrackerStatusPinning || pInfo.Name != "renamed" {
+		t.Error("the edit should be applied to the ongoing operation:", pInfo.Status, pInfo.Name)
+	}
+}
+
 // This tracks a slow CID and then tracks a fast/normal one.
 // Because we are pinning the slow CID, the fast one will stay
 // queued. We proceed to untrack it then. Since it was never
@@ -505,6 +594,92 @@ func TestStatus(t *testing.T) {
 	}
 }
 
+func TestPassive(t *testing.T) {
+	ctx := context.Background()
+
+	// Pinned everywhere. Only Cid1 is pinned in the IPFS mock.
+	normalPin := api.PinWithOpts(test.Cid1, pinOpts)
+	normalPin2 := api.PinWithOpts(test.Cid4, pinOpts)
+
+	spt := testStatelessPinTracker(t, normalPin, normalPin2)
+	spt.config.Passive = true
+	defer spt.Shutdown(ctx)
+
+	if st := spt.Status(ctx, test.Cid1); st.Status != api.TrackerStatusRemote {
+		t.Errorf("a passive peer should not pin anything: %s", st.Status)
+	}
+
+	err := spt.Track(ctx, normalPin2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second / 2)
+
+	if st := spt.Status(ctx, test.Cid4); st.Status != api.TrackerStatusRemote {
+		t.Errorf("a tracked pin should be remote: %s", st.Status)
+	}
+}
+
+func TestUnpinDelay(t *testing.T) {
+	ctx := context.Background()
+	spt := testStatelessPinTracker(t)
+	spt.config.UnpinDelay = time.Second / 2
+	defer spt.Shutdown(ctx)
+
+	err := spt.Untrack(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Unpinning unpinCancelCid fails in the mock. It must not happen
+	// when the item is tracked again.
+	err = spt.Untrack(ctx, unpinCancelCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []api.Cid{test.Cid1, unpinCancelCid} {
+		pi, ok := spt.optracker.GetExists(ctx, c, api.IPFSID{})
+		if !ok || pi.Status != api.TrackerStatusUnpinQueued {
+			t.Errorf("%s: the unpin should be queued during the delay: %+v", c, pi)
+		}
+	}
+
+	err = spt.Track(ctx, api.PinWithOpts(unpinCancelCid, pinOpts))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Second)
+
+	if pi, ok := spt.optracker.GetExists(ctx, test.Cid1, api.IPFSID{}); ok {
+		t.Errorf("the unpin should have happened after the delay: %+v", pi)
+	}
+	if pi, ok := spt.optracker.GetExists(ctx, unpinCancelCid, api.IPFSID{}); ok && pi.Status == api.TrackerStatusUnpinError {
+		t.Error("the delayed unpin should have been canceled")
+	}
+}
+
+func TestPinConcurrencyBudget(t *testing.T) {
+	ctx := context.Background()
+	spt := testStatelessPinTracker(t)
+	spt.config.ConcurrencyBudget = true
+	defer spt.Shutdown(ctx)
+
+	err := spt.Track(ctx, api.PinWithOpts(test.SlowCid1, pinOpts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if n := atomic.LoadInt64(&budgetInUse); n != 1 {
+		t.Errorf("the pin should hold the budget: %d", n)
+	}
+
+	time.Sleep(time.Second)
+	if n := atomic.LoadInt64(&budgetInUse); n != 0 {
+		t.Errorf("the budget should be given back: %d", n)
+	}
+}
+
 // Test
 func TestAttemptCountAndPriority(t *testing.T) {
 	ctx := context.Background()
@@ -573,3 +748,180 @@ func TestAttemptCountAndPriority(t *testing.T) {
 		t.Errorf("errPin should have 2 attempt counts to unpin: %+v", st)
 	}
 }
+
+func TestPinPriority(t *testing.T) {
+	ctx := context.Background()
+
+	slowPin := api.PinWithOpts(test.SlowCid1, pinOpts)
+
+	oldPin := func(c api.Cid, priority api.PinPriority) api.Pin {
+		opts := pinOpts
+		opts.Priority = priority
+		pin := api.PinWithOpts(c, opts)
+		pin.Timestamp = time.Now().Add(-time.Hour)
+		return pin
+	}
+	highPin := oldPin(test.Cid4, api.PinPriorityHigh)
+	normalPin := oldPin(test.Cid5, api.PinPriorityNormal)
+	lowPin := api.PinWithOpts(test.Cid3, pinOpts) // recent
+	lowPin.Priority = api.PinPriorityLow
+
+	spt := testStatelessPinTracker(t)
+	defer spt.Shutdown(ctx)
+
+	// Keep the only pin worker busy so that the rest stay queued.
+	if err := spt.Track(ctx, slowPin); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	for _, pin := range []api.Pin{lowPin, normalPin, highPin} {
+		if err := spt.Track(ctx, pin); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(spt.priorityPinCh) != 1 || len(spt.pinCh) != 1 || len(spt.lowPinCh) != 1 {
+		t.Fatalf("unexpected queues: priority: %d, normal: %d, low: %d",
+			len(spt.priorityPinCh), len(spt.pinCh), len(spt.lowPinCh))
+	}
+	if op := <-spt.priorityPinCh; !op.Cid().Equals(highPin.Cid) || !op.PriorityPin() {
+		t.Errorf("expected the high priority pin first: %s", op.Cid())
+	}
+	if op := <-spt.pinCh; !op.Cid().Equals(normalPin.Cid) || op.PriorityPin() {
+		t.Errorf("expected the normal priority pin second: %s", op.Cid())
+	}
+	if op := <-spt.lowPinCh; !op.Cid().Equals(lowPin.Cid) || op.PriorityPin() {
+		t.Errorf("expected the recent low priority pin not to be priority: %s", op.Cid())
+	}
+}
+
+func TestRetryPinQueue(t *testing.T) {
+	ctx := context.Background()
+
+	slowPin := api.PinWithOpts(test.SlowCid1, pinOpts)
+	errPin := api.PinWithOpts(pinErrCid, pinOpts)
+	spt := testStatelessPinTracker(t, errPin)
+	defer spt.Shutdown(ctx)
+
+	// Fail twice. The second attempt is a priority pin.
+	if err := spt.Track(ctx, errPin); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := spt.Recover(ctx, pinErrCid); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// Keep the only pin worker busy so that the retry stays queued.
+	if err := spt.Track(ctx, slowPin); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := spt.Recover(ctx, pinErrCid); err != nil {
+		t.Fatal(err)
+	}
+	if len(spt.retryPinCh) != 1 || len(spt.priorityPinCh) != 0 || len(spt.pinCh) != 0 {
+		t.Fatalf("unexpected queues: priority: %d, retry: %d, normal: %d",
+			len(spt.priorityPinCh), len(spt.retryPinCh), len(spt.pinCh))
+	}
+	if op := <-spt.retryPinCh; !op.Cid().Equals(pinErrCid) || op.PriorityPin() {
+		t.Errorf("expected the failed pin to be retried without priority: %s", op.Cid())
+	}
+}
+
+func TestRecoverScheduled(t *testing.T) {
+	ctx := context.Background()
+
+	errPin := api.PinWithOpts(pinErrCid, pinOpts)
+	spt := testStatelessPinTracker(t, errPin)
+	defer spt.Shutdown(ctx)
+
+	spt.config.RecoverBackoffMin = 100 * time.Millisecond
+	spt.config.RecoverBackoffMax = 150 * time.Millisecond
+	spt.config.RecoverMaxAttempts = 3
+
+	checkAttempts := func(n int, retryIn time.Duration) {
+		t.Helper()
+		time.Sleep(50 * time.Millisecond) // let the pin be applied
+		st := spt.Status(ctx, pinErrCid)
+		if st.AttemptCount != n {
+			t.Fatalf("expected %d attempts: %+v", n, st)
+		}
+		if retryIn == 0 {
+			if !st.NextRetry.IsZero() {
+				t.Errorf("expected no retry to be scheduled: %+v", st)
+			}
+			return
+		}
+		if !st.NextRetry.Equal(st.TS.Add(retryIn)) {
+			t.Errorf("expected a retry %s after the error: %+v", retryIn, st)
+		}
+	}
+
+	// The scan finds the unpinned item.
+	_, err := spt.RecoverScheduled(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAttempts(1, 100*time.Millisecond)
+
+	// Failed items are not retried before their time, even when
+	// scanning.
+	next, err := spt.RecoverScheduled(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !next.Equal(spt.Status(ctx, pinErrCid).NextRetry) {
+		t.Error("expected to be called again when the retry is due")
+	}
+	checkAttempts(1, 100*time.Millisecond)
+
+	time.Sleep(100 * time.Millisecond)
+	_, err = spt.RecoverScheduled(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAttempts(2, 150*time.Millisecond) // doubled, up to the max
+
+	time.Sleep(150 * time.Millisecond)
+	_, err = spt.RecoverScheduled(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAttempts(3, 0) // max attempts reached
+	if st := spt.Status(ctx, pinErrCid); st.Status != api.TrackerStatusPinErrorPermanent {
+		t.Fatalf("expected the pin to fail permanently: %+v", st)
+	}
+
+	_, err = spt.RecoverScheduled(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAttempts(3, 0)
+
+	// Recovering everything leaves it alone.
+	out := make(chan api.PinInfo, 10)
+	if err := spt.RecoverAll(ctx, out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 0 {
+		t.Errorf("expected no pins to be recovered: %d", len(out))
+	}
+	checkAttempts(3, 0)
+
+	// It is retried once when asked to.
+	out = make(chan api.PinInfo, 10)
+	if err := spt.RecoverPermanent(ctx, out); err != nil {
+		t.Fatal(err)
+	}
+	if pi := <-out; !pi.Cid.Equals(pinErrCid) {
+		t.Errorf("expected %s to be retried: %+v", pinErrCid, pi)
+	}
+	checkAttempts(4, 0)
+	if st := spt.Status(ctx, pinErrCid); st.Status != api.TrackerStatusPinErrorPermanent {
+		t.Errorf("expected the pin to fail permanently again: %+v", st)
+	}
+}
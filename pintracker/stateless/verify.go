@@ -0,0 +1,189 @@
+package stateless
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/pintracker/optracker"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"go.opencensus.io/trace"
+
+	// registers the dag-pb, raw and dag-cbor block decoders.
+	_ "github.com/ipfs/go-merkledag"
+)
+
+// errBlocksMissing is set on items which the ipfs daemon reports as
+// pinned but whose blocks are not stored, i.e. after a manual "repo gc" or
+// disk corruption.
+var errBlocksMissing = errors.New("blocks of a pinned item are missing from IPFS")
+
+// verifyWorker checks the blocks of the pinned items every VerifyInterval.
+func (spt *Tracker) verifyWorker() {
+	select {
+	case <-spt.ctx.Done():
+		return
+	case <-spt.rpcReady:
+	}
+
+	ticker := time.NewTicker(spt.config.VerifyInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-spt.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := spt.verifyPinned(spt.ctx); err != nil {
+				logger.Error(err)
+			}
+		}
+	}
+}
+
+// verifyPinned checks that the items of the state which the ipfs daemon
+// reports as pinned, and have no ongoing operations, have their root block
+// and VerifySampleSize of its children stored. Items with missing blocks
+// are set in error. They are not retried automatically, as the ipfs daemon
+// considers them pinned.
+func (spt *Tracker) verifyPinned(ctx context.Context) error {
+	ctx, span := trace.StartSpan(ctx, "tracker/stateless/verifyPinned")
+	defer span.End()
+
+	st, err := spt.getState(ctx)
+	if err != nil {
+		return err
+	}
+
+	ipfsPins := make(map[api.Cid]struct{})
+	ipfsPinsCh, errCh := spt.ipfsPins(ctx)
+	for ipfsPinInfo := range ipfsPinsCh {
+		ipfsPins[ipfsPinInfo.Cid] = struct{}{}
+	}
+	if err := <-errCh; err != nil {
+		return fmt.Errorf("could not get pinset from IPFS: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	statePins := make(chan api.Pin, pinsChannelSize)
+	go func() {
+		err := st.List(ctx, statePins)
+		if err != nil {
+			logger.Error(err)
+		}
+	}()
+
+	verified, failed := 0, 0
+	for p := range statePins {
+		if ctx.Err() != nil {
+			return fmt.Errorf("verification aborted: %w", ctx.Err())
+		}
+		if _, ok := ipfsPins[p.Cid]; !ok || p.Type == api.MetaType || spt.isRemotePin(p) {
+			continue
+		}
+		if _, ok := spt.optracker.GetExists(ctx, p.Cid, api.IPFSID{}); ok {
+			continue
+		}
+
+		missing, checked, err := spt.missingBlocks(ctx, p)
+		if err != nil {
+			logger.Warnf("could not verify the blocks of %s: %s", p.Cid, err)
+			continue
+		}
+		verified++
+		if len(missing) == 0 {
+			continue
+		}
+		failed++
+		logger.Errorf("%s is pinned but %d of %d checked blocks are missing from IPFS", p.Cid, len(missing), checked)
+		op := spt.optracker.TrackNewOperation(ctx, p, optracker.OperationPin, optracker.PhaseError)
+		if op == nil {
+			continue
+		}
+		op.SetError(fmt.Errorf("%w: %d of %d checked blocks", errBlocksMissing, len(missing), checked))
+		op.Cancel()
+	}
+	logger.Infof("verified the blocks of %d pinned items: %d have missing blocks", verified, failed)
+	return nil
+}
+
+// missingBlocks returns which of the checked blocks of a pin are missing
+// from IPFS, and how many were checked. Children are only checked when the
+// root block is present.
+func (spt *Tracker) missingBlocks(ctx context.Context, p api.Pin) ([]api.Cid, int, error) {
+	missing, err := spt.blocksMissing(ctx, []api.Cid{p.Cid})
+	if err != nil || len(missing) > 0 || spt.config.VerifySampleSize == 0 || p.MaxDepth == 0 {
+		return missing, 1, err
+	}
+
+	children, err := spt.sampleChildren(ctx, p.Cid, spt.config.VerifySampleSize)
+	if err != nil {
+		return nil, 1, err
+	}
+	if len(children) == 0 {
+		return nil, 1, nil
+	}
+	missing, err = spt.blocksMissing(ctx, children)
+	return missing, 1 + len(children), err
+}
+
+func (spt *Tracker) blocksMissing(ctx context.Context, cids []api.Cid) ([]api.Cid, error) {
+	var missing []api.Cid
+	err := spt.rpcClient.CallContext(
+		ctx,
+		"",
+		"IPFSConnector",
+		"BlocksMissing",
+		cids,
+		&missing,
+	)
+	return missing, err
+}
+
+// sampleChildren returns up to n randomly chosen children of a block
+// stored in IPFS.
+func (spt *Tracker) sampleChildren(ctx context.Context, c api.Cid, n int) ([]api.Cid, error) {
+	// raw blocks have no links: there is no need to read them.
+	if c.Type() == cid.Raw {
+		return nil, nil
+	}
+
+	var data []byte
+	err := spt.rpcClient.CallContext(
+		ctx,
+		"",
+		"IPFSConnector",
+		"BlockGet",
+		c,
+		&data,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error reading block %s: %w", c, err)
+	}
+	blk, err := blocks.NewBlockWithCid(data, c.Cid)
+	if err != nil {
+		return nil, err
+	}
+	nd, err := ipld.Decode(blk)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding block %s: %w", c, err)
+	}
+
+	links := nd.Links()
+	if n > len(links) {
+		n = len(links)
+	}
+	children := make([]api.Cid, n)
+	for i, j := range rand.Perm(len(links))[:n] {
+		children[i] = api.NewCid(links[j].Cid)
+	}
+	return children, nil
+}
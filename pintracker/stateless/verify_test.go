@@ -0,0 +1,45 @@
+package stateless
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/test"
+)
+
+func TestVerifyPinned(t *testing.T) {
+	ctx := context.Background()
+	spt := testStatelessPinTracker(t,
+		api.PinWithOpts(test.Cid1, pinOpts),
+		api.PinWithOpts(test.Cid2, pinOpts),
+	)
+	defer spt.Shutdown(ctx)
+
+	// Only the roots are checked: test.Cid2 is missing.
+	if err := spt.verifyPinned(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if st := spt.Status(ctx, test.Cid1); st.Status != api.TrackerStatusPinned {
+		t.Errorf("expected %s to be pinned: %+v", test.Cid1, st)
+	}
+	st := spt.Status(ctx, test.Cid2)
+	if st.Status != api.TrackerStatusPinError || !strings.Contains(st.Error, errBlocksMissing.Error()) {
+		t.Errorf("expected %s to be missing blocks: %+v", test.Cid2, st)
+	}
+	if !st.NextRetry.IsZero() {
+		t.Errorf("items with missing blocks should not be retried: %+v", st)
+	}
+
+	// Sampling the children of test.Cid1 finds that test.Cid3 is
+	// missing.
+	spt.config.VerifySampleSize = 5
+	if err := spt.verifyPinned(ctx); err != nil {
+		t.Fatal(err)
+	}
+	st = spt.Status(ctx, test.Cid1)
+	if st.Status != api.TrackerStatusPinError || !strings.Contains(st.Error, "1 of 3 checked blocks") {
+		t.Errorf("expected %s to be missing a child block: %+v", test.Cid1, st)
+	}
+}
@@ -0,0 +1,185 @@
+package ipfscluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+	"github.com/ipfs-cluster/ipfs-cluster/observations"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/trace"
+)
+
+// quotaMetricName names the alerts raised when a peer approaches its quota.
+const quotaMetricName = "quota"
+
+// quotaWarningRatio is the usage of a quota from which alerts are raised.
+const quotaWarningRatio = 0.9
+
+// quotaPinsCountInterval is how often the pins allocated to this peer are
+// counted for its quota. Counting them lists the whole shared state, so
+// pings in between use the last count.
+const quotaPinsCountInterval = 5 * time.Minute
+
+// quotaUsage describes the storage quota of a peer and how much of it is
+// used. It is carried by ping metrics.
+type quotaUsage struct {
+	MaxBytes  uint64 `json:"max_bytes,omitempty"`
+	UsedBytes uint64 `json:"used_bytes,omitempty"`
+	MaxPins   int    `json:"max_pins,omitempty"`
+	UsedPins  int    `json:"used_pins,omitempty"`
+}
+
+// exceeded returns true when any of the quotas has been reached.
+func (q *quotaUsage) exceeded() bool {
+	if q == nil {
+		return false
+	}
+	return (q.MaxBytes > 0 && q.UsedBytes >= q.MaxBytes) ||
+		(q.MaxPins > 0 && q.UsedPins >= q.MaxPins)
+}
+
+// usage returns the highest ratio between used and maximum values of the
+// quotas that are set.
+func (q *quotaUsage) usage() float64 {
+	if q == nil {
+		return 0
+	}
+	var ratio float64
+	if q.MaxBytes > 0 {
+		ratio = float64(q.UsedBytes) / float64(q.MaxBytes)
+	}
+	if q.MaxPins > 0 {
+		if r := float64(q.UsedPins) / float64(q.MaxPins); r > ratio {
+			ratio = r
+		}
+	}
+	return ratio
+}
+
+func (q *quotaUsage) String() string {
+	return fmt.Sprintf(
+		"bytes: %d/%d, pins: %d/%d",
+		q.UsedBytes, q.MaxBytes, q.UsedPins, q.MaxPins,
+	)
+}
+
+// quotaUsage obtains the usage of the quotas of this peer: the size of the
+// IPFS repository and the number of pins allocated to the peer in the
+// shared state, as last counted. It returns nil when no quotas are
+// configured.
+func (c *Cluster) quotaUsage(ctx context.Context) (*quotaUsage, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/quotaUsage")
+	defer span.End()
+
+	if c.config.QuotaMaxBytes == 0 && c.config.QuotaMaxPins == 0 {
+		return nil, nil
+	}
+
+	q := &quotaUsage{
+		MaxBytes: c.config.QuotaMaxBytes,
+		MaxPins:  c.config.QuotaMaxPins,
+	}
+
+	if q.MaxBytes > 0 {
+		repoStat, err := c.ipfs.RepoStat(ctx)
+		if err != nil {
+			return nil, err
+		}
+		q.UsedBytes = repoStat.RepoSize
+	}
+
+	if q.MaxPins > 0 {
+		used, err := c.quotaUsedPins(ctx)
+		if err != nil {
+			return nil, err
+		}
+		q.UsedPins = used
+	}
+
+	stats.Record(
+		ctx,
+		observations.QuotaUsedBytes.M(int64(q.UsedBytes)),
+		observations.QuotaUsedPins.M(int64(q.UsedPins)),
+	)
+	return q, nil
+}
+
+// quotaUsedPins returns the number of pins allocated to this peer in the
+// shared state. They are counted again when the last count is older than
+// quotaPinsCountInterval.
+func (c *Cluster) quotaUsedPins(ctx context.Context) (int, error) {
+	c.quotaPinsMux.Lock()
+	defer c.quotaPinsMux.Unlock()
+	if time.Since(c.quotaPinsCounted) < quotaPinsCountInterval {
+		return c.quotaPins, nil
+	}
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return 0, err
+	}
+	pins := make(chan api.Pin, 1024)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- cState.List(ctx, pins)
+	}()
+	used := 0
+	for p := range pins {
+		if !p.IsRemotePin(c.id) {
+			used++
+		}
+	}
+	if err := <-errCh; err != nil {
+		return 0, err
+	}
+	c.quotaPins = used
+	c.quotaPinsCounted = time.Now()
+	return used, nil
+}
+
+// checkQuota raises an alert when this peer goes above the warning ratio of
+// its quota. It is raised once until the usage goes back below it.
+func (c *Cluster) checkQuota(q *quotaUsage) {
+	c.alertsMux.Lock()
+	warn := q.usage() >= quotaWarningRatio
+	raise := warn && !c.quotaWarned
+	c.quotaWarned = warn
+	c.alertsMux.Unlock()
+
+	if !raise {
+		return
+	}
+
+	if q.exceeded() {
+		logger.Warnf("quota reached (%s): this peer will not be allocated new pins", q)
+	} else {
+		logger.Warnf("approaching quota (%s)", q)
+	}
+	alrt := api.Alert{
+		Metric: api.Metric{
+			Name:  quotaMetricName,
+			Peer:  c.id,
+			Value: q.String(),
+			Valid: true,
+		},
+		TriggeredAt: time.Now(),
+	}
+	c.recordAlert(alrt)
+}
+
+// overQuotaPeers returns the peers which have reached their quota,
+// according to their latest ping metrics.
+func (c *Cluster) overQuotaPeers(ctx context.Context) map[peer.ID]bool {
+	over := make(map[peer.ID]bool)
+	for _, m := range c.monitor.LatestMetrics(ctx, pingMetricName) {
+		if pingValueFromMetric(m).Quota.exceeded() {
+			over[m.Peer] = true
+		}
+	}
+	return over
+}
@@ -0,0 +1,142 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+
+	"go.opencensus.io/trace"
+)
+
+// reallocationBatchSize is the number of pins submitted together to the
+// consensus layer when changing replication factors.
+const reallocationBatchSize = 100
+
+var errNoReallocation = errors.New("cids or a search are needed to reallocate pins")
+
+// Reallocate changes the replication factors of existing pins, given by
+// their CIDs or by a search on their names and metadata. Their allocations
+// are changed incrementally: the peers already allocated to a pin keep it,
+// and peers are only added or removed as needed to meet the new factors, so
+// the pins are never unpinned. Pins whose factors do not change are left
+// untouched. It returns the results for every pin.
+func (c *Cluster) Reallocate(ctx context.Context, re api.Reallocation) ([]api.BatchResult, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/Reallocate")
+	defer span.End()
+
+	if c.config.FollowerMode {
+		return nil, errFollowerMode
+	}
+
+	if len(re.Cids) == 0 && re.Search.IsEmpty() {
+		return nil, errNoReallocation
+	}
+
+	rplMin := re.ReplicationFactorMin
+	rplMax := re.ReplicationFactorMax
+	if rplMin == 0 {
+		rplMin = c.config.ReplicationFactorMin
+	}
+	if rplMax == 0 {
+		rplMax = c.config.ReplicationFactorMax
+	}
+	if err := isReplicationFactorValid(rplMin, rplMax); err != nil {
+		return nil, err
+	}
+
+	var pins []api.Pin
+	var results []api.BatchResult
+	seen := make(map[api.Cid]struct{})
+	for _, ci := range re.Cids {
+		if _, ok := seen[ci]; ok {
+			continue
+		}
+		seen[ci] = struct{}{}
+		pin, err := c.PinGet(ctx, ci)
+		if err != nil {
+			results = append(results, api.BatchResult{
+				Action: api.BatchActionPin,
+				Cid:    ci,
+				Error:  err.Error(),
+			})
+			continue
+		}
+		pins = append(pins, pin)
+	}
+
+	if !re.Search.IsEmpty() {
+		found := make(chan api.Pin, 1024)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- c.SearchPins(ctx, re.Search, found)
+		}()
+		for p := range found {
+			if _, ok := seen[p.Cid]; ok {
+				continue
+			}
+			seen[p.Cid] = struct{}{}
+			pins = append(pins, p)
+		}
+		if err := <-errCh; err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Infof("setting replication of %d pins to [%d, %d]", len(pins), rplMin, rplMax)
+	changed, err := c.setReplication(ctx, pins, rplMin, rplMax)
+	if err != nil {
+		return nil, err
+	}
+	return append(changed, results...), nil
+}
+
+// setReplication sets the replication factors of the given pins, which are
+// re-allocated as needed. Pins are submitted with PinBatch, in batches of
+// reallocationBatchSize, so that large sets of pins are re-allocated
+// progressively. Only regular pins are changed: the results of other pins
+// carry an error. Pins which have the given factors already are not
+// submitted.
+func (c *Cluster) setReplication(ctx context.Context, pins []api.Pin, rplMin, rplMax int) ([]api.BatchResult, error) {
+	var items []api.BatchItem
+	var results []api.BatchResult
+	for _, p := range pins {
+		if p.Type != api.DataType {
+			results = append(results, api.BatchResult{
+				Action: api.BatchActionPin,
+				Cid:    p.Cid,
+				Pin:    p,
+				Error:  "only regular pins can be re-allocated",
+			})
+			continue
+		}
+		if p.ReplicationFactorMin == rplMin && p.ReplicationFactorMax == rplMax {
+			results = append(results, api.BatchResult{
+				Action: api.BatchActionPin,
+				Cid:    p.Cid,
+				Pin:    p,
+			})
+			continue
+		}
+		opts := p.PinOptions
+		opts.ReplicationFactorMin = rplMin
+		opts.ReplicationFactorMax = rplMax
+		items = append(items, api.BatchItem{
+			Action:  api.BatchActionPin,
+			Cid:     p.Cid,
+			Options: opts,
+		})
+	}
+
+	var batched []api.BatchResult
+	for len(items) > 0 {
+		n := minInt(reallocationBatchSize, len(items))
+		res, err := c.PinBatch(ctx, items[:n])
+		if err != nil {
+			return nil, err
+		}
+		batched = append(batched, res...)
+		items = items[n:]
+	}
+	return append(batched, results...), nil
+}
@@ -0,0 +1,69 @@
+package ipfscluster
+
+import (
+	"context"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	host "github.com/libp2p/go-libp2p/core/host"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// regionPeerstoreKey is the peerstore key under which the region announced
+// by every peer in its ping metric is stored.
+const regionPeerstoreKey = "cluster-region"
+
+// regionScore is the score given to peers in the same region when
+// RegionGossip is enabled. Others get no score.
+const regionScore = 10.0
+
+// regionScoreFunc returns a pubsub application-specific score function
+// which favors the peers which are in the given region.
+func regionScoreFunc(h host.Host, region string) func(peer.ID) float64 {
+	return func(p peer.ID) float64 {
+		v, err := h.Peerstore().Get(p, regionPeerstoreKey)
+		if err != nil {
+			return 0
+		}
+		if r, ok := v.(string); ok && r == region {
+			return regionScore
+		}
+		return 0
+	}
+}
+
+// regionGossipOptions returns the pubsub options to make the gossipsub mesh
+// prefer peers in the given region. Peers in other regions are never
+// penalized: they are still sent messages and gossip, but they are chosen
+// last when the mesh is pruned and grafted opportunistically.
+func regionGossipOptions(h host.Host, region string) []pubsub.Option {
+	params := &pubsub.PeerScoreParams{
+		AppSpecificScore:  regionScoreFunc(h, region),
+		AppSpecificWeight: 1,
+		DecayInterval:     time.Second,
+		DecayToZero:       0.01,
+	}
+	thresholds := &pubsub.PeerScoreThresholds{
+		GossipThreshold:             -100,
+		PublishThreshold:            -200,
+		GraylistThreshold:           -300,
+		AcceptPXThreshold:           0,
+		OpportunisticGraftThreshold: regionScore / 2,
+	}
+	return []pubsub.Option{pubsub.WithPeerScore(params, thresholds)}
+}
+
+// recordPeerRegions stores the regions announced in the latest ping
+// metrics in the peerstore, where the region score function reads them.
+func (c *Cluster) recordPeerRegions(ctx context.Context) {
+	for _, m := range c.monitor.LatestMetrics(ctx, pingMetricName) {
+		region := pingValueFromMetric(m).Region
+		if region == "" {
+			continue
+		}
+		err := c.host.Peerstore().Put(m.Peer, regionPeerstoreKey, region)
+		if err != nil {
+			logger.Debugf("error recording region for %s: %s", m.Peer, err)
+		}
+	}
+}
@@ -184,6 +184,31 @@ func (rpcapi *ClusterRPCAPI) Pin(ctx context.Context, in api.Pin, out *api.Pin)
 	return nil
 }
 
+// Events runs Cluster.Events().
+func (rpcapi *ClusterRPCAPI) Events(ctx context.Context, in <-chan struct{}, out chan<- api.Event) error {
+	return rpcapi.c.Events(ctx, out)
+}
+
+// PinBatch runs Cluster.PinBatch().
+func (rpcapi *ClusterRPCAPI) PinBatch(ctx context.Context, in []api.BatchItem, out *[]api.BatchResult) error {
+	results, err := rpcapi.c.PinBatch(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = results
+	return nil
+}
+
+// PinEdit runs Cluster.PinEdit().
+func (rpcapi *ClusterRPCAPI) PinEdit(ctx context.Context, in api.PinEdit, out *api.Pin) error {
+	pin, err := rpcapi.c.PinEdit(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = pin
+	return nil
+}
+
 // Unpin runs Cluster.Unpin().
 func (rpcapi *ClusterRPCAPI) Unpin(ctx context.Context, in api.Pin, out *api.Pin) error {
 	pin, err := rpcapi.c.Unpin(ctx, in.Cid)
@@ -194,6 +219,26 @@ func (rpcapi *ClusterRPCAPI) Unpin(ctx context.Context, in api.Pin, out *api.Pin
 	return nil
 }
 
+// UndoUnpin runs Cluster.UndoUnpin().
+func (rpcapi *ClusterRPCAPI) UndoUnpin(ctx context.Context, in api.Cid, out *api.Pin) error {
+	pin, err := rpcapi.c.UndoUnpin(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = pin
+	return nil
+}
+
+// Tombstone runs Cluster.Tombstone().
+func (rpcapi *ClusterRPCAPI) Tombstone(ctx context.Context, in api.Cid, out *api.Pin) error {
+	pin, err := rpcapi.c.Tombstone(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = pin
+	return nil
+}
+
 // PinPath resolves path into a cid and runs Cluster.Pin().
 func (rpcapi *ClusterRPCAPI) PinPath(ctx context.Context, in api.PinPath, out *api.Pin) error {
 	pin, err := rpcapi.c.PinPath(ctx, in.Path, in.PinOptions)
@@ -219,6 +264,59 @@ func (rpcapi *ClusterRPCAPI) Pins(ctx context.Context, in <-chan struct{}, out c
 	return rpcapi.c.Pins(ctx, out)
 }
 
+// SearchPins runs Cluster.SearchPins().
+func (rpcapi *ClusterRPCAPI) SearchPins(ctx context.Context, in <-chan api.PinSearch, out chan<- api.Pin) error {
+	search := <-in
+	return rpcapi.c.SearchPins(ctx, search, out)
+}
+
+// Groups runs Cluster.Groups().
+func (rpcapi *ClusterRPCAPI) Groups(ctx context.Context, in struct{}, out *[]api.PinGroup) error {
+	groups, err := rpcapi.c.Groups(ctx)
+	if err != nil {
+		return err
+	}
+	*out = groups
+	return nil
+}
+
+// GroupPins runs Cluster.GroupPins().
+func (rpcapi *ClusterRPCAPI) GroupPins(ctx context.Context, in <-chan string, out chan<- api.Pin) error {
+	group := <-in
+	return rpcapi.c.GroupPins(ctx, group, out)
+}
+
+// UnpinGroup runs Cluster.UnpinGroup().
+func (rpcapi *ClusterRPCAPI) UnpinGroup(ctx context.Context, in string, out *[]api.BatchResult) error {
+	results, err := rpcapi.c.UnpinGroup(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = results
+	return nil
+}
+
+// SetGroupReplication runs Cluster.SetGroupReplication() with the group and
+// replication factors set in the given options.
+func (rpcapi *ClusterRPCAPI) SetGroupReplication(ctx context.Context, in api.PinOptions, out *[]api.BatchResult) error {
+	results, err := rpcapi.c.SetGroupReplication(ctx, in.Group, in.ReplicationFactorMin, in.ReplicationFactorMax)
+	if err != nil {
+		return err
+	}
+	*out = results
+	return nil
+}
+
+// Reallocate runs Cluster.Reallocate().
+func (rpcapi *ClusterRPCAPI) Reallocate(ctx context.Context, in api.Reallocation, out *[]api.BatchResult) error {
+	results, err := rpcapi.c.Reallocate(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = results
+	return nil
+}
+
 // PinGet runs Cluster.PinGet().
 func (rpcapi *ClusterRPCAPI) PinGet(ctx context.Context, in api.Cid, out *api.Pin) error {
 	pin, err := rpcapi.c.PinGet(ctx, in)
@@ -229,6 +327,16 @@ func (rpcapi *ClusterRPCAPI) PinGet(ctx context.Context, in api.Cid, out *api.Pi
 	return nil
 }
 
+// VerifyPin runs Cluster.VerifyPin().
+func (rpcapi *ClusterRPCAPI) VerifyPin(ctx context.Context, in api.PinVerifyRequest, out *api.PinVerification) error {
+	res, err := rpcapi.c.VerifyPin(ctx, in.Cid, in.Sample)
+	if err != nil {
+		return err
+	}
+	*out = res
+	return nil
+}
+
 // Version runs Cluster.Version().
 func (rpcapi *ClusterRPCAPI) Version(ctx context.Context, in struct{}, out *api.Version) error {
 	*out = api.Version{
@@ -275,6 +383,36 @@ func (rpcapi *ClusterRPCAPI) PeerRemove(ctx context.Context, in peer.ID, out *st
 	return rpcapi.c.PeerRemove(ctx, in)
 }
 
+// TrustPeer runs Cluster.TrustPeer().
+func (rpcapi *ClusterRPCAPI) TrustPeer(ctx context.Context, in peer.ID, out *struct{}) error {
+	return rpcapi.c.TrustPeer(ctx, in)
+}
+
+// DistrustPeer runs Cluster.DistrustPeer().
+func (rpcapi *ClusterRPCAPI) DistrustPeer(ctx context.Context, in peer.ID, out *struct{}) error {
+	return rpcapi.c.DistrustPeer(ctx, in)
+}
+
+// TrustedPeers runs Cluster.TrustedPeers().
+func (rpcapi *ClusterRPCAPI) TrustedPeers(ctx context.Context, in struct{}, out *[]peer.ID) error {
+	peers, err := rpcapi.c.TrustedPeers(ctx)
+	if err != nil {
+		return err
+	}
+	*out = peers
+	return nil
+}
+
+// OperationLog runs Cluster.OperationLog().
+func (rpcapi *ClusterRPCAPI) OperationLog(ctx context.Context, in api.OpLogOptions, out *[]api.ConsensusOp) error {
+	ops, err := rpcapi.c.OperationLog(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = ops
+	return nil
+}
+
 // Join runs Cluster.Join().
 func (rpcapi *ClusterRPCAPI) Join(ctx context.Context, in api.Multiaddr, out *struct{}) error {
 	return rpcapi.c.Join(ctx, in.Value())
@@ -286,6 +424,12 @@ func (rpcapi *ClusterRPCAPI) StatusAll(ctx context.Context, in <-chan api.Tracke
 	return rpcapi.c.StatusAll(ctx, filter, out)
 }
 
+// StatusAllFiltered runs Cluster.StatusAllFiltered().
+func (rpcapi *ClusterRPCAPI) StatusAllFiltered(ctx context.Context, in <-chan api.StatusAllFilter, out chan<- api.GlobalPinInfo) error {
+	filter := <-in
+	return rpcapi.c.StatusAllFiltered(ctx, filter, out)
+}
+
 // StatusAllLocal runs Cluster.StatusAllLocal().
 func (rpcapi *ClusterRPCAPI) StatusAllLocal(ctx context.Context, in <-chan api.TrackerStatus, out chan<- api.PinInfo) error {
 	filter := <-in
@@ -319,6 +463,16 @@ func (rpcapi *ClusterRPCAPI) RecoverAllLocal(ctx context.Context, in <-chan stru
 	return rpcapi.c.RecoverAllLocal(ctx, out)
 }
 
+// RecoverPermanent runs Cluster.RecoverPermanent().
+func (rpcapi *ClusterRPCAPI) RecoverPermanent(ctx context.Context, in <-chan struct{}, out chan<- api.GlobalPinInfo) error {
+	return rpcapi.c.RecoverPermanent(ctx, out)
+}
+
+// RecoverPermanentLocal runs Cluster.RecoverPermanentLocal().
+func (rpcapi *ClusterRPCAPI) RecoverPermanentLocal(ctx context.Context, in <-chan struct{}, out chan<- api.PinInfo) error {
+	return rpcapi.c.RecoverPermanentLocal(ctx, out)
+}
+
 // Recover runs Cluster.Recover().
 func (rpcapi *ClusterRPCAPI) Recover(ctx context.Context, in api.Cid, out *api.GlobalPinInfo) error {
 	pinfo, err := rpcapi.c.Recover(ctx, in)
@@ -339,6 +493,18 @@ func (rpcapi *ClusterRPCAPI) RecoverLocal(ctx context.Context, in api.Cid, out *
 	return nil
 }
 
+// AcquireBudget waits until a heavy operation can start within the
+// GlobalConcurrencyBudget. It is used by the pintracker and the adders.
+func (rpcapi *ClusterRPCAPI) AcquireBudget(ctx context.Context, in struct{}, out *struct{}) error {
+	return rpcapi.c.acquireBudget(ctx)
+}
+
+// ReleaseBudget finishes an operation started with AcquireBudget.
+func (rpcapi *ClusterRPCAPI) ReleaseBudget(ctx context.Context, in struct{}, out *struct{}) error {
+	rpcapi.c.releaseBudget()
+	return nil
+}
+
 // BlockAllocate returns allocations for blocks. This is used in the adders.
 // It's different from pin allocations when ReplicationFactor < 0.
 func (rpcapi *ClusterRPCAPI) BlockAllocate(ctx context.Context, in api.Pin, out *[]peer.ID) error {
@@ -400,6 +566,56 @@ func (rpcapi *ClusterRPCAPI) RepoGC(ctx context.Context, in struct{}, out *api.G
 	return nil
 }
 
+// GarbageCollect runs Cluster.GarbageCollect() with the given concurrency.
+func (rpcapi *ClusterRPCAPI) GarbageCollect(ctx context.Context, in int, out *api.GlobalRepoGC) error {
+	res, err := rpcapi.c.GarbageCollect(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = res
+	return nil
+}
+
+// PinTrackerConcurrency runs Cluster.PinTrackerConcurrency().
+func (rpcapi *ClusterRPCAPI) PinTrackerConcurrency(ctx context.Context, in struct{}, out *[]api.PinTrackerConcurrency) error {
+	res, err := rpcapi.c.PinTrackerConcurrency(ctx)
+	if err != nil {
+		return err
+	}
+	*out = res
+	return nil
+}
+
+// SetPinTrackerConcurrency runs Cluster.SetPinTrackerConcurrency().
+func (rpcapi *ClusterRPCAPI) SetPinTrackerConcurrency(ctx context.Context, in api.PinTrackerConcurrency, out *[]api.PinTrackerConcurrency) error {
+	res, err := rpcapi.c.SetPinTrackerConcurrency(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = res
+	return nil
+}
+
+// PinTrackerPause runs Cluster.PinTrackerPause().
+func (rpcapi *ClusterRPCAPI) PinTrackerPause(ctx context.Context, in struct{}, out *[]api.PinTrackerConcurrency) error {
+	res, err := rpcapi.c.PinTrackerPause(ctx)
+	if err != nil {
+		return err
+	}
+	*out = res
+	return nil
+}
+
+// PinTrackerResume runs Cluster.PinTrackerResume().
+func (rpcapi *ClusterRPCAPI) PinTrackerResume(ctx context.Context, in struct{}, out *[]api.PinTrackerConcurrency) error {
+	res, err := rpcapi.c.PinTrackerResume(ctx)
+	if err != nil {
+		return err
+	}
+	*out = res
+	return nil
+}
+
 // RepoGCLocal performs garbage collection sweep only on the local peer's IPFS daemon.
 func (rpcapi *ClusterRPCAPI) RepoGCLocal(ctx context.Context, in struct{}, out *api.RepoGC) error {
 	res, err := rpcapi.c.RepoGCLocal(ctx)
@@ -427,6 +643,12 @@ func (rpcapi *ClusterRPCAPI) Alerts(ctx context.Context, in struct{}, out *[]api
 	return nil
 }
 
+// HealthCheck runs Cluster.HealthCheck().
+func (rpcapi *ClusterRPCAPI) HealthCheck(ctx context.Context, in struct{}, out *api.HealthReport) error {
+	*out = rpcapi.c.HealthCheck(ctx)
+	return nil
+}
+
 // IPFSID returns the current cached IPFS ID for a peer.
 func (rpcapi *ClusterRPCAPI) IPFSID(ctx context.Context, in peer.ID, out *api.IPFSID) error {
 	if in == "" {
@@ -505,6 +727,34 @@ func (rpcapi *PinTrackerRPCAPI) PinQueueSize(ctx context.Context, in struct{}, o
 	return err
 }
 
+// Concurrency runs PinTracker.Concurrency().
+func (rpcapi *PinTrackerRPCAPI) Concurrency(ctx context.Context, in struct{}, out *api.PinTrackerConcurrency) error {
+	*out = rpcapi.tracker.Concurrency(ctx)
+	return nil
+}
+
+// SetConcurrency runs PinTracker.SetConcurrency().
+func (rpcapi *PinTrackerRPCAPI) SetConcurrency(ctx context.Context, in api.PinTrackerConcurrency, out *api.PinTrackerConcurrency) error {
+	res, err := rpcapi.tracker.SetConcurrency(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = res
+	return nil
+}
+
+// Pause runs PinTracker.Pause().
+func (rpcapi *PinTrackerRPCAPI) Pause(ctx context.Context, in struct{}, out *api.PinTrackerConcurrency) error {
+	*out = rpcapi.tracker.Pause(ctx)
+	return nil
+}
+
+// Resume runs PinTracker.Resume().
+func (rpcapi *PinTrackerRPCAPI) Resume(ctx context.Context, in struct{}, out *api.PinTrackerConcurrency) error {
+	*out = rpcapi.tracker.Resume(ctx)
+	return nil
+}
+
 /*
    IPFS Connector component methods
 */
@@ -516,6 +766,26 @@ func (rpcapi *IPFSConnectorRPCAPI) Pin(ctx context.Context, in api.Pin, out *str
 	return rpcapi.ipfs.Pin(ctx, in)
 }
 
+// PinProgress runs IPFSConnector.PinProgress().
+func (rpcapi *IPFSConnectorRPCAPI) PinProgress(ctx context.Context, in api.Cid, out *api.PinProgress) error {
+	res, err := rpcapi.ipfs.PinProgress(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = res
+	return nil
+}
+
+// ID runs IPFSConnector.ID().
+func (rpcapi *IPFSConnectorRPCAPI) ID(ctx context.Context, in struct{}, out *api.IPFSID) error {
+	res, err := rpcapi.ipfs.ID(ctx)
+	if err != nil {
+		return err
+	}
+	*out = res
+	return nil
+}
+
 // Unpin runs IPFSConnector.Unpin().
 func (rpcapi *IPFSConnectorRPCAPI) Unpin(ctx context.Context, in api.Pin, out *struct{}) error {
 	return rpcapi.ipfs.Unpin(ctx, in.Cid)
@@ -592,6 +862,23 @@ func (rpcapi *IPFSConnectorRPCAPI) BlockGet(ctx context.Context, in api.Cid, out
 	return nil
 }
 
+// BlocksMissing returns the given blocks for which IPFSConnector.BlockHas()
+// is false.
+func (rpcapi *IPFSConnectorRPCAPI) BlocksMissing(ctx context.Context, in []api.Cid, out *[]api.Cid) error {
+	missing := []api.Cid{}
+	for _, c := range in {
+		ok, err := rpcapi.ipfs.BlockHas(ctx, c)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			missing = append(missing, c)
+		}
+	}
+	*out = missing
+	return nil
+}
+
 // Resolve runs IPFSConnector.Resolve().
 func (rpcapi *IPFSConnectorRPCAPI) Resolve(ctx context.Context, in string, out *api.Cid) error {
 	c, err := rpcapi.ipfs.Resolve(ctx, in)
@@ -634,6 +921,16 @@ func (rpcapi *ConsensusRPCAPI) RmPeer(ctx context.Context, in peer.ID, out *stru
 	return rpcapi.cons.RmPeer(ctx, in)
 }
 
+// Leader runs Consensus.Leader().
+func (rpcapi *ConsensusRPCAPI) Leader(ctx context.Context, in struct{}, out *peer.ID) error {
+	leader, err := rpcapi.cons.Leader(ctx)
+	if err != nil {
+		return err
+	}
+	*out = leader
+	return nil
+}
+
 // Peers runs Consensus.Peers().
 func (rpcapi *ConsensusRPCAPI) Peers(ctx context.Context, in struct{}, out *[]peer.ID) error {
 	peers, err := rpcapi.cons.Peers(ctx)
@@ -644,6 +941,18 @@ func (rpcapi *ConsensusRPCAPI) Peers(ctx context.Context, in struct{}, out *[]pe
 	return nil
 }
 
+// EncryptionKey runs Consensus.EncryptionKey().
+func (rpcapi *ConsensusRPCAPI) EncryptionKey(ctx context.Context, in string, out *[]byte) error {
+	ctx, span := trace.StartSpan(ctx, "rpc/consensus/EncryptionKey")
+	defer span.End()
+	key, err := rpcapi.cons.EncryptionKey(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = key
+	return nil
+}
+
 /*
    PeerMonitor
 */
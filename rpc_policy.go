@@ -7,64 +7,100 @@ package ipfscluster
 // without missing any endpoint.
 var DefaultRPCPolicy = map[string]RPCEndpointType{
 	// Cluster methods
-	"Cluster.Alerts":               RPCClosed,
-	"Cluster.BlockAllocate":        RPCClosed,
-	"Cluster.ConnectGraph":         RPCClosed,
-	"Cluster.ID":                   RPCOpen,
-	"Cluster.IDStream":             RPCOpen,
-	"Cluster.IPFSID":               RPCClosed,
-	"Cluster.Join":                 RPCClosed,
-	"Cluster.PeerAdd":              RPCOpen, // Used by Join()
-	"Cluster.PeerRemove":           RPCTrusted,
-	"Cluster.Peers":                RPCTrusted, // Used by ConnectGraph()
-	"Cluster.PeersWithFilter":      RPCClosed,
-	"Cluster.Pin":                  RPCClosed,
-	"Cluster.PinGet":               RPCClosed,
-	"Cluster.PinPath":              RPCClosed,
-	"Cluster.Pins":                 RPCClosed, // Used in stateless tracker, ipfsproxy, restapi
-	"Cluster.Recover":              RPCClosed,
-	"Cluster.RecoverAll":           RPCClosed,
-	"Cluster.RecoverAllLocal":      RPCTrusted,
-	"Cluster.RecoverLocal":         RPCTrusted,
-	"Cluster.RepoGC":               RPCClosed,
-	"Cluster.RepoGCLocal":          RPCTrusted,
-	"Cluster.SendInformerMetrics":  RPCClosed,
-	"Cluster.SendInformersMetrics": RPCClosed,
-	"Cluster.Status":               RPCClosed,
-	"Cluster.StatusAll":            RPCClosed,
-	"Cluster.StatusAllLocal":       RPCClosed,
-	"Cluster.StatusLocal":          RPCClosed,
-	"Cluster.Unpin":                RPCClosed,
-	"Cluster.UnpinPath":            RPCClosed,
-	"Cluster.Version":              RPCOpen,
+	"Cluster.AcquireBudget":            RPCClosed,
+	"Cluster.Alerts":                   RPCClosed,
+	"Cluster.BlockAllocate":            RPCClosed,
+	"Cluster.ConnectGraph":             RPCClosed,
+	"Cluster.DistrustPeer":             RPCClosed,
+	"Cluster.Events":                   RPCClosed,
+	"Cluster.GarbageCollect":           RPCClosed,
+	"Cluster.GroupPins":                RPCClosed,
+	"Cluster.Groups":                   RPCClosed,
+	"Cluster.HealthCheck":              RPCClosed,
+	"Cluster.ID":                       RPCOpen,
+	"Cluster.IDStream":                 RPCOpen,
+	"Cluster.IPFSID":                   RPCClosed,
+	"Cluster.Join":                     RPCClosed,
+	"Cluster.OperationLog":             RPCClosed,
+	"Cluster.PeerAdd":                  RPCOpen, // Used by Join()
+	"Cluster.PeerRemove":               RPCTrusted,
+	"Cluster.Peers":                    RPCTrusted, // Used by ConnectGraph()
+	"Cluster.PeersWithFilter":          RPCClosed,
+	"Cluster.Pin":                      RPCClosed,
+	"Cluster.PinTrackerConcurrency":    RPCClosed,
+	"Cluster.PinTrackerPause":          RPCClosed,
+	"Cluster.PinTrackerResume":         RPCClosed,
+	"Cluster.PinBatch":                 RPCClosed,
+	"Cluster.PinEdit":                  RPCClosed,
+	"Cluster.PinGet":                   RPCClosed,
+	"Cluster.PinPath":                  RPCClosed,
+	"Cluster.Pins":                     RPCClosed, // Used in stateless tracker, ipfsproxy, restapi
+	"Cluster.Reallocate":               RPCClosed,
+	"Cluster.ReleaseBudget":            RPCClosed,
+	"Cluster.Recover":                  RPCClosed,
+	"Cluster.RecoverAll":               RPCClosed,
+	"Cluster.RecoverAllLocal":          RPCTrusted,
+	"Cluster.RecoverLocal":             RPCTrusted,
+	"Cluster.RecoverPermanent":         RPCClosed,
+	"Cluster.RecoverPermanentLocal":    RPCTrusted,
+	"Cluster.RepoGC":                   RPCClosed,
+	"Cluster.RepoGCLocal":              RPCTrusted,
+	"Cluster.SearchPins":               RPCClosed,
+	"Cluster.SendInformerMetrics":      RPCClosed,
+	"Cluster.SendInformersMetrics":     RPCClosed,
+	"Cluster.SetGroupReplication":      RPCClosed,
+	"Cluster.SetPinTrackerConcurrency": RPCClosed,
+	"Cluster.Status":                   RPCClosed,
+	"Cluster.StatusAll":                RPCClosed,
+	"Cluster.StatusAllFiltered":        RPCClosed,
+	"Cluster.StatusAllLocal":           RPCClosed,
+	"Cluster.StatusLocal":              RPCClosed,
+	"Cluster.Tombstone":                RPCClosed,
+	"Cluster.TrustPeer":                RPCClosed,
+	"Cluster.TrustedPeers":             RPCClosed,
+	"Cluster.Unpin":                    RPCClosed,
+	"Cluster.UndoUnpin":                RPCClosed,
+	"Cluster.UnpinGroup":               RPCClosed,
+	"Cluster.UnpinPath":                RPCClosed,
+	"Cluster.VerifyPin":                RPCClosed,
+	"Cluster.Version":                  RPCOpen,
 
 	// PinTracker methods
-	"PinTracker.PinQueueSize": RPCClosed,
-	"PinTracker.Recover":      RPCTrusted, // Called in broadcast from Recover()
-	"PinTracker.RecoverAll":   RPCClosed,  // Broadcast in RecoverAll unimplemented
-	"PinTracker.Status":       RPCTrusted,
-	"PinTracker.StatusAll":    RPCTrusted,
-	"PinTracker.Track":        RPCClosed,
-	"PinTracker.Untrack":      RPCClosed,
+	"PinTracker.Concurrency":    RPCTrusted, // Called in broadcast from PinTrackerConcurrency()
+	"PinTracker.Pause":          RPCTrusted, // Called in broadcast from PinTrackerPause()
+	"PinTracker.PinQueueSize":   RPCClosed,
+	"PinTracker.Recover":        RPCTrusted, // Called in broadcast from Recover()
+	"PinTracker.RecoverAll":     RPCClosed,  // Broadcast in RecoverAll unimplemented
+	"PinTracker.Resume":         RPCTrusted, // Called in broadcast from PinTrackerResume()
+	"PinTracker.SetConcurrency": RPCTrusted, // Called in broadcast from SetPinTrackerConcurrency()
+	"PinTracker.Status":         RPCTrusted,
+	"PinTracker.StatusAll":      RPCTrusted,
+	"PinTracker.Track":          RPCClosed,
+	"PinTracker.Untrack":        RPCClosed,
 
 	// IPFSConnector methods
-	"IPFSConnector.BlockGet":    RPCClosed,
-	"IPFSConnector.BlockStream": RPCTrusted, // Called by adders
-	"IPFSConnector.ConfigKey":   RPCClosed,
-	"IPFSConnector.Pin":         RPCClosed,
-	"IPFSConnector.PinLs":       RPCClosed,
-	"IPFSConnector.PinLsCid":    RPCClosed,
-	"IPFSConnector.RepoStat":    RPCTrusted, // Called in broadcast from proxy/repo/stat
-	"IPFSConnector.Resolve":     RPCClosed,
-	"IPFSConnector.SwarmPeers":  RPCTrusted, // Called in ConnectGraph
-	"IPFSConnector.Unpin":       RPCClosed,
+	"IPFSConnector.BlockGet":      RPCClosed,
+	"IPFSConnector.BlockStream":   RPCTrusted, // Called by adders
+	"IPFSConnector.BlocksMissing": RPCTrusted, // Called by VerifyPin()
+	"IPFSConnector.ConfigKey":     RPCClosed,
+	"IPFSConnector.ID":            RPCClosed,
+	"IPFSConnector.Pin":           RPCClosed,
+	"IPFSConnector.PinLs":         RPCClosed,
+	"IPFSConnector.PinLsCid":      RPCClosed,
+	"IPFSConnector.PinProgress":   RPCClosed,
+	"IPFSConnector.RepoStat":      RPCTrusted, // Called in broadcast from proxy/repo/stat
+	"IPFSConnector.Resolve":       RPCClosed,
+	"IPFSConnector.SwarmPeers":    RPCTrusted, // Called in ConnectGraph
+	"IPFSConnector.Unpin":         RPCClosed,
 
 	// Consensus methods
-	"Consensus.AddPeer":  RPCTrusted, // Called by Raft/redirect to leader
-	"Consensus.LogPin":   RPCTrusted, // Called by Raft/redirect to leader
-	"Consensus.LogUnpin": RPCTrusted, // Called by Raft/redirect to leader
-	"Consensus.Peers":    RPCClosed,
-	"Consensus.RmPeer":   RPCTrusted, // Called by Raft/redirect to leader
+	"Consensus.AddPeer":       RPCTrusted, // Called by Raft/redirect to leader
+	"Consensus.EncryptionKey": RPCTrusted, // Called by CRDT peers decrypting the state
+	"Consensus.Leader":        RPCClosed,
+	"Consensus.LogPin":        RPCTrusted, // Called by Raft/redirect to leader
+	"Consensus.LogUnpin":      RPCTrusted, // Called by Raft/redirect to leader
+	"Consensus.Peers":         RPCClosed,
+	"Consensus.RmPeer":        RPCTrusted, // Called by Raft/redirect to leader
 
 	// PeerMonitor methods
 	"PeerMonitor.LatestMetrics": RPCClosed,
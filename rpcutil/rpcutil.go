@@ -124,6 +124,18 @@ func CopyRepoGCSliceToIfaces(in []api.RepoGC) []interface{} {
 	return ifaces
 }
 
+// CopyPinTrackerConcurrencyToIfaces converts an api.PinTrackerConcurrency
+// slice to an empty interface slice using pointers to each elements of the
+// original slice. Useful to handle gorpc.MultiCall() replies.
+func CopyPinTrackerConcurrencyToIfaces(in []api.PinTrackerConcurrency) []interface{} {
+	ifaces := make([]interface{}, len(in))
+	for i := range in {
+		in[i] = api.PinTrackerConcurrency{}
+		ifaces[i] = &(in[i])
+	}
+	return ifaces
+}
+
 // CopyEmptyStructToIfaces converts an empty struct slice to an empty interface
 // slice using pointers to each elements of the original slice.
 // Useful to handle gorpc.MultiCall() replies.
@@ -39,6 +39,8 @@ type State struct {
 	// version     int
 
 	totalPins int64
+
+	index *index
 }
 
 // DefaultHandle returns the codec handler of choice (Msgpack).
@@ -65,6 +67,7 @@ func New(ctx context.Context, dstore ds.Datastore, namespace string, handle code
 		codecHandle: handle,
 		namespace:   ds.NewKey(namespace),
 		totalPins:   0,
+		index:       newIndex(),
 	}
 
 	stats.Record(ctx, observations.Pins.M(0))
@@ -91,6 +94,9 @@ func (st *State) Add(ctx context.Context, c api.Pin) (err error) {
 	}()
 
 	err = st.dsWrite.Put(ctx, st.key(c.Cid), ps)
+	if err == nil {
+		st.index.add(c)
+	}
 	return
 }
 
@@ -102,9 +108,11 @@ func (st *State) Rm(ctx context.Context, c api.Cid) error {
 
 	err := st.dsWrite.Delete(ctx, st.key(c))
 	if err == ds.ErrNotFound {
+		st.index.rm(c)
 		return nil
 	}
 	if err == nil {
+		st.index.rm(c)
 		total := atomic.AddInt64(&st.totalPins, -1)
 		stats.Record(ctx, observations.Pins.M(total))
 	}
@@ -157,10 +165,52 @@ func (st *State) List(ctx context.Context, out chan<- api.Pin) error {
 		Prefix: st.namespace.String(),
 	}
 
-	results, err := st.dsRead.Query(ctx, q)
+	total, err := st.query(ctx, q, out)
 	if err != nil {
 		return err
 	}
+	if total >= 500000 {
+		logger.Infof("Full pinset listing finished: %d pins", total)
+	}
+	atomic.StoreInt64(&st.totalPins, total)
+	stats.Record(ctx, observations.Pins.M(total))
+	return nil
+}
+
+// ListAfter sends the pins which come after the given one on the given
+// channel, in the order of their keys, which is the order in which the
+// sorted key-value stores used for the state list them. All the pins are
+// sent when the given cid is undefined. Returns and closes channel when
+// done.
+func (st *State) ListAfter(ctx context.Context, after api.Cid, out chan<- api.Pin) error {
+	defer close(out)
+
+	_, span := trace.StartSpan(ctx, "state/dsstate/ListAfter")
+	defer span.End()
+
+	q := query.Query{
+		Prefix: st.namespace.String(),
+	}
+	if after.Defined() {
+		q.Filters = []query.Filter{
+			query.FilterKeyCompare{
+				Op:  query.GreaterThan,
+				Key: st.key(after).String(),
+			},
+		}
+	}
+
+	_, err := st.query(ctx, q, out)
+	return err
+}
+
+// query sends the pins resulting from the given query on the given channel
+// and returns how many were sent.
+func (st *State) query(ctx context.Context, q query.Query, out chan<- api.Pin) (int64, error) {
+	results, err := st.dsRead.Query(ctx, q)
+	if err != nil {
+		return 0, err
+	}
 	defer results.Close()
 
 	var total int64
@@ -168,15 +218,15 @@ func (st *State) List(ctx context.Context, out chan<- api.Pin) error {
 		// Abort if we shutdown.
 		select {
 		case <-ctx.Done():
-			err = fmt.Errorf("full pinset listing aborted: %w", ctx.Err())
+			err = fmt.Errorf("pinset listing aborted: %w", ctx.Err())
 			logger.Warning(err)
-			return err
+			return total, err
 		default:
 		}
 		if r.Error != nil {
 			err := fmt.Errorf("error in query result: %w", r.Error)
 			logger.Error(err)
-			return err
+			return total, err
 		}
 		k := ds.NewKey(r.Key)
 		ci, err := st.unkey(k)
@@ -193,18 +243,64 @@ func (st *State) List(ctx context.Context, out chan<- api.Pin) error {
 		out <- p
 
 		if total > 0 && total%500000 == 0 {
-			logger.Infof("Full pinset listing in progress: %d pins so far", total)
+			logger.Infof("Pinset listing in progress: %d pins so far", total)
 		}
 		total++
 	}
-	if total >= 500000 {
-		logger.Infof("Full pinset listing finished: %d pins", total)
+	return total, nil
+}
+
+// Search sends the pins matching the given search on the given channel,
+// which is closed when done. Pins are looked up in an index over their
+// names and metadata, which is built from the full pinset on the first
+// search.
+func (st *State) Search(ctx context.Context, search api.PinSearch, out chan<- api.Pin) error {
+	defer close(out)
+
+	ctx, span := trace.StartSpan(ctx, "state/dsstate/Search")
+	defer span.End()
+
+	err := st.index.build(func(ch chan<- api.Pin) error {
+		return st.List(ctx, ch)
+	})
+	if err != nil {
+		return fmt.Errorf("error building the search index: %w", err)
+	}
+
+	for _, c := range st.index.find(search) {
+		// The index may be ahead of what is readable from a batching
+		// state, so the pins are checked again.
+		p, err := st.Get(ctx, c)
+		if err == state.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !search.Match(p) {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case out <- p:
+		}
 	}
-	atomic.StoreInt64(&st.totalPins, total)
-	stats.Record(ctx, observations.Pins.M(total))
 	return nil
 }
 
+// Index updates the search index with a pin which was written to the
+// underlying datastore without using this State, i.e. when it is
+// replicated by the consensus component.
+func (st *State) Index(p api.Pin) {
+	st.index.add(p)
+}
+
+// Unindex removes a pin from the search index, like Index.
+func (st *State) Unindex(c api.Cid) {
+	st.index.rm(c)
+}
+
 // Migrate migrates an older state version to the current one.
 // This is a no-op for now.
 func (st *State) Migrate(ctx context.Context, r io.Reader) error {
@@ -273,6 +369,7 @@ func (st *State) Unmarshal(r io.Reader) error {
 		}
 	}
 
+	st.index.reset()
 	return nil
 }
 
@@ -347,6 +444,7 @@ func NewBatching(ctx context.Context, dstore ds.Batching, namespace string, hand
 		dsWrite:     batch,
 		codecHandle: handle,
 		namespace:   ds.NewKey(namespace),
+		index:       newIndex(),
 	}
 
 	bst := &BatchingState{}
@@ -125,6 +125,53 @@ func TestList(t *testing.T) {
 
 }
 
+func TestListAfter(t *testing.T) {
+	ctx := context.Background()
+	st := newState(t)
+
+	testCid2, _ := api.DecodeCid("QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc")
+	testCid3, _ := api.DecodeCid("QmWnYSKpNLzYPaWCvMCSYVhVqHvHYEsHfLPdSsMnzGBNXU")
+	cids := []api.Cid{testCid1, testCid2, testCid3}
+	for _, ci := range cids {
+		st.Add(ctx, api.PinCid(ci))
+	}
+
+	listAfter := func(after api.Cid) []api.Pin {
+		t.Helper()
+		out := make(chan api.Pin, 10)
+		if err := st.ListAfter(ctx, after, out); err != nil {
+			t.Fatal(err)
+		}
+		var pins []api.Pin
+		for p := range out {
+			pins = append(pins, p)
+		}
+		return pins
+	}
+
+	if pins := listAfter(api.CidUndef); len(pins) != 3 {
+		t.Fatalf("expected all the pins: %v", pins)
+	}
+
+	for _, after := range cids {
+		pins := listAfter(after)
+		expected := 0
+		for _, ci := range cids {
+			if st.key(ci).String() > st.key(after).String() {
+				expected++
+			}
+		}
+		if len(pins) != expected {
+			t.Errorf("expected %d pins after %s: %v", expected, after, pins)
+		}
+		for _, p := range pins {
+			if st.key(p.Cid).String() <= st.key(after).String() {
+				t.Errorf("%s does not come after %s", p.Cid, after)
+			}
+		}
+	}
+}
+
 func TestMarshalUnmarshal(t *testing.T) {
 	ctx := context.Background()
 	st := newState(t)
@@ -151,3 +198,57 @@ func TestMarshalUnmarshal(t *testing.T) {
 		t.Error("expected different cid")
 	}
 }
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	st := newState(t)
+
+	search := func(s api.PinSearch) []api.Pin {
+		t.Helper()
+		out := make(chan api.Pin, 10)
+		if err := st.Search(ctx, s, out); err != nil {
+			t.Fatal(err)
+		}
+		var pins []api.Pin
+		for p := range out {
+			pins = append(pins, p)
+		}
+		return pins
+	}
+
+	testCid2, _ := api.DecodeCid("QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc")
+	p1 := c
+	p1.Metadata = map[string]string{"dataset": "a"}
+	p2 := api.PinWithOpts(testCid2, api.PinOptions{
+		Metadata: map[string]string{"dataset": "b"},
+	})
+	st.Add(ctx, p1)
+
+	// Builds the index.
+	if pins := search(api.PinSearch{Name: "test"}); len(pins) != 1 || !pins[0].Cid.Equals(testCid1) {
+		t.Fatalf("unexpected search results: %v", pins)
+	}
+
+	// Updated after being built.
+	st.Add(ctx, p2)
+	if pins := search(api.PinSearch{Metadata: map[string]string{"dataset": ""}}); len(pins) != 2 {
+		t.Errorf("expected 2 pins with the dataset key: %v", pins)
+	}
+	if pins := search(api.PinSearch{Name: "test", Metadata: map[string]string{"dataset": "b"}}); len(pins) != 0 {
+		t.Errorf("expected no results: %v", pins)
+	}
+
+	p1.Metadata = map[string]string{"dataset": "b"}
+	st.Add(ctx, p1)
+	if pins := search(api.PinSearch{Metadata: map[string]string{"dataset": "a"}}); len(pins) != 0 {
+		t.Errorf("expected the previous metadata to be unindexed: %v", pins)
+	}
+	if pins := search(api.PinSearch{Metadata: map[string]string{"dataset": "b"}}); len(pins) != 2 {
+		t.Errorf("expected 2 pins in dataset b: %v", pins)
+	}
+
+	st.Rm(ctx, testCid2)
+	if pins := search(api.PinSearch{Metadata: map[string]string{"dataset": "b"}}); len(pins) != 1 {
+		t.Errorf("expected the removed pin to be unindexed: %v", pins)
+	}
+}
@@ -0,0 +1,257 @@
+package dsstate
+
+import (
+	"sync"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+)
+
+type cidSet map[api.Cid]struct{}
+
+func (s cidSet) add(c api.Cid) {
+	s[c] = struct{}{}
+}
+
+// indexEntry is what is indexed for a pin, so that it can be removed from
+// the index when the pin changes.
+type indexEntry struct {
+	name     string
+	metadata map[string]string
+}
+
+func (e indexEntry) match(search api.PinSearch) bool {
+	return search.Match(api.Pin{
+		PinOptions: api.PinOptions{
+			Name:     e.name,
+			Metadata: e.metadata,
+		},
+	})
+}
+
+// pinIndex maps pin names and metadata to the pins which have them. Pins
+// without name nor metadata are not indexed.
+type pinIndex struct {
+	names map[string]cidSet
+	// metadata key -> value -> pins
+	metadata map[string]map[string]cidSet
+	entries  map[api.Cid]indexEntry
+}
+
+func newPinIndex() *pinIndex {
+	return &pinIndex{
+		names:    make(map[string]cidSet),
+		metadata: make(map[string]map[string]cidSet),
+		entries:  make(map[api.Cid]indexEntry),
+	}
+}
+
+func (pi *pinIndex) add(p api.Pin) {
+	pi.rm(p.Cid)
+	if p.Name == "" && len(p.Metadata) == 0 {
+		return
+	}
+
+	entry := indexEntry{
+		name:     p.Name,
+		metadata: make(map[string]string, len(p.Metadata)),
+	}
+	if p.Name != "" {
+		set, ok := pi.names[p.Name]
+		if !ok {
+			set = make(cidSet)
+			pi.names[p.Name] = set
+		}
+		set.add(p.Cid)
+	}
+	for k, v := range p.Metadata {
+		entry.metadata[k] = v
+		values, ok := pi.metadata[k]
+		if !ok {
+			values = make(map[string]cidSet)
+			pi.metadata[k] = values
+		}
+		set, ok := values[v]
+		if !ok {
+			set = make(cidSet)
+			values[v] = set
+		}
+		set.add(p.Cid)
+	}
+	pi.entries[p.Cid] = entry
+}
+
+func (pi *pinIndex) rm(c api.Cid) {
+	entry, ok := pi.entries[c]
+	if !ok {
+		return
+	}
+	delete(pi.entries, c)
+
+	if set, ok := pi.names[entry.name]; ok {
+		delete(set, c)
+		if len(set) == 0 {
+			delete(pi.names, entry.name)
+		}
+	}
+	for k, v := range entry.metadata {
+		values := pi.metadata[k]
+		delete(values[v], c)
+		if len(values[v]) == 0 {
+			delete(values, v)
+		}
+		if len(values) == 0 {
+			delete(pi.metadata, k)
+		}
+	}
+}
+
+// find returns the pins matching the search. It starts from the smallest
+// set of pins selected by one of the criteria and checks the others on
+// their entries.
+func (pi *pinIndex) find(search api.PinSearch) []api.Cid {
+	var candidates []cidSet
+	if search.Name != "" {
+		candidates = append(candidates, pi.names[search.Name])
+	}
+	for k, v := range search.Metadata {
+		values := pi.metadata[k]
+		if v != "" {
+			candidates = append(candidates, values[v])
+			continue
+		}
+		// any value of the key
+		all := make(cidSet)
+		for _, set := range values {
+			for c := range set {
+				all.add(c)
+			}
+		}
+		candidates = append(candidates, all)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	smallest := candidates[0]
+	for _, set := range candidates[1:] {
+		if len(set) < len(smallest) {
+			smallest = set
+		}
+	}
+
+	var found []api.Cid
+	for c := range smallest {
+		if pi.entries[c].match(search) {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+// indexUpdate is an update received while the index is being built.
+type indexUpdate struct {
+	pin api.Pin
+	rm  bool
+}
+
+// index is the search index of a State. It is kept in memory and built
+// from the full pinset on the first search. From then on, it is updated
+// with every change. Updates received while it is being built are applied
+// once the pinset has been listed, so that they are not lost.
+type index struct {
+	// serializes builds
+	buildMu sync.Mutex
+
+	mu       sync.Mutex
+	built    bool
+	building bool
+	pending  []indexUpdate
+	pins     *pinIndex
+}
+
+func newIndex() *index {
+	return &index{}
+}
+
+func (idx *index) apply(u indexUpdate) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	switch {
+	case idx.building:
+		idx.pending = append(idx.pending, u)
+	case !idx.built:
+	case u.rm:
+		idx.pins.rm(u.pin.Cid)
+	default:
+		idx.pins.add(u.pin)
+	}
+}
+
+func (idx *index) add(p api.Pin) {
+	idx.apply(indexUpdate{pin: p})
+}
+
+func (idx *index) rm(c api.Cid) {
+	idx.apply(indexUpdate{pin: api.PinCid(c), rm: true})
+}
+
+// reset discards the index. It is built again on the next search.
+func (idx *index) reset() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.built = false
+	idx.pins = nil
+}
+
+// build builds the index, unless it is built already, with the pins sent
+// by the given list function.
+func (idx *index) build(list func(chan<- api.Pin) error) error {
+	idx.buildMu.Lock()
+	defer idx.buildMu.Unlock()
+
+	idx.mu.Lock()
+	if idx.built {
+		idx.mu.Unlock()
+		return nil
+	}
+	idx.building = true
+	idx.pending = nil
+	idx.mu.Unlock()
+
+	pins := newPinIndex()
+	ch := make(chan api.Pin, 1024)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- list(ch)
+	}()
+	for p := range ch {
+		pins.add(p)
+	}
+	err := <-errCh
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.building = false
+	if err == nil {
+		for _, u := range idx.pending {
+			if u.rm {
+				pins.rm(u.pin.Cid)
+			} else {
+				pins.add(u.pin)
+			}
+		}
+		idx.pins = pins
+		idx.built = true
+	}
+	idx.pending = nil
+	return err
+}
+
+func (idx *index) find(search api.PinSearch) []api.Cid {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if !idx.built {
+		return nil
+	}
+	return idx.pins.find(search)
+}
@@ -13,6 +13,11 @@ func (e *empty) List(ctx context.Context, out chan<- api.Pin) error {
 	return nil
 }
 
+func (e *empty) ListAfter(ctx context.Context, after api.Cid, out chan<- api.Pin) error {
+	close(out)
+	return nil
+}
+
 func (e *empty) Has(ctx context.Context, c api.Cid) (bool, error) {
 	return false, nil
 }
@@ -21,6 +26,11 @@ func (e *empty) Get(ctx context.Context, c api.Cid) (api.Pin, error) {
 	return api.Pin{}, ErrNotFound
 }
 
+func (e *empty) Search(ctx context.Context, search api.PinSearch, out chan<- api.Pin) error {
+	close(out)
+	return nil
+}
+
 // Empty returns an empty read-only state.
 func Empty() ReadOnly {
 	return &empty{}
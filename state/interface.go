@@ -33,11 +33,17 @@ type State interface {
 type ReadOnly interface {
 	// List lists all the pins in the state.
 	List(context.Context, chan<- api.Pin) error
+	// ListAfter lists the pins in the state which come after the given
+	// one, in a stable order. An undefined cid lists all of them.
+	ListAfter(context.Context, api.Cid, chan<- api.Pin) error
 	// Has returns true if the state is holding information for a Cid.
 	Has(context.Context, api.Cid) (bool, error)
 	// Get returns the information attacthed to this pin, if any. If the
 	// pin is not part of the state, it should return ErrNotFound.
 	Get(context.Context, api.Cid) (api.Pin, error)
+	// Search sends the pins matching the given search on the channel,
+	// and closes it when done.
+	Search(context.Context, api.PinSearch, chan<- api.Pin) error
 }
 
 // WriteOnly represents the write side of a State.
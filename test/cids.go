@@ -22,6 +22,10 @@ var (
 	// ErrorCid is meant to be used as a Cid which causes errors. i.e. the
 	// ipfs mock fails when pinning this CID.
 	ErrorCid, _ = api.DecodeCid("QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmmc")
+	// UnallocatableCid is meant to be used as a Cid for which there are
+	// never enough peers to allocate it. The mock cluster fails when
+	// pinning this CID.
+	UnallocatableCid, _ = api.DecodeCid("QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmme")
 	// NotFoundCid is meant to be used as a CID that doesn't exist in the
 	// pinset.
 	NotFoundCid, _ = api.DecodeCid("bafyreiay3jpjk74dkckv2r74eyvf3lfnxujefay2rtuluintasq2zlapv4")
@@ -42,6 +46,11 @@ var (
 	PeerName5 = "TestPeer5"
 	PeerName6 = "TestPeer6"
 
+	// Group1 is the group of Cid1 and Cid3 in the mock cluster.
+	Group1 = "TestGroup1"
+	// Owner3 is the owner of Cid3 in the mock cluster.
+	Owner3 = "user:TestOwner3"
+
 	PathIPFS1 = "/ipfs/QmaNJ5acV31sx8jq626qTpAWW4DXKw34aGhx53dECLvXbY"
 	PathIPFS2 = "/ipfs/QmbUNM297ZwxB8CfFAznK7H9YMesDoY6Tt5bPgt5MSCB2u/im.gif"
 	PathIPFS3 = "/ipfs/QmbUNM297ZwxB8CfFAznK7H9YMesDoY6Tt5bPgt5MSCB2u/im.gif/"
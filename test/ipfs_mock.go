@@ -31,6 +31,7 @@ const (
 	IpfsCustomHeaderValue = "42"
 	IpfsACAOrigin         = "myorigin"
 	IpfsErrFromNotPinned  = "'from' cid was not recursively pinned already"
+	IpfsDagSize           = 10 * 1024 * 1024 // cumulative size of every DAG
 )
 
 // IpfsMock is an ipfs daemon mock which should sustain the functionality used by ipfscluster.
@@ -45,6 +46,9 @@ type IpfsMock struct {
 	reqCountsMux sync.Mutex // guards access to reqCounts
 	reqCounts    map[string]int
 
+	mfsMux  sync.Mutex
+	mfsRoot cid.Cid
+
 	closeMux sync.Mutex
 	closed   bool
 }
@@ -101,10 +105,25 @@ type mockBlockPutResp struct {
 	Key string
 }
 
+type mockBlockStatResp struct {
+	Key  string
+	Size int
+}
+
+type mockObjectStatResp struct {
+	Hash           string
+	CumulativeSize uint64
+}
+
 type mockDagPutResp struct {
 	Cid cid.Cid
 }
 
+type mockFilesStatResp struct {
+	Hash string
+	Type string
+}
+
 type mockRepoGCResp struct {
 	Key   cid.Cid `json:",omitempty"`
 	Error string  `json:",omitempty"`
@@ -118,7 +137,11 @@ func NewIpfsMock(t *testing.T) *IpfsMock {
 		t.Fatal(err)
 	}
 
+	// The CID of an empty directory.
+	mfsRoot, _ := cid.Decode("QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+
 	m := &IpfsMock{
+		mfsRoot:    mfsRoot,
 		pinMap:     st,
 		BlockStore: make(map[string][]byte),
 		reqCounts:  make(map[string]int),
@@ -444,6 +467,39 @@ func (m *IpfsMock) handler(w http.ResponseWriter, r *http.Request) {
 			goto ERROR
 		}
 		w.Write(data)
+	case "block/stat":
+		arg, ok := extractCid(r.URL)
+		if !ok {
+			goto ERROR
+		}
+		data, ok := m.BlockStore[arg]
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			resp := ipfsErr{0, "block was not found locally (offline): ipld: could not find " + arg}
+			j, _ := json.Marshal(resp)
+			w.Write(j)
+			return
+		}
+		resp := mockBlockStatResp{
+			Key:  arg,
+			Size: len(data),
+		}
+		j, _ := json.Marshal(resp)
+		w.Write(j)
+	case "object/stat":
+		arg, ok := extractCid(r.URL)
+		if !ok {
+			goto ERROR
+		}
+		if arg == ErrorCid.String() {
+			goto ERROR
+		}
+		resp := mockObjectStatResp{
+			Hash:           arg,
+			CumulativeSize: IpfsDagSize,
+		}
+		j, _ := json.Marshal(resp)
+		w.Write(j)
 	case "dag/put":
 		// DAG-put is a fake implementation as we are not going to
 		// parse the input and we are just going to hash it and return
@@ -503,6 +559,33 @@ func (m *IpfsMock) handler(w http.ResponseWriter, r *http.Request) {
 			j, _ := json.Marshal(resp)
 			w.Write(j)
 		}
+	case "files/write", "files/cp":
+		// Every change gives the MFS root a new CID, unless
+		// not flushed.
+		if r.URL.Query().Get("flush") != "false" {
+			m.mfsMux.Lock()
+			m.mfsRoot, _ = cid.V1Builder{
+				Codec:  cid.DagProtobuf,
+				MhType: uint64(multicodec.Sha2_256),
+			}.Sum([]byte(m.mfsRoot.String()))
+			m.mfsMux.Unlock()
+		}
+		w.WriteHeader(http.StatusOK)
+	case "files/flush":
+		m.mfsMux.Lock()
+		resp := mockDagPutResp{Cid: m.mfsRoot}
+		m.mfsMux.Unlock()
+		j, _ := json.Marshal(resp)
+		w.Write(j)
+	case "files/stat":
+		m.mfsMux.Lock()
+		resp := mockFilesStatResp{
+			Hash: m.mfsRoot.String(),
+			Type: "directory",
+		}
+		m.mfsMux.Unlock()
+		j, _ := json.Marshal(resp)
+		w.Write(j)
 	case "repo/gc":
 		// It assumes `/repo/gc` with parameter `stream-errors=true`
 		enc := json.NewEncoder(w)
@@ -3,6 +3,7 @@ package test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
@@ -69,6 +70,9 @@ func (mock *mockCluster) Pin(ctx context.Context, in api.Pin, out *api.Pin) erro
 	if in.Cid.Equals(ErrorCid) {
 		return ErrBadCid
 	}
+	if in.Cid.Equals(UnallocatableCid) {
+		return fmt.Errorf("%w. Needed at least: 1. Wanted at most: 1. Available candidates: 0.", api.ErrInsufficientAllocations)
+	}
 
 	// a pin is never returned the replications set to 0.
 	if in.ReplicationFactorMin == 0 {
@@ -92,6 +96,108 @@ func (mock *mockCluster) Unpin(ctx context.Context, in api.Pin, out *api.Pin) er
 	return nil
 }
 
+func (mock *mockCluster) UndoUnpin(ctx context.Context, in api.Cid, out *api.Pin) error {
+	if in.Equals(ErrorCid) {
+		return ErrBadCid
+	}
+	*out = api.PinCid(in)
+	return nil
+}
+
+func (mock *mockCluster) Tombstone(ctx context.Context, in api.Cid, out *api.Pin) error {
+	if in.Equals(ErrorCid) {
+		return ErrBadCid
+	}
+	p := api.PinCid(in)
+	if in.Equals(Cid3) {
+		p.Owner = Owner3
+	}
+	*out = p
+	return nil
+}
+
+func (mock *mockCluster) Events(ctx context.Context, in <-chan struct{}, out chan<- api.Event) error {
+	defer close(out)
+	events := []api.Event{
+		{
+			Type: api.EventPinStatus,
+			Peer: PeerID1,
+			PinInfo: &api.PinInfo{
+				Cid:  Cid1,
+				Peer: PeerID1,
+				PinInfoShort: api.PinInfoShort{
+					Status: api.TrackerStatusPinned,
+					TS:     time.Now(),
+				},
+			},
+		},
+		{
+			Type: api.EventPeerJoined,
+			Peer: PeerID2,
+		},
+	}
+	for _, ev := range events {
+		ev.Timestamp = time.Now()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case out <- ev:
+		}
+	}
+	return nil
+}
+
+func (mock *mockCluster) PinBatch(ctx context.Context, in []api.BatchItem, out *[]api.BatchResult) error {
+	results := make([]api.BatchResult, len(in))
+	for i, item := range in {
+		results[i] = api.BatchResult{
+			Action: item.Action,
+			Cid:    item.Cid,
+		}
+		var err error
+		switch item.Action {
+		case api.BatchActionPin:
+			err = mock.Pin(ctx, api.PinWithOpts(item.Cid, item.Options), &results[i].Pin)
+		case api.BatchActionUnpin:
+			err = mock.Unpin(ctx, api.PinCid(item.Cid), &results[i].Pin)
+		default:
+			err = fmt.Errorf("unknown action: %q", item.Action)
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+	*out = results
+	return nil
+}
+
+func (mock *mockCluster) PinEdit(ctx context.Context, in api.PinEdit, out *api.Pin) error {
+	if in.Cid.Equals(ErrorCid) {
+		return ErrBadCid
+	}
+	if in.Cid.Equals(NotFoundCid) {
+		return state.ErrNotFound
+	}
+	pin := api.PinCid(in.Cid)
+	if in.Name != nil {
+		pin.Name = *in.Name
+	}
+	if in.Group != nil {
+		pin.Group = *in.Group
+	}
+	for k, v := range in.Metadata {
+		if v == "" {
+			continue
+		}
+		if pin.Metadata == nil {
+			pin.Metadata = make(map[string]string)
+		}
+		pin.Metadata[k] = v
+	}
+	*out = pin
+	return nil
+}
+
 func (mock *mockCluster) PinPath(ctx context.Context, in api.PinPath, out *api.Pin) error {
 	p, err := gopath.ParsePath(in.Path)
 	if err != nil {
@@ -139,6 +245,95 @@ func (mock *mockCluster) Pins(ctx context.Context, in <-chan struct{}, out chan<
 	return nil
 }
 
+func (mock *mockCluster) SearchPins(ctx context.Context, in <-chan api.PinSearch, out chan<- api.Pin) error {
+	defer close(out)
+	search := <-in
+	p := api.PinWithOpts(Cid1, api.PinOptions{
+		ReplicationFactorMin: -1,
+		ReplicationFactorMax: -1,
+		Name:                 "foo",
+		Metadata:             map[string]string{"hello": "world"},
+	})
+	if search.Match(p) {
+		out <- p
+	}
+	return nil
+}
+
+func (mock *mockCluster) Groups(ctx context.Context, in struct{}, out *[]api.PinGroup) error {
+	*out = []api.PinGroup{
+		{Name: Group1, Pins: 2},
+	}
+	return nil
+}
+
+func (mock *mockCluster) GroupPins(ctx context.Context, in <-chan string, out chan<- api.Pin) error {
+	defer close(out)
+	group := <-in
+	if group != Group1 {
+		return nil
+	}
+	for _, c := range []api.Cid{Cid1, Cid3} {
+		p := api.PinWithOpts(c, api.PinOptions{
+			ReplicationFactorMin: -1,
+			ReplicationFactorMax: -1,
+			Group:                Group1,
+		})
+		if c.Equals(Cid3) {
+			p.Owner = Owner3
+		}
+		out <- p
+	}
+	return nil
+}
+
+func (mock *mockCluster) UnpinGroup(ctx context.Context, in string, out *[]api.BatchResult) error {
+	var results []api.BatchResult
+	if in == Group1 {
+		for _, c := range []api.Cid{Cid1, Cid3} {
+			results = append(results, api.BatchResult{
+				Action: api.BatchActionUnpin,
+				Cid:    c,
+				Pin:    api.PinCid(c),
+			})
+		}
+	}
+	*out = results
+	return nil
+}
+
+func (mock *mockCluster) SetGroupReplication(ctx context.Context, in api.PinOptions, out *[]api.BatchResult) error {
+	var results []api.BatchResult
+	if in.Group == Group1 {
+		for _, c := range []api.Cid{Cid1, Cid3} {
+			results = append(results, api.BatchResult{
+				Action: api.BatchActionPin,
+				Cid:    c,
+				Pin:    api.PinWithOpts(c, in),
+			})
+		}
+	}
+	*out = results
+	return nil
+}
+
+func (mock *mockCluster) Reallocate(ctx context.Context, in api.Reallocation, out *[]api.BatchResult) error {
+	opts := api.PinOptions{
+		ReplicationFactorMin: in.ReplicationFactorMin,
+		ReplicationFactorMax: in.ReplicationFactorMax,
+	}
+	var results []api.BatchResult
+	for _, c := range in.Cids {
+		results = append(results, api.BatchResult{
+			Action: api.BatchActionPin,
+			Cid:    c,
+			Pin:    api.PinWithOpts(c, opts),
+		})
+	}
+	*out = results
+	return nil
+}
+
 func (mock *mockCluster) PinGet(ctx context.Context, in api.Cid, out *api.Pin) error {
 	switch in.String() {
 	case ErrorCid.String():
@@ -147,6 +342,9 @@ func (mock *mockCluster) PinGet(ctx context.Context, in api.Cid, out *api.Pin) e
 		p := api.PinCid(in)
 		p.ReplicationFactorMin = -1
 		p.ReplicationFactorMax = -1
+		if in.Equals(Cid3) {
+			p.Owner = Owner3
+		}
 		*out = p
 		return nil
 	case Cid2.String(): // This is a remote pin
@@ -222,6 +420,35 @@ func (mock *mockCluster) PeerRemove(ctx context.Context, in peer.ID, out *struct
 	return nil
 }
 
+func (mock *mockCluster) TrustPeer(ctx context.Context, in peer.ID, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockCluster) DistrustPeer(ctx context.Context, in peer.ID, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockCluster) TrustedPeers(ctx context.Context, in struct{}, out *[]peer.ID) error {
+	*out = []peer.ID{PeerID1, PeerID2}
+	return nil
+}
+
+func (mock *mockCluster) OperationLog(ctx context.Context, in api.OpLogOptions, out *[]api.ConsensusOp) error {
+	*out = []api.ConsensusOp{
+		{
+			ID:     "2",
+			Height: 2,
+			Unpins: []api.Cid{Cid1},
+		},
+		{
+			ID:     "1",
+			Height: 1,
+			Pins:   []api.Pin{api.PinCid(Cid1)},
+		},
+	}
+	return nil
+}
+
 func (mock *mockCluster) ConnectGraph(ctx context.Context, in struct{}, out *api.ConnectGraph) error {
 	*out = api.ConnectGraph{
 		ClusterID: PeerID1,
@@ -249,37 +476,51 @@ func (mock *mockCluster) StatusAll(ctx context.Context, in <-chan api.TrackerSta
 	filter := <-in
 
 	pid := PeerID1.String()
+	ma, _ := api.NewMultiaddr("/ip4/1.2.3.4/ipfs/" + PeerID4.String())
+	now := time.Now()
 	gPinInfos := []api.GlobalPinInfo{
 		{
-			Cid:  Cid1,
-			Name: "aaa",
+			Cid:     Cid1,
+			Name:    "aaa",
+			Group:   Group1,
+			Created: now.Add(-time.Minute),
 			PeerMap: map[string]api.PinInfoShort{
 				pid: {
-					Status: api.TrackerStatusPinned,
-					TS:     time.Now(),
+					IPFS:          PeerID4,
+					IPFSAddresses: []api.Multiaddr{ma},
+					Status:        api.TrackerStatusPinned,
+					TS:            now,
 				},
 			},
 		},
 		{
-			Cid:  Cid2,
-			Name: "bbb",
+			Cid:     Cid2,
+			Name:    "bbb",
+			Created: now.Add(-2 * time.Minute),
+			Origins: []api.Multiaddr{ma},
 			PeerMap: map[string]api.PinInfoShort{
 				pid: {
-					Status: api.TrackerStatusPinning,
-					TS:     time.Now(),
+					IPFS:          PeerID4,
+					IPFSAddresses: []api.Multiaddr{ma},
+					Status:        api.TrackerStatusPinning,
+					TS:            now,
 				},
 			},
 		},
 		{
-			Cid:  Cid3,
-			Name: "ccc",
+			Cid:     Cid3,
+			Name:    "ccc",
+			Group:   Group1,
+			Created: now.Add(-3 * time.Minute),
 			Metadata: map[string]string{
 				"ccc": "3c",
 			},
 			PeerMap: map[string]api.PinInfoShort{
 				pid: {
-					Status: api.TrackerStatusPinError,
-					TS:     time.Now(),
+					IPFS:          PeerID4,
+					IPFSAddresses: []api.Multiaddr{ma},
+					Status:        api.TrackerStatusPinError,
+					TS:            now,
 				},
 			},
 		},
@@ -303,6 +544,45 @@ func (mock *mockCluster) StatusAll(ctx context.Context, in <-chan api.TrackerSta
 	return nil
 }
 
+func (mock *mockCluster) StatusAllFiltered(ctx context.Context, in <-chan api.StatusAllFilter, out chan<- api.GlobalPinInfo) error {
+	defer close(out)
+	filter := <-in
+
+	stIn := make(chan api.TrackerStatus, 1)
+	stIn <- filter.Status
+	close(stIn)
+	stOut := make(chan api.GlobalPinInfo, 3)
+	err := mock.StatusAll(ctx, stIn, stOut)
+	if err != nil {
+		return err
+	}
+	skip := filter.Offset
+	sent := 0
+	for gpi := range stOut {
+		pi := api.PinInfo{
+			Cid:         gpi.Cid,
+			Name:        gpi.Name,
+			Group:       gpi.Group,
+			Allocations: gpi.Allocations,
+			Created:     gpi.Created,
+			Metadata:    gpi.Metadata,
+		}
+		if !filter.Match(pi) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if filter.Limit > 0 && sent >= filter.Limit {
+			break
+		}
+		out <- gpi
+		sent++
+	}
+	return nil
+}
+
 func (mock *mockCluster) StatusAllLocal(ctx context.Context, in <-chan api.TrackerStatus, out chan<- api.PinInfo) error {
 	return (&mockPinTracker{}).StatusAll(ctx, in, out)
 }
@@ -313,6 +593,11 @@ func (mock *mockCluster) Status(ctx context.Context, in api.Cid, out *api.Global
 	}
 	ma, _ := api.NewMultiaddr("/ip4/1.2.3.4/ipfs/" + PeerID3.String())
 
+	st := api.TrackerStatusPinned
+	if in.Equals(SlowCid1) {
+		st = api.TrackerStatusPinning
+	}
+
 	*out = api.GlobalPinInfo{
 		Cid:         in,
 		Name:        "test",
@@ -327,7 +612,7 @@ func (mock *mockCluster) Status(ctx context.Context, in api.Cid, out *api.Global
 				PeerName:      PeerName3,
 				IPFS:          PeerID3,
 				IPFSAddresses: []api.Multiaddr{ma},
-				Status:        api.TrackerStatusPinned,
+				Status:        st,
 				TS:            time.Now(),
 			},
 		},
@@ -350,6 +635,17 @@ func (mock *mockCluster) RecoverAllLocal(ctx context.Context, in <-chan struct{}
 	return (&mockPinTracker{}).RecoverAll(ctx, in, out)
 }
 
+func (mock *mockCluster) RecoverPermanent(ctx context.Context, in <-chan struct{}, out chan<- api.GlobalPinInfo) error {
+	f := make(chan api.TrackerStatus, 1)
+	f <- api.TrackerStatusError
+	close(f)
+	return mock.StatusAll(ctx, f, out)
+}
+
+func (mock *mockCluster) RecoverPermanentLocal(ctx context.Context, in <-chan struct{}, out chan<- api.PinInfo) error {
+	return (&mockPinTracker{}).RecoverAll(ctx, in, out)
+}
+
 func (mock *mockCluster) Recover(ctx context.Context, in api.Cid, out *api.GlobalPinInfo) error {
 	return mock.Status(ctx, in, out)
 }
@@ -358,6 +654,14 @@ func (mock *mockCluster) RecoverLocal(ctx context.Context, in api.Cid, out *api.
 	return (&mockPinTracker{}).Recover(ctx, in, out)
 }
 
+func (mock *mockCluster) AcquireBudget(ctx context.Context, in struct{}, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockCluster) ReleaseBudget(ctx context.Context, in struct{}, out *struct{}) error {
+	return nil
+}
+
 func (mock *mockCluster) BlockAllocate(ctx context.Context, in api.Pin, out *[]peer.ID) error {
 	if in.ReplicationFactorMin > 1 {
 		return errors.New("replMin too high: can only mock-allocate to 1")
@@ -377,9 +681,42 @@ func (mock *mockCluster) RepoGC(ctx context.Context, in struct{}, out *api.Globa
 	return nil
 }
 
+func (mock *mockCluster) GarbageCollect(ctx context.Context, in int, out *api.GlobalRepoGC) error {
+	return mock.RepoGC(ctx, struct{}{}, out)
+}
+
+func (mock *mockCluster) PinTrackerConcurrency(ctx context.Context, in struct{}, out *[]api.PinTrackerConcurrency) error {
+	var conc api.PinTrackerConcurrency
+	err := (&mockPinTracker{}).Concurrency(ctx, struct{}{}, &conc)
+	*out = []api.PinTrackerConcurrency{conc}
+	return err
+}
+
+func (mock *mockCluster) SetPinTrackerConcurrency(ctx context.Context, in api.PinTrackerConcurrency, out *[]api.PinTrackerConcurrency) error {
+	var conc api.PinTrackerConcurrency
+	err := (&mockPinTracker{}).SetConcurrency(ctx, in, &conc)
+	*out = []api.PinTrackerConcurrency{conc}
+	return err
+}
+
+func (mock *mockCluster) PinTrackerPause(ctx context.Context, in struct{}, out *[]api.PinTrackerConcurrency) error {
+	var conc api.PinTrackerConcurrency
+	err := (&mockPinTracker{}).Pause(ctx, struct{}{}, &conc)
+	*out = []api.PinTrackerConcurrency{conc}
+	return err
+}
+
+func (mock *mockCluster) PinTrackerResume(ctx context.Context, in struct{}, out *[]api.PinTrackerConcurrency) error {
+	var conc api.PinTrackerConcurrency
+	err := (&mockPinTracker{}).Resume(ctx, struct{}{}, &conc)
+	*out = []api.PinTrackerConcurrency{conc}
+	return err
+}
+
 func (mock *mockCluster) RepoGCLocal(ctx context.Context, in struct{}, out *api.RepoGC) error {
 	*out = api.RepoGC{
-		Peer: PeerID1,
+		Peer:      PeerID1,
+		Reclaimed: 1024,
 		Keys: []api.IPFSRepoGC{
 			{
 				Key: Cid1,
@@ -422,6 +759,47 @@ func (mock *mockCluster) Alerts(ctx context.Context, in struct{}, out *[]api.Ale
 	return nil
 }
 
+func (mock *mockCluster) HealthCheck(ctx context.Context, in struct{}, out *api.HealthReport) error {
+	*out = api.HealthReport{
+		Peer:    PeerID1,
+		Healthy: true,
+		Components: []api.ComponentHealth{
+			{Component: api.HealthComponentIPFS, Healthy: true, Latency: 1},
+			{Component: api.HealthComponentConsensus, Healthy: true},
+			{Component: api.HealthComponentDatastore, Healthy: true},
+			{Component: api.HealthComponentMonitor, Healthy: true},
+		},
+	}
+	return nil
+}
+
+func (mock *mockCluster) VerifyPin(ctx context.Context, in api.PinVerifyRequest, out *api.PinVerification) error {
+	var pin api.Pin
+	if err := mock.PinGet(ctx, in.Cid, &pin); err != nil {
+		return err
+	}
+	res := api.PinVerification{
+		Cid:     in.Cid,
+		Blocks:  3,
+		Checked: 3,
+		Peers: []api.PeerVerification{
+			{Peer: PeerID1, Peername: PeerName1},
+		},
+	}
+	if in.Sample > 0 && in.Sample < res.Blocks {
+		res.Checked = in.Sample
+	}
+	if in.Cid.Equals(Cid2) { // a block is missing on the remote peer
+		res.Peers = append(res.Peers, api.PeerVerification{
+			Peer:     PeerID2,
+			Peername: PeerName2,
+			Missing:  []api.Cid{Cid4},
+		})
+	}
+	*out = res
+	return nil
+}
+
 func (mock *mockCluster) IPFSID(ctx context.Context, in peer.ID, out *api.IPFSID) error {
 	var id api.ID
 	_ = mock.ID(ctx, struct{}{}, &id)
@@ -507,6 +885,50 @@ func (mock *mockPinTracker) PinQueueSize(ctx context.Context, in struct{}, out *
 	return nil
 }
 
+func (mock *mockPinTracker) Concurrency(ctx context.Context, in struct{}, out *api.PinTrackerConcurrency) error {
+	*out = api.PinTrackerConcurrency{
+		Peer:     PeerID1,
+		Peername: PeerName1,
+		Pins:     10,
+		MaxPins:  10,
+		Unpins:   1,
+	}
+	return nil
+}
+
+func (mock *mockPinTracker) SetConcurrency(ctx context.Context, in api.PinTrackerConcurrency, out *api.PinTrackerConcurrency) error {
+	if in.Pins < 0 || in.Unpins < 0 {
+		return errors.New("concurrency cannot be negative")
+	}
+	conc := api.PinTrackerConcurrency{
+		Peer:     PeerID1,
+		Peername: PeerName1,
+		Pins:     10,
+		MaxPins:  10,
+		Unpins:   1,
+		Auto:     in.Auto,
+	}
+	if in.Pins > 0 {
+		conc.Pins = in.Pins
+		conc.MaxPins = in.Pins
+	}
+	if in.Unpins > 0 {
+		conc.Unpins = in.Unpins
+	}
+	*out = conc
+	return nil
+}
+
+func (mock *mockPinTracker) Pause(ctx context.Context, in struct{}, out *api.PinTrackerConcurrency) error {
+	err := mock.Concurrency(ctx, in, out)
+	out.Paused = true
+	return err
+}
+
+func (mock *mockPinTracker) Resume(ctx context.Context, in struct{}, out *api.PinTrackerConcurrency) error {
+	return mock.Concurrency(ctx, in, out)
+}
+
 /* PeerMonitor methods */
 
 // LatestMetrics runs PeerMonitor.LatestMetrics().
@@ -540,6 +962,15 @@ func (mock *mockIPFSConnector) Pin(ctx context.Context, in api.Pin, out *struct{
 	return nil
 }
 
+func (mock *mockIPFSConnector) PinProgress(ctx context.Context, in api.Cid, out *api.PinProgress) error {
+	*out = api.PinProgress{
+		Blocks: 4,
+		Bytes:  4 * 256 * 1024,
+		Size:   IpfsDagSize,
+	}
+	return nil
+}
+
 func (mock *mockIPFSConnector) Unpin(ctx context.Context, in api.Pin, out *struct{}) error {
 	switch in.Cid {
 	case SlowCid1:
@@ -579,6 +1010,13 @@ func (mock *mockIPFSConnector) ConfigKey(ctx context.Context, in string, out *in
 	return nil
 }
 
+func (mock *mockIPFSConnector) ID(ctx context.Context, in struct{}, out *api.IPFSID) error {
+	*out = api.IPFSID{
+		ID: PeerID1,
+	}
+	return nil
+}
+
 func (mock *mockIPFSConnector) RepoStat(ctx context.Context, in struct{}, out *api.IPFSRepoStat) error {
 	// since we have two pins. Assume each is 1000B.
 	stat := api.IPFSRepoStat{
@@ -594,10 +1032,17 @@ func (mock *mockIPFSConnector) BlockStream(ctx context.Context, in <-chan api.No
 	return nil
 }
 
+func (mock *mockIPFSConnector) BlocksMissing(ctx context.Context, in []api.Cid, out *[]api.Cid) error {
+	*out = []api.Cid{}
+	return nil
+}
+
 func (mock *mockIPFSConnector) Resolve(ctx context.Context, in string, out *api.Cid) error {
 	switch in {
 	case ErrorCid.String(), "/ipfs/" + ErrorCid.String():
 		*out = ErrorCid
+	case "/ipfs/" + Cid3.String():
+		*out = Cid3
 	default:
 		*out = Cid2
 	}
@@ -612,7 +1057,16 @@ func (mock *mockConsensus) RmPeer(ctx context.Context, in peer.ID, out *struct{}
 	return errors.New("mock rpc cannot redirect")
 }
 
+func (mock *mockConsensus) Leader(ctx context.Context, in struct{}, out *peer.ID) error {
+	*out = PeerID1
+	return nil
+}
+
 func (mock *mockConsensus) Peers(ctx context.Context, in struct{}, out *[]peer.ID) error {
 	*out = []peer.ID{PeerID1, PeerID2, PeerID3}
 	return nil
 }
+
+func (mock *mockConsensus) EncryptionKey(ctx context.Context, in string, out *[]byte) error {
+	return errors.New("mock rpc has no encryption keys")
+}
@@ -0,0 +1,122 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	"go.opencensus.io/trace"
+)
+
+var errNoTombstone = errors.New("the unpin cannot be undone: it was not handled by this peer, or its grace period is over")
+
+// tombstones keeps the pins unpinned by this peer during the
+// UnpinGracePeriod, so that the unpins can be undone.
+type tombstones struct {
+	mu   sync.Mutex
+	pins map[api.Cid]tombstone
+}
+
+type tombstone struct {
+	pin     api.Pin
+	expires time.Time
+}
+
+func newTombstones() *tombstones {
+	return &tombstones{
+		pins: make(map[api.Cid]tombstone),
+	}
+}
+
+// add records the given pins until the grace period is over. Expired
+// tombstones are removed at the same time.
+func (ts *tombstones) add(grace time.Duration, pins ...api.Pin) {
+	if grace <= 0 || len(pins) == 0 {
+		return
+	}
+
+	now := time.Now()
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for c, t := range ts.pins {
+		if now.After(t.expires) {
+			delete(ts.pins, c)
+		}
+	}
+	for _, p := range pins {
+		ts.pins[p.Cid] = tombstone{
+			pin:     p,
+			expires: now.Add(grace),
+		}
+	}
+}
+
+// get returns the tombstone for the given CID, if it has not expired.
+func (ts *tombstones) get(c api.Cid) (api.Pin, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t, ok := ts.pins[c]
+	if !ok || time.Now().After(t.expires) {
+		return api.Pin{}, false
+	}
+	return t.pin, true
+}
+
+// take removes and returns the tombstone for the given CID, if it has not
+// expired.
+func (ts *tombstones) take(c api.Cid) (api.Pin, bool) {
+	pin, ok := ts.get(c)
+	ts.mu.Lock()
+	delete(ts.pins, c)
+	ts.mu.Unlock()
+	return pin, ok
+}
+
+// Tombstone returns the Pin object of an item unpinned by this peer during
+// the last UnpinGracePeriod, as it was before the unpin, so that it can be
+// checked before undoing it with UndoUnpin.
+func (c *Cluster) Tombstone(ctx context.Context, h api.Cid) (api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/Tombstone")
+	defer span.End()
+
+	pin, ok := c.tombstones.get(h)
+	if !ok {
+		return api.Pin{}, errNoTombstone
+	}
+	return pin, nil
+}
+
+// UndoUnpin pins again an item unpinned by this peer during the last
+// UnpinGracePeriod, with the options that it had. It is allocated like any
+// other pin, as the peers it was allocated to may have left or reached
+// their quota since. Peers delay unpinning items in IPFS during that time,
+// so those allocated again do not need to fetch them. It returns the
+// restored Pin object.
+func (c *Cluster) UndoUnpin(ctx context.Context, h api.Cid) (api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/UndoUnpin")
+	defer span.End()
+
+	if c.config.FollowerMode {
+		return api.Pin{}, errFollowerMode
+	}
+
+	pin, ok := c.tombstones.take(h)
+	if !ok {
+		return api.Pin{}, errNoTombstone
+	}
+
+	if existing, err := c.PinGet(ctx, h); err == nil {
+		// pinned again in the meantime.
+		return existing, nil
+	}
+
+	logger.Infof("IPFS cluster undoing unpin: %s", h)
+	opts := pin.PinOptions
+	opts.PinUpdate = api.CidUndef
+	result, _, err := c.pin(ctx, api.PinWithOpts(h, opts), []peer.ID{})
+	return result, err
+}
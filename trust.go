@@ -0,0 +1,46 @@
+package ipfscluster
+
+import (
+	"context"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+
+	"go.opencensus.io/trace"
+)
+
+// TrustPeer makes every peer in the cluster trust the given peer. The change
+// is recorded through the consensus layer, so it survives restarts and
+// peers converge on it without editing their configuration.
+func (c *Cluster) TrustPeer(ctx context.Context, pid peer.ID) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/TrustPeer")
+	defer span.End()
+
+	if c.config.FollowerMode {
+		return errFollowerMode
+	}
+
+	logger.Infof("recording trust for peer %s", pid)
+	return c.consensus.LogTrust(ctx, pid)
+}
+
+// DistrustPeer makes every peer in the cluster stop trusting the given peer,
+// including those which have it in their configured trusted peers.
+func (c *Cluster) DistrustPeer(ctx context.Context, pid peer.ID) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/DistrustPeer")
+	defer span.End()
+
+	if c.config.FollowerMode {
+		return errFollowerMode
+	}
+
+	logger.Infof("recording distrust for peer %s", pid)
+	return c.consensus.LogDistrust(ctx, pid)
+}
+
+// TrustedPeers returns the peers that this peer currently trusts.
+func (c *Cluster) TrustedPeers(ctx context.Context) ([]peer.ID, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/TrustedPeers")
+	defer span.End()
+
+	return c.consensus.TrustedPeers(ctx)
+}
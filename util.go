@@ -166,6 +166,17 @@ type pingValue struct {
 	Peername      string          `json:"peer_name,omitempty"`
 	IPFSID        peer.ID         `json:"ipfs_id,omitempty"`
 	IPFSAddresses []api.Multiaddr `json:"ipfs_addresses,omitempty"`
+	// Passive is set by peers in PassiveMode, which must not be
+	// allocated any pins.
+	Passive bool `json:"passive,omitempty"`
+	// Quota is set by peers with a storage quota. They are not
+	// allocated new pins once it is exceeded.
+	Quota *quotaUsage `json:"quota,omitempty"`
+	// Operations is the number of heavy operations running in the
+	// peer, set when a GlobalConcurrencyBudget is configured.
+	Operations int `json:"operations,omitempty"`
+	// Region is the region label of the peer.
+	Region string `json:"region,omitempty"`
 }
 
 // Valid returns true if the PingValue has IPFSID set.
@@ -0,0 +1,183 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/ipfs-cluster/ipfs-cluster/api"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	"go.opencensus.io/trace"
+
+	// registers the dag-pb, raw and dag-cbor block decoders.
+	_ "github.com/ipfs/go-merkledag"
+)
+
+// verifyBatchSize is the maximum number of blocks checked on a peer with
+// every RPC call.
+const verifyBatchSize = 1000
+
+// VerifyPin checks that the peers which a pin is allocated to store all the
+// blocks of its DAG, as opposed to having pinned it. The DAG is walked,
+// down to the pin's MaxDepth, by reading the blocks from the local IPFS
+// daemon. Then, the blocks, or the given number of randomly chosen blocks
+// when sample is not 0, are looked up on the IPFS daemons of the allocated
+// peers without fetching them from the network.
+//
+// Only data and shard pins can be verified, as meta and cluster DAG pins
+// are not pinned in IPFS.
+func (c *Cluster) VerifyPin(ctx context.Context, h api.Cid, sample int) (api.PinVerification, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/VerifyPin")
+	defer span.End()
+
+	if sample < 0 {
+		return api.PinVerification{}, errors.New("the sample size cannot be negative")
+	}
+
+	pin, err := c.PinGet(ctx, h)
+	if err != nil {
+		return api.PinVerification{}, err
+	}
+	switch pin.Type {
+	case api.DataType, api.ShardType:
+	default:
+		return api.PinVerification{}, fmt.Errorf("%s pins are not pinned in IPFS: verify their shards instead", pin.Type)
+	}
+
+	dagBlocks, err := c.dagBlocks(ctx, pin.Cid, int(pin.MaxDepth))
+	if err != nil {
+		return api.PinVerification{}, fmt.Errorf("error walking the DAG: %w", err)
+	}
+	checked := dagBlocks
+	if sample > 0 && sample < len(dagBlocks) {
+		checked = make([]api.Cid, sample)
+		for i, j := range rand.Perm(len(dagBlocks))[:sample] {
+			checked[i] = dagBlocks[j]
+		}
+	}
+
+	peers := pin.Allocations
+	if len(peers) == 0 { // pinned everywhere
+		peers, err = c.consensus.Peers(ctx)
+		if err != nil {
+			return api.PinVerification{}, err
+		}
+	}
+
+	res := api.PinVerification{
+		Cid:     pin.Cid,
+		Blocks:  len(dagBlocks),
+		Checked: len(checked),
+		Peers:   make([]api.PeerVerification, len(peers)),
+	}
+
+	var wg sync.WaitGroup
+	for i, p := range peers {
+		wg.Add(1)
+		go func(i int, p peer.ID) {
+			defer wg.Done()
+			res.Peers[i] = c.verifyPeerBlocks(ctx, p, checked)
+		}(i, p)
+	}
+	wg.Wait()
+
+	for _, pv := range res.Peers {
+		switch {
+		case pv.Error != "":
+			logger.Warnf("verify %s: could not check %s: %s", h, pv.Peer, pv.Error)
+		case len(pv.Missing) > 0:
+			logger.Warnf("verify %s: %s is missing %d blocks", h, pv.Peer, len(pv.Missing))
+		}
+	}
+	return res, nil
+}
+
+// verifyPeerBlocks returns which of the given blocks the IPFS daemon of a
+// peer does not store.
+func (c *Cluster) verifyPeerBlocks(ctx context.Context, p peer.ID, checked []api.Cid) api.PeerVerification {
+	pv := api.PeerVerification{
+		Peer:     p,
+		Peername: pingValueFromMetric(c.monitor.LatestForPeer(ctx, pingMetricName, p)).Peername,
+	}
+	if p == c.id {
+		pv.Peername = c.config.Peername
+	}
+
+	for start := 0; start < len(checked); start += verifyBatchSize {
+		end := start + verifyBatchSize
+		if end > len(checked) {
+			end = len(checked)
+		}
+		var missing []api.Cid
+		err := c.rpcClient.CallContext(
+			ctx,
+			p,
+			"IPFSConnector",
+			"BlocksMissing",
+			checked[start:end],
+			&missing,
+		)
+		if err != nil {
+			pv.Error = err.Error()
+			return pv
+		}
+		pv.Missing = append(pv.Missing, missing...)
+	}
+	return pv
+}
+
+// dagBlocks returns the CIDs of the blocks of the DAG under root, down to
+// maxDepth links deep (without limit when negative), reading them from the
+// local IPFS daemon.
+func (c *Cluster) dagBlocks(ctx context.Context, root api.Cid, maxDepth int) ([]api.Cid, error) {
+	seen := map[api.Cid]struct{}{root: {}}
+	dagBlocks := []api.Cid{root}
+	level := []api.Cid{root}
+	for depth := 0; len(level) > 0 && (maxDepth < 0 || depth < maxDepth); depth++ {
+		var next []api.Cid
+		for _, b := range level {
+			links, err := c.blockLinks(ctx, b)
+			if err != nil {
+				return nil, err
+			}
+			for _, l := range links {
+				lc := api.NewCid(l.Cid)
+				if _, ok := seen[lc]; ok {
+					continue
+				}
+				seen[lc] = struct{}{}
+				dagBlocks = append(dagBlocks, lc)
+				next = append(next, lc)
+			}
+		}
+		level = next
+	}
+	return dagBlocks, nil
+}
+
+// blockLinks returns the links of a block read from the local IPFS daemon.
+func (c *Cluster) blockLinks(ctx context.Context, b api.Cid) ([]*ipld.Link, error) {
+	// raw blocks have no links: there is no need to read them.
+	if b.Type() == cid.Raw {
+		return nil, nil
+	}
+	data, err := c.ipfs.BlockGet(ctx, b)
+	if err != nil {
+		return nil, fmt.Errorf("error reading block %s: %w", b, err)
+	}
+	blk, err := blocks.NewBlockWithCid(data, b.Cid)
+	if err != nil {
+		return nil, err
+	}
+	nd, err := ipld.Decode(blk)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding block %s: %w", b, err)
+	}
+	return nd.Links(), nil
+}